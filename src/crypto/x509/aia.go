@@ -0,0 +1,219 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// IssuerRequest is a request for the certificates at a caIssuers URL, made
+// by IssuerChaser to an IssuerFetcher.
+type IssuerRequest struct {
+	// URL is the caIssuers URL, see Certificate.IssuingCertificateURL. Its
+	// scheme is http or https.
+	URL string
+
+	// MaxSize is the maximum size of the response body. The fetcher should
+	// stop reading, and fail, once it is exceeded.
+	MaxSize int64
+}
+
+// IssuerResponse is the result of an IssuerRequest.
+type IssuerResponse struct {
+	// ContentType is the media type of Body, as in the Content-Type header
+	// of an HTTP response.
+	ContentType string
+
+	// Body is a DER encoded certificate, or a DER encoded certs-only
+	// PKCS #7 structure, RFC 5280, Section 4.2.2.1.
+	Body []byte
+}
+
+// An IssuerFetcher retrieves the certificates at caIssuers URLs. It is
+// typically implemented with a net/http.Client, which this package can't
+// depend on.
+type IssuerFetcher interface {
+	FetchIssuer(ctx context.Context, req *IssuerRequest) (*IssuerResponse, error)
+}
+
+// IssuerChaser finds the missing intermediates of a certificate by following
+// the caIssuers URLs of its Authority Information Access extension, also
+// known as AIA chasing.
+//
+// Since the URLs come from the certificate being verified, which is
+// attacker-controlled, chasing is bounded by default: only http and https
+// URLs are fetched, each URL at most once, and the number of fetches, the
+// depth of the chain and the size of the responses are limited. The limits
+// can be tuned, but not disabled.
+//
+// An IssuerChaser is safe for concurrent use if its Fetcher and Store are.
+// Its fields must not be modified after the first call to Chase.
+type IssuerChaser struct {
+	// Fetcher performs the requests.
+	Fetcher IssuerFetcher
+
+	// Store, if not nil, is consulted before fetching, and keeps the
+	// certificates fetched.
+	Store IntermediateStore
+
+	// MaxFetches is the maximum number of requests made by a call to
+	// Chase. If zero, it is 4.
+	MaxFetches int
+	// MaxDepth is the maximum number of issuers returned by Chase. If
+	// zero, it is 4.
+	MaxDepth int
+	// MaxSize is the maximum size of a response body. If zero, it is
+	// 64 KiB.
+	MaxSize int64
+
+	// AllowAnyContentType accepts responses whose content type is not one
+	// of application/pkix-cert and application/pkcs7-mime, or their legacy
+	// names application/x-x509-ca-cert and application/x-pkcs7-certificates.
+	// Their body is then parsed as either. Some servers are misconfigured
+	// to use application/octet-stream.
+	AllowAnyContentType bool
+}
+
+func (ch *IssuerChaser) maxFetches() int {
+	if ch.MaxFetches <= 0 {
+		return 4
+	}
+	return ch.MaxFetches
+}
+
+func (ch *IssuerChaser) maxDepth() int {
+	if ch.MaxDepth <= 0 {
+		return 4
+	}
+	return ch.MaxDepth
+}
+
+func (ch *IssuerChaser) maxSize() int64 {
+	if ch.MaxSize <= 0 {
+		return 64 << 10
+	}
+	return ch.MaxSize
+}
+
+// Chase returns the issuers of cert found by following caIssuers URLs,
+// starting with the issuer of cert. Each returned certificate is a CA
+// certificate with the subject of, and a valid signature on, the previous
+// one. Chasing stops at a self-signed certificate, which is returned too, or
+// at a certificate without caIssuers URLs.
+//
+// No trust decision is made: the result is meant to be added to
+// VerifyOptions.Intermediates. Chase returns an error if a limit is reached,
+// if the issuers form a loop, or if the caIssuers URLs of a certificate don't
+// yield its issuer. The issuers found until then are returned along with the
+// error.
+func (ch *IssuerChaser) Chase(ctx context.Context, cert *Certificate) ([]*Certificate, error) {
+	chain := []*Certificate{cert}
+	fetched := make(map[string]bool)
+	for c := cert; !c.isSelfSigned(); {
+		if len(chain) > ch.maxDepth() {
+			return chain[1:], fmt.Errorf("x509: more than %d issuers found by caIssuers chasing", ch.maxDepth())
+		}
+
+		var issuer *Certificate
+		if ch.Store != nil {
+			if issuers := storedIssuers(c, ch.Store, chain); len(issuers) > 0 {
+				issuer = issuers[0]
+			}
+		}
+		var lastErr error
+		for _, u := range c.IssuingCertificateURL {
+			if issuer != nil {
+				break
+			}
+			if fetched[u] {
+				continue
+			}
+			if len(fetched) == ch.maxFetches() {
+				return chain[1:], fmt.Errorf("x509: more than %d fetches needed for caIssuers chasing", ch.maxFetches())
+			}
+			fetched[u] = true
+			certs, err := ch.fetch(ctx, u)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if ch.Store != nil {
+				// The store is only a cache, failing to update it does
+				// not affect the result.
+				ch.Store.Put(u, certs)
+			}
+			for _, candidate := range certs {
+				if !candidate.IsCACert() || !bytes.Equal(c.RawIssuer, candidate.RawSubject) || c.CheckSignatureFrom(candidate) != nil {
+					continue
+				}
+				if containsCert(chain, candidate) {
+					return chain[1:], fmt.Errorf("x509: caIssuers loop at %q", candidate.Subject)
+				}
+				issuer = candidate
+				break
+			}
+		}
+
+		if issuer == nil {
+			if lastErr != nil {
+				return chain[1:], lastErr
+			}
+			if len(c.IssuingCertificateURL) > 0 {
+				return chain[1:], fmt.Errorf("x509: issuer of %q not found at its caIssuers URLs", c.Subject)
+			}
+			break
+		}
+		chain = append(chain, issuer)
+		c = issuer
+	}
+	return chain[1:], nil
+}
+
+// fetch returns the certificates at the caIssuers URL u.
+func (ch *IssuerChaser) fetch(ctx context.Context, u string) ([]*Certificate, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("x509: invalid caIssuers URL %q: %v", u, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("x509: unsupported scheme in caIssuers URL %q", u)
+	}
+
+	resp, err := ch.Fetcher.FetchIssuer(ctx, &IssuerRequest{URL: u, MaxSize: ch.maxSize()})
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(resp.Body)) > ch.maxSize() {
+		return nil, fmt.Errorf("x509: response from caIssuers URL %q is larger than %d bytes", u, ch.maxSize())
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(resp.ContentType, ";")[0]))
+	switch mediaType {
+	case "application/pkix-cert", "application/x-x509-ca-cert":
+		cert, err := ParseCertificate(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return []*Certificate{cert}, nil
+	case "application/pkcs7-mime", "application/x-pkcs7-certificates":
+		return ParsePKCS7Certificates(resp.Body)
+	}
+	if !ch.AllowAnyContentType {
+		return nil, fmt.Errorf("x509: unexpected content type %q from caIssuers URL %q", resp.ContentType, u)
+	}
+	if cert, err := ParseCertificate(resp.Body); err == nil {
+		return []*Certificate{cert}, nil
+	}
+	certs, err := ParsePKCS7Certificates(resp.Body)
+	if err != nil {
+		return nil, errors.New("x509: response from caIssuers URL is neither a certificate nor a PKCS #7 structure")
+	}
+	return certs, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+type fakeIssuerFetcher struct {
+	responses map[string]*IssuerResponse
+	requests  []string
+}
+
+func (f *fakeIssuerFetcher) FetchIssuer(ctx context.Context, req *IssuerRequest) (*IssuerResponse, error) {
+	f.requests = append(f.requests, req.URL)
+	resp, ok := f.responses[req.URL]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return resp, nil
+}
+
+func TestIssuerChaser(t *testing.T) {
+	newCert := func(cn string, key, parentKey crypto.Signer, parent *Certificate, isCA bool, issuerURL string) *Certificate {
+		template := &Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              KeyUsageCertSign | KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if issuerURL != "" {
+			template.IssuingCertificateURL = []string{issuerURL}
+		}
+		if parent == nil {
+			parent = template
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	newKey := func() crypto.Signer {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	rootKey, interKey, subKey, leafKey := newKey(), newKey(), newKey(), newKey()
+	root := newCert("Root", rootKey, rootKey, nil, true, "")
+	inter := newCert("Intermediate", interKey, rootKey, root, true, "http://root.example/root.crt")
+	sub := newCert("Sub", subKey, interKey, inter, true, "http://inter.example/inter.p7c")
+	leaf := newCert("Leaf", leafKey, subKey, sub, false, "http://sub.example/sub.crt")
+
+	pkcs7, err := MarshalPKCS7Certificates([]*Certificate{inter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := map[string]*IssuerResponse{
+		"http://sub.example/sub.crt":     {ContentType: "application/pkix-cert", Body: sub.Raw},
+		"http://inter.example/inter.p7c": {ContentType: "application/pkcs7-mime", Body: pkcs7},
+		"http://root.example/root.crt":   {ContentType: "application/x-x509-ca-cert; charset=binary", Body: root.Raw},
+	}
+
+	chaser := &IssuerChaser{Fetcher: &fakeIssuerFetcher{responses: responses}}
+	issuers, err := chaser.Chase(context.Background(), leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*Certificate{sub, inter, root}; !equalChains(issuers, want) {
+		t.Errorf("got %v, want %v", chainToDebugString(issuers), chainToDebugString(want))
+	}
+
+	tests := []struct {
+		name    string
+		chaser  IssuerChaser
+		edit    func(map[string]*IssuerResponse)
+		issuers int
+	}{
+		{"max fetches", IssuerChaser{MaxFetches: 2}, nil, 2},
+		{"max depth", IssuerChaser{MaxDepth: 1}, nil, 1},
+		{"max size", IssuerChaser{MaxSize: int64(len(sub.Raw) - 1)}, nil, 0},
+		{"content type", IssuerChaser{}, func(m map[string]*IssuerResponse) {
+			m["http://inter.example/inter.p7c"] = &IssuerResponse{ContentType: "text/html", Body: pkcs7}
+		}, 1},
+		{"wrong issuer", IssuerChaser{}, func(m map[string]*IssuerResponse) {
+			m["http://inter.example/inter.p7c"] = &IssuerResponse{ContentType: "application/pkix-cert", Body: root.Raw}
+		}, 1},
+		{"not found", IssuerChaser{}, func(m map[string]*IssuerResponse) {
+			delete(m, "http://root.example/root.crt")
+		}, 2},
+	}
+	for _, tt := range tests {
+		m := make(map[string]*IssuerResponse)
+		for u, resp := range responses {
+			m[u] = resp
+		}
+		if tt.edit != nil {
+			tt.edit(m)
+		}
+		fetcher := &fakeIssuerFetcher{responses: m}
+		tt.chaser.Fetcher = fetcher
+		issuers, err := tt.chaser.Chase(context.Background(), leaf)
+		if err == nil {
+			t.Errorf("%s: Chase succeeded", tt.name)
+		}
+		if len(issuers) != tt.issuers {
+			t.Errorf("%s: got %d issuers, want %d", tt.name, len(issuers), tt.issuers)
+		}
+		if len(fetcher.requests) > 3 {
+			t.Errorf("%s: made %d requests", tt.name, len(fetcher.requests))
+		}
+	}
+
+	// Misconfigured servers can be accepted.
+	octetStream := map[string]*IssuerResponse{
+		"http://sub.example/sub.crt": {ContentType: "application/octet-stream", Body: sub.Raw},
+	}
+	chaser = &IssuerChaser{Fetcher: &fakeIssuerFetcher{responses: octetStream}, AllowAnyContentType: true, MaxDepth: 1}
+	if issuers, err := chaser.Chase(context.Background(), leaf); len(issuers) != 1 || !issuers[0].Equal(sub) {
+		t.Errorf("AllowAnyContentType: got %v, %v", chainToDebugString(issuers), err)
+	}
+
+	// Only http and https URLs are fetched.
+	ldap := newCert("Leaf", leafKey, subKey, sub, false, "ldap://sub.example/cn=Sub")
+	fetcher := &fakeIssuerFetcher{responses: responses}
+	chaser = &IssuerChaser{Fetcher: fetcher}
+	if _, err := chaser.Chase(context.Background(), ldap); err == nil || len(fetcher.requests) != 0 {
+		t.Errorf("ldap URL: got %v after %d requests", err, len(fetcher.requests))
+	}
+
+	// Two CAs that point at each other form a loop.
+	aKey, bKey := newKey(), newKey()
+	a := newCert("A", aKey, aKey, nil, true, "")
+	b := newCert("B", bKey, aKey, a, true, "http://a.example/a.crt")
+	aByB := newCert("A", aKey, bKey, b, true, "http://b.example/b.crt")
+	loopLeaf := newCert("Leaf", leafKey, aKey, aByB, false, "http://b.example/a.crt")
+	fetcher = &fakeIssuerFetcher{responses: map[string]*IssuerResponse{
+		"http://b.example/a.crt": {ContentType: "application/pkix-cert", Body: aByB.Raw},
+		"http://b.example/b.crt": {ContentType: "application/pkix-cert", Body: b.Raw},
+		"http://a.example/a.crt": {ContentType: "application/pkix-cert", Body: aByB.Raw},
+	}}
+	chaser = &IssuerChaser{Fetcher: fetcher, MaxFetches: 10, MaxDepth: 10}
+	if issuers, err := chaser.Chase(context.Background(), loopLeaf); err == nil || len(issuers) != 2 {
+		t.Errorf("loop: got %v, %v", chainToDebugString(issuers), err)
+	}
+
+	// With a store, certificates are fetched only once.
+	dir, err := ioutil.TempDir("", "x509-intermediates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fetcher = &fakeIssuerFetcher{responses: responses}
+	chaser = &IssuerChaser{Fetcher: fetcher, Store: &DiskIntermediateStore{Dir: dir}}
+	for i := 0; i < 2; i++ {
+		if issuers, err := chaser.Chase(context.Background(), leaf); err != nil || len(issuers) != 3 {
+			t.Errorf("with store: got %v, %v", chainToDebugString(issuers), err)
+		}
+	}
+	if len(fetcher.requests) != 3 {
+		t.Errorf("with store: made %d requests, want 3", len(fetcher.requests))
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var (
+	// oidExtensionAndroidKeyDescription is the Android key attestation
+	// extension, see
+	// https://source.android.com/docs/security/features/keystore/attestation.
+	oidExtensionAndroidKeyDescription = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 17}
+	// oidExtensionAppleAttestationNonce holds the nonce of Apple App Attest
+	// and Apple anonymous attestation credential certificates.
+	oidExtensionAppleAttestationNonce = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}
+)
+
+// AndroidSecurityLevel is the kind of environment an Android key or its
+// attestation lives in.
+type AndroidSecurityLevel int
+
+const (
+	AndroidSecurityLevelSoftware           AndroidSecurityLevel = 0
+	AndroidSecurityLevelTrustedEnvironment AndroidSecurityLevel = 1
+	AndroidSecurityLevelStrongBox          AndroidSecurityLevel = 2
+)
+
+func (l AndroidSecurityLevel) String() string {
+	switch l {
+	case AndroidSecurityLevelSoftware:
+		return "Software"
+	case AndroidSecurityLevelTrustedEnvironment:
+		return "TrustedEnvironment"
+	case AndroidSecurityLevelStrongBox:
+		return "StrongBox"
+	}
+	return fmt.Sprintf("AndroidSecurityLevel(%d)", int(l))
+}
+
+// AndroidVerifiedBootState is the verified boot state of an Android device.
+type AndroidVerifiedBootState int
+
+const (
+	// AndroidVerifiedBootVerified means the device booted software signed
+	// by the device manufacturer.
+	AndroidVerifiedBootVerified AndroidVerifiedBootState = 0
+	// AndroidVerifiedBootSelfSigned means the device booted software signed
+	// by a key configured by the user.
+	AndroidVerifiedBootSelfSigned AndroidVerifiedBootState = 1
+	// AndroidVerifiedBootUnverified means the bootloader is unlocked, and
+	// the device can run any software.
+	AndroidVerifiedBootUnverified AndroidVerifiedBootState = 2
+	// AndroidVerifiedBootFailed means the verification of the booted
+	// software failed.
+	AndroidVerifiedBootFailed AndroidVerifiedBootState = 3
+)
+
+func (s AndroidVerifiedBootState) String() string {
+	switch s {
+	case AndroidVerifiedBootVerified:
+		return "Verified"
+	case AndroidVerifiedBootSelfSigned:
+		return "SelfSigned"
+	case AndroidVerifiedBootUnverified:
+		return "Unverified"
+	case AndroidVerifiedBootFailed:
+		return "Failed"
+	}
+	return fmt.Sprintf("AndroidVerifiedBootState(%d)", int(s))
+}
+
+// AndroidKeyDescription is the Android key attestation extension of a
+// certificate, which describes the attested key and the device holding it.
+type AndroidKeyDescription struct {
+	AttestationVersion       int
+	AttestationSecurityLevel AndroidSecurityLevel
+	// KeyMintVersion is the version of the KeyMint, formerly Keymaster,
+	// implementation.
+	KeyMintVersion       int
+	KeyMintSecurityLevel AndroidSecurityLevel
+	// AttestationChallenge is the challenge passed by the application when
+	// it requested the attestation, which the verifier must check.
+	AttestationChallenge []byte
+	UniqueID             []byte
+
+	// SoftwareEnforced and HardwareEnforced are the authorizations of the
+	// key enforced by the operating system and by the secure hardware.
+	// Only HardwareEnforced can be trusted if the operating system might be
+	// compromised.
+	SoftwareEnforced AndroidAuthorizationList
+	HardwareEnforced AndroidAuthorizationList
+}
+
+// AndroidAuthorizationList is a list of authorizations of an attested
+// Android key. Each field is set from the entry with the tag given in its
+// comment. The values of the enumerations are those of the KeyMint HAL.
+type AndroidAuthorizationList struct {
+	// Tags are the tags of the entries of the list, in order. They tell
+	// absent entries from those with a zero value.
+	Tags []int
+
+	Purposes          []int     // [1]
+	Algorithm         int       // [2]
+	KeySize           int       // [3]
+	Digests           []int     // [5]
+	Paddings          []int     // [6]
+	ECCurve           int       // [10]
+	RSAPublicExponent int64     // [200]
+	NoAuthRequired    bool      // [503]
+	UserAuthType      int       // [504]
+	AuthTimeout       int       // [505]
+	CreationTime      time.Time // [701]
+	Origin            int       // [702]
+	// RootOfTrust is only present in HardwareEnforced.
+	RootOfTrust              *AndroidRootOfTrust              // [704]
+	OSVersion                int                              // [705]
+	OSPatchLevel             int                              // [706]
+	AttestationApplicationID *AndroidAttestationApplicationID // [709]
+	VendorPatchLevel         int                              // [718]
+	BootPatchLevel           int                              // [719]
+
+	// Other holds the entries with other tags, undecoded.
+	Other []asn1.RawValue
+}
+
+// Has reports whether the list has an entry with the given tag.
+func (l *AndroidAuthorizationList) Has(tag int) bool {
+	for _, t := range l.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AndroidRootOfTrust describes the verified boot state of the device holding
+// an attested Android key.
+type AndroidRootOfTrust struct {
+	// VerifiedBootKey is the hash of the key that verifies the booted
+	// software.
+	VerifiedBootKey   []byte
+	DeviceLocked      bool
+	VerifiedBootState AndroidVerifiedBootState
+	// VerifiedBootHash is the hash of the verified software, from
+	// attestation version 3.
+	VerifiedBootHash []byte
+}
+
+// AndroidAttestationApplicationID identifies the applications that may use
+// an attested Android key.
+type AndroidAttestationApplicationID struct {
+	Packages []AndroidPackageInfo
+	// SignatureDigests are the SHA-256 hashes of the signing certificates
+	// of the applications.
+	SignatureDigests [][]byte
+}
+
+// AndroidPackageInfo is a package of an AndroidAttestationApplicationID.
+type AndroidPackageInfo struct {
+	Name    string
+	Version int64
+}
+
+// AndroidKeyDescription returns the Android key attestation extension of c,
+// OID 1.3.6.1.4.1.11129.2.1.17.
+//
+// It only decodes the extension: the attestation can only be trusted if the
+// chain of c verifies to a Google attestation root, for example with the
+// options returned by AndroidKeyAttestationVerifyOptions, and if the
+// AttestationChallenge is the one the verifier issued.
+func (c *Certificate) AndroidKeyDescription() (*AndroidKeyDescription, error) {
+	for _, e := range c.Extensions {
+		if e.Id.Equal(oidExtensionAndroidKeyDescription) {
+			return parseAndroidKeyDescription(e.Value)
+		}
+	}
+	return nil, errors.New("x509: certificate has no Android key attestation extension")
+}
+
+func parseAndroidKeyDescription(der []byte) (*AndroidKeyDescription, error) {
+	// KeyDescription ::= SEQUENCE {
+	//     attestationVersion         INTEGER,
+	//     attestationSecurityLevel   SecurityLevel,
+	//     keyMintVersion             INTEGER,
+	//     keyMintSecurityLevel       SecurityLevel,
+	//     attestationChallenge       OCTET_STRING,
+	//     uniqueId                   OCTET_STRING,
+	//     softwareEnforced           AuthorizationList,
+	//     hardwareEnforced           AuthorizationList }
+	//
+	// The tags of AuthorizationList entries go beyond 30, which cryptobyte
+	// doesn't support, so the extension is parsed with encoding/asn1.
+	var kd struct {
+		AttestationVersion       int
+		AttestationSecurityLevel asn1.Enumerated
+		KeyMintVersion           int
+		KeyMintSecurityLevel     asn1.Enumerated
+		AttestationChallenge     []byte
+		UniqueID                 []byte
+		SoftwareEnforced         asn1.RawValue
+		HardwareEnforced         asn1.RawValue
+	}
+	if rest, err := asn1.Unmarshal(der, &kd); err != nil || len(rest) != 0 {
+		return nil, errors.New("x509: invalid Android key attestation extension")
+	}
+	out := &AndroidKeyDescription{
+		AttestationVersion:       kd.AttestationVersion,
+		AttestationSecurityLevel: AndroidSecurityLevel(kd.AttestationSecurityLevel),
+		KeyMintVersion:           kd.KeyMintVersion,
+		KeyMintSecurityLevel:     AndroidSecurityLevel(kd.KeyMintSecurityLevel),
+		AttestationChallenge:     kd.AttestationChallenge,
+		UniqueID:                 kd.UniqueID,
+	}
+	var err error
+	if out.SoftwareEnforced, err = parseAndroidAuthorizationList(kd.SoftwareEnforced); err != nil {
+		return nil, err
+	}
+	if out.HardwareEnforced, err = parseAndroidAuthorizationList(kd.HardwareEnforced); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parseAndroidAuthorizationList(list asn1.RawValue) (AndroidAuthorizationList, error) {
+	errInvalid := errors.New("x509: invalid Android key attestation authorization list")
+
+	var out AndroidAuthorizationList
+	if list.Class != asn1.ClassUniversal || list.Tag != asn1.TagSequence || !list.IsCompound {
+		return out, errInvalid
+	}
+	rest := list.Bytes
+	for len(rest) > 0 {
+		var entry asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &entry); err != nil {
+			return out, errInvalid
+		}
+		if entry.Class != asn1.ClassContextSpecific || !entry.IsCompound {
+			return out, errInvalid
+		}
+		out.Tags = append(out.Tags, entry.Tag)
+
+		// Every entry is EXPLICIT tagged.
+		var ms int64
+		switch entry.Tag {
+		case 1:
+			err = unmarshalExplicit(entry.Bytes, &out.Purposes, "set")
+		case 2:
+			err = unmarshalExplicit(entry.Bytes, &out.Algorithm, "")
+		case 3:
+			err = unmarshalExplicit(entry.Bytes, &out.KeySize, "")
+		case 5:
+			err = unmarshalExplicit(entry.Bytes, &out.Digests, "set")
+		case 6:
+			err = unmarshalExplicit(entry.Bytes, &out.Paddings, "set")
+		case 10:
+			err = unmarshalExplicit(entry.Bytes, &out.ECCurve, "")
+		case 200:
+			err = unmarshalExplicit(entry.Bytes, &out.RSAPublicExponent, "")
+		case 503:
+			err = unmarshalExplicit(entry.Bytes, &asn1.RawValue{}, "")
+			out.NoAuthRequired = true
+		case 504:
+			err = unmarshalExplicit(entry.Bytes, &out.UserAuthType, "")
+		case 505:
+			err = unmarshalExplicit(entry.Bytes, &out.AuthTimeout, "")
+		case 701:
+			// Milliseconds since the Unix epoch.
+			err = unmarshalExplicit(entry.Bytes, &ms, "")
+			out.CreationTime = time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+		case 702:
+			err = unmarshalExplicit(entry.Bytes, &out.Origin, "")
+		case 704:
+			var rot struct {
+				VerifiedBootKey   []byte
+				DeviceLocked      bool
+				VerifiedBootState asn1.Enumerated
+				VerifiedBootHash  []byte `asn1:"optional"`
+			}
+			err = unmarshalExplicit(entry.Bytes, &rot, "")
+			out.RootOfTrust = &AndroidRootOfTrust{
+				VerifiedBootKey:   rot.VerifiedBootKey,
+				DeviceLocked:      rot.DeviceLocked,
+				VerifiedBootState: AndroidVerifiedBootState(rot.VerifiedBootState),
+				VerifiedBootHash:  rot.VerifiedBootHash,
+			}
+		case 705:
+			err = unmarshalExplicit(entry.Bytes, &out.OSVersion, "")
+		case 706:
+			err = unmarshalExplicit(entry.Bytes, &out.OSPatchLevel, "")
+		case 709:
+			var id []byte
+			if err = unmarshalExplicit(entry.Bytes, &id, ""); err == nil {
+				out.AttestationApplicationID, err = parseAndroidAttestationApplicationID(id)
+			}
+		case 718:
+			err = unmarshalExplicit(entry.Bytes, &out.VendorPatchLevel, "")
+		case 719:
+			err = unmarshalExplicit(entry.Bytes, &out.BootPatchLevel, "")
+		default:
+			out.Other = append(out.Other, entry)
+		}
+		if err != nil {
+			return out, fmt.Errorf("x509: invalid Android key attestation authorization [%d]: %v", entry.Tag, err)
+		}
+	}
+	return out, nil
+}
+
+// unmarshalExplicit parses the contents of an EXPLICIT tagged element, which
+// must be a single element, into out.
+func unmarshalExplicit(contents []byte, out interface{}, params string) error {
+	rest, err := asn1.UnmarshalWithParams(contents, out, params)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func parseAndroidAttestationApplicationID(der []byte) (*AndroidAttestationApplicationID, error) {
+	// AttestationApplicationId ::= SEQUENCE {
+	//     package_infos      SET OF AttestationPackageInfo,
+	//     signature_digests  SET OF OCTET_STRING }
+	//
+	// AttestationPackageInfo ::= SEQUENCE {
+	//     package_name  OCTET_STRING,
+	//     version       INTEGER }
+	var id struct {
+		PackageInfos []struct {
+			Name    []byte
+			Version int64
+		} `asn1:"set"`
+		SignatureDigests [][]byte `asn1:"set"`
+	}
+	if rest, err := asn1.Unmarshal(der, &id); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("trailing data")
+	}
+	out := &AndroidAttestationApplicationID{SignatureDigests: id.SignatureDigests}
+	for _, p := range id.PackageInfos {
+		out.Packages = append(out.Packages, AndroidPackageInfo{string(p.Name), p.Version})
+	}
+	return out, nil
+}
+
+// AppleAttestationNonce returns the nonce of an Apple App Attest or Apple
+// anonymous attestation credential certificate, from the extension with OID
+// 1.2.840.113635.100.8.2. For App Attest, the verifier must check that it is
+// the SHA-256 hash of the authenticator data followed by the hash of the
+// client data.
+//
+// The attestation can only be trusted if the chain of c verifies to the
+// Apple attestation root, for example with the options returned by
+// AppleAttestationVerifyOptions. Decoding the CBOR attestation object that
+// carries the chain is left to the caller.
+func (c *Certificate) AppleAttestationNonce() ([]byte, error) {
+	for _, e := range c.Extensions {
+		if !e.Id.Equal(oidExtensionAppleAttestationNonce) {
+			continue
+		}
+		// SEQUENCE { nonce [1] EXPLICIT OCTET STRING }
+		input := cryptobyte.String(e.Value)
+		var seq, explicit, nonce cryptobyte.String
+		if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+			!seq.ReadASN1(&explicit, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) || !seq.Empty() ||
+			!explicit.ReadASN1(&nonce, cryptobyte_asn1.OCTET_STRING) || !explicit.Empty() {
+			return nil, errors.New("x509: invalid Apple attestation nonce extension")
+		}
+		return nonce, nil
+	}
+	return nil, errors.New("x509: certificate has no Apple attestation nonce extension")
+}
+
+// AndroidKeyAttestationVerifyOptions returns VerifyOptions for the
+// certificate chain of an Android key attestation, to which the
+// intermediates of the chain must be added. roots must hold the Google
+// Hardware Attestation Root certificates, which Google publishes in the
+// Android documentation. Attestation certificates have no extended key
+// usages, so KeyUsages is ExtKeyUsageAny.
+//
+// Google also publishes a list of revoked attestation keys, which Verify
+// doesn't check.
+func AndroidKeyAttestationVerifyOptions(roots *CertPool) VerifyOptions {
+	opts := NewVerifyOptions()
+	opts.Roots = roots
+	opts.KeyUsages = []ExtKeyUsage{ExtKeyUsageAny}
+	return opts
+}
+
+// AppleAttestationVerifyOptions returns VerifyOptions for the certificate
+// chain of an Apple App Attest or anonymous attestation, to which the
+// intermediates of the chain must be added. roots must hold the Apple App
+// Attestation Root CA or the Apple WebAuthn Root CA certificate, which Apple
+// publishes on its Private PKI page. Credential certificates have no
+// extended key usages, so KeyUsages is ExtKeyUsageAny.
+func AppleAttestationVerifyOptions(roots *CertPool) VerifyOptions {
+	opts := NewVerifyOptions()
+	opts.Roots = roots
+	opts.KeyUsages = []ExtKeyUsage{ExtKeyUsageAny}
+	return opts
+}
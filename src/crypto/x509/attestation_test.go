@@ -0,0 +1,216 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// explicitEntry returns an AuthorizationList entry with the given tag.
+func explicitEntry(t *testing.T, tag int, value interface{}, params string) asn1.RawValue {
+	der, err := asn1.MarshalWithParams(value, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: der}
+}
+
+func TestAndroidKeyDescription(t *testing.T) {
+	appID, err := asn1.Marshal(struct {
+		PackageInfos []struct {
+			Name    []byte
+			Version int64
+		} `asn1:"set"`
+		SignatureDigests [][]byte `asn1:"set"`
+	}{
+		PackageInfos: []struct {
+			Name    []byte
+			Version int64
+		}{{[]byte("com.example.app"), 42}},
+		SignatureDigests: [][]byte{bytes.Repeat([]byte{1}, 32)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type rootOfTrust struct {
+		VerifiedBootKey   []byte
+		DeviceLocked      bool
+		VerifiedBootState asn1.Enumerated
+		VerifiedBootHash  []byte
+	}
+	null := asn1.RawValue{Tag: asn1.TagNull}
+	software := []asn1.RawValue{
+		explicitEntry(t, 701, int64(1577836800123), ""),
+		explicitEntry(t, 709, appID, ""),
+	}
+	hardware := []asn1.RawValue{
+		explicitEntry(t, 1, []int{2, 3}, "set"),
+		explicitEntry(t, 2, 3, ""),
+		explicitEntry(t, 3, 256, ""),
+		explicitEntry(t, 5, []int{4}, "set"),
+		explicitEntry(t, 10, 1, ""),
+		explicitEntry(t, 503, null, ""),
+		explicitEntry(t, 702, 0, ""),
+		explicitEntry(t, 704, rootOfTrust{[]byte("boot key"), true, 0, []byte("boot hash")}, ""),
+		explicitEntry(t, 705, 110000, ""),
+		explicitEntry(t, 706, 202009, ""),
+		explicitEntry(t, 710, []byte("brand"), ""),
+		explicitEntry(t, 719, 20200905, ""),
+	}
+	value, err := asn1.Marshal(struct {
+		AttestationVersion       int
+		AttestationSecurityLevel asn1.Enumerated
+		KeyMintVersion           int
+		KeyMintSecurityLevel     asn1.Enumerated
+		AttestationChallenge     []byte
+		UniqueID                 []byte
+		SoftwareEnforced         []asn1.RawValue
+		HardwareEnforced         []asn1.RawValue
+	}{4, 1, 41, 2, []byte("challenge"), []byte{}, software, hardware})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "Android Keystore Key"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionAndroidKeyDescription, Value: value}},
+	}, root, key.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kd, err := leaf.AndroidKeyDescription()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kd.AttestationVersion != 4 || kd.AttestationSecurityLevel != AndroidSecurityLevelTrustedEnvironment ||
+		kd.KeyMintVersion != 41 || kd.KeyMintSecurityLevel != AndroidSecurityLevelStrongBox ||
+		string(kd.AttestationChallenge) != "challenge" || len(kd.UniqueID) != 0 {
+		t.Errorf("got key description %+v", kd)
+	}
+
+	sw := kd.SoftwareEnforced
+	if !sw.CreationTime.Equal(time.Unix(1577836800, 123e6)) {
+		t.Errorf("CreationTime = %v", sw.CreationTime)
+	}
+	if id := sw.AttestationApplicationID; id == nil || len(id.Packages) != 1 ||
+		id.Packages[0] != (AndroidPackageInfo{"com.example.app", 42}) ||
+		len(id.SignatureDigests) != 1 || len(id.SignatureDigests[0]) != 32 {
+		t.Errorf("AttestationApplicationID = %+v", sw.AttestationApplicationID)
+	}
+
+	hw := kd.HardwareEnforced
+	if len(hw.Purposes) != 2 || hw.Purposes[1] != 3 || hw.Algorithm != 3 || hw.KeySize != 256 ||
+		len(hw.Digests) != 1 || hw.Digests[0] != 4 || hw.ECCurve != 1 || !hw.NoAuthRequired ||
+		hw.OSVersion != 110000 || hw.OSPatchLevel != 202009 || hw.BootPatchLevel != 20200905 {
+		t.Errorf("got hardware enforced list %+v", hw)
+	}
+	if !hw.Has(702) || hw.Origin != 0 || sw.Has(702) {
+		t.Errorf("origin: hardware %v, software %v", hw.Has(702), sw.Has(702))
+	}
+	if rot := hw.RootOfTrust; rot == nil || string(rot.VerifiedBootKey) != "boot key" || !rot.DeviceLocked ||
+		rot.VerifiedBootState != AndroidVerifiedBootVerified || string(rot.VerifiedBootHash) != "boot hash" {
+		t.Errorf("RootOfTrust = %+v", hw.RootOfTrust)
+	}
+	if len(hw.Other) != 1 || hw.Other[0].Tag != 710 {
+		t.Errorf("Other = %v", hw.Other)
+	}
+
+	// The chain has no extended key usages.
+	roots := NewCertPool()
+	roots.AddCert(root)
+	if _, err := leaf.Verify(AndroidKeyAttestationVerifyOptions(roots)); err != nil {
+		t.Errorf("AndroidKeyAttestationVerifyOptions: %v", err)
+	}
+
+	if _, err := root.AndroidKeyDescription(); err == nil {
+		t.Error("AndroidKeyDescription succeeded without the extension")
+	}
+	if _, err := parseAndroidKeyDescription(value[:len(value)-1]); err == nil {
+		t.Error("truncated key description was accepted")
+	}
+	bad, err := asn1.Marshal(explicitEntry(t, 2, []byte("RSA"), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseAndroidAuthorizationList(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: bad}); err == nil {
+		t.Error("authorization list with a malformed entry was accepted")
+	}
+}
+
+func TestAppleAttestationNonce(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0xaa}, 32)
+	value, err := asn1.Marshal(struct {
+		Nonce []byte `asn1:"explicit,tag:1"`
+	}{nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, rootKey, err := generateCert("Apple App Attestation Root CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "credential"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionAppleAttestationNonce, Value: value}},
+	}, root, key.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := leaf.AppleAttestationNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, nonce) {
+		t.Errorf("got nonce %x, want %x", got, nonce)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	if _, err := leaf.Verify(AppleAttestationVerifyOptions(roots)); err != nil {
+		t.Errorf("AppleAttestationVerifyOptions: %v", err)
+	}
+
+	if _, err := root.AppleAttestationNonce(); err == nil {
+		t.Error("AppleAttestationNonce succeeded without the extension")
+	}
+	leaf.Extensions[len(leaf.Extensions)-1].Value = nonce
+	if _, err := leaf.AppleAttestationNonce(); err == nil {
+		t.Error("malformed nonce extension was accepted")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 3739, Section 3.2.2
+//
+// id-pe-biometricInfo OBJECT IDENTIFIER ::= {id-pe 2}
+var oidExtensionBiometricInfo = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 2}
+
+// Predefined biometric types, see RFC 3739, Section 3.2.2.
+const (
+	BiometricPicture              = 0
+	BiometricHandwrittenSignature = 1
+)
+
+// BiometricData is an entry of the qualified certificate biometricInfo
+// extension defined in RFC 3739, Section 3.2.2. It binds a hash of biometric
+// information, such as a picture of the subject, to the certificate.
+type BiometricData struct {
+	// Type is the predefined type of the biometric data, one of
+	// BiometricPicture or BiometricHandwrittenSignature. It is ignored if
+	// TypeOID is set.
+	Type int
+	// TypeOID identifies the type of the biometric data if it is not one of
+	// the predefined types.
+	TypeOID asn1.ObjectIdentifier
+
+	// HashAlgorithm identifies the algorithm used to compute Hash.
+	HashAlgorithm pkix.AlgorithmIdentifier
+	// Hash is the hash of the biometric data.
+	Hash []byte
+	// SourceDataURI optionally references the biometric data itself.
+	SourceDataURI string
+}
+
+func parseBiometricInfoExtension(der []byte) ([]BiometricData, error) {
+	// BiometricSyntax ::= SEQUENCE OF BiometricData
+	//
+	// BiometricData ::= SEQUENCE {
+	//      typeOfBiometricData TypeOfBiometricData,
+	//      hashAlgorithm       AlgorithmIdentifier,
+	//      biometricDataHash   OCTET STRING,
+	//      sourceDataUri       IA5String OPTIONAL }
+	//
+	// TypeOfBiometricData ::= CHOICE {
+	//      predefinedBiometricType PredefinedBiometricType,
+	//      biometricDataOid        OBJECT IDENTIFIER }
+	errInvalid := errors.New("x509: invalid biometricInfo extension")
+
+	input := cryptobyte.String(der)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, errInvalid
+	}
+
+	var out []BiometricData
+	for !seq.Empty() {
+		var data, hashAlg, hash cryptobyte.String
+		if !seq.ReadASN1(&data, cryptobyte_asn1.SEQUENCE) {
+			return nil, errInvalid
+		}
+
+		var bd BiometricData
+		switch {
+		case data.PeekASN1Tag(cryptobyte_asn1.INTEGER):
+			if !data.ReadASN1Integer(&bd.Type) {
+				return nil, errInvalid
+			}
+		case data.PeekASN1Tag(cryptobyte_asn1.OBJECT_IDENTIFIER):
+			if !data.ReadASN1ObjectIdentifier(&bd.TypeOID) {
+				return nil, errInvalid
+			}
+		default:
+			return nil, errInvalid
+		}
+
+		if !data.ReadASN1Element(&hashAlg, cryptobyte_asn1.SEQUENCE) {
+			return nil, errInvalid
+		}
+		if rest, err := asn1.Unmarshal(hashAlg, &bd.HashAlgorithm); err != nil || len(rest) != 0 {
+			return nil, errInvalid
+		}
+		if !data.ReadASN1(&hash, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errInvalid
+		}
+		bd.Hash = append([]byte(nil), hash...)
+
+		var uri cryptobyte.String
+		var hasURI bool
+		if !data.ReadOptionalASN1(&uri, &hasURI, cryptobyte_asn1.IA5String) || !data.Empty() {
+			return nil, errInvalid
+		}
+		if hasURI {
+			bd.SourceDataURI = string(uri)
+			if err := isIA5String(bd.SourceDataURI); err != nil {
+				return nil, errInvalid
+			}
+		}
+
+		out = append(out, bd)
+	}
+
+	return out, nil
+}
+
+func marshalBiometricInfo(data []BiometricData) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for _, bd := range data {
+			hashAlg, err := asn1.Marshal(bd.HashAlgorithm)
+			if err != nil {
+				b.SetError(err)
+				return
+			}
+			if err := isIA5String(bd.SourceDataURI); err != nil {
+				b.SetError(err)
+				return
+			}
+
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				if len(bd.TypeOID) > 0 {
+					b.AddASN1ObjectIdentifier(bd.TypeOID)
+				} else {
+					b.AddASN1Int64(int64(bd.Type))
+				}
+				b.AddBytes(hashAlg)
+				b.AddASN1OctetString(bd.Hash)
+				if len(bd.SourceDataURI) > 0 {
+					b.AddASN1(cryptobyte_asn1.IA5String, func(b *cryptobyte.Builder) {
+						b.AddBytes([]byte(bd.SourceDataURI))
+					})
+				}
+			})
+		}
+	})
+	return b.Bytes()
+}
@@ -0,0 +1,230 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A BundleAnchor is a certificate of a PEM bundle compared by DiffBundles.
+type BundleAnchor struct {
+	Certificate *Certificate
+	// Subject is the subject of Certificate, in the form of
+	// pkix.Name.String.
+	Subject string
+	// SHA256 is the hex encoded SHA-256 fingerprint of the DER certificate.
+	SHA256 string
+	// Trust is the trust attributes of the certificate, if it was in a
+	// "TRUSTED CERTIFICATE" PEM block that has them.
+	Trust *TrustAttributes
+
+	rawTrust []byte
+}
+
+func (a BundleAnchor) String() string {
+	return fmt.Sprintf("%s (SHA-256 %s)", a.Subject, a.SHA256)
+}
+
+// A BundleChange is a trust anchor that changed between two bundles: a
+// certificate for the same subject and public key was replaced, or its trust
+// attributes changed.
+type BundleChange struct {
+	Old, New BundleAnchor
+	// Fields lists what changed, among "NotBefore", "NotAfter",
+	// "SerialNumber", "SignatureAlgorithm", "Extensions", "Encoding", for
+	// other differences in the certificate, and "Trust".
+	Fields []string
+}
+
+// BundleDiff is the result of DiffBundles. Each list is sorted by subject,
+// then by fingerprint.
+type BundleDiff struct {
+	Added   []BundleAnchor
+	Removed []BundleAnchor
+	Changed []BundleChange
+}
+
+// Empty reports whether the bundles hold the same trust anchors.
+func (d BundleDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns one line per added, removed and changed anchor, suitable
+// for alerts and logs.
+func (d BundleDiff) String() string {
+	var b strings.Builder
+	for _, a := range d.Added {
+		fmt.Fprintf(&b, "added: %v\n", a)
+	}
+	for _, a := range d.Removed {
+		fmt.Fprintf(&b, "removed: %v\n", a)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "changed: %s (SHA-256 %s -> %s): %s\n", c.New.Subject, c.Old.SHA256, c.New.SHA256, strings.Join(c.Fields, ", "))
+	}
+	return b.String()
+}
+
+// DiffBundles compares two PEM bundles of trust anchors, such as two
+// versions of the ca-certificates bundle of a base image, and reports the
+// anchors that were added, removed or changed.
+//
+// As in CertPool.AppendCertsFromPEM, "CERTIFICATE" and "TRUSTED CERTIFICATE"
+// blocks are read, and other blocks, and certificates that fail to parse,
+// are ignored. A certificate in both bundles is unchanged unless its trust
+// attributes differ. A certificate only in new that has the same subject and
+// public key as one only in old, as checked by SPKISubjectSHA256, replaces
+// it and is reported as changed. The order of the certificates in the bundles
+// doesn't matter.
+func DiffBundles(old, new []byte) BundleDiff {
+	oldAnchors, newAnchors := parseBundle(old), parseBundle(new)
+
+	var d BundleDiff
+	// Certificates present in both bundles.
+	byFingerprint := make(map[string][]BundleAnchor)
+	for _, a := range oldAnchors {
+		byFingerprint[a.SHA256] = append(byFingerprint[a.SHA256], a)
+	}
+	var added []BundleAnchor
+	for _, a := range newAnchors {
+		olds := byFingerprint[a.SHA256]
+		if len(olds) == 0 {
+			added = append(added, a)
+			continue
+		}
+		byFingerprint[a.SHA256] = olds[1:]
+		if !bytes.Equal(olds[0].rawTrust, a.rawTrust) {
+			d.Changed = append(d.Changed, BundleChange{olds[0], a, []string{"Trust"}})
+		}
+	}
+
+	// Replaced certificates, matched by subject and public key. The
+	// certificates left in byFingerprint are only in old.
+	byIdentity := make(map[[sha256.Size]byte][]BundleAnchor)
+	for _, a := range oldAnchors {
+		olds := byFingerprint[a.SHA256]
+		if len(olds) == 0 {
+			continue
+		}
+		byFingerprint[a.SHA256] = olds[1:]
+		id := SPKISubjectSHA256(a.Certificate)
+		byIdentity[id] = append(byIdentity[id], a)
+	}
+	for _, a := range added {
+		id := SPKISubjectSHA256(a.Certificate)
+		olds := byIdentity[id]
+		if len(olds) == 0 {
+			d.Added = append(d.Added, a)
+			continue
+		}
+		byIdentity[id] = olds[1:]
+		d.Changed = append(d.Changed, BundleChange{olds[0], a, anchorChanges(olds[0], a)})
+	}
+	for _, olds := range byIdentity {
+		d.Removed = append(d.Removed, olds...)
+	}
+
+	sortBundleAnchors(d.Added)
+	sortBundleAnchors(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return bundleAnchorLess(d.Changed[i].New, d.Changed[j].New)
+	})
+	return d
+}
+
+// parseBundle returns the certificates of the PEM bundle data.
+func parseBundle(data []byte) []BundleAnchor {
+	var anchors []BundleAnchor
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if len(block.Headers) != 0 {
+			continue
+		}
+		var a BundleAnchor
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			a.Certificate, err = ParseCertificate(block.Bytes)
+		case "TRUSTED CERTIFICATE":
+			a.Certificate, a.Trust, err = ParseTrustedCertificate(block.Bytes)
+			if err == nil {
+				a.rawTrust = block.Bytes[len(a.Certificate.Raw):]
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		h := sha256.Sum256(a.Certificate.Raw)
+		a.Subject = a.Certificate.Subject.String()
+		a.SHA256 = hex.EncodeToString(h[:])
+		anchors = append(anchors, a)
+	}
+	return anchors
+}
+
+// anchorChanges returns the BundleChange.Fields for the replacement of old
+// by new.
+func anchorChanges(old, new BundleAnchor) []string {
+	o, n := old.Certificate, new.Certificate
+	var fields []string
+	if !o.NotBefore.Equal(n.NotBefore) {
+		fields = append(fields, "NotBefore")
+	}
+	if !o.NotAfter.Equal(n.NotAfter) {
+		fields = append(fields, "NotAfter")
+	}
+	if o.SerialNumber.Cmp(n.SerialNumber) != 0 {
+		fields = append(fields, "SerialNumber")
+	}
+	if o.SignatureAlgorithm != n.SignatureAlgorithm {
+		fields = append(fields, "SignatureAlgorithm")
+	}
+	if !equalExtensions(o, n) {
+		fields = append(fields, "Extensions")
+	}
+	if len(fields) == 0 {
+		fields = append(fields, "Encoding")
+	}
+	if !bytes.Equal(old.rawTrust, new.rawTrust) {
+		fields = append(fields, "Trust")
+	}
+	return fields
+}
+
+func equalExtensions(a, b *Certificate) bool {
+	if len(a.Extensions) != len(b.Extensions) {
+		return false
+	}
+	for i := range a.Extensions {
+		ea, eb := a.Extensions[i], b.Extensions[i]
+		if !ea.Id.Equal(eb.Id) || ea.Critical != eb.Critical || !bytes.Equal(ea.Value, eb.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func bundleAnchorLess(a, b BundleAnchor) bool {
+	if a.Subject != b.Subject {
+		return a.Subject < b.Subject
+	}
+	return a.SHA256 < b.SHA256
+}
+
+func sortBundleAnchors(anchors []BundleAnchor) {
+	sort.Slice(anchors, func(i, j int) bool { return bundleAnchorLess(anchors[i], anchors[j]) })
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffBundles(t *testing.T) {
+	var certs []*Certificate
+	var keys []interface{}
+	for _, name := range []string{"Kept Root", "Renewed Root", "Removed Root", "Trusted Root", "Added Root"} {
+		cert, key, err := generateCert(name, true, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs, keys = append(certs, cert), append(keys, key)
+	}
+	kept, renewed, removed, trusted, added := certs[0], certs[1], certs[2], certs[3], certs[4]
+
+	template := TemplateFromCertificate(renewed)
+	template.SerialNumber = big.NewInt(2)
+	template.NotAfter = renewed.NotAfter.Add(365 * 24 * time.Hour)
+	der, err := CreateCertificate(rand.Reader, template, template, renewed.PublicKey, keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewal, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := func(blocks ...interface{}) []byte {
+		var buf bytes.Buffer
+		for _, b := range blocks {
+			switch b := b.(type) {
+			case *Certificate:
+				pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: b.Raw})
+			case []byte:
+				buf.Write(b)
+			}
+		}
+		return buf.Bytes()
+	}
+	old := bundle(kept, renewed, removed, trusted, []byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"))
+	new := bundle(added, trustedCertificateBlock(trusted, ExtKeyUsageServerAuth), renewal, kept)
+
+	d := DiffBundles(old, new)
+	if len(d.Added) != 1 || d.Added[0].Certificate.Subject.CommonName != "Added Root" ||
+		d.Added[0].Subject != "CN=Added Root" || len(d.Added[0].SHA256) != 64 {
+		t.Errorf("Added = %v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Certificate.Subject.CommonName != "Removed Root" {
+		t.Errorf("Removed = %v", d.Removed)
+	}
+	if len(d.Changed) != 2 {
+		t.Fatalf("Changed = %v", d.Changed)
+	}
+	if c := d.Changed[0]; c.New.Certificate.Subject.CommonName != "Renewed Root" ||
+		strings.Join(c.Fields, ",") != "NotAfter,SerialNumber" {
+		t.Errorf("renewal: got %+v", c)
+	}
+	if c := d.Changed[1]; c.New.Certificate.Subject.CommonName != "Trusted Root" ||
+		strings.Join(c.Fields, ",") != "Trust" || c.Old.Trust != nil || c.New.Trust == nil {
+		t.Errorf("trust change: got %+v", c)
+	}
+	if d.Empty() {
+		t.Error("Empty reported no changes")
+	}
+	if s := d.String(); strings.Count(s, "\n") != 4 || !strings.Contains(s, "removed: CN=Removed Root (SHA-256 ") {
+		t.Errorf("String() = %q", s)
+	}
+
+	// The order of the certificates doesn't matter.
+	if d := DiffBundles(old, bundle(trusted, removed, renewed, kept)); !d.Empty() {
+		t.Errorf("reordered bundle: got %v", d)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// A CanonicalDeviation is a place where the encoding of a certificate
+// differs from its canonical DER encoding, as reported by
+// Certificate.Canonical.
+type CanonicalDeviation struct {
+	// Field is the field of the certificate holding the deviation, such as
+	// "subject", "validity" or "extension 2.5.29.17".
+	Field string
+	// Problem describes the deviation.
+	Problem string
+}
+
+func (d CanonicalDeviation) String() string {
+	return d.Field + ": " + d.Problem
+}
+
+// Canonical returns the canonical DER re-encoding of c, along with every
+// deviation of c.Raw from it. Certificates that differ only in encoding, for
+// example as logged by different Certificate Transparency logs or
+// re-encoded by different tools, have the same canonical encoding, which can
+// serve as a deduplication key.
+//
+// Canonicalization sorts the elements of SET OF values, including the
+// attributes of multi-valued relative distinguished names, by their
+// encoding; re-encodes times as UTCTime with seconds, in UTC, through 2049
+// and as GeneralizedTime afterwards, as required by RFC 5280; encodes TRUE
+// as 0xff; removes an explicit version 1 and explicit critical flags set to
+// FALSE; and removes trailing zero bits from the key usage extension. The
+// values of the other extensions are canonicalized as generic DER.
+//
+// The signature of c is kept as is, so it doesn't verify over the canonical
+// encoding if any deviation was found. The result is not meant to be used as
+// a certificate.
+func (c *Certificate) Canonical() ([]byte, []CanonicalDeviation, error) {
+	cz := &canonicalizer{}
+	input := cryptobyte.String(c.Raw)
+	var cert, tbs cryptobyte.String
+	if !input.ReadASN1(&cert, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!cert.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, nil, errors.New("x509: malformed certificate")
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			cz.tbsCertificate(b, tbs)
+		})
+		for _, field := range []string{"signatureAlgorithm", "signatureValue"} {
+			var element cryptobyte.String
+			if !cert.ReadAnyASN1Element(&element, new(cryptobyte_asn1.Tag)) {
+				b.SetError(errors.New("x509: malformed certificate"))
+				return
+			}
+			b.AddBytes(cz.element(element, field))
+		}
+		if !cert.Empty() {
+			b.SetError(errors.New("x509: trailing data after certificate"))
+		}
+	})
+	if cz.err != nil {
+		return nil, nil, cz.err
+	}
+	der, err := b.Bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return der, cz.deviations, nil
+}
+
+var (
+	versionTag    = cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()
+	extensionsTag = cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+)
+
+type canonicalizer struct {
+	deviations []CanonicalDeviation
+	err        error
+}
+
+func (cz *canonicalizer) deviate(field, format string, args ...interface{}) {
+	cz.deviations = append(cz.deviations, CanonicalDeviation{field, fmt.Sprintf(format, args...)})
+}
+
+func (cz *canonicalizer) fail() {
+	if cz.err == nil {
+		cz.err = errors.New("x509: malformed certificate")
+	}
+}
+
+// tbsCertificate adds the canonical encoding of the contents of the
+// TBSCertificate tbs to b.
+func (cz *canonicalizer) tbsCertificate(b *cryptobyte.Builder, tbs cryptobyte.String) {
+	fields := []string{"serialNumber", "signature", "issuer", "validity", "subject", "subjectPublicKeyInfo"}
+	if tbs.PeekASN1Tag(versionTag) {
+		var element, version cryptobyte.String
+		var v int
+		if !tbs.ReadASN1Element(&element, versionTag) {
+			cz.fail()
+			return
+		}
+		if inner := element; !inner.ReadASN1(&version, versionTag) || !version.ReadASN1Integer(&v) {
+			cz.fail()
+			return
+		}
+		if v == 0 {
+			// Version ::= INTEGER { v1(0), v2(1), v3(2) } DEFAULT v1
+			cz.deviate("version", "explicit default version v1")
+		} else {
+			b.AddBytes(cz.element(element, "version"))
+		}
+	}
+	for !tbs.Empty() {
+		var element cryptobyte.String
+		var tag cryptobyte_asn1.Tag
+		if !tbs.ReadAnyASN1Element(&element, &tag) {
+			cz.fail()
+			return
+		}
+		field := "uniqueIdentifier"
+		if len(fields) > 0 {
+			field, fields = fields[0], fields[1:]
+		}
+		if tag == extensionsTag {
+			cz.extensions(b, element)
+			continue
+		}
+		b.AddBytes(cz.element(element, field))
+	}
+}
+
+// extensions adds the canonical encoding of the [3] Extensions element to b.
+func (cz *canonicalizer) extensions(b *cryptobyte.Builder, element cryptobyte.String) {
+	var outer, exts cryptobyte.String
+	if !element.ReadASN1(&outer, extensionsTag) ||
+		!outer.ReadASN1(&exts, cryptobyte_asn1.SEQUENCE) || !outer.Empty() {
+		cz.fail()
+		return
+	}
+	b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for !exts.Empty() {
+				// Extension ::= SEQUENCE {
+				//     extnID      OBJECT IDENTIFIER,
+				//     critical    BOOLEAN DEFAULT FALSE,
+				//     extnValue   OCTET STRING }
+				var ext, value cryptobyte.String
+				var id asn1.ObjectIdentifier
+				var critical bool
+				if !exts.ReadASN1(&ext, cryptobyte_asn1.SEQUENCE) ||
+					!ext.ReadASN1ObjectIdentifier(&id) {
+					cz.fail()
+					return
+				}
+				field := "extension " + id.String()
+				if ext.PeekASN1Tag(cryptobyte_asn1.BOOLEAN) {
+					var raw cryptobyte.String
+					if !ext.ReadASN1(&raw, cryptobyte_asn1.BOOLEAN) || len(raw) != 1 {
+						cz.fail()
+						return
+					}
+					critical = raw[0] != 0
+					switch {
+					case !critical:
+						cz.deviate(field, "explicit default critical flag FALSE")
+					case raw[0] != 0xff:
+						cz.deviate(field, "BOOLEAN TRUE encoded as %#x", raw[0])
+					}
+				}
+				if !ext.ReadASN1(&value, cryptobyte_asn1.OCTET_STRING) || !ext.Empty() {
+					cz.fail()
+					return
+				}
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(id)
+					if critical {
+						b.AddASN1Boolean(true)
+					}
+					b.AddASN1OctetString(cz.extensionValue(id, value, field))
+				})
+			}
+		})
+	})
+}
+
+// extensionValue returns the canonical encoding of the value of the
+// extension id.
+func (cz *canonicalizer) extensionValue(id asn1.ObjectIdentifier, value []byte, field string) []byte {
+	if id.Equal(oidExtensionKeyUsage) {
+		var usageBits asn1.BitString
+		if rest, err := asn1.Unmarshal(value, &usageBits); err == nil && len(rest) == 0 &&
+			checkKeyUsageEncoding(value) != nil && usageBits.BitLength > 0 {
+			bitLength := asn1BitLength(usageBits.Bytes)
+			canonical, err := asn1.Marshal(asn1.BitString{Bytes: usageBits.Bytes[:(bitLength+7)/8], BitLength: bitLength})
+			if err == nil {
+				cz.deviate(field, "%d bits, with trailing zero bits", usageBits.BitLength)
+				return canonical
+			}
+		}
+	}
+
+	var out []byte
+	input := cryptobyte.String(value)
+	for !input.Empty() {
+		var element cryptobyte.String
+		if !input.ReadAnyASN1Element(&element, new(cryptobyte_asn1.Tag)) {
+			// Not DER, so there is no canonical form to compare to.
+			return value
+		}
+		out = append(out, cz.element(element, field)...)
+	}
+	return out
+}
+
+// element returns the canonical encoding of the DER element der.
+func (cz *canonicalizer) element(der cryptobyte.String, field string) []byte {
+	raw := []byte(der)
+	var contents cryptobyte.String
+	var tag cryptobyte_asn1.Tag
+	if !der.ReadAnyASN1(&contents, &tag) {
+		cz.fail()
+		return raw
+	}
+
+	var b cryptobyte.Builder
+	switch {
+	case tag == cryptobyte_asn1.UTCTime || tag == cryptobyte_asn1.GeneralizedTime:
+		var t time.Time
+		if _, err := asn1.Unmarshal(raw, &t); err != nil {
+			cz.fail()
+			return raw
+		}
+		canonical, err := asn1.Marshal(t.UTC())
+		if err != nil {
+			cz.fail()
+			return raw
+		}
+		if !bytes.Equal(canonical, raw) {
+			cz.deviate(field, "time %q is not canonical", string(contents))
+		}
+		return canonical
+	case tag == cryptobyte_asn1.BOOLEAN:
+		if len(contents) == 1 && contents[0] != 0 && contents[0] != 0xff {
+			cz.deviate(field, "BOOLEAN TRUE encoded as %#x", contents[0])
+			b.AddASN1Boolean(true)
+			return b.BytesOrPanic()
+		}
+		return raw
+	case tag&0x20 == 0: // primitive
+		return raw
+	}
+
+	var children [][]byte
+	for !contents.Empty() {
+		var child cryptobyte.String
+		if !contents.ReadAnyASN1Element(&child, new(cryptobyte_asn1.Tag)) {
+			cz.fail()
+			return raw
+		}
+		children = append(children, cz.element(child, field))
+	}
+	if tag == cryptobyte_asn1.SET && !sort.SliceIsSorted(children, func(i, j int) bool {
+		return bytes.Compare(children[i], children[j]) < 0
+	}) {
+		cz.deviate(field, "SET OF elements are not sorted")
+		sort.Slice(children, func(i, j int) bool {
+			return bytes.Compare(children[i], children[j]) < 0
+		})
+	}
+	b.AddASN1(tag, func(b *cryptobyte.Builder) {
+		for _, child := range children {
+			b.AddBytes(child)
+		}
+	})
+	return b.BytesOrPanic()
+}
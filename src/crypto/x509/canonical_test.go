@@ -0,0 +1,152 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+func TestCanonical(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	san, err := marshalSANs([]string{"example.com"}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyUsage, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x80, 0}, BitLength: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A certificate with an explicit version 1, an unsorted multi-valued
+	// RDN, non-canonical times, an explicit non-critical flag and a key
+	// usage with trailing zero bits.
+	build := func(version int64, criticalFalse bool) []byte {
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+					b.AddASN1Int64(version)
+				})
+				b.AddASN1Int64(1)
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(oidSignatureECDSAWithSHA256)
+				})
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {
+						for _, atv := range []struct {
+							oid   asn1.ObjectIdentifier
+							value string
+						}{{asn1.ObjectIdentifier{2, 5, 4, 10}, "a"}, {asn1.ObjectIdentifier{2, 5, 4, 3}, "b"}} {
+							b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+								b.AddASN1ObjectIdentifier(atv.oid)
+								b.AddASN1(cryptobyte_asn1.UTF8String, func(b *cryptobyte.Builder) {
+									b.AddBytes([]byte(atv.value))
+								})
+							})
+						}
+					})
+				})
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.UTCTime, func(b *cryptobyte.Builder) {
+						b.AddBytes([]byte("2001010000Z"))
+					})
+					b.AddASN1(cryptobyte_asn1.GeneralizedTime, func(b *cryptobyte.Builder) {
+						b.AddBytes([]byte("20300101000000Z"))
+					})
+				})
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {})
+				b.AddBytes(spki)
+				b.AddASN1(cryptobyte_asn1.Tag(3).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidExtensionSubjectAltName)
+							if criticalFalse {
+								b.AddASN1Boolean(false)
+							}
+							b.AddASN1OctetString(san)
+						})
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidExtensionKeyUsage)
+							b.AddASN1Boolean(true)
+							b.AddASN1OctetString(keyUsage)
+						})
+					})
+				})
+			})
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidSignatureECDSAWithSHA256)
+			})
+			b.AddASN1BitString([]byte{1, 2, 3})
+		})
+		return b.BytesOrPanic()
+	}
+
+	cert, err := ParseCertificate(build(0, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, deviations, err := cert.Canonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, d := range deviations {
+		fields = append(fields, d.Field)
+	}
+	want := []string{"version", "issuer", "validity", "validity", "extension 2.5.29.17", "extension 2.5.29.15"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got deviations %v, want deviations in %q", deviations, want)
+	}
+
+	parsed, err := ParseCertificate(canonical)
+	if err != nil {
+		t.Fatalf("canonical encoding doesn't parse: %v", err)
+	}
+	if !parsed.NotBefore.Equal(cert.NotBefore) || !parsed.NotAfter.Equal(cert.NotAfter) ||
+		parsed.Issuer.CommonName != cert.Issuer.CommonName || !reflect.DeepEqual(parsed.Issuer.Organization, cert.Issuer.Organization) ||
+		parsed.KeyUsage != cert.KeyUsage ||
+		!reflect.DeepEqual(parsed.DNSNames, cert.DNSNames) {
+		t.Errorf("canonical encoding changed the contents of the certificate")
+	}
+	again, deviations, err := parsed.Canonical()
+	if err != nil || len(deviations) != 0 || !bytes.Equal(again, canonical) {
+		t.Errorf("canonical encoding is not canonical: %v, %v", deviations, err)
+	}
+
+	// A certificate that differs only in encoding has the same canonical
+	// encoding.
+	other, err := ParseCertificate(build(0, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherCanonical, _, err := other.Canonical(); err != nil || !bytes.Equal(otherCanonical, canonical) {
+		t.Errorf("equivalent certificates have different canonical encodings")
+	}
+
+	// Certificates created by this package are canonical.
+	created, _, err := generateCert("Leaf", false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if der, deviations, err := created.Canonical(); err != nil || len(deviations) != 0 || !bytes.Equal(der, created.Raw) {
+		t.Errorf("created certificate is not canonical: %v, %v", deviations, err)
+	}
+}
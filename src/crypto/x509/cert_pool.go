@@ -5,16 +5,95 @@
 package x509
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// debugPoolCallers enables recording the call site of AddCert and
+// AppendCertsFromPEM for each certificate, as reported by CertPool.Audit.
+var debugPoolCallers = strings.Contains(os.Getenv("GODEBUG"), "x509poolcallers=1")
+
 // CertPool is a set of certificates.
+//
+// A CertPool may be read by multiple goroutines simultaneously, for example
+// by concurrent calls to Verify, but adding certificates to it must not
+// happen concurrently with any other use of it.
+// Pools created by NewConcurrentCertPool don't have this restriction.
 type CertPool struct {
 	bySubjectKeyId map[string][]int
 	byName         map[string][]int
 	certs          []*Certificate
+
+	// origins[i] records where certs[i] came from.
+	origins []certOrigin
+
+	// hasTrust reports whether any origin has trust attributes.
+	hasTrust bool
+
+	// shared is set for pools returned by NewConcurrentCertPool, whose
+	// other fields are unused.
+	shared *sharedCertPool
+
+	// index is set for pools returned by NewCertPoolFromIndex, whose other
+	// fields are unused.
+	index *certIndex
+}
+
+// sharedCertPool is the copy-on-write state of a concurrent CertPool.
+type sharedCertPool struct {
+	mu      sync.Mutex   // serializes additions
+	current atomic.Value // *CertPool, never modified once stored
+}
+
+// certOrigin is the provenance of a certificate in a CertPool.
+type certOrigin struct {
+	system   bool
+	path     string
+	store    string
+	trust    *TrustAttributes
+	callSite string
+}
+
+// AnchorInfo describes a certificate in a CertPool and where it came from,
+// so that security reviews can tell why a process trusts a given CA.
+type AnchorInfo struct {
+	Certificate *Certificate
+
+	// System reports whether the certificate was loaded from the system
+	// root store, by SystemCertPool or because VerifyOptions.Roots was nil.
+	System bool
+
+	// Path is the file the certificate was read from, if it was loaded from
+	// a file or directory of the system root store. It is empty on
+	// platforms that do not keep roots in files.
+	Path string
+
+	// Store is the platform store the certificate was loaded from, if it is
+	// a system root on a platform with several root stores. On macOS, it is
+	// the trust settings domain, "user", "admin" or "system". It is
+	// "embedded" for the roots compiled in with the x509embedroots build
+	// tag, as reported by EmbeddedRootsVersion.
+	Store string
+
+	// Trust is the trust attributes of the certificate, if it was added by
+	// AddCertWithTrust or from a "TRUSTED CERTIFICATE" PEM block.
+	Trust *TrustAttributes
+
+	// CallSite is the function, file and line of the AddCert or
+	// AppendCertsFromPEM call that added the certificate. It is recorded
+	// only if the GODEBUG environment variable contains x509poolcallers=1,
+	// since looking up the caller slows down adding certificates.
+	CallSite string
 }
 
 // NewCertPool returns a new, empty CertPool.
@@ -25,11 +104,53 @@ func NewCertPool() *CertPool {
 	}
 }
 
+// NewConcurrentCertPool returns a new, empty CertPool that is safe for
+// concurrent use, including adding certificates while other goroutines
+// verify chains with it, as when a service adds the intermediates it learns
+// from handshakes to a shared pool. Verify and the other operations of the
+// package use a snapshot of the pool taken when they start. Each addition
+// copies the pool, so it is meant for pools that are read much more often
+// than written to.
+func NewConcurrentCertPool() *CertPool {
+	shared := new(sharedCertPool)
+	shared.current.Store(NewCertPool())
+	return &CertPool{shared: shared}
+}
+
+// view returns s, or the current snapshot of s if it is a concurrent pool.
+// Operations that look up certificates by index must use a single view.
+func (s *CertPool) view() *CertPool {
+	if s == nil || s.shared == nil {
+		return s
+	}
+	return s.shared.current.Load().(*CertPool)
+}
+
+// update applies f to a copy of the current snapshot of the concurrent pool
+// s, and makes the result the current snapshot.
+func (s *CertPool) update(f func(*CertPool)) {
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
+	next := s.view().copy()
+	f(next)
+	s.shared.current.Store(next)
+}
+
 func (s *CertPool) copy() *CertPool {
+	s = s.view()
+	if s.index != nil {
+		p := NewCertPool()
+		for _, c := range s.certificates() {
+			p.addCert(c, certOrigin{})
+		}
+		return p
+	}
 	p := &CertPool{
 		bySubjectKeyId: make(map[string][]int, len(s.bySubjectKeyId)),
 		byName:         make(map[string][]int, len(s.byName)),
 		certs:          make([]*Certificate, len(s.certs)),
+		origins:        make([]certOrigin, len(s.origins)),
+		hasTrust:       s.hasTrust,
 	}
 	for k, v := range s.bySubjectKeyId {
 		indexes := make([]int, len(v))
@@ -42,6 +163,7 @@ func (s *CertPool) copy() *CertPool {
 		p.byName[k] = indexes
 	}
 	copy(p.certs, s.certs)
+	copy(p.origins, s.origins)
 	return p
 }
 
@@ -62,15 +184,23 @@ func SystemCertPool() (*CertPool, error) {
 		return sysRoots.copy(), nil
 	}
 
-	return loadSystemRoots()
+	roots, err := loadSystemRoots()
+	if roots != nil {
+		roots.markSystem()
+	}
+	return roots, err
 }
 
 // findPotentialParents returns the indexes of certificates in s which might
-// have signed cert. The caller must not modify the returned slice.
+// have signed cert. The caller must not modify the returned slice. s must not
+// be a concurrent pool, since the indexes would refer to a snapshot.
 func (s *CertPool) findPotentialParents(cert *Certificate) []int {
 	if s == nil {
 		return nil
 	}
+	if s.index != nil {
+		return s.index.findPotentialParents(cert)
+	}
 
 	var candidates []int
 	if len(cert.AuthorityKeyId) > 0 {
@@ -82,10 +212,59 @@ func (s *CertPool) findPotentialParents(cert *Certificate) []int {
 	return candidates
 }
 
+// findPotentialParentsConstantTime is like findPotentialParents, but compares
+// the issuer of cert with every certificate in s in constant time, instead of
+// looking it up in a map, so that the time taken depends only on the size of
+// s, the lengths of the compared values and the number of matches.
+func (s *CertPool) findPotentialParentsConstantTime(cert *Certificate) []int {
+	if s == nil {
+		return nil
+	}
+	if s.index != nil {
+		return s.index.findPotentialParentsConstantTime(cert)
+	}
+
+	var byKeyId, byName []int
+	for i, c := range s.certs {
+		if len(cert.AuthorityKeyId) > 0 && subtle.ConstantTimeCompare(c.SubjectKeyId, cert.AuthorityKeyId) == 1 {
+			byKeyId = append(byKeyId, i)
+		}
+		if subtle.ConstantTimeCompare(c.RawSubject, cert.RawIssuer) == 1 {
+			byName = append(byName, i)
+		}
+	}
+	if len(byKeyId) > 0 {
+		return byKeyId
+	}
+	return byName
+}
+
+// containsConstantTime is like contains, but compares cert with every
+// certificate in s in constant time.
+func (s *CertPool) containsConstantTime(cert *Certificate) bool {
+	s = s.view()
+	if s == nil {
+		return false
+	}
+	if s.index != nil {
+		return s.index.containsConstantTime(cert)
+	}
+
+	found := 0
+	for _, c := range s.certs {
+		found |= subtle.ConstantTimeCompare(c.Raw, cert.Raw)
+	}
+	return found == 1
+}
+
 func (s *CertPool) contains(cert *Certificate) bool {
+	s = s.view()
 	if s == nil {
 		return false
 	}
+	if s.index != nil {
+		return s.index.contains(cert)
+	}
 
 	candidates := s.byName[string(cert.RawSubject)]
 	for _, c := range candidates {
@@ -97,12 +276,97 @@ func (s *CertPool) contains(cert *Certificate) bool {
 	return false
 }
 
+// len returns the number of certificates in s, which must not be a
+// concurrent pool.
+func (s *CertPool) len() int {
+	if s.index != nil {
+		return s.index.n
+	}
+	return len(s.certs)
+}
+
+// cert returns the i-th certificate of s, which must not be a concurrent
+// pool. It only fails for pools backed by an index that was modified.
+func (s *CertPool) cert(i int) (*Certificate, error) {
+	if s.index != nil {
+		return s.index.cert(i)
+	}
+	return s.certs[i], nil
+}
+
+// certificates returns the certificates in s, in the order they were added.
+// The caller must not modify the returned slice.
+func (s *CertPool) certificates() []*Certificate {
+	s = s.view()
+	if s == nil {
+		return nil
+	}
+	if s.index == nil {
+		return s.certs
+	}
+	certs := make([]*Certificate, 0, s.index.n)
+	for i := 0; i < s.index.n; i++ {
+		// Certificates of a modified index are left out, and reported by
+		// Verify if it needs them.
+		if c, err := s.index.cert(i); err == nil {
+			certs = append(certs, c)
+		}
+	}
+	return certs
+}
+
+// origin returns the provenance of the certificate in s equal to cert, and
+// whether there is one.
+func (s *CertPool) origin(cert *Certificate) (certOrigin, bool) {
+	s = s.view()
+	if s == nil {
+		return certOrigin{}, false
+	}
+	if s.index != nil {
+		return certOrigin{}, s.index.contains(cert)
+	}
+
+	for _, c := range s.byName[string(cert.RawSubject)] {
+		if s.certs[c].Equal(cert) {
+			return s.origins[c], true
+		}
+	}
+
+	return certOrigin{}, false
+}
+
 // AddCert adds a certificate to a pool.
 func (s *CertPool) AddCert(cert *Certificate) {
 	if cert == nil {
 		panic("adding nil Certificate to CertPool")
 	}
+	s.addCert(cert, certOrigin{callSite: callSite()})
+}
 
+// callSite returns the caller of the exported CertPool method that called
+// it, if debugPoolCallers is set.
+func callSite() string {
+	if !debugPoolCallers {
+		return ""
+	}
+	pc, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return ""
+	}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		return fmt.Sprintf("%s %s:%d", fn.Name(), file, line)
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func (s *CertPool) addCert(cert *Certificate, origin certOrigin) {
+	if s.index != nil {
+		panic("x509: adding a certificate to a CertPool backed by an index")
+	}
+	if s.shared != nil {
+		s.update(func(p *CertPool) { p.addCert(cert, origin) })
+		return
+	}
 	// Check that the certificate isn't being added twice.
 	if s.contains(cert) {
 		return
@@ -110,6 +374,10 @@ func (s *CertPool) AddCert(cert *Certificate) {
 
 	n := len(s.certs)
 	s.certs = append(s.certs, cert)
+	s.origins = append(s.origins, origin)
+	if origin.trust != nil {
+		s.hasTrust = true
+	}
 
 	if len(cert.SubjectKeyId) > 0 {
 		keyId := string(cert.SubjectKeyId)
@@ -123,25 +391,46 @@ func (s *CertPool) AddCert(cert *Certificate) {
 // It appends any certificates found to s and reports whether any certificates
 // were successfully parsed.
 //
+// OpenSSL "TRUSTED CERTIFICATE" blocks are also accepted, and their trust
+// attributes are honored as by AddCertWithTrust.
+//
 // On many Linux systems, /etc/ssl/cert.pem will contain the system wide set
 // of root CAs in a format suitable for this function.
 func (s *CertPool) AppendCertsFromPEM(pemCerts []byte) (ok bool) {
+	return s.appendCertsFromPEM(pemCerts, certOrigin{callSite: callSite()})
+}
+
+func (s *CertPool) appendCertsFromPEM(pemCerts []byte, origin certOrigin) (ok bool) {
+	if s.shared != nil {
+		s.update(func(p *CertPool) { ok = p.appendCertsFromPEM(pemCerts, origin) })
+		return ok
+	}
 	for len(pemCerts) > 0 {
 		var block *pem.Block
 		block, pemCerts = pem.Decode(pemCerts)
 		if block == nil {
 			break
 		}
-		if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+		if len(block.Headers) != 0 {
 			continue
 		}
 
-		cert, err := ParseCertificate(block.Bytes)
+		var cert *Certificate
+		var err error
+		blockOrigin := origin
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err = ParseCertificate(block.Bytes)
+		case "TRUSTED CERTIFICATE":
+			cert, blockOrigin.trust, err = ParseTrustedCertificate(block.Bytes)
+		default:
+			continue
+		}
 		if err != nil {
 			continue
 		}
 
-		s.AddCert(cert)
+		s.addCert(cert, blockOrigin)
 		ok = true
 	}
 
@@ -151,9 +440,80 @@ func (s *CertPool) AppendCertsFromPEM(pemCerts []byte) (ok bool) {
 // Subjects returns a list of the DER-encoded subjects of
 // all of the certificates in the pool.
 func (s *CertPool) Subjects() [][]byte {
-	res := make([][]byte, len(s.certs))
-	for i, c := range s.certs {
-		res[i] = c.RawSubject
+	s = s.view()
+	res := make([][]byte, s.len())
+	for i := range res {
+		if s.index != nil {
+			res[i] = s.index.subject(i)
+		} else {
+			res[i] = s.certs[i].RawSubject
+		}
 	}
 	return res
 }
+
+// WritePEM writes the certificates in s to w as a sequence of PEM
+// "CERTIFICATE" blocks, as accepted by AppendCertsFromPEM. Certificates with
+// trust attributes, added with AddCertWithTrust or from a "TRUSTED
+// CERTIFICATE" block, are written as "TRUSTED CERTIFICATE" blocks with their
+// attributes, so that reading them back doesn't widen their trust.
+//
+// The certificates are sorted by their DER-encoded subject, then by serial
+// number, rather than by the order they were added, so that the same set of
+// certificates always produces the same output.
+func (s *CertPool) WritePEM(w io.Writer) error {
+	s = s.view()
+	if s == nil {
+		return nil
+	}
+	info := s.Audit()
+	sort.Slice(info, func(i, j int) bool {
+		a, b := info[i].Certificate, info[j].Certificate
+		if c := bytes.Compare(a.RawSubject, b.RawSubject); c != 0 {
+			return c < 0
+		}
+		if c := a.SerialNumber.Cmp(b.SerialNumber); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(a.Raw, b.Raw) < 0
+	})
+	for _, anchor := range info {
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: anchor.Certificate.Raw}
+		if anchor.Trust != nil {
+			der, err := marshalTrustedCertificate(anchor.Certificate, anchor.Trust)
+			if err != nil {
+				return err
+			}
+			block = &pem.Block{Type: "TRUSTED CERTIFICATE", Bytes: der}
+		}
+		if err := pem.Encode(w, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markSystem records that all certificates in s come from the system root
+// store.
+func (s *CertPool) markSystem() {
+	for i := range s.origins {
+		s.origins[i].system = true
+		s.origins[i].callSite = ""
+	}
+}
+
+// Audit returns the certificates in s, in the order they were added, along
+// with where each of them came from.
+func (s *CertPool) Audit() []AnchorInfo {
+	s = s.view()
+	certs := s.certificates()
+	info := make([]AnchorInfo, len(certs))
+	for i, c := range certs {
+		var o certOrigin
+		if s.index == nil {
+			o = s.origins[i]
+		}
+		info[i] = AnchorInfo{Certificate: c, System: o.system, Path: o.path, Store: o.store, Trust: o.trust, CallSite: o.callSite}
+	}
+	return info
+}
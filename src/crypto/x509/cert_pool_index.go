@@ -0,0 +1,302 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// The format of a CertPool index, with all integers big-endian uint32:
+//
+//	magic   [8]byte         "x509idx\x01"
+//	n       uint32          number of certificates
+//	records [n][6]uint32    offset and length of the DER certificate, of its
+//	                        subject and of its subject key identifier, from
+//	                        the start of the index
+//	subject [n]uint32       record numbers sorted by subject
+//	m       uint32          number of certificates with a subject key id
+//	keyId   [m]uint32       record numbers sorted by subject key id
+//	data                    the DER certificates
+//
+// The subjects and key identifiers point inside the certificates, so that
+// lookups don't need to parse them.
+const certIndexMagic = "x509idx\x01"
+
+const certIndexRecordLen = 6 * 4
+
+var errBadCertIndex = errors.New("x509: malformed CertPool index")
+
+// certIndex is a CertPool index, as written by CertPool.WriteIndex.
+type certIndex struct {
+	data      []byte
+	n         int
+	records   []byte
+	bySubject []byte
+	byKeyId   []byte
+
+	// parsed holds each certificate once it is first used, so that Verify
+	// sees the same *Certificate every time.
+	parsed []indexedCert
+}
+
+type indexedCert struct {
+	once sync.Once
+	cert *Certificate
+	err  error
+}
+
+func (x *certIndex) field(i, f int) []byte {
+	r := x.records[i*certIndexRecordLen+f*8:]
+	off, n := binary.BigEndian.Uint32(r), binary.BigEndian.Uint32(r[4:])
+	return x.data[off : off+n : off+n]
+}
+
+func (x *certIndex) der(i int) []byte     { return x.field(i, 0) }
+func (x *certIndex) subject(i int) []byte { return x.field(i, 1) }
+func (x *certIndex) keyId(i int) []byte   { return x.field(i, 2) }
+
+// cert returns the i-th certificate, parsing it on first use. Its Raw fields
+// alias x.data.
+func (x *certIndex) cert(i int) (*Certificate, error) {
+	p := &x.parsed[i]
+	p.once.Do(func() {
+		p.cert, p.err = ParseCertificate(x.der(i))
+		if p.err != nil {
+			// NewCertPoolFromIndex parsed every certificate already.
+			p.err = errors.New("x509: CertPool index was modified: " + p.err.Error())
+		}
+	})
+	return p.cert, p.err
+}
+
+// lookup returns the record numbers of table, sorted by key, for which key
+// returns k.
+func (x *certIndex) lookup(table []byte, key func(int) []byte, k []byte) []int {
+	entry := func(j int) int { return int(binary.BigEndian.Uint32(table[j*4:])) }
+	n := len(table) / 4
+	j := sort.Search(n, func(j int) bool { return bytes.Compare(key(entry(j)), k) >= 0 })
+	var found []int
+	for ; j < n && bytes.Equal(key(entry(j)), k); j++ {
+		found = append(found, entry(j))
+	}
+	return found
+}
+
+func (x *certIndex) findPotentialParents(cert *Certificate) []int {
+	var candidates []int
+	if len(cert.AuthorityKeyId) > 0 {
+		candidates = x.lookup(x.byKeyId, x.keyId, cert.AuthorityKeyId)
+	}
+	if len(candidates) == 0 {
+		candidates = x.lookup(x.bySubject, x.subject, cert.RawIssuer)
+	}
+	return candidates
+}
+
+func (x *certIndex) findPotentialParentsConstantTime(cert *Certificate) []int {
+	var byKeyId, byName []int
+	for i := 0; i < x.n; i++ {
+		if len(cert.AuthorityKeyId) > 0 && subtle.ConstantTimeCompare(x.keyId(i), cert.AuthorityKeyId) == 1 {
+			byKeyId = append(byKeyId, i)
+		}
+		if subtle.ConstantTimeCompare(x.subject(i), cert.RawIssuer) == 1 {
+			byName = append(byName, i)
+		}
+	}
+	if len(byKeyId) > 0 {
+		return byKeyId
+	}
+	return byName
+}
+
+func (x *certIndex) contains(cert *Certificate) bool {
+	for _, i := range x.lookup(x.bySubject, x.subject, cert.RawSubject) {
+		if bytes.Equal(x.der(i), cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *certIndex) containsConstantTime(cert *Certificate) bool {
+	found := 0
+	for i := 0; i < x.n; i++ {
+		found |= subtle.ConstantTimeCompare(x.der(i), cert.Raw)
+	}
+	return found == 1
+}
+
+// WriteIndex writes the certificates in s to w as an index that
+// NewCertPoolFromIndex can load without copying it. The index is typically
+// built ahead of time, for example when building a container image, and
+// memory-mapped at run time.
+func (s *CertPool) WriteIndex(w io.Writer) error {
+	certs := s.certificates()
+	n := len(certs)
+	var keyIds []int
+	for i, c := range certs {
+		if len(c.Raw) == 0 {
+			return errNotParsed
+		}
+		if len(c.SubjectKeyId) > 0 {
+			keyIds = append(keyIds, i)
+		}
+	}
+
+	records := make([]uint32, 0, 6*n)
+	off := len(certIndexMagic) + 4 + n*certIndexRecordLen + 4*n + 4 + 4*len(keyIds)
+	for _, c := range certs {
+		// Any occurrence of the bytes will do, since only their value is
+		// used, such as the issuer of a self-issued certificate.
+		subject, keyId := bytes.Index(c.Raw, c.RawSubject), 0
+		if len(c.SubjectKeyId) > 0 {
+			keyId = bytes.Index(c.Raw, c.SubjectKeyId)
+		}
+		if subject < 0 || keyId < 0 {
+			return errors.New("x509: certificate fields don't match its DER encoding")
+		}
+		records = append(records,
+			uint32(off), uint32(len(c.Raw)),
+			uint32(off+subject), uint32(len(c.RawSubject)),
+			uint32(off+keyId), uint32(len(c.SubjectKeyId)))
+		off += len(c.Raw)
+	}
+
+	bySubject := make([]int, n)
+	for i := range bySubject {
+		bySubject[i] = i
+	}
+	sort.SliceStable(bySubject, func(i, j int) bool {
+		return bytes.Compare(certs[bySubject[i]].RawSubject, certs[bySubject[j]].RawSubject) < 0
+	})
+	sort.SliceStable(keyIds, func(i, j int) bool {
+		return bytes.Compare(certs[keyIds[i]].SubjectKeyId, certs[keyIds[j]].SubjectKeyId) < 0
+	})
+
+	var header bytes.Buffer
+	header.WriteString(certIndexMagic)
+	write := func(v uint32) {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], v)
+		header.Write(b[:])
+	}
+	write(uint32(n))
+	for _, v := range records {
+		write(v)
+	}
+	for _, i := range bySubject {
+		write(uint32(i))
+	}
+	write(uint32(len(keyIds)))
+	for _, i := range keyIds {
+		write(uint32(i))
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	for _, c := range certs {
+		if _, err := w.Write(c.Raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewCertPoolFromIndex returns a read-only CertPool over index, as written by
+// CertPool.WriteIndex.
+//
+// index is not copied, and must not be modified while the pool is in use. It
+// is meant to be a memory-mapped file, for example returned by syscall.Mmap,
+// so that a large root store takes little resident memory: certificates are
+// looked up without parsing them, and the ones used by Verify are parsed the
+// first time they are needed, with their Raw fields pointing into index.
+//
+// NewCertPoolFromIndex checks the index and parses each certificate once. Its
+// result is a regular pool for the other operations, but AddCert,
+// AddCertWithTrust and AppendCertsFromPEM panic.
+func NewCertPoolFromIndex(index []byte) (*CertPool, error) {
+	x := &certIndex{data: index}
+	if len(index) < len(certIndexMagic)+4 || string(index[:len(certIndexMagic)]) != certIndexMagic {
+		return nil, errBadCertIndex
+	}
+	rest := index[len(certIndexMagic):]
+	next := func(n int) ([]byte, bool) {
+		if uint64(len(rest)) < uint64(n)*4 {
+			return nil, false
+		}
+		b := rest[:n*4]
+		rest = rest[n*4:]
+		return b, true
+	}
+	count := func() (int, bool) {
+		b, ok := next(1)
+		if !ok || binary.BigEndian.Uint32(b) > uint32(len(index)) {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint32(b)), true
+	}
+	var ok bool
+	if x.n, ok = count(); !ok {
+		return nil, errBadCertIndex
+	}
+	if x.records, ok = next(6 * x.n); !ok {
+		return nil, errBadCertIndex
+	}
+	if x.bySubject, ok = next(x.n); !ok {
+		return nil, errBadCertIndex
+	}
+	m, ok := count()
+	if !ok || m > x.n {
+		return nil, errBadCertIndex
+	}
+	if x.byKeyId, ok = next(m); !ok {
+		return nil, errBadCertIndex
+	}
+
+	for i := 0; i < x.n; i++ {
+		r := x.records[i*certIndexRecordLen:]
+		var f [6]uint64
+		for j := range f {
+			f[j] = uint64(binary.BigEndian.Uint32(r[j*4:]))
+		}
+		derEnd := f[0] + f[1]
+		if derEnd > uint64(len(index)) ||
+			f[2] < f[0] || f[2]+f[3] > derEnd ||
+			f[4] < f[0] || f[4]+f[5] > derEnd {
+			return nil, errBadCertIndex
+		}
+		c, err := ParseCertificate(x.der(i))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(x.subject(i), c.RawSubject) || !bytes.Equal(x.keyId(i), c.SubjectKeyId) {
+			return nil, errBadCertIndex
+		}
+	}
+	checkTable := func(table []byte, key func(int) []byte) bool {
+		for j := 0; j < len(table)/4; j++ {
+			i := binary.BigEndian.Uint32(table[j*4:])
+			if uint64(i) >= uint64(x.n) {
+				return false
+			}
+			if j > 0 && bytes.Compare(key(int(binary.BigEndian.Uint32(table[j*4-4:]))), key(int(i))) > 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if !checkTable(x.bySubject, x.subject) || !checkTable(x.byKeyId, x.keyId) {
+		return nil, errBadCertIndex
+	}
+	x.parsed = make([]indexedCert, x.n)
+
+	return &CertPool{index: x}, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCertPoolFromIndex(t *testing.T) {
+	root, rootKey, err := generateCert("Index Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := generateCert("Other Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Index Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf.example.com", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := func(certs ...*Certificate) []byte {
+		pool := NewCertPool()
+		for _, c := range certs {
+			pool.AddCert(c)
+		}
+		var buf bytes.Buffer
+		if err := pool.WriteIndex(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	rootsIndex := index(other, root)
+	roots, err := NewCertPoolFromIndex(rootsIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediates, err := NewCertPoolFromIndex(index(inter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, constantTime := range []bool{false, true} {
+		chains, err := leaf.Verify(VerifyOptions{
+			Roots:                roots,
+			Intermediates:        intermediates,
+			ConstantTimeMatching: constantTime,
+		})
+		if err != nil {
+			t.Fatalf("ConstantTimeMatching %v: %v", constantTime, err)
+		}
+		if len(chains) != 1 || len(chains[0]) != 3 || !chains[0][2].Equal(root) {
+			t.Fatalf("ConstantTimeMatching %v: got chains %v", constantTime, chains)
+		}
+		// The root is parsed from the index, without copying it.
+		got := chains[0][2].Raw
+		if i := bytes.Index(rootsIndex, root.Raw); i < 0 || &got[0] != &rootsIndex[i] {
+			t.Error("the root certificate doesn't point into the index")
+		}
+	}
+
+	// Certificates are parsed once, so that Verify can recognize them.
+	chains1, err := leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chains2, err := leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chains1[0][1] != chains2[0][1] || chains1[0][2] != chains2[0][2] {
+		t.Error("certificates of the index were parsed again")
+	}
+
+	// A modified index makes Verify fail instead of panicking.
+	modified := append([]byte(nil), rootsIndex...)
+	modifiedRoots, err := NewCertPoolFromIndex(modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified[bytes.Index(modified, root.Raw)] = 0
+	if _, err := leaf.Verify(VerifyOptions{Roots: modifiedRoots, Intermediates: intermediates}); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("Verify with a modified index returned %v", err)
+	}
+
+	if subjects := roots.Subjects(); len(subjects) != 2 || !bytes.Equal(subjects[1], root.RawSubject) {
+		t.Errorf("Subjects() = %q", subjects)
+	}
+	if audit := roots.Audit(); len(audit) != 2 || !audit[0].Certificate.Equal(other) || audit[0].System {
+		t.Errorf("Audit() = %v", audit)
+	}
+	var want, got bytes.Buffer
+	pool := NewCertPool()
+	pool.AddCert(root)
+	pool.AddCert(other)
+	if err := pool.WritePEM(&want); err != nil {
+		t.Fatal(err)
+	}
+	if err := roots.WritePEM(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("WritePEM of the index pool differs from the original pool")
+	}
+
+	// Copies can be modified.
+	copied := roots.copy()
+	copied.AddCert(inter)
+	if !copied.contains(root) || !copied.contains(inter) || roots.contains(inter) {
+		t.Error("copy of the index pool has the wrong certificates")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("AddCert didn't panic on a pool backed by an index")
+			}
+		}()
+		roots.AddCert(leaf)
+	}()
+
+	for _, bad := range [][]byte{
+		nil,
+		[]byte("x509idx\x01"),
+		rootsIndex[:len(rootsIndex)-1],
+		append([]byte("x509idx\x02"), rootsIndex[8:]...),
+	} {
+		if _, err := NewCertPoolFromIndex(bad); err == nil {
+			t.Errorf("malformed index of %d bytes was accepted", len(bad))
+		}
+	}
+	// The subjects must be sorted.
+	swapped := append([]byte(nil), rootsIndex...)
+	table := len(certIndexMagic) + 4 + 2*certIndexRecordLen
+	copy(swapped[table:], rootsIndex[table+4:table+8])
+	copy(swapped[table+4:], rootsIndex[table:table+4])
+	if _, err := NewCertPoolFromIndex(swapped); err == nil {
+		t.Error("index with unsorted subjects was accepted")
+	}
+}
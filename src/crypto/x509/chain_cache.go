@@ -0,0 +1,210 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// VerifiedChainCache caches the chains verified for peer certificates, so
+// that a TLS stack seeing the same certificate again, for example when a
+// client resumes a session, doesn't verify it again. It can be consulted
+// from the VerifyPeerCertificate or VerifyConnection callbacks of
+// crypto/tls, with the raw leaf certificate of the peer.
+//
+// Entries are keyed by the SHA-256 hash of the leaf, and each chain is kept
+// until the earliest NotAfter time of its certificates, or MaxAge after it
+// was added, whichever comes first, according to the current time. The
+// options the chains were verified with are not recorded, so a cache must
+// only be used with a single VerifyOptions, other than DNSName, DNSNames and
+// KeyUsages, which Verify checks again for cached chains. The result of
+// verifying a leaf with the intermediates sent by a peer is reused for other
+// peers sending the same leaf. Cached chains are not checked for revocation
+// again.
+//
+// A VerifiedChainCache is safe for concurrent use. Its fields must not be
+// modified after the first call to one of its methods.
+type VerifiedChainCache struct {
+	// MaxEntries is the maximum number of leaves cached. When it is
+	// reached, expired entries are dropped, and then the entry that expires
+	// first. If zero, it is 1024.
+	MaxEntries int
+
+	// MaxAge, if not zero, is the maximum time a chain is cached, so that
+	// changes in revocation status are eventually noticed.
+	MaxAge time.Duration
+
+	now func() time.Time // for testing
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*verifiedChainEntry
+}
+
+type verifiedChainEntry struct {
+	chains  [][]*Certificate
+	expires []time.Time // for each of chains
+}
+
+const defaultMaxVerifiedChainEntries = 1024
+
+// Get returns the cached chains for the DER encoded leaf certificate
+// rawLeaf that have not expired, or nil if there are none. Unlike Verify,
+// it doesn't check host names or key usages.
+func (c *VerifiedChainCache) Get(rawLeaf []byte) [][]*Certificate {
+	now := c.timeNow()
+	key := sha256.Sum256(rawLeaf)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	var chains [][]*Certificate
+	for i, chain := range entry.chains {
+		if now.After(entry.expires[i]) {
+			continue
+		}
+		chains = append(chains, append([]*Certificate(nil), chain...))
+	}
+	if chains == nil {
+		delete(c.entries, key)
+	}
+	return chains
+}
+
+// Put adds chains, as returned by Certificate.Verify, replacing the cached
+// chains for their leaf, which must be the same for all of them.
+func (c *VerifiedChainCache) Put(chains [][]*Certificate) {
+	now := c.timeNow()
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return
+	}
+	entry := &verifiedChainEntry{}
+	for _, chain := range chains {
+		expires := chain[0].NotAfter
+		for _, cert := range chain[1:] {
+			if cert.NotAfter.Before(expires) {
+				expires = cert.NotAfter
+			}
+		}
+		if c.MaxAge > 0 && now.Add(c.MaxAge).Before(expires) {
+			expires = now.Add(c.MaxAge)
+		}
+		entry.chains = append(entry.chains, append([]*Certificate(nil), chain...))
+		entry.expires = append(entry.expires, expires)
+	}
+
+	key := sha256.Sum256(chains[0][0].Raw)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]*verifiedChainEntry)
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.makeRoom(now)
+	}
+	c.entries[key] = entry
+}
+
+// makeRoom drops entries until there is room for a new one. c.mu must be
+// held.
+func (c *VerifiedChainCache) makeRoom(now time.Time) {
+	max := c.MaxEntries
+	if max <= 0 {
+		max = defaultMaxVerifiedChainEntries
+	}
+	if len(c.entries) < max {
+		return
+	}
+	for key, entry := range c.entries {
+		if now.After(entry.latestExpiry()) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) >= max {
+		var first [sha256.Size]byte
+		var firstExpiry time.Time
+		for key, entry := range c.entries {
+			if expiry := entry.latestExpiry(); firstExpiry.IsZero() || expiry.Before(firstExpiry) {
+				first, firstExpiry = key, expiry
+			}
+		}
+		delete(c.entries, first)
+	}
+}
+
+// latestExpiry returns the time after which all the chains of e expired.
+func (e *verifiedChainEntry) latestExpiry() time.Time {
+	var latest time.Time
+	for _, expires := range e.expires {
+		if expires.After(latest) {
+			latest = expires
+		}
+	}
+	return latest
+}
+
+// Verify returns the cached chains for leaf, if any, and otherwise verifies
+// it with opts and caches the chains it returns. Cached chains are only
+// returned if leaf is valid for opts.DNSName and opts.DNSNames, and if they
+// allow opts.KeyUsages, as Verify would check.
+func (c *VerifiedChainCache) Verify(leaf *Certificate, opts VerifyOptions) ([][]*Certificate, error) {
+	if cached := c.Get(leaf.Raw); cached != nil {
+		if len(opts.DNSName) > 0 {
+			if err := leaf.verifyHostname(opts.DNSName, &opts); err != nil {
+				return nil, err
+			}
+		}
+		if len(opts.DNSNames) > 0 {
+			if err := leaf.verifyHostnames(opts.DNSNames, &opts); err != nil {
+				return nil, err
+			}
+		}
+		if chains := opts.chainsAllowingKeyUsages(cached); chains != nil {
+			return chains, nil
+		}
+		// Let Verify report why the usages are not allowed.
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Put(chains)
+	return chains, nil
+}
+
+// chainsAllowingKeyUsages returns the chains that allow opts.KeyUsages, as
+// checked by Verify, or nil if there are none.
+func (opts *VerifyOptions) chainsAllowingKeyUsages(chains [][]*Certificate) [][]*Certificate {
+	keyUsages := opts.KeyUsages
+	if len(keyUsages) == 0 {
+		keyUsages = []ExtKeyUsage{ExtKeyUsageServerAuth}
+	}
+	anyUsage := false
+	for _, usage := range keyUsages {
+		if usage == ExtKeyUsageAny && !opts.RequireAllKeyUsages {
+			anyUsage = true
+		}
+	}
+	var allowed [][]*Certificate
+	for _, chain := range chains {
+		if opts.checkChainTrust(chain, keyUsages) != nil {
+			continue
+		}
+		if anyUsage || opts.chainAllowsKeyUsages(chain, keyUsages) {
+			allowed = append(allowed, chain)
+		}
+	}
+	return allowed
+}
+
+func (c *VerifiedChainCache) timeNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
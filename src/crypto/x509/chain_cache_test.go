@@ -0,0 +1,122 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifiedChainCache(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := generateCert("other", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	opts := VerifyOptions{Roots: roots}
+
+	now := time.Now()
+	cache := &VerifiedChainCache{MaxEntries: 1, now: func() time.Time { return now }}
+	if chains := cache.Get(leaf.Raw); chains != nil {
+		t.Fatalf("empty cache returned %v", chains)
+	}
+	if _, err := cache.Verify(leaf, opts); err != nil {
+		t.Fatal(err)
+	}
+	// The cached chains are returned without verifying the leaf again.
+	chains, err := cache.Verify(leaf, VerifyOptions{Roots: NewCertPool()})
+	if err != nil {
+		t.Fatalf("cached leaf: %v", err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 2 || chains[0][1] != root {
+		t.Errorf("got chains %v", chains)
+	}
+	chains[0][1] = leaf
+	if chains := cache.Get(leaf.Raw); len(chains) != 1 || chains[0][1] != root {
+		t.Error("modifying the returned chains modified the cache")
+	}
+
+	// The chain expires with the first of its certificates to expire.
+	now = root.NotAfter.Add(time.Second)
+	if chains := cache.Get(leaf.Raw); chains != nil {
+		t.Errorf("expired chains were returned: %v", chains)
+	}
+
+	now = time.Now()
+	cache.Put([][]*Certificate{{leaf, root}})
+	cache.Put([][]*Certificate{{other, root}})
+	if cache.Get(leaf.Raw) != nil || cache.Get(other.Raw) == nil {
+		t.Error("MaxEntries was not honored")
+	}
+
+	cache = &VerifiedChainCache{MaxAge: time.Minute, now: func() time.Time { return now }}
+	cache.Put([][]*Certificate{{leaf, root}})
+	now = now.Add(2 * time.Minute)
+	if chains := cache.Get(leaf.Raw); chains != nil {
+		t.Errorf("chains older than MaxAge were returned: %v", chains)
+	}
+}
+
+func TestVerifiedChainCacheRechecksNames(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"leaf.example.com"},
+		ExtKeyUsage:  []ExtKeyUsage{ExtKeyUsageServerAuth},
+	}, root, key.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	cache := &VerifiedChainCache{}
+	if _, err := cache.Verify(leaf, VerifyOptions{Roots: roots, DNSName: "leaf.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.Verify(leaf, VerifyOptions{Roots: roots, DNSName: "other.example.com"})
+	if _, ok := err.(HostnameError); !ok {
+		t.Errorf("cached leaf with another DNSName: got %v, want a HostnameError", err)
+	}
+	_, err = cache.Verify(leaf, VerifyOptions{Roots: roots, DNSNames: []string{"leaf.example.com", "other.example.com"}})
+	if err == nil {
+		t.Error("cached leaf with another entry in DNSNames was accepted")
+	}
+	_, err = cache.Verify(leaf, VerifyOptions{Roots: roots, KeyUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}})
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != IncompatibleUsage {
+		t.Errorf("cached leaf with another key usage: got %v, want IncompatibleUsage", err)
+	}
+	if _, err := cache.Verify(leaf, VerifyOptions{Roots: roots, DNSName: "leaf.example.com"}); err != nil {
+		t.Errorf("cached leaf: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/asn1"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// ValidationLevel is how thoroughly a CA validated the subject of a
+// certificate before issuing it, as labeled by the CA/Browser Forum Baseline
+// Requirements.
+type ValidationLevel int
+
+const (
+	// ValidationUnknown means the level could not be determined.
+	ValidationUnknown ValidationLevel = iota
+	// ValidationDV means only control of the domain names was validated.
+	ValidationDV
+	// ValidationIV means the identity of an individual was validated.
+	ValidationIV
+	// ValidationOV means the identity of an organization was validated.
+	ValidationOV
+	// ValidationEV means the organization was validated following the
+	// Extended Validation Guidelines.
+	ValidationEV
+)
+
+func (l ValidationLevel) String() string {
+	switch l {
+	case ValidationDV:
+		return "DV"
+	case ValidationIV:
+		return "IV"
+	case ValidationOV:
+		return "OV"
+	case ValidationEV:
+		return "EV"
+	}
+	return "unknown"
+}
+
+// CertificateClass is the result of Classifier.Classify.
+type CertificateClass struct {
+	// Level is the validation level of the certificate.
+	Level ValidationLevel
+	// Heuristic reports whether Level was guessed from the subject of the
+	// certificate, because none of its policies is known.
+	Heuristic bool
+	// QWAC reports whether the certificate is a qualified website
+	// authentication certificate under the eIDAS regulation.
+	QWAC bool
+}
+
+// A Classifier labels TLS server certificates with their validation level,
+// and whether they are QWACs, for analytics and security user interfaces.
+// The result is a heuristic: it is not verified, and says nothing about
+// whether a certificate is trusted.
+//
+// The tables of policy OIDs can be changed, for example to add the legacy EV
+// policies of individual CAs, but not while Classify is running.
+type Classifier struct {
+	// DVPolicies, IVPolicies, OVPolicies and EVPolicies are the certificate
+	// policies that mark each validation level. If a certificate asserts
+	// policies of several levels, the highest one is used.
+	DVPolicies []OID
+	IVPolicies []OID
+	OVPolicies []OID
+	EVPolicies []OID
+
+	// QWACPolicies are the certificate policies that mark a QWAC. A
+	// certificate with the QcCompliance and the web QcType statements of
+	// ETSI EN 319 412-5 is a QWAC as well.
+	QWACPolicies []OID
+}
+
+// NewClassifier returns a Classifier with the policies reserved by the
+// CA/Browser Forum, and the equivalent policies of ETSI EN 319 411-1 and
+// ETSI EN 319 411-2.
+func NewClassifier() *Classifier {
+	return &Classifier{
+		DVPolicies: []OID{
+			mustOIDFromInts(2, 23, 140, 1, 2, 1), // CA/B Forum domain-validated
+			mustOIDFromInts(0, 4, 0, 2042, 1, 6), // ETSI DVCP
+		},
+		IVPolicies: []OID{
+			mustOIDFromInts(2, 23, 140, 1, 2, 3), // CA/B Forum individual-validated
+			mustOIDFromInts(0, 4, 0, 2042, 1, 8), // ETSI IVCP
+		},
+		OVPolicies: []OID{
+			mustOIDFromInts(2, 23, 140, 1, 2, 2), // CA/B Forum organization-validated
+			mustOIDFromInts(0, 4, 0, 2042, 1, 7), // ETSI OVCP
+		},
+		EVPolicies: []OID{
+			mustOIDFromInts(2, 23, 140, 1, 1),    // CA/B Forum extended-validation
+			mustOIDFromInts(0, 4, 0, 2042, 1, 4), // ETSI EVCP
+		},
+		QWACPolicies: []OID{
+			mustOIDFromInts(0, 4, 0, 194112, 1, 4), // ETSI QCP-w
+			mustOIDFromInts(0, 4, 0, 19495, 3, 1),  // ETSI PSD2 QCP-w
+		},
+	}
+}
+
+func mustOIDFromInts(arcs ...uint64) OID {
+	oid, err := OIDFromInts(arcs)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+var (
+	oidExtensionQCStatements = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}
+	oidQCCompliance          = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 1}
+	oidQCType                = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6}
+	oidQCTypeWeb             = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 3}
+
+	oidJurisdictionCountry = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 3}
+	oidBusinessCategory    = asn1.ObjectIdentifier{2, 5, 4, 15}
+	oidGivenName           = asn1.ObjectIdentifier{2, 5, 4, 42}
+	oidSurname             = asn1.ObjectIdentifier{2, 5, 4, 4}
+)
+
+// Classify returns the class of c, which should be a leaf certificate.
+//
+// The validation level is that of the policies of c. If c asserts none of
+// the known policies, the level is guessed from its subject: EV if it has
+// the jurisdiction country and business category attributes required by the
+// Extended Validation Guidelines, OV if it names an organization, IV if it
+// names a person, and DV if it has DNS names but none of these.
+func (cl *Classifier) Classify(c *Certificate) CertificateClass {
+	var class CertificateClass
+	for _, p := range c.Policies {
+		switch {
+		case containsOID(cl.EVPolicies, p):
+			class.Level = ValidationEV
+		case containsOID(cl.OVPolicies, p) && class.Level < ValidationOV:
+			class.Level = ValidationOV
+		case containsOID(cl.IVPolicies, p) && class.Level < ValidationIV:
+			class.Level = ValidationIV
+		case containsOID(cl.DVPolicies, p) && class.Level < ValidationDV:
+			class.Level = ValidationDV
+		}
+		if containsOID(cl.QWACPolicies, p) {
+			class.QWAC = true
+		}
+	}
+	if !class.QWAC {
+		class.QWAC = hasQualifiedWebStatements(c)
+	}
+
+	if class.Level == ValidationUnknown {
+		class.Heuristic = true
+		subject := func(oid asn1.ObjectIdentifier) bool {
+			for _, atv := range c.Subject.Names {
+				if atv.Type.Equal(oid) {
+					return true
+				}
+			}
+			return false
+		}
+		switch {
+		case subject(oidJurisdictionCountry) && subject(oidBusinessCategory) && c.Subject.SerialNumber != "":
+			class.Level = ValidationEV
+		case len(c.Subject.Organization) > 0:
+			class.Level = ValidationOV
+		case subject(oidGivenName) || subject(oidSurname):
+			class.Level = ValidationIV
+		case len(c.DNSNames) > 0:
+			class.Level = ValidationDV
+		default:
+			class.Heuristic = false
+		}
+	}
+	return class
+}
+
+func containsOID(oids []OID, oid OID) bool {
+	for _, o := range oids {
+		if o.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasQualifiedWebStatements reports whether c has the QcCompliance statement
+// and a QcType statement including web authentication, ETSI EN 319 412-5.
+func hasQualifiedWebStatements(c *Certificate) bool {
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(oidExtensionQCStatements) {
+			continue
+		}
+		// QCStatements ::= SEQUENCE OF QCStatement
+		// QCStatement ::= SEQUENCE {
+		//     statementId   QC-STATEMENT.&id({SupportedStatements}),
+		//     statementInfo QC-STATEMENT.&Type({SupportedStatements}{@statementId}) OPTIONAL }
+		// QcType ::= SEQUENCE OF OBJECT IDENTIFIER
+		input := cryptobyte.String(ext.Value)
+		var statements cryptobyte.String
+		if !input.ReadASN1(&statements, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+			return false
+		}
+		var compliance, web bool
+		for !statements.Empty() {
+			var statement cryptobyte.String
+			var id asn1.ObjectIdentifier
+			if !statements.ReadASN1(&statement, cryptobyte_asn1.SEQUENCE) ||
+				!statement.ReadASN1ObjectIdentifier(&id) {
+				return false
+			}
+			switch {
+			case id.Equal(oidQCCompliance):
+				compliance = true
+			case id.Equal(oidQCType):
+				var types cryptobyte.String
+				if !statement.ReadASN1(&types, cryptobyte_asn1.SEQUENCE) {
+					return false
+				}
+				for !types.Empty() {
+					var qcType asn1.ObjectIdentifier
+					if !types.ReadASN1ObjectIdentifier(&qcType) {
+						return false
+					}
+					if qcType.Equal(oidQCTypeWeb) {
+						web = true
+					}
+				}
+			}
+		}
+		return compliance && web
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCert := func(subject pkix.Name, policies []string, exts ...pkix.Extension) *Certificate {
+		template := &Certificate{
+			SerialNumber:    big.NewInt(1),
+			Subject:         subject,
+			NotBefore:       time.Now(),
+			NotAfter:        time.Now().Add(time.Hour),
+			DNSNames:        []string{"example.com"},
+			ExtraExtensions: exts,
+		}
+		for _, p := range policies {
+			oid, err := ParseOID(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template.Policies = append(template.Policies, oid)
+		}
+		der, err := CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	qcStatements := func(types ...asn1.ObjectIdentifier) pkix.Extension {
+		type qcStatement struct {
+			ID    asn1.ObjectIdentifier
+			Types []asn1.ObjectIdentifier `asn1:"optional"`
+		}
+		value, err := asn1.Marshal([]qcStatement{{ID: oidQCCompliance}, {ID: oidQCType, Types: types}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkix.Extension{Id: oidExtensionQCStatements, Value: value}
+	}
+	evSubject := pkix.Name{
+		CommonName:   "example.com",
+		Organization: []string{"Example"},
+		SerialNumber: "1234",
+		ExtraNames: []pkix.AttributeTypeAndValue{
+			{Type: oidJurisdictionCountry, Value: "DE"},
+			{Type: oidBusinessCategory, Value: "Private Organization"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		cert      *Certificate
+		level     ValidationLevel
+		heuristic bool
+		qwac      bool
+	}{
+		{"DV policy", newCert(pkix.Name{Organization: []string{"Example"}}, []string{"2.23.140.1.2.1"}), ValidationDV, false, false},
+		{"OV and EV policies", newCert(pkix.Name{}, []string{"2.23.140.1.2.2", "2.23.140.1.1"}), ValidationEV, false, false},
+		{"ETSI IVCP", newCert(pkix.Name{}, []string{"0.4.0.2042.1.8"}), ValidationIV, false, false},
+		{"QWAC policy", newCert(pkix.Name{}, []string{"2.23.140.1.1", "0.4.0.194112.1.4"}), ValidationEV, false, true},
+		{"QC statements", newCert(pkix.Name{}, []string{"2.23.140.1.2.2"}, qcStatements(oidQCTypeWeb)), ValidationOV, false, true},
+		{"QC statements for signatures", newCert(pkix.Name{}, nil, qcStatements(asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 1})), ValidationDV, true, false},
+		{"EV subject", newCert(evSubject, []string{"1.2.3.4"}), ValidationEV, true, false},
+		{"OV subject", newCert(pkix.Name{Organization: []string{"Example"}}, nil), ValidationOV, true, false},
+		{"IV subject", newCert(pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{{Type: oidSurname, Value: "Doe"}}}, nil), ValidationIV, true, false},
+		{"DV subject", newCert(pkix.Name{CommonName: "example.com"}, nil), ValidationDV, true, false},
+	}
+	classifier := NewClassifier()
+	for _, tt := range tests {
+		got := classifier.Classify(tt.cert)
+		want := CertificateClass{Level: tt.level, Heuristic: tt.heuristic, QWAC: tt.qwac}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, want)
+		}
+	}
+
+	// The tables can be extended, for example with the EV policy of a CA.
+	legacyEV := newCert(pkix.Name{}, []string{"1.3.6.1.4.1.34697.2.1"})
+	if got := classifier.Classify(legacyEV); got.Level != ValidationDV || !got.Heuristic {
+		t.Errorf("unknown policy: got %+v", got)
+	}
+	classifier.EVPolicies = append(classifier.EVPolicies, legacyEV.Policies[0])
+	if got := classifier.Classify(legacyEV); got.Level != ValidationEV || got.Heuristic {
+		t.Errorf("added EV policy: got %+v", got)
+	}
+}
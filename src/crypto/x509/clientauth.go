@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SANType is a set of subject alternative name types.
+type SANType int
+
+const (
+	SANDNSName SANType = 1 << iota
+	SANEmailAddress
+	SANIPAddress
+	SANURI
+)
+
+func (t SANType) String() string {
+	var names []string
+	for _, n := range []struct {
+		t    SANType
+		name string
+	}{
+		{SANDNSName, "DNS name"},
+		{SANEmailAddress, "email address"},
+		{SANIPAddress, "IP address"},
+		{SANURI, "URI"},
+	} {
+		if t&n.t != 0 {
+			names = append(names, n.name)
+			t &^= n.t
+		}
+	}
+	if t != 0 {
+		names = append(names, fmt.Sprintf("SANType(%#x)", int(t)))
+	}
+	return strings.Join(names, ", ")
+}
+
+// ClientAuthOptions contains parameters for Certificate.CheckClientAuth.
+type ClientAuthOptions struct {
+	// CurrentTime is used to check the validity period of the certificate.
+	// If zero, the current time is used.
+	CurrentTime time.Time
+
+	// RequiredSANs is the set of subject alternative name types the
+	// certificate must have at least one name of, for example SANURI for a
+	// SPIFFE ID. Each type in the set is required.
+	RequiredSANs SANType
+}
+
+// CheckClientAuth checks that c is suitable as a TLS client certificate, so
+// that servers outside crypto/tls, such as those of other transports or
+// protocols, can apply the same rules. c must be valid at opts.CurrentTime,
+// its extended key usages must allow ExtKeyUsageClientAuth as
+// IsServerAuthCert checks ExtKeyUsageServerAuth, and its key usage, if
+// present, must include KeyUsageDigitalSignature, since the client proves
+// possession of its key with a signature.
+//
+// CheckClientAuth only checks c itself. Its chain must still be verified, with
+// VerifyOptions.KeyUsages set to ExtKeyUsageClientAuth. Violations are
+// reported with a CertificateInvalidError.
+func (c *Certificate) CheckClientAuth(opts ClientAuthOptions) error {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if now.Before(c.NotBefore) {
+		return CertificateInvalidError{
+			Cert:   c,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), c.NotBefore.Format(time.RFC3339)),
+		}
+	} else if now.After(c.NotAfter) {
+		return CertificateInvalidError{
+			Cert:   c,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339)),
+		}
+	}
+
+	if !checkChainForKeyUsage([]*Certificate{c}, []ExtKeyUsage{ExtKeyUsageClientAuth}) {
+		return CertificateInvalidError{c, IncompatibleUsage, "extended key usage does not include client authentication"}
+	}
+	if c.KeyUsage != 0 && c.KeyUsage&KeyUsageDigitalSignature == 0 {
+		return CertificateInvalidError{c, IncompatibleUsage, "key usage does not include digital signature"}
+	}
+
+	var missing SANType
+	if opts.RequiredSANs&SANDNSName != 0 && len(c.DNSNames) == 0 {
+		missing |= SANDNSName
+	}
+	if opts.RequiredSANs&SANEmailAddress != 0 && len(c.EmailAddresses) == 0 {
+		missing |= SANEmailAddress
+	}
+	if opts.RequiredSANs&SANIPAddress != 0 && len(c.IPAddresses) == 0 {
+		missing |= SANIPAddress
+	}
+	if opts.RequiredSANs&SANURI != 0 && len(c.URIs) == 0 {
+		missing |= SANURI
+	}
+	if missing != 0 {
+		return CertificateInvalidError{c, MissingSubjectAltName, missing.String()}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCheckClientAuth(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	spiffe, _ := url.Parse("spiffe://example.org/workload")
+	client := func(edit func(*Certificate)) *Certificate {
+		c := &Certificate{
+			NotBefore:   now.Add(-time.Hour),
+			NotAfter:    now.Add(time.Hour),
+			KeyUsage:    KeyUsageDigitalSignature | KeyUsageKeyEncipherment,
+			ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageClientAuth},
+			URIs:        []*url.URL{spiffe},
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		cert   *Certificate
+		opts   ClientAuthOptions
+		reason InvalidReason // or -1 if the certificate is suitable
+		detail string
+	}{
+		{"client", client(nil), ClientAuthOptions{}, -1, ""},
+		{"no usages", client(func(c *Certificate) { c.KeyUsage, c.ExtKeyUsage = 0, nil }), ClientAuthOptions{}, -1, ""},
+		{"any usage", client(func(c *Certificate) { c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageAny} }), ClientAuthOptions{}, -1, ""},
+		{"server only", client(func(c *Certificate) { c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageServerAuth} }), ClientAuthOptions{}, IncompatibleUsage, "extended key usage does not include client authentication"},
+		{"key agreement only", client(func(c *Certificate) { c.KeyUsage = KeyUsageKeyAgreement }), ClientAuthOptions{}, IncompatibleUsage, "key usage does not include digital signature"},
+		{"expired", client(nil), ClientAuthOptions{CurrentTime: now.Add(2 * time.Hour)}, Expired, "current time 2017-07-14T04:40:00Z is after 2017-07-14T03:40:00Z"},
+		{"not yet valid", client(nil), ClientAuthOptions{CurrentTime: now.Add(-2 * time.Hour)}, Expired, "current time 2017-07-14T00:40:00Z is before 2017-07-14T01:40:00Z"},
+		{"required URI", client(nil), ClientAuthOptions{CurrentTime: now, RequiredSANs: SANURI}, -1, ""},
+		{"missing SANs", client(nil), ClientAuthOptions{CurrentTime: now, RequiredSANs: SANURI | SANDNSName | SANEmailAddress}, MissingSubjectAltName, "DNS name, email address"},
+	}
+	for _, tt := range tests {
+		if tt.opts.CurrentTime.IsZero() {
+			tt.opts.CurrentTime = now
+		}
+		err := tt.cert.CheckClientAuth(tt.opts)
+		if tt.reason == -1 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if e, ok := err.(CertificateInvalidError); !ok || e.Reason != tt.reason || e.Detail != tt.detail || e.Cert != tt.cert {
+			t.Errorf("%s: got %v, want reason %d with %q", tt.name, err, tt.reason, tt.detail)
+		}
+	}
+}
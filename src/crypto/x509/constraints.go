@@ -0,0 +1,263 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// NameConstraints holds the name constraints of a CA certificate, as
+// described in RFC 5280, Section 4.2.1.10. Its fields have the same meaning
+// as the corresponding fields of Certificate.
+type NameConstraints struct {
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+
+	PermittedIPRanges []*net.IPNet
+	ExcludedIPRanges  []*net.IPNet
+
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+
+	PermittedURIDomains []string
+	ExcludedURIDomains  []string
+
+	PermittedDirectoryNames []pkix.Name
+	ExcludedDirectoryNames  []pkix.Name
+}
+
+// NameConstraints returns the name constraints of c. The returned value
+// shares its slices with c.
+func (c *Certificate) NameConstraints() *NameConstraints {
+	return &NameConstraints{
+		PermittedDNSDomains:     c.PermittedDNSDomains,
+		ExcludedDNSDomains:      c.ExcludedDNSDomains,
+		PermittedIPRanges:       c.PermittedIPRanges,
+		ExcludedIPRanges:        c.ExcludedIPRanges,
+		PermittedEmailAddresses: c.PermittedEmailAddresses,
+		ExcludedEmailAddresses:  c.ExcludedEmailAddresses,
+		PermittedURIDomains:     c.PermittedURIDomains,
+		ExcludedURIDomains:      c.ExcludedURIDomains,
+		PermittedDirectoryNames: c.PermittedDirectoryNames,
+		ExcludedDirectoryNames:  c.ExcludedDirectoryNames,
+	}
+}
+
+// Check reports whether the subject and subject alternative names of cert
+// are allowed by nc, following the same rules as Verify applies to the names in a chain.
+// This lets tools check whether a certificate would violate the constraints
+// of an intermediate, for example when linting a CA, without building and
+// verifying a chain. Signatures, validity periods and other constraints are
+// not checked. Directory names are compared strictly, as if
+// VerifyOptions.NormalizeDirectoryNames was false.
+//
+// If a name is not allowed, the error is a CertificateInvalidError for cert
+// with reason CANotAuthorizedForThisName. As in Verify, a certificate without
+// subject alternative names whose common name could be taken as a hostname is
+// rejected with reason NameConstraintsWithoutSANs.
+func (nc *NameConstraints) Check(cert *Certificate) error {
+	if cert.commonNameAsHostname(nil) {
+		return CertificateInvalidError{cert, NameConstraintsWithoutSANs, ""}
+	}
+	count := 0
+	return nc.check(cert, cert, &count, defaultMaxConstraintComparisons, &VerifyOptions{}, nil)
+}
+
+// checkIssuedNames checks the names of a certificate being created, with the
+// DER encoded subject and the extensions given, against the name constraints
+// of the CA certificates cas, which will issue it directly or indirectly.
+func checkIssuedNames(cas []*Certificate, subject []byte, extensions []pkix.Extension) error {
+	var rdn pkix.RDNSequence
+	if rest, err := asn1.Unmarshal(subject, &rdn); err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return errors.New("x509: trailing data after subject")
+	}
+	cert := &Certificate{RawSubject: subject, Extensions: extensions}
+	cert.Subject.FillFromRDNSequence(&rdn)
+
+	count := 0
+	for _, ca := range cas {
+		nc := ca.NameConstraints()
+		if permitted, excluded := nc.subtrees(); permitted+excluded == 0 {
+			continue
+		}
+		if cert.commonNameAsHostname(nil) {
+			return CertificateInvalidError{ca, NameConstraintsWithoutSANs, ""}
+		}
+		if err := nc.check(ca, cert, &count, defaultMaxConstraintComparisons, &VerifyOptions{}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subtrees returns the number of permitted and excluded subtrees of nc.
+func (nc *NameConstraints) subtrees() (permitted, excluded int) {
+	permitted = len(nc.PermittedDNSDomains) + len(nc.PermittedIPRanges) + len(nc.PermittedEmailAddresses) +
+		len(nc.PermittedURIDomains) + len(nc.PermittedDirectoryNames)
+	excluded = len(nc.ExcludedDNSDomains) + len(nc.ExcludedIPRanges) + len(nc.ExcludedEmailAddresses) +
+		len(nc.ExcludedURIDomains) + len(nc.ExcludedDirectoryNames)
+	return permitted, excluded
+}
+
+// check checks the names of leaf against nc. ca is the certificate that
+// errors and traces refer to. If record is not nil, it is called for every
+// name that is checked.
+func (nc *NameConstraints) check(ca, leaf *Certificate, count *int, maxConstraintComparisons int, opts *VerifyOptions, record func(nameType, name string)) error {
+	checkName := func(nameType, name string, parsedName interface{},
+		match func(parsedName, constraint interface{}) (bool, error),
+		permitted, excluded interface{}) error {
+		err := ca.checkNameConstraints(count, maxConstraintComparisons, nameType, name, parsedName, match, permitted, excluded)
+		opts.tracef("%s %q against the name constraints of %v: %s", nameType, name, ca, traceErr(err))
+		if record != nil {
+			record(nameType, name)
+		}
+		return err
+	}
+
+	checkDirectoryName := func(name pkix.Name) error {
+		return checkName("directory name", name.String(), name.Names,
+			func(parsedName, constraint interface{}) (bool, error) {
+				return matchDirectoryNameConstraint(parsedName.([]pkix.AttributeTypeAndValue), constraint.(pkix.Name), opts.NormalizeDirectoryNames), nil
+			}, nc.PermittedDirectoryNames, nc.ExcludedDirectoryNames)
+	}
+
+	if len(leaf.Subject.Names) > 0 && (len(nc.PermittedDirectoryNames) > 0 || len(nc.ExcludedDirectoryNames) > 0) {
+		if err := checkDirectoryName(leaf.Subject); err != nil {
+			return err
+		}
+	}
+
+	if !leaf.hasSANExtension() {
+		return nil
+	}
+	return forEachSAN(leaf.getSANExtension(), func(tag int, data []byte) error {
+		switch tag {
+		case nameTypeEmail:
+			name := string(data)
+			mailbox, ok := parseRFC2821Mailbox(name)
+			if !ok {
+				return fmt.Errorf("x509: cannot parse rfc822Name %q", mailbox)
+			}
+
+			err := checkName("email address", name, mailbox,
+				func(parsedName, constraint interface{}) (bool, error) {
+					return matchEmailConstraint(parsedName.(rfc2821Mailbox), constraint.(string))
+				}, nc.PermittedEmailAddresses, nc.ExcludedEmailAddresses)
+			if err != nil {
+				return err
+			}
+
+		case nameTypeDNS:
+			name := string(data)
+			if _, ok := domainToReverseLabels(name); !ok {
+				return fmt.Errorf("x509: cannot parse dnsName %q", name)
+			}
+
+			err := checkName("DNS name", name, name,
+				func(parsedName, constraint interface{}) (bool, error) {
+					return matchDomainConstraint(parsedName.(string), constraint.(string))
+				}, nc.PermittedDNSDomains, nc.ExcludedDNSDomains)
+			if err != nil {
+				return err
+			}
+
+		case nameTypeURI:
+			name := string(data)
+			uri, err := url.Parse(name)
+			if err != nil {
+				return fmt.Errorf("x509: internal error: URI SAN %q failed to parse", name)
+			}
+
+			err = checkName("URI", name, uri,
+				func(parsedName, constraint interface{}) (bool, error) {
+					return matchURIConstraint(parsedName.(*url.URL), constraint.(string))
+				}, nc.PermittedURIDomains, nc.ExcludedURIDomains)
+			if err != nil {
+				return err
+			}
+
+		case nameTypeIP:
+			ip := net.IP(data)
+			if l := len(ip); l != net.IPv4len && l != net.IPv6len {
+				return fmt.Errorf("x509: internal error: IP SAN %x failed to parse", data)
+			}
+
+			match := matchIPConstraint
+			if opts.IPv4Mapped == IPv4MappedEquivalent {
+				match = matchIPConstraintUnmapped
+			}
+			err := checkName("IP address", ip.String(), ip,
+				func(parsedName, constraint interface{}) (bool, error) {
+					return match(parsedName.(net.IP), constraint.(*net.IPNet))
+				}, nc.PermittedIPRanges, nc.ExcludedIPRanges)
+			if err != nil {
+				return err
+			}
+
+		case nameTypeDirectory:
+			var rdns pkix.RDNSequence
+			if rest, err := asn1.Unmarshal(data, &rdns); err != nil || len(rest) != 0 {
+				return fmt.Errorf("x509: cannot parse directoryName %x", data)
+			}
+			var name pkix.Name
+			name.FillFromRDNSequence(&rdns)
+			if err := checkDirectoryName(name); err != nil {
+				return err
+			}
+
+		default:
+			// Unknown SAN types are ignored.
+		}
+
+		return nil
+	})
+}
+
+// matchDirectoryNameConstraint reports whether the attributes of constraint
+// are a prefix of name. Attributes of a constraint that was not parsed from
+// a certificate are taken in the order of pkix.Name.ToRDNSequence.
+func matchDirectoryNameConstraint(name []pkix.AttributeTypeAndValue, constraint pkix.Name, normalize bool) bool {
+	attrs := constraint.Names
+	if len(attrs) == 0 {
+		for _, rdn := range constraint.ToRDNSequence() {
+			attrs = append(attrs, rdn...)
+		}
+	}
+	if len(attrs) > len(name) {
+		return false
+	}
+	for i, attr := range attrs {
+		if !attr.Type.Equal(name[i].Type) {
+			return false
+		}
+		a, aIsString := attr.Value.(string)
+		b, bIsString := name[i].Value.(string)
+		switch {
+		case aIsString && bIsString && normalize:
+			if !strings.EqualFold(normalizeDirectoryString(a), normalizeDirectoryString(b)) {
+				return false
+			}
+		case !reflect.DeepEqual(attr.Value, name[i].Value):
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeDirectoryString applies the insignificant space handling of
+// RFC 4518, Section 2.6.1: leading and trailing spaces are removed and
+// internal runs of spaces are replaced by a single one.
+func normalizeDirectoryString(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNameConstraintsCheck(t *testing.T) {
+	_, permittedIPs, _ := net.ParseCIDR("192.0.2.0/24")
+	nc := &NameConstraints{
+		PermittedDNSDomains:    []string{"example.com"},
+		ExcludedDNSDomains:     []string{"bad.example.com"},
+		PermittedIPRanges:      []*net.IPNet{permittedIPs},
+		ExcludedEmailAddresses: []string{"evil@example.com"},
+		PermittedURIDomains:    []string{".example.com"},
+	}
+	uri := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		mod    func(*Certificate)
+		reason InvalidReason // or -1 if the certificate is permitted
+	}{
+		{"permitted DNS name", func(c *Certificate) { c.DNSNames = []string{"www.example.com"} }, -1},
+		{"excluded DNS name", func(c *Certificate) { c.DNSNames = []string{"x.bad.example.com"} }, CANotAuthorizedForThisName},
+		{"other DNS name", func(c *Certificate) { c.DNSNames = []string{"example.org"} }, CANotAuthorizedForThisName},
+		{"permitted IP", func(c *Certificate) { c.IPAddresses = []net.IP{net.IPv4(192, 0, 2, 10).To4()} }, -1},
+		{"other IP", func(c *Certificate) { c.IPAddresses = []net.IP{net.IPv4(198, 51, 100, 1).To4()} }, CANotAuthorizedForThisName},
+		{"excluded email", func(c *Certificate) { c.EmailAddresses = []string{"evil@example.com"} }, CANotAuthorizedForThisName},
+		{"other email", func(c *Certificate) { c.EmailAddresses = []string{"good@example.com"} }, -1},
+		{"permitted URI", func(c *Certificate) { c.URIs = []*url.URL{uri("https://api.example.com/")} }, -1},
+		{"other URI", func(c *Certificate) { c.URIs = []*url.URL{uri("https://example.com/")} }, CANotAuthorizedForThisName},
+	}
+	for _, test := range tests {
+		template := &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Leaf"},
+			NotBefore:    time.Unix(1000, 0),
+			NotAfter:     time.Unix(100000, 0),
+		}
+		test.mod(template)
+		cert := serialiseAndParse(t, template)
+		if cert == nil {
+			continue
+		}
+
+		err := nc.Check(cert)
+		if test.reason == -1 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != test.reason {
+			t.Errorf("%s: got error %v, want reason %d", test.name, err, test.reason)
+		}
+	}
+
+	if err := (&NameConstraints{}).Check(serialiseAndParse(t, &Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		DNSNames:     []string{"anything.example"},
+	})); err != nil {
+		t.Errorf("empty constraints rejected a certificate: %v", err)
+	}
+}
+
+func TestDirectoryNameConstraints(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &Certificate{
+		SerialNumber:            big.NewInt(1),
+		Subject:                 pkix.Name{CommonName: "Root"},
+		NotBefore:               time.Unix(1000, 0),
+		NotAfter:                time.Unix(100000, 0),
+		BasicConstraintsValid:   true,
+		IsCA:                    true,
+		PermittedDirectoryNames: []pkix.Name{{Country: []string{"US"}, Organization: []string{"Acme Corp"}}},
+		ExcludedDirectoryNames:  []pkix.Name{{Country: []string{"US"}, Organization: []string{"Acme Corp"}, OrganizationalUnit: []string{"Blocked"}}},
+	}
+	rootDER, err := CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	tests := []struct {
+		name              string
+		subject           pkix.Name
+		dirNames          []pkix.Name
+		strict, normalize bool
+	}{
+		{
+			name:      "exact",
+			subject:   pkix.Name{Country: []string{"US"}, Organization: []string{"Acme Corp"}, CommonName: "Leaf"},
+			strict:    true,
+			normalize: true,
+		},
+		{
+			name:      "case and spaces",
+			subject:   pkix.Name{Country: []string{"US"}, Organization: []string{" ACME  corp"}, CommonName: "Leaf"},
+			strict:    false,
+			normalize: true,
+		},
+		{
+			name:    "other organization",
+			subject: pkix.Name{Country: []string{"US"}, Organization: []string{"Other"}, CommonName: "Leaf"},
+		},
+		{
+			name:    "excluded unit",
+			subject: pkix.Name{Country: []string{"US"}, Organization: []string{"Acme Corp"}, OrganizationalUnit: []string{"Blocked"}, CommonName: "Leaf"},
+		},
+		{
+			name:     "excluded alternative name",
+			subject:  pkix.Name{Country: []string{"US"}, Organization: []string{"Acme Corp"}, CommonName: "Leaf"},
+			dirNames: []pkix.Name{{Country: []string{"US"}, Organization: []string{"Acme Corp"}, OrganizationalUnit: []string{"Blocked"}}},
+		},
+	}
+	for _, test := range tests {
+		template := &Certificate{
+			SerialNumber:   big.NewInt(2),
+			Subject:        test.subject,
+			NotBefore:      time.Unix(1000, 0),
+			NotAfter:       time.Unix(100000, 0),
+			DirectoryNames: test.dirNames,
+		}
+		der, err := CreateCertificate(rand.Reader, template, root, rootKey.Public(), rootKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, normalize := range []bool{false, true} {
+			want := test.strict
+			if normalize {
+				want = test.normalize
+			}
+			_, err := leaf.Verify(VerifyOptions{
+				Roots:                   roots,
+				CurrentTime:             time.Unix(2000, 0),
+				NormalizeDirectoryNames: normalize,
+			})
+			if (err == nil) != want {
+				t.Errorf("%s (normalize %v): got error %v, want success %v", test.name, normalize, err, want)
+			}
+		}
+	}
+}
+
+func TestVerifyChainsNameConstraints(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(template, parent *Certificate, pub, priv interface{}) *Certificate {
+		template.NotBefore = time.Unix(1000, 0)
+		template.NotAfter = time.Unix(100000, 0)
+		der, err := CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	rootTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root"},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		PermittedDNSDomains:   []string{"example.com"},
+		ExcludedIPRanges:      []*net.IPNet{{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}},
+	}
+	root := create(rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	inter := create(&Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate"},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root, interKey.Public(), rootKey)
+	leaf := create(&Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Leaf"},
+		DNSNames:     []string{"www.example.com"},
+		IPAddresses:  []net.IP{net.IPv4(192, 0, 2, 1).To4()},
+	}, inter, interKey.Public(), interKey)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+	chains, err := leaf.VerifyChains(VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(2000, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 {
+		t.Fatalf("got %d chains, want 1", len(chains))
+	}
+
+	evaluations := chains[0].NameConstraints
+	if len(evaluations) != 1 {
+		t.Fatalf("got %d evaluations, want only the root's", len(evaluations))
+	}
+	e := evaluations[0]
+	if e.Certificate != root {
+		t.Errorf("evaluation is for %q, want the root", e.Certificate.Subject)
+	}
+	if e.PermittedSubtrees != 1 || e.ExcludedSubtrees != 1 {
+		t.Errorf("got %d permitted and %d excluded subtrees, want 1 and 1", e.PermittedSubtrees, e.ExcludedSubtrees)
+	}
+	want := []ConstrainedName{{"DNS name", "www.example.com"}, {"IP address", "192.0.2.1"}}
+	if !reflect.DeepEqual(e.Names, want) {
+		t.Errorf("got names %v, want %v", e.Names, want)
+	}
+}
+
+func TestCreateCertificateEnforceNameConstraints(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Constrained CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		PermittedDNSDomains:   []string{"example.com"},
+		ExcludedDirectoryNames: []pkix.Name{{
+			Organization: []string{"Evil"},
+		}},
+	}
+	der, err := CreateCertificate(rand.Reader, caTemplate, caTemplate, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subject pkix.Name
+		dns     []string
+		reason  InvalidReason
+	}{
+		{"permitted", pkix.Name{CommonName: "www.example.com"}, []string{"www.example.com"}, -1},
+		{"DNS name", pkix.Name{}, []string{"www.example.org"}, CANotAuthorizedForThisName},
+		{"subject", pkix.Name{Organization: []string{"Evil"}}, []string{"www.example.com"}, CANotAuthorizedForThisName},
+	}
+	for _, tt := range tests {
+		template := &Certificate{
+			SerialNumber:           big.NewInt(2),
+			Subject:                tt.subject,
+			DNSNames:               tt.dns,
+			NotBefore:              time.Now().Add(-time.Hour),
+			NotAfter:               time.Now().Add(time.Hour),
+			EnforceNameConstraints: true,
+		}
+		_, err := CreateCertificate(rand.Reader, template, ca, &key.PublicKey, key)
+		if tt.reason == -1 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if e, ok := err.(CertificateInvalidError); !ok || e.Reason != tt.reason || e.Cert != ca {
+			t.Errorf("%s: got error %v, want reason %d for the CA", tt.name, err, tt.reason)
+		}
+
+		// Without the option, the certificate is created, and only
+		// rejected by Verify.
+		template.EnforceNameConstraints = false
+		if _, err := CreateCertificate(rand.Reader, template, ca, &key.PublicKey, key); err != nil {
+			t.Errorf("%s: without EnforceNameConstraints: %v", tt.name, err)
+		}
+	}
+}
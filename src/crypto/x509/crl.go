@@ -0,0 +1,336 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 5280, 5.2.5
+var oidExtensionIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+
+// RFC 5280, 5.2.5
+//
+// IssuingDistributionPoint ::= SEQUENCE {
+//      distributionPoint          [0] DistributionPointName OPTIONAL,
+//      onlyContainsUserCerts      [1] BOOLEAN DEFAULT FALSE,
+//      onlyContainsCACerts        [2] BOOLEAN DEFAULT FALSE,
+//      onlySomeReasons            [3] ReasonFlags OPTIONAL,
+//      indirectCRL                [4] BOOLEAN DEFAULT FALSE,
+//      onlyContainsAttributeCerts [5] BOOLEAN DEFAULT FALSE }
+type issuingDistributionPoint struct {
+	DistributionPoint          distributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool                  `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool                  `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString        `asn1:"optional,tag:3"`
+	IndirectCRL                bool                  `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool                  `asn1:"optional,tag:5"`
+}
+
+// CRLInvalidReason is the reason a CRL was rejected by VerifyCRL.
+type CRLInvalidReason int
+
+const (
+	// CRLIssuerMismatch results when the issuer of the CRL is not the
+	// subject of the issuing certificate, or not the issuer of the
+	// certificate being checked.
+	CRLIssuerMismatch CRLInvalidReason = iota
+	// CRLKeyIdentifierMismatch results when the authority key identifier
+	// of the CRL does not match the subject key identifier of the issuing
+	// certificate.
+	CRLKeyIdentifierMismatch
+	// CRLNotYetValid results when the thisUpdate time of the CRL is after
+	// the current time.
+	CRLNotYetValid
+	// CRLExpired results when the nextUpdate time of the CRL is before the
+	// current time.
+	CRLExpired
+	// CRLNumberMissing results when CRLVerifyOptions.LastNumber is set but
+	// the CRL has no CRL number.
+	CRLNumberMissing
+	// CRLNumberRegressed results when the CRL number is lower than
+	// CRLVerifyOptions.LastNumber, which indicates that an older CRL is
+	// being replayed.
+	CRLNumberRegressed
+	// CRLOutOfScope results when the issuing distribution point of the CRL
+	// shows that it does not cover the certificate being checked.
+	CRLOutOfScope
+)
+
+// CRLInvalidError results when a CRL cannot be used to check the revocation
+// status of a certificate.
+type CRLInvalidError struct {
+	Reason CRLInvalidReason
+	Detail string
+}
+
+func (e CRLInvalidError) Error() string {
+	switch e.Reason {
+	case CRLIssuerMismatch:
+		return "x509: CRL issuer does not match the issuing certificate: " + e.Detail
+	case CRLKeyIdentifierMismatch:
+		return "x509: CRL authority key identifier does not match the issuing certificate"
+	case CRLNotYetValid:
+		return "x509: CRL is not yet valid: " + e.Detail
+	case CRLExpired:
+		return "x509: CRL has expired: " + e.Detail
+	case CRLNumberMissing:
+		return "x509: CRL has no CRL number"
+	case CRLNumberRegressed:
+		return "x509: CRL number is lower than a previously seen one: " + e.Detail
+	case CRLOutOfScope:
+		return "x509: CRL does not cover the certificate: " + e.Detail
+	}
+	return "x509: unknown CRL error"
+}
+
+// CRLVerifyOptions contains parameters for VerifyCRL.
+type CRLVerifyOptions struct {
+	// CurrentTime is used to check thisUpdate and nextUpdate. If zero, the
+	// current time is used.
+	CurrentTime time.Time
+
+	// LastNumber, if not nil, is the highest CRL number previously seen
+	// from the same issuer and scope. A CRL with a lower number, or without
+	// a number, is rejected, so that a stale cached CRL cannot be replayed
+	// in place of a newer one.
+	LastNumber *big.Int
+}
+
+// VerifyCRL checks that crl was issued and signed by issuer, and, if cert is
+// not nil, that it covers cert, following RFC 5280, Section 6.3.3. It does not
+// look up cert in the list of revoked certificates.
+//
+// The issuer of crl must be the subject of issuer, and if both carry a key
+// identifier, the authority key identifier of crl must match the subject key
+// identifier of issuer. The scope set by the issuing distribution point
+// extension is enforced against cert: its user or CA certificate
+// restrictions, and its distribution point names, which must include a URI
+// from cert.CRLDistributionPoints. Indirect CRLs and CRLs covering only some
+// revocation reasons are treated as out of scope.
+//
+// VerifyCRL returns the CRL number of crl, if any, which the caller may store
+// and pass as CRLVerifyOptions.LastNumber when checking the next CRL.
+// Violations are reported with a CRLInvalidError.
+func VerifyCRL(crl *pkix.CertificateList, issuer, cert *Certificate, opts CRLVerifyOptions) (number *big.Int, err error) {
+	rawIssuer, err := crlRawIssuer(crl)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(rawIssuer, issuer.RawSubject) {
+		return nil, CRLInvalidError{CRLIssuerMismatch, fmt.Sprintf("CRL issued by %q, certificate subject is %q", crl.TBSCertList.Issuer.String(), issuer.Subject.String())}
+	}
+	if cert != nil && !bytes.Equal(rawIssuer, cert.RawIssuer) {
+		return nil, CRLInvalidError{CRLIssuerMismatch, fmt.Sprintf("CRL issued by %q, certificate issued by %q", crl.TBSCertList.Issuer.String(), cert.Issuer.String())}
+	}
+
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&KeyUsageCRLSign == 0 {
+		return nil, CertificateInvalidError{issuer, IncompatibleUsage, ""}
+	}
+	if err := issuer.CheckCRLSignature(crl); err != nil {
+		return nil, err
+	}
+
+	var idp *issuingDistributionPoint
+	for _, e := range crl.TBSCertList.Extensions {
+		switch {
+		case e.Id.Equal(oidExtensionAuthorityKeyId):
+			var a authKeyId
+			if rest, err := asn1.Unmarshal(e.Value, &a); err != nil {
+				return nil, err
+			} else if len(rest) != 0 {
+				return nil, errors.New("x509: trailing data after CRL authority key-id")
+			}
+			if len(a.Id) > 0 && len(issuer.SubjectKeyId) > 0 && !bytes.Equal(a.Id, issuer.SubjectKeyId) {
+				return nil, CRLInvalidError{Reason: CRLKeyIdentifierMismatch}
+			}
+
+		case e.Id.Equal(oidExtensionCRLNumber):
+			number = new(big.Int)
+			if rest, err := asn1.Unmarshal(e.Value, &number); err != nil {
+				return nil, err
+			} else if len(rest) != 0 {
+				return nil, errors.New("x509: trailing data after CRL number")
+			}
+
+		case e.Id.Equal(oidExtensionIssuingDistributionPoint):
+			idp = new(issuingDistributionPoint)
+			if rest, err := asn1.Unmarshal(e.Value, idp); err != nil {
+				return nil, err
+			} else if len(rest) != 0 {
+				return nil, errors.New("x509: trailing data after CRL issuing distribution point")
+			}
+
+		default:
+			if e.Critical {
+				return nil, UnhandledCriticalExtension{}
+			}
+		}
+	}
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if thisUpdate := crl.TBSCertList.ThisUpdate; now.Before(thisUpdate) {
+		return nil, CRLInvalidError{CRLNotYetValid, fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), thisUpdate.Format(time.RFC3339))}
+	}
+	if nextUpdate := crl.TBSCertList.NextUpdate; !nextUpdate.IsZero() && now.After(nextUpdate) {
+		return nil, CRLInvalidError{CRLExpired, fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), nextUpdate.Format(time.RFC3339))}
+	}
+
+	if opts.LastNumber != nil {
+		if number == nil {
+			return nil, CRLInvalidError{Reason: CRLNumberMissing}
+		}
+		if number.Cmp(opts.LastNumber) < 0 {
+			return nil, CRLInvalidError{CRLNumberRegressed, fmt.Sprintf("got %v, previously saw %v", number, opts.LastNumber)}
+		}
+	}
+
+	if cert != nil && idp != nil {
+		if err := idp.checkScope(cert); err != nil {
+			return nil, err
+		}
+	}
+
+	return number, nil
+}
+
+// checkScope checks that the CRL with the issuing distribution point idp
+// covers cert.
+func (idp *issuingDistributionPoint) checkScope(cert *Certificate) error {
+	isCA := cert.BasicConstraintsValid && cert.IsCA
+	switch {
+	case idp.IndirectCRL:
+		return CRLInvalidError{CRLOutOfScope, "indirect CRLs are not supported"}
+	case idp.OnlyContainsAttributeCerts:
+		return CRLInvalidError{CRLOutOfScope, "CRL only covers attribute certificates"}
+	case idp.OnlyContainsUserCerts && isCA:
+		return CRLInvalidError{CRLOutOfScope, "CRL only covers end-entity certificates"}
+	case idp.OnlyContainsCACerts && !isCA:
+		return CRLInvalidError{CRLOutOfScope, "CRL only covers CA certificates"}
+	case idp.OnlySomeReasons.BitLength > 0:
+		return CRLInvalidError{CRLOutOfScope, "CRL only covers some revocation reasons"}
+	case len(idp.DistributionPoint.RelativeName) > 0:
+		return CRLInvalidError{CRLOutOfScope, "relative distribution point names are not supported"}
+	}
+
+	if len(idp.DistributionPoint.FullName) == 0 {
+		return nil
+	}
+	var names []string
+	for _, name := range idp.DistributionPoint.FullName {
+		if name.Tag != nameTypeURI {
+			continue
+		}
+		names = append(names, string(name.Bytes))
+		for _, uri := range cert.CRLDistributionPoints {
+			if uri == string(name.Bytes) {
+				return nil
+			}
+		}
+	}
+	return CRLInvalidError{CRLOutOfScope, fmt.Sprintf("CRL distribution points %q do not include any of the certificate's %q", names, cert.CRLDistributionPoints)}
+}
+
+// crlRawIssuer returns the DER encoded issuer of crl, as it appears in the
+// signed portion.
+func crlRawIssuer(crl *pkix.CertificateList) ([]byte, error) {
+	input := cryptobyte.String(crl.TBSCertList.Raw)
+	var tbs, issuer cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1Element(&issuer, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed CRL")
+	}
+	return issuer, nil
+}
+
+// RevokedCertificateEntries returns the DER encoding of each entry of the
+// revokedCertificates list of crl, in order, as it appears in the signed
+// portion. Together with crl.TBSCertList.Raw and crl.SignatureValue, it lets
+// the entries of a CRL be countersigned or archived without re-encoding them.
+func RevokedCertificateEntries(crl *pkix.CertificateList) ([][]byte, error) {
+	raw := crl.TBSCertList.Raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = asn1.Marshal(crl.TBSCertList); err != nil {
+			return nil, err
+		}
+	}
+	input := cryptobyte.String(raw)
+	var tbs, revoked cryptobyte.String
+	var hasRevoked bool
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!skipASN1Time(&tbs) {
+		return nil, errors.New("x509: malformed CRL")
+	}
+	if tbs.PeekASN1Tag(cryptobyte_asn1.UTCTime) || tbs.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime) {
+		if !skipASN1Time(&tbs) {
+			return nil, errors.New("x509: malformed CRL")
+		}
+	}
+	if !tbs.ReadOptionalASN1(&revoked, &hasRevoked, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed CRL")
+	}
+
+	var entries [][]byte
+	for !revoked.Empty() {
+		var entry cryptobyte.String
+		if !revoked.ReadASN1Element(&entry, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed CRL entry")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// skipASN1Time skips a UTCTime or GeneralizedTime.
+func skipASN1Time(s *cryptobyte.String) bool {
+	if s.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime) {
+		return s.SkipASN1(cryptobyte_asn1.GeneralizedTime)
+	}
+	return s.SkipASN1(cryptobyte_asn1.UTCTime)
+}
+
+// RevokedSetHash returns a SHA-256 hash of the set of entries of crl. It
+// depends only on the entries, not on their order or on the other fields of
+// the CRL such as thisUpdate, nextUpdate, the CRL number or the signature, so
+// comparing the hashes of two fetches of a CRL tells whether the set of
+// revoked certificates changed.
+func RevokedSetHash(crl *pkix.CertificateList) ([sha256.Size]byte, error) {
+	entries, err := RevokedCertificateEntries(crl)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i], entries[j]) < 0
+	})
+	h := sha256.New()
+	for _, entry := range entries {
+		// DER elements are self-delimiting, so the concatenation is
+		// unambiguous.
+		h.Write(entry)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum, nil
+}
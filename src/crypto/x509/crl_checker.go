@@ -0,0 +1,144 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// CRLChecker checks certificates against the CRLs published at their CRL
+// distribution points. Its CheckRevocation method is meant to be used as
+// VerifyOptions.CheckRevocation, so that chains are checked with the issuer
+// that Verify chose for each certificate.
+//
+// A CRLChecker is safe for concurrent use. Its fields must not be modified
+// after the first check.
+type CRLChecker struct {
+	// Cache fetches the CRLs, and keeps them until their nextUpdate time.
+	Cache *RevocationCache
+
+	// SoftFail makes CheckChain and CheckRevocation consider good the
+	// certificates whose CRL can't be fetched or verified, instead of
+	// failing. Certificates listed in a verified CRL are still rejected.
+	SoftFail bool
+
+	now func() time.Time // for testing
+
+	mu sync.Mutex
+	// crls holds the last CRL parsed for each URL, and the DER it was
+	// parsed from, so that a CRL served from Cache is parsed only once.
+	crls map[string]parsedCRL
+	// numbers holds the highest CRL number verified for each URL, so that
+	// an older CRL can't be replayed.
+	numbers map[string]*big.Int
+}
+
+type parsedCRL struct {
+	der []byte
+	crl *pkix.CertificateList
+}
+
+var errNoCRLDistributionPoint = errors.New("x509: certificate has no CRL distribution point")
+
+// Status returns the revocation status of cert, issued by issuer, according
+// to the CRL at the first of cert.CRLDistributionPoints that can be fetched
+// and checked with VerifyCRL. If none can, it returns the error for the
+// first one.
+func (c *CRLChecker) Status(ctx context.Context, cert, issuer *Certificate) (RevocationStatus, error) {
+	if len(cert.CRLDistributionPoints) == 0 {
+		return RevocationStatus{}, errNoCRLDistributionPoint
+	}
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+	var firstErr error
+	for _, url := range cert.CRLDistributionPoints {
+		crl, err := c.fetch(ctx, url, cert, issuer, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("x509: checking CRL %s: %w", url, err)
+			}
+			continue
+		}
+		return CRLRevocationStatus(cert.SerialNumber, []*pkix.CertificateList{crl}, now)
+	}
+	return RevocationStatus{}, firstErr
+}
+
+// fetch returns the CRL at url, after checking that it covers cert and was
+// signed by issuer.
+func (c *CRLChecker) fetch(ctx context.Context, url string, cert, issuer *Certificate, now time.Time) (*pkix.CertificateList, error) {
+	der, err := c.Cache.FetchCRL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	parsed, ok := c.crls[url]
+	last := c.numbers[url]
+	c.mu.Unlock()
+	if !ok || len(parsed.der) != len(der) || len(der) == 0 || &parsed.der[0] != &der[0] {
+		crl, err := ParseDERCRL(der)
+		if err != nil {
+			return nil, err
+		}
+		parsed = parsedCRL{der, crl}
+	}
+
+	number, err := VerifyCRL(parsed.crl, issuer, cert, CRLVerifyOptions{CurrentTime: now, LastNumber: last})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.crls == nil {
+		c.crls = make(map[string]parsedCRL)
+		c.numbers = make(map[string]*big.Int)
+	}
+	c.crls[url] = parsed
+	if number != nil && (c.numbers[url] == nil || number.Cmp(c.numbers[url]) > 0) {
+		c.numbers[url] = number
+	}
+	return parsed.crl, nil
+}
+
+// CheckChain checks every certificate of chain but the last against the
+// CRLs of its distribution points, using the next certificate as its issuer.
+// Certificates without distribution points are not checked. A revoked or
+// held certificate is reported with a CertificateInvalidError with Reason
+// Revoked.
+func (c *CRLChecker) CheckChain(ctx context.Context, chain []*Certificate) error {
+	for i := 0; i+1 < len(chain); i++ {
+		cert := chain[i]
+		if len(cert.CRLDistributionPoints) == 0 {
+			continue
+		}
+		status, err := c.Status(ctx, cert, chain[i+1])
+		if err != nil {
+			if c.SoftFail {
+				continue
+			}
+			return err
+		}
+		if status.State != RevocationGood {
+			return CertificateInvalidError{cert, Revoked, fmt.Sprintf("%v at %s, reason %v", status.State, status.RevocationTime.Format(time.RFC3339), status.Reason)}
+		}
+	}
+	return nil
+}
+
+// CheckRevocation is CheckChain without a context, with the signature of
+// VerifyOptions.CheckRevocation.
+func (c *CRLChecker) CheckRevocation(chain []*Certificate) error {
+	return c.CheckChain(context.Background(), chain)
+}
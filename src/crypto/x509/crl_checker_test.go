@@ -0,0 +1,131 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCRLChecker(t *testing.T) {
+	now := time.Now()
+	create := func(template, parent *Certificate, pub, priv interface{}) *Certificate {
+		template.NotBefore, template.NotAfter = now.Add(-time.Hour), now.Add(time.Hour)
+		der, err := CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CRL CA"},
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	ca := create(caTemplate, caTemplate, caKey.Public(), caKey)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := create(&Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "leaf"},
+		DNSNames:              []string{"leaf.example"},
+		CRLDistributionPoints: []string{"http://crl.example/ca.crl"},
+	}, ca, leafKey.Public(), caKey)
+
+	crl := func(revoked ...*big.Int) []byte {
+		list := &RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: now.Add(-time.Minute),
+			NextUpdate: now.Add(time.Hour),
+		}
+		for _, serial := range revoked {
+			list.RevokedCertificates = append(list.RevokedCertificates, pkix.RevokedCertificate{
+				SerialNumber:   serial,
+				RevocationTime: now.Add(-time.Minute),
+			})
+		}
+		der, err := CreateRevocationList(rand.Reader, list, ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	checker := func(body []byte, err error) *CRLChecker {
+		return &CRLChecker{Cache: &RevocationCache{Fetcher: &fakeRevocationFetcher{body: body, err: err}}}
+	}
+	roots := NewCertPool()
+	roots.AddCert(ca)
+
+	good := checker(crl(big.NewInt(7)), nil)
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, CheckRevocation: good.CheckRevocation}); err != nil {
+		t.Errorf("certificate not listed: %v", err)
+	}
+	// The cached CRL is not parsed again.
+	parsed := good.crls["http://crl.example/ca.crl"].crl
+	if err := good.CheckChain(context.Background(), []*Certificate{leaf, ca}); err != nil {
+		t.Error(err)
+	}
+	if good.crls["http://crl.example/ca.crl"].crl != parsed {
+		t.Error("the cached CRL was parsed again")
+	}
+
+	revoked := checker(crl(big.NewInt(42)), nil)
+	_, err = leaf.Verify(VerifyOptions{Roots: roots, CheckRevocation: revoked.CheckRevocation})
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != Revoked || e.Cert != leaf {
+		t.Errorf("revoked certificate: got %v", err)
+	}
+	if status, err := revoked.Status(context.Background(), leaf, ca); err != nil || status.State != RevocationRevoked {
+		t.Errorf("Status = %v, %v", status, err)
+	}
+
+	unavailable := checker(nil, errors.New("connection refused"))
+	if err := unavailable.CheckChain(context.Background(), []*Certificate{leaf, ca}); err == nil {
+		t.Error("unavailable CRL: got no error")
+	}
+	unavailable.SoftFail = true
+	if err := unavailable.CheckChain(context.Background(), []*Certificate{leaf, ca}); err != nil {
+		t.Errorf("unavailable CRL with SoftFail: %v", err)
+	}
+
+	// A CRL signed by another key is rejected.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := CreateRevocationList(rand.Reader, &RevocationList{
+		Number:     big.NewInt(2),
+		ThisUpdate: now.Add(-time.Minute),
+		NextUpdate: now.Add(time.Hour),
+	}, ca, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checker(forged, nil).CheckChain(context.Background(), []*Certificate{leaf, ca}); err == nil {
+		t.Error("forged CRL: got no error")
+	}
+
+	if _, err := good.Status(context.Background(), ca, ca); err == nil {
+		t.Error("Status succeeded without distribution points")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+var (
+	oidExtensionReasonCode        = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+)
+
+// CRLReason is the reason a certificate was listed in a CRL, as in the
+// reasonCode CRL entry extension of RFC 5280, Section 5.3.1.
+type CRLReason int
+
+const (
+	CRLReasonUnspecified          CRLReason = 0
+	CRLReasonKeyCompromise        CRLReason = 1
+	CRLReasonCACompromise         CRLReason = 2
+	CRLReasonAffiliationChanged   CRLReason = 3
+	CRLReasonSuperseded           CRLReason = 4
+	CRLReasonCessationOfOperation CRLReason = 5
+	// CRLReasonCertificateHold suspends the certificate. It can be released
+	// later, which makes the certificate valid again.
+	CRLReasonCertificateHold CRLReason = 6
+	// CRLReasonRemoveFromCRL, used in delta CRLs, releases a certificate
+	// on hold, or removes the entry of an expired certificate.
+	CRLReasonRemoveFromCRL      CRLReason = 8
+	CRLReasonPrivilegeWithdrawn CRLReason = 9
+	CRLReasonAACompromise       CRLReason = 10
+)
+
+var crlReasonNames = map[CRLReason]string{
+	CRLReasonUnspecified:          "unspecified",
+	CRLReasonKeyCompromise:        "keyCompromise",
+	CRLReasonCACompromise:         "cACompromise",
+	CRLReasonAffiliationChanged:   "affiliationChanged",
+	CRLReasonSuperseded:           "superseded",
+	CRLReasonCessationOfOperation: "cessationOfOperation",
+	CRLReasonCertificateHold:      "certificateHold",
+	CRLReasonRemoveFromCRL:        "removeFromCRL",
+	CRLReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	CRLReasonAACompromise:         "aACompromise",
+}
+
+func (r CRLReason) String() string {
+	if name, ok := crlReasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("CRLReason(%d)", int(r))
+}
+
+// Extension returns the reasonCode extension for r, to be added to the
+// Extensions of a pkix.RevokedCertificate.
+func (r CRLReason) Extension() pkix.Extension {
+	value, err := asn1.Marshal(asn1.Enumerated(r))
+	if err != nil {
+		panic(err) // marshaling an integer can't fail
+	}
+	return pkix.Extension{Id: oidExtensionReasonCode, Value: value}
+}
+
+// RevokedCertificateReason returns the reason of the CRL entry rc. As in
+// RFC 5280, an entry without a reasonCode extension has the reason
+// CRLReasonUnspecified.
+func RevokedCertificateReason(rc *pkix.RevokedCertificate) (CRLReason, error) {
+	for _, e := range rc.Extensions {
+		if !e.Id.Equal(oidExtensionReasonCode) {
+			continue
+		}
+		var reason asn1.Enumerated
+		if rest, err := asn1.Unmarshal(e.Value, &reason); err != nil {
+			return 0, err
+		} else if len(rest) != 0 {
+			return 0, errors.New("x509: trailing data after CRL reason code")
+		}
+		if _, ok := crlReasonNames[CRLReason(reason)]; !ok {
+			return 0, fmt.Errorf("x509: unknown CRL reason code %d", reason)
+		}
+		return CRLReason(reason), nil
+	}
+	return CRLReasonUnspecified, nil
+}
+
+// RevocationState is the effective revocation state of a certificate.
+type RevocationState int
+
+const (
+	// RevocationGood means the certificate is not revoked, because it was
+	// never listed, or because it was released from hold.
+	RevocationGood RevocationState = iota
+	// RevocationOnHold means the certificate is suspended by a
+	// certificateHold entry, and may be released later.
+	RevocationOnHold
+	// RevocationRevoked means the certificate is permanently revoked.
+	RevocationRevoked
+)
+
+func (s RevocationState) String() string {
+	switch s {
+	case RevocationGood:
+		return "good"
+	case RevocationOnHold:
+		return "on hold"
+	case RevocationRevoked:
+		return "revoked"
+	}
+	return fmt.Sprintf("RevocationState(%d)", int(s))
+}
+
+// RevocationStatus is the result of CRLRevocationStatus.
+type RevocationStatus struct {
+	State RevocationState
+	// Reason and RevocationTime are those of the CRL entry that put the
+	// certificate in State. They are zero if the certificate was never
+	// listed.
+	Reason         CRLReason
+	RevocationTime time.Time
+}
+
+// CRLRevocationStatus returns the effective revocation status of the
+// certificate with the given serial number over crls, a sequence of CRLs,
+// full or delta, from the same issuer and scope. The CRLs must have been
+// checked already, for example with VerifyCRL. They are processed in the
+// order of their thisUpdate times.
+//
+// A certificateHold entry puts the certificate on hold, and a later CRL
+// releases it, either by no longer listing it or by listing it with
+// removeFromCRL, RFC 5280, Section 5.3.1. An entry with any other reason
+// revokes the certificate permanently, including one that was on hold: later
+// CRLs can't make it good again. A removeFromCRL entry for a certificate that
+// is not on hold is ignored.
+//
+// Entries with a revocation time after now are ignored, as are the CRLs
+// issued after now. If now is zero, the current time is used.
+func CRLRevocationStatus(serial *big.Int, crls []*pkix.CertificateList, now time.Time) (RevocationStatus, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	sorted := make([]*pkix.CertificateList, len(crls))
+	copy(sorted, crls)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TBSCertList.ThisUpdate.Before(sorted[j].TBSCertList.ThisUpdate)
+	})
+
+	var status RevocationStatus
+	for _, crl := range sorted {
+		if crl.TBSCertList.ThisUpdate.After(now) {
+			break
+		}
+		var entry *pkix.RevokedCertificate
+		for i := range crl.TBSCertList.RevokedCertificates {
+			rc := &crl.TBSCertList.RevokedCertificates[i]
+			if rc.SerialNumber.Cmp(serial) == 0 && !rc.RevocationTime.After(now) {
+				entry = rc
+				break
+			}
+		}
+		if entry == nil {
+			if status.State == RevocationOnHold && !isDeltaCRL(crl) {
+				status = RevocationStatus{}
+			}
+			continue
+		}
+		reason, err := RevokedCertificateReason(entry)
+		if err != nil {
+			return RevocationStatus{}, err
+		}
+		switch reason {
+		case CRLReasonRemoveFromCRL:
+			if status.State == RevocationOnHold {
+				status = RevocationStatus{}
+			}
+		case CRLReasonCertificateHold:
+			if status.State != RevocationRevoked {
+				status = RevocationStatus{RevocationOnHold, reason, entry.RevocationTime}
+			}
+		default:
+			if status.State != RevocationRevoked {
+				status = RevocationStatus{RevocationRevoked, reason, entry.RevocationTime}
+			}
+		}
+	}
+	return status, nil
+}
+
+// isDeltaCRL reports whether crl has the delta CRL indicator extension, RFC
+// 5280, Section 5.2.4. A delta CRL only lists changes, so not being listed in
+// it says nothing about a certificate.
+func isDeltaCRL(crl *pkix.CertificateList) bool {
+	for _, e := range crl.TBSCertList.Extensions {
+		if e.Id.Equal(oidExtensionDeltaCRLIndicator) {
+			return true
+		}
+	}
+	return false
+}
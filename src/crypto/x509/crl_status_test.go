@@ -0,0 +1,91 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCRLRevocationStatus(t *testing.T) {
+	serial := big.NewInt(42)
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return start.Add(time.Duration(n) * 24 * time.Hour) }
+	entry := func(revoked int, reason CRLReason) pkix.RevokedCertificate {
+		return pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: day(revoked),
+			Extensions:     []pkix.Extension{reason.Extension()},
+		}
+	}
+	crl := func(issued int, entries ...pkix.RevokedCertificate) *pkix.CertificateList {
+		return &pkix.CertificateList{TBSCertList: pkix.TBSCertificateList{
+			ThisUpdate:          day(issued),
+			RevokedCertificates: entries,
+		}}
+	}
+	delta := func(issued int, entries ...pkix.RevokedCertificate) *pkix.CertificateList {
+		c := crl(issued, entries...)
+		c.TBSCertList.Extensions = []pkix.Extension{{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: []byte{2, 1, 1}}}
+		return c
+	}
+	other := pkix.RevokedCertificate{SerialNumber: big.NewInt(1), RevocationTime: day(0)}
+
+	tests := []struct {
+		name  string
+		crls  []*pkix.CertificateList
+		now   int
+		state RevocationState
+	}{
+		{"never listed", []*pkix.CertificateList{crl(0, other), crl(1)}, 2, RevocationGood},
+		{"on hold", []*pkix.CertificateList{crl(0), crl(1, entry(1, CRLReasonCertificateHold))}, 2, RevocationOnHold},
+		{"released by omission", []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), crl(2, other)}, 3, RevocationGood},
+		{"released out of order", []*pkix.CertificateList{crl(2), crl(1, entry(1, CRLReasonCertificateHold))}, 3, RevocationGood},
+		{"before release", []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), crl(2)}, 1, RevocationOnHold},
+		{"released by delta", []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), delta(2, entry(2, CRLReasonRemoveFromCRL))}, 3, RevocationGood},
+		{"delta without entry", []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), delta(2, other)}, 3, RevocationOnHold},
+		{"hold then revoked", []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), crl(2, entry(2, CRLReasonKeyCompromise)), crl(3)}, 4, RevocationRevoked},
+		{"revoked then hold", []*pkix.CertificateList{crl(1, entry(1, CRLReasonSuperseded)), crl(2, entry(2, CRLReasonCertificateHold))}, 3, RevocationRevoked},
+		{"revoked then removed", []*pkix.CertificateList{crl(1, entry(1, CRLReasonSuperseded)), delta(2, entry(2, CRLReasonRemoveFromCRL))}, 3, RevocationRevoked},
+		{"future revocation time", []*pkix.CertificateList{crl(1, entry(5, CRLReasonKeyCompromise))}, 2, RevocationGood},
+	}
+	for _, tt := range tests {
+		status, err := CRLRevocationStatus(serial, tt.crls, day(tt.now))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if status.State != tt.state {
+			t.Errorf("%s: got %v, want %v", tt.name, status.State, tt.state)
+		}
+	}
+
+	status, err := CRLRevocationStatus(serial, []*pkix.CertificateList{crl(1, entry(1, CRLReasonCertificateHold)), crl(2, entry(2, CRLReasonKeyCompromise))}, day(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RevocationStatus{RevocationRevoked, CRLReasonKeyCompromise, day(2)}); status != want {
+		t.Errorf("got %+v, want %+v", status, want)
+	}
+}
+
+func TestRevokedCertificateReason(t *testing.T) {
+	for _, reason := range []CRLReason{CRLReasonKeyCompromise, CRLReasonCertificateHold, CRLReasonRemoveFromCRL} {
+		rc := &pkix.RevokedCertificate{Extensions: []pkix.Extension{reason.Extension()}}
+		if got, err := RevokedCertificateReason(rc); err != nil || got != reason {
+			t.Errorf("got %v, %v; want %v", got, err, reason)
+		}
+	}
+	if got, err := RevokedCertificateReason(&pkix.RevokedCertificate{}); err != nil || got != CRLReasonUnspecified {
+		t.Errorf("entry without reason: got %v, %v", got, err)
+	}
+	value, _ := asn1.Marshal(asn1.Enumerated(7))
+	if _, err := RevokedCertificateReason(&pkix.RevokedCertificate{Extensions: []pkix.Extension{{Id: oidExtensionReasonCode, Value: value}}}); err == nil {
+		t.Error("unassigned reason code 7 was accepted")
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifyCRL(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(template, parent *Certificate) *Certificate {
+		template.NotBefore = time.Unix(1000, 0)
+		template.NotAfter = time.Unix(100000, 0)
+		der, err := CreateCertificate(rand.Reader, template, parent, caKey.Public(), caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	caTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CA"},
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	ca := create(caTemplate, caTemplate)
+	rekeyedTemplate := *caTemplate
+	rekeyedTemplate.SubjectKeyId = []byte{5, 6, 7, 8}
+	rekeyed := create(&rekeyedTemplate, &rekeyedTemplate)
+	leaf := create(&Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Leaf"},
+		CRLDistributionPoints: []string{"http://crl.example/ca.crl"},
+	}, ca)
+	other := create(&Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Other"},
+	}, leaf)
+
+	idp := func(v issuingDistributionPoint) pkix.Extension {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkix.Extension{Id: oidExtensionIssuingDistributionPoint, Critical: true, Value: der}
+	}
+	uriName := func(uri string) []asn1.RawValue {
+		return []asn1.RawValue{{Tag: nameTypeURI, Class: asn1.ClassContextSpecific, Bytes: []byte(uri)}}
+	}
+
+	tests := []struct {
+		name       string
+		number     int64
+		extensions []pkix.Extension
+		issuer     *Certificate
+		cert       *Certificate
+		lastNumber *big.Int
+		now        int64
+		reason     CRLInvalidReason // or -1 if the CRL is valid
+	}{
+		{name: "valid", number: 5, cert: leaf, reason: -1},
+		{name: "no certificate", number: 5, reason: -1},
+		{name: "same number", number: 5, cert: leaf, lastNumber: big.NewInt(5), reason: -1},
+		{name: "replayed", number: 4, cert: leaf, lastNumber: big.NewInt(5), reason: CRLNumberRegressed},
+		{name: "other issuer", number: 5, cert: other, reason: CRLIssuerMismatch},
+		{name: "key identifier", number: 5, cert: leaf, issuer: rekeyed, reason: CRLKeyIdentifierMismatch},
+		{name: "expired", number: 5, cert: leaf, now: 5000, reason: CRLExpired},
+		{name: "not yet valid", number: 5, cert: leaf, now: 1500, reason: CRLNotYetValid},
+		{
+			name:       "matching distribution point",
+			number:     5,
+			cert:       leaf,
+			extensions: []pkix.Extension{idp(issuingDistributionPoint{DistributionPoint: distributionPointName{FullName: uriName("http://crl.example/ca.crl")}})},
+			reason:     -1,
+		},
+		{
+			name:       "other distribution point",
+			number:     5,
+			cert:       leaf,
+			extensions: []pkix.Extension{idp(issuingDistributionPoint{DistributionPoint: distributionPointName{FullName: uriName("http://crl.example/shard2.crl")}})},
+			reason:     CRLOutOfScope,
+		},
+		{
+			name:       "only user certificates",
+			number:     5,
+			cert:       leaf,
+			extensions: []pkix.Extension{idp(issuingDistributionPoint{OnlyContainsUserCerts: true})},
+			reason:     -1,
+		},
+		{
+			name:       "only CA certificates",
+			number:     5,
+			cert:       leaf,
+			extensions: []pkix.Extension{idp(issuingDistributionPoint{OnlyContainsCACerts: true})},
+			reason:     CRLOutOfScope,
+		},
+		{
+			name:       "indirect",
+			number:     5,
+			cert:       leaf,
+			extensions: []pkix.Extension{idp(issuingDistributionPoint{IndirectCRL: true})},
+			reason:     CRLOutOfScope,
+		},
+	}
+	for _, test := range tests {
+		issuer := test.issuer
+		if issuer == nil {
+			issuer = ca
+		}
+		der, err := CreateRevocationList(rand.Reader, &RevocationList{
+			Number:          big.NewInt(test.number),
+			ThisUpdate:      time.Unix(2000, 0),
+			NextUpdate:      time.Unix(4000, 0),
+			ExtraExtensions: test.extensions,
+		}, issuer, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := ParseDERCRL(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.now == 0 {
+			test.now = 3000
+		}
+		number, err := VerifyCRL(crl, ca, test.cert, CRLVerifyOptions{
+			CurrentTime: time.Unix(test.now, 0),
+			LastNumber:  test.lastNumber,
+		})
+		if test.reason == -1 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			} else if number == nil || number.Int64() != test.number {
+				t.Errorf("%s: got CRL number %v, want %d", test.name, number, test.number)
+			}
+			continue
+		}
+		if invalid, ok := err.(CRLInvalidError); !ok || invalid.Reason != test.reason {
+			t.Errorf("%s: got error %v, want reason %d", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestRevokedSetHash(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CA"},
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Unix(100000, 0),
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := func(serial int64, reason CRLReason) pkix.RevokedCertificate {
+		return pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Unix(2000+serial, 0).UTC(),
+			Extensions:     []pkix.Extension{reason.Extension()},
+		}
+	}
+	newCRL := func(number int64, entries ...pkix.RevokedCertificate) *pkix.CertificateList {
+		der, err := CreateRevocationList(rand.Reader, &RevocationList{
+			RevokedCertificates: entries,
+			Number:              big.NewInt(number),
+			ThisUpdate:          time.Unix(3000+number, 0),
+			NextUpdate:          time.Unix(9000, 0),
+		}, ca, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := ParseDERCRL(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+	hash := func(crl *pkix.CertificateList) [32]byte {
+		sum, err := RevokedSetHash(crl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	crl := newCRL(1, entry(10, CRLReasonKeyCompromise), entry(11, CRLReasonCertificateHold))
+	entries, err := RevokedCertificateEntries(crl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for i, raw := range entries {
+		want, err := asn1.Marshal(crl.TBSCertList.RevokedCertificates[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, want) {
+			t.Errorf("entry %d: got %x, want %x", i, raw, want)
+		}
+	}
+	if err := ca.CheckSignature(ca.SignatureAlgorithm, crl.TBSCertList.Raw, crl.SignatureValue.RightAlign()); err != nil {
+		t.Errorf("raw TBSCertList and signature don't verify: %v", err)
+	}
+
+	if entries, err := RevokedCertificateEntries(newCRL(1)); err != nil || len(entries) != 0 {
+		t.Errorf("empty CRL: got %d entries, %v", len(entries), err)
+	}
+	reordered := newCRL(2, entry(11, CRLReasonCertificateHold), entry(10, CRLReasonKeyCompromise))
+	if hash(crl) != hash(reordered) {
+		t.Error("hash changed with the order of the entries and the CRL number")
+	}
+	changed := newCRL(2, entry(10, CRLReasonKeyCompromise), entry(11, CRLReasonKeyCompromise))
+	if hash(crl) == hash(changed) {
+		t.Error("hash did not change with the reason of an entry")
+	}
+	added := newCRL(2, entry(10, CRLReasonKeyCompromise), entry(11, CRLReasonCertificateHold), entry(12, CRLReasonSuperseded))
+	if hash(crl) == hash(added) {
+		t.Error("hash did not change with a new entry")
+	}
+
+	// Lists built in memory, without a raw encoding, are marshaled.
+	unparsed := &pkix.CertificateList{TBSCertList: pkix.TBSCertificateList{
+		Signature:           crl.TBSCertList.Signature,
+		Issuer:              crl.TBSCertList.Issuer,
+		ThisUpdate:          time.Unix(5000, 0),
+		RevokedCertificates: reordered.TBSCertList.RevokedCertificates,
+	}}
+	if hash(crl) != hash(unparsed) {
+		t.Error("hash of an unparsed CRL differs")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// oidExtensionSCTList is the embedded SCT list extension of RFC 6962,
+// Section 3.3.
+var oidExtensionSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// A CTRequirement requires the leaves of some chains to come with Signed
+// Certificate Timestamps (SCTs) from Certificate Transparency logs, RFC 6962.
+type CTRequirement struct {
+	// Roots, if not nil, restricts the requirement to chains whose last
+	// certificate is in Roots, for example to enforce CT for public roots
+	// but not for internal ones.
+	Roots *CertPool
+
+	// After, if not zero, restricts the requirement to leaves whose
+	// NotBefore is after it, so that certificates issued before CT was
+	// enforced keep working, as in the CT policies of browsers.
+	After time.Time
+
+	// MinSCTs is the number of SCTs from distinct logs that the leaf must
+	// have. If it is zero, one SCT is required.
+	MinSCTs int
+}
+
+// applies reports whether r applies to chain.
+func (r *CTRequirement) applies(chain []*Certificate) bool {
+	if r.Roots != nil && !r.Roots.contains(chain[len(chain)-1]) {
+		return false
+	}
+	return r.After.IsZero() || chain[0].NotBefore.After(r.After)
+}
+
+// checkChainSCTs checks that chain meets opts.CTRequirements.
+func (opts *VerifyOptions) checkChainSCTs(chain []*Certificate) error {
+	count := -1
+	for i := range opts.CTRequirements {
+		r := &opts.CTRequirements[i]
+		if !r.applies(chain) {
+			continue
+		}
+		if count < 0 {
+			count = countSCTLogs(chain[0], opts.SCTs)
+		}
+		min := r.MinSCTs
+		if min == 0 {
+			min = 1
+		}
+		if count < min {
+			return CertificateInvalidError{chain[0], MissingSCTs, fmt.Sprintf("SCTs from %d logs, %d required", count, min)}
+		}
+	}
+	return nil
+}
+
+// countSCTLogs returns the number of distinct logs that issued the version 1
+// SCTs embedded in c or listed in extra. SCTs that are malformed or of other
+// versions are ignored. Signatures are not checked.
+func countSCTLogs(c *Certificate, extra [][]byte) int {
+	logs := make(map[string]bool)
+	add := func(sct []byte) {
+		// struct {
+		//     Version sct_version;  // v1(0)
+		//     LogID id;             // opaque key_id[32]
+		//     ...
+		// } SignedCertificateTimestamp;
+		if len(sct) > 33 && sct[0] == 0 {
+			logs[string(sct[1:33])] = true
+		}
+	}
+	for _, sct := range extra {
+		add(sct)
+	}
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(oidExtensionSCTList) {
+			continue
+		}
+		var list []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(rest) != 0 {
+			break
+		}
+		// opaque SerializedSCT<1..2^16-1>;
+		// struct {
+		//     SerializedSCT sct_list <1..2^16-1>;
+		// } SignedCertificateTimestampList;
+		input := cryptobyte.String(list)
+		var scts cryptobyte.String
+		if !input.ReadUint16LengthPrefixed(&scts) || !input.Empty() {
+			break
+		}
+		for !scts.Empty() {
+			var sct cryptobyte.String
+			if !scts.ReadUint16LengthPrefixed(&sct) {
+				break
+			}
+			add(sct)
+		}
+	}
+	return len(logs)
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testSCT returns a v1 SCT from the log whose ID is 32 bytes of id, with
+// placeholder contents after the log ID.
+func testSCT(id byte) []byte {
+	return append(append([]byte{0}, bytes.Repeat([]byte{id}, 32)...), 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func sctListExtension(t *testing.T, scts ...[]byte) pkix.Extension {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sct)
+			})
+		}
+	})
+	value, err := asn1.Marshal(b.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidExtensionSCTList, Value: value}
+}
+
+func TestCTRequirements(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	issued := time.Now().Add(-time.Hour)
+	newLeaf := func(exts ...pkix.Extension) *Certificate {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &Certificate{
+			SerialNumber:    big.NewInt(1),
+			Subject:         pkix.Name{CommonName: "leaf"},
+			NotBefore:       issued,
+			NotAfter:        issued.Add(24 * time.Hour),
+			ExtKeyUsage:     []ExtKeyUsage{ExtKeyUsageServerAuth},
+			ExtraExtensions: exts,
+		}
+		der, err := CreateCertificate(rand.Reader, template, root, &priv.PublicKey, rootKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	plain := newLeaf()
+	logged := newLeaf(sctListExtension(t, testSCT(1), testSCT(2), testSCT(2)))
+
+	before, after := issued.Add(-time.Minute), issued.Add(time.Minute)
+	tests := []struct {
+		name string
+		leaf *Certificate
+		reqs []CTRequirement
+		scts [][]byte
+		ok   bool
+	}{
+		{"no requirement", plain, nil, nil, true},
+		{"not logged", plain, []CTRequirement{{}}, nil, false},
+		{"issued before the policy date", plain, []CTRequirement{{After: after}}, nil, true},
+		{"issued after the policy date", plain, []CTRequirement{{After: before}}, nil, false},
+		{"other roots", plain, []CTRequirement{{Roots: NewCertPool()}}, nil, true},
+		{"listed root", plain, []CTRequirement{{Roots: roots}}, nil, false},
+		{"TLS SCT", plain, []CTRequirement{{}}, [][]byte{testSCT(1)}, true},
+		{"unknown version", plain, []CTRequirement{{}}, [][]byte{append([]byte{1}, testSCT(1)[1:]...)}, false},
+		{"embedded SCTs", logged, []CTRequirement{{MinSCTs: 2}}, nil, true},
+		{"SCTs from the same log", logged, []CTRequirement{{MinSCTs: 3}}, [][]byte{testSCT(2)}, false},
+		{"embedded and TLS SCTs", logged, []CTRequirement{{MinSCTs: 3}}, [][]byte{testSCT(3)}, true},
+		{"all requirements", logged, []CTRequirement{{MinSCTs: 2}, {After: before, MinSCTs: 3}}, nil, false},
+	}
+	for _, tt := range tests {
+		_, err := tt.leaf.Verify(VerifyOptions{Roots: roots, CTRequirements: tt.reqs, SCTs: tt.scts})
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok {
+			if e, ok := err.(CertificateInvalidError); !ok || e.Reason != MissingSCTs {
+				t.Errorf("%s: got error %v, want MissingSCTs", tt.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+)
+
+// TLSAUsage is the certificate usage field of a TLSA record, RFC 6698,
+// Section 2.1.1. The names follow RFC 7218.
+type TLSAUsage uint8
+
+const (
+	// TLSAUsagePKIXTA requires a chain valid for VerifyOptions.Roots that
+	// includes a matching CA certificate.
+	TLSAUsagePKIXTA TLSAUsage = 0
+	// TLSAUsagePKIXEE requires a chain valid for VerifyOptions.Roots whose
+	// leaf matches.
+	TLSAUsagePKIXEE TLSAUsage = 1
+	// TLSAUsageDANETA makes a matching certificate of the presented chain
+	// the only trust anchor.
+	TLSAUsageDANETA TLSAUsage = 2
+	// TLSAUsageDANEEE accepts a matching leaf, without further checks.
+	TLSAUsageDANEEE TLSAUsage = 3
+)
+
+// TLSASelector is the selector field of a TLSA record, RFC 6698,
+// Section 2.1.2.
+type TLSASelector uint8
+
+const (
+	TLSASelectorCert TLSASelector = 0 // the DER encoded certificate
+	TLSASelectorSPKI TLSASelector = 1 // its DER encoded SubjectPublicKeyInfo
+)
+
+// TLSAMatchingType is the matching type field of a TLSA record, RFC 6698,
+// Section 2.1.3.
+type TLSAMatchingType uint8
+
+const (
+	TLSAMatchingFull    TLSAMatchingType = 0 // the selected content itself
+	TLSAMatchingSHA2256 TLSAMatchingType = 1 // its SHA-256 hash
+	TLSAMatchingSHA2512 TLSAMatchingType = 2 // its SHA-512 hash
+)
+
+// A TLSARecord is a DNS TLSA resource record, which associates a certificate
+// or public key with a TLS server, RFC 6698.
+type TLSARecord struct {
+	Usage        TLSAUsage
+	Selector     TLSASelector
+	MatchingType TLSAMatchingType
+	// Data is the certificate association data.
+	Data []byte
+}
+
+// TLSAData returns the certificate association data of cert for the given
+// selector and matching type, as published in a TLSA record.
+func TLSAData(cert *Certificate, selector TLSASelector, matchingType TLSAMatchingType) ([]byte, error) {
+	var content []byte
+	switch selector {
+	case TLSASelectorCert:
+		content = cert.Raw
+	case TLSASelectorSPKI:
+		content = cert.RawSubjectPublicKeyInfo
+	default:
+		return nil, fmt.Errorf("x509: unknown TLSA selector %d", selector)
+	}
+	switch matchingType {
+	case TLSAMatchingFull:
+		return append([]byte(nil), content...), nil
+	case TLSAMatchingSHA2256:
+		h := sha256.Sum256(content)
+		return h[:], nil
+	case TLSAMatchingSHA2512:
+		h := sha512.Sum512(content)
+		return h[:], nil
+	}
+	return nil, fmt.Errorf("x509: unknown TLSA matching type %d", matchingType)
+}
+
+// usable reports whether r has known parameters. Records that are not
+// usable are ignored, per RFC 6698, Section 4.1.
+func (r *TLSARecord) usable() bool {
+	return r.Usage <= TLSAUsageDANEEE && r.Selector <= TLSASelectorSPKI && r.MatchingType <= TLSAMatchingSHA2512
+}
+
+// matches reports whether the association data of r matches cert.
+func (r *TLSARecord) matches(cert *Certificate) bool {
+	data, err := TLSAData(cert, r.Selector, r.MatchingType)
+	return err == nil && bytes.Equal(data, r.Data)
+}
+
+// VerifyDANE verifies the chain presented by a TLS server, starting with its
+// leaf, against the TLSA records published for the server, as specified by
+// RFC 6698 and updated by RFC 7671. It returns the chains accepted by at
+// least one record.
+//
+// For records with the PKIX-TA and PKIX-EE usages, the chain is verified
+// with opts, using the presented certificates as intermediates, as Verify
+// does. For DANE-TA records, it is verified the same way but with the
+// matching presented certificate as the only root. DANE-EE records accept a
+// matching leaf by themselves, ignoring its names and validity period, per
+// RFC 7671, Section 5.1, and the resulting chain is just the leaf.
+//
+// Records with unknown parameters are ignored. If none are usable, VerifyDANE
+// returns an error, and the caller should fall back to its usual
+// verification, if any.
+func VerifyDANE(presented []*Certificate, records []TLSARecord, opts VerifyOptions) ([][]*Certificate, error) {
+	if len(presented) == 0 {
+		return nil, errors.New("x509: no certificates presented")
+	}
+	leaf := presented[0]
+
+	var (
+		chains   [][]*Certificate
+		firstErr error
+		usable   bool
+
+		pkixDone   bool
+		pkixChains [][]*Certificate
+		pkixErr    error
+	)
+	add := func(chain []*Certificate) {
+		for _, seen := range chains {
+			if equalChains(seen, chain) {
+				return
+			}
+		}
+		chains = append(chains, chain)
+	}
+	pkix := func() [][]*Certificate {
+		if !pkixDone {
+			pkixChains, pkixErr = verifyPresentedChain(presented, opts)
+			if pkixErr != nil && firstErr == nil {
+				firstErr = pkixErr
+			}
+			pkixDone = true
+		}
+		return pkixChains
+	}
+
+	for i := range records {
+		r := &records[i]
+		if !r.usable() {
+			continue
+		}
+		usable = true
+
+		switch r.Usage {
+		case TLSAUsagePKIXTA:
+			for _, chain := range pkix() {
+				for _, ca := range chain[1:] {
+					if r.matches(ca) {
+						add(chain)
+						break
+					}
+				}
+			}
+
+		case TLSAUsagePKIXEE:
+			if r.matches(leaf) {
+				for _, chain := range pkix() {
+					add(chain)
+				}
+			}
+
+		case TLSAUsageDANETA:
+			for _, ta := range presented {
+				if !r.matches(ta) {
+					continue
+				}
+				taOpts := opts
+				taOpts.Roots = NewCertPool()
+				taOpts.Roots.AddCert(ta)
+				taChains, err := verifyPresentedChain(presented, taOpts)
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				for _, chain := range taChains {
+					add(chain)
+				}
+			}
+
+		case TLSAUsageDANEEE:
+			if r.matches(leaf) {
+				add([]*Certificate{leaf})
+			}
+		}
+	}
+
+	if len(chains) > 0 {
+		return chains, nil
+	}
+	if !usable {
+		return nil, errors.New("x509: no usable TLSA records")
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, errors.New("x509: no TLSA record matches the presented certificates")
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+func TestTLSAData(t *testing.T) {
+	cert, _, err := generateCert("Leaf", false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	for _, tt := range []struct {
+		selector     TLSASelector
+		matchingType TLSAMatchingType
+		want         []byte
+		size         int
+	}{
+		{TLSASelectorCert, TLSAMatchingFull, cert.Raw, len(cert.Raw)},
+		{TLSASelectorSPKI, TLSAMatchingFull, cert.RawSubjectPublicKeyInfo, len(cert.RawSubjectPublicKeyInfo)},
+		{TLSASelectorSPKI, TLSAMatchingSHA2256, spkiHash[:], 32},
+		{TLSASelectorCert, TLSAMatchingSHA2512, nil, 64},
+	} {
+		data, err := TLSAData(cert, tt.selector, tt.matchingType)
+		if err != nil {
+			t.Errorf("%d %d: %v", tt.selector, tt.matchingType, err)
+			continue
+		}
+		if len(data) != tt.size || tt.want != nil && !bytes.Equal(data, tt.want) {
+			t.Errorf("%d %d: got %x", tt.selector, tt.matchingType, data)
+		}
+	}
+	if _, err := TLSAData(cert, 2, TLSAMatchingFull); err == nil {
+		t.Error("unknown selector accepted")
+	}
+	if _, err := TLSAData(cert, TLSASelectorCert, 3); err == nil {
+		t.Error("unknown matching type accepted")
+	}
+}
+
+func TestVerifyDANE(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := generateCert("Other", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presented := []*Certificate{leaf, intermediate}
+
+	record := func(usage TLSAUsage, selector TLSASelector, matchingType TLSAMatchingType, cert *Certificate) TLSARecord {
+		data, err := TLSAData(cert, selector, matchingType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return TLSARecord{usage, selector, matchingType, data}
+	}
+
+	trusted := NewCertPool()
+	trusted.AddCert(root)
+	expired := leaf.NotAfter.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		records []TLSARecord
+		opts    VerifyOptions
+		length  int // of the accepted chain, or 0 if none
+	}{
+		{
+			name:    "DANE-EE",
+			records: []TLSARecord{record(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA2256, leaf)},
+			length:  1,
+		},
+		{
+			name:    "DANE-EE ignores expiry",
+			records: []TLSARecord{record(TLSAUsageDANEEE, TLSASelectorCert, TLSAMatchingFull, leaf)},
+			opts:    VerifyOptions{CurrentTime: expired},
+			length:  1,
+		},
+		{
+			name:    "DANE-TA",
+			records: []TLSARecord{record(TLSAUsageDANETA, TLSASelectorCert, TLSAMatchingSHA2512, intermediate)},
+			length:  2,
+		},
+		{
+			name:    "DANE-TA expired",
+			records: []TLSARecord{record(TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingSHA2256, intermediate)},
+			opts:    VerifyOptions{CurrentTime: expired},
+		},
+		{
+			name:    "PKIX-TA",
+			records: []TLSARecord{record(TLSAUsagePKIXTA, TLSASelectorSPKI, TLSAMatchingSHA2256, root)},
+			opts:    VerifyOptions{Roots: trusted},
+			length:  3,
+		},
+		{
+			name:    "PKIX-TA untrusted",
+			records: []TLSARecord{record(TLSAUsagePKIXTA, TLSASelectorSPKI, TLSAMatchingSHA2256, root)},
+			opts:    VerifyOptions{Roots: NewCertPool()},
+		},
+		{
+			name:    "PKIX-TA leaf",
+			records: []TLSARecord{record(TLSAUsagePKIXTA, TLSASelectorSPKI, TLSAMatchingSHA2256, leaf)},
+			opts:    VerifyOptions{Roots: trusted},
+		},
+		{
+			name:    "PKIX-EE",
+			records: []TLSARecord{record(TLSAUsagePKIXEE, TLSASelectorCert, TLSAMatchingSHA2256, leaf)},
+			opts:    VerifyOptions{Roots: trusted},
+			length:  3,
+		},
+		{
+			name: "no match",
+			records: []TLSARecord{
+				record(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA2256, other),
+				record(TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingSHA2256, other),
+			},
+		},
+		{
+			name: "unusable records are ignored",
+			records: []TLSARecord{
+				{Usage: 4, Data: leaf.Raw},
+				{Usage: TLSAUsageDANEEE, Selector: TLSASelectorCert, MatchingType: 255, Data: leaf.Raw},
+				record(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingFull, leaf),
+			},
+			length: 1,
+		},
+		{
+			name:    "no usable records",
+			records: []TLSARecord{{Usage: 4, Data: leaf.Raw}},
+		},
+	}
+	for _, tt := range tests {
+		chains, err := VerifyDANE(presented, tt.records, tt.opts)
+		if tt.length == 0 {
+			if err == nil {
+				t.Errorf("%s: verified %d chains", tt.name, len(chains))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(chains) != 1 || len(chains[0]) != tt.length || chains[0][0] != leaf {
+			t.Errorf("%s: got %d chains, the first of length %d", tt.name, len(chains), len(chains[0]))
+		}
+	}
+}
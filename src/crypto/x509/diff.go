@@ -0,0 +1,283 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// A Difference describes a certificate field whose value in an issued
+// certificate does not match the value requested by the template it was
+// created from.
+type Difference struct {
+	// Field is the name of the Certificate field that differs, such as
+	// "NotAfter" or "DNSNames". Differences in ExtraExtensions are reported
+	// as "ExtraExtensions[oid]", and likewise for OIDExtensions.
+	Field string
+	// Template and Issued hold the value of the field in the template and
+	// in the issued certificate respectively.
+	Template, Issued interface{}
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: template has %v, issued certificate has %v", d.Field, d.Template, d.Issued)
+}
+
+// DiffTemplate compares a parsed, issued certificate against the template that
+// was passed to CreateCertificate and reports every field that was not
+// reproduced faithfully. It is intended for post-issuance checks in CA
+// pipelines, where a parent CA or signing middleware might have truncated the
+// validity period or removed names.
+//
+// Only the template fields that CreateCertificate consumes are compared.
+// Fields that CreateCertificate is allowed to fill in are only compared if
+// they are set in the template: SubjectKeyId, SignatureAlgorithm and
+// PublicKey. AuthorityKeyId is never compared, since it is taken from the
+// parent. Validity bounds are compared at one second precision, which is the
+// resolution of the X.509 encoding. Lists are compared regardless of order.
+//
+// A nil result means that the issued certificate matches the template.
+func DiffTemplate(issued, template *Certificate) []Difference {
+	var d differ
+
+	if template.SerialNumber != nil && (issued.SerialNumber == nil || template.SerialNumber.Cmp(issued.SerialNumber) != 0) {
+		d.add("SerialNumber", template.SerialNumber, issued.SerialNumber)
+	}
+
+	if subject, err := subjectBytes(template); err == nil && !bytes.Equal(subject, issued.RawSubject) {
+		d.add("Subject", template.Subject.String(), issued.Subject.String())
+	}
+
+	if !template.NotBefore.Truncate(time.Second).Equal(issued.NotBefore.Truncate(time.Second)) {
+		d.add("NotBefore", template.NotBefore.UTC(), issued.NotBefore.UTC())
+	}
+	if !template.NotAfter.Truncate(time.Second).Equal(issued.NotAfter.Truncate(time.Second)) {
+		d.add("NotAfter", template.NotAfter.UTC(), issued.NotAfter.UTC())
+	}
+
+	if template.SignatureAlgorithm != UnknownSignatureAlgorithm && template.SignatureAlgorithm != issued.SignatureAlgorithm {
+		d.add("SignatureAlgorithm", template.SignatureAlgorithm, issued.SignatureAlgorithm)
+	}
+
+	if template.PublicKey != nil {
+		if spki, err := MarshalPKIXPublicKey(template.PublicKey); err == nil && !bytes.Equal(spki, issued.RawSubjectPublicKeyInfo) {
+			d.add("PublicKey", template.PublicKey, issued.PublicKey)
+		}
+	}
+
+	if template.KeyUsage != issued.KeyUsage {
+		d.add("KeyUsage", template.KeyUsage, issued.KeyUsage)
+	}
+
+	d.sets("ExtKeyUsage", template.ExtKeyUsage, issued.ExtKeyUsage, extKeyUsageStrings(template.ExtKeyUsage), extKeyUsageStrings(issued.ExtKeyUsage))
+	if len(template.UnknownExtKeyUsageOIDs) > 0 {
+		d.sets("UnknownExtKeyUsageOIDs", template.UnknownExtKeyUsageOIDs, issued.UnknownExtKeyUsageOIDs, largeOIDStrings(template.UnknownExtKeyUsageOIDs), largeOIDStrings(issued.UnknownExtKeyUsageOIDs))
+	} else {
+		d.sets("UnknownExtKeyUsage", template.UnknownExtKeyUsage, issued.UnknownExtKeyUsage, oidStrings(template.UnknownExtKeyUsage), oidStrings(issued.UnknownExtKeyUsage))
+	}
+
+	if template.BasicConstraintsValid != issued.BasicConstraintsValid {
+		d.add("BasicConstraintsValid", template.BasicConstraintsValid, issued.BasicConstraintsValid)
+	} else if template.BasicConstraintsValid {
+		if template.IsCA != issued.IsCA {
+			d.add("IsCA", template.IsCA, issued.IsCA)
+		}
+		if want, got := templateMaxPathLen(template), templateMaxPathLen(issued); want != got {
+			d.add("MaxPathLen", want, got)
+		}
+	}
+
+	if len(template.SubjectKeyId) > 0 && !bytes.Equal(template.SubjectKeyId, issued.SubjectKeyId) {
+		d.add("SubjectKeyId", template.SubjectKeyId, issued.SubjectKeyId)
+	}
+
+	d.sets("OCSPServer", template.OCSPServer, issued.OCSPServer, template.OCSPServer, issued.OCSPServer)
+	d.sets("IssuingCertificateURL", template.IssuingCertificateURL, issued.IssuingCertificateURL, template.IssuingCertificateURL, issued.IssuingCertificateURL)
+
+	d.sets("DNSNames", template.DNSNames, issued.DNSNames, template.DNSNames, issued.DNSNames)
+	d.sets("EmailAddresses", template.EmailAddresses, issued.EmailAddresses, template.EmailAddresses, issued.EmailAddresses)
+	d.sets("IPAddresses", template.IPAddresses, issued.IPAddresses, ipStrings(template.IPAddresses), ipStrings(issued.IPAddresses))
+	d.sets("URIs", template.URIs, issued.URIs, uriStrings(template.URIs), uriStrings(issued.URIs))
+	d.sets("DirectoryNames", template.DirectoryNames, issued.DirectoryNames, nameStrings(template.DirectoryNames), nameStrings(issued.DirectoryNames))
+
+	d.sets("PermittedDNSDomains", template.PermittedDNSDomains, issued.PermittedDNSDomains, template.PermittedDNSDomains, issued.PermittedDNSDomains)
+	d.sets("ExcludedDNSDomains", template.ExcludedDNSDomains, issued.ExcludedDNSDomains, template.ExcludedDNSDomains, issued.ExcludedDNSDomains)
+	d.sets("PermittedIPRanges", template.PermittedIPRanges, issued.PermittedIPRanges, ipNetStrings(template.PermittedIPRanges), ipNetStrings(issued.PermittedIPRanges))
+	d.sets("ExcludedIPRanges", template.ExcludedIPRanges, issued.ExcludedIPRanges, ipNetStrings(template.ExcludedIPRanges), ipNetStrings(issued.ExcludedIPRanges))
+	d.sets("PermittedEmailAddresses", template.PermittedEmailAddresses, issued.PermittedEmailAddresses, template.PermittedEmailAddresses, issued.PermittedEmailAddresses)
+	d.sets("ExcludedEmailAddresses", template.ExcludedEmailAddresses, issued.ExcludedEmailAddresses, template.ExcludedEmailAddresses, issued.ExcludedEmailAddresses)
+	d.sets("PermittedURIDomains", template.PermittedURIDomains, issued.PermittedURIDomains, template.PermittedURIDomains, issued.PermittedURIDomains)
+	d.sets("ExcludedURIDomains", template.ExcludedURIDomains, issued.ExcludedURIDomains, template.ExcludedURIDomains, issued.ExcludedURIDomains)
+	d.sets("PermittedDirectoryNames", template.PermittedDirectoryNames, issued.PermittedDirectoryNames, nameStrings(template.PermittedDirectoryNames), nameStrings(issued.PermittedDirectoryNames))
+	d.sets("ExcludedDirectoryNames", template.ExcludedDirectoryNames, issued.ExcludedDirectoryNames, nameStrings(template.ExcludedDirectoryNames), nameStrings(issued.ExcludedDirectoryNames))
+	if template.hasTemplateNameConstraints() && template.PermittedDNSDomainsCritical != issued.PermittedDNSDomainsCritical {
+		d.add("PermittedDNSDomainsCritical", template.PermittedDNSDomainsCritical, issued.PermittedDNSDomainsCritical)
+	}
+
+	d.sets("CRLDistributionPoints", template.CRLDistributionPoints, issued.CRLDistributionPoints, template.CRLDistributionPoints, issued.CRLDistributionPoints)
+	if len(template.Policies) > 0 {
+		d.sets("Policies", template.Policies, issued.Policies, largeOIDStrings(template.Policies), largeOIDStrings(issued.Policies))
+	} else {
+		d.sets("PolicyIdentifiers", template.PolicyIdentifiers, issued.PolicyIdentifiers, oidStrings(template.PolicyIdentifiers), oidStrings(issued.PolicyIdentifiers))
+	}
+	d.sets("PolicyMappings", template.PolicyMappings, issued.PolicyMappings, policyMappingStrings(template.PolicyMappings), policyMappingStrings(issued.PolicyMappings))
+
+	for _, want := range template.ExtraExtensions {
+		field := "ExtraExtensions[" + want.Id.String() + "]"
+		got, ok := findExtension(issued.Extensions, want.Id)
+		switch {
+		case !ok:
+			d.add(field, want, nil)
+		case got.Critical != want.Critical || !bytes.Equal(got.Value, want.Value):
+			d.add(field, want, got)
+		}
+	}
+	for _, want := range template.OIDExtensions {
+		field := "OIDExtensions[" + want.Id.String() + "]"
+		got, ok := findOIDExtension(issued.OIDExtensions, want.Id)
+		switch {
+		case !ok:
+			d.add(field, want, nil)
+		case got.Critical != want.Critical || !bytes.Equal(got.Value, want.Value):
+			d.add(field, want, got)
+		}
+	}
+
+	return d
+}
+
+// differ accumulates the result of DiffTemplate.
+type differ []Difference
+
+func (d *differ) add(field string, template, issued interface{}) {
+	*d = append(*d, Difference{Field: field, Template: template, Issued: issued})
+}
+
+// sets records a difference for field if the string forms of the template
+// and issued values are not equal as multisets. The original values are
+// recorded in the Difference.
+func (d *differ) sets(field string, template, issued interface{}, templateStrings, issuedStrings []string) {
+	if !equalStringSets(templateStrings, issuedStrings) {
+		d.add(field, template, issued)
+	}
+}
+
+func equalStringSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// templateMaxPathLen returns the path length constraint that c encodes, with
+// -1 meaning unlimited, using the template interpretation of MaxPathLen and
+// MaxPathLenZero described in the Certificate documentation.
+func templateMaxPathLen(c *Certificate) int {
+	if c.MaxPathLen == 0 && !c.MaxPathLenZero {
+		return -1
+	}
+	return c.MaxPathLen
+}
+
+// findExtension returns the first extension in extensions with the given id.
+func findExtension(extensions []pkix.Extension, id asn1.ObjectIdentifier) (pkix.Extension, bool) {
+	for _, e := range extensions {
+		if e.Id.Equal(id) {
+			return e, true
+		}
+	}
+	return pkix.Extension{}, false
+}
+
+// findOIDExtension returns the first extension in extensions with the given
+// id.
+func findOIDExtension(extensions []OIDExtension, id OID) (OIDExtension, bool) {
+	for _, e := range extensions {
+		if e.Id.Equal(id) {
+			return e, true
+		}
+	}
+	return OIDExtension{}, false
+}
+
+func extKeyUsageStrings(usages []ExtKeyUsage) []string {
+	var s []string
+	for _, u := range usages {
+		s = append(s, fmt.Sprint(int(u)))
+	}
+	return s
+}
+
+func oidStrings(oids []asn1.ObjectIdentifier) []string {
+	var s []string
+	for _, oid := range oids {
+		s = append(s, oid.String())
+	}
+	return s
+}
+
+func largeOIDStrings(oids []OID) []string {
+	var s []string
+	for _, oid := range oids {
+		s = append(s, oid.String())
+	}
+	return s
+}
+
+func policyMappingStrings(mappings []PolicyMapping) []string {
+	var s []string
+	for _, m := range mappings {
+		s = append(s, m.IssuerDomainPolicy.String()+" -> "+m.SubjectDomainPolicy.String())
+	}
+	return s
+}
+
+func ipStrings(ips []net.IP) []string {
+	var s []string
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	return s
+}
+
+func ipNetStrings(nets []*net.IPNet) []string {
+	var s []string
+	for _, n := range nets {
+		s = append(s, n.String())
+	}
+	return s
+}
+
+func nameStrings(names []pkix.Name) []string {
+	var s []string
+	for _, n := range names {
+		s = append(s, n.String())
+	}
+	return s
+}
+
+func uriStrings(uris []*url.URL) []string {
+	var s []string
+	for _, u := range uris {
+		s = append(s, u.String())
+	}
+	return s
+}
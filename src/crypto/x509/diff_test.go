@@ -0,0 +1,89 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func diffTestTemplate() *Certificate {
+	return &Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject: pkix.Name{
+			CommonName:   "diff.example",
+			Organization: []string{"Σ Acme Co"},
+		},
+		NotBefore: time.Unix(1000, 0),
+		NotAfter:  time.Unix(100000, 0),
+
+		KeyUsage:    KeyUsageDigitalSignature | KeyUsageKeyEncipherment,
+		ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+
+		DNSNames:    []string{"diff.example", "www.diff.example"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1).To4(), net.ParseIP("2001:db8::1")},
+
+		PolicyIdentifiers: []asn1.ObjectIdentifier{[]int{2, 23, 140, 1, 2, 1}},
+		ExtraExtensions: []pkix.Extension{
+			{Id: []int{1, 2, 3, 4}, Value: []byte{0x05, 0x00}},
+		},
+	}
+}
+
+func TestDiffTemplateIdentical(t *testing.T) {
+	template := diffTestTemplate()
+	issued := serialiseAndParse(t, template)
+
+	if diffs := DiffTemplate(issued, template); diffs != nil {
+		t.Errorf("unexpected differences: %v", diffs)
+	}
+
+	// Reordering list fields in the template is not a difference.
+	template.DNSNames = []string{"www.diff.example", "diff.example"}
+	template.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageClientAuth, ExtKeyUsageServerAuth}
+	if diffs := DiffTemplate(issued, template); diffs != nil {
+		t.Errorf("unexpected differences after reordering: %v", diffs)
+	}
+}
+
+func TestDiffTemplateAltered(t *testing.T) {
+	template := diffTestTemplate()
+
+	// Simulate a parent CA that truncates the validity period, strips a
+	// SAN and drops the extra extension.
+	altered := diffTestTemplate()
+	altered.NotAfter = time.Unix(50000, 0)
+	altered.DNSNames = altered.DNSNames[:1]
+	altered.ExtraExtensions = nil
+	derBytes, err := CreateCertificate(rand.Reader, altered, altered, &testPrivateKey.PublicKey, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := DiffTemplate(issued, template)
+	want := []string{"NotAfter", "DNSNames", "ExtraExtensions[1.2.3.4]"}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d differences, want %d: %v", len(diffs), len(want), diffs)
+	}
+	for i, d := range diffs {
+		if d.Field != want[i] {
+			t.Errorf("difference #%d is for %q, want %q", i, d.Field, want[i])
+		}
+	}
+	if got := diffs[0].Issued.(time.Time); !got.Equal(altered.NotAfter) {
+		t.Errorf("issued NotAfter reported as %v, want %v", got, altered.NotAfter)
+	}
+}
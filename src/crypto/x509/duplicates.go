@@ -0,0 +1,156 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DuplicateHandling controls how CreateCertificate treats repeated values in
+// a template. RFC 5280 forbids more than one instance of an extension, and
+// some parsers reject certificates with repeated subject alternative names.
+type DuplicateHandling int
+
+const (
+	// DuplicatesError makes CreateCertificate return an error if
+	// ExtraExtensions contains an extension more than once, or if a subject
+	// alternative name is repeated.
+	DuplicatesError DuplicateHandling = iota
+	// DuplicatesOverride makes a later entry of ExtraExtensions replace an
+	// earlier one with the same OID, in the position of the earlier one,
+	// and drops repeated subject alternative names.
+	DuplicatesOverride
+)
+
+// dedupExtensions returns extensions with no repeated OIDs, following h.
+func dedupExtensions(extensions []pkix.Extension, h DuplicateHandling) ([]pkix.Extension, error) {
+	var out []pkix.Extension
+	seen := make(map[string]int)
+	for _, e := range extensions {
+		id := e.Id.String()
+		if i, ok := seen[id]; ok {
+			if h != DuplicatesOverride {
+				return nil, fmt.Errorf("x509: extension %s appears more than once in ExtraExtensions", id)
+			}
+			out[i] = e
+			continue
+		}
+		seen[id] = len(out)
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// subjectAltNames holds the subject alternative names of a template.
+type subjectAltNames struct {
+	dnsNames       []string
+	emailAddresses []string
+	ipAddresses    []net.IP
+	uris           []*url.URL
+	directoryNames []pkix.Name
+}
+
+// dedupSANs returns the subject alternative names of template with no
+// repeated entries, following template.DuplicateHandling. Internationalized
+// DNS names are first converted to their ASCII form. DNS names are
+// compared case-insensitively, IP addresses by value regardless of their
+// length, and directory names by their encoding.
+func dedupSANs(template *Certificate) (sans subjectAltNames, err error) {
+	h := template.DuplicateHandling
+
+	dnsNames := template.DNSNames
+	if !template.InsecureSkipSANValidation {
+		if dnsNames, err = dnsNamesToASCII(dnsNames, template.RejectUnicodeDNSNames); err != nil {
+			return sans, err
+		}
+	}
+	var keys []string
+	for _, name := range dnsNames {
+		keys = append(keys, strings.ToLower(name))
+	}
+	keep, err := dedupKeys("DNS name", keys, dnsNames, h)
+	if err != nil {
+		return sans, err
+	}
+	for _, i := range keep {
+		sans.dnsNames = append(sans.dnsNames, dnsNames[i])
+	}
+
+	keep, err = dedupKeys("email address", template.EmailAddresses, template.EmailAddresses, h)
+	if err != nil {
+		return sans, err
+	}
+	for _, i := range keep {
+		sans.emailAddresses = append(sans.emailAddresses, template.EmailAddresses[i])
+	}
+
+	keys = nil
+	for _, ip := range template.IPAddresses {
+		keys = append(keys, ip.String())
+	}
+	keep, err = dedupKeys("IP address", keys, keys, h)
+	if err != nil {
+		return sans, err
+	}
+	for _, i := range keep {
+		sans.ipAddresses = append(sans.ipAddresses, template.IPAddresses[i])
+	}
+
+	keys = nil
+	for _, uri := range template.URIs {
+		keys = append(keys, uri.String())
+	}
+	keep, err = dedupKeys("URI", keys, keys, h)
+	if err != nil {
+		return sans, err
+	}
+	for _, i := range keep {
+		sans.uris = append(sans.uris, template.URIs[i])
+	}
+
+	keys = nil
+	var names []string
+	for _, name := range template.DirectoryNames {
+		b, err := asn1.Marshal(name.ToRDNSequence())
+		if err != nil {
+			return sans, err
+		}
+		keys = append(keys, string(b))
+		names = append(names, name.String())
+	}
+	keep, err = dedupKeys("directory name", keys, names, h)
+	if err != nil {
+		return sans, err
+	}
+	for _, i := range keep {
+		sans.directoryNames = append(sans.directoryNames, template.DirectoryNames[i])
+	}
+
+	return sans, nil
+}
+
+// dedupKeys returns the indexes of the first occurrence of each value in
+// keys, or an error naming the repeated value, taken from names, if h is
+// DuplicatesError.
+func dedupKeys(kind string, keys, names []string, h DuplicateHandling) ([]int, error) {
+	var keep []int
+	seen := make(map[string]bool)
+	for i, k := range keys {
+		if seen[k] {
+			if h != DuplicatesOverride {
+				return nil, fmt.Errorf("x509: %s %q appears more than once in the template", kind, names[i])
+			}
+			continue
+		}
+		seen[k] = true
+		keep = append(keep, i)
+	}
+	return keep, nil
+}
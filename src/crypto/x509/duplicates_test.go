@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateCertificateDuplicates(t *testing.T) {
+	oid := asn1.ObjectIdentifier{1, 2, 3, 4}
+	base := func() *Certificate {
+		return &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Duplicates"},
+			NotBefore:    time.Unix(1000, 0),
+			NotAfter:     time.Unix(100000, 0),
+		}
+	}
+
+	tests := []struct {
+		name string
+		mod  func(*Certificate)
+		err  string
+	}{
+		{"extension", func(c *Certificate) {
+			c.ExtraExtensions = []pkix.Extension{{Id: oid, Value: []byte{5, 0}}, {Id: oid, Value: []byte{1, 1, 0}}}
+		}, "extension 1.2.3.4 appears more than once"},
+		{"DNS name", func(c *Certificate) {
+			c.DNSNames = []string{"example.com", "www.example.com", "Example.COM"}
+		}, `DNS name "Example.COM" appears more than once`},
+		{"IP address", func(c *Certificate) {
+			c.IPAddresses = []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 1).To4()}
+		}, `IP address "192.0.2.1" appears more than once`},
+		{"directory name", func(c *Certificate) {
+			c.DirectoryNames = []pkix.Name{{CommonName: "a"}, {CommonName: "a"}}
+		}, `directory name "CN=a" appears more than once`},
+	}
+	for _, test := range tests {
+		template := base()
+		test.mod(template)
+		_, err := CreateCertificate(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	template := base()
+	for _, test := range tests {
+		test.mod(template)
+	}
+	template.DuplicateHandling = DuplicatesOverride
+	cert := serialiseAndParse(t, template)
+
+	if want := []string{"example.com", "www.example.com"}; !reflect.DeepEqual(cert.DNSNames, want) {
+		t.Errorf("got DNS names %v, want %v", cert.DNSNames, want)
+	}
+	if len(cert.IPAddresses) != 1 || len(cert.DirectoryNames) != 1 {
+		t.Errorf("got IP addresses %v and directory names %v, want one of each", cert.IPAddresses, cert.DirectoryNames)
+	}
+	var found []pkix.Extension
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(oid) {
+			found = append(found, e)
+		}
+	}
+	if len(found) != 1 || !reflect.DeepEqual(found[0].Value, []byte{1, 1, 0}) {
+		t.Errorf("got extensions %v, want only the last one", found)
+	}
+}
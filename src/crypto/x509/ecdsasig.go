@@ -0,0 +1,50 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// ValidateECDSASignature checks that sig is the strict DER encoding of an
+// ECDSA-Sig-Value, as defined in RFC 3279, Section 2.2.3, for a key on curve.
+// It does not verify the signature itself.
+//
+// The encoding must use minimal lengths and integers, with no trailing data,
+// and r and s must be in the range [1, N-1], where N is the order of curve.
+// If lowS is true, s must also be at most N/2, which rules out the second,
+// malleable form (r, N-s) of every signature.
+//
+// Systems that must reach the same verdict as other validators can use this
+// to reject encodings that some implementations tolerate. See also
+// VerifyOptions.StrictECDSASignatures and VerifyOptions.RequireLowS.
+func ValidateECDSASignature(curve elliptic.Curve, sig []byte, lowS bool) error {
+	var (
+		r, s  = new(big.Int), new(big.Int)
+		inner cryptobyte.String
+	)
+	input := cryptobyte.String(sig)
+	if !input.ReadASN1(&inner, cryptobyte_asn1.SEQUENCE) ||
+		!input.Empty() ||
+		!inner.ReadASN1Integer(r) ||
+		!inner.ReadASN1Integer(s) ||
+		!inner.Empty() {
+		return errors.New("x509: ECDSA signature is not strict DER")
+	}
+
+	n := curve.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return errors.New("x509: ECDSA signature value out of range")
+	}
+	if lowS && s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return errors.New("x509: ECDSA signature does not have a low S value")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+// withS returns sig with its S value replaced by the low or high form.
+func withS(t *testing.T, curve elliptic.Curve, sig []byte, high bool) []byte {
+	var v struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &v); err != nil {
+		t.Fatal(err)
+	}
+	n := curve.Params().N
+	isHigh := v.S.Cmp(new(big.Int).Rsh(n, 1)) > 0
+	if isHigh != high {
+		v.S.Sub(n, v.S)
+	}
+	out, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestValidateECDSASignature(t *testing.T) {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	encode := func(r, s *big.Int) []byte {
+		b, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	valid := encode(big.NewInt(1), big.NewInt(2))
+
+	tests := []struct {
+		name   string
+		sig    []byte
+		lowS   bool
+		wantOK bool
+	}{
+		{"valid", valid, true, true},
+		{"high S", encode(big.NewInt(1), new(big.Int).Sub(n, big.NewInt(2))), false, true},
+		{"high S with lowS", encode(big.NewInt(1), new(big.Int).Sub(n, big.NewInt(2))), true, false},
+		{"trailing data", append(append([]byte(nil), valid...), 0), false, false},
+		{"non-minimal integer", []byte{0x30, 0x07, 0x02, 0x02, 0x00, 0x01, 0x02, 0x01, 0x02}, false, false},
+		{"long form length", []byte{0x30, 0x81, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02}, false, false},
+		{"zero R", encode(big.NewInt(0), big.NewInt(2)), false, false},
+		{"negative S", encode(big.NewInt(1), big.NewInt(-2)), false, false},
+		{"S equal to N", encode(big.NewInt(1), n), false, false},
+	}
+	for _, test := range tests {
+		err := ValidateECDSASignature(curve, test.sig, test.lowS)
+		if ok := err == nil; ok != test.wantOK {
+			t.Errorf("%s: got error %v, want success: %v", test.name, err, test.wantOK)
+		}
+	}
+}
+
+func TestVerifyRequireLowS(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both forms of an ECDSA signature verify.
+	curve := root.PublicKey.(*ecdsa.PublicKey).Curve
+	leaf.Signature = withS(t, curve, leaf.Signature, true)
+
+	opts := VerifyOptions{Roots: NewCertPool(), StrictECDSASignatures: true}
+	opts.Roots.AddCert(root)
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Fatalf("high S signature rejected by strict verification: %v", err)
+	}
+
+	opts.RequireLowS = true
+	_, err = leaf.Verify(opts)
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != NonCanonicalSignature {
+		t.Fatalf("got error %v, want NonCanonicalSignature", err)
+	}
+
+	leaf.Signature = withS(t, curve, leaf.Signature, false)
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("low S signature rejected: %v", err)
+	}
+}
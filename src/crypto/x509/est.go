@@ -0,0 +1,121 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// MarshalESTCACerts returns the body of an Enrollment over Secure Transport
+// (EST) /cacerts response, RFC 7030, Section 4.1.3: the base64 encoding of a
+// certs-only PKCS #7 SignedData structure, as produced by
+// MarshalPKCS7Certificates. The response must be served with the
+// "application/pkcs7-mime" content type.
+//
+// certs must form a set that CheckESTCACerts accepts, that is the current
+// root CA certificate and optionally intermediates and root rollover
+// certificates.
+func MarshalESTCACerts(certs []*Certificate) ([]byte, error) {
+	if _, _, err := CheckESTCACerts(certs); err != nil {
+		return nil, err
+	}
+	der, err := MarshalPKCS7Certificates(certs)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, base64.StdEncoding.EncodedLen(len(der)))
+	base64.StdEncoding.Encode(body, der)
+	return body, nil
+}
+
+// ParseESTCACerts parses the body of an EST /cacerts response, RFC 7030,
+// Section 4.1.3, and checks it with CheckESTCACerts. The body is expected to
+// be base64 encoded, possibly with line breaks, but a DER body, as sent by
+// some servers, is accepted too.
+//
+// The certificates are returned split into roots, which the client should
+// use as its explicit trust anchors, and intermediates.
+func ParseESTCACerts(body []byte) (roots, intermediates []*Certificate, err error) {
+	der := body
+	if len(body) == 0 || body[0] != 0x30 {
+		b64 := bytes.Map(func(r rune) rune {
+			switch r {
+			case ' ', '\t', '\r', '\n':
+				return -1
+			}
+			return r
+		}, body)
+		der = make([]byte, base64.StdEncoding.DecodedLen(len(b64)))
+		n, err := base64.StdEncoding.Decode(der, b64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("x509: invalid base64 in EST /cacerts response: %v", err)
+		}
+		der = der[:n]
+	}
+	certs, err := ParsePKCS7Certificates(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return CheckESTCACerts(certs)
+}
+
+// CheckESTCACerts checks that certs form a coherent set of CA certificates
+// for an EST /cacerts response, and returns them split into roots and
+// intermediates, in their original order.
+//
+// Every certificate must be a CA certificate, as reported by IsCACert, and
+// the set must contain at least one root, a self-signed certificate. Every
+// other certificate must chain, through signatures by certificates of the
+// set, to one of the roots. This covers the rollover certificates of RFC
+// 7030, Section 4.1.3, which cross-sign the old and new roots. Validity
+// periods are not checked, since rollover certificates may not be valid yet.
+func CheckESTCACerts(certs []*Certificate) (roots, intermediates []*Certificate, err error) {
+	if len(certs) == 0 {
+		return nil, nil, errors.New("x509: no certificates in EST /cacerts response")
+	}
+	for _, c := range certs {
+		if !c.IsCACert() {
+			return nil, nil, fmt.Errorf("x509: EST /cacerts response includes non-CA certificate %q", c.Subject)
+		}
+		if c.IsSelfIssued() && c.CheckSignatureFrom(c) == nil {
+			roots = append(roots, c)
+		} else {
+			intermediates = append(intermediates, c)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, nil, errors.New("x509: EST /cacerts response includes no root certificate")
+	}
+
+	// Mark the certificates that chain to a root until no more do.
+	chained := make(map[*Certificate]bool, len(certs))
+	for _, root := range roots {
+		chained[root] = true
+	}
+	for progress := true; progress; {
+		progress = false
+		for _, c := range intermediates {
+			if chained[c] {
+				continue
+			}
+			for _, issuer := range certs {
+				if chained[issuer] && bytes.Equal(c.RawIssuer, issuer.RawSubject) && c.CheckSignatureFrom(issuer) == nil {
+					chained[c] = true
+					progress = true
+					break
+				}
+			}
+		}
+	}
+	for _, c := range intermediates {
+		if !chained[c] {
+			return nil, nil, fmt.Errorf("x509: EST /cacerts response includes certificate %q that does not chain to a root", c.Subject)
+		}
+	}
+	return roots, intermediates, nil
+}
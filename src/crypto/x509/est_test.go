@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestESTCACerts(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subInter, _, err := generateCert("Sub-intermediate", true, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoot, otherKey, err := generateCert("Other root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, _, err := generateCert("Orphan", true, otherRoot, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := MarshalESTCACerts([]*Certificate{subInter, inter, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wrapped bytes.Buffer
+	for s := string(body); len(s) > 0; {
+		n := 64
+		if n > len(s) {
+			n = len(s)
+		}
+		wrapped.WriteString(s[:n] + "\r\n")
+		s = s[n:]
+	}
+	for name, body := range map[string][]byte{"base64": body, "wrapped": wrapped.Bytes(), "DER": der} {
+		roots, intermediates, err := ParseESTCACerts(body)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(roots) != 1 || !roots[0].Equal(root) {
+			t.Errorf("%s: unexpected roots %v", name, roots)
+		}
+		if len(intermediates) != 2 || !intermediates[0].Equal(subInter) || !intermediates[1].Equal(inter) {
+			t.Errorf("%s: unexpected intermediates %v", name, intermediates)
+		}
+	}
+
+	if _, _, err := ParseESTCACerts([]byte("not base64!")); err == nil {
+		t.Error("invalid base64 was accepted")
+	}
+
+	tests := []struct {
+		name  string
+		certs []*Certificate
+		err   string
+	}{
+		{"empty", nil, "no certificates"},
+		{"leaf", []*Certificate{root, inter, leaf}, "non-CA certificate"},
+		{"no root", []*Certificate{inter}, "no root"},
+		{"orphan", []*Certificate{root, orphan}, "does not chain"},
+		{"two roots", []*Certificate{root, otherRoot, orphan, inter}, ""},
+	}
+	for _, tt := range tests {
+		_, _, err := CheckESTCACerts(tt.certs)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+		if _, err := MarshalESTCACerts(tt.certs); (err == nil) != (tt.err == "") {
+			t.Errorf("%s: MarshalESTCACerts returned %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 2315, Section 14
+//
+// pkcs-7 OBJECT IDENTIFIER ::= { iso(1) member-body(2) US(840) rsadsi(113549) pkcs(1) 7 }
+// data OBJECT IDENTIFIER ::= { pkcs-7 1 }
+// signedData OBJECT IDENTIFIER ::= { pkcs-7 2 }
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// MarshalPKCS7Certificates returns the DER encoding of a PKCS #7 SignedData
+// structure that carries certs and no content or signatures, as described
+// in RFC 2315, Section 9.1. Such "certs-only" files usually have a .p7b or
+// .p7c extension and can be imported by the Windows certificate manager and
+// by macOS Keychain Access.
+//
+// The certificates are stored in the given order, typically a chain as
+// returned by Verify, starting with the leaf.
+func MarshalPKCS7Certificates(certs []*Certificate) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("x509: no certificates to marshal")
+	}
+
+	// ContentInfo ::= SEQUENCE {
+	//      contentType ContentType,
+	//      content     [0] EXPLICIT ANY DEFINED BY contentType OPTIONAL }
+	//
+	// SignedData ::= SEQUENCE {
+	//      version          Version,
+	//      digestAlgorithms DigestAlgorithmIdentifiers,
+	//      contentInfo      ContentInfo,
+	//      certificates     [0] IMPLICIT ExtendedCertificatesAndCertificates OPTIONAL,
+	//      crls             [1] IMPLICIT CertificateRevocationLists OPTIONAL,
+	//      signerInfos      SignerInfos }
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(oidPKCS7SignedData)
+		b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1Int64(1)
+				b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {})
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(oidPKCS7Data)
+				})
+				b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+					for _, c := range certs {
+						b.AddBytes(c.Raw)
+					}
+				})
+				b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// MarshalConfigurationProfile returns an Apple configuration profile, usually
+// stored in a file with a .mobileconfig extension, that installs certs on
+// iOS, iPadOS and macOS devices. Self-signed certificates are installed as
+// trusted roots, which users must still enable on iOS. Other certificates
+// are installed as intermediates or leaves. The proprietary macOS .keychain
+// database format is not supported, but such profiles are imported into the
+// keychain when installed.
+//
+// identifier is the reverse-DNS identifier of the profile, such as
+// "com.example.roots", and displayName is the name shown to the user. The
+// UUIDs of the profile are derived from identifier and certs, so that
+// marshaling the same certificates again produces a profile that replaces
+// the installed one.
+func MarshalConfigurationProfile(certs []*Certificate, identifier, displayName string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("x509: no certificates to marshal")
+	}
+	if identifier == "" {
+		return nil, errors.New("x509: configuration profile identifier is empty")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+`)
+	for i, c := range certs {
+		payloadType := "com.apple.security.pkcs1"
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+			payloadType = "com.apple.security.root"
+		}
+		name := c.Subject.CommonName
+		if name == "" {
+			name = c.Subject.String()
+		}
+		id := fmt.Sprintf("%s.cert%d", identifier, i)
+
+		buf.WriteString("\t\t<dict>\n")
+		writePlistKeyString(&buf, "\t\t\t", "PayloadCertificateFileName", name+".cer")
+		buf.WriteString("\t\t\t<key>PayloadContent</key>\n\t\t\t<data>")
+		buf.WriteString(base64.StdEncoding.EncodeToString(c.Raw))
+		buf.WriteString("</data>\n")
+		writePlistKeyString(&buf, "\t\t\t", "PayloadDisplayName", name)
+		writePlistKeyString(&buf, "\t\t\t", "PayloadIdentifier", id)
+		writePlistKeyString(&buf, "\t\t\t", "PayloadType", payloadType)
+		writePlistKeyString(&buf, "\t\t\t", "PayloadUUID", profileUUID(id, c.Raw))
+		buf.WriteString("\t\t\t<key>PayloadVersion</key>\n\t\t\t<integer>1</integer>\n")
+		buf.WriteString("\t\t</dict>\n")
+	}
+	buf.WriteString("\t</array>\n")
+
+	var raws []byte
+	for _, c := range certs {
+		raws = append(raws, c.Raw...)
+	}
+	writePlistKeyString(&buf, "\t", "PayloadDisplayName", displayName)
+	writePlistKeyString(&buf, "\t", "PayloadIdentifier", identifier)
+	writePlistKeyString(&buf, "\t", "PayloadType", "Configuration")
+	writePlistKeyString(&buf, "\t", "PayloadUUID", profileUUID(identifier, raws))
+	buf.WriteString("\t<key>PayloadVersion</key>\n\t<integer>1</integer>\n")
+	buf.WriteString("</dict>\n</plist>\n")
+	return buf.Bytes(), nil
+}
+
+var plistEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func writePlistKeyString(buf *bytes.Buffer, indent, key, value string) {
+	fmt.Fprintf(buf, "%s<key>%s</key>\n%s<string>%s</string>\n", indent, key, indent, plistEscaper.Replace(value))
+}
+
+// profileUUID returns a name-based UUID for a payload, in the style of
+// RFC 4122, Section 4.3, but using SHA-256.
+func profileUUID(id string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write(data)
+	u := h.Sum(nil)[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
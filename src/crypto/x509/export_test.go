@@ -0,0 +1,102 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"strings"
+	"testing"
+)
+
+func TestMarshalPKCS7Certificates(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := MarshalPKCS7Certificates([]*Certificate{leaf, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if rest, err := asn1.Unmarshal(der, &contentInfo); err != nil || len(rest) != 0 {
+		t.Fatalf("failed to parse ContentInfo: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		t.Errorf("content type is %v", contentInfo.ContentType)
+	}
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     []asn1.RawValue `asn1:"tag:0"`
+		SignerInfos      asn1.RawValue
+	}
+	if rest, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil || len(rest) != 0 {
+		t.Fatalf("failed to parse SignedData: %v", err)
+	}
+	if len(signedData.Certificates) != 2 ||
+		!bytes.Equal(signedData.Certificates[0].FullBytes, leaf.Raw) ||
+		!bytes.Equal(signedData.Certificates[1].FullBytes, root.Raw) {
+		t.Errorf("unexpected certificates in SignedData")
+	}
+
+	if _, err := MarshalPKCS7Certificates(nil); err == nil {
+		t.Error("MarshalPKCS7Certificates succeeded without certificates")
+	}
+}
+
+func TestMarshalConfigurationProfile(t *testing.T) {
+	root, rootKey, err := generateCert("Root & Co", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Certificate{leaf, root}
+
+	profile, err := MarshalConfigurationProfile(chain, "com.example.roots", "Example <roots>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(profile)
+	for _, want := range []string{
+		"<string>com.apple.security.root</string>",
+		"<string>com.apple.security.pkcs1</string>",
+		"<string>Root &amp; Co</string>",
+		"<string>Example &lt;roots&gt;</string>",
+		"<string>com.example.roots.cert1</string>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("profile does not contain %q", want)
+		}
+	}
+	if strings.Count(s, "<string>com.apple.security.root</string>") != 1 {
+		t.Error("expected exactly one root payload")
+	}
+
+	again, err := MarshalConfigurationProfile(chain, "com.example.roots", "Example <roots>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(profile, again) {
+		t.Error("marshaling the same certificates produced different profiles")
+	}
+
+	if _, err := MarshalConfigurationProfile(chain, "", "Example"); err == nil {
+		t.Error("MarshalConfigurationProfile succeeded without an identifier")
+	}
+}
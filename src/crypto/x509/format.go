@@ -0,0 +1,17 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+// FormatChain returns a one-line description of chain for logs, such as
+//
+//	CN=leaf (ECDSA-P-256, expires 2021-06-01T00:00:00Z) -> CN=Root (RSA-2048, expires 2030-01-01T00:00:00Z)
+//
+// Each certificate is described by its subject, the algorithm and size of its
+// public key and its expiry time in UTC, starting with the leaf. The format
+// is meant for people and may change. To omit fields that may identify a
+// person, use Redaction.FormatChain.
+func FormatChain(chain []*Certificate) string {
+	return Redaction(0).FormatChain(chain)
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+)
+
+func TestFormatChain(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Certificate{
+		{
+			Subject:   pkix.Name{CommonName: "leaf", Organization: []string{"Example"}},
+			PublicKey: &ecKey.PublicKey,
+			NotAfter:  time.Date(2021, 6, 1, 2, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+		},
+		{
+			PublicKey: &testPrivateKey.PublicKey,
+			NotAfter:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	want := "CN=leaf,O=Example (ECDSA-P-256, expires 2021-06-01T00:00:00Z) -> <empty subject> (RSA-1024, expires 2030-01-01T00:00:00Z)"
+	if got := FormatChain(chain); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := FormatChain(nil); got != "" {
+		t.Errorf("empty chain: got %q", got)
+	}
+}
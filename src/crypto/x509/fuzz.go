@@ -0,0 +1,212 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build gofuzz
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// The targets in this file exercise the verification logic rather than the
+// parser. Their input is a sequence of DER certificates, each prefixed by its
+// length as a 16-bit big-endian integer. The seed corpus of
+// crypto/x509/internal/x509test can be turned into suitable inputs by adding
+// such prefixes.
+
+// fuzzKey signs every certificate that the chain building targets re-sign,
+// so that signatures never stop the fuzzer from reaching the interesting
+// code, and any certificate can act as the parent of any other.
+var fuzzKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// splitFuzzInput splits data into at most max parsed certificates. It
+// returns nil if any of them does not parse.
+func splitFuzzInput(data []byte, max int) []*Certificate {
+	var certs []*Certificate
+	for len(data) >= 2 && len(certs) < max {
+		n := int(data[0])<<8 | int(data[1])
+		data = data[2:]
+		if n > len(data) {
+			return nil
+		}
+		c, err := ParseCertificate(data[:n])
+		if err != nil {
+			return nil
+		}
+		certs = append(certs, c)
+		data = data[n:]
+	}
+	return certs
+}
+
+// FuzzNameConstraints checks the names of a leaf, the second certificate of
+// the input, against the name constraints of a CA, the first certificate.
+// Whenever the constraints are accepted, the IP address constraints are
+// re-checked independently, since historical bypasses came from mismatched
+// interpretations of IP and name encodings.
+func FuzzNameConstraints(data []byte) int {
+	certs := splitFuzzInput(data, 2)
+	if len(certs) != 2 {
+		return 0
+	}
+	ca, leaf := certs[0], certs[1]
+	if !ca.hasNameConstraints() {
+		return 0
+	}
+	// Signatures and name chaining are checked elsewhere.
+	leaf.RawIssuer = ca.RawSubject
+
+	opts := &VerifyOptions{CurrentTime: ca.NotBefore}
+	if err := ca.isValid(intermediateCertificate, []*Certificate{leaf}, opts); err != nil {
+		return 0
+	}
+
+	for _, ip := range leaf.IPAddresses {
+		for _, excluded := range ca.ExcludedIPRanges {
+			if len(excluded.IP) == len(ip) && excluded.Contains(ip) {
+				panic(fmt.Sprintf("IP %v accepted despite excluded range %v", ip, excluded))
+			}
+		}
+		if len(ca.PermittedIPRanges) == 0 {
+			continue
+		}
+		permitted := false
+		for _, r := range ca.PermittedIPRanges {
+			if len(r.IP) == len(ip) && r.Contains(ip) {
+				permitted = true
+			}
+		}
+		if !permitted {
+			panic(fmt.Sprintf("IP %v accepted outside of permitted ranges %v", ip, ca.PermittedIPRanges))
+		}
+	}
+	for _, name := range leaf.DNSNames {
+		for _, excluded := range ca.ExcludedDNSDomains {
+			if strings.EqualFold(strings.TrimPrefix(excluded, "."), name) {
+				panic(fmt.Sprintf("DNS name %q accepted despite excluded domain %q", name, excluded))
+			}
+		}
+	}
+	return 1
+}
+
+// fuzzVerify re-signs the certificates in data with fuzzKey, so that each is
+// issued by the next and the last is a self-signed root, and verifies the
+// first one with the given key usages. It returns nil if the input is not
+// usable or verification fails.
+func fuzzVerify(data []byte, keyUsages []ExtKeyUsage) (leaf *Certificate, chains [][]*Certificate) {
+	certs := splitFuzzInput(data, 8)
+	if len(certs) < 2 {
+		return nil, nil
+	}
+
+	resigned := make([]*Certificate, len(certs))
+	for i := len(certs) - 1; i >= 0; i-- {
+		t := TemplateFromCertificate(certs[i])
+		t.SignatureAlgorithm = UnknownSignatureAlgorithm
+		// Malformed names are what the fuzzer is looking for.
+		t.InsecureSkipSANValidation = true
+		t.DuplicateHandling = DuplicatesOverride
+		parent := t
+		if i < len(certs)-1 {
+			parent = resigned[i+1]
+		}
+		der, err := CreateCertificateWithOptions(rand.Reader, t, parent, &fuzzKey.PublicKey, fuzzKey, IssuanceOptions{InsecureAllowNonPositiveSerial: true})
+		if err != nil {
+			return nil, nil
+		}
+		if resigned[i], err = ParseCertificate(der); err != nil {
+			return nil, nil
+		}
+	}
+
+	opts := VerifyOptions{
+		Roots:         NewCertPool(),
+		Intermediates: NewCertPool(),
+		CurrentTime:   resigned[0].NotBefore,
+		KeyUsages:     keyUsages,
+	}
+	opts.Roots.AddCert(resigned[len(resigned)-1])
+	for _, c := range resigned[1 : len(resigned)-1] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	chains, err := resigned[0].Verify(opts)
+	if err != nil {
+		return nil, nil
+	}
+	return resigned[0], chains
+}
+
+// FuzzChainBuilding builds chains from crafted pools and checks that every
+// chain returned by Verify is well formed.
+func FuzzChainBuilding(data []byte) int {
+	leaf, chains := fuzzVerify(data, []ExtKeyUsage{ExtKeyUsageAny})
+	if leaf == nil {
+		return 0
+	}
+	for _, chain := range chains {
+		if !chain[0].Equal(leaf) {
+			panic("chain does not start with the leaf")
+		}
+		seen := make(map[string]bool)
+		for i, c := range chain {
+			if seen[string(c.Raw)] {
+				panic("chain contains a certificate twice")
+			}
+			seen[string(c.Raw)] = true
+			if i == len(chain)-1 {
+				break
+			}
+			parent := chain[i+1]
+			if !bytes.Equal(c.RawIssuer, parent.RawSubject) {
+				panic("chain links certificates with mismatched names")
+			}
+			if parent.BasicConstraintsValid && !parent.IsCA {
+				panic("chain contains a non-CA issuer")
+			}
+		}
+	}
+	return 1
+}
+
+// FuzzExtKeyUsagePolicy checks that an accepted chain honors the extended key
+// usages of every certificate in it, which is the only policy that Verify
+// processes.
+func FuzzExtKeyUsagePolicy(data []byte) int {
+	if len(data) < 1 {
+		return 0
+	}
+	usage := ExtKeyUsage(int(data[0]) % (int(ExtKeyUsageMicrosoftKernelCodeSigning) + 1))
+	if usage == ExtKeyUsageAny {
+		return 0
+	}
+	leaf, chains := fuzzVerify(data[1:], []ExtKeyUsage{usage})
+	if leaf == nil {
+		return 0
+	}
+	for _, chain := range chains {
+	NextCert:
+		for _, c := range chain {
+			if len(c.ExtKeyUsage) == 0 && len(c.UnknownExtKeyUsage) == 0 && len(c.UnknownExtKeyUsageOIDs) == 0 {
+				continue
+			}
+			for _, u := range c.ExtKeyUsage {
+				if u == ExtKeyUsageAny || u == usage ||
+					usage == ExtKeyUsageServerAuth &&
+						(u == ExtKeyUsageNetscapeServerGatedCrypto || u == ExtKeyUsageMicrosoftServerGatedCrypto) {
+					continue NextCert
+				}
+			}
+			panic(fmt.Sprintf("chain accepted for %v despite extended key usages %v %v", usage, c.ExtKeyUsage, c.UnknownExtKeyUsage))
+		}
+	}
+	return 1
+}
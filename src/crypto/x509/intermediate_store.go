@@ -0,0 +1,347 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An IntermediateStore keeps the intermediate certificates downloaded from
+// the caIssuers URLs of certificates, see Certificate.IssuingCertificateURL,
+// so that chasing them again does not download them again.
+//
+// Stored certificates can be looked up by the URL they were downloaded from,
+// or by their subject key identifier, which is the authority key identifier
+// of the certificates they issued. The latter finds an issuer even if it was
+// downloaded from another URL.
+type IntermediateStore interface {
+	// GetByURL returns the certificates stored for url, or nil if there
+	// are none.
+	GetByURL(url string) []*Certificate
+	// GetByKeyID returns the stored certificates whose SubjectKeyId is
+	// keyID, or nil if there are none.
+	GetByKeyID(keyID []byte) []*Certificate
+	// Put stores certs, downloaded from url, replacing the certificates
+	// previously stored for url.
+	Put(url string, certs []*Certificate) error
+}
+
+// DiskIntermediateStore is an IntermediateStore that keeps certificates in
+// PEM files in a directory, so that they survive process restarts and are
+// shared by the processes using the same directory.
+//
+// Each URL, and each certificate with a subject key identifier, is kept in a
+// file of its own, which is replaced atomically. When there are more than
+// MaxFiles of them, the least recently used ones are removed.
+//
+// A DiskIntermediateStore is safe for concurrent use. Its fields must not be
+// modified after the first call to one of its methods.
+type DiskIntermediateStore struct {
+	// Dir is the directory holding the files. It is created if it doesn't
+	// exist, and should not be used for anything else.
+	Dir string
+
+	// MaxFiles is the maximum number of files kept in Dir. If zero, it is
+	// 1000.
+	MaxFiles int
+
+	mu sync.Mutex // serializes Put
+}
+
+const (
+	intermediateURLPrefix = "url-"
+	intermediateKeyPrefix = "key-"
+	intermediateSuffix    = ".pem"
+	intermediateURLHeader = "URL"
+)
+
+func (s *DiskIntermediateStore) urlFile(url string) string {
+	h := sha256.Sum256([]byte(url))
+	return intermediateURLPrefix + hex.EncodeToString(h[:]) + intermediateSuffix
+}
+
+// keyFilePrefix returns the prefix of the names of the files holding the
+// certificates with the subject key identifier keyID.
+func (s *DiskIntermediateStore) keyFilePrefix(keyID []byte) string {
+	h := sha256.Sum256(keyID)
+	return intermediateKeyPrefix + hex.EncodeToString(h[:]) + "-"
+}
+
+func (s *DiskIntermediateStore) keyFile(cert *Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return s.keyFilePrefix(cert.SubjectKeyId) + hex.EncodeToString(h[:8]) + intermediateSuffix
+}
+
+// GetByURL implements IntermediateStore.
+func (s *DiskIntermediateStore) GetByURL(url string) []*Certificate {
+	certs, storedURL := s.read(s.urlFile(url))
+	if storedURL != url {
+		return nil
+	}
+	return certs
+}
+
+// GetByKeyID implements IntermediateStore.
+func (s *DiskIntermediateStore) GetByKeyID(keyID []byte) []*Certificate {
+	if len(keyID) == 0 {
+		return nil
+	}
+	files, err := s.files()
+	if err != nil {
+		return nil
+	}
+	prefix := s.keyFilePrefix(keyID)
+	var out []*Certificate
+	for _, fi := range files {
+		if !strings.HasPrefix(fi.Name(), prefix) {
+			continue
+		}
+		certs, _ := s.read(fi.Name())
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, keyID) {
+				out = append(out, cert)
+			}
+		}
+	}
+	return out
+}
+
+// read returns the certificates in the file name, and the URL recorded in
+// it, if any. Unreadable or malformed files are treated as missing.
+func (s *DiskIntermediateStore) read(name string) (certs []*Certificate, url string) {
+	path := filepath.Join(s.Dir, name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ""
+	}
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, ""
+		}
+		if len(certs) == 0 {
+			url = block.Headers[intermediateURLHeader]
+		}
+		cert, err := ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, ""
+		}
+		certs = append(certs, cert)
+	}
+
+	// Record the use, so that the file is not among the first removed.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return certs, url
+}
+
+// Put implements IntermediateStore. Storing no certificates removes those
+// stored for url.
+func (s *DiskIntermediateStore) Put(url string, certs []*Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	if len(certs) == 0 {
+		err := os.Remove(filepath.Join(s.Dir, s.urlFile(url)))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+
+	var buf bytes.Buffer
+	for i, cert := range certs {
+		if len(cert.Raw) == 0 {
+			return errors.New("x509: intermediate store requires parsed certificates")
+		}
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+		if i == 0 {
+			block.Headers = map[string]string{intermediateURLHeader: url}
+		}
+		if err := pem.Encode(&buf, block); err != nil {
+			return err
+		}
+	}
+	if err := s.write(s.urlFile(url), buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, cert := range certs {
+		if len(cert.SubjectKeyId) == 0 {
+			continue
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err := s.write(s.keyFile(cert), data); err != nil {
+			return err
+		}
+	}
+
+	return s.evict()
+}
+
+// write replaces the file name with data, atomically so that concurrent
+// readers never see a partial file.
+func (s *DiskIntermediateStore) write(name string, data []byte) error {
+	f, err := ioutil.TempFile(s.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.Dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// files returns the files of the store in Dir.
+func (s *DiskIntermediateStore) files() ([]os.FileInfo, error) {
+	all, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	files := all[:0]
+	for _, fi := range all {
+		name := fi.Name()
+		if fi.Mode().IsRegular() && strings.HasSuffix(name, intermediateSuffix) &&
+			(strings.HasPrefix(name, intermediateURLPrefix) || strings.HasPrefix(name, intermediateKeyPrefix)) {
+			files = append(files, fi)
+		}
+	}
+	return files, nil
+}
+
+// evict removes the least recently used files beyond MaxFiles.
+func (s *DiskIntermediateStore) evict() error {
+	maxFiles := s.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = 1000
+	}
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	if len(files) <= maxFiles {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files[:len(files)-maxFiles] {
+		err := os.Remove(filepath.Join(s.Dir, fi.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxCompletedChainLength is the maximum length of the chains returned by
+// CompleteChain.
+const maxCompletedChainLength = 10
+
+// CompleteChain returns the likely chains of leaf, built from the issuers
+// found in store by authority key identifier and by caIssuers URL. It is
+// meant for installers and certificate managers that are given only a leaf,
+// and need to serve or export it with its intermediates.
+//
+// Each chain starts with leaf, and each of its other certificates has the
+// subject of, and a valid signature on, the previous one. A chain ends with a
+// self-signed certificate, or with the last certificate whose issuer is not in
+// store. Chains ending with a self-signed certificate are returned first.
+// No trust decision is made: validity periods, name constraints and key
+// usages are not checked, and the last certificate need not be trusted. Use
+// Verify for that.
+//
+// If leaf is self-signed, the only chain is leaf alone. Otherwise,
+// CompleteChain returns an error if store has no issuer of leaf.
+func CompleteChain(leaf *Certificate, store IntermediateStore) ([][]*Certificate, error) {
+	if leaf.isSelfSigned() {
+		return [][]*Certificate{{leaf}}, nil
+	}
+
+	var chains [][]*Certificate
+	var extend func(chain []*Certificate)
+	extend = func(chain []*Certificate) {
+		c := chain[len(chain)-1]
+		var issuers []*Certificate
+		if !c.isSelfSigned() && len(chain) < maxCompletedChainLength {
+			issuers = storedIssuers(c, store, chain)
+		}
+		if len(issuers) == 0 {
+			chains = append(chains, chain)
+			return
+		}
+		for _, issuer := range issuers {
+			extend(appendToFreshChain(chain, issuer))
+		}
+	}
+	extend([]*Certificate{leaf})
+
+	if len(chains) == 1 && len(chains[0]) == 1 {
+		return nil, errors.New("x509: no issuer of the certificate found in the intermediate store")
+	}
+	sort.SliceStable(chains, func(i, j int) bool {
+		return chains[i][len(chains[i])-1].isSelfSigned() && !chains[j][len(chains[j])-1].isSelfSigned()
+	})
+	return chains, nil
+}
+
+// storedIssuers returns the certificates of store that issued c, other than
+// those already in chain.
+func storedIssuers(c *Certificate, store IntermediateStore, chain []*Certificate) []*Certificate {
+	var candidates []*Certificate
+	if len(c.AuthorityKeyId) > 0 {
+		candidates = append(candidates, store.GetByKeyID(c.AuthorityKeyId)...)
+	}
+	for _, url := range c.IssuingCertificateURL {
+		candidates = append(candidates, store.GetByURL(url)...)
+	}
+
+	var issuers []*Certificate
+	for _, candidate := range candidates {
+		if containsCert(chain, candidate) || containsCert(issuers, candidate) {
+			continue
+		}
+		if bytes.Equal(c.RawIssuer, candidate.RawSubject) && c.CheckSignatureFrom(candidate) == nil {
+			issuers = append(issuers, candidate)
+		}
+	}
+	return issuers
+}
+
+func containsCert(certs []*Certificate, cert *Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskIntermediateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "x509-intermediates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "http://ca.example/intermediate.crt"
+	store := &DiskIntermediateStore{Dir: filepath.Join(dir, "store")}
+	if certs := store.GetByURL(url); certs != nil {
+		t.Fatalf("empty store returned %d certificates", len(certs))
+	}
+	if err := store.Put(url, []*Certificate{intermediate}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store for the same directory, as after a restart, finds the
+	// certificate by URL and by the authority key identifier of the leaf.
+	store = &DiskIntermediateStore{Dir: store.Dir}
+	if certs := store.GetByURL(url); len(certs) != 1 || !certs[0].Equal(intermediate) {
+		t.Errorf("GetByURL returned %v", certs)
+	}
+	if certs := store.GetByKeyID(leaf.AuthorityKeyId); len(certs) != 1 || !certs[0].Equal(intermediate) {
+		t.Errorf("GetByKeyID returned %v", certs)
+	}
+	if certs := store.GetByURL(url + "?"); certs != nil {
+		t.Errorf("GetByURL for another URL returned %v", certs)
+	}
+	if certs := store.GetByKeyID(intermediate.AuthorityKeyId); certs != nil {
+		t.Errorf("GetByKeyID for another key returned %v", certs)
+	}
+
+	// Malformed files are ignored.
+	if err := ioutil.WriteFile(filepath.Join(store.Dir, store.urlFile(url)), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if certs := store.GetByURL(url); certs != nil {
+		t.Errorf("GetByURL returned %v from a malformed file", certs)
+	}
+
+	// Putting no certificates removes the URL.
+	if err := store.Put(url, []*Certificate{intermediate}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(url, nil); err != nil {
+		t.Fatal(err)
+	}
+	if certs := store.GetByURL(url); certs != nil {
+		t.Errorf("GetByURL returned %v after removal", certs)
+	}
+}
+
+func TestDiskIntermediateStoreEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "x509-intermediates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &DiskIntermediateStore{Dir: dir, MaxFiles: 4}
+	urls := []string{"http://a.example/ca.crt", "http://b.example/ca.crt", "http://c.example/ca.crt"}
+	for i, url := range urls {
+		cert, _, err := generateCert("CA", true, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(url, []*Certificate{cert}); err != nil {
+			t.Fatal(err)
+		}
+		// Make the files of each URL older than those of the next one.
+		old := time.Now().Add(time.Duration(i-len(urls)) * time.Hour)
+		for _, name := range []string{store.urlFile(url), store.keyFile(cert)} {
+			if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	files, err := store.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Errorf("got %d files, want 4", len(files))
+	}
+	if certs := store.GetByURL(urls[0]); certs != nil {
+		t.Errorf("least recently used URL was not evicted")
+	}
+	if certs := store.GetByURL(urls[2]); len(certs) != 1 {
+		t.Errorf("most recently used URL was evicted")
+	}
+}
+
+func TestCompleteChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "x509-intermediates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A cross-signed version of the intermediate, from a root that is not
+	// in the store, is found by the same key identifier.
+	otherRoot, otherRootKey, err := generateCert("Other root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate"},
+		NotBefore:             intermediate.NotBefore,
+		NotAfter:              intermediate.NotAfter,
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := CreateCertificate(rand.Reader, template, otherRoot, intermediateKey.(crypto.Signer).Public(), otherRootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cross, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &DiskIntermediateStore{Dir: dir}
+	if _, err := CompleteChain(leaf, store); err == nil {
+		t.Error("CompleteChain succeeded with an empty store")
+	}
+	if chains, err := CompleteChain(root, store); err != nil || len(chains) != 1 || len(chains[0]) != 1 {
+		t.Errorf("CompleteChain of a root returned %v, %v", chains, err)
+	}
+
+	if err := store.Put("http://cross.example/ca.crt", []*Certificate{cross}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("http://ca.example/ca.crt", []*Certificate{intermediate, root}); err != nil {
+		t.Fatal(err)
+	}
+	chains, err := CompleteChain(leaf, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]*Certificate{{leaf, intermediate, root}, {leaf, cross}}
+	if len(chains) != len(want) {
+		t.Fatalf("got %d chains, want %d", len(chains), len(want))
+	}
+	for i := range want {
+		if !equalChains(chains[i], want[i]) {
+			t.Errorf("chain %d: got %v, want %v", i, chainToDebugString(chains[i]), chainToDebugString(want[i]))
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build gofuzz
+
+package x509test
+
+import "fmt"
+
+// Fuzz is a go-fuzz target for the certificate parser. If Reference is set,
+// any divergence from it is reported as a crash.
+func Fuzz(data []byte) int {
+	score := 0
+	if _, err := Parse(data); err == nil {
+		score = 1
+	}
+	if Reference != nil {
+		if err := Compare(data, Reference); err != nil {
+			panic(fmt.Sprintf("%v\ninput: %x", err, data))
+		}
+	}
+	return score
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// Generates the seed corpus in testdata/corpus.
+//
+// The corpus covers the certificate features that crypto/x509 interprets,
+// as well as encodings that implementations have historically disagreed on,
+// followed by truncated and corrupted variants of some of them.
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var output = flag.String("output", "testdata/corpus", "directory to write the corpus to")
+
+func main() {
+	flag.Parse()
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	base := func(cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	rawExtension := func(id asn1.ObjectIdentifier, critical bool, value interface{}) pkix.Extension {
+		b, err := asn1.Marshal(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return pkix.Extension{Id: id, Critical: critical, Value: b}
+	}
+
+	type seed struct {
+		name     string
+		template *x509.Certificate
+		key      crypto.Signer
+	}
+	var seeds []seed
+	add := func(name string, key crypto.Signer, mod func(*x509.Certificate)) {
+		t := base(name)
+		if mod != nil {
+			mod(t)
+		}
+		seeds = append(seeds, seed{name, t, key})
+	}
+
+	add("rsa-minimal", rsaKey, nil)
+	add("p256-minimal", p256Key, nil)
+	add("p384-minimal", p384Key, nil)
+	add("ed25519-minimal", edKey, nil)
+	add("rsa-pss", rsaKey, func(t *x509.Certificate) {
+		t.SignatureAlgorithm = x509.SHA256WithRSAPSS
+	})
+	add("ca", p256Key, func(t *x509.Certificate) {
+		t.BasicConstraintsValid = true
+		t.IsCA = true
+		t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	})
+	add("ca-pathlen-zero", p256Key, func(t *x509.Certificate) {
+		t.BasicConstraintsValid = true
+		t.IsCA = true
+		t.MaxPathLenZero = true
+	})
+	add("ca-pathlen-3", p256Key, func(t *x509.Certificate) {
+		t.BasicConstraintsValid = true
+		t.IsCA = true
+		t.MaxPathLen = 3
+	})
+	add("leaf-pathlen", p256Key, func(t *x509.Certificate) {
+		// A path length constraint on a leaf, which CreateCertificate
+		// refuses to produce from the template fields.
+		t.ExtraExtensions = []pkix.Extension{rawExtension(asn1.ObjectIdentifier{2, 5, 29, 19}, true, struct {
+			IsCA       bool `asn1:"optional"`
+			MaxPathLen int  `asn1:"optional,default:-1"`
+		}{false, 0})}
+	})
+	add("server", p256Key, func(t *x509.Certificate) {
+		t.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+		t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		t.DNSNames = []string{"example.com", "*.example.com"}
+		t.IPAddresses = []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.ParseIP("2001:db8::1")}
+	})
+	add("unknown-eku-order", p256Key, func(t *x509.Certificate) {
+		t.UnknownExtKeyUsage = []asn1.ObjectIdentifier{{1, 2, 3, 4}}
+		t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	})
+	add("all-sans", p256Key, func(t *x509.Certificate) {
+		u, _ := url.Parse("spiffe://example.org/service")
+		t.DNSNames = []string{"example.com"}
+		t.EmailAddresses = []string{"admin@example.com"}
+		t.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1).To4()}
+		t.URIs = []*url.URL{u}
+	})
+	add("empty-subject-critical-san", p256Key, func(t *x509.Certificate) {
+		t.Subject = pkix.Name{}
+		t.DNSNames = []string{"example.com"}
+	})
+	add("name-constraints", p256Key, func(t *x509.Certificate) {
+		t.BasicConstraintsValid = true
+		t.IsCA = true
+		t.PermittedDNSDomainsCritical = true
+		t.PermittedDNSDomains = []string{"example.com", ".example.org"}
+		t.ExcludedDNSDomains = []string{"bad.example.com"}
+		_, permitted, _ := net.ParseCIDR("10.0.0.0/8")
+		_, excluded, _ := net.ParseCIDR("2001:db8::/32")
+		t.PermittedIPRanges = []*net.IPNet{permitted}
+		t.ExcludedIPRanges = []*net.IPNet{excluded}
+		t.PermittedEmailAddresses = []string{"example.com"}
+		t.ExcludedURIDomains = []string{".example.net"}
+	})
+	add("policies-aia-crldp", p256Key, func(t *x509.Certificate) {
+		t.PolicyIdentifiers = []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}, {2, 5, 29, 32, 0}}
+		t.OCSPServer = []string{"http://ocsp.example.com"}
+		t.IssuingCertificateURL = []string{"http://example.com/ca.crt"}
+		t.CRLDistributionPoints = []string{"http://example.com/ca.crl"}
+	})
+	add("key-ids", p256Key, func(t *x509.Certificate) {
+		t.SubjectKeyId = []byte{1, 2, 3, 4}
+		t.AuthorityKeyId = []byte{5, 6, 7, 8}
+	})
+	add("unknown-critical", p256Key, func(t *x509.Certificate) {
+		t.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5}, Critical: true, Value: []byte{0x05, 0x00}}}
+	})
+	add("large-serial", p256Key, func(t *x509.Certificate) {
+		t.SerialNumber = new(big.Int).Lsh(big.NewInt(1), 159)
+	})
+	add("negative-serial", p256Key, func(t *x509.Certificate) {
+		t.SerialNumber = big.NewInt(-1)
+	})
+	add("generalized-time", p256Key, func(t *x509.Certificate) {
+		t.NotAfter = time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+	add("rich-subject", p256Key, func(t *x509.Certificate) {
+		t.Subject = pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Example, Inc."},
+			OrganizationalUnit: []string{"A", "B"},
+			Locality:           []string{"Anytown"},
+			Province:           []string{"CA"},
+			CommonName:         "Ελληνικά \"quoted\" + special",
+			SerialNumber:       "1234",
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}, Value: "example"},
+				{Type: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}, Value: "admin@example.com"},
+			},
+		}
+	})
+	add("biometric-logotype", p256Key, func(t *x509.Certificate) {
+		h := sha256.Sum256([]byte("logo"))
+		alg := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}}
+		t.BiometricInfo = []x509.BiometricData{{Type: x509.BiometricPicture, HashAlgorithm: alg, Hash: h[:]}}
+		t.Logotypes = &x509.Logotypes{SubjectLogo: &x509.LogotypeInfo{
+			Images: []x509.LogotypeDetails{{
+				MediaType: "image/png",
+				Hashes:    []x509.HashAlgAndValue{{Algorithm: alg, Value: h[:]}},
+				URIs:      []string{"https://example.com/logo.png"},
+			}},
+		}}
+	})
+
+	var corpus [][]byte
+	for _, s := range seeds {
+		// The negative-serial seed needs the serial number check relaxed.
+		opts := x509.IssuanceOptions{InsecureAllowNonPositiveSerial: true}
+		der, err := x509.CreateCertificateWithOptions(rand.Reader, s.template, s.template, s.key.Public(), s.key, opts)
+		if err != nil {
+			log.Fatalf("%s: %v", s.name, err)
+		}
+		corpus = append(corpus, der)
+		write(s.name, der)
+	}
+
+	// Corrupted variants of the first few seeds.
+	for i, der := range corpus[:4] {
+		write(fmt.Sprintf("%s-truncated", seeds[i].name), der[:len(der)/2])
+		flipped := append([]byte(nil), der...)
+		flipped[len(flipped)/3] ^= 0x80
+		write(fmt.Sprintf("%s-bitflip", seeds[i].name), flipped)
+		write(fmt.Sprintf("%s-trailing", seeds[i].name), append(append([]byte(nil), der...), 0))
+	}
+}
+
+func write(name string, der []byte) {
+	if err := ioutil.WriteFile(filepath.Join(*output, name+".der"), der, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
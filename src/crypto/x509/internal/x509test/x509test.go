@@ -0,0 +1,202 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package x509test contains helpers for differential testing of the
+// crypto/x509 parser against other X.509 implementations.
+//
+// A certificate is reduced to an implementation neutral Summary, and Compare
+// reports where two parsers disagree about the same input, either because
+// only one of them accepted it or because they extracted different values.
+// This catches semantic divergences that fuzzing for panics alone does not.
+//
+// To compare against another implementation, such as OpenSSL via cgo in an
+// external CI job, add a file to this package, typically guarded by a build
+// tag, that sets Reference in an init function. The tests in this package and
+// the go-fuzz target in fuzz.go then check every input against it. The seed
+// corpus in testdata/corpus can be used as the initial go-fuzz corpus.
+package x509test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Summary is the implementation neutral view of a parsed certificate that
+// is compared between implementations. Object identifiers are in dotted
+// decimal form and names are in their raw DER encoding, so that no
+// implementation specific formatting is involved.
+type Summary struct {
+	Version      int
+	SerialNumber string // decimal
+	RawIssuer    []byte
+	RawSubject   []byte
+	NotBefore    time.Time
+	NotAfter     time.Time
+
+	// Extensions lists the OIDs of all extensions, in order, with a "!"
+	// suffix for critical extensions.
+	Extensions []string
+
+	BasicConstraintsValid bool
+	IsCA                  bool
+	MaxPathLen            int // -1 if absent
+
+	// KeyUsage holds the bits of the key usage extension, with bit 0 being
+	// digitalSignature, or 0 if absent.
+	KeyUsage    int
+	ExtKeyUsage []string
+
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []string
+	URIs           []string
+
+	SubjectKeyId   []byte
+	AuthorityKeyId []byte
+}
+
+var oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+// A ParseFunc parses a DER encoded certificate into a Summary.
+type ParseFunc func(der []byte) (*Summary, error)
+
+// Reference is the implementation that Parse is compared against by the
+// tests and the fuzz target in this package. It is nil unless set by an
+// external harness.
+var Reference ParseFunc
+
+// Parse parses der with crypto/x509 and summarizes the result.
+func Parse(der []byte) (*Summary, error) {
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(c), nil
+}
+
+// Summarize returns the Summary of a certificate parsed by crypto/x509.
+func Summarize(c *x509.Certificate) *Summary {
+	s := &Summary{
+		Version:               c.Version,
+		RawIssuer:             c.RawIssuer,
+		RawSubject:            c.RawSubject,
+		NotBefore:             c.NotBefore.UTC(),
+		NotAfter:              c.NotAfter.UTC(),
+		BasicConstraintsValid: c.BasicConstraintsValid,
+		IsCA:                  c.IsCA,
+		MaxPathLen:            -1,
+		KeyUsage:              int(c.KeyUsage),
+		DNSNames:              c.DNSNames,
+		EmailAddresses:        c.EmailAddresses,
+		SubjectKeyId:          c.SubjectKeyId,
+		AuthorityKeyId:        c.AuthorityKeyId,
+	}
+	if c.SerialNumber != nil {
+		s.SerialNumber = c.SerialNumber.String()
+	}
+	if c.BasicConstraintsValid && (c.MaxPathLen > 0 || c.MaxPathLenZero) {
+		s.MaxPathLen = c.MaxPathLen
+	}
+	for _, e := range c.Extensions {
+		id := e.Id.String()
+		if e.Critical {
+			id += "!"
+		}
+		s.Extensions = append(s.Extensions, id)
+
+		// crypto/x509 splits extended key usages into known and unknown
+		// ones, so take them from the extension to preserve their order.
+		if e.Id.Equal(oidExtensionExtendedKeyUsage) {
+			var oids []asn1.ObjectIdentifier
+			asn1.Unmarshal(e.Value, &oids)
+			for _, oid := range oids {
+				s.ExtKeyUsage = append(s.ExtKeyUsage, oid.String())
+			}
+		}
+	}
+	for _, ip := range c.IPAddresses {
+		s.IPAddresses = append(s.IPAddresses, ip.String())
+	}
+	for _, u := range c.URIs {
+		s.URIs = append(s.URIs, u.String())
+	}
+	return s
+}
+
+// A Divergence is a field on which two implementations disagree.
+type Divergence struct {
+	Field     string
+	Go, Other interface{}
+}
+
+// DivergenceError is returned by Compare if the implementations disagree.
+type DivergenceError struct {
+	Input []byte
+
+	// GoErr and OtherErr are the parsing errors, if exactly one of the
+	// implementations rejected Input.
+	GoErr, OtherErr error
+
+	// Differences lists the differing fields if both accepted Input.
+	Differences []Divergence
+}
+
+func (e *DivergenceError) Error() string {
+	switch {
+	case e.GoErr != nil:
+		return "x509test: only the other implementation accepted the input: " + e.GoErr.Error()
+	case e.OtherErr != nil:
+		return "x509test: only crypto/x509 accepted the input: " + e.OtherErr.Error()
+	}
+	var buf bytes.Buffer
+	buf.WriteString("x509test: implementations disagree on")
+	for _, d := range e.Differences {
+		fmt.Fprintf(&buf, " %s (%v vs %v);", d.Field, d.Go, d.Other)
+	}
+	return buf.String()
+}
+
+// Compare parses der with crypto/x509 and with other, and returns a
+// *DivergenceError if the results differ. It returns nil if both
+// implementations reject der.
+func Compare(der []byte, other ParseFunc) error {
+	got, goErr := Parse(der)
+	want, otherErr := other(der)
+	switch {
+	case goErr != nil && otherErr != nil:
+		return nil
+	case goErr != nil || otherErr != nil:
+		return &DivergenceError{Input: der, GoErr: goErr, OtherErr: otherErr}
+	}
+
+	var diffs []Divergence
+	gv, ov := reflect.ValueOf(got).Elem(), reflect.ValueOf(want).Elem()
+	for i := 0; i < gv.NumField(); i++ {
+		g, o := gv.Field(i).Interface(), ov.Field(i).Interface()
+		if !equal(g, o) {
+			diffs = append(diffs, Divergence{Field: gv.Type().Field(i).Name, Go: g, Other: o})
+		}
+	}
+	if diffs != nil {
+		return &DivergenceError{Input: der, Differences: diffs}
+	}
+	return nil
+}
+
+// equal is like reflect.DeepEqual, except that nil and empty slices are
+// equal and times are compared with Equal.
+func equal(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		return ta.Equal(b.(time.Time))
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Slice && va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
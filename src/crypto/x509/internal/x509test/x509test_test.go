@@ -0,0 +1,84 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func readCorpus(t *testing.T) map[string][]byte {
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.der"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("empty seed corpus")
+	}
+	corpus := make(map[string][]byte)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus[filepath.Base(f)] = b
+	}
+	return corpus
+}
+
+func TestCorpus(t *testing.T) {
+	accepted := 0
+	for name, der := range readCorpus(t) {
+		if _, err := Parse(der); err == nil {
+			accepted++
+		}
+		if err := Compare(der, Parse); err != nil {
+			t.Errorf("%s: parser disagrees with itself: %v", name, err)
+		}
+	}
+	if accepted == 0 {
+		t.Error("no seed in the corpus was accepted by crypto/x509")
+	}
+}
+
+func TestDifferential(t *testing.T) {
+	if Reference == nil {
+		t.Skip("no reference implementation registered")
+	}
+	for name, der := range readCorpus(t) {
+		if err := Compare(der, Reference); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestCompareReportsDivergence(t *testing.T) {
+	der := readCorpus(t)["server.der"]
+
+	// An implementation that ignores extended key usages.
+	other := func(der []byte) (*Summary, error) {
+		s, err := Parse(der)
+		if err != nil {
+			return nil, err
+		}
+		s.ExtKeyUsage = nil
+		return s, nil
+	}
+	err := Compare(der, other)
+	de, ok := err.(*DivergenceError)
+	if !ok {
+		t.Fatalf("got %v, want a *DivergenceError", err)
+	}
+	if len(de.Differences) != 1 || de.Differences[0].Field != "ExtKeyUsage" {
+		t.Errorf("unexpected differences: %v", de.Differences)
+	}
+
+	// An implementation that accepts everything.
+	lenient := func([]byte) (*Summary, error) { return new(Summary), nil }
+	if err, ok := Compare([]byte{0x30, 0x00}, lenient).(*DivergenceError); !ok || err.GoErr == nil {
+		t.Errorf("acceptance divergence not reported, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// TimedChain is a chain returned by VerifyDuring, together with the part of
+// the requested interval during which it is valid.
+type TimedChain struct {
+	Certificates []*Certificate
+
+	// NotBefore and NotAfter bound the times within the requested interval
+	// at which all the certificates of the chain are valid, taking
+	// VerifyOptions.ClockSkew into account.
+	NotBefore, NotAfter time.Time
+}
+
+// VerifyDuring is like Verify, but checks whether c was valid at some time in
+// the interval from notBefore to notAfter, rather than at
+// opts.CurrentTime, which is ignored. This is needed to validate long-term
+// signatures, when it is only known that a document was signed during an
+// interval, for example between two timestamps.
+//
+// It returns every chain that is valid at some time in the interval, in the
+// order of the time from which they are valid, with the sub-interval during
+// which each is valid. The union of those sub-intervals is the set of times
+// at which c was valid. If no chain is valid at any time in the interval, it
+// returns the error of Verify at notBefore.
+//
+// Chains are built at notBefore and at every time in the interval at which c
+// or a certificate of opts.Roots, opts.Intermediates or
+// opts.TrustedIntermediates becomes valid, since a chain that is valid at
+// some time is valid from the latest of those times. If opts.Roots is nil on
+// Windows, the times at which the system roots become valid are not known.
+func (c *Certificate) VerifyDuring(opts VerifyOptions, notBefore, notAfter time.Time) ([]TimedChain, error) {
+	if notAfter.Before(notBefore) {
+		return nil, errors.New("x509: VerifyDuring interval ends before it starts")
+	}
+
+	roots := opts.Roots
+	if roots == nil && runtime.GOOS != "windows" {
+		roots = systemRootsPool()
+	}
+	times := []time.Time{notBefore}
+	addTime := func(cert *Certificate) {
+		if t := cert.NotBefore.Add(-opts.ClockSkew); t.After(notBefore) && !t.After(notAfter) {
+			times = append(times, t)
+		}
+	}
+	addTime(c)
+	for _, pool := range []*CertPool{roots, opts.Intermediates, opts.TrustedIntermediates} {
+		if pool == nil {
+			continue
+		}
+		for _, cert := range pool.certificates() {
+			addTime(cert)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	var (
+		timed    []TimedChain
+		firstErr error
+	)
+	for i, t := range times {
+		if i > 0 && t.Equal(times[i-1]) {
+			continue
+		}
+		opts.CurrentTime = t
+		chains, err := c.Verify(opts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+	NextChain:
+		for _, chain := range chains {
+			for _, seen := range timed {
+				if equalChains(seen.Certificates, chain) {
+					continue NextChain
+				}
+			}
+			tc := TimedChain{Certificates: chain, NotBefore: notBefore, NotAfter: notAfter}
+			for _, cert := range chain {
+				if start := cert.NotBefore.Add(-opts.ClockSkew); start.After(tc.NotBefore) {
+					tc.NotBefore = start
+				}
+				if end := cert.NotAfter.Add(opts.ClockSkew); end.Before(tc.NotAfter) {
+					tc.NotAfter = end
+				}
+			}
+			timed = append(timed, tc)
+		}
+	}
+
+	if len(timed) == 0 {
+		return nil, firstErr
+	}
+	return timed, nil
+}
+
+// equalChains reports whether a and b consist of the same certificates.
+func equalChains(a, b []*Certificate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
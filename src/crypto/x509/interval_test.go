@@ -0,0 +1,108 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifyDuring(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	day := 24 * time.Hour
+
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	serial := int64(0)
+	issue := func(cn string, isCA bool, notBefore, notAfter time.Time, key *ecdsa.PrivateKey, parent *Certificate, parentKey *ecdsa.PrivateKey) *Certificate {
+		serial++
+		template := &Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			KeyUsage:              KeyUsageCertSign | KeyUsageDigitalSignature,
+			ExtKeyUsage:           []ExtKeyUsage{ExtKeyUsageServerAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	rootKey, intermediateKey := newKey(), newKey()
+	root := issue("Root", true, now.Add(-10*day), now.Add(10*day), rootKey, nil, nil)
+	// The intermediate expired and was reissued two days later.
+	old := issue("Intermediate", true, now.Add(-10*day), now.Add(-5*day), intermediateKey, root, rootKey)
+	reissued := issue("Intermediate", true, now.Add(-3*day), now.Add(5*day), intermediateKey, root, rootKey)
+	leaf := issue("Leaf", false, now.Add(-9*day), now.Add(2*day), newKey(), old, intermediateKey)
+
+	opts := VerifyOptions{Roots: NewCertPool(), Intermediates: NewCertPool()}
+	opts.Roots.AddCert(root)
+	opts.Intermediates.AddCert(old)
+	opts.Intermediates.AddCert(reissued)
+
+	timed, err := leaf.VerifyDuring(opts, now.Add(-8*day), now.Add(day))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		intermediate        *Certificate
+		notBefore, notAfter time.Time
+	}{
+		{old, now.Add(-8 * day), now.Add(-5 * day)},
+		{reissued, now.Add(-3 * day), now.Add(day)},
+	}
+	if len(timed) != len(want) {
+		t.Fatalf("got %d chains, want %d", len(timed), len(want))
+	}
+	for i, w := range want {
+		tc := timed[i]
+		if len(tc.Certificates) != 3 || tc.Certificates[1] != w.intermediate {
+			t.Errorf("chain %d: got %s", i, chainToDebugString(tc.Certificates))
+		}
+		if !tc.NotBefore.Equal(w.notBefore) || !tc.NotAfter.Equal(w.notAfter) {
+			t.Errorf("chain %d: valid from %v to %v, want from %v to %v", i, tc.NotBefore, tc.NotAfter, w.notBefore, w.notAfter)
+		}
+	}
+
+	// No chain is valid between the expiry and the reissuance of the
+	// intermediate.
+	_, err = leaf.VerifyDuring(opts, now.Add(-5*day).Add(time.Minute), now.Add(-3*day).Add(-time.Minute))
+	if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != Expired {
+		t.Errorf("got error %v, want Expired", err)
+	}
+
+	// Unless the clock skew covers the gap.
+	opts.ClockSkew = day + time.Hour
+	timed, err = leaf.VerifyDuring(opts, now.Add(-5*day).Add(time.Minute), now.Add(-3*day).Add(-time.Minute))
+	if err != nil || len(timed) != 2 {
+		t.Errorf("with clock skew: got %d chains, error %v", len(timed), err)
+	}
+
+	if _, err := leaf.VerifyDuring(opts, now, now.Add(-time.Second)); err == nil {
+		t.Error("reversed interval was accepted")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// IssuanceOptions contains parameters for CreateCertificateWithOptions,
+// CreateCertificateVerified and CheckIssuance.
+type IssuanceOptions struct {
+	// CurrentTime is the time at which the issuing chain must be valid. If
+	// zero, the current time is used. CreateCertificateWithOptions ignores
+	// it.
+	CurrentTime time.Time
+
+	// InsecureAllowNonPositiveSerial allows a zero or negative
+	// SerialNumber in the template, which RFC 5280, Section 4.1.2.2
+	// forbids. It is meant only for generating test fixtures.
+	InsecureAllowNonPositiveSerial bool
+
+	// SerialInUse, if not nil, is called with the serial number of the new
+	// certificate before it is signed. If it returns true, ErrSerialInUse
+	// is returned. It lets CA implementations detect serial number
+	// collisions.
+	SerialInUse func(serial *big.Int) bool
+}
+
+// CreateCertificateWithOptions is like CreateCertificate, but applies the
+// serial number policy of opts.
+func CreateCertificateWithOptions(rand io.Reader, template, parent *Certificate, pub, priv interface{}, opts IssuanceOptions) ([]byte, error) {
+	var constrainingCAs []*Certificate
+	if template.EnforceNameConstraints {
+		constrainingCAs = []*Certificate{parent}
+	}
+	return createCertificate(rand, template, parent, pub, priv, constrainingCAs, opts)
+}
+
+// CreateCertificateVerified is like CreateCertificate, but first validates
+// the chain that issues the new certificate, so that CA software cannot
+// accidentally issue from an expired or non-CA certificate. chain starts with
+// the issuing certificate, the parent of CreateCertificate, and continues
+// towards the root. It need not include the root. The new certificate is for
+// template.PublicKey, and is signed by signer, which must hold the key of
+// chain[0].
+//
+// Every certificate of chain must be a CA certificate, as reported by
+// IsCACert, be valid at opts.CurrentTime, and be issued and signed by the
+// next one. The path length constraints of chain must leave room for the new
+// certificate: if it is a CA certificate, a CA with a MaxPathLen of zero
+// cannot issue it. Whether the last certificate of chain is trusted is not
+// checked. If template.EnforceNameConstraints is set, the names of the new
+// certificate are checked against the name constraints of every certificate
+// of chain.
+//
+// The serial number policy of opts is applied as by
+// CreateCertificateWithOptions. Violations are reported with a
+// CertificateInvalidError for the offending certificate of chain, except for
+// bad signatures.
+func CreateCertificateVerified(rand io.Reader, template *Certificate, chain []*Certificate, signer crypto.Signer, opts IssuanceOptions) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("x509: no issuing certificate")
+	}
+	if !PublicKeysEqual(signer.Public(), chain[0].PublicKey) {
+		return nil, errors.New("x509: signer key does not match the issuing certificate")
+	}
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if errs := checkIssuingChain(template, chain, now); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	var constrainingCAs []*Certificate
+	if template.EnforceNameConstraints {
+		constrainingCAs = chain
+	}
+	return createCertificate(rand, template, chain[0], template.PublicKey, signer, constrainingCAs, opts)
+}
+
+// checkIssuingChain returns the violations of the rules of
+// CreateCertificateVerified by chain, for issuing template at now, in chain
+// order.
+func checkIssuingChain(template *Certificate, chain []*Certificate, now time.Time) []error {
+	var errs []error
+	newCA := template.BasicConstraintsValid && template.IsCA
+	for i, ca := range chain {
+		if !ca.IsCACert() {
+			errs = append(errs, notAuthorizedToSign(ca))
+		}
+		if now.Before(ca.NotBefore) {
+			errs = append(errs, CertificateInvalidError{ca, Expired, fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), ca.NotBefore.Format(time.RFC3339))})
+		}
+		if now.After(ca.NotAfter) {
+			errs = append(errs, CertificateInvalidError{ca, Expired, fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), ca.NotAfter.Format(time.RFC3339))})
+		}
+		// As in Verify, the CAs between ca and the leaf count towards its
+		// path length constraint, and a new CA adds one.
+		intermediates := i
+		if newCA {
+			intermediates++
+		}
+		if ca.BasicConstraintsValid && ca.MaxPathLen >= 0 && intermediates > ca.MaxPathLen {
+			errs = append(errs, CertificateInvalidError{ca, TooManyIntermediates, ""})
+		}
+		if i+1 < len(chain) {
+			if !bytes.Equal(ca.RawIssuer, chain[i+1].RawSubject) {
+				errs = append(errs, CertificateInvalidError{ca, NameMismatch, ""})
+			} else if err := ca.CheckSignatureFrom(chain[i+1]); err != nil {
+				errs = append(errs, fmt.Errorf("x509: issuing chain is broken at %q: %v", ca.Subject, err))
+			}
+		}
+	}
+	return errs
+}
+
+// CheckIssuance reports whether template could be issued under chain,
+// without signing anything, for example to decide whether to approve a
+// certificate signing request. chain is as for CreateCertificateVerified.
+//
+// CheckIssuance makes the checks of CreateCertificateVerified, and checks the
+// names of template against the name constraints of chain regardless of
+// template.EnforceNameConstraints. It also checks that the extended key
+// usages of template are allowed by every certificate of chain, as Verify
+// requires, and that the validity period of template is within that of every
+// certificate of chain, which Verify does not require but a certificate
+// outliving its issuer can't be verified for its whole lifetime.
+//
+// CheckIssuance returns every violation found, or nil if template can be
+// issued. Violations are reported with a CertificateInvalidError for the
+// offending certificate of chain, except for bad signatures and malformed
+// templates.
+func CheckIssuance(template *Certificate, chain []*Certificate, opts IssuanceOptions) []error {
+	if len(chain) == 0 {
+		return []error{errors.New("x509: no issuing certificate")}
+	}
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	errs := checkIssuingChain(template, chain, now)
+
+	for _, ca := range chain {
+		for _, usage := range template.ExtKeyUsage {
+			if !checkChainForKeyUsage([]*Certificate{ca}, []ExtKeyUsage{usage}) {
+				errs = append(errs, CertificateInvalidError{ca, CANotAuthorizedForExtKeyUsage, extKeyUsageName(usage)})
+			}
+		}
+		if detail := validityNestingViolation(template, ca); detail != "" {
+			errs = append(errs, CertificateInvalidError{ca, ValidityNotNested, detail})
+		}
+	}
+
+	subject, err := subjectBytes(template)
+	if err != nil {
+		return append(errs, err)
+	}
+	if bytes.Equal(subject, chain[0].RawSubject) {
+		// Self-issued certificates are exempt from name constraints,
+		// RFC 5280, Section 4.2.1.10.
+		return errs
+	}
+	extensions, err := buildExtensions(template, bytes.Equal(subject, emptyASN1Subject), nil, nil)
+	if err != nil {
+		return append(errs, err)
+	}
+	for _, ca := range chain {
+		if err := checkIssuedNames([]*Certificate{ca}, subject, extensions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validityNestingViolation returns a description of how the validity period
+// of c extends beyond that of issuer, or "" if it does not.
+func validityNestingViolation(c, issuer *Certificate) string {
+	switch {
+	case c.NotBefore.Before(issuer.NotBefore):
+		return fmt.Sprintf("notBefore %s is before the notBefore %s of %q", c.NotBefore.UTC().Format(time.RFC3339), issuer.NotBefore.UTC().Format(time.RFC3339), issuer.Subject)
+	case c.NotAfter.After(issuer.NotAfter):
+		return fmt.Sprintf("notAfter %s is after the notAfter %s of %q", c.NotAfter.UTC().Format(time.RFC3339), issuer.NotAfter.UTC().Format(time.RFC3339), issuer.Subject)
+	}
+	return ""
+}
+
+// extKeyUsageName returns the name of u used by VerificationPolicy.
+func extKeyUsageName(u ExtKeyUsage) string {
+	for _, n := range extKeyUsageNames {
+		if n.extKeyUsage == u {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("ExtKeyUsage(%d)", int(u))
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCreateCertificateVerified(t *testing.T) {
+	now := time.Now()
+	newCA := func(cn string, parent *Certificate, parentKey crypto.Signer, edit func(*Certificate)) (*Certificate, crypto.Signer) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			KeyUsage:              KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLen:            -1,
+		}
+		if edit != nil {
+			edit(template)
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	root, rootKey := newCA("Root", nil, nil, func(c *Certificate) { c.MaxPathLen = 1 })
+	inter, interKey := newCA("Intermediate", root, rootKey, func(c *Certificate) {
+		c.PermittedDNSDomains = []string{"example.com"}
+	})
+	zero, zeroKey := newCA("Zero", root, rootKey, func(c *Certificate) { c.MaxPathLenZero, c.MaxPathLen = true, 0 })
+	expired, expiredKey := newCA("Expired", root, rootKey, func(c *Certificate) { c.NotAfter = now.Add(-time.Minute) })
+	other, otherKey := newCA("Other root", nil, nil, nil)
+	notCA, notCAKey := newCA("Not a CA", root, rootKey, func(c *Certificate) { c.IsCA = false })
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := func(dns string, isCA bool) *Certificate {
+		return &Certificate{
+			SerialNumber:           big.NewInt(2),
+			Subject:                pkix.Name{CommonName: "new"},
+			DNSNames:               []string{dns},
+			NotBefore:              now,
+			NotAfter:               now.Add(time.Hour),
+			BasicConstraintsValid:  isCA,
+			IsCA:                   isCA,
+			EnforceNameConstraints: true,
+			PublicKey:              &leafKey.PublicKey,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		template *Certificate
+		chain    []*Certificate
+		signer   crypto.Signer
+		opts     IssuanceOptions
+		reason   InvalidReason
+		errCert  *Certificate
+	}{
+		{"leaf", leaf("www.example.com", false), []*Certificate{inter, root}, interKey, IssuanceOptions{}, -1, nil},
+		{"without root", leaf("www.example.com", false), []*Certificate{inter}, interKey, IssuanceOptions{}, -1, nil},
+		{"constrained name", leaf("www.example.org", false), []*Certificate{inter, root}, interKey, IssuanceOptions{}, CANotAuthorizedForThisName, inter},
+		{"expired", leaf("www.example.com", false), []*Certificate{expired, root}, expiredKey, IssuanceOptions{}, Expired, expired},
+		{"future time", leaf("www.example.com", false), []*Certificate{inter, root}, interKey, IssuanceOptions{CurrentTime: now.Add(2 * time.Hour)}, Expired, inter},
+		{"not a CA", leaf("www.example.com", false), []*Certificate{notCA, root}, notCAKey, IssuanceOptions{}, NotAuthorizedToSign, notCA},
+		{"leaf from zero path length", leaf("www.example.com", false), []*Certificate{zero, root}, zeroKey, IssuanceOptions{}, -1, nil},
+		{"CA from zero path length", leaf("www.example.com", true), []*Certificate{zero, root}, zeroKey, IssuanceOptions{}, TooManyIntermediates, zero},
+		{"CA under path length one", leaf("www.example.com", true), []*Certificate{inter, root}, interKey, IssuanceOptions{}, TooManyIntermediates, root},
+		{"wrong issuer", leaf("www.example.com", false), []*Certificate{inter, other}, interKey, IssuanceOptions{}, NameMismatch, inter},
+	}
+	for _, tt := range tests {
+		der, err := CreateCertificateVerified(rand.Reader, tt.template, tt.chain, tt.signer, tt.opts)
+		if tt.reason == -1 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			cert, err := ParseCertificate(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cert.CheckSignatureFrom(tt.chain[0]); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if e, ok := err.(CertificateInvalidError); !ok || e.Reason != tt.reason || e.Cert != tt.errCert {
+			t.Errorf("%s: got error %v, want reason %d for %v", tt.name, err, tt.reason, tt.errCert.Subject)
+		}
+	}
+
+	if _, err := CreateCertificateVerified(rand.Reader, leaf("www.example.com", false), []*Certificate{inter, root}, otherKey, IssuanceOptions{}); err == nil {
+		t.Error("signer with the wrong key was accepted")
+	}
+	if _, err := CreateCertificateVerified(rand.Reader, leaf("www.example.com", false), nil, interKey, IssuanceOptions{}); err == nil {
+		t.Error("empty chain was accepted")
+	}
+	inUse := IssuanceOptions{SerialInUse: func(*big.Int) bool { return true }}
+	if _, err := CreateCertificateVerified(rand.Reader, leaf("www.example.com", false), []*Certificate{inter, root}, interKey, inUse); err != ErrSerialInUse {
+		t.Errorf("serial in use: got %v, want ErrSerialInUse", err)
+	}
+}
+
+func TestCheckIssuance(t *testing.T) {
+	now := time.Now()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(12 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		ExtKeyUsage:           []ExtKeyUsage{ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		PermittedDNSDomains:   []string{"example.com"},
+	}, root, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Certificate{inter, root}
+
+	leaf := func(edit func(*Certificate)) *Certificate {
+		c := &Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      pkix.Name{CommonName: "www.example.com"},
+			DNSNames:     []string{"www.example.com"},
+			NotBefore:    now,
+			NotAfter:     now.Add(time.Hour),
+			ExtKeyUsage:  []ExtKeyUsage{ExtKeyUsageServerAuth},
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+
+	if errs := CheckIssuance(leaf(nil), chain, IssuanceOptions{}); errs != nil {
+		t.Errorf("valid template: %v", errs)
+	}
+
+	type violation struct {
+		cert   *Certificate
+		reason InvalidReason
+	}
+	tests := []struct {
+		name string
+		edit func(*Certificate)
+		want []violation
+	}{
+		{"name", func(c *Certificate) { c.DNSNames = []string{"www.example.org"} }, []violation{{inter, CANotAuthorizedForThisName}}},
+		{"usage", func(c *Certificate) { c.ExtKeyUsage = append(c.ExtKeyUsage, ExtKeyUsageClientAuth) }, []violation{{inter, CANotAuthorizedForExtKeyUsage}}},
+		{"outlives intermediate", func(c *Certificate) { c.NotAfter = now.Add(13 * time.Hour) }, []violation{{inter, ValidityNotNested}}},
+		{"outlives root", func(c *Certificate) { c.NotAfter = now.Add(25 * time.Hour) }, []violation{{inter, ValidityNotNested}, {root, ValidityNotNested}}},
+		{"backdated", func(c *Certificate) { c.NotBefore = now.Add(-2 * time.Hour) }, []violation{{inter, ValidityNotNested}, {root, ValidityNotNested}}},
+		{"CA under zero path length", func(c *Certificate) { c.BasicConstraintsValid, c.IsCA = true, true }, []violation{{inter, TooManyIntermediates}}},
+		{"everything", func(c *Certificate) {
+			c.DNSNames = []string{"www.example.org"}
+			c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageCodeSigning}
+			c.NotAfter = now.Add(13 * time.Hour)
+		}, []violation{{inter, CANotAuthorizedForExtKeyUsage}, {inter, ValidityNotNested}, {inter, CANotAuthorizedForThisName}}},
+	}
+	for _, tt := range tests {
+		errs := CheckIssuance(leaf(tt.edit), chain, IssuanceOptions{})
+		var got []violation
+		for _, err := range errs {
+			e, ok := err.(CertificateInvalidError)
+			if !ok {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+				continue
+			}
+			got = append(got, violation{e.Cert, e.Reason})
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %d violations", tt.name, errs, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: violation %d is %v, want reason %d for %v", tt.name, i, errs[i], tt.want[i].reason, tt.want[i].cert.Subject)
+			}
+		}
+	}
+
+	if errs := CheckIssuance(leaf(nil), nil, IssuanceOptions{}); len(errs) != 1 {
+		t.Errorf("empty chain: got %v", errs)
+	}
+}
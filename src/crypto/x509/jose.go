@@ -0,0 +1,162 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// This file implements the certificate header parameters of JOSE, RFC 7515,
+// Sections 4.1.6 to 4.1.8, and of COSE, RFC 9360, along with the other
+// certificate fingerprints.
+
+// EncodeX5C returns the JOSE "x5c" value for chain, which should start with
+// the leaf: the standard (not URL-safe) base64 encodings of the DER
+// certificates.
+func EncodeX5C(chain []*Certificate) []string {
+	x5c := make([]string, len(chain))
+	for i, cert := range chain {
+		x5c[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	return x5c
+}
+
+// ParseX5C parses a JOSE "x5c" value.
+func ParseX5C(x5c []string) ([]*Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("x509: empty x5c")
+	}
+	certs := make([]*Certificate, len(x5c))
+	for i, s := range x5c {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("x509: invalid base64 in x5c entry %d: %v", i, err)
+		}
+		if certs[i], err = ParseCertificate(der); err != nil {
+			return nil, err
+		}
+	}
+	return certs, nil
+}
+
+// VerifyX5C parses a JOSE "x5c" value and verifies its first certificate with
+// opts, using the others as intermediates in addition to opts.Intermediates.
+// It returns the verified chains, as Verify does.
+func VerifyX5C(x5c []string, opts VerifyOptions) ([][]*Certificate, error) {
+	certs, err := ParseX5C(x5c)
+	if err != nil {
+		return nil, err
+	}
+	return verifyPresentedChain(certs, opts)
+}
+
+// VerifyX5Chain parses a COSE "x5chain" value, the DER certificates starting
+// with the leaf, and verifies it like VerifyX5C.
+func VerifyX5Chain(x5chain [][]byte, opts VerifyOptions) ([][]*Certificate, error) {
+	if len(x5chain) == 0 {
+		return nil, errors.New("x509: empty x5chain")
+	}
+	certs := make([]*Certificate, len(x5chain))
+	for i, der := range x5chain {
+		var err error
+		if certs[i], err = ParseCertificate(der); err != nil {
+			return nil, err
+		}
+	}
+	return verifyPresentedChain(certs, opts)
+}
+
+func verifyPresentedChain(certs []*Certificate, opts VerifyOptions) ([][]*Certificate, error) {
+	if len(certs) > 1 {
+		if opts.Intermediates == nil {
+			opts.Intermediates = NewCertPool()
+		} else {
+			opts.Intermediates = opts.Intermediates.copy()
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+	return certs[0].Verify(opts)
+}
+
+// X5T returns the JOSE "x5t" value of cert, the base64url encoded SHA-1
+// hash of its DER encoding.
+func X5T(cert *Certificate) string {
+	h := sha1.Sum(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// X5TS256 returns the JOSE "x5t#S256" value of cert, the base64url encoded
+// SHA-256 hash of its DER encoding.
+func X5TS256(cert *Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// COSE algorithm identifiers of the hash functions that COSECertHash
+// supports, from the IANA COSE Algorithms registry.
+const (
+	COSEAlgSHA256_64  = -15 // SHA-256 truncated to 64 bits
+	COSEAlgSHA256     = -16
+	COSEAlgSHA512_256 = -17
+	COSEAlgSHA384     = -43
+	COSEAlgSHA512     = -44
+)
+
+// COSECertHash returns the hashValue of the COSE_CertHash of cert, as used by
+// the COSE "x5t" header parameter, for the COSE hash algorithm identifier
+// alg. It returns an error for unsupported algorithms.
+func COSECertHash(cert *Certificate, alg int) ([]byte, error) {
+	switch alg {
+	case COSEAlgSHA256_64:
+		h := sha256.Sum256(cert.Raw)
+		return h[:8], nil
+	case COSEAlgSHA256:
+		h := sha256.Sum256(cert.Raw)
+		return h[:], nil
+	case COSEAlgSHA512_256:
+		h := sha512.Sum512_256(cert.Raw)
+		return h[:], nil
+	case COSEAlgSHA384:
+		h := sha512.Sum384(cert.Raw)
+		return h[:], nil
+	case COSEAlgSHA512:
+		h := sha512.Sum512(cert.Raw)
+		return h[:], nil
+	}
+	return nil, fmt.Errorf("x509: unsupported COSE hash algorithm %d", alg)
+}
+
+// SPKISHA256 returns the SHA-256 hash of the DER encoded
+// SubjectPublicKeyInfo of cert. It is the value of PinnedKeys entries and
+// the issuer identifier of CRLite.
+func SPKISHA256(cert *Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// SPKISubjectSHA256 returns the SHA-256 hash of the DER encoded
+// SubjectPublicKeyInfo of cert followed by its DER encoded subject. It
+// identifies a key and subject pair, so that renewals, reissuances and cross
+// signatures of a CA certificate share it, which makes it a deduplication
+// key for Certificate Transparency and CRLite tooling. PeekSPKISubjectSHA256
+// computes the same hash from a DER certificate without parsing it.
+func SPKISubjectSHA256(cert *Certificate) [sha256.Size]byte {
+	return spkiSubjectSHA256(cert.RawSubjectPublicKeyInfo, cert.RawSubject)
+}
+
+func spkiSubjectSHA256(spki, subject []byte) [sha256.Size]byte {
+	var out [sha256.Size]byte
+	h := sha256.New()
+	h.Write(spki)
+	h.Write(subject)
+	h.Sum(out[:0])
+	return out
+}
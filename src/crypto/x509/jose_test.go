@@ -0,0 +1,123 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestX5C(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	x5c := EncodeX5C([]*Certificate{leaf, intermediate})
+	if want := base64.StdEncoding.EncodeToString(leaf.Raw); x5c[0] != want {
+		t.Errorf("x5c[0] = %q, want %q", x5c[0], want)
+	}
+	chains, err := VerifyX5C(x5c, VerifyOptions{Roots: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 3 || !chains[0][1].Equal(intermediate) {
+		t.Errorf("got chains %v", chains)
+	}
+
+	// The intermediates of opts are used too, and not modified.
+	intermediates := NewCertPool()
+	intermediates.AddCert(intermediate)
+	if _, err := VerifyX5C(x5c[:1], VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("x5c with the intermediate in opts: %v", err)
+	}
+	empty := NewCertPool()
+	if _, err := VerifyX5C(x5c, VerifyOptions{Roots: roots, Intermediates: empty}); err != nil || len(empty.certs) != 0 {
+		t.Errorf("VerifyX5C returned %v and added %d certificates to opts.Intermediates", err, len(empty.certs))
+	}
+	if _, err := VerifyX5C(x5c[:1], VerifyOptions{Roots: roots}); err == nil {
+		t.Error("x5c without the intermediate verified")
+	}
+	if _, err := VerifyX5Chain([][]byte{leaf.Raw, intermediate.Raw}, VerifyOptions{Roots: roots}); err != nil {
+		t.Errorf("VerifyX5Chain: %v", err)
+	}
+
+	for _, bad := range [][]string{nil, {"not base64!"}, {base64.StdEncoding.EncodeToString([]byte("not a certificate"))}} {
+		if _, err := ParseX5C(bad); err == nil {
+			t.Errorf("ParseX5C(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestCertificateThumbprints(t *testing.T) {
+	cert, _, err := generateCert("Leaf", false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(cert.Raw)
+	if got, want := X5TS256(cert), base64.RawURLEncoding.EncodeToString(h[:]); got != want {
+		t.Errorf("X5TS256 = %q, want %q", got, want)
+	}
+	if got := X5T(cert); len(got) != 27 {
+		t.Errorf("X5T = %q, want 27 characters", got)
+	}
+
+	for alg, size := range map[int]int{
+		COSEAlgSHA256_64:  8,
+		COSEAlgSHA256:     32,
+		COSEAlgSHA512_256: 32,
+		COSEAlgSHA384:     48,
+		COSEAlgSHA512:     64,
+	} {
+		hash, err := COSECertHash(cert, alg)
+		if err != nil || len(hash) != size {
+			t.Errorf("COSECertHash(%d) = %x, %v, want %d bytes", alg, hash, err, size)
+		}
+	}
+	if hash, _ := COSECertHash(cert, COSEAlgSHA256_64); !bytes.Equal(hash, h[:8]) {
+		t.Errorf("SHA-256/64 hash %s is not a prefix of %s", hex.EncodeToString(hash), hex.EncodeToString(h[:]))
+	}
+	if _, err := COSECertHash(cert, -14); err == nil {
+		t.Error("COSECertHash accepted SHA-1")
+	}
+
+	if got, want := SPKISHA256(cert), sha256.Sum256(cert.RawSubjectPublicKeyInfo); got != want {
+		t.Errorf("SPKISHA256 = %x, want %x", got, want)
+	}
+	want := sha256.Sum256(append(append([]byte{}, cert.RawSubjectPublicKeyInfo...), cert.RawSubject...))
+	if got := SPKISubjectSHA256(cert); got != want {
+		t.Errorf("SPKISubjectSHA256 = %x, want %x", got, want)
+	}
+	// A certificate for the same key and subject from another issuer.
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, TemplateFromCertificate(cert), root, cert.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SPKISubjectSHA256(other) != want || X5TS256(other) == X5TS256(cert) {
+		t.Error("certificates for the same key and subject have different SPKISubjectSHA256 hashes")
+	}
+}
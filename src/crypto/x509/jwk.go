@@ -0,0 +1,195 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// A JWK is a public JSON Web Key, RFC 7517. Its struct tags follow
+// encoding/json conventions, so it can be marshaled and unmarshaled with
+// that package. Private key members are not supported.
+type JWK struct {
+	// KeyType is "RSA", "EC" or "OKP".
+	KeyType string `json:"kty"`
+
+	// Use, KeyOps, Algorithm and KeyID are carried along, but are not
+	// interpreted by this package.
+	Use       string   `json:"use,omitempty"`
+	KeyOps    []string `json:"key_ops,omitempty"`
+	Algorithm string   `json:"alg,omitempty"`
+	KeyID     string   `json:"kid,omitempty"`
+
+	// Curve is "P-256", "P-384" or "P-521" for EC keys and "Ed25519" for
+	// OKP keys.
+	Curve string `json:"crv,omitempty"`
+	// X and Y are the base64url encoded coordinates of an EC key, or X the
+	// base64url encoded OKP key.
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+
+	// N and E are the base64url encoded modulus and exponent of an RSA key.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// X5C, X5T and X5TS256 are the certificate chain of the key and the
+	// thumbprints of its first certificate, as returned by EncodeX5C, X5T
+	// and X5TS256.
+	X5C     []string `json:"x5c,omitempty"`
+	X5T     string   `json:"x5t,omitempty"`
+	X5TS256 string   `json:"x5t#S256,omitempty"`
+}
+
+var jwkCurves = []struct {
+	name  string
+	curve elliptic.Curve
+}{
+	{"P-256", elliptic.P256()},
+	{"P-384", elliptic.P384()},
+	{"P-521", elliptic.P521()},
+}
+
+var jwkEncoding = base64.RawURLEncoding
+
+// PublicKeyToJWK returns the JWK of pub, which must be an *rsa.PublicKey, an
+// *ecdsa.PublicKey on one of the NIST curves, or an ed25519.PublicKey.
+func PublicKeyToJWK(pub crypto.PublicKey) (*JWK, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			N:       jwkEncoding.EncodeToString(pub.N.Bytes()),
+			E:       jwkEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		for _, c := range jwkCurves {
+			if c.curve != pub.Curve {
+				continue
+			}
+			// RFC 7518, Section 6.2.1.2: the coordinates have the full
+			// size of the field, including leading zeros.
+			size := (c.curve.Params().BitSize + 7) / 8
+			return &JWK{
+				KeyType: "EC",
+				Curve:   c.name,
+				X:       jwkEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+				Y:       jwkEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+			}, nil
+		}
+		return nil, errors.New("x509: unsupported elliptic curve for JWK")
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       jwkEncoding.EncodeToString(pub),
+		}, nil
+	}
+	return nil, fmt.Errorf("x509: unsupported public key type for JWK: %T", pub)
+}
+
+// CertificateToJWK returns the JWK of the public key of chain[0], with the
+// chain, which should start with the leaf, and the thumbprints of its first
+// certificate.
+func CertificateToJWK(chain []*Certificate) (*JWK, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("x509: empty certificate chain")
+	}
+	jwk, err := PublicKeyToJWK(chain[0].PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	jwk.X5C = EncodeX5C(chain)
+	jwk.X5T = X5T(chain[0])
+	jwk.X5TS256 = X5TS256(chain[0])
+	return jwk, nil
+}
+
+// JWKToPublicKey returns the public key of jwk, as an *rsa.PublicKey, an
+// *ecdsa.PublicKey or an ed25519.PublicKey. If jwk has X5C, X5T or X5TS256
+// members, they are checked to match the key, as RFC 7517 requires, but the
+// certificates are not verified; see VerifyX5C.
+func JWKToPublicKey(jwk *JWK) (crypto.PublicKey, error) {
+	pub, err := jwkPublicKey(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jwk.X5C) > 0 {
+		certs, err := ParseX5C(jwk.X5C)
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := certs[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(pub) {
+			return nil, errors.New("x509: JWK key does not match the key of its x5c certificate")
+		}
+		if jwk.X5T != "" && jwk.X5T != X5T(certs[0]) ||
+			jwk.X5TS256 != "" && jwk.X5TS256 != X5TS256(certs[0]) {
+			return nil, errors.New("x509: JWK thumbprint does not match its x5c certificate")
+		}
+	}
+	return pub, nil
+}
+
+func jwkPublicKey(jwk *JWK) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := jwkEncoding.DecodeString(jwk.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("x509: invalid RSA modulus in JWK")
+		}
+		e, err := jwkEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 {
+			return nil, errors.New("x509: invalid RSA exponent in JWK")
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("x509: RSA exponent in JWK out of range")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if pub.N.Sign() <= 0 {
+			return nil, errors.New("x509: RSA modulus in JWK is not positive")
+		}
+		return pub, nil
+
+	case "EC":
+		for _, c := range jwkCurves {
+			if c.name != jwk.Curve {
+				continue
+			}
+			size := (c.curve.Params().BitSize + 7) / 8
+			x, errX := jwkEncoding.DecodeString(jwk.X)
+			y, errY := jwkEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil || len(x) != size || len(y) != size {
+				return nil, errors.New("x509: invalid EC coordinates in JWK")
+			}
+			pub := &ecdsa.PublicKey{Curve: c.curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			p := c.curve.Params().P
+			if pub.X.Cmp(p) >= 0 || pub.Y.Cmp(p) >= 0 || !c.curve.IsOnCurve(pub.X, pub.Y) {
+				return nil, errors.New("x509: EC point in JWK is not on the curve")
+			}
+			return pub, nil
+		}
+		return nil, fmt.Errorf("x509: unsupported EC curve %q in JWK", jwk.Curve)
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("x509: unsupported OKP curve %q in JWK", jwk.Curve)
+		}
+		x, err := jwkEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("x509: invalid Ed25519 key in JWK")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("x509: unsupported JWK key type %q", jwk.KeyType)
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJWKRoundTrip(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pub := range []crypto.PublicKey{&testPrivateKey.PublicKey, &p384.PublicKey, &p521.PublicKey, ed} {
+		jwk, err := PublicKeyToJWK(pub)
+		if err != nil {
+			t.Errorf("%T: %v", pub, err)
+			continue
+		}
+		data, err := json.Marshal(jwk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded JWK
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		got, err := JWKToPublicKey(&decoded)
+		if err != nil {
+			t.Errorf("%s: %v", data, err)
+			continue
+		}
+		if !got.(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+			t.Errorf("%s: round trip returned a different key", data)
+		}
+	}
+}
+
+func TestJWKRFCExamples(t *testing.T) {
+	for _, data := range []string{
+		// RFC 7517, Appendix A.1.
+		`{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM","use":"enc","kid":"1"}`,
+		`{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`,
+		// RFC 8037, Appendix A.2.
+		`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+	} {
+		var jwk JWK
+		if err := json.Unmarshal([]byte(data), &jwk); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := JWKToPublicKey(&jwk)
+		if err != nil {
+			t.Errorf("%s: %v", data, err)
+			continue
+		}
+		back, err := PublicKeyToJWK(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back.Use, back.Algorithm, back.KeyID = jwk.Use, jwk.Algorithm, jwk.KeyID
+		if !reflect.DeepEqual(back, &jwk) {
+			t.Errorf("got %+v, want %+v", back, &jwk)
+		}
+	}
+}
+
+func TestJWKX5C(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk, err := CertificateToJWK([]*Certificate{leaf, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwk.X5C) != 2 || jwk.X5T != X5T(leaf) || jwk.X5TS256 != X5TS256(leaf) {
+		t.Errorf("got %+v", jwk)
+	}
+	if _, err := JWKToPublicKey(jwk); err != nil {
+		t.Error(err)
+	}
+
+	wrongThumbprint := *jwk
+	wrongThumbprint.X5TS256 = X5TS256(root)
+	if _, err := JWKToPublicKey(&wrongThumbprint); err == nil {
+		t.Error("JWK with a wrong thumbprint accepted")
+	}
+	wrongKey := *jwk
+	wrongKey.X5C = EncodeX5C([]*Certificate{root})
+	wrongKey.X5T, wrongKey.X5TS256 = "", ""
+	if _, err := JWKToPublicKey(&wrongKey); err == nil {
+		t.Error("JWK with the certificate of another key accepted")
+	}
+}
+
+func TestJWKInvalid(t *testing.T) {
+	for _, jwk := range []JWK{
+		{KeyType: "oct"},
+		{KeyType: "RSA", N: "AQAB"},
+		{KeyType: "RSA", N: "AQAB", E: "AQ"},
+		{KeyType: "RSA", N: "AA", E: "AQAB"},
+		{KeyType: "EC", Curve: "P-256", X: "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", Y: "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"},
+		// Coordinates without leading zeros.
+		{KeyType: "EC", Curve: "P-256", X: "AQ", Y: "AQ"},
+		{KeyType: "EC", Curve: "secp256k1", X: "AQ", Y: "AQ"},
+		{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcH"},
+		{KeyType: "OKP", Curve: "X25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		// Padded base64.
+		{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo="},
+	} {
+		if _, err := JWKToPublicKey(&jwk); err == nil {
+			t.Errorf("%+v was accepted", jwk)
+		}
+	}
+}
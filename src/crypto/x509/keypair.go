@@ -0,0 +1,101 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Chain is a certificate chain, starting with the leaf and followed by the
+// certificates that issued it, in order.
+type Chain []*Certificate
+
+// Leaf returns the first certificate of the chain, or nil if it is empty.
+func (c Chain) Leaf() *Certificate {
+	if len(c) == 0 {
+		return nil
+	}
+	return c[0]
+}
+
+// NotAfter returns the earliest expiration time of the certificates in the
+// chain, after which the chain as a whole is no longer valid.
+func (c Chain) NotAfter() time.Time {
+	var t time.Time
+	for _, cert := range c {
+		if t.IsZero() || cert.NotAfter.Before(t) {
+			t = cert.NotAfter
+		}
+	}
+	return t
+}
+
+// LoadKeyPair parses a certificate chain and its private key, and checks
+// that they are usable together, as a server typically does before serving
+// them. It is similar to crypto/tls.X509KeyPair, but does not depend on TLS.
+//
+// certPEM holds the chain, starting with the leaf, usually as a sequence of
+// PEM "CERTIFICATE" blocks, although any encoding accepted by
+// ParseAnyCertificate can be used. keyPEM holds the private key of the leaf
+// in any format accepted by ParsePrivateKey.
+//
+// LoadKeyPair returns an error if the private key does not match the public
+// key of the leaf, if a certificate in the chain is not issued and signed by
+// the one that follows it, or if a certificate is expired or not yet valid.
+// The chain is not verified against any roots, see Certificate.Verify.
+func LoadKeyPair(certPEM, keyPEM []byte) (Chain, crypto.Signer, error) {
+	certs, err := ParseAnyCertificate(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain := Chain(certs)
+
+	key, _, err := ParsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("x509: private key does not implement crypto.Signer")
+	}
+	if !PublicKeysEqual(chain.Leaf().PublicKey, signer.Public()) {
+		return nil, nil, errors.New("x509: private key does not match public key of the leaf certificate")
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		child, parent := chain[i], chain[i+1]
+		if !bytes.Equal(child.RawIssuer, parent.RawSubject) {
+			return nil, nil, fmt.Errorf("x509: certificate %d in the chain (%q) is not the issuer of certificate %d (%q); the chain is out of order or incomplete",
+				i+1, parent.Subject.String(), i, child.Subject.String())
+		}
+		if err := child.CheckSignatureFrom(parent); err != nil {
+			return nil, nil, fmt.Errorf("x509: certificate %d in the chain (%q) is not signed by certificate %d: %v",
+				i, child.Subject.String(), i+1, err)
+		}
+	}
+
+	now := time.Now()
+	for _, c := range chain {
+		if now.Before(c.NotBefore) {
+			return nil, nil, CertificateInvalidError{
+				Cert:   c,
+				Reason: Expired,
+				Detail: fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), c.NotBefore.Format(time.RFC3339)),
+			}
+		} else if now.After(c.NotAfter) {
+			return nil, nil, CertificateInvalidError{
+				Cert:   c,
+				Reason: Expired,
+				Detail: fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339)),
+			}
+		}
+	}
+
+	return chain, signer, nil
+}
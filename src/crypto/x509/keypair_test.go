@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadKeyPair(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, leafKey, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodeCerts := func(certs ...*Certificate) []byte {
+		var out []byte
+		for _, c := range certs {
+			out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+		}
+		return out
+	}
+	encodeKey := func(key interface{}) []byte {
+		der, err := MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	chain, signer, err := LoadKeyPair(encodeCerts(leaf, inter, root), encodeKey(leafKey))
+	if err != nil {
+		t.Fatalf("LoadKeyPair failed: %v", err)
+	}
+	if len(chain) != 3 || !chain.Leaf().Equal(leaf) {
+		t.Errorf("unexpected chain %v", chainToDebugString(chain))
+	}
+	if !PublicKeysEqual(signer.Public(), leafKey.(*ecdsa.PrivateKey).Public()) {
+		t.Error("LoadKeyPair returned the wrong key")
+	}
+	if chain.NotAfter().After(root.NotAfter) {
+		t.Errorf("NotAfter is %v, later than the root's %v", chain.NotAfter(), root.NotAfter)
+	}
+
+	expiredTemplate := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Expired"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	expiredDER, err := CreateCertificate(rand.Reader, expiredTemplate, inter, leafKey.(*ecdsa.PrivateKey).Public(), interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := ParseCertificate(expiredDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		certs    []byte
+		key      []byte
+		expected string
+	}{
+		{"wrong key", encodeCerts(leaf, inter), encodeKey(interKey), "does not match"},
+		{"out of order", encodeCerts(leaf, root, inter), encodeKey(leafKey), "out of order"},
+		{"expired", encodeCerts(expired, inter), encodeKey(leafKey), "expired"},
+		{"no certificates", nil, encodeKey(leafKey), "neither PEM nor DER"},
+		{"no key", encodeCerts(leaf), nil, "private key"},
+	}
+	for _, test := range tests {
+		_, _, err := LoadKeyPair(test.certs, test.key)
+		if err == nil {
+			t.Errorf("%s: LoadKeyPair succeeded", test.name)
+		} else if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: error %q does not contain %q", test.name, err, test.expected)
+		}
+	}
+}
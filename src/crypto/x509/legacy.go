@@ -0,0 +1,179 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// Legacy, vendor-specific extensions that were commonly emitted by older
+// certificate authorities.
+//
+// netscape-cert-type OBJECT IDENTIFIER ::= { 2 16 840 1 113730 1 1 }
+// netscape-comment   OBJECT IDENTIFIER ::= { 2 16 840 1 113730 1 13 }
+// entrustVersInfo    OBJECT IDENTIFIER ::= { 1 2 840 113533 7 65 0 }
+var (
+	oidExtensionNetscapeCertType = asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 1}
+	oidExtensionNetscapeComment  = asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 13}
+	oidExtensionEntrustVersion   = asn1.ObjectIdentifier{1, 2, 840, 113533, 7, 65, 0}
+)
+
+// NetscapeCertType is the bitmap of usages from the legacy netscape-cert-type
+// extension.
+type NetscapeCertType int
+
+const (
+	NetscapeSSLClient NetscapeCertType = 1 << iota
+	NetscapeSSLServer
+	NetscapeSMIME
+	NetscapeObjectSigning
+	NetscapeReserved
+	NetscapeSSLCA
+	NetscapeSMIMECA
+	NetscapeObjectSigningCA
+)
+
+var netscapeCertTypeNames = [...]string{
+	"SSL client",
+	"SSL server",
+	"S/MIME",
+	"object signing",
+	"reserved",
+	"SSL CA",
+	"S/MIME CA",
+	"object signing CA",
+}
+
+func (t NetscapeCertType) String() string {
+	var names []string
+	for i, name := range netscapeCertTypeNames {
+		if t&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// EntrustVersionInfo is the content of the Entrust version information
+// extension.
+type EntrustVersionInfo struct {
+	// Version is the Entrust product version string, such as "V4.0".
+	Version string
+	// Flags holds the raw entrustInfoFlags bits, if present.
+	Flags asn1.BitString
+}
+
+// LegacyExtensions holds the decoded form of vendor extensions that predate
+// RFC 5280 profiles and are not otherwise interpreted by this package.
+type LegacyExtensions struct {
+	// NetscapeCertType is only meaningful if HasNetscapeCertType is true.
+	NetscapeCertType    NetscapeCertType
+	HasNetscapeCertType bool
+
+	// NetscapeComment is the free form netscape-comment text.
+	NetscapeComment string
+
+	// EntrustVersion is non-nil if the Entrust version information
+	// extension was present.
+	EntrustVersion *EntrustVersionInfo
+}
+
+// LegacyExtensions decodes the netscape-cert-type, netscape-comment and
+// Entrust version information extensions of c, which are otherwise only
+// available in raw form via Extensions. It is intended for inventory tools
+// that need to report what constraints old internal CAs encoded.
+//
+// Decoding is best-effort: every extension that can be decoded is returned,
+// and if any recognized extension is malformed the first such problem is
+// reported as a non-nil error alongside the partial result. These extensions
+// are never considered during verification.
+func (c *Certificate) LegacyExtensions() (*LegacyExtensions, error) {
+	out := new(LegacyExtensions)
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, e := range c.Extensions {
+		switch {
+		case e.Id.Equal(oidExtensionNetscapeCertType):
+			var bits asn1.BitString
+			if rest, err := asn1.Unmarshal(e.Value, &bits); err != nil || len(rest) != 0 {
+				fail(errors.New("x509: invalid netscape-cert-type extension"))
+				continue
+			}
+			var t NetscapeCertType
+			for i := 0; i < 8; i++ {
+				if bits.At(i) != 0 {
+					t |= 1 << uint(i)
+				}
+			}
+			out.NetscapeCertType = t
+			out.HasNetscapeCertType = true
+
+		case e.Id.Equal(oidExtensionNetscapeComment):
+			comment, err := parseLegacyString(cryptobyte.String(e.Value))
+			if err != nil {
+				fail(fmt.Errorf("x509: invalid netscape-comment extension: %v", err))
+				continue
+			}
+			out.NetscapeComment = comment
+
+		case e.Id.Equal(oidExtensionEntrustVersion):
+			// EntrustVersionInfo ::= SEQUENCE {
+			//    entrustVers       GeneralString,
+			//    entrustInfoFlags  EntrustInfoFlags OPTIONAL }
+			//
+			// EntrustInfoFlags ::= BIT STRING
+			input := cryptobyte.String(e.Value)
+			var seq, vers cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+				!seq.ReadAnyASN1Element(&vers, &tag) {
+				fail(errors.New("x509: invalid Entrust version extension"))
+				continue
+			}
+			version, err := parseLegacyString(vers)
+			if err != nil {
+				fail(fmt.Errorf("x509: invalid Entrust version extension: %v", err))
+				continue
+			}
+			info := &EntrustVersionInfo{Version: version}
+			if !seq.Empty() && (!seq.ReadASN1BitString(&info.Flags) || !seq.Empty()) {
+				fail(errors.New("x509: invalid Entrust version extension flags"))
+				continue
+			}
+			out.EntrustVersion = info
+		}
+	}
+
+	return out, firstErr
+}
+
+// parseLegacyString decodes a single ASN.1 string element. Legacy CAs used a
+// variety of string types for free form text, so any of the common ones are
+// accepted.
+func parseLegacyString(der cryptobyte.String) (string, error) {
+	var value cryptobyte.String
+	var tag cryptobyte_asn1.Tag
+	if !der.ReadAnyASN1(&value, &tag) || !der.Empty() {
+		return "", errors.New("malformed string")
+	}
+	switch tag {
+	case cryptobyte_asn1.IA5String, cryptobyte_asn1.PrintableString, cryptobyte_asn1.UTF8String,
+		cryptobyte_asn1.Tag(asn1.TagGeneralString), cryptobyte_asn1.Tag(asn1.TagT61String),
+		cryptobyte_asn1.Tag(26) /* VisibleString */ :
+		return string(value), nil
+	}
+	return "", fmt.Errorf("unsupported string type %d", tag)
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestLegacyExtensions(t *testing.T) {
+	certType, _ := asn1.Marshal(asn1.BitString{Bytes: []byte{0xc4}, BitLength: 6})
+	comment, _ := asn1.Marshal("OpenSSL Generated Certificate")
+	entrust := []byte{
+		0x30, 0x0b,
+		0x1b, 0x05, 'V', '4', '.', '0', 'c', // GeneralString
+		0x03, 0x02, 0x04, 0x90, // BIT STRING
+	}
+
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "legacy"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionNetscapeCertType, Value: certType},
+			{Id: oidExtensionNetscapeComment, Value: comment},
+			{Id: oidExtensionEntrustVersion, Value: entrust},
+		},
+	}
+
+	legacy, err := serialiseAndParse(t, template).LegacyExtensions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !legacy.HasNetscapeCertType || legacy.NetscapeCertType != NetscapeSSLClient|NetscapeSSLServer|NetscapeSSLCA {
+		t.Errorf("unexpected netscape-cert-type %v", legacy.NetscapeCertType)
+	}
+	if s := legacy.NetscapeCertType.String(); s != "SSL client, SSL server, SSL CA" {
+		t.Errorf("unexpected netscape-cert-type string %q", s)
+	}
+	if legacy.NetscapeComment != "OpenSSL Generated Certificate" {
+		t.Errorf("unexpected netscape-comment %q", legacy.NetscapeComment)
+	}
+	if legacy.EntrustVersion == nil || legacy.EntrustVersion.Version != "V4.0c" || legacy.EntrustVersion.Flags.At(0) != 1 {
+		t.Errorf("unexpected Entrust version %+v", legacy.EntrustVersion)
+	}
+}
+
+func TestLegacyExtensionsBestEffort(t *testing.T) {
+	comment, _ := asn1.Marshal("still readable")
+	c := &Certificate{
+		Extensions: []pkix.Extension{
+			{Id: oidExtensionNetscapeCertType, Value: []byte{0x04, 0x00}},
+			{Id: oidExtensionNetscapeComment, Value: comment},
+		},
+	}
+
+	legacy, err := c.LegacyExtensions()
+	if err == nil {
+		t.Error("malformed netscape-cert-type did not return an error")
+	}
+	if legacy.HasNetscapeCertType {
+		t.Error("malformed netscape-cert-type was reported as present")
+	}
+	if legacy.NetscapeComment != "still readable" {
+		t.Errorf("well-formed extension was not decoded, got %q", legacy.NetscapeComment)
+	}
+}
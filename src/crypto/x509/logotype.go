@@ -0,0 +1,399 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 3709, Section 4.1
+//
+// id-pe-logotype OBJECT IDENTIFIER ::= { id-pe 12 }
+var oidExtensionLogotype = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 12}
+
+// Logotypes represents the logotype certificate extension defined in
+// RFC 3709. Only the fields needed to locate and authenticate logotype data
+// are represented; the optional imageInfo and audioInfo hints are ignored when
+// parsing and omitted when marshaling.
+type Logotypes struct {
+	CommunityLogos []LogotypeInfo
+	IssuerLogo     *LogotypeInfo
+	SubjectLogo    *LogotypeInfo
+	OtherLogos     []OtherLogotypeInfo
+}
+
+// LogotypeInfo holds a single logotype, given either directly as a list of
+// images and audio clips, or indirectly as a reference to a LogotypeData
+// structure stored elsewhere. Exactly one of the two forms should be set.
+type LogotypeInfo struct {
+	// Images and Audio are used for the direct form.
+	Images []LogotypeDetails
+	Audio  []LogotypeDetails
+
+	// ReferenceHashes and ReferenceURIs are used for the indirect form.
+	ReferenceHashes []HashAlgAndValue
+	ReferenceURIs   []string
+}
+
+// OtherLogotypeInfo is a logotype of a type other than the ones defined
+// directly by RFC 3709, such as a loyalty logotype.
+type OtherLogotypeInfo struct {
+	Type asn1.ObjectIdentifier
+	Info LogotypeInfo
+}
+
+// LogotypeDetails identifies a logotype image or audio object by media type,
+// hash and location.
+type LogotypeDetails struct {
+	MediaType string
+	Hashes    []HashAlgAndValue
+	URIs      []string
+}
+
+// HashAlgAndValue is a hash value and the algorithm that was used to compute
+// it.
+type HashAlgAndValue struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Value     []byte
+}
+
+var errInvalidLogotype = errors.New("x509: invalid logotype extension")
+
+func parseLogotypeExtension(der []byte) (*Logotypes, error) {
+	// LogotypeExtn ::= SEQUENCE {
+	//    communityLogos  [0] EXPLICIT SEQUENCE OF LogotypeInfo OPTIONAL,
+	//    issuerLogo      [1] EXPLICIT LogotypeInfo OPTIONAL,
+	//    subjectLogo     [2] EXPLICIT LogotypeInfo OPTIONAL,
+	//    otherLogos      [3] EXPLICIT SEQUENCE OF OtherLogotypeInfo OPTIONAL }
+	input := cryptobyte.String(der)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, errInvalidLogotype
+	}
+
+	out := new(Logotypes)
+	var community, issuer, subject, other cryptobyte.String
+	var hasCommunity, hasIssuer, hasSubject, hasOther bool
+	if !seq.ReadOptionalASN1(&community, &hasCommunity, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!seq.ReadOptionalASN1(&issuer, &hasIssuer, cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()) ||
+		!seq.ReadOptionalASN1(&subject, &hasSubject, cryptobyte_asn1.Tag(2).ContextSpecific().Constructed()) ||
+		!seq.ReadOptionalASN1(&other, &hasOther, cryptobyte_asn1.Tag(3).ContextSpecific().Constructed()) ||
+		!seq.Empty() {
+		return nil, errInvalidLogotype
+	}
+
+	if hasCommunity {
+		var logos cryptobyte.String
+		if !community.ReadASN1(&logos, cryptobyte_asn1.SEQUENCE) || !community.Empty() {
+			return nil, errInvalidLogotype
+		}
+		for !logos.Empty() {
+			info, err := parseLogotypeInfo(&logos)
+			if err != nil {
+				return nil, err
+			}
+			out.CommunityLogos = append(out.CommunityLogos, info)
+		}
+	}
+	if hasIssuer {
+		info, err := parseLogotypeInfo(&issuer)
+		if err != nil || !issuer.Empty() {
+			return nil, errInvalidLogotype
+		}
+		out.IssuerLogo = &info
+	}
+	if hasSubject {
+		info, err := parseLogotypeInfo(&subject)
+		if err != nil || !subject.Empty() {
+			return nil, errInvalidLogotype
+		}
+		out.SubjectLogo = &info
+	}
+	if hasOther {
+		var logos cryptobyte.String
+		if !other.ReadASN1(&logos, cryptobyte_asn1.SEQUENCE) || !other.Empty() {
+			return nil, errInvalidLogotype
+		}
+		for !logos.Empty() {
+			// OtherLogotypeInfo ::= SEQUENCE {
+			//    logotypeType    OBJECT IDENTIFIER,
+			//    info            LogotypeInfo }
+			var o OtherLogotypeInfo
+			var otherSeq cryptobyte.String
+			if !logos.ReadASN1(&otherSeq, cryptobyte_asn1.SEQUENCE) ||
+				!otherSeq.ReadASN1ObjectIdentifier(&o.Type) {
+				return nil, errInvalidLogotype
+			}
+			info, err := parseLogotypeInfo(&otherSeq)
+			if err != nil || !otherSeq.Empty() {
+				return nil, errInvalidLogotype
+			}
+			o.Info = info
+			out.OtherLogos = append(out.OtherLogos, o)
+		}
+	}
+
+	return out, nil
+}
+
+// parseLogotypeInfo reads a LogotypeInfo from the front of s.
+func parseLogotypeInfo(s *cryptobyte.String) (LogotypeInfo, error) {
+	// LogotypeInfo ::= CHOICE {
+	//    direct          [0] LogotypeData,
+	//    indirect        [1] LogotypeReference }
+	//
+	// LogotypeData ::= SEQUENCE {
+	//    image           SEQUENCE OF LogotypeImage OPTIONAL,
+	//    audio           [1] SEQUENCE OF LogotypeAudio OPTIONAL }
+	//
+	// LogotypeReference ::= SEQUENCE {
+	//    refStructHash   SEQUENCE SIZE (1..MAX) OF HashAlgAndValue,
+	//    refStructURI    SEQUENCE SIZE (1..MAX) OF IA5String }
+	var info LogotypeInfo
+	var body cryptobyte.String
+	switch {
+	case s.PeekASN1Tag(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()):
+		if !s.ReadASN1(&body, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+			return info, errInvalidLogotype
+		}
+		var images, audio cryptobyte.String
+		var hasImages, hasAudio bool
+		if !body.ReadOptionalASN1(&images, &hasImages, cryptobyte_asn1.SEQUENCE) ||
+			!body.ReadOptionalASN1(&audio, &hasAudio, cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()) ||
+			!body.Empty() {
+			return info, errInvalidLogotype
+		}
+		var err error
+		if info.Images, err = parseLogotypeObjects(images); err != nil {
+			return info, err
+		}
+		if info.Audio, err = parseLogotypeObjects(audio); err != nil {
+			return info, err
+		}
+	case s.PeekASN1Tag(cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()):
+		if !s.ReadASN1(&body, cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()) {
+			return info, errInvalidLogotype
+		}
+		var hashes, uris cryptobyte.String
+		if !body.ReadASN1(&hashes, cryptobyte_asn1.SEQUENCE) ||
+			!body.ReadASN1(&uris, cryptobyte_asn1.SEQUENCE) ||
+			!body.Empty() {
+			return info, errInvalidLogotype
+		}
+		var err error
+		if info.ReferenceHashes, err = parseHashAlgAndValues(hashes); err != nil {
+			return info, err
+		}
+		if info.ReferenceURIs, err = parseIA5Strings(uris); err != nil {
+			return info, err
+		}
+	default:
+		return info, errInvalidLogotype
+	}
+	return info, nil
+}
+
+// parseLogotypeObjects parses the contents of a SEQUENCE OF LogotypeImage or
+// LogotypeAudio.
+func parseLogotypeObjects(objects cryptobyte.String) ([]LogotypeDetails, error) {
+	// LogotypeImage ::= SEQUENCE {
+	//    imageDetails    LogotypeDetails,
+	//    imageInfo       LogotypeImageInfo OPTIONAL }
+	//
+	// LogotypeDetails ::= SEQUENCE {
+	//    mediaType       IA5String,
+	//    logotypeHash    SEQUENCE SIZE (1..MAX) OF HashAlgAndValue,
+	//    logotypeURI     SEQUENCE SIZE (1..MAX) OF IA5String }
+	var out []LogotypeDetails
+	for !objects.Empty() {
+		var object, details, mediaType, hashes, uris cryptobyte.String
+		if !objects.ReadASN1(&object, cryptobyte_asn1.SEQUENCE) ||
+			!object.ReadASN1(&details, cryptobyte_asn1.SEQUENCE) ||
+			!details.ReadASN1(&mediaType, cryptobyte_asn1.IA5String) ||
+			!details.ReadASN1(&hashes, cryptobyte_asn1.SEQUENCE) ||
+			!details.ReadASN1(&uris, cryptobyte_asn1.SEQUENCE) ||
+			!details.Empty() {
+			return nil, errInvalidLogotype
+		}
+		d := LogotypeDetails{MediaType: string(mediaType)}
+		var err error
+		if d.Hashes, err = parseHashAlgAndValues(hashes); err != nil {
+			return nil, err
+		}
+		if d.URIs, err = parseIA5Strings(uris); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func parseHashAlgAndValues(s cryptobyte.String) ([]HashAlgAndValue, error) {
+	// HashAlgAndValue ::= SEQUENCE {
+	//    hashAlg         AlgorithmIdentifier,
+	//    hashValue       OCTET STRING }
+	var out []HashAlgAndValue
+	for !s.Empty() {
+		var seq, alg, value cryptobyte.String
+		if !s.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) ||
+			!seq.ReadASN1Element(&alg, cryptobyte_asn1.SEQUENCE) ||
+			!seq.ReadASN1(&value, cryptobyte_asn1.OCTET_STRING) ||
+			!seq.Empty() {
+			return nil, errInvalidLogotype
+		}
+		var h HashAlgAndValue
+		if rest, err := asn1.Unmarshal(alg, &h.Algorithm); err != nil || len(rest) != 0 {
+			return nil, errInvalidLogotype
+		}
+		h.Value = append([]byte(nil), value...)
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func parseIA5Strings(s cryptobyte.String) ([]string, error) {
+	var out []string
+	for !s.Empty() {
+		var v cryptobyte.String
+		if !s.ReadASN1(&v, cryptobyte_asn1.IA5String) {
+			return nil, errInvalidLogotype
+		}
+		if err := isIA5String(string(v)); err != nil {
+			return nil, errInvalidLogotype
+		}
+		out = append(out, string(v))
+	}
+	return out, nil
+}
+
+func marshalLogotypes(l *Logotypes) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		if len(l.CommunityLogos) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for i := range l.CommunityLogos {
+						addLogotypeInfo(b, &l.CommunityLogos[i])
+					}
+				})
+			})
+		}
+		if l.IssuerLogo != nil {
+			b.AddASN1(cryptobyte_asn1.Tag(1).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				addLogotypeInfo(b, l.IssuerLogo)
+			})
+		}
+		if l.SubjectLogo != nil {
+			b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				addLogotypeInfo(b, l.SubjectLogo)
+			})
+		}
+		if len(l.OtherLogos) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(3).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for i := range l.OtherLogos {
+						other := &l.OtherLogos[i]
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(other.Type)
+							addLogotypeInfo(b, &other.Info)
+						})
+					}
+				})
+			})
+		}
+	})
+	return b.Bytes()
+}
+
+func addLogotypeInfo(b *cryptobyte.Builder, info *LogotypeInfo) {
+	direct := len(info.Images) > 0 || len(info.Audio) > 0
+	indirect := len(info.ReferenceHashes) > 0 || len(info.ReferenceURIs) > 0
+	if direct == indirect {
+		b.SetError(errors.New("x509: logotype must be either direct or indirect"))
+		return
+	}
+
+	if indirect {
+		if len(info.ReferenceHashes) == 0 || len(info.ReferenceURIs) == 0 {
+			b.SetError(errors.New("x509: indirect logotype requires both hashes and URIs"))
+			return
+		}
+		b.AddASN1(cryptobyte_asn1.Tag(1).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+			addHashAlgAndValues(b, info.ReferenceHashes)
+			addIA5Strings(b, info.ReferenceURIs)
+		})
+		return
+	}
+
+	b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+		if len(info.Images) > 0 {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				addLogotypeObjects(b, info.Images)
+			})
+		}
+		if len(info.Audio) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(1).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				addLogotypeObjects(b, info.Audio)
+			})
+		}
+	})
+}
+
+func addLogotypeObjects(b *cryptobyte.Builder, objects []LogotypeDetails) {
+	for _, d := range objects {
+		if len(d.Hashes) == 0 || len(d.URIs) == 0 {
+			b.SetError(errors.New("x509: logotype details require at least one hash and one URI"))
+			return
+		}
+		if err := isIA5String(d.MediaType); err != nil {
+			b.SetError(err)
+			return
+		}
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.IA5String, func(b *cryptobyte.Builder) {
+					b.AddBytes([]byte(d.MediaType))
+				})
+				addHashAlgAndValues(b, d.Hashes)
+				addIA5Strings(b, d.URIs)
+			})
+		})
+	}
+}
+
+func addHashAlgAndValues(b *cryptobyte.Builder, hashes []HashAlgAndValue) {
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for _, h := range hashes {
+			alg, err := asn1.Marshal(h.Algorithm)
+			if err != nil {
+				b.SetError(err)
+				return
+			}
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddBytes(alg)
+				b.AddASN1OctetString(h.Value)
+			})
+		}
+	})
+}
+
+func addIA5Strings(b *cryptobyte.Builder, values []string) {
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for _, v := range values {
+			if err := isIA5String(v); err != nil {
+				b.SetError(err)
+				return
+			}
+			b.AddASN1(cryptobyte_asn1.IA5String, func(b *cryptobyte.Builder) {
+				b.AddBytes([]byte(v))
+			})
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var sha256AlgorithmIdentifier = pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+
+func TestBiometricInfoRoundTrip(t *testing.T) {
+	picture := sha256.Sum256([]byte("picture"))
+	other := sha256.Sum256([]byte("fingerprint"))
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "qualified"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		BiometricInfo: []BiometricData{
+			{
+				Type:          BiometricPicture,
+				HashAlgorithm: sha256AlgorithmIdentifier,
+				Hash:          picture[:],
+				SourceDataURI: "https://example.com/picture.jpg",
+			},
+			{
+				TypeOID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+				HashAlgorithm: sha256AlgorithmIdentifier,
+				Hash:          other[:],
+			},
+		},
+	}
+
+	cert := serialiseAndParse(t, template)
+	if len(cert.BiometricInfo) != 2 {
+		t.Fatalf("got %d biometricInfo entries, want 2", len(cert.BiometricInfo))
+	}
+	for i, got := range cert.BiometricInfo {
+		want := template.BiometricInfo[i]
+		if got.Type != want.Type || !got.TypeOID.Equal(want.TypeOID) ||
+			!got.HashAlgorithm.Algorithm.Equal(want.HashAlgorithm.Algorithm) ||
+			!bytes.Equal(got.Hash, want.Hash) || got.SourceDataURI != want.SourceDataURI {
+			t.Errorf("biometricInfo #%d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if len(cert.UnhandledCriticalExtensions) != 0 {
+		t.Errorf("unexpected unhandled critical extensions: %v", cert.UnhandledCriticalExtensions)
+	}
+}
+
+func TestLogotypeRoundTrip(t *testing.T) {
+	logoHash := sha256.Sum256([]byte("logo"))
+	hashes := []HashAlgAndValue{{Algorithm: sha256AlgorithmIdentifier, Value: logoHash[:]}}
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "logotype"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		Logotypes: &Logotypes{
+			CommunityLogos: []LogotypeInfo{{
+				Images: []LogotypeDetails{{
+					MediaType: "image/svg+xml",
+					Hashes:    hashes,
+					URIs:      []string{"https://example.com/community.svg"},
+				}},
+			}},
+			IssuerLogo: &LogotypeInfo{
+				ReferenceHashes: hashes,
+				ReferenceURIs:   []string{"https://example.com/issuer.der"},
+			},
+			SubjectLogo: &LogotypeInfo{
+				Images: []LogotypeDetails{{
+					MediaType: "image/png",
+					Hashes:    hashes,
+					URIs:      []string{"https://example.com/subject.png"},
+				}},
+				Audio: []LogotypeDetails{{
+					MediaType: "audio/mpeg",
+					Hashes:    hashes,
+					URIs:      []string{"https://example.com/jingle.mp3"},
+				}},
+			},
+			OtherLogos: []OtherLogotypeInfo{{
+				Type: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 20, 1},
+				Info: LogotypeInfo{
+					ReferenceHashes: hashes,
+					ReferenceURIs:   []string{"https://example.com/loyalty.der"},
+				},
+			}},
+		},
+	}
+
+	got := serialiseAndParse(t, template).Logotypes
+	if got == nil {
+		t.Fatal("parsed certificate has no logotypes")
+	}
+	want := template.Logotypes
+	if len(got.CommunityLogos) != 1 || got.CommunityLogos[0].Images[0].MediaType != "image/svg+xml" {
+		t.Errorf("unexpected community logos: %+v", got.CommunityLogos)
+	}
+	if got.IssuerLogo == nil || got.IssuerLogo.ReferenceURIs[0] != want.IssuerLogo.ReferenceURIs[0] ||
+		!bytes.Equal(got.IssuerLogo.ReferenceHashes[0].Value, logoHash[:]) {
+		t.Errorf("unexpected issuer logo: %+v", got.IssuerLogo)
+	}
+	if got.SubjectLogo == nil || len(got.SubjectLogo.Images) != 1 || len(got.SubjectLogo.Audio) != 1 ||
+		got.SubjectLogo.Audio[0].URIs[0] != "https://example.com/jingle.mp3" {
+		t.Errorf("unexpected subject logo: %+v", got.SubjectLogo)
+	}
+	if len(got.OtherLogos) != 1 || !got.OtherLogos[0].Type.Equal(want.OtherLogos[0].Type) {
+		t.Errorf("unexpected other logos: %+v", got.OtherLogos)
+	}
+}
+
+func TestLogotypeInvalid(t *testing.T) {
+	if _, err := marshalLogotypes(&Logotypes{SubjectLogo: &LogotypeInfo{}}); err == nil {
+		t.Error("marshaling an empty LogotypeInfo succeeded")
+	}
+	if _, err := parseLogotypeExtension([]byte{0x30, 0x03, 0xa2, 0x01, 0x00}); err == nil {
+		t.Error("parsing a malformed logotype extension succeeded")
+	}
+}
+
+func TestMalformedInformationalExtensions(t *testing.T) {
+	garbage := []byte{0x30, 0x03, 0xa2, 0x01, 0x00}
+	for _, critical := range []bool{false, true} {
+		template := &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "malformed"},
+			NotBefore:    time.Unix(1000, 0),
+			NotAfter:     time.Unix(100000, 0),
+			ExtraExtensions: []pkix.Extension{
+				{Id: oidExtensionBiometricInfo, Critical: critical, Value: garbage},
+				{Id: oidExtensionLogotype, Critical: critical, Value: garbage},
+			},
+		}
+		cert := serialiseAndParse(t, template)
+		if cert.BiometricInfo != nil || cert.Logotypes != nil {
+			t.Errorf("critical=%v: malformed extensions were parsed: %+v, %+v", critical, cert.BiometricInfo, cert.Logotypes)
+		}
+		if len(cert.UnhandledExtensions) != 2 {
+			t.Errorf("critical=%v: got unhandled extensions %v, want both", critical, cert.UnhandledExtensions)
+		}
+		if got := len(cert.UnhandledCriticalExtensions); critical && got != 2 || !critical && got != 0 {
+			t.Errorf("critical=%v: got unhandled critical extensions %v", critical, cert.UnhandledCriticalExtensions)
+		}
+	}
+}
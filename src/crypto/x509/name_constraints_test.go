@@ -1669,6 +1669,9 @@ func makeConstraintsLeafCert(leaf leafSpec, key *ecdsa.PrivateKey, parent *Certi
 		KeyUsage:              KeyUsageDigitalSignature,
 		BasicConstraintsValid: true,
 		IsCA:                  false,
+
+		// The test cases include malformed names on purpose.
+		InsecureSkipSANValidation: true,
 	}
 
 	for _, name := range leaf.sans {
@@ -0,0 +1,72 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"fmt"
+	"time"
+)
+
+// RFC 6960, Section 4.2.2.2.1
+//
+// id-pkix-ocsp-nocheck OBJECT IDENTIFIER ::= { id-pkix-ocsp 5 }
+var oidExtensionOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// VerifyOCSPResponder checks that responder is authorized to sign OCSP
+// responses about certificates issued by issuer, following RFC 6960,
+// Section 4.2.2.2. It does not verify the OCSP response itself.
+//
+// If responder is issuer, the CA is signing its own responses and no further
+// checks are made. Otherwise responder must be a delegated responder: it must
+// be directly issued and signed by issuer, valid at currentTime (or the
+// current time if zero), and carry the id-kp-OCSPSigning extended key usage.
+// The anyExtendedKeyUsage value is deliberately not accepted.
+//
+// noCheck reports whether responder carries the id-pkix-ocsp-nocheck
+// extension, in which case clients should not check its revocation status.
+func VerifyOCSPResponder(responder, issuer *Certificate, currentTime time.Time) (noCheck bool, err error) {
+	if responder.Equal(issuer) {
+		return false, nil
+	}
+
+	if !bytes.Equal(responder.RawIssuer, issuer.RawSubject) {
+		return false, CertificateInvalidError{responder, NameMismatch, ""}
+	}
+	if err := responder.CheckSignatureFrom(issuer); err != nil {
+		return false, err
+	}
+
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+	if currentTime.Before(responder.NotBefore) {
+		return false, CertificateInvalidError{
+			Cert:   responder,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is before %s", currentTime.Format(time.RFC3339), responder.NotBefore.Format(time.RFC3339)),
+		}
+	} else if currentTime.After(responder.NotAfter) {
+		return false, CertificateInvalidError{
+			Cert:   responder,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is after %s", currentTime.Format(time.RFC3339), responder.NotAfter.Format(time.RFC3339)),
+		}
+	}
+
+	authorized := false
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == ExtKeyUsageOCSPSigning {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		return false, CertificateInvalidError{responder, IncompatibleUsage, ""}
+	}
+
+	return oidInExtensions(oidExtensionOCSPNoCheck, responder.Extensions), nil
+}
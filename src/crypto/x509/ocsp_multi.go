@@ -0,0 +1,539 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var (
+	oidSHA1           = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasic      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	ocspHashAlgorithm = []struct {
+		hash crypto.Hash
+		oid  asn1.ObjectIdentifier
+	}{
+		{crypto.SHA1, oidSHA1},
+		{crypto.SHA256, oidSHA256},
+		{crypto.SHA384, oidSHA384},
+		{crypto.SHA512, oidSHA512},
+	}
+)
+
+// OCSPCertID identifies a certificate in OCSP requests and responses, RFC
+// 6960, Section 4.1.1.
+type OCSPCertID struct {
+	// HashAlgorithm is the hash used for IssuerNameHash and IssuerKeyHash.
+	HashAlgorithm crypto.Hash
+	// IssuerNameHash is the hash of the DER encoded subject of the issuer.
+	IssuerNameHash []byte
+	// IssuerKeyHash is the hash of the public key of the issuer, excluding
+	// the tag, length and unused bits of its BIT STRING.
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+// NewOCSPCertID returns the OCSPCertID of cert, issued by issuer, using the
+// hash h, which must be one of SHA-1, SHA-256, SHA-384 and SHA-512.
+func NewOCSPCertID(cert, issuer *Certificate, h crypto.Hash) (OCSPCertID, error) {
+	if ocspHashOID(h) == nil || !h.Available() {
+		return OCSPCertID{}, fmt.Errorf("x509: unsupported OCSP hash algorithm %v", h)
+	}
+	input := cryptobyte.String(issuer.RawSubjectPublicKeyInfo)
+	var spki cryptobyte.String
+	var key []byte
+	if !input.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) ||
+		!spki.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitStringAsBytes(&key) {
+		return OCSPCertID{}, errors.New("x509: malformed issuer public key")
+	}
+	nameHash := h.New()
+	nameHash.Write(issuer.RawSubject)
+	keyHash := h.New()
+	keyHash.Write(key)
+	return OCSPCertID{
+		HashAlgorithm:  h,
+		IssuerNameHash: nameHash.Sum(nil),
+		IssuerKeyHash:  keyHash.Sum(nil),
+		SerialNumber:   new(big.Int).Set(cert.SerialNumber),
+	}, nil
+}
+
+// Equal reports whether id and other identify the same certificate with the
+// same hash algorithm.
+func (id OCSPCertID) Equal(other OCSPCertID) bool {
+	return id.key() == other.key()
+}
+
+// key returns a string that identifies id, to be used as a map key.
+func (id OCSPCertID) key() string {
+	return fmt.Sprintf("%d/%x/%x/%v", id.HashAlgorithm, id.IssuerNameHash, id.IssuerKeyHash, id.SerialNumber)
+}
+
+func ocspHashOID(h crypto.Hash) asn1.ObjectIdentifier {
+	for _, a := range ocspHashAlgorithm {
+		if a.hash == h {
+			return a.oid
+		}
+	}
+	return nil
+}
+
+// OCSPStatus is the status of a certificate in an OCSP response.
+type OCSPStatus int
+
+const (
+	OCSPGood OCSPStatus = iota
+	OCSPRevoked
+	OCSPUnknown
+)
+
+func (s OCSPStatus) String() string {
+	switch s {
+	case OCSPGood:
+		return "good"
+	case OCSPRevoked:
+		return "revoked"
+	case OCSPUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("OCSPStatus(%d)", int(s))
+}
+
+// OCSPSingleResponse is the status of one certificate in an OCSP response,
+// RFC 6960, Section 4.2.1.
+type OCSPSingleResponse struct {
+	CertID OCSPCertID
+	Status OCSPStatus
+	// RevokedAt and RevocationReason are set if Status is OCSPRevoked.
+	RevokedAt        time.Time
+	RevocationReason CRLReason
+	ThisUpdate       time.Time
+	// NextUpdate is zero if the response does not have one.
+	NextUpdate time.Time
+}
+
+// OCSPStaples holds the OCSP responses covering a certificate chain, as sent
+// by a server in the ocsp_response_list of the status_request_v2 TLS
+// extension with the ocsp_multi type, RFC 6961. The responses are indexed by
+// the OCSPCertID of each certificate they cover.
+type OCSPStaples struct {
+	byID map[string]*ocspStaple
+}
+
+// ocspStaple is a single response, along with the response containing it.
+type ocspStaple struct {
+	der    []byte
+	basic  *basicOCSPResponse
+	single OCSPSingleResponse
+}
+
+// ParseOCSPStaples parses the DER encoded OCSP responses, in any order. Empty
+// responses are skipped, as the ocsp_response_list of RFC 6961 uses them for
+// certificates without a response. The responses are not verified.
+func ParseOCSPStaples(responses [][]byte) (*OCSPStaples, error) {
+	s := &OCSPStaples{}
+	for _, der := range responses {
+		if len(der) == 0 {
+			continue
+		}
+		if err := s.Add(der); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add adds the DER encoded OCSP response der, replacing any previous
+// response for the certificates it covers. The response is not verified.
+func (s *OCSPStaples) Add(der []byte) error {
+	basic, err := parseOCSPResponse(der)
+	if err != nil {
+		return err
+	}
+	if s.byID == nil {
+		s.byID = make(map[string]*ocspStaple)
+	}
+	for _, single := range basic.responses {
+		s.byID[single.CertID.key()] = &ocspStaple{der, basic, single}
+	}
+	return nil
+}
+
+// Lookup returns the DER encoded OCSP response covering the certificate
+// identified by id, and its single response for it.
+func (s *OCSPStaples) Lookup(id OCSPCertID) (der []byte, single OCSPSingleResponse, ok bool) {
+	staple, ok := s.byID[id.key()]
+	if !ok {
+		return nil, OCSPSingleResponse{}, false
+	}
+	return staple.der, staple.single, true
+}
+
+// lookupChain returns the response covering chain[i], trying each supported
+// hash algorithm, since the responder picks it.
+func (s *OCSPStaples) lookupChain(chain []*Certificate, i int) (*ocspStaple, error) {
+	for _, a := range ocspHashAlgorithm {
+		if !a.hash.Available() {
+			continue
+		}
+		id, err := NewOCSPCertID(chain[i], chain[i+1], a.hash)
+		if err != nil {
+			return nil, err
+		}
+		if staple, ok := s.byID[id.key()]; ok {
+			return staple, nil
+		}
+	}
+	return nil, nil
+}
+
+// ResponseList returns the responses for the certificates of chain, in chain
+// order, with nil for the certificates without one, ready to be sent as the
+// ocsp_response_list of RFC 6961. chain must start with the leaf and include
+// the issuer of each certificate. The last certificate of chain, whose issuer
+// is not included, has no response.
+func (s *OCSPStaples) ResponseList(chain []*Certificate) ([][]byte, error) {
+	list := make([][]byte, len(chain))
+	for i := 0; i < len(chain)-1; i++ {
+		staple, err := s.lookupChain(chain, i)
+		if err != nil {
+			return nil, err
+		}
+		if staple != nil {
+			list[i] = staple.der
+		}
+	}
+	return list, nil
+}
+
+// VerifyChain verifies the responses covering the certificates of chain in a
+// single call, and returns the single response for each certificate but the
+// last, whose issuer is not included. chain must start with the leaf and
+// include the issuer of each certificate, as returned by Certificate.Verify.
+//
+// Each response must be signed by the issuer of the certificate, or by a
+// delegated responder included in the response and accepted by
+// VerifyOCSPResponder, and must be current at now, or the current time if
+// zero. VerifyChain returns an error if a certificate has no such response,
+// or if it is revoked or unknown to the responder; the single responses
+// verified until then are returned along with it.
+func (s *OCSPStaples) VerifyChain(chain []*Certificate, now time.Time) ([]OCSPSingleResponse, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	var singles []OCSPSingleResponse
+	for i := 0; i < len(chain)-1; i++ {
+		cert := chain[i]
+		single, err := s.verify(chain, i, now)
+		if err != nil {
+			return singles, err
+		}
+		if single == nil {
+			return singles, fmt.Errorf("x509: no OCSP response for %q", cert.Subject)
+		}
+		singles = append(singles, *single)
+		switch single.Status {
+		case OCSPRevoked:
+			return singles, fmt.Errorf("x509: %q was revoked at %s (%v)", cert.Subject, single.RevokedAt.Format(time.RFC3339), single.RevocationReason)
+		case OCSPUnknown:
+			return singles, fmt.Errorf("x509: %q is unknown to its OCSP responder", cert.Subject)
+		}
+	}
+	return singles, nil
+}
+
+// verify verifies the response covering chain[i], if any, and returns its
+// single response for chain[i], or nil if there is no response.
+func (s *OCSPStaples) verify(chain []*Certificate, i int, now time.Time) (*OCSPSingleResponse, error) {
+	staple, err := s.lookupChain(chain, i)
+	if err != nil || staple == nil {
+		return nil, err
+	}
+	if err := staple.basic.verify(chain[i+1], now); err != nil {
+		return nil, err
+	}
+	if err := staple.single.checkTime(fmt.Sprintf("%q", chain[i].Subject), now); err != nil {
+		return nil, err
+	}
+	return &staple.single, nil
+}
+
+// checkChain is the check of VerifyOptions.OCSPStaples: it verifies the
+// responses covering the certificates of chain, skipping those without one,
+// unless requireLeaf is set and the leaf has none.
+func (s *OCSPStaples) checkChain(chain []*Certificate, now time.Time, requireLeaf bool) error {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for i := 0; i < len(chain)-1; i++ {
+		cert := chain[i]
+		single, err := s.verify(chain, i, now)
+		if err != nil {
+			return err
+		}
+		if single == nil {
+			if i == 0 && requireLeaf {
+				return fmt.Errorf("x509: no OCSP response stapled for %q", cert.Subject)
+			}
+			continue
+		}
+		switch single.Status {
+		case OCSPRevoked:
+			return CertificateInvalidError{cert, Revoked, fmt.Sprintf("revoked at %s, reason %v", single.RevokedAt.Format(time.RFC3339), single.RevocationReason)}
+		case OCSPUnknown:
+			return fmt.Errorf("x509: %q is unknown to its OCSP responder", cert.Subject)
+		}
+	}
+	return nil
+}
+
+// checkTime checks that r is current at now. name identifies the
+// certificate in errors.
+func (r *OCSPSingleResponse) checkTime(name string, now time.Time) error {
+	if now.Before(r.ThisUpdate) {
+		return fmt.Errorf("x509: OCSP response for %s is not yet valid: current time %s is before %s", name, now.Format(time.RFC3339), r.ThisUpdate.Format(time.RFC3339))
+	}
+	if !r.NextUpdate.IsZero() && now.After(r.NextUpdate) {
+		return fmt.Errorf("x509: OCSP response for %s has expired: current time %s is after %s", name, now.Format(time.RFC3339), r.NextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// basicOCSPResponse is a parsed BasicOCSPResponse, RFC 6960, Section 4.2.1.
+type basicOCSPResponse struct {
+	rawTBS             []byte
+	signatureAlgorithm SignatureAlgorithm
+	signature          []byte
+	certs              []*Certificate
+	responses          []OCSPSingleResponse
+	nonce              []byte // nil if the response has no nonce extension
+}
+
+// verify checks that the response was signed by issuer, or by a delegated
+// responder for issuer.
+func (b *basicOCSPResponse) verify(issuer *Certificate, now time.Time) error {
+	if issuer.CheckSignature(b.signatureAlgorithm, b.rawTBS, b.signature) == nil {
+		return nil
+	}
+	for _, responder := range b.certs {
+		if _, err := VerifyOCSPResponder(responder, issuer, now); err != nil {
+			continue
+		}
+		if responder.CheckSignature(b.signatureAlgorithm, b.rawTBS, b.signature) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("x509: OCSP response is not signed by %q or an authorized responder", issuer.Subject)
+}
+
+// parseOCSPResponse parses the successful OCSP response der.
+func parseOCSPResponse(der []byte) (*basicOCSPResponse, error) {
+	// OCSPResponse ::= SEQUENCE {
+	//    responseStatus         OCSPResponseStatus,
+	//    responseBytes          [0] EXPLICIT ResponseBytes OPTIONAL }
+	//
+	// ResponseBytes ::=       SEQUENCE {
+	//    responseType   OBJECT IDENTIFIER,
+	//    response       OCTET STRING }
+	input := cryptobyte.String(der)
+	var resp, responseBytes, response cryptobyte.String
+	var status int
+	var responseType asn1.ObjectIdentifier
+	if !input.ReadASN1(&resp, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!resp.ReadASN1Enum(&status) {
+		return nil, errMalformedOCSPResponse
+	}
+	if status != 0 {
+		return nil, errors.New("x509: OCSP response status is not successful")
+	}
+	if !resp.ReadASN1(&responseBytes, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!responseBytes.ReadASN1(&responseBytes, cryptobyte_asn1.SEQUENCE) ||
+		!responseBytes.ReadASN1ObjectIdentifier(&responseType) ||
+		!responseBytes.ReadASN1(&response, cryptobyte_asn1.OCTET_STRING) {
+		return nil, errMalformedOCSPResponse
+	}
+	if !responseType.Equal(oidOCSPBasic) {
+		return nil, fmt.Errorf("x509: unsupported OCSP response type %v", responseType)
+	}
+
+	// BasicOCSPResponse       ::= SEQUENCE {
+	//    tbsResponseData      ResponseData,
+	//    signatureAlgorithm   AlgorithmIdentifier,
+	//    signature            BIT STRING,
+	//    certs            [0] EXPLICIT SEQUENCE OF Certificate OPTIONAL }
+	//
+	// ResponseData ::= SEQUENCE {
+	//    version              [0] EXPLICIT Version DEFAULT v1,
+	//    responderID              ResponderID,
+	//    producedAt               GeneralizedTime,
+	//    responses                SEQUENCE OF SingleResponse,
+	//    responseExtensions   [1] EXPLICIT Extensions OPTIONAL }
+	var basic, rawTBS, tbs, rawAlgorithm, responses, certs cryptobyte.String
+	var signature asn1.BitString
+	var hasCerts bool
+	if !response.ReadASN1(&basic, cryptobyte_asn1.SEQUENCE) ||
+		!basic.ReadASN1Element(&rawTBS, cryptobyte_asn1.SEQUENCE) ||
+		!basic.ReadASN1Element(&rawAlgorithm, cryptobyte_asn1.SEQUENCE) ||
+		!basic.ReadASN1BitString(&signature) ||
+		!basic.ReadOptionalASN1(&certs, &hasCerts, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+		return nil, errMalformedOCSPResponse
+	}
+	var algorithm pkix.AlgorithmIdentifier
+	if rest, err := asn1.Unmarshal(rawAlgorithm, &algorithm); err != nil || len(rest) != 0 {
+		return nil, errMalformedOCSPResponse
+	}
+	b := &basicOCSPResponse{
+		rawTBS:             rawTBS,
+		signatureAlgorithm: getSignatureAlgorithmFromAI(algorithm),
+		signature:          signature.RightAlign(),
+	}
+	if hasCerts {
+		var seq cryptobyte.String
+		if !certs.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) {
+			return nil, errMalformedOCSPResponse
+		}
+		var err error
+		if b.certs, err = ParseCertificates(seq); err != nil {
+			return nil, err
+		}
+	}
+
+	var responderID cryptobyte.String
+	var responderTag cryptobyte_asn1.Tag
+	if !rawTBS.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!tbs.ReadAnyASN1(&responderID, &responderTag) ||
+		!tbs.SkipASN1(cryptobyte_asn1.GeneralizedTime) ||
+		!tbs.ReadASN1(&responses, cryptobyte_asn1.SEQUENCE) {
+		return nil, errMalformedOCSPResponse
+	}
+	for !responses.Empty() {
+		var single cryptobyte.String
+		if !responses.ReadASN1(&single, cryptobyte_asn1.SEQUENCE) {
+			return nil, errMalformedOCSPResponse
+		}
+		r, err := parseOCSPSingleResponse(single)
+		if err != nil {
+			return nil, err
+		}
+		b.responses = append(b.responses, r)
+	}
+	var nonce cryptobyte.String
+	var hasNonce bool
+	if !readOCSPNonce(&tbs, &nonce, &hasNonce, 1) {
+		return nil, errMalformedOCSPResponse
+	}
+	if hasNonce {
+		b.nonce = nonce
+	}
+	return b, nil
+}
+
+var (
+	ocspGoodTag    = cryptobyte_asn1.Tag(0).ContextSpecific()
+	ocspRevokedTag = cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()
+	ocspUnknownTag = cryptobyte_asn1.Tag(2).ContextSpecific()
+)
+
+// parseOCSPSingleResponse parses the contents of a SingleResponse.
+func parseOCSPSingleResponse(single cryptobyte.String) (OCSPSingleResponse, error) {
+	// SingleResponse ::= SEQUENCE {
+	//    certID                       CertID,
+	//    certStatus                   CertStatus,
+	//    thisUpdate                   GeneralizedTime,
+	//    nextUpdate         [0]       EXPLICIT GeneralizedTime OPTIONAL,
+	//    singleExtensions   [1]       EXPLICIT Extensions OPTIONAL }
+	var r OCSPSingleResponse
+	var status, nextUpdate cryptobyte.String
+	var statusTag cryptobyte_asn1.Tag
+	var hasNextUpdate bool
+	if !readOCSPCertID(&single, &r.CertID) ||
+		!single.ReadAnyASN1(&status, &statusTag) ||
+		!single.ReadASN1GeneralizedTime(&r.ThisUpdate) ||
+		!single.ReadOptionalASN1(&nextUpdate, &hasNextUpdate, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+		return r, errMalformedOCSPResponse
+	}
+	if hasNextUpdate && !nextUpdate.ReadASN1GeneralizedTime(&r.NextUpdate) {
+		return r, errMalformedOCSPResponse
+	}
+
+	switch statusTag {
+	case ocspGoodTag:
+		r.Status = OCSPGood
+	case ocspUnknownTag:
+		r.Status = OCSPUnknown
+	case ocspRevokedTag:
+		// RevokedInfo ::= SEQUENCE {
+		//    revocationTime              GeneralizedTime,
+		//    revocationReason    [0]     EXPLICIT CRLReason OPTIONAL }
+		var reason cryptobyte.String
+		var hasReason bool
+		r.Status = OCSPRevoked
+		if !status.ReadASN1GeneralizedTime(&r.RevokedAt) ||
+			!status.ReadOptionalASN1(&reason, &hasReason, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+			return r, errMalformedOCSPResponse
+		}
+		if hasReason {
+			var code int
+			if !reason.ReadASN1Enum(&code) {
+				return r, errMalformedOCSPResponse
+			}
+			r.RevocationReason = CRLReason(code)
+		}
+	default:
+		return r, errMalformedOCSPResponse
+	}
+	return r, nil
+}
+
+// readOCSPCertID reads a CertID from s into id. The hash algorithm is left
+// zero if it is not supported.
+func readOCSPCertID(s *cryptobyte.String, id *OCSPCertID) bool {
+	// CertID          ::=     SEQUENCE {
+	//    hashAlgorithm       AlgorithmIdentifier,
+	//    issuerNameHash      OCTET STRING,
+	//    issuerKeyHash       OCTET STRING,
+	//    serialNumber        CertificateSerialNumber }
+	var certID, hashAlgorithm cryptobyte.String
+	var hashOID asn1.ObjectIdentifier
+	id.SerialNumber = new(big.Int)
+	if !s.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAlgorithm, cryptobyte_asn1.SEQUENCE) ||
+		!hashAlgorithm.ReadASN1ObjectIdentifier(&hashOID) ||
+		!certID.ReadASN1Bytes(&id.IssuerNameHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Bytes(&id.IssuerKeyHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Integer(id.SerialNumber) {
+		return false
+	}
+	for _, a := range ocspHashAlgorithm {
+		if a.oid.Equal(hashOID) {
+			id.HashAlgorithm = a.hash
+		}
+	}
+	return true
+}
+
+// addOCSPCertID adds id to b as a CertID.
+func addOCSPCertID(b *cryptobyte.Builder, id OCSPCertID) {
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(ocspHashOID(id.HashAlgorithm))
+			b.AddASN1NULL()
+		})
+		b.AddASN1OctetString(id.IssuerNameHash)
+		b.AddASN1OctetString(id.IssuerKeyHash)
+		b.AddASN1BigInt(id.SerialNumber)
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+type testOCSPSingle struct {
+	id         OCSPCertID
+	status     OCSPStatus
+	reason     CRLReason
+	nextUpdate time.Time
+}
+
+// makeOCSPResponse returns an OCSP response for singles, signed by key and
+// including certs.
+func makeOCSPResponse(t *testing.T, key crypto.Signer, certs []*Certificate, now time.Time, singles ...testOCSPSingle) []byte {
+	return makeOCSPResponseWithNonce(t, key, certs, now, nil, singles...)
+}
+
+// makeOCSPResponseWithNonce is like makeOCSPResponse, but adds a nonce
+// extension if nonce is not nil.
+func makeOCSPResponseWithNonce(t *testing.T, key crypto.Signer, certs []*Certificate, now time.Time, nonce []byte, singles ...testOCSPSingle) []byte {
+	explicit := func(n uint8) cryptobyte_asn1.Tag { return cryptobyte_asn1.Tag(n).ContextSpecific().Constructed() }
+	var tbs cryptobyte.Builder
+	tbs.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(explicit(2), func(b *cryptobyte.Builder) {
+			b.AddASN1OctetString([]byte("responder key hash"))
+		})
+		b.AddASN1GeneralizedTime(now)
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, s := range singles {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(ocspHashOID(s.id.HashAlgorithm))
+							b.AddASN1NULL()
+						})
+						b.AddASN1OctetString(s.id.IssuerNameHash)
+						b.AddASN1OctetString(s.id.IssuerKeyHash)
+						b.AddASN1BigInt(s.id.SerialNumber)
+					})
+					switch s.status {
+					case OCSPGood:
+						b.AddASN1(ocspGoodTag, func(b *cryptobyte.Builder) {})
+					case OCSPUnknown:
+						b.AddASN1(ocspUnknownTag, func(b *cryptobyte.Builder) {})
+					case OCSPRevoked:
+						b.AddASN1(ocspRevokedTag, func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(now.Add(-time.Hour))
+							b.AddASN1(explicit(0), func(b *cryptobyte.Builder) {
+								b.AddASN1Enum(int64(s.reason))
+							})
+						})
+					}
+					b.AddASN1GeneralizedTime(now.Add(-time.Minute))
+					if !s.nextUpdate.IsZero() {
+						b.AddASN1(explicit(0), func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(s.nextUpdate)
+						})
+					}
+				})
+			}
+		})
+		if nonce != nil {
+			b.AddASN1(explicit(1), func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1ObjectIdentifier(oidOCSPNonce)
+						b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+							b.AddASN1OctetString(nonce)
+						})
+					})
+				})
+			})
+		}
+	})
+	rawTBS := tbs.BytesOrPanic()
+	digest := sha256.Sum256(rawTBS)
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Enum(0)
+		b.AddASN1(explicit(0), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidOCSPBasic)
+				b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddBytes(rawTBS)
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSignatureECDSAWithSHA256)
+						})
+						b.AddASN1BitString(signature)
+						if len(certs) > 0 {
+							b.AddASN1(explicit(0), func(b *cryptobyte.Builder) {
+								b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+									for _, c := range certs {
+										b.AddBytes(c.Raw)
+									}
+								})
+							})
+						}
+					})
+				})
+			})
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+func TestOCSPStaples(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Certificate{leaf, inter, root}
+
+	now := time.Now()
+	responderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "OCSP responder"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []ExtKeyUsage{ExtKeyUsageOCSPSigning},
+	}, root, responderKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := func(cert, issuer *Certificate, h crypto.Hash) OCSPCertID {
+		id, err := NewOCSPCertID(cert, issuer, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	leafID, interID := id(leaf, inter, crypto.SHA1), id(inter, root, crypto.SHA256)
+	nextUpdate := now.Add(time.Hour)
+	leafResp := makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, testOCSPSingle{id: leafID, nextUpdate: nextUpdate})
+	interResp := makeOCSPResponse(t, responderKey, []*Certificate{responder}, now, testOCSPSingle{id: interID, nextUpdate: nextUpdate})
+
+	staples, err := ParseOCSPStaples([][]byte{interResp, nil, leafResp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := staples.ResponseList(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || !bytes.Equal(list[0], leafResp) || !bytes.Equal(list[1], interResp) || list[2] != nil {
+		t.Errorf("ResponseList returned the wrong responses")
+	}
+	if der, single, ok := staples.Lookup(leafID); !ok || !bytes.Equal(der, leafResp) || !single.CertID.Equal(leafID) {
+		t.Errorf("Lookup(leafID) = %v, %v", single, ok)
+	}
+	if _, _, ok := staples.Lookup(id(leaf, inter, crypto.SHA256)); ok {
+		t.Error("Lookup succeeded with a different hash algorithm")
+	}
+	singles, err := staples.VerifyChain(chain, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(singles) != 2 || singles[0].Status != OCSPGood || singles[1].Status != OCSPGood ||
+		!singles[1].NextUpdate.Equal(nextUpdate.Truncate(time.Second)) {
+		t.Errorf("VerifyChain returned %+v", singles)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		leafResp []byte
+		singles  int
+	}{
+		{"revoked", makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, testOCSPSingle{id: leafID, status: OCSPRevoked, reason: CRLReasonKeyCompromise}), 1},
+		{"unknown", makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, testOCSPSingle{id: leafID, status: OCSPUnknown}), 1},
+		{"expired", makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, testOCSPSingle{id: leafID, nextUpdate: now.Add(-time.Second)}), 0},
+		{"wrong signer", makeOCSPResponse(t, otherKey, nil, now, testOCSPSingle{id: leafID}), 0},
+		{"unauthorized responder", makeOCSPResponse(t, responderKey, []*Certificate{responder}, now, testOCSPSingle{id: leafID}), 0},
+		{"missing", nil, 0},
+	}
+	for _, tt := range tests {
+		staples, err := ParseOCSPStaples([][]byte{tt.leafResp, interResp})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		singles, err := staples.VerifyChain(chain, now)
+		if err == nil {
+			t.Errorf("%s: VerifyChain succeeded", tt.name)
+		}
+		if len(singles) != tt.singles {
+			t.Errorf("%s: got %d single responses, want %d", tt.name, len(singles), tt.singles)
+		}
+		if tt.name == "revoked" && singles[0].RevocationReason != CRLReasonKeyCompromise {
+			t.Errorf("revoked: got reason %v", singles[0].RevocationReason)
+		}
+	}
+
+	// A single response can cover several certificates of the chain.
+	both := makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, testOCSPSingle{id: leafID}, testOCSPSingle{id: interID})
+	staples = &OCSPStaples{}
+	if err := staples.Add(both); err != nil {
+		t.Fatal(err)
+	}
+	if singles, err := staples.VerifyChain(chain, now); err == nil || len(singles) != 1 {
+		t.Errorf("response for the intermediate signed by the intermediate: got %v, %v", singles, err)
+	}
+
+	if _, err := ParseOCSPStaples([][]byte{{0x30, 0x03, 0x0a, 0x01, 0x00}}); err == nil {
+		t.Error("malformed response was accepted")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 8954, Section 2.1
+//
+// id-pkix-ocsp-nonce OBJECT IDENTIFIER ::= { id-pkix-ocsp 2 }
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// maxOCSPNonceLen is the maximum length of a nonce, RFC 8954, Section 2.1.
+const maxOCSPNonceLen = 32
+
+var errMalformedOCSPRequest = errors.New("x509: malformed OCSP request")
+
+// OCSPRequest is an unsigned OCSP request, RFC 6960, Section 4.1.1.
+type OCSPRequest struct {
+	// CertIDs identifies the certificates whose status is requested, as
+	// returned by NewOCSPCertID.
+	CertIDs []OCSPCertID
+
+	// Nonce, if not empty, is sent in a nonce extension, RFC 8954, and must
+	// be echoed by the response for VerifyOCSPResponse to accept it, which
+	// prevents the replay of older responses. Responders that serve
+	// pre-signed responses ignore it. It must be at most 32 bytes long.
+	Nonce []byte
+}
+
+// CreateOCSPRequest returns the DER encoding of req, to be sent to an OCSP
+// responder.
+func CreateOCSPRequest(req *OCSPRequest) ([]byte, error) {
+	if len(req.CertIDs) == 0 {
+		return nil, errors.New("x509: OCSP request without certificates")
+	}
+	for _, id := range req.CertIDs {
+		if ocspHashOID(id.HashAlgorithm) == nil || id.SerialNumber == nil {
+			return nil, errors.New("x509: invalid OCSPCertID in OCSP request")
+		}
+	}
+	if len(req.Nonce) > maxOCSPNonceLen {
+		return nil, fmt.Errorf("x509: OCSP nonce is %d bytes long, longer than %d", len(req.Nonce), maxOCSPNonceLen)
+	}
+
+	// OCSPRequest     ::=     SEQUENCE {
+	//    tbsRequest                  TBSRequest,
+	//    optionalSignature   [0]     EXPLICIT Signature OPTIONAL }
+	//
+	// TBSRequest      ::=     SEQUENCE {
+	//    version             [0]     EXPLICIT Version DEFAULT v1,
+	//    requestorName       [1]     EXPLICIT GeneralName OPTIONAL,
+	//    requestList                 SEQUENCE OF Request,
+	//    requestExtensions   [2]     EXPLICIT Extensions OPTIONAL }
+	//
+	// Request         ::=     SEQUENCE {
+	//    reqCert                     CertID,
+	//    singleRequestExtensions     [0] EXPLICIT Extensions OPTIONAL }
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				for _, id := range req.CertIDs {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						addOCSPCertID(b, id)
+					})
+				}
+			})
+			if len(req.Nonce) > 0 {
+				b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidOCSPNonce)
+							b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+								b.AddASN1OctetString(req.Nonce)
+							})
+						})
+					})
+				})
+			}
+		})
+	})
+	return b.Bytes()
+}
+
+// ParseOCSPRequest parses a DER encoded OCSP request. The signature of a
+// signed request is not verified.
+func ParseOCSPRequest(der []byte) (*OCSPRequest, error) {
+	input := cryptobyte.String(der)
+	var req, tbs, requests cryptobyte.String
+	if !input.ReadASN1(&req, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!req.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!req.SkipOptionalASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) || !req.Empty() ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(1).ContextSpecific().Constructed()) ||
+		!tbs.ReadASN1(&requests, cryptobyte_asn1.SEQUENCE) {
+		return nil, errMalformedOCSPRequest
+	}
+	out := new(OCSPRequest)
+	for !requests.Empty() {
+		var request cryptobyte.String
+		var id OCSPCertID
+		if !requests.ReadASN1(&request, cryptobyte_asn1.SEQUENCE) ||
+			!readOCSPCertID(&request, &id) {
+			return nil, errMalformedOCSPRequest
+		}
+		out.CertIDs = append(out.CertIDs, id)
+	}
+	var nonce cryptobyte.String
+	var hasNonce bool
+	if !readOCSPNonce(&tbs, &nonce, &hasNonce, 2) || !tbs.Empty() {
+		return nil, errMalformedOCSPRequest
+	}
+	if hasNonce {
+		out.Nonce = nonce
+	}
+	return out, nil
+}
+
+// readOCSPNonce reads the optional [tag] EXPLICIT Extensions field from s,
+// and sets nonce to the value of its nonce extension, if any.
+func readOCSPNonce(s *cryptobyte.String, nonce *cryptobyte.String, hasNonce *bool, tag uint8) bool {
+	var exts cryptobyte.String
+	var hasExts bool
+	if !s.ReadOptionalASN1(&exts, &hasExts, cryptobyte_asn1.Tag(tag).ContextSpecific().Constructed()) {
+		return false
+	}
+	*hasNonce = false
+	if !hasExts {
+		return true
+	}
+	if !exts.ReadASN1(&exts, cryptobyte_asn1.SEQUENCE) {
+		return false
+	}
+	for !exts.Empty() {
+		var ext, value cryptobyte.String
+		var id asn1.ObjectIdentifier
+		if !exts.ReadASN1(&ext, cryptobyte_asn1.SEQUENCE) ||
+			!ext.ReadASN1ObjectIdentifier(&id) ||
+			!ext.SkipOptionalASN1(cryptobyte_asn1.BOOLEAN) ||
+			!ext.ReadASN1(&value, cryptobyte_asn1.OCTET_STRING) {
+			return false
+		}
+		if !id.Equal(oidOCSPNonce) {
+			continue
+		}
+		// RFC 8954 encodes the nonce as an OCTET STRING in the extension
+		// value, but responders following RFC 2560 use the value itself.
+		var inner cryptobyte.String
+		if v := value; v.ReadASN1(&inner, cryptobyte_asn1.OCTET_STRING) && v.Empty() {
+			value = inner
+		}
+		*nonce, *hasNonce = value, true
+	}
+	return true
+}
+
+// VerifyOCSPResponse verifies der, an OCSP response to req for certificates
+// issued by issuer, and returns its single response for each certificate of
+// req, in order.
+//
+// The response must be signed by issuer, or by a delegated responder
+// included in the response and accepted by VerifyOCSPResponder. It must
+// echo the nonce of req, if any, and each single response must be current
+// at now, or the current time if zero. The status of the certificates is
+// returned, not checked.
+func VerifyOCSPResponse(der []byte, req *OCSPRequest, issuer *Certificate, now time.Time) ([]OCSPSingleResponse, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	basic, err := parseOCSPResponse(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := basic.verify(issuer, now); err != nil {
+		return nil, err
+	}
+	if len(req.Nonce) > 0 {
+		if basic.nonce == nil {
+			return nil, errors.New("x509: OCSP response has no nonce")
+		}
+		if !bytes.Equal(basic.nonce, req.Nonce) {
+			return nil, errors.New("x509: OCSP response nonce doesn't match the request")
+		}
+	}
+
+	singles := make([]OCSPSingleResponse, 0, len(req.CertIDs))
+	for _, id := range req.CertIDs {
+		found := false
+		for _, single := range basic.responses {
+			if !single.CertID.Equal(id) {
+				continue
+			}
+			if err := single.checkTime(fmt.Sprintf("serial %v", id.SerialNumber), now); err != nil {
+				return nil, err
+			}
+			singles = append(singles, single)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("x509: OCSP response doesn't cover serial %v", id.SerialNumber)
+		}
+	}
+	return singles, nil
+}
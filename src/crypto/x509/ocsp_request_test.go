@@ -0,0 +1,154 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+	"time"
+)
+
+func TestOCSPRequest(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := generateCert("Other", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewOCSPCertID(leaf, root, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := NewOCSPCertID(other, root, crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := []byte("0123456789abcdef")
+	req := &OCSPRequest{CertIDs: []OCSPCertID{id, otherID}, Nonce: nonce}
+	der, err := CreateOCSPRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseOCSPRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.CertIDs) != 2 || !parsed.CertIDs[0].Equal(id) || !parsed.CertIDs[1].Equal(otherID) ||
+		!bytes.Equal(parsed.Nonce, nonce) {
+		t.Errorf("ParseOCSPRequest returned %+v", parsed)
+	}
+	if _, err := ParseOCSPRequest(der[:len(der)-1]); err == nil {
+		t.Error("truncated request was parsed")
+	}
+	if _, err := CreateOCSPRequest(&OCSPRequest{CertIDs: req.CertIDs, Nonce: make([]byte, 33)}); err == nil {
+		t.Error("33-byte nonce was accepted")
+	}
+	if _, err := CreateOCSPRequest(&OCSPRequest{}); err == nil {
+		t.Error("request without certificates was accepted")
+	}
+
+	now := time.Now()
+	key := rootKey.(crypto.Signer)
+	singles := []testOCSPSingle{{id: otherID, status: OCSPRevoked}, {id: id}}
+	resp := makeOCSPResponseWithNonce(t, key, nil, now, nonce, singles...)
+	got, err := VerifyOCSPResponse(resp, req, root, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].CertID.Equal(id) || got[0].Status != OCSPGood || got[1].Status != OCSPRevoked {
+		t.Errorf("VerifyOCSPResponse returned %+v", got)
+	}
+
+	tests := []struct {
+		name string
+		resp []byte
+		req  *OCSPRequest
+	}{
+		{"wrong nonce", makeOCSPResponseWithNonce(t, key, nil, now, []byte("replayed"), singles...), req},
+		{"missing nonce", makeOCSPResponse(t, key, nil, now, singles...), req},
+		{"missing certificate", makeOCSPResponseWithNonce(t, key, nil, now, nonce, singles[1]), req},
+		{"expired", makeOCSPResponse(t, key, nil, now, testOCSPSingle{id: id, nextUpdate: now.Add(-time.Second)}), &OCSPRequest{CertIDs: []OCSPCertID{id}}},
+	}
+	for _, tt := range tests {
+		if _, err := VerifyOCSPResponse(tt.resp, tt.req, root, now); err == nil {
+			t.Errorf("%s: VerifyOCSPResponse succeeded", tt.name)
+		}
+	}
+	// Without a nonce in the request, the nonce of the response is ignored.
+	if _, err := VerifyOCSPResponse(resp, &OCSPRequest{CertIDs: []OCSPCertID{id}}, root, now); err != nil {
+		t.Errorf("request without nonce: %v", err)
+	}
+	if _, err := VerifyOCSPResponse(resp, req, leaf, now); err == nil {
+		t.Error("response verified with the wrong issuer")
+	}
+}
+
+func TestVerifyOCSPStaples(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafID, err := NewOCSPCertID(leaf, inter, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	staples := func(singles ...testOCSPSingle) *OCSPStaples {
+		s, err := ParseOCSPStaples([][]byte{makeOCSPResponse(t, interKey.(crypto.Signer), nil, now, singles...)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+	opts := VerifyOptions{Roots: roots, Intermediates: intermediates, OCSPStaples: staples(testOCSPSingle{id: leafID})}
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("good staple: %v", err)
+	}
+	// The intermediate has no staple, which is only required for the leaf.
+	opts.RequireOCSPStaple = true
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("good staple, required: %v", err)
+	}
+
+	opts.OCSPStaples = staples(testOCSPSingle{id: leafID, status: OCSPRevoked, reason: CRLReasonKeyCompromise})
+	_, err = leaf.Verify(opts)
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != Revoked || e.Cert != leaf {
+		t.Errorf("revoked staple: got %v", err)
+	}
+	opts.OCSPStaples = staples(testOCSPSingle{id: leafID, nextUpdate: now.Add(-time.Second)})
+	if _, err := leaf.Verify(opts); err == nil {
+		t.Error("expired staple was accepted")
+	}
+
+	opts.OCSPStaples = nil
+	if _, err := leaf.Verify(opts); err == nil {
+		t.Error("missing required staple was accepted")
+	}
+	opts.RequireOCSPStaple = false
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("no staple: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifyOCSPResponder(t *testing.T) {
+	ca, caKey, err := generateCert("CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, otherCAKey, err := generateCert("Other CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	issue := func(eku []ExtKeyUsage, noCheck bool, parent *Certificate, parentKey interface{}) *Certificate {
+		template := &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "OCSP responder"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			ExtKeyUsage:  eku,
+		}
+		if noCheck {
+			template.ExtraExtensions = []pkix.Extension{{Id: oidExtensionOCSPNoCheck, Value: []byte{0x05, 0x00}}}
+		}
+		derBytes, err := CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(derBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	if noCheck, err := VerifyOCSPResponder(ca, ca, now); err != nil || noCheck {
+		t.Errorf("CA as its own responder: got %v, %v", noCheck, err)
+	}
+
+	delegated := issue([]ExtKeyUsage{ExtKeyUsageOCSPSigning}, true, ca, caKey)
+	if noCheck, err := VerifyOCSPResponder(delegated, ca, now); err != nil || !noCheck {
+		t.Errorf("delegated responder: got %v, %v", noCheck, err)
+	}
+	if _, err := VerifyOCSPResponder(delegated, ca, now.Add(2*time.Hour)); err == nil {
+		t.Error("expired responder was accepted")
+	}
+
+	withoutCheck := issue([]ExtKeyUsage{ExtKeyUsageOCSPSigning}, false, ca, caKey)
+	if noCheck, err := VerifyOCSPResponder(withoutCheck, ca, now); err != nil || noCheck {
+		t.Errorf("responder without nocheck: got %v, %v", noCheck, err)
+	}
+
+	for _, eku := range [][]ExtKeyUsage{nil, {ExtKeyUsageAny}, {ExtKeyUsageServerAuth}} {
+		if _, err := VerifyOCSPResponder(issue(eku, false, ca, caKey), ca, now); err == nil {
+			t.Errorf("responder with extended key usage %v was accepted", eku)
+		}
+	}
+
+	if _, err := VerifyOCSPResponder(issue([]ExtKeyUsage{ExtKeyUsageOCSPSigning}, false, otherCA, otherCAKey), ca, now); err == nil {
+		t.Error("responder issued by a different CA was accepted")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var errInvalidOID = errors.New("x509: invalid object identifier")
+
+// An OID represents an ASN.1 OBJECT IDENTIFIER. Unlike
+// asn1.ObjectIdentifier, it can hold arcs of any size, such as the UUID
+// based arcs under 2.25, which encoding/asn1 rejects as too large.
+//
+// The zero OID is not a valid object identifier.
+type OID struct {
+	der []byte // contents of the DER encoding, without tag and length
+}
+
+// An OIDExtension is an extension whose identifier has an arc too large for
+// asn1.ObjectIdentifier, and which therefore can't be held in a
+// pkix.Extension.
+type OIDExtension struct {
+	Id       OID
+	Critical bool
+	Value    []byte
+}
+
+// OIDFromInts returns the OID with the given arcs.
+func OIDFromInts(arcs []uint64) (OID, error) {
+	if len(arcs) < 2 || arcs[0] > 2 || (arcs[0] < 2 && arcs[1] >= 40) ||
+		arcs[1] > math.MaxUint64-80 {
+		return OID{}, errInvalidOID
+	}
+	der := appendBase128Uint(nil, arcs[0]*40+arcs[1])
+	for _, arc := range arcs[2:] {
+		der = appendBase128Uint(der, arc)
+	}
+	return OID{der}, nil
+}
+
+// ParseOID parses an OID in dotted decimal form, for example
+// "2.25.329800735698586629295641978511506172918". Unlike with OIDFromInts,
+// the arcs can be of any size.
+func ParseOID(s string) (OID, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return OID{}, errInvalidOID
+	}
+	arcs := make([]*big.Int, len(parts))
+	for i, part := range parts {
+		// Reject signs, empty arcs and leading zeros, which SetString
+		// would accept.
+		if part == "" || part[0] < '0' || part[0] > '9' || len(part) > 1 && part[0] == '0' {
+			return OID{}, errInvalidOID
+		}
+		arc, ok := new(big.Int).SetString(part, 10)
+		if !ok {
+			return OID{}, errInvalidOID
+		}
+		arcs[i] = arc
+	}
+	if arcs[0].Cmp(big.NewInt(2)) > 0 || arcs[0].Cmp(big.NewInt(2)) < 0 && arcs[1].Cmp(big.NewInt(40)) >= 0 {
+		return OID{}, errInvalidOID
+	}
+	first := new(big.Int).Mul(arcs[0], big.NewInt(40))
+	der := appendBase128Big(nil, first.Add(first, arcs[1]))
+	for _, arc := range arcs[2:] {
+		der = appendBase128Big(der, arc)
+	}
+	return OID{der}, nil
+}
+
+func appendBase128Big(dst []byte, n *big.Int) []byte {
+	if n.IsUint64() {
+		return appendBase128Uint(dst, n.Uint64())
+	}
+	l := (n.BitLen() + 6) / 7
+	v, mask := new(big.Int), big.NewInt(0x7f)
+	for i := l - 1; i >= 0; i-- {
+		b := byte(v.Rsh(n, uint(i*7)).And(v, mask).Uint64())
+		if i != 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+func appendBase128Uint(dst []byte, n uint64) []byte {
+	l := 1
+	for i := n >> 7; i > 0; i >>= 7 {
+		l++
+	}
+	for i := l - 1; i >= 0; i-- {
+		b := byte(n>>uint(i*7)) & 0x7f
+		if i != 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// newOIDFromDER returns the OID with the given DER contents, or false if der
+// is not a valid encoding.
+func newOIDFromDER(der []byte) (OID, bool) {
+	if len(der) == 0 || der[len(der)-1]&0x80 != 0 {
+		return OID{}, false
+	}
+	start := 0
+	for i, b := range der {
+		// X.690, 8.19.2: each subidentifier is encoded in the fewest
+		// possible octets, so it can't start with 0x80.
+		if i == start && b == 0x80 {
+			return OID{}, false
+		}
+		if b&0x80 == 0 {
+			start = i + 1
+		}
+	}
+	return OID{der}, true
+}
+
+// readOID reads an OBJECT IDENTIFIER of any size from s.
+func readOID(s *cryptobyte.String, out *OID) bool {
+	var der cryptobyte.String
+	if !s.ReadASN1(&der, cryptobyte_asn1.OBJECT_IDENTIFIER) {
+		return false
+	}
+	oid, ok := newOIDFromDER(der)
+	*out = oid
+	return ok
+}
+
+// addOID appends the DER encoding of oid to b.
+func addOID(b *cryptobyte.Builder, oid OID) {
+	if len(oid.der) == 0 {
+		b.SetError(errInvalidOID)
+		return
+	}
+	b.AddASN1(cryptobyte_asn1.OBJECT_IDENTIFIER, func(b *cryptobyte.Builder) {
+		b.AddBytes(oid.der)
+	})
+}
+
+// Equal reports whether oid and other represent the same identifier.
+func (oid OID) Equal(other OID) bool {
+	return bytes.Equal(oid.der, other.der)
+}
+
+// EqualASN1OID reports whether oid and other represent the same identifier.
+func (oid OID) EqualASN1OID(other asn1.ObjectIdentifier) bool {
+	o, ok := oid.toASN1OID()
+	return ok && o.Equal(other)
+}
+
+// toASN1OID returns oid as an asn1.ObjectIdentifier, or false if one of its
+// arcs does not fit in an int.
+func (oid OID) toASN1OID() (asn1.ObjectIdentifier, bool) {
+	var out asn1.ObjectIdentifier
+	der := oid.der
+	for len(der) > 0 {
+		var arc []byte
+		arc, der = nextArc(der)
+		n, ok := arcUint(arc)
+		if !ok || n > math.MaxInt64>>(64-strconv.IntSize) {
+			return nil, false
+		}
+		if len(out) == 0 {
+			if n < 80 {
+				out = append(out, int(n/40), int(n%40))
+			} else {
+				out = append(out, 2, int(n-80))
+			}
+			continue
+		}
+		out = append(out, int(n))
+	}
+	if len(out) == 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// String returns the dotted decimal form of oid, for example "2.5.29.32".
+func (oid OID) String() string {
+	var buf []byte
+	der := oid.der
+	for first := true; len(der) > 0; first = false {
+		var arc []byte
+		arc, der = nextArc(der)
+		n, ok := arcUint(arc)
+		switch {
+		case !first:
+			buf = append(buf, '.')
+			if ok {
+				buf = strconv.AppendUint(buf, n, 10)
+			} else {
+				buf = arcBig(arc).Append(buf, 10)
+			}
+		case ok && n < 80:
+			buf = strconv.AppendUint(buf, n/40, 10)
+			buf = append(buf, '.')
+			buf = strconv.AppendUint(buf, n%40, 10)
+		case ok:
+			buf = append(buf, "2."...)
+			buf = strconv.AppendUint(buf, n-80, 10)
+		default:
+			v := arcBig(arc)
+			buf = append(buf, "2."...)
+			buf = v.Sub(v, big.NewInt(80)).Append(buf, 10)
+		}
+	}
+	return string(buf)
+}
+
+// nextArc splits the encoding of the first subidentifier off der.
+func nextArc(der []byte) (arc, rest []byte) {
+	n := 0
+	for n < len(der)-1 && der[n]&0x80 != 0 {
+		n++
+	}
+	return der[:n+1], der[n+1:]
+}
+
+// arcUint decodes a subidentifier, or returns false if it does not fit in a
+// uint64.
+func arcUint(arc []byte) (uint64, bool) {
+	var n uint64
+	for _, b := range arc {
+		if n>>57 != 0 {
+			return 0, false
+		}
+		n = n<<7 | uint64(b&0x7f)
+	}
+	return n, true
+}
+
+// arcBig decodes a subidentifier of any size.
+func arcBig(arc []byte) *big.Int {
+	n := new(big.Int)
+	for _, b := range arc {
+		n.Lsh(n, 7)
+		n.Or(n, big.NewInt(int64(b&0x7f)))
+	}
+	return n
+}
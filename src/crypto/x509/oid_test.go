@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestOID(t *testing.T) {
+	tests := []struct {
+		s    string
+		der  []byte
+		asn1 asn1.ObjectIdentifier // nil if an arc does not fit in an int
+		arcs []uint64              // nil if an arc does not fit in a uint64
+	}{
+		{"2.5.29.32", []byte{0x55, 0x1d, 0x20}, asn1.ObjectIdentifier{2, 5, 29, 32}, []uint64{2, 5, 29, 32}},
+		{"1.3.6.1.4.1.11129.2.4.2", []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0xd6, 0x79, 0x02, 0x04, 0x02}, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}, []uint64{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}},
+		{"2.999.3", []byte{0x88, 0x37, 0x03}, asn1.ObjectIdentifier{2, 999, 3}, []uint64{2, 999, 3}},
+		{"1.2.18446744073709551615", []byte{0x2a, 0x81, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, nil, []uint64{1, 2, math.MaxUint64}},
+		{"2.25.329800735698586629295641978511506172918", []byte{0x69, 0x83, 0xf0, 0x9d, 0xa7, 0xeb, 0xcf, 0xde, 0xe0, 0xc7, 0xa1, 0xa7, 0xb2, 0xc0, 0x94, 0x8c, 0xc8, 0xf9, 0xd7, 0x76}, nil, nil},
+		{"2.36893488147419103152", []byte{0x84, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, nil, nil},
+	}
+	for _, tt := range tests {
+		oid, err := ParseOID(tt.s)
+		if err != nil {
+			t.Errorf("ParseOID(%q): %v", tt.s, err)
+			continue
+		}
+		if !bytes.Equal(oid.der, tt.der) {
+			t.Errorf("ParseOID(%q) = %x, want %x", tt.s, oid.der, tt.der)
+		}
+		if s := oid.String(); s != tt.s {
+			t.Errorf("String() = %q, want %q", s, tt.s)
+		}
+		if _, ok := newOIDFromDER(tt.der); !ok {
+			t.Errorf("%s: DER encoding rejected", tt.s)
+		}
+
+		got, ok := oid.toASN1OID()
+		if (tt.asn1 != nil) != ok || ok && !got.Equal(tt.asn1) {
+			t.Errorf("%s: toASN1OID() = %v, %v, want %v", tt.s, got, ok, tt.asn1)
+		}
+		if tt.asn1 != nil && !oid.EqualASN1OID(tt.asn1) {
+			t.Errorf("%s: EqualASN1OID(%v) = false", tt.s, tt.asn1)
+		}
+
+		if tt.arcs != nil {
+			fromInts, err := OIDFromInts(tt.arcs)
+			if err != nil || !fromInts.Equal(oid) {
+				t.Errorf("OIDFromInts(%v) = %x, %v, want %x", tt.arcs, fromInts.der, err, tt.der)
+			}
+		}
+	}
+
+	for _, s := range []string{"", "1", "3.1", "1.40", "1.2.", "1..2", "1.02", "1.-2", "1.+2", "1.2a"} {
+		if _, err := ParseOID(s); err == nil {
+			t.Errorf("ParseOID(%q) succeeded", s)
+		}
+	}
+	for _, arcs := range [][]uint64{{}, {1}, {3, 1}, {0, 40}, {2, math.MaxUint64}} {
+		if _, err := OIDFromInts(arcs); err == nil {
+			t.Errorf("OIDFromInts(%v) succeeded", arcs)
+		}
+	}
+	for _, der := range [][]byte{{}, {0x2a, 0x80, 0x01}, {0x2a, 0x81}} {
+		if _, ok := newOIDFromDER(der); ok {
+			t.Errorf("newOIDFromDER(%x) succeeded", der)
+		}
+	}
+}
+
+func TestLargeOIDs(t *testing.T) {
+	mustParseOID := func(s string) OID {
+		oid, err := ParseOID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return oid
+	}
+	policy := mustParseOID("2.25.329800735698586629295641978511506172918")
+	eku := mustParseOID("2.25.329800735698586629295641978511506172918.1")
+	ext := mustParseOID("2.25.329800735698586629295641978511506172918.2")
+	template := &Certificate{
+		SerialNumber:           big.NewInt(1),
+		Subject:                pkix.Name{CommonName: "large OIDs"},
+		NotBefore:              time.Unix(1000, 0),
+		NotAfter:               time.Unix(100000, 0),
+		UnknownExtKeyUsageOIDs: []OID{eku},
+		Policies:               []OID{mustParseOID("2.23.140.1.2.1"), policy},
+		OIDExtensions:          []OIDExtension{{Id: ext, Value: []byte{0x05, 0x00}}},
+	}
+	cert := serialiseAndParse(t, template)
+
+	if len(cert.Policies) != 2 || !cert.Policies[1].Equal(policy) {
+		t.Errorf("got Policies %v", cert.Policies)
+	}
+	if len(cert.PolicyIdentifiers) != 1 || !cert.PolicyIdentifiers[0].Equal(asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}) {
+		t.Errorf("got PolicyIdentifiers %v", cert.PolicyIdentifiers)
+	}
+	if len(cert.UnknownExtKeyUsageOIDs) != 1 || !cert.UnknownExtKeyUsageOIDs[0].Equal(eku) || len(cert.UnknownExtKeyUsage) != 0 {
+		t.Errorf("got UnknownExtKeyUsageOIDs %v and UnknownExtKeyUsage %v", cert.UnknownExtKeyUsageOIDs, cert.UnknownExtKeyUsage)
+	}
+	if len(cert.OIDExtensions) != 1 || !cert.OIDExtensions[0].Id.Equal(ext) || !bytes.Equal(cert.OIDExtensions[0].Value, []byte{0x05, 0x00}) {
+		t.Errorf("got OIDExtensions %v", cert.OIDExtensions)
+	}
+	for _, e := range cert.Extensions {
+		if len(e.Id) > 2 && e.Id[0] == 2 && e.Id[1] == 25 {
+			t.Errorf("large OID extension %v in Extensions", e.Id)
+		}
+	}
+
+	reissue := TemplateFromCertificate(cert)
+	if len(reissue.ExtraExtensions) != 0 {
+		t.Errorf("TemplateFromCertificate moved %d extensions to ExtraExtensions", len(reissue.ExtraExtensions))
+	}
+	if diffs := DiffTemplate(serialiseAndParse(t, reissue), reissue); diffs != nil {
+		t.Errorf("reissued certificate differs from its template: %v", diffs)
+	}
+
+	// An unknown extended key usage restricts the certificate, even if it
+	// doesn't fit in UnknownExtKeyUsage.
+	roots := NewCertPool()
+	roots.AddCert(cert)
+	opts := VerifyOptions{Roots: roots, CurrentTime: time.Unix(2000, 0)}
+	_, err := cert.Verify(opts)
+	if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != IncompatibleUsage {
+		t.Errorf("got error %v, want IncompatibleUsage", err)
+	}
+	opts.KeyUsages = []ExtKeyUsage{ExtKeyUsageAny}
+	if _, err := cert.Verify(opts); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	reissue.OIDExtensions[0].Critical = true
+	cert = serialiseAndParse(t, reissue)
+	if len(cert.OIDExtensions) != 1 || !cert.OIDExtensions[0].Critical {
+		t.Fatalf("got OIDExtensions %v", cert.OIDExtensions)
+	}
+	roots = NewCertPool()
+	roots.AddCert(cert)
+	opts.Roots = roots
+	if _, err := cert.Verify(opts); err == nil {
+		t.Error("certificate with a critical large OID extension verified")
+	} else if _, ok := err.(UnhandledCriticalExtension); !ok {
+		t.Errorf("got error %v, want UnhandledCriticalExtension", err)
+	}
+}
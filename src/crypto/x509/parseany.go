@@ -0,0 +1,234 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 7292, Appendix D and RFC 2315, Section 14
+var (
+	oidPKCS12CertBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS12X509Cert     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPKCS7EncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+)
+
+var (
+	errInvalidPKCS7   = errors.New("x509: invalid PKCS #7 certificates")
+	errInvalidPKCS12  = errors.New("x509: invalid PKCS #12 file")
+	errNoCertificates = errors.New("x509: no certificates found")
+)
+
+// ParseAnyCertificate parses one or more certificates from data, which may
+// be in any of the common encodings:
+//
+//  - PEM, with "CERTIFICATE", "TRUSTED CERTIFICATE" or "PKCS7" blocks;
+//    other blocks, and trust attributes, are ignored
+//  - one or more concatenated DER certificates
+//  - a DER PKCS #7 certs-only structure, as produced by
+//    MarshalPKCS7Certificates
+//  - a DER PKCS #12 file whose certificates are not encrypted
+//
+// The integrity of PKCS #12 files is not checked, and the private keys they
+// may contain are ignored. BER encodings that are not valid DER are not
+// supported.
+func ParseAnyCertificate(data []byte) ([]*Certificate, error) {
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return parsePEMCertificates(data)
+	}
+
+	input := cryptobyte.String(data)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: data is neither PEM nor DER")
+	}
+	switch {
+	case seq.PeekASN1Tag(cryptobyte_asn1.SEQUENCE):
+		return ParseCertificates(data)
+	case seq.PeekASN1Tag(cryptobyte_asn1.OBJECT_IDENTIFIER):
+		return ParsePKCS7Certificates(data)
+	case seq.PeekASN1Tag(cryptobyte_asn1.INTEGER):
+		return parsePKCS12Certificates(data)
+	}
+	return nil, errors.New("x509: unrecognized DER structure")
+}
+
+func parsePEMCertificates(data []byte) ([]*Certificate, error) {
+	var certs []*Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var parsed []*Certificate
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			var c *Certificate
+			c, err = ParseCertificate(block.Bytes)
+			parsed = []*Certificate{c}
+		case "TRUSTED CERTIFICATE":
+			var c *Certificate
+			c, _, err = ParseTrustedCertificate(block.Bytes)
+			parsed = []*Certificate{c}
+		case "PKCS7":
+			parsed, err = ParsePKCS7Certificates(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, parsed...)
+	}
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+	return certs, nil
+}
+
+// ParsePKCS7Certificates parses the certificates of a DER encoded PKCS #7
+// SignedData structure, such as the contents of a .p7b or .p7c file. Any
+// content, revocation lists and signatures are ignored.
+func ParsePKCS7Certificates(der []byte) ([]*Certificate, error) {
+	input := cryptobyte.String(der)
+	var contentInfo, content, signedData cryptobyte.String
+	var contentType asn1.ObjectIdentifier
+	if !input.ReadASN1(&contentInfo, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!contentInfo.ReadASN1ObjectIdentifier(&contentType) {
+		return nil, errInvalidPKCS7
+	}
+	if !contentType.Equal(oidPKCS7SignedData) {
+		return nil, errors.New("x509: PKCS #7 content is not SignedData")
+	}
+	if !contentInfo.ReadASN1(&content, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!content.ReadASN1(&signedData, cryptobyte_asn1.SEQUENCE) {
+		return nil, errInvalidPKCS7
+	}
+
+	var version int
+	var certsData cryptobyte.String
+	var hasCerts bool
+	if !signedData.ReadASN1Integer(&version) ||
+		!signedData.SkipASN1(cryptobyte_asn1.SET) ||
+		!signedData.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!signedData.ReadOptionalASN1(&certsData, &hasCerts, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errInvalidPKCS7
+	}
+	if !hasCerts || certsData.Empty() {
+		return nil, errNoCertificates
+	}
+	return ParseCertificates(certsData)
+}
+
+// parsePKCS12Certificates returns the certificates in the unencrypted
+// SafeContents of a PKCS #12 file, as described in RFC 7292, Section 4.
+func parsePKCS12Certificates(der []byte) ([]*Certificate, error) {
+	// PFX ::= SEQUENCE {
+	//      version  INTEGER {v3(3)}(v3,...),
+	//      authSafe ContentInfo,
+	//      macData  MacData OPTIONAL }
+	input := cryptobyte.String(der)
+	var pfx, authSafe, authSafeContent, safes cryptobyte.String
+	var version int
+	var contentType asn1.ObjectIdentifier
+	if !input.ReadASN1(&pfx, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!pfx.ReadASN1Integer(&version) || version != 3 ||
+		!pfx.ReadASN1(&authSafe, cryptobyte_asn1.SEQUENCE) ||
+		!authSafe.ReadASN1ObjectIdentifier(&contentType) || !contentType.Equal(oidPKCS7Data) ||
+		!authSafe.ReadASN1(&authSafeContent, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!authSafeContent.ReadASN1(&safes, cryptobyte_asn1.OCTET_STRING) {
+		return nil, errInvalidPKCS12
+	}
+
+	// AuthenticatedSafe ::= SEQUENCE OF ContentInfo
+	var safeList cryptobyte.String
+	if !safes.ReadASN1(&safeList, cryptobyte_asn1.SEQUENCE) {
+		return nil, errInvalidPKCS12
+	}
+	var certs []*Certificate
+	encrypted := false
+	for !safeList.Empty() {
+		var safe, safeContent, bags cryptobyte.String
+		if !safeList.ReadASN1(&safe, cryptobyte_asn1.SEQUENCE) ||
+			!safe.ReadASN1ObjectIdentifier(&contentType) {
+			return nil, errInvalidPKCS12
+		}
+		if contentType.Equal(oidPKCS7EncryptedData) {
+			encrypted = true
+			continue
+		}
+		if !contentType.Equal(oidPKCS7Data) ||
+			!safe.ReadASN1(&safeContent, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+			!safeContent.ReadASN1(&bags, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errInvalidPKCS12
+		}
+		found, err := parsePKCS12SafeContents(bags)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, found...)
+	}
+
+	if len(certs) == 0 {
+		if encrypted {
+			return nil, errors.New("x509: encrypted PKCS #12 contents are not supported")
+		}
+		return nil, errNoCertificates
+	}
+	return certs, nil
+}
+
+func parsePKCS12SafeContents(der cryptobyte.String) ([]*Certificate, error) {
+	// SafeContents ::= SEQUENCE OF SafeBag
+	//
+	// SafeBag ::= SEQUENCE {
+	//      bagId         BAG-TYPE.&id ({PKCS12BagSet}),
+	//      bagValue      [0] EXPLICIT BAG-TYPE.&Type({PKCS12BagSet}{@bagId}),
+	//      bagAttributes SET OF PKCS12Attribute OPTIONAL }
+	//
+	// CertBag ::= SEQUENCE {
+	//      certId    BAG-TYPE.&id   ({CertTypes}),
+	//      certValue [0] EXPLICIT BAG-TYPE.&Type ({CertTypes}{@certId}) }
+	var bags cryptobyte.String
+	if !der.ReadASN1(&bags, cryptobyte_asn1.SEQUENCE) {
+		return nil, errInvalidPKCS12
+	}
+	var certs []*Certificate
+	for !bags.Empty() {
+		var bag, bagValue, certBag, certValue, certDER cryptobyte.String
+		var bagID, certID asn1.ObjectIdentifier
+		if !bags.ReadASN1(&bag, cryptobyte_asn1.SEQUENCE) ||
+			!bag.ReadASN1ObjectIdentifier(&bagID) ||
+			!bag.ReadASN1(&bagValue, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+			return nil, errInvalidPKCS12
+		}
+		if !bagID.Equal(oidPKCS12CertBag) {
+			continue
+		}
+		if !bagValue.ReadASN1(&certBag, cryptobyte_asn1.SEQUENCE) ||
+			!certBag.ReadASN1ObjectIdentifier(&certID) ||
+			!certBag.ReadASN1(&certValue, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+			!certValue.ReadASN1(&certDER, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errInvalidPKCS12
+		}
+		if !certID.Equal(oidPKCS12X509Cert) {
+			continue
+		}
+		c, err := ParseCertificate(certDER)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
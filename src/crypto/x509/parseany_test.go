@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseAnyCertificate(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := MarshalPKCS7Certificates([]*Certificate{leaf, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := ioutil.ReadFile("testdata/test-file.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+	pemBundle = append(pemBundle, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{1}})...)
+	pemBundle = append(pemBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"DER", leaf.Raw, []string{"Leaf"}},
+		{"concatenated DER", append(append([]byte(nil), leaf.Raw...), root.Raw...), []string{"Leaf", "Root"}},
+		{"PEM bundle", pemBundle, []string{"Leaf", "Root"}},
+		{"PKCS #7", p7, []string{"Leaf", "Root"}},
+		{"PEM PKCS #7", pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: p7}), []string{"Leaf", "Root"}},
+		{"PKCS #12", p12, []string{"test-file"}},
+	}
+	for _, test := range tests {
+		certs, err := ParseAnyCertificate(test.data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(certs) != len(test.want) {
+			t.Errorf("%s: got %d certificates, want %d", test.name, len(certs), len(test.want))
+			continue
+		}
+		for i, c := range certs {
+			if c.Subject.CommonName != test.want[i] {
+				t.Errorf("%s: certificate %d is %q, want %q", test.name, i, c.Subject.CommonName, test.want[i])
+			}
+		}
+	}
+
+	encrypted, err := ioutil.ReadFile("testdata/test-file-encrypted.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{
+		nil,
+		[]byte("not a certificate"),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{1}}),
+		leaf.Raw[:len(leaf.Raw)/2],
+		encrypted,
+	} {
+		if _, err := ParseAnyCertificate(data); err == nil {
+			t.Errorf("ParseAnyCertificate(%q) succeeded", data)
+		}
+	}
+}
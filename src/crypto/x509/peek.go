@@ -0,0 +1,123 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// peekTBSCertificate returns the contents of the TBSCertificate of the
+// certificate in der, positioned after the version.
+func peekTBSCertificate(der []byte) (cryptobyte.String, error) {
+	input := cryptobyte.String(der)
+	var cert, tbs cryptobyte.String
+	if !input.ReadASN1(&cert, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!cert.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed certificate")
+	}
+	return tbs, nil
+}
+
+// PeekValidity returns the validity period of the DER encoded certificate
+// in der, without parsing the rest of it. It is meant for tools that scan
+// large numbers of stored certificates, for example to find those about to
+// expire.
+//
+// Unlike ParseCertificate, PeekValidity does not check that the certificate
+// is otherwise well formed.
+func PeekValidity(der []byte) (notBefore, notAfter time.Time, err error) {
+	tbs, err := peekTBSCertificate(der)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	var raw cryptobyte.String
+	if !tbs.SkipASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1Element(&raw, cryptobyte_asn1.SEQUENCE) {
+		return time.Time{}, time.Time{}, errors.New("x509: malformed certificate")
+	}
+	var v validity
+	if rest, err := asn1.Unmarshal(raw, &v); err != nil {
+		return time.Time{}, time.Time{}, err
+	} else if len(rest) != 0 {
+		return time.Time{}, time.Time{}, errors.New("x509: malformed validity")
+	}
+	return v.NotBefore, v.NotAfter, nil
+}
+
+// PeekSerialIssuer returns the serial number and the DER encoded issuer of
+// the DER encoded certificate in der, without parsing the rest of it. The
+// issuer is in the same form as Certificate.RawIssuer, and together with the
+// serial number identifies the certificate. rawIssuer aliases der.
+//
+// Unlike ParseCertificate, PeekSerialIssuer does not check that the
+// certificate is otherwise well formed.
+func PeekSerialIssuer(der []byte) (serial *big.Int, rawIssuer []byte, err error) {
+	tbs, err := peekTBSCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial = new(big.Int)
+	var issuer cryptobyte.String
+	if !tbs.ReadASN1Integer(serial) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1Element(&issuer, cryptobyte_asn1.SEQUENCE) {
+		return nil, nil, errors.New("x509: malformed certificate")
+	}
+	return serial, issuer, nil
+}
+
+// PeekSPKISubjectSHA256 returns the same hash as SPKISubjectSHA256 for the
+// DER encoded certificate in der, without parsing the rest of it or copying
+// the hashed fields, for deduplicating large numbers of certificates.
+//
+// Unlike ParseCertificate, PeekSPKISubjectSHA256 does not check that the
+// certificate is otherwise well formed.
+func PeekSPKISubjectSHA256(der []byte) ([sha256.Size]byte, error) {
+	tbs, err := peekTBSCertificate(der)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	var subject, spki cryptobyte.String
+	if !tbs.SkipASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1Element(&subject, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1Element(&spki, cryptobyte_asn1.SEQUENCE) {
+		return [sha256.Size]byte{}, errors.New("x509: malformed certificate")
+	}
+	return spkiSubjectSHA256(spki, subject), nil
+}
+
+// PeekSignatureAlgorithm returns the signature algorithm of the DER encoded
+// certificate, certificate request or CRL in der, without parsing the rest
+// of it, for example to find which of the template SignatureAlgorithms
+// CreateCertificate used. It returns UnknownSignatureAlgorithm if the
+// algorithm is not supported by this package.
+func PeekSignatureAlgorithm(der []byte) (SignatureAlgorithm, error) {
+	input := cryptobyte.String(der)
+	var signed, algo cryptobyte.String
+	if !input.ReadASN1(&signed, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!signed.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!signed.ReadASN1Element(&algo, cryptobyte_asn1.SEQUENCE) {
+		return UnknownSignatureAlgorithm, errors.New("x509: malformed signed structure")
+	}
+	var ai pkix.AlgorithmIdentifier
+	if rest, err := asn1.Unmarshal(algo, &ai); err != nil || len(rest) != 0 {
+		return UnknownSignatureAlgorithm, errors.New("x509: malformed signature algorithm")
+	}
+	return getSignatureAlgorithmFromAI(ai), nil
+}
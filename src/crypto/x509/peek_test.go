@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/pem"
+	"testing"
+)
+
+func TestPeek(t *testing.T) {
+	block, _ := pem.Decode([]byte(pemCertificate))
+	certs, err := ParseCertificates(append(block.Bytes, fromBase64(certBytes)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cert := range certs {
+		der := cert.Raw
+
+		notBefore, notAfter, err := PeekValidity(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !notBefore.Equal(cert.NotBefore) || !notAfter.Equal(cert.NotAfter) {
+			t.Errorf("PeekValidity = %v, %v; want %v, %v", notBefore, notAfter, cert.NotBefore, cert.NotAfter)
+		}
+
+		serial, issuer, err := PeekSerialIssuer(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if serial.Cmp(cert.SerialNumber) != 0 || !bytes.Equal(issuer, cert.RawIssuer) {
+			t.Errorf("PeekSerialIssuer = %v, %x; want %v, %x", serial, issuer, cert.SerialNumber, cert.RawIssuer)
+		}
+
+		hash, err := PeekSPKISubjectSHA256(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := SPKISubjectSHA256(cert); hash != want {
+			t.Errorf("PeekSPKISubjectSHA256 = %x, want %x", hash, want)
+		}
+
+		algo, err := PeekSignatureAlgorithm(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if algo != cert.SignatureAlgorithm {
+			t.Errorf("PeekSignatureAlgorithm = %v, want %v", algo, cert.SignatureAlgorithm)
+		}
+
+		for _, bad := range [][]byte{nil, der[:len(der)/2], append(der[:len(der):len(der)], 0)} {
+			if _, _, err := PeekValidity(bad); err == nil {
+				t.Errorf("PeekValidity accepted %d bytes of malformed input", len(bad))
+			}
+			if _, _, err := PeekSerialIssuer(bad); err == nil {
+				t.Errorf("PeekSerialIssuer accepted %d bytes of malformed input", len(bad))
+			}
+			if _, err := PeekSPKISubjectSHA256(bad); err == nil {
+				t.Errorf("PeekSPKISubjectSHA256 accepted %d bytes of malformed input", len(bad))
+			}
+			if _, err := PeekSignatureAlgorithm(bad); err == nil {
+				t.Errorf("PeekSignatureAlgorithm accepted %d bytes of malformed input", len(bad))
+			}
+		}
+	}
+}
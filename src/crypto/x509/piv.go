@@ -0,0 +1,203 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Extensions of the YubiKey PIV attestation certificates, see
+// https://developers.yubico.com/PIV/Introduction/PIV_attestation.html.
+var (
+	oidExtensionYubicoFirmware   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 3}
+	oidExtensionYubicoSerial     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 7}
+	oidExtensionYubicoPolicy     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 8}
+	oidExtensionYubicoFormFactor = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 9}
+)
+
+// PIVPINPolicy is the PIN policy of a key in a PIV slot.
+type PIVPINPolicy int
+
+const (
+	PIVPINPolicyNever  PIVPINPolicy = 1
+	PIVPINPolicyOnce   PIVPINPolicy = 2
+	PIVPINPolicyAlways PIVPINPolicy = 3
+)
+
+func (p PIVPINPolicy) String() string {
+	switch p {
+	case PIVPINPolicyNever:
+		return "never"
+	case PIVPINPolicyOnce:
+		return "once"
+	case PIVPINPolicyAlways:
+		return "always"
+	}
+	return fmt.Sprintf("PIVPINPolicy(%d)", int(p))
+}
+
+// PIVTouchPolicy is the touch policy of a key in a PIV slot.
+type PIVTouchPolicy int
+
+const (
+	PIVTouchPolicyNever  PIVTouchPolicy = 1
+	PIVTouchPolicyAlways PIVTouchPolicy = 2
+	// PIVTouchPolicyCached requires a touch at most once every 15 seconds.
+	PIVTouchPolicyCached PIVTouchPolicy = 3
+)
+
+func (p PIVTouchPolicy) String() string {
+	switch p {
+	case PIVTouchPolicyNever:
+		return "never"
+	case PIVTouchPolicyAlways:
+		return "always"
+	case PIVTouchPolicyCached:
+		return "cached"
+	}
+	return fmt.Sprintf("PIVTouchPolicy(%d)", int(p))
+}
+
+// PIVFormFactor is the form factor of a YubiKey.
+type PIVFormFactor int
+
+const (
+	PIVFormFactorUSBAKeychain          PIVFormFactor = 1
+	PIVFormFactorUSBANano              PIVFormFactor = 2
+	PIVFormFactorUSBCKeychain          PIVFormFactor = 3
+	PIVFormFactorUSBCNano              PIVFormFactor = 4
+	PIVFormFactorUSBCLightningKeychain PIVFormFactor = 5
+)
+
+func (f PIVFormFactor) String() string {
+	switch f {
+	case PIVFormFactorUSBAKeychain:
+		return "USB-A keychain"
+	case PIVFormFactorUSBANano:
+		return "USB-A nano"
+	case PIVFormFactorUSBCKeychain:
+		return "USB-C keychain"
+	case PIVFormFactorUSBCNano:
+		return "USB-C nano"
+	case PIVFormFactorUSBCLightningKeychain:
+		return "USB-C and Lightning keychain"
+	}
+	return fmt.Sprintf("PIVFormFactor(%d)", int(f))
+}
+
+// PIVFirmwareVersion is the firmware version of a YubiKey.
+type PIVFirmwareVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v PIVFirmwareVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// PIVAttestation holds the fields of a YubiKey PIV attestation certificate,
+// which certifies that the key of a PIV slot was generated on the device.
+// Fields whose extension is absent are zero.
+type PIVAttestation struct {
+	Firmware    PIVFirmwareVersion
+	Serial      uint32
+	PINPolicy   PIVPINPolicy
+	TouchPolicy PIVTouchPolicy
+	FormFactor  PIVFormFactor
+}
+
+// PIVAttestation returns the YubiKey attestation fields of c, an attestation
+// certificate of a PIV slot. It returns an error if c has none of them.
+//
+// It only decodes the extensions: use VerifyPIVAttestation to check that c
+// was issued by a YubiKey.
+func (c *Certificate) PIVAttestation() (*PIVAttestation, error) {
+	var a PIVAttestation
+	found := false
+	for _, e := range c.Extensions {
+		switch {
+		case e.Id.Equal(oidExtensionYubicoFirmware):
+			if len(e.Value) != 3 {
+				return nil, errors.New("x509: invalid YubiKey firmware version extension")
+			}
+			a.Firmware = PIVFirmwareVersion{int(e.Value[0]), int(e.Value[1]), int(e.Value[2])}
+		case e.Id.Equal(oidExtensionYubicoSerial):
+			var serial int64
+			if rest, err := asn1.Unmarshal(e.Value, &serial); err != nil || len(rest) != 0 ||
+				serial < 0 || serial > math.MaxUint32 {
+				return nil, errors.New("x509: invalid YubiKey serial number extension")
+			}
+			a.Serial = uint32(serial)
+		case e.Id.Equal(oidExtensionYubicoPolicy):
+			if len(e.Value) != 2 {
+				return nil, errors.New("x509: invalid YubiKey PIN and touch policy extension")
+			}
+			a.PINPolicy, a.TouchPolicy = PIVPINPolicy(e.Value[0]), PIVTouchPolicy(e.Value[1])
+		case e.Id.Equal(oidExtensionYubicoFormFactor):
+			if len(e.Value) != 1 {
+				return nil, errors.New("x509: invalid YubiKey form factor extension")
+			}
+			a.FormFactor = PIVFormFactor(e.Value[0])
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, errors.New("x509: certificate has no YubiKey attestation extensions")
+	}
+	return &a, nil
+}
+
+// PIVAttestationVerifyOptions returns VerifyOptions for the attestation
+// certificate of a YubiKey, the one in PIV slot f9. roots must hold the
+// Yubico PIV Root CA certificate, which Yubico publishes, and the Yubico
+// intermediates, if any, must be added to Intermediates.
+func PIVAttestationVerifyOptions(roots *CertPool) VerifyOptions {
+	opts := NewVerifyOptions()
+	opts.Roots = roots
+	opts.KeyUsages = []ExtKeyUsage{ExtKeyUsageAny}
+	return opts
+}
+
+// VerifyPIVAttestation verifies that leaf, the attestation certificate of
+// the key in a PIV slot, was signed by attester, the attestation certificate
+// of the YubiKey in slot f9, and that attester verifies with opts, usually
+// returned by PIVAttestationVerifyOptions. It then returns the attestation
+// fields of leaf.
+//
+// The attestation certificates of some YubiKeys are not marked as CAs, so
+// the signature of leaf is checked without checking the basic constraints or
+// key usage of attester.
+func VerifyPIVAttestation(leaf, attester *Certificate, opts VerifyOptions) (*PIVAttestation, error) {
+	if _, err := attester.Verify(opts); err != nil {
+		return nil, err
+	}
+	if err := attester.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature); err != nil {
+		return nil, unknownAuthorityError(leaf, err, attester)
+	}
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if now.Before(leaf.NotBefore) {
+		return nil, CertificateInvalidError{
+			Cert:   leaf,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), leaf.NotBefore.Format(time.RFC3339)),
+		}
+	} else if now.After(leaf.NotAfter) {
+		return nil, CertificateInvalidError{
+			Cert:   leaf,
+			Reason: Expired,
+			Detail: fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339)),
+		}
+	}
+	return leaf.PIVAttestation()
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifyPIVAttestation(t *testing.T) {
+	root, rootKey, err := generateCert("Yubico PIV Root CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	create := func(template, parent *Certificate, pub, priv interface{}) *Certificate {
+		template.SerialNumber = big.NewInt(1)
+		template.NotBefore = now.Add(-time.Hour)
+		template.NotAfter = now.Add(time.Hour)
+		der, err := CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	attesterKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slotKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like those of some YubiKeys, the attester is not marked as a CA.
+	attester := create(&Certificate{Subject: pkix.Name{CommonName: "Yubico PIV Attestation"}}, root, attesterKey.Public(), rootKey)
+
+	serial, err := asn1.Marshal(12345678)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions := []pkix.Extension{
+		{Id: oidExtensionYubicoFirmware, Value: []byte{5, 2, 7}},
+		{Id: oidExtensionYubicoSerial, Value: serial},
+		{Id: oidExtensionYubicoPolicy, Value: []byte{2, 3}},
+		{Id: oidExtensionYubicoFormFactor, Value: []byte{3}},
+	}
+	leaf := create(&Certificate{
+		Subject:         pkix.Name{CommonName: "YubiKey PIV Attestation 9a"},
+		ExtraExtensions: extensions,
+	}, attester, slotKey.Public(), attesterKey)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	opts := PIVAttestationVerifyOptions(roots)
+	a, err := VerifyPIVAttestation(leaf, attester, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PIVAttestation{
+		Firmware:    PIVFirmwareVersion{5, 2, 7},
+		Serial:      12345678,
+		PINPolicy:   PIVPINPolicyOnce,
+		TouchPolicy: PIVTouchPolicyCached,
+		FormFactor:  PIVFormFactorUSBCKeychain,
+	}
+	if *a != want {
+		t.Errorf("got %+v, want %+v", *a, want)
+	}
+	if a.Firmware.String() != "5.2.7" {
+		t.Errorf("firmware version is %q", a.Firmware)
+	}
+
+	// A leaf signed by another key.
+	forged := create(&Certificate{
+		Subject:         pkix.Name{CommonName: "YubiKey PIV Attestation 9a"},
+		ExtraExtensions: extensions,
+	}, attester, slotKey.Public(), slotKey)
+	if _, err := VerifyPIVAttestation(forged, attester, opts); err == nil {
+		t.Error("leaf not signed by the attester was accepted")
+	} else if _, ok := err.(UnknownAuthorityError); !ok {
+		t.Errorf("forged leaf: got %T, want UnknownAuthorityError", err)
+	}
+
+	// An attester that doesn't chain to the roots.
+	otherRoot, otherKey, err := generateCert("Other Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted := create(&Certificate{Subject: pkix.Name{CommonName: "Yubico PIV Attestation"}}, otherRoot, attesterKey.Public(), otherKey.(crypto.Signer))
+	if _, err := VerifyPIVAttestation(leaf, untrusted, opts); err == nil {
+		t.Error("untrusted attester was accepted")
+	}
+
+	opts.CurrentTime = now.Add(30 * time.Minute)
+	if _, err := VerifyPIVAttestation(leaf, attester, opts); err != nil {
+		t.Errorf("within the validity period: %v", err)
+	}
+	opts.CurrentTime = now.Add(2 * time.Hour)
+	if _, err := VerifyPIVAttestation(leaf, attester, opts); err == nil {
+		t.Error("expired attestation was accepted")
+	}
+
+	if _, err := root.PIVAttestation(); err == nil {
+		t.Error("PIVAttestation succeeded without the extensions")
+	}
+	for i := range extensions {
+		bad := &Certificate{Extensions: []pkix.Extension{{Id: extensions[i].Id, Value: []byte{1, 2, 3, 4}}}}
+		if _, err := bad.PIVAttestation(); err == nil {
+			t.Errorf("malformed %v extension was accepted", extensions[i].Id)
+		}
+	}
+}
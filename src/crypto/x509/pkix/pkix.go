@@ -136,12 +136,22 @@ type Name struct {
 	// distinguished names. Values override any attributes with the same OID.
 	// The ExtraNames field is not populated when parsing, see Names.
 	ExtraNames []AttributeTypeAndValue
+
+	// RDNs contains the complete sequence of relative distinguished names
+	// when parsing, preserving their order, the grouping of multi-valued
+	// RDNs and attributes that are not parsed into other fields. When
+	// marshaling, RDNs is used as-is if ExtraNames is empty and the other
+	// fields, except Names, hold exactly the values parsed from RDNs, or if
+	// they are all empty, including Names. Otherwise it is ignored.
+	RDNs RDNSequence
 }
 
 // FillFromRDNSequence populates n from the provided RDNSequence.
 // Multi-entry RDNs are flattened, all entries are added to the
-// relevant n fields, and the grouping is not preserved.
+// relevant n fields, and the grouping is only preserved in RDNs, which is
+// replaced by a copy of rdns.
 func (n *Name) FillFromRDNSequence(rdns *RDNSequence) {
+	n.RDNs = append(RDNSequence(nil), *rdns...)
 	for _, rdn := range *rdns {
 		if len(rdn) == 0 {
 			continue
@@ -223,7 +233,20 @@ func (n Name) appendRDNs(in RDNSequence, values []string, oid asn1.ObjectIdentif
 //  - PostalCode
 //
 // Each ExtraNames entry is encoded as an individual RDN.
-func (n Name) ToRDNSequence() (ret RDNSequence) {
+//
+// If n was parsed and its fields have not been modified since, or only RDNs
+// is set, the RDNs field is returned instead, so that the original order and
+// grouping are preserved.
+func (n Name) ToRDNSequence() RDNSequence {
+	if n.useRDNs() {
+		return append(RDNSequence(nil), n.RDNs...)
+	}
+	return n.fieldsToRDNSequence()
+}
+
+// fieldsToRDNSequence converts the named fields and ExtraNames of n into an
+// RDNSequence, in the fixed order documented by ToRDNSequence.
+func (n Name) fieldsToRDNSequence() (ret RDNSequence) {
 	ret = n.appendRDNs(ret, n.Country, oidCountry)
 	ret = n.appendRDNs(ret, n.Province, oidProvince)
 	ret = n.appendRDNs(ret, n.Locality, oidLocality)
@@ -260,7 +283,110 @@ func (n Name) String() string {
 			n.ExtraNames = append(n.ExtraNames, atv)
 		}
 	}
-	return n.ToRDNSequence().String()
+	return n.fieldsToRDNSequence().String()
+}
+
+// RDNString returns the string form of n like String, but following the
+// order and grouping of the RDNs field when n would be marshaled from it, as
+// documented by ToRDNSequence.
+func (n Name) RDNString() string {
+	if n.useRDNs() {
+		return n.RDNs.String()
+	}
+	return n.String()
+}
+
+// useRDNs reports whether n should be marshaled from its RDNs field, which
+// is the case if no other field that is marshaled contradicts it.
+func (n Name) useRDNs() bool {
+	if len(n.RDNs) == 0 || len(n.ExtraNames) > 0 {
+		return false
+	}
+	if len(n.Country) == 0 && len(n.Organization) == 0 && len(n.OrganizationalUnit) == 0 &&
+		len(n.Locality) == 0 && len(n.Province) == 0 && len(n.StreetAddress) == 0 &&
+		len(n.PostalCode) == 0 && n.SerialNumber == "" && n.CommonName == "" &&
+		len(n.Names) == 0 {
+		// Only RDNs was set.
+		return true
+	}
+
+	var parsed Name
+	parsed.FillFromRDNSequence(&n.RDNs)
+	return equalStrings(n.Country, parsed.Country) &&
+		equalStrings(n.Organization, parsed.Organization) &&
+		equalStrings(n.OrganizationalUnit, parsed.OrganizationalUnit) &&
+		equalStrings(n.Locality, parsed.Locality) &&
+		equalStrings(n.Province, parsed.Province) &&
+		equalStrings(n.StreetAddress, parsed.StreetAddress) &&
+		equalStrings(n.PostalCode, parsed.PostalCode) &&
+		n.SerialNumber == parsed.SerialNumber &&
+		n.CommonName == parsed.CommonName
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	oidDomainComponent = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	oidUserID          = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+	oidEmailAddress    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+)
+
+// DomainComponents returns the values of the domainComponent (DC) attributes
+// of n, in order, as defined in RFC 4519.
+func (n Name) DomainComponents() []string {
+	return n.attributeValues(oidDomainComponent)
+}
+
+// UserIDs returns the values of the userId (UID) attributes of n, in order,
+// as defined in RFC 4519.
+func (n Name) UserIDs() []string {
+	return n.attributeValues(oidUserID)
+}
+
+// EmailAddresses returns the values of the legacy emailAddress attributes of
+// n, in order, as defined in RFC 2985.
+func (n Name) EmailAddresses() []string {
+	return n.attributeValues(oidEmailAddress)
+}
+
+// SerialNumbers returns the values of all serialNumber attributes of n, in
+// order. Unlike the SerialNumber field, it includes all of them if n has
+// more than one.
+func (n Name) SerialNumbers() []string {
+	return n.attributeValues(oidSerialNumber)
+}
+
+// attributeValues returns the string values of the attributes of type oid in
+// RDNs or, if that is empty, in ExtraNames.
+func (n Name) attributeValues(oid asn1.ObjectIdentifier) []string {
+	var values []string
+	add := func(atv AttributeTypeAndValue) {
+		if s, ok := atv.Value.(string); ok && atv.Type.Equal(oid) {
+			values = append(values, s)
+		}
+	}
+	if len(n.RDNs) > 0 {
+		for _, rdn := range n.RDNs {
+			for _, atv := range rdn {
+				add(atv)
+			}
+		}
+	} else {
+		for _, atv := range n.ExtraNames {
+			add(atv)
+		}
+	}
+	return values
 }
 
 // oidInAttributeTypeAndValue reports whether a type with the given OID exists
@@ -0,0 +1,351 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VerificationPolicy is a serializable description of the restrictions that
+// Verify applies to certificate chains, suitable for keeping fleet-wide
+// policy in configuration files. The struct tags follow encoding/json
+// conventions, which most YAML packages also honor.
+//
+// A VerificationPolicy does not include the pools of trusted and
+// intermediate certificates or the name to verify, which are set on the
+// VerifyOptions returned by its VerifyOptions method.
+type VerificationPolicy struct {
+	// ExtKeyUsages lists the acceptable extended key usages by name, such
+	// as "serverAuth", "clientAuth" or "anyExtendedKeyUsage". See
+	// VerifyOptions.KeyUsages.
+	ExtKeyUsages []string `json:"extKeyUsages,omitempty"`
+	// RequireAllExtKeyUsages requires every usage of ExtKeyUsages instead
+	// of any of them. See VerifyOptions.RequireAllKeyUsages.
+	RequireAllExtKeyUsages bool `json:"requireAllExtKeyUsages,omitempty"`
+	// CertificatePolicies lists the acceptable certificate policies in
+	// dotted decimal form. See VerifyOptions.CertificatePolicies.
+	CertificatePolicies []string `json:"certificatePolicies,omitempty"`
+
+	// MinRSAKeySize is the minimum size in bits of RSA keys.
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+	// DisallowedSignatureAlgorithms lists signature algorithms by the names
+	// returned by SignatureAlgorithm.String, such as "SHA1-RSA".
+	DisallowedSignatureAlgorithms []string `json:"disallowedSignatureAlgorithms,omitempty"`
+
+	// Revocation is the revocation checking mode. Only "none", which is
+	// also the default, is currently supported.
+	Revocation string `json:"revocation,omitempty"`
+
+	// ClockSkew is the tolerated clock skew, in the syntax accepted by
+	// time.ParseDuration, such as "5m".
+	ClockSkew string `json:"clockSkew,omitempty"`
+	// MaxChainLength is the maximum number of certificates in a chain,
+	// including the leaf and the root.
+	MaxChainLength int `json:"maxChainLength,omitempty"`
+
+	// PinnedKeys and BlockedKeys hold base64 encoded SHA-256 hashes of
+	// SubjectPublicKeyInfo structures, in the format used by HTTP Public Key
+	// Pinning. See VerifyOptions.PinnedKeys and VerifyOptions.BlockedKeys.
+	PinnedKeys  []string `json:"pinnedKeys,omitempty"`
+	BlockedKeys []string `json:"blockedKeys,omitempty"`
+
+	// CTRequirements are the Certificate Transparency requirements. See
+	// VerifyOptions.CTRequirements.
+	CTRequirements []CTRequirementPolicy `json:"ctRequirements,omitempty"`
+
+	// StrictECDSASignatures and RequireLowS restrict the encoding of ECDSA
+	// signatures. See the VerifyOptions fields of the same names.
+	StrictECDSASignatures bool `json:"strictECDSASignatures,omitempty"`
+	RequireLowS           bool `json:"requireLowS,omitempty"`
+
+	// StrictKeyUsageEncoding requires key usage extensions to be DER
+	// encoded. See VerifyOptions.StrictKeyUsageEncoding.
+	StrictKeyUsageEncoding bool `json:"strictKeyUsageEncoding,omitempty"`
+
+	// StrictBasicConstraints requires roots to have a basic constraints
+	// extension asserting cA. See VerifyOptions.StrictBasicConstraints.
+	StrictBasicConstraints bool `json:"strictBasicConstraints,omitempty"`
+
+	// NormalizeDirectoryNames relaxes the comparison of directoryName
+	// constraints. See VerifyOptions.NormalizeDirectoryNames.
+	NormalizeDirectoryNames bool `json:"normalizeDirectoryNames,omitempty"`
+
+	// LegacyCommonName is "ignored" or "hostname" to override the GODEBUG
+	// setting for treating the Common Name as a hostname. See
+	// VerifyOptions.LegacyCommonName.
+	LegacyCommonName string `json:"legacyCommonName,omitempty"`
+
+	// IPv4Mapped is "equivalent" or "distinct" to select how IPv4-mapped
+	// IPv6 addresses relate to IPv4 addresses. See VerifyOptions.IPv4Mapped.
+	IPv4Mapped string `json:"ipv4Mapped,omitempty"`
+
+	// ValidityNesting is "warn" or "enforce" to check that the validity
+	// period of each certificate is within that of its issuer. See
+	// VerifyOptions.ValidityNesting.
+	ValidityNesting string `json:"validityNesting,omitempty"`
+
+	// ConstantTimeMatching hides which pool certificates and pinned keys
+	// were compared. See VerifyOptions.ConstantTimeMatching.
+	ConstantTimeMatching bool `json:"constantTimeMatching,omitempty"`
+}
+
+// CTRequirementPolicy is the serializable form of a CTRequirement. Its Roots
+// can't be serialized, so the requirement applies to chains to any root.
+type CTRequirementPolicy struct {
+	// After is the time, in RFC 3339 format, after which leaves must have
+	// SCTs, or empty to require them from all leaves.
+	After string `json:"after,omitempty"`
+	// MinSCTs is the number of SCTs from distinct logs required.
+	MinSCTs int `json:"minSCTs,omitempty"`
+}
+
+var extKeyUsageNames = []struct {
+	extKeyUsage ExtKeyUsage
+	name        string
+}{
+	{ExtKeyUsageAny, "anyExtendedKeyUsage"},
+	{ExtKeyUsageServerAuth, "serverAuth"},
+	{ExtKeyUsageClientAuth, "clientAuth"},
+	{ExtKeyUsageCodeSigning, "codeSigning"},
+	{ExtKeyUsageEmailProtection, "emailProtection"},
+	{ExtKeyUsageIPSECEndSystem, "ipsecEndSystem"},
+	{ExtKeyUsageIPSECTunnel, "ipsecTunnel"},
+	{ExtKeyUsageIPSECUser, "ipsecUser"},
+	{ExtKeyUsageTimeStamping, "timeStamping"},
+	{ExtKeyUsageOCSPSigning, "OCSPSigning"},
+	{ExtKeyUsageMicrosoftServerGatedCrypto, "msSGC"},
+	{ExtKeyUsageNetscapeServerGatedCrypto, "nsSGC"},
+	{ExtKeyUsageMicrosoftCommercialCodeSigning, "msCodeCom"},
+	{ExtKeyUsageMicrosoftKernelCodeSigning, "msKernelCodeSigning"},
+}
+
+// VerifyOptions returns the VerifyOptions that implement p. It returns an
+// error if p contains unknown names or malformed values.
+func (p *VerificationPolicy) VerifyOptions() (VerifyOptions, error) {
+	opts := VerifyOptions{
+		RequireAllKeyUsages:     p.RequireAllExtKeyUsages,
+		MinRSAKeySize:           p.MinRSAKeySize,
+		MaxChainLength:          p.MaxChainLength,
+		StrictECDSASignatures:   p.StrictECDSASignatures,
+		RequireLowS:             p.RequireLowS,
+		StrictKeyUsageEncoding:  p.StrictKeyUsageEncoding,
+		StrictBasicConstraints:  p.StrictBasicConstraints,
+		NormalizeDirectoryNames: p.NormalizeDirectoryNames,
+		ConstantTimeMatching:    p.ConstantTimeMatching,
+	}
+
+NextUsage:
+	for _, name := range p.ExtKeyUsages {
+		for _, u := range extKeyUsageNames {
+			if u.name == name {
+				opts.KeyUsages = append(opts.KeyUsages, u.extKeyUsage)
+				continue NextUsage
+			}
+		}
+		return VerifyOptions{}, fmt.Errorf("x509: unknown extended key usage %q", name)
+	}
+
+NextAlgorithm:
+	for _, name := range p.DisallowedSignatureAlgorithms {
+		for _, details := range signatureAlgorithmDetails {
+			if details.name == name {
+				opts.DisallowedSignatureAlgorithms = append(opts.DisallowedSignatureAlgorithms, details.algo)
+				continue NextAlgorithm
+			}
+		}
+		return VerifyOptions{}, fmt.Errorf("x509: unknown signature algorithm %q", name)
+	}
+
+	for _, s := range p.CertificatePolicies {
+		policy, err := ParseOID(s)
+		if err != nil {
+			return VerifyOptions{}, fmt.Errorf("x509: invalid certificate policy %q", s)
+		}
+		opts.CertificatePolicies = append(opts.CertificatePolicies, policy)
+	}
+
+	switch p.LegacyCommonName {
+	case "":
+	case "ignored":
+		opts.LegacyCommonName = CommonNameIgnored
+	case "hostname":
+		opts.LegacyCommonName = CommonNameAsHostname
+	default:
+		return VerifyOptions{}, fmt.Errorf("x509: unknown Common Name mode %q", p.LegacyCommonName)
+	}
+
+	switch p.IPv4Mapped {
+	case "":
+	case "equivalent":
+		opts.IPv4Mapped = IPv4MappedEquivalent
+	case "distinct":
+		opts.IPv4Mapped = IPv4MappedDistinct
+	default:
+		return VerifyOptions{}, fmt.Errorf("x509: unknown IPv4-mapped address mode %q", p.IPv4Mapped)
+	}
+
+	switch p.ValidityNesting {
+	case "":
+	case "warn":
+		opts.ValidityNesting = ValidityNestingWarn
+	case "enforce":
+		opts.ValidityNesting = ValidityNestingEnforced
+	default:
+		return VerifyOptions{}, fmt.Errorf("x509: unknown validity nesting mode %q", p.ValidityNesting)
+	}
+
+	switch p.Revocation {
+	case "", "none":
+	default:
+		return VerifyOptions{}, fmt.Errorf("x509: unsupported revocation mode %q", p.Revocation)
+	}
+
+	if p.ClockSkew != "" {
+		skew, err := time.ParseDuration(p.ClockSkew)
+		if err != nil {
+			return VerifyOptions{}, fmt.Errorf("x509: invalid clock skew: %v", err)
+		}
+		if skew < 0 {
+			return VerifyOptions{}, errors.New("x509: negative clock skew")
+		}
+		opts.ClockSkew = skew
+	}
+
+	for _, r := range p.CTRequirements {
+		req := CTRequirement{MinSCTs: r.MinSCTs}
+		if r.After != "" {
+			after, err := time.Parse(time.RFC3339, r.After)
+			if err != nil {
+				return VerifyOptions{}, fmt.Errorf("x509: invalid CT requirement date: %v", err)
+			}
+			req.After = after
+		}
+		if req.MinSCTs < 0 {
+			return VerifyOptions{}, errors.New("x509: negative number of SCTs in CT requirement")
+		}
+		opts.CTRequirements = append(opts.CTRequirements, req)
+	}
+
+	var err error
+	if opts.PinnedKeys, err = decodeKeyHashes(p.PinnedKeys); err != nil {
+		return VerifyOptions{}, err
+	}
+	if opts.BlockedKeys, err = decodeKeyHashes(p.BlockedKeys); err != nil {
+		return VerifyOptions{}, err
+	}
+
+	return opts, nil
+}
+
+// PolicyFromVerifyOptions returns the VerificationPolicy corresponding to
+// opts, ignoring the fields of opts that are not part of a policy, such as
+// the certificate pools. It returns an error if opts uses values that have
+// no name.
+func PolicyFromVerifyOptions(opts VerifyOptions) (*VerificationPolicy, error) {
+	p := &VerificationPolicy{
+		RequireAllExtKeyUsages:  opts.RequireAllKeyUsages,
+		MinRSAKeySize:           opts.MinRSAKeySize,
+		MaxChainLength:          opts.MaxChainLength,
+		StrictECDSASignatures:   opts.StrictECDSASignatures,
+		RequireLowS:             opts.RequireLowS,
+		StrictKeyUsageEncoding:  opts.StrictKeyUsageEncoding,
+		StrictBasicConstraints:  opts.StrictBasicConstraints,
+		NormalizeDirectoryNames: opts.NormalizeDirectoryNames,
+		ConstantTimeMatching:    opts.ConstantTimeMatching,
+	}
+
+NextUsage:
+	for _, usage := range opts.KeyUsages {
+		for _, u := range extKeyUsageNames {
+			if u.extKeyUsage == usage {
+				p.ExtKeyUsages = append(p.ExtKeyUsages, u.name)
+				continue NextUsage
+			}
+		}
+		return nil, fmt.Errorf("x509: unknown extended key usage %d", usage)
+	}
+
+NextAlgorithm:
+	for _, algo := range opts.DisallowedSignatureAlgorithms {
+		for _, details := range signatureAlgorithmDetails {
+			if details.algo == algo {
+				p.DisallowedSignatureAlgorithms = append(p.DisallowedSignatureAlgorithms, details.name)
+				continue NextAlgorithm
+			}
+		}
+		return nil, fmt.Errorf("x509: unknown signature algorithm %v", algo)
+	}
+
+	for _, policy := range opts.CertificatePolicies {
+		p.CertificatePolicies = append(p.CertificatePolicies, policy.String())
+	}
+
+	switch opts.LegacyCommonName {
+	case CommonNameDefault:
+	case CommonNameIgnored:
+		p.LegacyCommonName = "ignored"
+	case CommonNameAsHostname:
+		p.LegacyCommonName = "hostname"
+	default:
+		return nil, fmt.Errorf("x509: unknown Common Name mode %d", opts.LegacyCommonName)
+	}
+
+	switch opts.IPv4Mapped {
+	case IPv4MappedDefault:
+	case IPv4MappedEquivalent:
+		p.IPv4Mapped = "equivalent"
+	case IPv4MappedDistinct:
+		p.IPv4Mapped = "distinct"
+	default:
+		return nil, fmt.Errorf("x509: unknown IPv4-mapped address mode %d", opts.IPv4Mapped)
+	}
+
+	switch opts.ValidityNesting {
+	case ValidityNestingIgnored:
+	case ValidityNestingWarn:
+		p.ValidityNesting = "warn"
+	case ValidityNestingEnforced:
+		p.ValidityNesting = "enforce"
+	default:
+		return nil, fmt.Errorf("x509: unknown validity nesting mode %d", opts.ValidityNesting)
+	}
+
+	if opts.ClockSkew != 0 {
+		p.ClockSkew = opts.ClockSkew.String()
+	}
+	for _, r := range opts.CTRequirements {
+		if r.Roots != nil {
+			return nil, errors.New("x509: CT requirement restricted to a root pool has no policy form")
+		}
+		req := CTRequirementPolicy{MinSCTs: r.MinSCTs}
+		if !r.After.IsZero() {
+			req.After = r.After.Format(time.RFC3339)
+		}
+		p.CTRequirements = append(p.CTRequirements, req)
+	}
+	for _, h := range opts.PinnedKeys {
+		p.PinnedKeys = append(p.PinnedKeys, base64.StdEncoding.EncodeToString(h))
+	}
+	for _, h := range opts.BlockedKeys {
+		p.BlockedKeys = append(p.BlockedKeys, base64.StdEncoding.EncodeToString(h))
+	}
+
+	return p, nil
+}
+
+func decodeKeyHashes(in []string) ([][]byte, error) {
+	var out [][]byte
+	for _, s := range in {
+		h, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || len(h) != 32 {
+			return nil, fmt.Errorf("x509: invalid SHA-256 public key hash %q", s)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVerificationPolicyRoundTrip(t *testing.T) {
+	const config = `{
+		"extKeyUsages": ["serverAuth", "clientAuth"],
+		"requireAllExtKeyUsages": true,
+		"certificatePolicies": ["2.16.840.1.101.3.2.1.3.13", "2.5.29.32.0"],
+		"minRSAKeySize": 2048,
+		"disallowedSignatureAlgorithms": ["SHA1-RSA", "MD5-RSA"],
+		"revocation": "none",
+		"clockSkew": "5m0s",
+		"maxChainLength": 4,
+		"pinnedKeys": ["47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="],
+		"ctRequirements": [{"after": "2018-04-30T00:00:00Z", "minSCTs": 2}],
+		"requireLowS": true,
+		"strictKeyUsageEncoding": true,
+		"strictBasicConstraints": true,
+		"normalizeDirectoryNames": true,
+		"legacyCommonName": "ignored",
+		"ipv4Mapped": "equivalent",
+		"validityNesting": "warn",
+		"constantTimeMatching": true
+	}`
+
+	var p VerificationPolicy
+	if err := json.Unmarshal([]byte(config), &p); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := p.VerifyOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := sha256.Sum256(nil)
+	medium, _ := ParseOID("2.16.840.1.101.3.2.1.3.13")
+	want := VerifyOptions{
+		KeyUsages:                     []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth},
+		RequireAllKeyUsages:           true,
+		CertificatePolicies:           []OID{medium, anyPolicy},
+		MinRSAKeySize:                 2048,
+		DisallowedSignatureAlgorithms: []SignatureAlgorithm{SHA1WithRSA, MD5WithRSA},
+		ClockSkew:                     5 * time.Minute,
+		MaxChainLength:                4,
+		PinnedKeys:                    [][]byte{empty[:]},
+		CTRequirements:                []CTRequirement{{After: time.Date(2018, 4, 30, 0, 0, 0, 0, time.UTC), MinSCTs: 2}},
+		RequireLowS:                   true,
+		StrictKeyUsageEncoding:        true,
+		StrictBasicConstraints:        true,
+		NormalizeDirectoryNames:       true,
+		LegacyCommonName:              CommonNameIgnored,
+		IPv4Mapped:                    IPv4MappedEquivalent,
+		ValidityNesting:               ValidityNestingWarn,
+		ConstantTimeMatching:          true,
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("got options %+v, want %+v", opts, want)
+	}
+
+	back, err := PolicyFromVerifyOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Revocation = ""
+	if !reflect.DeepEqual(back, &p) {
+		t.Errorf("got policy %+v, want %+v", back, &p)
+	}
+
+	for _, bad := range []VerificationPolicy{
+		{ExtKeyUsages: []string{"serverauth"}},
+		{DisallowedSignatureAlgorithms: []string{"ROT13"}},
+		{Revocation: "hard-fail"},
+		{ClockSkew: "5 minutes"},
+		{PinnedKeys: []string{"AAAA"}},
+		{CertificatePolicies: []string{"anyPolicy"}},
+		{LegacyCommonName: "true"},
+		{IPv4Mapped: "mapped"},
+		{ValidityNesting: "always"},
+		{CTRequirements: []CTRequirementPolicy{{After: "2018-04-30"}}},
+	} {
+		if _, err := bad.VerifyOptions(); err == nil {
+			t.Errorf("policy %+v was accepted", bad)
+		}
+	}
+}
+
+func TestVerifyChainPolicy(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := func(c *Certificate) []byte {
+		h := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+		return h[:]
+	}
+	other := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name   string
+		opts   VerifyOptions
+		reason InvalidReason
+		ok     bool
+	}{
+		{name: "no policy", ok: true},
+		{name: "max length", opts: VerifyOptions{MaxChainLength: 2}, reason: ChainTooLong},
+		{name: "disallowed algorithm", opts: VerifyOptions{DisallowedSignatureAlgorithms: []SignatureAlgorithm{ECDSAWithSHA256}}, reason: InsecureAlgorithm},
+		{name: "pinned intermediate", opts: VerifyOptions{PinnedKeys: [][]byte{other[:], hash(intermediate)}}, ok: true},
+		{name: "no pin", opts: VerifyOptions{PinnedKeys: [][]byte{other[:]}}, reason: NoPinnedKey},
+		{name: "blocked root", opts: VerifyOptions{BlockedKeys: [][]byte{hash(root)}}, reason: BlockedKey},
+		{name: "expired", opts: VerifyOptions{CurrentTime: leaf.NotAfter.Add(time.Minute)}, reason: Expired},
+		{name: "clock skew", opts: VerifyOptions{CurrentTime: leaf.NotAfter.Add(time.Minute), ClockSkew: 5 * time.Minute}, ok: true},
+	}
+	for _, tt := range tests {
+		for _, constantTime := range []bool{false, true} {
+			opts := tt.opts
+			opts.Roots = NewCertPool()
+			opts.Roots.AddCert(root)
+			opts.Intermediates = NewCertPool()
+			opts.Intermediates.AddCert(intermediate)
+			opts.ConstantTimeMatching = constantTime
+
+			_, err := leaf.Verify(opts)
+			if tt.ok {
+				if err != nil {
+					t.Errorf("%s (constant time %v): unexpected error: %v", tt.name, constantTime, err)
+				}
+				continue
+			}
+			if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != tt.reason {
+				t.Errorf("%s (constant time %v): got error %v, want reason %d", tt.name, constantTime, err, tt.reason)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// PrivateKeyFormat identifies an encoding of private keys.
+type PrivateKeyFormat int
+
+const (
+	PrivateKeyPKCS8   PrivateKeyFormat = iota + 1 // PKCS#8, PEM type "PRIVATE KEY"
+	PrivateKeyPKCS1                               // PKCS#1, PEM type "RSA PRIVATE KEY"
+	PrivateKeySEC1                                // SEC 1, PEM type "EC PRIVATE KEY"
+	PrivateKeyOpenSSH                             // OpenSSH, PEM type "OPENSSH PRIVATE KEY"
+)
+
+var privateKeyFormats = []struct {
+	format  PrivateKeyFormat
+	name    string
+	pemType string
+	parse   func(der []byte) (interface{}, error)
+}{
+	{PrivateKeyPKCS8, "PKCS#8", "PRIVATE KEY", ParsePKCS8PrivateKey},
+	{PrivateKeyPKCS1, "PKCS#1", "RSA PRIVATE KEY", func(der []byte) (interface{}, error) { return ParsePKCS1PrivateKey(der) }},
+	{PrivateKeySEC1, "SEC 1", "EC PRIVATE KEY", func(der []byte) (interface{}, error) { return ParseECPrivateKey(der) }},
+	{PrivateKeyOpenSSH, "OpenSSH", "OPENSSH PRIVATE KEY", parseOpenSSHPrivateKey},
+}
+
+func (f PrivateKeyFormat) String() string {
+	for _, p := range privateKeyFormats {
+		if p.format == f {
+			return p.name
+		}
+	}
+	return fmt.Sprintf("PrivateKeyFormat(%d)", int(f))
+}
+
+// ParsePrivateKeyError is returned by ParsePrivateKey if the key could not
+// be parsed in any of the formats it tried.
+type ParsePrivateKeyError struct {
+	// Formats lists the formats that were tried, in order, and Errs holds
+	// the corresponding errors.
+	Formats []PrivateKeyFormat
+	Errs    []error
+}
+
+func (e *ParsePrivateKeyError) Error() string {
+	var parts []string
+	for i, f := range e.Formats {
+		parts = append(parts, f.String()+": "+strings.TrimPrefix(e.Errs[i].Error(), "x509: "))
+	}
+	return "x509: failed to parse private key (" + strings.Join(parts, "; ") + ")"
+}
+
+// ParsePrivateKey parses an unencrypted private key in PKCS#8, PKCS#1, SEC 1
+// or OpenSSH format. data may be PEM, in which case the first block whose
+// type ends in "PRIVATE KEY" is used, or DER. For OpenSSH keys, DER refers
+// to the binary encoding inside the PEM block.
+//
+// The format suggested by the PEM block type, if any, is tried first,
+// followed by the others. It returns a *rsa.PrivateKey, a *ecdsa.PrivateKey,
+// or a ed25519.PrivateKey, along with the format in which it was found. If
+// no format matches, the error is a *ParsePrivateKeyError.
+//
+// Encrypted keys are not supported, see DecryptPEMBlock for legacy
+// encrypted PEM blocks.
+func ParsePrivateKey(data []byte) (key interface{}, format PrivateKeyFormat, err error) {
+	der, pemType := data, ""
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		var block *pem.Block
+		for {
+			block, data = pem.Decode(data)
+			if block == nil {
+				return nil, 0, errors.New("x509: no private key PEM block found")
+			}
+			if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+				break
+			}
+		}
+		if block.Type == "ENCRYPTED PRIVATE KEY" || IsEncryptedPEMBlock(block) {
+			return nil, 0, errors.New("x509: encrypted private keys are not supported")
+		}
+		der, pemType = block.Bytes, block.Type
+	}
+
+	var formats []PrivateKeyFormat
+	var errs []error
+	try := func(i int) (interface{}, PrivateKeyFormat, bool) {
+		p := privateKeyFormats[i]
+		key, err := p.parse(der)
+		if err == nil {
+			return key, p.format, true
+		}
+		formats = append(formats, p.format)
+		errs = append(errs, err)
+		return nil, 0, false
+	}
+
+	hinted := -1
+	for i, p := range privateKeyFormats {
+		if p.pemType == pemType {
+			hinted = i
+			if key, format, ok := try(i); ok {
+				return key, format, nil
+			}
+		}
+	}
+	for i := range privateKeyFormats {
+		if i == hinted {
+			continue
+		}
+		if key, format, ok := try(i); ok {
+			return key, format, nil
+		}
+	}
+	return nil, 0, &ParsePrivateKeyError{Formats: formats, Errs: errs}
+}
+
+const openSSHMagic = "openssh-key-v1\x00"
+
+var errInvalidOpenSSHKey = errors.New("x509: invalid OpenSSH private key")
+
+// parseOpenSSHPrivateKey parses the binary form of an unencrypted
+// "openssh-key-v1" private key, as described in the PROTOCOL.key file of
+// the OpenSSH distribution.
+func parseOpenSSHPrivateKey(der []byte) (interface{}, error) {
+	if !bytes.HasPrefix(der, []byte(openSSHMagic)) {
+		return nil, errors.New("x509: missing OpenSSH private key header")
+	}
+	input := cryptobyte.String(der[len(openSSHMagic):])
+
+	var cipherName, kdfName, kdfOptions, publicKey, private cryptobyte.String
+	var numKeys uint32
+	if !readSSHString(&input, &cipherName) ||
+		!readSSHString(&input, &kdfName) ||
+		!readSSHString(&input, &kdfOptions) ||
+		!input.ReadUint32(&numKeys) ||
+		!readSSHString(&input, &publicKey) ||
+		!readSSHString(&input, &private) || !input.Empty() {
+		return nil, errInvalidOpenSSHKey
+	}
+	if string(cipherName) != "none" || string(kdfName) != "none" {
+		return nil, errors.New("x509: encrypted OpenSSH private keys are not supported")
+	}
+	if numKeys != 1 {
+		return nil, errors.New("x509: OpenSSH private key files with multiple keys are not supported")
+	}
+
+	var check1, check2 uint32
+	var keyType cryptobyte.String
+	if !private.ReadUint32(&check1) || !private.ReadUint32(&check2) || check1 != check2 ||
+		!readSSHString(&private, &keyType) {
+		return nil, errInvalidOpenSSHKey
+	}
+
+	var key interface{}
+	var err error
+	switch string(keyType) {
+	case "ssh-ed25519":
+		key, err = parseOpenSSHEd25519(&private)
+	case "ssh-rsa":
+		key, err = parseOpenSSHRSA(&private)
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		key, err = parseOpenSSHECDSA(&private, string(keyType))
+	default:
+		return nil, fmt.Errorf("x509: unsupported OpenSSH private key type %q", string(keyType))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The comment is followed by the padding bytes 1, 2, 3 and so on.
+	var comment cryptobyte.String
+	if !readSSHString(&private, &comment) {
+		return nil, errInvalidOpenSSHKey
+	}
+	for i := 0; !private.Empty(); i++ {
+		var b uint8
+		if !private.ReadUint8(&b) || int(b) != i+1 {
+			return nil, errInvalidOpenSSHKey
+		}
+	}
+	return key, nil
+}
+
+func parseOpenSSHEd25519(s *cryptobyte.String) (interface{}, error) {
+	var pub, priv cryptobyte.String
+	if !readSSHString(s, &pub) || !readSSHString(s, &priv) ||
+		len(pub) != ed25519.PublicKeySize || len(priv) != ed25519.PrivateKeySize {
+		return nil, errInvalidOpenSSHKey
+	}
+	key := ed25519.NewKeyFromSeed(priv[:ed25519.SeedSize])
+	if !bytes.Equal(key[ed25519.SeedSize:], pub) || !bytes.Equal(key, priv) {
+		return nil, errors.New("x509: OpenSSH Ed25519 private key does not match its public key")
+	}
+	return key, nil
+}
+
+func parseOpenSSHRSA(s *cryptobyte.String) (interface{}, error) {
+	var n, e, d, iqmp, p, q *big.Int
+	for _, v := range []**big.Int{&n, &e, &d, &iqmp, &p, &q} {
+		if *v = readSSHMPInt(s); *v == nil {
+			return nil, errInvalidOpenSSHKey
+		}
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("x509: OpenSSH RSA private key has an invalid public exponent")
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+		D:         d,
+		Primes:    []*big.Int{p, q},
+	}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	key.Precompute()
+	return key, nil
+}
+
+func parseOpenSSHECDSA(s *cryptobyte.String, keyType string) (interface{}, error) {
+	var curve elliptic.Curve
+	switch keyType {
+	case "ecdsa-sha2-nistp256":
+		curve = elliptic.P256()
+	case "ecdsa-sha2-nistp384":
+		curve = elliptic.P384()
+	case "ecdsa-sha2-nistp521":
+		curve = elliptic.P521()
+	}
+	var curveName, point cryptobyte.String
+	if !readSSHString(s, &curveName) || "ecdsa-sha2-"+string(curveName) != keyType ||
+		!readSSHString(s, &point) {
+		return nil, errInvalidOpenSSHKey
+	}
+	d := readSSHMPInt(s)
+	if d == nil || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errInvalidOpenSSHKey
+	}
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.Bytes())
+	if !bytes.Equal(elliptic.Marshal(curve, key.X, key.Y), point) {
+		return nil, errors.New("x509: OpenSSH ECDSA private key does not match its public key")
+	}
+	return key, nil
+}
+
+// readSSHString reads a string, as defined in RFC 4251, Section 5, into out.
+func readSSHString(s, out *cryptobyte.String) bool {
+	var n uint32
+	var b []byte
+	if !s.ReadUint32(&n) || !s.ReadBytes(&b, int(n)) {
+		return false
+	}
+	*out = b
+	return true
+}
+
+// readSSHMPInt reads a non-negative SSH mpint, as defined in RFC 4251,
+// Section 5. It returns nil on error.
+func readSSHMPInt(s *cryptobyte.String) *big.Int {
+	var b cryptobyte.String
+	if !readSSHString(s, &b) || len(b) > 0 && b[0]&0x80 != 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
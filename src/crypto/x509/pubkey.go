@@ -0,0 +1,170 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+)
+
+// PublicKeysEqual reports whether a and b are the same public key.
+//
+// Keys that implement an Equal(crypto.PublicKey) bool method, as the
+// *rsa.PublicKey, *ecdsa.PublicKey and ed25519.PublicKey types do, are
+// compared with it. Other keys are equal if MarshalPKIXPublicKey encodes them
+// identically. Unlike reflect.DeepEqual, this does not depend on internal
+// representations, such as the normalization of big.Int values or whether
+// the curve of an ECDSA key is the same pointer.
+//
+// PublicKeysEqual returns false if either key is nil.
+func PublicKeysEqual(a, b crypto.PublicKey) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if k, ok := a.(interface{ Equal(crypto.PublicKey) bool }); ok {
+		return k.Equal(b)
+	}
+	aBytes, aAlgo, err := marshalPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bBytes, bAlgo, err := marshalPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return aAlgo.Algorithm.Equal(bAlgo.Algorithm) &&
+		bytes.Equal(aAlgo.Parameters.FullBytes, bAlgo.Parameters.FullBytes) &&
+		bytes.Equal(aBytes, bBytes)
+}
+
+// SameKeyAs reports whether c and other certify the same public key.
+//
+// If the public key algorithm of either certificate is not supported by this
+// package, the algorithms, their parameters and the key bits of the two
+// SubjectPublicKeyInfo structures are compared instead.
+func (c *Certificate) SameKeyAs(other *Certificate) bool {
+	if c.PublicKey != nil && other.PublicKey != nil {
+		return PublicKeysEqual(c.PublicKey, other.PublicKey)
+	}
+	return sameSubjectPublicKeyInfo(c.RawSubjectPublicKeyInfo, other.RawSubjectPublicKeyInfo)
+}
+
+// sameSubjectPublicKeyInfo compares two DER encoded SubjectPublicKeyInfo
+// structures by their contents, so that equivalent encodings of the same
+// key are equal.
+func sameSubjectPublicKeyInfo(a, b []byte) bool {
+	var aInfo, bInfo publicKeyInfo
+	if rest, err := asn1.Unmarshal(a, &aInfo); err != nil || len(rest) != 0 {
+		return false
+	}
+	if rest, err := asn1.Unmarshal(b, &bInfo); err != nil || len(rest) != 0 {
+		return false
+	}
+	return aInfo.Algorithm.Algorithm.Equal(bInfo.Algorithm.Algorithm) &&
+		bytes.Equal(aInfo.Algorithm.Parameters.FullBytes, bInfo.Algorithm.Parameters.FullBytes) &&
+		aInfo.PublicKey.BitLength == bInfo.PublicKey.BitLength &&
+		bytes.Equal(aInfo.PublicKey.Bytes, bInfo.PublicKey.Bytes)
+}
+
+// PublicKeyInfo is a parsed SubjectPublicKeyInfo structure, as defined in RFC
+// 5280, Section 4.1. Unlike ParsePKIXPublicKey, ParsePKIX keeps the
+// algorithm identifier and the encoded key of algorithms this package does
+// not support, so that inventory tools can classify keys they cannot use.
+type PublicKeyInfo struct {
+	Raw []byte // Complete ASN.1 DER content.
+
+	// Algorithm is the algorithm identifier, including its raw parameters.
+	Algorithm pkix.AlgorithmIdentifier
+	// SubjectPublicKey is the encoded public key.
+	SubjectPublicKey asn1.BitString
+
+	// PublicKeyAlgorithm is UnknownPublicKeyAlgorithm if the algorithm is
+	// not supported by this package.
+	PublicKeyAlgorithm PublicKeyAlgorithm
+	// PublicKey is the parsed key, as returned by ParsePKIXPublicKey. It is
+	// nil if the algorithm or the elliptic curve is not supported.
+	PublicKey interface{}
+
+	// Size is the size of the key in bits: the size of the modulus for RSA,
+	// of the prime P for DSA and of the curve for ECDSA, and 256 for
+	// Ed25519. It is zero if PublicKey is nil.
+	Size int
+
+	// Curve is the named curve of an ECDSA key, which is set even if the
+	// curve is not supported. CurveName is its name, such as "P-256", if it
+	// is supported.
+	Curve     asn1.ObjectIdentifier
+	CurveName string
+}
+
+// ParsePKIX parses a DER encoded SubjectPublicKeyInfo structure.
+//
+// Keys of unsupported algorithms and ECDSA keys on unsupported curves are
+// not an error; the returned PublicKeyInfo then has a nil PublicKey. An
+// error is returned if the structure is malformed, or if the key of a
+// supported algorithm does not parse.
+func ParsePKIX(derBytes []byte) (*PublicKeyInfo, error) {
+	var pki publicKeyInfo
+	if rest, err := asn1.Unmarshal(derBytes, &pki); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("x509: trailing data after ASN.1 of public-key")
+	}
+
+	info := &PublicKeyInfo{
+		Raw:                pki.Raw,
+		Algorithm:          pki.Algorithm,
+		SubjectPublicKey:   pki.PublicKey,
+		PublicKeyAlgorithm: getPublicKeyAlgorithmFromOID(pki.Algorithm.Algorithm),
+	}
+
+	if info.PublicKeyAlgorithm == ECDSA {
+		var curve asn1.ObjectIdentifier
+		if rest, err := asn1.Unmarshal(pki.Algorithm.Parameters.FullBytes, &curve); err != nil || len(rest) != 0 {
+			return nil, errors.New("x509: failed to parse ECDSA parameters as named curve")
+		}
+		info.Curve = curve
+		if namedCurveFromOID(curve) == nil {
+			return info, nil
+		}
+	}
+	if info.PublicKeyAlgorithm == UnknownPublicKeyAlgorithm {
+		return info, nil
+	}
+
+	pub, err := parsePublicKey(info.PublicKeyAlgorithm, &pki)
+	if err != nil {
+		return nil, err
+	}
+	info.PublicKey = pub
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		info.Size = pub.N.BitLen()
+	case *dsa.PublicKey:
+		info.Size = pub.P.BitLen()
+	case *ecdsa.PublicKey:
+		info.Size = pub.Curve.Params().BitSize
+		info.CurveName = pub.Curve.Params().Name
+	case ed25519.PublicKey:
+		info.Size = 8 * ed25519.PublicKeySize
+	}
+	return info, nil
+}
+
+// Marshal returns the DER encoding of the SubjectPublicKeyInfo structure
+// made of p.Algorithm and p.SubjectPublicKey. The other fields are ignored.
+func (p *PublicKeyInfo) Marshal() ([]byte, error) {
+	return asn1.Marshal(publicKeyInfo{
+		Algorithm: p.Algorithm,
+		PublicKey: p.SubjectPublicKey,
+	})
+}
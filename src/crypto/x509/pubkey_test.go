@@ -0,0 +1,175 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestPublicKeysEqual(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Copies with different internal representations.
+	rsaCopy := &rsa.PublicKey{N: new(big.Int).SetBytes(testPrivateKey.N.Bytes()), E: testPrivateKey.E}
+	ecCopy := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).Set(ecKey.X), Y: new(big.Int).Set(ecKey.Y)}
+	edCopy := append(ed25519.PublicKey(nil), edPub...)
+
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{&testPrivateKey.PublicKey, rsaCopy, true},
+		{&ecKey.PublicKey, ecCopy, true},
+		{edPub, edCopy, true},
+		{&testPrivateKey.PublicKey, &ecKey.PublicKey, false},
+		{&ecKey.PublicKey, edPub, false},
+		{edPub, ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)), false},
+		{edPub, nil, false},
+		{nil, nil, false},
+	}
+	for i, test := range tests {
+		if got := PublicKeysEqual(test.a, test.b); got != test.want {
+			t.Errorf("#%d: PublicKeysEqual(%T, %T) = %v; want %v", i, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSameKeyAs(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, _, err := RenewCertificate(root, nil, RenewalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, template.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !root.SameKeyAs(renewed) {
+		t.Error("renewed certificate does not have the same key")
+	}
+	if root.SameKeyAs(leaf) {
+		t.Error("certificates with different keys have the same key")
+	}
+
+	// Certificates with unsupported keys fall back to comparing the
+	// encoded keys.
+	unsupported := *renewed
+	unsupported.PublicKey = nil
+	if !root.SameKeyAs(&unsupported) || !unsupported.SameKeyAs(root) {
+		t.Error("fallback comparison does not match the same key")
+	}
+	if unsupported.SameKeyAs(leaf) {
+		t.Error("fallback comparison matches a different key")
+	}
+}
+
+func TestParsePKIX(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshal := func(pub interface{}) []byte {
+		der, err := MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	raw := func(algo pkix.AlgorithmIdentifier, key []byte) []byte {
+		der, err := asn1.Marshal(publicKeyInfo{
+			Algorithm: algo,
+			PublicKey: asn1.BitString{Bytes: key, BitLength: 8 * len(key)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	secp256k1, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+
+	tests := []struct {
+		name      string
+		der       []byte
+		algo      PublicKeyAlgorithm
+		hasKey    bool
+		size      int
+		curve     asn1.ObjectIdentifier
+		curveName string
+	}{
+		{"RSA", marshal(&testPrivateKey.PublicKey), RSA, true, 1024, nil, ""},
+		{"ECDSA", marshal(&ecKey.PublicKey), ECDSA, true, 384, oidNamedCurveP384, "P-384"},
+		{"Ed25519", marshal(edPub), Ed25519, true, 256, nil, ""},
+		{"unsupported curve", raw(pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: secp256k1},
+		}, make([]byte, 65)), ECDSA, false, 0, asn1.ObjectIdentifier{1, 3, 132, 0, 10}, ""},
+		{"X25519", raw(pkix.AlgorithmIdentifier{
+			Algorithm: asn1.ObjectIdentifier{1, 3, 101, 110},
+		}, make([]byte, 32)), UnknownPublicKeyAlgorithm, false, 0, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ParsePKIX(test.der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.PublicKeyAlgorithm != test.algo {
+				t.Errorf("PublicKeyAlgorithm = %v; want %v", info.PublicKeyAlgorithm, test.algo)
+			}
+			if (info.PublicKey != nil) != test.hasKey {
+				t.Errorf("PublicKey = %v; want key: %v", info.PublicKey, test.hasKey)
+			}
+			if info.Size != test.size {
+				t.Errorf("Size = %d; want %d", info.Size, test.size)
+			}
+			if !info.Curve.Equal(test.curve) || info.CurveName != test.curveName {
+				t.Errorf("Curve = %v %q; want %v %q", info.Curve, info.CurveName, test.curve, test.curveName)
+			}
+			der, err := info.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(der, test.der) || !bytes.Equal(info.Raw, test.der) {
+				t.Error("Marshal and Raw do not match the input")
+			}
+		})
+	}
+
+	if _, err := ParsePKIX(raw(pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyEd25519}, []byte{1, 2, 3})); err == nil {
+		t.Error("malformed Ed25519 key was accepted")
+	}
+}
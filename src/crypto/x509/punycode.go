@@ -0,0 +1,173 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// dnsNamesToASCII converts the internationalized names in names to their
+// ASCII form, replacing every label that contains non-ASCII characters with
+// its A-label, as described in RFC 5890, Section 2.3.2.1. If reject is true,
+// such names are an error instead.
+//
+// Labels are lowercased but not otherwise normalized; callers that need the
+// full mapping of UTS #46 should convert names with golang.org/x/net/idna
+// before putting them in a template.
+func dnsNamesToASCII(names []string, reject bool) ([]string, error) {
+	var out []string
+	for i, name := range names {
+		if isASCII(name) {
+			if out != nil {
+				out = append(out, name)
+			}
+			continue
+		}
+		if reject {
+			return nil, fmt.Errorf("x509: DNS name %q contains non-ASCII characters", name)
+		}
+		if out == nil {
+			out = append(make([]string, 0, len(names)), names[:i]...)
+		}
+		ascii, err := dnsNameToASCII(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ascii)
+	}
+	if out == nil {
+		return names, nil
+	}
+	return out, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func dnsNameToASCII(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("x509: DNS name %q is not valid UTF-8", name)
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncode(strings.ToLower(label))
+		if err != nil {
+			return "", fmt.Errorf("x509: cannot convert DNS name %q to ASCII: %v", name, err)
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// Bootstring parameters for Punycode, from RFC 3492, Section 5.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+var errPunycodeOverflow = errors.New("label too long")
+
+// punycodeEncode encodes label following RFC 3492, Section 6.3.
+func punycodeEncode(label string) (string, error) {
+	var out []byte
+	runes := []rune(label)
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+	for h < len(runes) {
+		m := rune(utf8.MaxRune + 1)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (1<<30)/(h+1) {
+			return "", errPunycodeOverflow
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+				if delta > 1<<30 {
+					return "", errPunycodeOverflow
+				}
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := k - bias
+				if t < punycodeTMin {
+					t = punycodeTMin
+				} else if t > punycodeTMax {
+					t = punycodeTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			out = append(out, punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+// punycodeAdapt is the bias adaptation function of RFC 3492, Section 6.1.
+func punycodeAdapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
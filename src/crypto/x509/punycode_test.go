@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDNSNameToASCII(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"*.München.de", "*.xn--mnchen-3ya.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"ü", "xn--tda"},
+	}
+	for _, test := range tests {
+		got, err := dnsNameToASCII(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got != test.out {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.out)
+		}
+	}
+	if _, err := dnsNameToASCII("bad\xffname"); err == nil {
+		t.Error("invalid UTF-8 was accepted")
+	}
+}
+
+func TestCreateCertificateUnicodeDNSNames(t *testing.T) {
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "IDN"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		DNSNames:     []string{"bücher.example", "www.example"},
+	}
+	cert := serialiseAndParse(t, template)
+	if want := []string{"xn--bcher-kva.example", "www.example"}; !reflect.DeepEqual(cert.DNSNames, want) {
+		t.Errorf("got DNS names %q, want %q", cert.DNSNames, want)
+	}
+	if template.DNSNames[0] != "bücher.example" {
+		t.Error("template was modified")
+	}
+
+	template.DNSNames = append(template.DNSNames, "xn--bcher-kva.example")
+	template.DuplicateHandling = DuplicatesError
+	if _, err := dedupSANs(template); err == nil {
+		t.Error("U-label and A-label of the same name were not detected as duplicates")
+	}
+
+	template.DNSNames = template.DNSNames[:1]
+	template.RejectUnicodeDNSNames = true
+	if _, err := dedupSANs(template); err == nil {
+		t.Error("non-ASCII DNS name accepted with RejectUnicodeDNSNames")
+	}
+}
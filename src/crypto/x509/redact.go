@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"strings"
+	"time"
+)
+
+// Redaction is a set of certificate fields to omit when logging a chain or a
+// ValidationReport, for example because client certificates hold the names
+// and email addresses of people.
+type Redaction uint
+
+const (
+	// RedactSerialNumber omits the serial numbers of all certificates.
+	RedactSerialNumber Redaction = 1 << iota
+	// RedactPublicKey omits the description of the public keys of all
+	// certificates.
+	RedactPublicKey
+	// RedactSubject omits the subject of the leaf, the first certificate of
+	// a chain or report. The names of the CAs above it are kept.
+	RedactSubject
+)
+
+// redacted replaces the omitted fields.
+const redacted = "[redacted]"
+
+// FormatChain is like the FormatChain function, but omits the fields in r.
+func (r Redaction) FormatChain(chain []*Certificate) string {
+	var b strings.Builder
+	for i, c := range chain {
+		if i > 0 {
+			b.WriteString(" -> ")
+		}
+		if subject := c.Subject.String(); i == 0 && r&RedactSubject != 0 {
+			b.WriteString(redacted)
+		} else if subject != "" {
+			b.WriteString(subject)
+		} else {
+			b.WriteString("<empty subject>")
+		}
+		b.WriteString(" (")
+		if r&RedactPublicKey == 0 {
+			b.WriteString(publicKeyKind(c.PublicKey))
+			b.WriteString(", ")
+		}
+		b.WriteString("expires ")
+		b.WriteString(c.NotAfter.UTC().Format(time.RFC3339))
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Report returns a copy of report without the fields in r, suitable for
+// marshaling to JSON. With RedactSubject, the subject of the leaf is also
+// removed from the error and the details of the checks. RedactPublicKey has
+// no effect, since reports don't describe public keys.
+func (r Redaction) Report(report *ValidationReport) *ValidationReport {
+	out := *report
+	out.Certificates = append([]CertificateReport(nil), report.Certificates...)
+	var subject string
+	if len(out.Certificates) > 0 && r&RedactSubject != 0 {
+		subject = out.Certificates[0].Subject
+		out.Certificates[0].Subject = redacted
+	}
+	scrub := func(s string) string {
+		if subject == "" {
+			return s
+		}
+		return strings.ReplaceAll(s, subject, redacted)
+	}
+	out.Error = scrub(out.Error)
+	for i := range out.Certificates {
+		c := &out.Certificates[i]
+		if r&RedactSerialNumber != 0 {
+			c.SerialNumber = redacted
+		}
+		for _, check := range []*CheckResult{&c.Signature, &c.Validity, &c.Revocation, &c.Constraints, &c.Policy} {
+			check.Detail = scrub(check.Detail)
+		}
+	}
+	return &out
+}
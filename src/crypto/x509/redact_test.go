@@ -0,0 +1,76 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedaction(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber:   big.NewInt(0x5ec2e7),
+		Subject:        pkix.Name{CommonName: "Jane Doe", Organization: []string{"Example"}},
+		EmailAddresses: []string{"jane@example.com"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExtKeyUsage:    []ExtKeyUsage{ExtKeyUsageClientAuth},
+	}, root, key.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Certificate{leaf, root}
+
+	full := FormatChain(chain)
+	if !strings.Contains(full, "CN=Jane Doe") || !strings.Contains(full, "ECDSA-P-256") {
+		t.Errorf("FormatChain returned %q", full)
+	}
+	got := (RedactSubject | RedactPublicKey).FormatChain(chain)
+	want := "[redacted] (expires 2030-01-01T00:00:00Z) -> CN=Root (expires " + root.NotAfter.UTC().Format(time.RFC3339) + ")"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	report := leaf.VerifyReport(VerifyOptions{Roots: roots, KeyUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}}, nil)
+	report.Certificates[0].Constraints.Detail = "checked " + leaf.Subject.String()
+	report.Error = "x509: " + leaf.Subject.String() + " failed"
+	redactedReport := (RedactSubject | RedactSerialNumber).Report(report)
+	out, err := json.Marshal(redactedReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Jane", "5ec2e7"} {
+		if strings.Contains(string(out), s) {
+			t.Errorf("redacted report contains %q: %s", s, out)
+		}
+	}
+	if !strings.Contains(string(out), "CN=Root") {
+		t.Errorf("redacted report lost the issuer: %s", out)
+	}
+	if report.Certificates[0].Subject != leaf.Subject.String() || report.Certificates[0].SerialNumber != "5ec2e7" {
+		t.Error("Report modified its argument")
+	}
+}
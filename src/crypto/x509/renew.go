@@ -0,0 +1,129 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// ErrKeyReuse is returned by RenewCertificate if RenewalOptions.RequireNewKey
+// is set and the renewed certificate would use the same public key as the old
+// one.
+var ErrKeyReuse = errors.New("x509: renewal reuses the public key of the old certificate")
+
+// RenewalOptions contains parameters for RenewCertificate.
+type RenewalOptions struct {
+	// NotBefore and NotAfter are the validity period of the renewed
+	// certificate. If NotBefore is zero, the current time is used. If
+	// NotAfter is zero, the lifetime of the old certificate is preserved.
+	NotBefore, NotAfter time.Time
+
+	// SerialNumber is the serial number of the renewed certificate. If nil,
+	// a random 128-bit serial number is generated.
+	SerialNumber *big.Int
+
+	// RequireNewKey causes RenewCertificate to fail with ErrKeyReuse if the
+	// new public key is the same as the old one.
+	RequireNewKey bool
+}
+
+// RenewCertificate returns a template, suitable for CreateCertificate, that
+// renews old for the public key newPub. If newPub is nil, the public key of old
+// is reused.
+//
+// The subject and extensions are copied as by TemplateFromCertificate. The
+// validity period and serial number are reset according to opts. If the
+// public key changes, the subject key identifier is recomputed from newPub;
+// otherwise the one from old is kept. The second return value reports whether
+// the public key is unchanged.
+//
+// The PublicKey field of the returned template is set to the new public key,
+// which should also be passed as pub to CreateCertificate.
+func RenewCertificate(old *Certificate, newPub crypto.PublicKey, opts RenewalOptions) (template *Certificate, sameKey bool, err error) {
+	if newPub == nil {
+		newPub = old.PublicKey
+	}
+
+	newKeyBytes, _, err := marshalPublicKey(newPub)
+	if err != nil {
+		return nil, false, err
+	}
+	sameKey = PublicKeysEqual(old.PublicKey, newPub)
+	if sameKey && opts.RequireNewKey {
+		return nil, true, ErrKeyReuse
+	}
+
+	t := TemplateFromCertificate(old)
+	t.PublicKey = newPub
+
+	if !sameKey {
+		// Method 1 in RFC 5280, Section 4.2.1.2, as used by
+		// CreateCertificate for CA certificates.
+		h := sha1.Sum(newKeyBytes)
+		t.SubjectKeyId = h[:]
+
+		extensions := t.ExtraExtensions[:0]
+		for _, e := range t.ExtraExtensions {
+			if !e.Id.Equal(oidExtensionSubjectKeyId) {
+				extensions = append(extensions, e)
+			}
+		}
+		t.ExtraExtensions = extensions
+	}
+
+	lifetime := old.NotAfter.Sub(old.NotBefore)
+	t.NotBefore = opts.NotBefore
+	if t.NotBefore.IsZero() {
+		t.NotBefore = time.Now()
+	}
+	t.NotAfter = opts.NotAfter
+	if t.NotAfter.IsZero() {
+		t.NotAfter = t.NotBefore.Add(lifetime)
+	}
+	if t.NotAfter.Before(t.NotBefore) {
+		return nil, sameKey, errors.New("x509: renewed certificate would expire before it becomes valid")
+	}
+
+	t.SerialNumber = opts.SerialNumber
+	if t.SerialNumber == nil {
+		t.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, sameKey, err
+		}
+	}
+
+	return t, sameKey, nil
+}
+
+// ARICertID returns the unique identifier of c used by the ACME Renewal
+// Information extension: the base64url encoded key identifier of the
+// authority key identifier extension, a period, and the base64url encoded
+// DER content octets of the serial number, both without padding. It returns
+// an error if c has no authority key identifier.
+func (c *Certificate) ARICertID() (string, error) {
+	if len(c.AuthorityKeyId) == 0 {
+		return "", errors.New("x509: certificate has no authority key identifier")
+	}
+	if c.SerialNumber == nil {
+		return "", errors.New("x509: certificate has no serial number")
+	}
+	var serial asn1.RawValue
+	der, err := asn1.Marshal(c.SerialNumber)
+	if err != nil {
+		return "", err
+	}
+	if _, err := asn1.Unmarshal(der, &serial); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(c.AuthorityKeyId) + "." +
+		base64.RawURLEncoding.EncodeToString(serial.Bytes), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenewCertificate(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same-key renewal.
+	notBefore := time.Now().Truncate(time.Second)
+	template, sameKey, err := RenewCertificate(old, nil, RenewalOptions{NotBefore: notBefore})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameKey {
+		t.Error("renewal with the old key was not detected")
+	}
+	if !bytes.Equal(template.SubjectKeyId, old.SubjectKeyId) {
+		t.Error("same-key renewal changed the subject key identifier")
+	}
+	if !template.NotBefore.Equal(notBefore) || !template.NotAfter.Equal(notBefore.Add(old.NotAfter.Sub(old.NotBefore))) {
+		t.Errorf("unexpected validity %v - %v", template.NotBefore, template.NotAfter)
+	}
+	if template.SerialNumber == nil || template.SerialNumber.Cmp(old.SerialNumber) == 0 {
+		t.Error("renewal did not pick a fresh serial number")
+	}
+	if _, _, err := RenewCertificate(old, nil, RenewalOptions{RequireNewKey: true}); err != ErrKeyReuse {
+		t.Errorf("got %v, want ErrKeyReuse", err)
+	}
+
+	// Renewal with a new key.
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, sameKey, err = RenewCertificate(old, newKey.Public(), RenewalOptions{
+		SerialNumber:  big.NewInt(42),
+		RequireNewKey: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sameKey {
+		t.Error("renewal with a new key was reported as same-key")
+	}
+	derBytes, err := CreateCertificate(rand.Reader, template, root, template.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(renewed.RawSubject, old.RawSubject) {
+		t.Error("renewal changed the subject")
+	}
+	if renewed.SerialNumber.Int64() != 42 {
+		t.Errorf("got serial number %v, want 42", renewed.SerialNumber)
+	}
+	if bytes.Equal(renewed.SubjectKeyId, old.SubjectKeyId) {
+		t.Error("subject key identifier was not recomputed for the new key")
+	}
+	if !renewed.PublicKey.(*ecdsa.PublicKey).Equal(newKey.Public()) {
+		t.Error("renewed certificate does not contain the new key")
+	}
+	if err := renewed.CheckSignatureFrom(root); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestARICertID(t *testing.T) {
+	// The example from the ACME Renewal Information draft.
+	aki := []byte{0x69, 0x88, 0x5B, 0x6B, 0x87, 0x46, 0x40, 0x41, 0xE1, 0xB3, 0x7B, 0x84, 0x7B, 0xA0, 0xAE, 0x2C, 0xDE, 0x01, 0xC8, 0xD4}
+	cert := &Certificate{AuthorityKeyId: aki, SerialNumber: big.NewInt(0x87654321)}
+	id, err := cert.ARICertID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE"; id != want {
+		t.Errorf("got %q, want %q", id, want)
+	}
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := leaf.ARICertID(); err != nil || !strings.HasPrefix(id, base64.RawURLEncoding.EncodeToString(root.SubjectKeyId)+".") {
+		t.Errorf("got %q, %v", id, err)
+	}
+	if _, err := (&Certificate{SerialNumber: big.NewInt(1)}).ARICertID(); err == nil {
+		t.Error("certificate without an authority key identifier was accepted")
+	}
+}
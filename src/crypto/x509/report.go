@@ -0,0 +1,265 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Indication is the overall result of a certificate validation, following
+// the X.509 certificate validation building block of ETSI EN 319 102-1.
+type Indication string
+
+const (
+	IndicationPassed        Indication = "PASSED"
+	IndicationIndeterminate Indication = "INDETERMINATE"
+)
+
+// SubIndication qualifies IndicationIndeterminate. The values are those of
+// ETSI EN 319 102-1, Section 5.1.3.
+type SubIndication string
+
+const (
+	// SubIndicationNoCertificateChainFound means no chain to a trust
+	// anchor could be built.
+	SubIndicationNoCertificateChainFound SubIndication = "NO_CERTIFICATE_CHAIN_FOUND"
+	// SubIndicationOutOfBoundsNoPOE means a certificate of the chain is
+	// expired or not yet valid at the validation time.
+	SubIndicationOutOfBoundsNoPOE SubIndication = "OUT_OF_BOUNDS_NO_POE"
+	// SubIndicationRevokedNoPOE means the leaf is revoked.
+	SubIndicationRevokedNoPOE SubIndication = "REVOKED_NO_POE"
+	// SubIndicationRevokedCANoPOE means a CA certificate of the chain is
+	// revoked.
+	SubIndicationRevokedCANoPOE SubIndication = "REVOKED_CA_NO_POE"
+	// SubIndicationChainConstraintsFailure means the chain violates a
+	// constraint of a certificate or of the VerifyOptions.
+	SubIndicationChainConstraintsFailure SubIndication = "CHAIN_CONSTRAINTS_FAILURE"
+	// SubIndicationCryptoConstraintsFailure means a certificate uses an
+	// algorithm or key that the VerifyOptions do not accept.
+	SubIndicationCryptoConstraintsFailure SubIndication = "CRYPTO_CONSTRAINTS_FAILURE"
+	// SubIndicationTryLater means CRLs were supplied but none could be used
+	// to check the revocation status of a certificate of the chain.
+	SubIndicationTryLater SubIndication = "TRY_LATER"
+)
+
+// CheckStatus is the outcome of one check of a certificate in a
+// ValidationReport.
+type CheckStatus string
+
+const (
+	CheckPassed     CheckStatus = "PASSED"
+	CheckFailed     CheckStatus = "FAILED"
+	CheckNotChecked CheckStatus = "NOT_CHECKED"
+)
+
+// CheckResult is the outcome of one check of a certificate, with a human
+// readable explanation.
+type CheckResult struct {
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// CertificateReport identifies a certificate of a ValidationReport and holds
+// the outcome of each check of it.
+type CertificateReport struct {
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SerialNumber string `json:"serialNumber"`
+	// SHA256 is the hex encoded SHA-256 hash of the DER certificate.
+	SHA256 string `json:"sha256"`
+
+	// Signature is the check of the signature by the next certificate of
+	// the chain, and of the signature algorithm.
+	Signature CheckResult `json:"signature"`
+	// Validity is the check of the validity period.
+	Validity CheckResult `json:"validity"`
+	// Revocation is the check of the CRLs passed to VerifyReport.
+	Revocation CheckResult `json:"revocation"`
+	// Constraints are the checks of basic constraints, path length, key
+	// usages and name constraints.
+	Constraints CheckResult `json:"constraints"`
+	// Policy is the check of certificate policies and of the other
+	// restrictions of the VerifyOptions, such as pinned keys and
+	// Certificate Transparency requirements.
+	Policy CheckResult `json:"policy"`
+}
+
+// ValidationReport is the result of VerifyReport. It mirrors the concepts of
+// the validation reports of ETSI EN 319 102-1 and ETSI TS 119 102-2, and can
+// be marshaled to JSON for records and compliance audits.
+type ValidationReport struct {
+	Indication     Indication    `json:"indication"`
+	SubIndication  SubIndication `json:"subIndication,omitempty"`
+	ValidationTime time.Time     `json:"validationTime"`
+
+	// Certificates is the chain that was validated, starting with the
+	// leaf. If no chain was found, it holds only the certificate the error
+	// is about, or the leaf.
+	Certificates []CertificateReport `json:"certificates"`
+
+	// Err is the error that caused the validation not to pass, if any.
+	Err error `json:"-"`
+	// Error is the text of Err.
+	Error string `json:"error,omitempty"`
+}
+
+// VerifyReport verifies c as Verify does, and reports the outcome of each
+// check of each certificate of the resulting chain.
+//
+// If crls is not nil, the revocation status of each certificate of the
+// chain, except the trust anchor, is checked against the first CRL in crls
+// that VerifyCRL accepts for it at the validation time. A certificate that no
+// CRL covers makes the validation INDETERMINATE with SubIndicationTryLater.
+// If crls is nil, revocation is not checked.
+//
+// When Verify returns several chains, the first one that is not revoked is
+// reported.
+func (c *Certificate) VerifyReport(opts VerifyOptions, crls []*pkix.CertificateList) *ValidationReport {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	chains, err := c.Verify(opts)
+	if err != nil {
+		return failedReport(c, now, err)
+	}
+
+	var report *ValidationReport
+	for _, chain := range chains {
+		r := chainReport(chain, now, crls)
+		if report == nil || r.Indication == IndicationPassed {
+			report = r
+		}
+		if r.Indication == IndicationPassed {
+			break
+		}
+	}
+	return report
+}
+
+func newCertificateReport(c *Certificate) CertificateReport {
+	notChecked := CheckResult{Status: CheckNotChecked}
+	return CertificateReport{
+		Subject:      c.Subject.String(),
+		Issuer:       c.Issuer.String(),
+		SerialNumber: fmt.Sprintf("%x", c.SerialNumber),
+		SHA256:       fmt.Sprintf("%x", sha256.Sum256(c.Raw)),
+		Signature:    notChecked,
+		Validity:     notChecked,
+		Revocation:   notChecked,
+		Constraints:  notChecked,
+		Policy:       notChecked,
+	}
+}
+
+// chainReport returns the report of a chain returned by Verify, after checking
+// it against crls.
+func chainReport(chain []*Certificate, now time.Time, crls []*pkix.CertificateList) *ValidationReport {
+	report := &ValidationReport{Indication: IndicationPassed, ValidationTime: now}
+	passed := CheckResult{Status: CheckPassed}
+	for i, c := range chain {
+		r := newCertificateReport(c)
+		r.Validity = CheckResult{CheckPassed, fmt.Sprintf("valid from %s to %s", c.NotBefore.UTC().Format(time.RFC3339), c.NotAfter.UTC().Format(time.RFC3339))}
+		r.Constraints = passed
+		r.Policy = passed
+		if i == len(chain)-1 {
+			r.Signature.Detail = "trust anchor"
+			r.Revocation.Detail = "trust anchor"
+			report.Certificates = append(report.Certificates, r)
+			break
+		}
+		issuer := chain[i+1]
+		r.Signature = CheckResult{CheckPassed, fmt.Sprintf("%v signature by %s", c.SignatureAlgorithm, issuer.Subject)}
+		if crls != nil {
+			r.Revocation = checkRevocation(c, issuer, now, crls)
+		}
+		if report.Err == nil {
+			switch {
+			case r.Revocation.Status == CheckFailed && i == 0:
+				report.SubIndication = SubIndicationRevokedNoPOE
+				report.Err = errors.New("x509: certificate is revoked")
+			case r.Revocation.Status == CheckFailed:
+				report.SubIndication = SubIndicationRevokedCANoPOE
+				report.Err = errors.New("x509: a CA certificate of the chain is revoked")
+			case crls != nil && r.Revocation.Status == CheckNotChecked:
+				report.SubIndication = SubIndicationTryLater
+				report.Err = errors.New("x509: no CRL covers a certificate of the chain")
+			}
+		}
+		report.Certificates = append(report.Certificates, r)
+	}
+	if report.Err != nil {
+		report.Indication = IndicationIndeterminate
+		report.Error = report.Err.Error()
+	}
+	return report
+}
+
+// checkRevocation looks up c in the first CRL of crls that VerifyCRL accepts
+// for it.
+func checkRevocation(c, issuer *Certificate, now time.Time, crls []*pkix.CertificateList) CheckResult {
+	for _, crl := range crls {
+		if _, err := VerifyCRL(crl, issuer, c, CRLVerifyOptions{CurrentTime: now}); err != nil {
+			continue
+		}
+		status, err := CRLRevocationStatus(c.SerialNumber, []*pkix.CertificateList{crl}, now)
+		if err != nil {
+			return CheckResult{CheckFailed, err.Error()}
+		}
+		switch status.State {
+		case RevocationRevoked:
+			return CheckResult{CheckFailed, "revoked at " + status.RevocationTime.UTC().Format(time.RFC3339)}
+		case RevocationOnHold:
+			return CheckResult{CheckFailed, "on hold since " + status.RevocationTime.UTC().Format(time.RFC3339)}
+		}
+		return CheckResult{CheckPassed, "not listed in the CRL issued at " + crl.TBSCertList.ThisUpdate.UTC().Format(time.RFC3339)}
+	}
+	return CheckResult{CheckNotChecked, "no applicable CRL"}
+}
+
+// failedReport returns the report for an error returned by Verify.
+func failedReport(leaf *Certificate, now time.Time, err error) *ValidationReport {
+	report := &ValidationReport{
+		Indication:     IndicationIndeterminate,
+		SubIndication:  SubIndicationNoCertificateChainFound,
+		ValidationTime: now,
+		Err:            err,
+		Error:          err.Error(),
+	}
+	r := newCertificateReport(leaf)
+	failed := CheckResult{CheckFailed, err.Error()}
+
+	switch err := err.(type) {
+	case CertificateInvalidError:
+		if err.Cert != nil {
+			r = newCertificateReport(err.Cert)
+		}
+		report.SubIndication = SubIndicationChainConstraintsFailure
+		switch err.Reason {
+		case Expired:
+			report.SubIndication = SubIndicationOutOfBoundsNoPOE
+			r.Validity = failed
+		case InsecureAlgorithm, NonCanonicalSignature:
+			report.SubIndication = SubIndicationCryptoConstraintsFailure
+			r.Signature = failed
+		case NoPinnedKey, BlockedKey, NoValidPolicy, MissingSCTs:
+			r.Policy = failed
+		default:
+			r.Constraints = failed
+		}
+	case HostnameError:
+		report.SubIndication = SubIndicationChainConstraintsFailure
+		r.Constraints = failed
+	case UnknownAuthorityError:
+		r.Signature = failed
+	}
+	report.Certificates = []CertificateReport{r}
+	return report
+}
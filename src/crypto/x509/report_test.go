@@ -0,0 +1,188 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyReport(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root"},
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	newCRL := func(revoked ...*big.Int) *pkix.CertificateList {
+		list := &RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: now.Add(-time.Minute),
+			NextUpdate: now.Add(time.Hour),
+		}
+		for _, serial := range revoked {
+			list.RevokedCertificates = append(list.RevokedCertificates, pkix.RevokedCertificate{
+				SerialNumber:   serial,
+				RevocationTime: now.Add(-time.Minute),
+			})
+		}
+		der, err := CreateRevocationList(rand.Reader, list, root, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := ParseDERCRL(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+
+	tests := []struct {
+		name        string
+		opts        VerifyOptions
+		crls        []*pkix.CertificateList
+		indication  Indication
+		sub         SubIndication
+		length      int
+		check       func(*CertificateReport) CheckResult
+		checkStatus CheckStatus
+	}{
+		{
+			name:        "no CRLs",
+			indication:  IndicationPassed,
+			length:      2,
+			check:       func(r *CertificateReport) CheckResult { return r.Revocation },
+			checkStatus: CheckNotChecked,
+		},
+		{
+			name:        "not revoked",
+			crls:        []*pkix.CertificateList{newCRL(big.NewInt(42))},
+			indication:  IndicationPassed,
+			length:      2,
+			check:       func(r *CertificateReport) CheckResult { return r.Revocation },
+			checkStatus: CheckPassed,
+		},
+		{
+			name:        "revoked",
+			crls:        []*pkix.CertificateList{newCRL(leaf.SerialNumber)},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationRevokedNoPOE,
+			length:      2,
+			check:       func(r *CertificateReport) CheckResult { return r.Revocation },
+			checkStatus: CheckFailed,
+		},
+		{
+			name:        "no applicable CRL",
+			crls:        []*pkix.CertificateList{},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationTryLater,
+			length:      2,
+			check:       func(r *CertificateReport) CheckResult { return r.Revocation },
+			checkStatus: CheckNotChecked,
+		},
+		{
+			name:        "expired",
+			opts:        VerifyOptions{CurrentTime: now.Add(48 * time.Hour)},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationOutOfBoundsNoPOE,
+			length:      1,
+			check:       func(r *CertificateReport) CheckResult { return r.Validity },
+			checkStatus: CheckFailed,
+		},
+		{
+			name:        "disallowed algorithm",
+			opts:        VerifyOptions{DisallowedSignatureAlgorithms: []SignatureAlgorithm{ECDSAWithSHA256}},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationCryptoConstraintsFailure,
+			length:      1,
+			check:       func(r *CertificateReport) CheckResult { return r.Signature },
+			checkStatus: CheckFailed,
+		},
+		{
+			name:        "hostname mismatch",
+			opts:        VerifyOptions{DNSName: "example.com"},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationChainConstraintsFailure,
+			length:      1,
+			check:       func(r *CertificateReport) CheckResult { return r.Constraints },
+			checkStatus: CheckFailed,
+		},
+		{
+			name:        "unknown authority",
+			opts:        VerifyOptions{Roots: NewCertPool()},
+			indication:  IndicationIndeterminate,
+			sub:         SubIndicationNoCertificateChainFound,
+			length:      1,
+			check:       func(r *CertificateReport) CheckResult { return r.Signature },
+			checkStatus: CheckFailed,
+		},
+	}
+	for _, tt := range tests {
+		if tt.opts.Roots == nil {
+			tt.opts.Roots = roots
+		}
+		report := leaf.VerifyReport(tt.opts, tt.crls)
+		if report.Indication != tt.indication || report.SubIndication != tt.sub {
+			t.Errorf("%s: got %s/%s, want %s/%s", tt.name, report.Indication, report.SubIndication, tt.indication, tt.sub)
+		}
+		if (report.Err == nil) != (tt.indication == IndicationPassed) {
+			t.Errorf("%s: unexpected error %v", tt.name, report.Err)
+		}
+		if len(report.Certificates) != tt.length {
+			t.Errorf("%s: got %d certificates, want %d", tt.name, len(report.Certificates), tt.length)
+			continue
+		}
+		if got := tt.check(&report.Certificates[0]); got.Status != tt.checkStatus {
+			t.Errorf("%s: got check status %s (%q), want %s", tt.name, got.Status, got.Detail, tt.checkStatus)
+		}
+		if tt.indication == IndicationPassed {
+			anchor := report.Certificates[len(report.Certificates)-1]
+			if anchor.Signature.Status != CheckNotChecked || anchor.Validity.Status != CheckPassed {
+				t.Errorf("%s: unexpected trust anchor checks %+v", tt.name, anchor)
+			}
+		}
+	}
+
+	out, err := json.Marshal(leaf.VerifyReport(VerifyOptions{Roots: roots}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"indication":"PASSED"`, `"signature":{"status":"PASSED"`, `"revocation":{"status":"NOT_CHECKED"}`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("JSON report %s does not contain %s", out, want)
+		}
+	}
+}
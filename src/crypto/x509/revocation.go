@@ -0,0 +1,326 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RevocationRequest is a request for a CRL or an OCSP response, made by
+// RevocationCache to a RevocationFetcher.
+type RevocationRequest struct {
+	// URL is the CRL distribution point, or the OCSP responder URL with the
+	// request encoded as for an HTTP GET, per RFC 6960, Appendix A.1.
+	URL string
+
+	// ETag and LastModified are the validators of the cached copy, if any.
+	// A fetcher using HTTP sends them as If-None-Match and
+	// If-Modified-Since.
+	ETag         string
+	LastModified string
+}
+
+// RevocationResponse is the result of a RevocationRequest.
+type RevocationResponse struct {
+	// NotModified reports that the cached copy is still current, for
+	// example because the server answered 304 Not Modified. Body is then
+	// ignored.
+	NotModified bool
+
+	// Body is the DER encoded CRL or OCSP response.
+	Body []byte
+
+	// ETag and LastModified are the validators of Body, to be sent with
+	// the next request for the same URL.
+	ETag         string
+	LastModified string
+}
+
+// A RevocationFetcher retrieves CRLs and OCSP responses. It is typically
+// implemented with a net/http.Client, which this package can't depend on.
+type RevocationFetcher interface {
+	FetchRevocation(ctx context.Context, req *RevocationRequest) (*RevocationResponse, error)
+}
+
+// RevocationCache is a cache of CRLs and OCSP responses in front of a
+// RevocationFetcher, so that a busy server does not fetch the same
+// revocation information once per connection.
+//
+// Entries are kept until the nextUpdate time of the CRL or OCSP response,
+// within the bounds of MinTTL and MaxTTL, and are then revalidated with a
+// conditional request. Concurrent requests for the same URL share a single
+// fetch.
+//
+// A RevocationCache is safe for concurrent use. Its fields must not be
+// modified after the first call to FetchCRL or FetchOCSP.
+type RevocationCache struct {
+	// Fetcher performs the requests.
+	Fetcher RevocationFetcher
+
+	// MinTTL is the minimum time an entry is kept before being
+	// revalidated, even if its nextUpdate time is earlier or missing. If
+	// zero, it is one minute.
+	MinTTL time.Duration
+	// MaxTTL is the maximum time an entry is kept before being
+	// revalidated. If zero, it is 24 hours.
+	MaxTTL time.Duration
+
+	// StaleIfError is how long after expiring an entry can still be
+	// returned if fetching a new one fails, so that an outage of the CA
+	// does not immediately cause revocation checks to fail. While a stale
+	// entry is being returned, fetching is retried at most once per MinTTL.
+	// If zero, expired entries are never returned.
+	StaleIfError time.Duration
+
+	now func() time.Time // for testing
+
+	mu       sync.Mutex
+	entries  map[string]*revocationCacheEntry
+	inflight map[string]*revocationCall
+}
+
+type revocationCacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	expires      time.Time
+	retryAfter   time.Time // after a failed revalidation
+}
+
+type revocationCall struct {
+	done  chan struct{}
+	entry *revocationCacheEntry
+	err   error
+}
+
+// FetchCRL returns the DER encoded CRL at url, from the cache if possible.
+func (c *RevocationCache) FetchCRL(ctx context.Context, url string) ([]byte, error) {
+	return c.fetch(ctx, url, func(body []byte) (time.Time, error) {
+		crl, err := ParseDERCRL(body)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return crl.TBSCertList.NextUpdate, nil
+	})
+}
+
+// FetchOCSP returns the DER encoded OCSP response for the GET request url,
+// from the cache if possible. Responses whose status is not successful are
+// returned as errors and are not cached.
+func (c *RevocationCache) FetchOCSP(ctx context.Context, url string) ([]byte, error) {
+	return c.fetch(ctx, url, ocspNextUpdate)
+}
+
+func (c *RevocationCache) fetch(ctx context.Context, url string, nextUpdate func([]byte) (time.Time, error)) ([]byte, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	c.mu.Lock()
+	cached := c.entries[url]
+	if cached != nil && (now().Before(cached.expires) || now().Before(cached.retryAfter) && c.usableIfStale(cached, now())) {
+		c.mu.Unlock()
+		return cached.body, nil
+	}
+	call := c.inflight[url]
+	if call == nil {
+		call = &revocationCall{done: make(chan struct{})}
+		if c.inflight == nil {
+			c.inflight = make(map[string]*revocationCall)
+		}
+		c.inflight[url] = call
+		go c.revalidate(url, cached, call, now, nextUpdate)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if call.err != nil {
+		if cached != nil && c.usableIfStale(cached, now()) {
+			return cached.body, nil
+		}
+		return nil, call.err
+	}
+	return call.entry.body, nil
+}
+
+// usableIfStale reports whether entry can be returned at now if it can't be
+// revalidated.
+func (c *RevocationCache) usableIfStale(entry *revocationCacheEntry, now time.Time) bool {
+	return c.StaleIfError > 0 && now.Before(entry.expires.Add(c.StaleIfError))
+}
+
+// revalidate fetches url, conditionally if cached is not nil, stores the
+// result in the cache and completes call. It runs independently of the
+// context of the caller that started it, so that the result is not lost for
+// the other callers waiting for it.
+func (c *RevocationCache) revalidate(url string, cached *revocationCacheEntry, call *revocationCall, now func() time.Time, nextUpdate func([]byte) (time.Time, error)) {
+	defer close(call.done)
+
+	req := &RevocationRequest{URL: url}
+	if cached != nil {
+		req.ETag, req.LastModified = cached.etag, cached.lastModified
+	}
+	resp, err := c.Fetcher.FetchRevocation(context.Background(), req)
+
+	var entry *revocationCacheEntry
+	switch {
+	case err != nil:
+	case resp.NotModified && cached != nil:
+		entry = &revocationCacheEntry{body: cached.body, etag: cached.etag, lastModified: cached.lastModified}
+		if resp.ETag != "" {
+			entry.etag = resp.ETag
+		}
+		if resp.LastModified != "" {
+			entry.lastModified = resp.LastModified
+		}
+	case resp.NotModified:
+		err = errors.New("x509: revocation fetcher reported an unconditional request as not modified")
+	default:
+		entry = &revocationCacheEntry{body: resp.Body, etag: resp.ETag, lastModified: resp.LastModified}
+	}
+
+	var next time.Time
+	if err == nil {
+		next, err = nextUpdate(entry.body)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, url)
+	if err != nil {
+		call.err = err
+		if cached != nil {
+			stale := *cached
+			stale.retryAfter = now().Add(c.minTTL())
+			c.entries[url] = &stale
+		}
+		return
+	}
+	entry.expires = c.expiry(now(), next)
+	if c.entries == nil {
+		c.entries = make(map[string]*revocationCacheEntry)
+	}
+	c.entries[url] = entry
+	call.entry = entry
+}
+
+func (c *RevocationCache) minTTL() time.Duration {
+	if c.MinTTL == 0 {
+		return time.Minute
+	}
+	return c.MinTTL
+}
+
+// expiry returns the time until which an entry fetched at now and with the
+// given nextUpdate time is kept.
+func (c *RevocationCache) expiry(now, nextUpdate time.Time) time.Time {
+	minTTL, maxTTL := c.minTTL(), c.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = 24 * time.Hour
+	}
+	switch {
+	case nextUpdate.Before(now.Add(minTTL)):
+		return now.Add(minTTL)
+	case nextUpdate.After(now.Add(maxTTL)):
+		return now.Add(maxTTL)
+	}
+	return nextUpdate
+}
+
+var errMalformedOCSPResponse = errors.New("x509: malformed OCSP response")
+
+// ocspNextUpdate returns the earliest nextUpdate time of the single responses
+// in the OCSP response der, or the zero time if none has one. The response is
+// not verified.
+func ocspNextUpdate(der []byte) (time.Time, error) {
+	// RFC 6960, 4.2.1
+	//
+	// OCSPResponse ::= SEQUENCE {
+	//    responseStatus         OCSPResponseStatus,
+	//    responseBytes          [0] EXPLICIT ResponseBytes OPTIONAL }
+	//
+	// ResponseBytes ::=       SEQUENCE {
+	//    responseType   OBJECT IDENTIFIER,
+	//    response       OCTET STRING }
+	input := cryptobyte.String(der)
+	var resp, responseBytes, response cryptobyte.String
+	var status int
+	if !input.ReadASN1(&resp, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!resp.ReadASN1Enum(&status) {
+		return time.Time{}, errMalformedOCSPResponse
+	}
+	if status != 0 {
+		return time.Time{}, errors.New("x509: OCSP response status is not successful")
+	}
+	if !resp.ReadASN1(&responseBytes, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!responseBytes.ReadASN1(&responseBytes, cryptobyte_asn1.SEQUENCE) ||
+		!responseBytes.SkipASN1(cryptobyte_asn1.OBJECT_IDENTIFIER) ||
+		!responseBytes.ReadASN1(&response, cryptobyte_asn1.OCTET_STRING) {
+		return time.Time{}, errMalformedOCSPResponse
+	}
+
+	// BasicOCSPResponse       ::= SEQUENCE {
+	//    tbsResponseData      ResponseData,
+	//    ... }
+	//
+	// ResponseData ::= SEQUENCE {
+	//    version              [0] EXPLICIT Version DEFAULT v1,
+	//    responderID              ResponderID,
+	//    producedAt               GeneralizedTime,
+	//    responses                SEQUENCE OF SingleResponse,
+	//    responseExtensions   [1] EXPLICIT Extensions OPTIONAL }
+	var basic, tbs, responderID, responses cryptobyte.String
+	var responderTag cryptobyte_asn1.Tag
+	if !response.ReadASN1(&basic, cryptobyte_asn1.SEQUENCE) ||
+		!basic.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) ||
+		!tbs.ReadAnyASN1(&responderID, &responderTag) ||
+		!tbs.SkipASN1(cryptobyte_asn1.GeneralizedTime) ||
+		!tbs.ReadASN1(&responses, cryptobyte_asn1.SEQUENCE) {
+		return time.Time{}, errMalformedOCSPResponse
+	}
+
+	// SingleResponse ::= SEQUENCE {
+	//    certID                       CertID,
+	//    certStatus                   CertStatus,
+	//    thisUpdate                   GeneralizedTime,
+	//    nextUpdate         [0]       EXPLICIT GeneralizedTime OPTIONAL,
+	//    singleExtensions   [1]       EXPLICIT Extensions OPTIONAL }
+	var earliest time.Time
+	for !responses.Empty() {
+		var single, status, nextUpdate cryptobyte.String
+		var hasNextUpdate bool
+		var statusTag cryptobyte_asn1.Tag
+		if !responses.ReadASN1(&single, cryptobyte_asn1.SEQUENCE) ||
+			!single.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+			!single.ReadAnyASN1(&status, &statusTag) ||
+			!single.SkipASN1(cryptobyte_asn1.GeneralizedTime) ||
+			!single.ReadOptionalASN1(&nextUpdate, &hasNextUpdate, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+			return time.Time{}, errMalformedOCSPResponse
+		}
+		if !hasNextUpdate {
+			continue
+		}
+		var t time.Time
+		if !nextUpdate.ReadASN1GeneralizedTime(&t) {
+			return time.Time{}, errMalformedOCSPResponse
+		}
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest, nil
+}
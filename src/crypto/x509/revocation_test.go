@@ -0,0 +1,218 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+type fakeRevocationFetcher struct {
+	mu       sync.Mutex
+	body     []byte
+	etag     string
+	err      error
+	requests []RevocationRequest
+	block    chan struct{}
+}
+
+func (f *fakeRevocationFetcher) FetchRevocation(ctx context.Context, req *RevocationRequest) (*RevocationResponse, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, *req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if req.ETag != "" && req.ETag == f.etag {
+		return &RevocationResponse{NotModified: true}, nil
+	}
+	return &RevocationResponse{Body: f.body, ETag: f.etag}, nil
+}
+
+func TestRevocationCache(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CA"},
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		KeyUsage:              KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Unix(100000, 0),
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := CreateRevocationList(rand.Reader, &RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Unix(2000, 0),
+		NextUpdate: time.Unix(2000, 0).Add(time.Hour),
+	}, ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(2000, 0)
+	fetcher := &fakeRevocationFetcher{body: crl, etag: `"v1"`}
+	cache := &RevocationCache{
+		Fetcher:      fetcher,
+		StaleIfError: time.Hour,
+		now:          func() time.Time { return now },
+	}
+	fetch := func(step string) []byte {
+		body, err := cache.FetchCRL(context.Background(), "http://crl.example/ca.crl")
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		return body
+	}
+
+	if !bytes.Equal(fetch("first fetch"), crl) {
+		t.Fatal("unexpected CRL")
+	}
+	now = now.Add(30 * time.Minute)
+	fetch("cached fetch")
+	if len(fetcher.requests) != 1 {
+		t.Fatalf("got %d requests before nextUpdate, want 1", len(fetcher.requests))
+	}
+
+	now = now.Add(time.Hour)
+	fetch("revalidation")
+	if len(fetcher.requests) != 2 || fetcher.requests[1].ETag != `"v1"` {
+		t.Fatalf("expected a conditional request, got %+v", fetcher.requests)
+	}
+	// The CRL is past its nextUpdate, so it is now kept for MinTTL only.
+	now = now.Add(2 * time.Minute)
+	fetcher.err = errors.New("CA is down")
+	if !bytes.Equal(fetch("stale fetch"), crl) {
+		t.Fatal("unexpected stale CRL")
+	}
+	fetch("stale fetch before retry")
+	if len(fetcher.requests) != 3 {
+		t.Fatalf("got %d requests during the outage, want 3", len(fetcher.requests))
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := cache.FetchCRL(context.Background(), "http://crl.example/ca.crl"); err == nil {
+		t.Fatal("entry returned after the StaleIfError period")
+	}
+	if _, err := cache.FetchCRL(context.Background(), "http://crl.example/other.crl"); err == nil {
+		t.Fatal("error not returned for an uncached URL")
+	}
+}
+
+func TestRevocationCacheSingleFetch(t *testing.T) {
+	fetcher := &fakeRevocationFetcher{body: testOCSPResponse(t, time.Now().Add(time.Hour)), block: make(chan struct{})}
+	cache := &RevocationCache{Fetcher: fetcher}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.FetchOCSP(context.Background(), "http://ocsp.example/MEMwQTA"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Let the goroutines queue up behind the first fetch.
+	time.Sleep(10 * time.Millisecond)
+	close(fetcher.block)
+	wg.Wait()
+
+	if len(fetcher.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(fetcher.requests))
+	}
+}
+
+func TestOCSPNextUpdate(t *testing.T) {
+	want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := ocspNextUpdate(testOCSPResponse(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("got nextUpdate %v, want %v", got, want)
+	}
+
+	if _, err := ocspNextUpdate([]byte{0x30, 0x03, 0x0a, 0x01, 0x03}); err == nil {
+		t.Error("tryLater response accepted")
+	}
+}
+
+// testOCSPResponse returns an unsigned OCSP response with a single good
+// response valid until nextUpdate.
+func testOCSPResponse(t *testing.T, nextUpdate time.Time) []byte {
+	var basic cryptobyte.Builder
+	basic.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // tbsResponseData
+			b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				b.AddASN1OctetString(make([]byte, 20))
+			})
+			b.AddASN1GeneralizedTime(nextUpdate.Add(-time.Hour))
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // responses
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // certID
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSHA256)
+						})
+						b.AddASN1OctetString(make([]byte, 32))
+						b.AddASN1OctetString(make([]byte, 32))
+						b.AddASN1BigInt(big.NewInt(1))
+					})
+					b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific(), func(b *cryptobyte.Builder) {}) // good
+					b.AddASN1GeneralizedTime(nextUpdate.Add(-time.Hour))
+					b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+						b.AddASN1GeneralizedTime(nextUpdate)
+					})
+				})
+			})
+		})
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(oidSignatureECDSAWithSHA256)
+		})
+		b.AddASN1BitString([]byte{0})
+	})
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Enum(0)
+		b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 7, 48, 1, 1})
+				b.AddASN1OctetString(basic.BytesOrPanic())
+			})
+		})
+	})
+	der, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
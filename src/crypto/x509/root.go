@@ -4,7 +4,10 @@
 
 package x509
 
-import "sync"
+import (
+	"errors"
+	"sync"
+)
 
 var (
 	once           sync.Once
@@ -12,14 +15,97 @@ var (
 	systemRootsErr error
 )
 
+// errNoSystemRoots is used when the system root store could be read but
+// holds no certificates, which would otherwise make every verification fail
+// as if the certificates were untrusted.
+var errNoSystemRoots = errors.New("x509: no system root certificates found")
+
+// checkSystemRootStore is set on platforms where Verify hands chain building
+// to the platform verifier instead of loading the roots. It reports whether
+// the platform root store can be opened.
+var checkSystemRootStore func() error
+
+// systemStoreOf is set on platforms where Verify hands chain building to the
+// platform verifier. It returns the name of the system store that holds
+// cert, or "" if none does, as for certificates that the platform fetched.
+var systemStoreOf func(cert *Certificate) string
+
+// missingRootLocation is set on platforms where the location of the system
+// roots can be configured in the environment. It returns the error for a
+// configured location that doesn't exist, if any, to explain why no roots
+// were found.
+var missingRootLocation func() error
+
+// embeddedRootsPEM and embeddedRootsVersion are set by root_embed.go, which
+// is only built with the x509embedroots build tag.
+var embeddedRootsPEM, embeddedRootsVersion string
+
+// EmbeddedRootsVersion returns the version, the date in YYYY-MM-DD form, of
+// the snapshot of the Mozilla root store compiled into the program with the
+// x509embedroots build tag, or "" if the program was built without it.
+//
+// With that tag, the snapshot is used as the system roots when none can be
+// loaded from the system, for example in a container built from scratch. It
+// is not used on Windows, where Verify relies on the platform verifier.
+func EmbeddedRootsVersion() string {
+	return embeddedRootsVersion
+}
+
 func systemRootsPool() *CertPool {
 	once.Do(initSystemRoots)
 	return systemRoots
 }
 
 func initSystemRoots() {
+	if checkSystemRootStore != nil {
+		systemRootsErr = checkSystemRootStore()
+		return
+	}
 	systemRoots, systemRootsErr = loadSystemRoots()
+	if systemRootsErr == nil && (systemRoots == nil || len(systemRoots.certs) == 0) {
+		systemRootsErr = errNoSystemRoots
+		if missingRootLocation != nil {
+			if err := missingRootLocation(); err != nil {
+				systemRootsErr = err
+			}
+		}
+	}
+	if systemRootsErr != nil && embeddedRootsPEM != "" {
+		systemRoots, systemRootsErr = loadEmbeddedRoots(), nil
+	}
 	if systemRootsErr != nil {
 		systemRoots = nil
+		return
+	}
+	systemRoots.markSystem()
+}
+
+// loadEmbeddedRoots returns a pool of the roots compiled in with the
+// x509embedroots build tag.
+func loadEmbeddedRoots() *CertPool {
+	roots := NewCertPool()
+	roots.appendCertsFromPEM([]byte(embeddedRootsPEM), certOrigin{store: "embedded"})
+	return roots
+}
+
+// SystemRootsAvailable reports whether Verify can use the system roots when
+// VerifyOptions.Roots is nil. It is meant for health checks at startup, so
+// that a missing or empty root store is detected before the first handshake
+// fails. If the roots are not available, the error is a SystemRootsError
+// describing why; Verify returns the same error in that case.
+//
+// On Windows, where Verify uses the platform verifier, the roots are
+// available if the system root store can be opened, since Windows may fetch
+// root certificates on demand. On other platforms, the roots are available
+// if they were loaded without error and at least one was found, or if roots
+// were compiled in with the x509embedroots build tag.
+//
+// The result is computed once and does not reflect later changes to the
+// system configuration.
+func SystemRootsAvailable() (bool, error) {
+	systemRootsPool()
+	if systemRootsErr != nil {
+		return false, SystemRootsError{systemRootsErr}
 	}
+	return true, nil
 }
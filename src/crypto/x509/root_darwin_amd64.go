@@ -23,7 +23,10 @@ func (c *Certificate) systemVerify(opts *VerifyOptions) (chains [][]*Certificate
 var loadSystemRootsWithCgo func() (*CertPool, error)
 
 func loadSystemRoots() (*CertPool, error) {
-	var trustedRoots []*Certificate
+	var (
+		trustedRoots  []*Certificate
+		trustedStores []string
+	)
 	untrustedRoots := make(map[string]bool)
 
 	// macOS has three trust domains: one for CAs added by users to their
@@ -38,7 +41,7 @@ func loadSystemRoots() (*CertPool, error) {
 		if err == macOS.ErrNoTrustSettings {
 			continue
 		} else if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("x509: reading the %s trust settings: %w", domainNames[domain], err)
 		}
 		defer macOS.CFRelease(certs)
 
@@ -79,10 +82,12 @@ func loadSystemRoots() (*CertPool, error) {
 			case macOS.SecTrustSettingsResultTrustRoot:
 				if isRootCertificate(cert) {
 					trustedRoots = append(trustedRoots, cert)
+					trustedStores = append(trustedStores, domainNames[domain])
 				}
 			case macOS.SecTrustSettingsResultTrustAsRoot:
 				if !isRootCertificate(cert) {
 					trustedRoots = append(trustedRoots, cert)
+					trustedStores = append(trustedStores, domainNames[domain])
 				}
 
 			case macOS.SecTrustSettingsResultDeny:
@@ -106,14 +111,22 @@ func loadSystemRoots() (*CertPool, error) {
 	}
 
 	pool := NewCertPool()
-	for _, cert := range trustedRoots {
+	for i, cert := range trustedRoots {
 		if !untrustedRoots[string(cert.Raw)] {
-			pool.AddCert(cert)
+			pool.addCert(cert, certOrigin{store: trustedStores[i]})
 		}
 	}
 	return pool, nil
 }
 
+// domainNames are the names of the trust settings domains, as reported by
+// AnchorInfo.Store and VerifiedChain.Stores.
+var domainNames = map[macOS.SecTrustSettingsDomain]string{
+	macOS.SecTrustSettingsDomainUser:   "user",
+	macOS.SecTrustSettingsDomainAdmin:  "admin",
+	macOS.SecTrustSettingsDomainSystem: "system",
+}
+
 // exportCertificate returns a *Certificate for a SecCertificateRef.
 func exportCertificate(cert macOS.CFRef) (*Certificate, error) {
 	data, err := macOS.SecItemExport(cert)
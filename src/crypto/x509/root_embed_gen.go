@@ -0,0 +1,128 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// Generates root_embed.go.
+//
+// The roots are those of the Mozilla root store that are trusted for TLS
+// server authentication, as converted to PEM by the curl project from
+// Mozilla's certdata.txt. Use -input to read a local copy of such a bundle
+// instead of downloading it, and -version to set the snapshot version if the
+// bundle lacks curl's header.
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var (
+	output  = flag.String("output", "root_embed.go", "file name to write")
+	input   = flag.String("input", "", "PEM bundle to read instead of downloading it")
+	version = flag.String("version", "", "snapshot version, by default the date in the bundle header")
+)
+
+const bundleURL = "https://curl.se/ca/cacert.pem"
+
+func main() {
+	flag.Parse()
+
+	data, err := readBundle()
+	if err != nil {
+		log.Fatal(err)
+	}
+	v := *version
+	if v == "" {
+		if v, err = bundleVersion(data); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s", header)
+	fmt.Fprintf(buf, "func init() {\n\tembeddedRootsVersion = %q\n\tembeddedRootsPEM = rootsPEM\n}\n\n", v)
+
+	fmt.Fprintf(buf, "const rootsPEM = `\n")
+	n := 0
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			log.Printf("skipping unparsable certificate: %v", err)
+			continue
+		}
+		if err := pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: block.Bytes}); err != nil {
+			log.Fatal(err)
+		}
+		n++
+	}
+	fmt.Fprintf(buf, "`\n")
+	if n == 0 {
+		log.Fatal("no certificates found")
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal("source format error:", err)
+	}
+	if err := ioutil.WriteFile(*output, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readBundle() ([]byte, error) {
+	if *input != "" {
+		return ioutil.ReadFile(*input)
+	}
+	resp, err := http.Get(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", bundleURL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// bundleVersion returns the date of the Mozilla data in the header of a curl
+// bundle, such as "## Certificate data from Mozilla as of: Tue Jul  2
+// 03:12:04 2024 GMT".
+func bundleVersion(data []byte) (string, error) {
+	m := regexp.MustCompile(`Certificate data from Mozilla as of: (.*)`).FindSubmatch(data)
+	if m == nil {
+		return "", fmt.Errorf("no version in bundle header, use -version")
+	}
+	t, err := time.Parse("Mon Jan _2 15:04:05 2006 MST", string(bytes.TrimSpace(m[1])))
+	if err != nil {
+		return "", fmt.Errorf("parsing bundle date: %v", err)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+const header = `// Code generated by root_embed_gen.go; DO NOT EDIT.
+
+//go:generate go run root_embed_gen.go -output root_embed.go
+
+// +build x509embedroots
+
+package x509
+
+`
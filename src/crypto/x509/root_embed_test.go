@@ -0,0 +1,32 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build x509embedroots
+
+package x509
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmbeddedRoots(t *testing.T) {
+	version := EmbeddedRootsVersion()
+	if _, err := time.Parse("2006-01-02", version); err != nil {
+		t.Errorf("EmbeddedRootsVersion() = %q, want a date", version)
+	}
+
+	roots := loadEmbeddedRoots()
+	if n := len(roots.certs); n < 100 {
+		t.Errorf("got %d embedded roots, want at least 100", n)
+	}
+	for _, info := range roots.Audit() {
+		if info.Store != "embedded" {
+			t.Fatalf("embedded root %v has store %q", info.Certificate.Subject, info.Store)
+		}
+		if !info.Certificate.IsCACert() {
+			t.Errorf("embedded root %v is not a CA", info.Certificate.Subject)
+		}
+	}
+}
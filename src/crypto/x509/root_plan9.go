@@ -26,7 +26,7 @@ func loadSystemRoots() (*CertPool, error) {
 	for _, file := range certFiles {
 		data, err := ioutil.ReadFile(file)
 		if err == nil {
-			roots.AppendCertsFromPEM(data)
+			roots.appendCertsFromPEM(data, certOrigin{path: file})
 			return roots, nil
 		}
 		if bestErr == nil || (os.IsNotExist(bestErr) && !os.IsNotExist(err)) {
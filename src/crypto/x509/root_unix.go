@@ -36,6 +36,10 @@ const (
 	certDirEnv = "SSL_CERT_DIR"
 )
 
+func init() {
+	missingRootLocation = missingConfiguredRootLocation
+}
+
 func (c *Certificate) systemVerify(opts *VerifyOptions) (chains [][]*Certificate, err error) {
 	return nil, nil
 }
@@ -52,7 +56,7 @@ func loadSystemRoots() (*CertPool, error) {
 	for _, file := range files {
 		data, err := ioutil.ReadFile(file)
 		if err == nil {
-			roots.AppendCertsFromPEM(data)
+			roots.appendCertsFromPEM(data, certOrigin{path: file})
 			break
 		}
 		if firstErr == nil && !os.IsNotExist(err) {
@@ -78,9 +82,10 @@ func loadSystemRoots() (*CertPool, error) {
 			continue
 		}
 		for _, fi := range fis {
-			data, err := ioutil.ReadFile(directory + "/" + fi.Name())
+			path := directory + "/" + fi.Name()
+			data, err := ioutil.ReadFile(path)
 			if err == nil {
-				roots.AppendCertsFromPEM(data)
+				roots.appendCertsFromPEM(data, certOrigin{path: path})
 			}
 		}
 	}
@@ -92,6 +97,25 @@ func loadSystemRoots() (*CertPool, error) {
 	return nil, firstErr
 }
 
+// missingConfiguredRootLocation returns the error for the first location
+// configured with SSL_CERT_FILE or SSL_CERT_DIR that doesn't exist, if any.
+// loadSystemRoots ignores missing locations, like missing default ones.
+func missingConfiguredRootLocation() error {
+	var locations []string
+	if f := os.Getenv(certFileEnv); f != "" {
+		locations = append(locations, f)
+	}
+	if d := os.Getenv(certDirEnv); d != "" {
+		locations = append(locations, strings.Split(d, ":")...)
+	}
+	for _, location := range locations {
+		if _, err := os.Stat(location); os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 // readUniqueDirectoryEntries is like ioutil.ReadDir but omits
 // symlinks that point within the directory.
 func readUniqueDirectoryEntries(dir string) ([]os.FileInfo, error) {
@@ -8,12 +8,14 @@ package x509
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -43,6 +45,15 @@ func TestEnvVars(t *testing.T) {
 			dirs:    []string{testDir},
 			cns:     nil,
 		},
+		{
+			// A missing file in the environment doesn't prevent loading the directory.
+			name:    "missing-file",
+			fileEnv: testMissing,
+			dirEnv:  testDir,
+			files:   nil,
+			dirs:    nil,
+			cns:     []string{testDirCN},
+		},
 		{
 			// File environment overrides default file locations.
 			name:    "file",
@@ -197,10 +208,85 @@ func TestLoadSystemCertsLoadColonSeparatedDirs(t *testing.T) {
 	strCertPool := func(p *CertPool) string {
 		return string(bytes.Join(p.Subjects(), []byte("\n")))
 	}
-	if !reflect.DeepEqual(gotPool, wantPool) {
+	if !reflect.DeepEqual(gotPool.certs, wantPool.certs) {
 		g, w := strCertPool(gotPool), strCertPool(wantPool)
 		t.Fatalf("Mismatched certPools\nGot:\n%s\n\nWant:\n%s", g, w)
 	}
+	for i, info := range gotPool.Audit() {
+		if want := filepath.Join(certDirs[i], "cert.crt"); info.Path != want {
+			t.Errorf("Audit()[%d].Path = %q; want %q", i, info.Path, want)
+		}
+	}
+}
+
+// withoutSystemRootStore runs f with no system root store to load roots from.
+func withoutSystemRootStore(f func()) {
+	origCertFiles, origCertDirectories := certFiles, certDirectories
+	origFile, origDir := os.Getenv(certFileEnv), os.Getenv(certDirEnv)
+	origRoots, origErr := systemRootsPool(), systemRootsErr
+	defer func() {
+		certFiles = origCertFiles
+		certDirectories = origCertDirectories
+		os.Setenv(certFileEnv, origFile)
+		os.Setenv(certDirEnv, origDir)
+		systemRoots, systemRootsErr = origRoots, origErr
+	}()
+
+	certFiles, certDirectories = []string{testMissing}, []string{testMissing}
+	os.Setenv(certFileEnv, "")
+	os.Setenv(certDirEnv, "")
+	once = sync.Once{}
+	f()
+}
+
+func TestSystemRootsAvailableEmpty(t *testing.T) {
+	defer func(embedded string) {
+		embeddedRootsPEM = embedded
+	}(embeddedRootsPEM)
+	embeddedRootsPEM = ""
+	withoutSystemRootStore(func() { testSystemRootsAvailableEmpty(t) })
+}
+
+func testSystemRootsAvailableEmpty(t *testing.T) {
+	ok, err := SystemRootsAvailable()
+	if ok {
+		t.Fatal("SystemRootsAvailable reported available roots with no root store")
+	}
+	if se, isSE := err.(SystemRootsError); !isSE || se.Err != errNoSystemRoots {
+		t.Fatalf("SystemRootsAvailable returned %v; want SystemRootsError for %v", err, errNoSystemRoots)
+	}
+
+	leaf, err := certificateFromPEM(googleLeaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = leaf.Verify(VerifyOptions{})
+	if _, ok := err.(SystemRootsError); !ok {
+		t.Errorf("Verify returned %v; want SystemRootsError", err)
+	}
+}
+
+func TestSystemRootsErrorCause(t *testing.T) {
+	defer func(embedded string) {
+		embeddedRootsPEM = embedded
+	}(embeddedRootsPEM)
+	embeddedRootsPEM = ""
+	withoutSystemRootStore(func() {
+		os.Setenv(certFileEnv, testMissing)
+		_, err := SystemRootsAvailable()
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("SystemRootsAvailable returned %v; want an error wrapping os.ErrNotExist", err)
+		}
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != testMissing {
+			t.Errorf("SystemRootsAvailable returned %v; want a *os.PathError for %s", err, testMissing)
+		}
+		// The missing location is only reported, SystemCertPool still
+		// returns an empty pool to add to.
+		if pool, err := SystemCertPool(); err != nil || pool == nil {
+			t.Errorf("SystemCertPool returned %v, %v; want an empty pool", pool, err)
+		}
+	})
 }
 
 func TestReadUniqueDirectoryEntries(t *testing.T) {
@@ -230,3 +316,23 @@ func TestReadUniqueDirectoryEntries(t *testing.T) {
 		t.Errorf("got %q; want %q", gotNames, wantNames)
 	}
 }
+
+func TestSystemRootsEmbeddedFallback(t *testing.T) {
+	if EmbeddedRootsVersion() == "" {
+		t.Skip("built without the x509embedroots tag")
+	}
+	withoutSystemRootStore(func() {
+		if ok, err := SystemRootsAvailable(); !ok {
+			t.Fatalf("SystemRootsAvailable reported no roots with embedded roots: %v", err)
+		}
+		pool, err := SystemCertPool()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range pool.Audit() {
+			if !info.System || info.Store != "embedded" {
+				t.Fatalf("system root %v has System %v and store %q", info.Certificate.Subject, info.System, info.Store)
+			}
+		}
+	})
+}
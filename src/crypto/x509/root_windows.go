@@ -5,6 +5,7 @@
 package x509
 
 import (
+	"bytes"
 	"errors"
 	"syscall"
 	"unsafe"
@@ -38,7 +39,7 @@ func createStoreContext(leaf *Certificate, opts *VerifyOptions) (*syscall.CertCo
 	}
 
 	if opts.Intermediates != nil {
-		for _, intermediate := range opts.Intermediates.certs {
+		for _, intermediate := range opts.Intermediates.certificates() {
 			ctx, err := syscall.CertCreateCertificateContext(syscall.X509_ASN_ENCODING|syscall.PKCS_7_ASN_ENCODING, &intermediate.Raw[0], uint32(len(intermediate.Raw)))
 			if err != nil {
 				return nil, err
@@ -89,7 +90,7 @@ func checkChainTrustStatus(c *Certificate, chainCtx *syscall.CertChainContext) e
 		case syscall.CERT_TRUST_IS_NOT_TIME_VALID:
 			return CertificateInvalidError{c, Expired, ""}
 		default:
-			return UnknownAuthorityError{c, nil, nil}
+			return unknownAuthorityError(c, nil, nil)
 		}
 	}
 	return nil
@@ -129,9 +130,9 @@ func checkChainSSLServerPolicy(c *Certificate, chainCtx *syscall.CertChainContex
 		case syscall.CERT_E_CN_NO_MATCH:
 			return HostnameError{c, opts.DNSName}
 		case syscall.CERT_E_UNTRUSTEDROOT:
-			return UnknownAuthorityError{c, nil, nil}
+			return unknownAuthorityError(c, nil, nil)
 		default:
-			return UnknownAuthorityError{c, nil, nil}
+			return unknownAuthorityError(c, nil, nil)
 		}
 	}
 
@@ -241,6 +242,55 @@ func (c *Certificate) systemVerify(opts *VerifyOptions) (chains [][]*Certificate
 	return [][]*Certificate{chain}, nil
 }
 
+func init() {
+	checkSystemRootStore = openSystemRootStore
+	systemStoreOf = findSystemStore
+}
+
+// findSystemStore returns the name of the first system store, of those the
+// platform verifier builds chains from, that holds cert, or "" if none does.
+func findSystemStore(cert *Certificate) string {
+	for _, name := range []string{"ROOT", "CA"} {
+		if systemStoreContains(name, cert) {
+			return name
+		}
+	}
+	return ""
+}
+
+func systemStoreContains(name string, cert *Certificate) bool {
+	store, err := syscall.CertOpenSystemStore(0, syscall.StringToUTF16Ptr(name))
+	if err != nil {
+		return false
+	}
+	defer syscall.CertCloseStore(store, 0)
+
+	var ctx *syscall.CertContext
+	for {
+		// CertEnumCertificatesInStore frees the previous context.
+		ctx, err = syscall.CertEnumCertificatesInStore(store, ctx)
+		if err != nil || ctx == nil {
+			return false
+		}
+		buf := (*[1 << 20]byte)(unsafe.Pointer(ctx.EncodedCert))[:ctx.Length:ctx.Length]
+		if bytes.Equal(buf, cert.Raw) {
+			syscall.CertFreeCertificateContext(ctx)
+			return true
+		}
+	}
+}
+
+// openSystemRootStore checks that the ROOT system store used by the platform
+// verifier can be opened.
+func openSystemRootStore() error {
+	store, err := syscall.CertOpenSystemStore(0, syscall.StringToUTF16Ptr("ROOT"))
+	if err != nil {
+		return err
+	}
+	syscall.CertCloseStore(store, 0)
+	return nil
+}
+
 func loadSystemRoots() (*CertPool, error) {
 	// TODO: restore this functionality on Windows. We tried to do
 	// it in Go 1.8 but had to revert it. See Issue 18609.
@@ -0,0 +1,120 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"time"
+)
+
+// ChainScoreOptions contains parameters for ScoreChain.
+type ChainScoreOptions struct {
+	// CurrentTime is the time used to compute the expiry headroom. If
+	// zero, the current time is used.
+	CurrentTime time.Time
+
+	// RootPrograms maps the names of root programs, such as "mozilla" or
+	// "apple", to the roots they trust. A chain is more widely accepted
+	// the more programs include its root.
+	RootPrograms map[string]*CertPool
+}
+
+// ChainScore describes the properties of a verified chain that matter when
+// choosing which of several certificates to serve.
+type ChainScore struct {
+	// Length is the number of certificates in the chain, including the
+	// leaf and the root.
+	Length int
+
+	// RSAOperations is the number of RSA signatures a client verifies to
+	// validate the chain, not counting the self-signature of the root.
+	RSAOperations int
+
+	// WeakHash reports whether a certificate other than the root is signed
+	// with SHA-1 or MD5.
+	WeakHash bool
+
+	// ExpiryHeadroom is the time left until the first certificate in the
+	// chain expires. It is negative if one already has.
+	ExpiryHeadroom time.Duration
+
+	// RootPrograms lists the names of the root programs that include the
+	// root of the chain, in no particular order.
+	RootPrograms []string
+}
+
+// ScoreChain computes the ChainScore of chain, which should have been
+// returned by Verify, and so start with the leaf and end with a root.
+func ScoreChain(chain []*Certificate, opts ChainScoreOptions) ChainScore {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	score := ChainScore{Length: len(chain)}
+	if len(chain) == 0 {
+		return score
+	}
+	for i, c := range chain {
+		if headroom := c.NotAfter.Sub(now); i == 0 || headroom < score.ExpiryHeadroom {
+			score.ExpiryHeadroom = headroom
+		}
+		if i == len(chain)-1 {
+			break
+		}
+		if chain[i+1].PublicKeyAlgorithm == RSA {
+			score.RSAOperations++
+		}
+		for _, details := range signatureAlgorithmDetails {
+			if details.algo == c.SignatureAlgorithm && (details.hash == crypto.SHA1 || details.hash == crypto.MD5) {
+				score.WeakHash = true
+			}
+		}
+	}
+
+	root := chain[len(chain)-1]
+	for name, pool := range opts.RootPrograms {
+		if pool.contains(root) {
+			score.RootPrograms = append(score.RootPrograms, name)
+		}
+	}
+	return score
+}
+
+// Better reports whether a chain scored s should be preferred over one
+// scored other. The criteria are, in order of importance: no weak hashes,
+// acceptance by more root programs, not being expired, fewer RSA operations,
+// a shorter chain and a longer expiry headroom.
+func (s ChainScore) Better(other ChainScore) bool {
+	switch {
+	case s.WeakHash != other.WeakHash:
+		return !s.WeakHash
+	case len(s.RootPrograms) != len(other.RootPrograms):
+		return len(s.RootPrograms) > len(other.RootPrograms)
+	case (s.ExpiryHeadroom < 0) != (other.ExpiryHeadroom < 0):
+		return s.ExpiryHeadroom >= 0
+	case s.RSAOperations != other.RSAOperations:
+		return s.RSAOperations < other.RSAOperations
+	case s.Length != other.Length:
+		return s.Length < other.Length
+	}
+	return s.ExpiryHeadroom > other.ExpiryHeadroom
+}
+
+// BestChain returns the index of the preferred chain in chains according to
+// ChainScore.Better, or -1 if chains is empty. Servers holding several
+// certificates can pass the verified chain of each of them to pick the one
+// to serve.
+func BestChain(chains [][]*Certificate, opts ChainScoreOptions) int {
+	best := -1
+	var bestScore ChainScore
+	for i, chain := range chains {
+		score := ScoreChain(chain, opts)
+		if best == -1 || score.Better(bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScoreChain(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	cert := func(name string, keyAlgo PublicKeyAlgorithm, sigAlgo SignatureAlgorithm, notAfter time.Time) *Certificate {
+		return &Certificate{
+			Raw:                []byte(name),
+			RawSubject:         []byte(name),
+			PublicKeyAlgorithm: keyAlgo,
+			SignatureAlgorithm: sigAlgo,
+			NotAfter:           notAfter,
+		}
+	}
+	later := now.Add(90 * 24 * time.Hour)
+
+	rsaRoot := cert("RSA root", RSA, SHA1WithRSA, now.Add(10*365*24*time.Hour))
+	ecRoot := cert("EC root", ECDSA, ECDSAWithSHA256, now.Add(10*365*24*time.Hour))
+	rsaIntermediate := cert("RSA intermediate", RSA, SHA256WithRSA, now.Add(365*24*time.Hour))
+
+	rsaChain := []*Certificate{cert("leaf", ECDSA, SHA256WithRSA, later), rsaIntermediate, rsaRoot}
+	sha1Chain := []*Certificate{cert("leaf", ECDSA, SHA1WithRSA, later), rsaIntermediate, rsaRoot}
+	ecChain := []*Certificate{cert("leaf", ECDSA, ECDSAWithSHA256, now.Add(30*24*time.Hour)), ecRoot}
+
+	programs := map[string]*CertPool{"a": NewCertPool(), "b": NewCertPool()}
+	programs["a"].AddCert(rsaRoot)
+	programs["a"].AddCert(ecRoot)
+	programs["b"].AddCert(rsaRoot)
+	opts := ChainScoreOptions{CurrentTime: now, RootPrograms: programs}
+
+	got := ScoreChain(rsaChain, opts)
+	want := ChainScore{Length: 3, RSAOperations: 2, ExpiryHeadroom: 90 * 24 * time.Hour}
+	programsGot := got.RootPrograms
+	got.RootPrograms = nil
+	if !reflect.DeepEqual(got, want) || len(programsGot) != 2 {
+		t.Errorf("ScoreChain = %+v with programs %v; want %+v with 2 programs", got, programsGot, want)
+	}
+	if s := ScoreChain(sha1Chain, opts); !s.WeakHash {
+		t.Error("SHA-1 signature on the leaf was not detected")
+	}
+	// The self-signature of the root does not count.
+	if s := ScoreChain(rsaChain[1:], opts); s.WeakHash {
+		t.Error("SHA-1 self-signature of the root was counted")
+	}
+
+	if best := BestChain([][]*Certificate{sha1Chain, ecChain, rsaChain}, opts); best != 2 {
+		t.Errorf("BestChain picked chain %d; want the chain trusted by both programs", best)
+	}
+	delete(programs, "b")
+	if best := BestChain([][]*Certificate{sha1Chain, rsaChain, ecChain}, opts); best != 2 {
+		t.Errorf("BestChain picked chain %d; want the chain with fewer RSA operations", best)
+	}
+	if best := BestChain(nil, opts); best != -1 {
+		t.Errorf("BestChain(nil) = %d; want -1", best)
+	}
+}
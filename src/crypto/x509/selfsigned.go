@@ -0,0 +1,187 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// SelfSignedOptions contains parameters for GenerateSelfSigned.
+type SelfSignedOptions struct {
+	// Hosts lists the DNS names, including wildcards such as
+	// "*.example.test", and IP addresses that the certificate is valid
+	// for. If empty, "localhost", "127.0.0.1" and "::1" are used.
+	Hosts []string
+
+	// Key is the private key of the certificate. If nil, an ECDSA P-256
+	// key is generated.
+	Key crypto.Signer
+
+	// NotBefore is the start of the validity period. If zero, it is five
+	// minutes before the current time, to tolerate clock skew.
+	NotBefore time.Time
+	// ValidFor is the length of the validity period. If zero, it is 90
+	// days.
+	ValidFor time.Duration
+
+	// WithCA causes the certificate to be issued by a freshly generated CA,
+	// instead of being self-signed. The private key of the CA is discarded,
+	// and the CA is restricted to Hosts with name constraints, so that
+	// trusting it does not allow any other certificate to be issued.
+	WithCA bool
+
+	// Rand is the source of entropy. If nil, crypto/rand.Reader is used.
+	Rand io.Reader
+}
+
+// GenerateSelfSigned creates a certificate for the server side of a local
+// development or test environment, along with its private key.
+//
+// The certificate is valid for the names in opts.Hosts, which are set as
+// subject alternative names, and for both server and client
+// authentication. The returned chain holds the certificate, followed by
+// the CA that issued it if opts.WithCA is set. The last certificate of the
+// chain is the one to add to a CertPool of roots.
+func GenerateSelfSigned(opts SelfSignedOptions) (Chain, crypto.Signer, error) {
+	random := opts.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	hosts := opts.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-5 * time.Minute)
+	}
+	validFor := opts.ValidFor
+	if validFor == 0 {
+		validFor = 90 * 24 * time.Hour
+	}
+	if validFor < 0 {
+		return nil, nil, errors.New("x509: negative validity period")
+	}
+
+	key := opts.Key
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), random); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	template := &Certificate{
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validFor),
+		KeyUsage:              KeyUsageDigitalSignature,
+		ExtKeyUsage:           []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if _, isRSA := key.Public().(*rsa.PublicKey); isRSA {
+		// RSA key exchange, as used by older TLS versions, requires this.
+		template.KeyUsage |= KeyUsageKeyEncipherment
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	parent, parentKey := template, key
+	var ca *Certificate
+	if opts.WithCA {
+		caKey, err := ecdsa.GenerateKey(elliptic.P256(), random)
+		if err != nil {
+			return nil, nil, err
+		}
+		caTemplate := &Certificate{
+			NotBefore:             template.NotBefore,
+			NotAfter:              template.NotAfter,
+			KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLenZero:        true,
+
+			PermittedDNSDomainsCritical: true,
+		}
+		if caTemplate.SerialNumber, err = randomSerialNumber(random); err != nil {
+			return nil, nil, err
+		}
+		caTemplate.Subject = pkix.Name{CommonName: "Development CA " + caTemplate.SerialNumber.Text(16)}
+		for _, name := range template.DNSNames {
+			caTemplate.PermittedDNSDomains = append(caTemplate.PermittedDNSDomains, strings.TrimPrefix(name, "*."))
+		}
+		for _, ip := range template.IPAddresses {
+			mask := net.CIDRMask(len(ip)*8, len(ip)*8)
+			caTemplate.PermittedIPRanges = append(caTemplate.PermittedIPRanges, &net.IPNet{IP: ip, Mask: mask})
+		}
+		if len(caTemplate.PermittedDNSDomains) == 0 {
+			// An empty list would permit every DNS name.
+			caTemplate.ExcludedDNSDomains = []string{""}
+		}
+		if len(caTemplate.PermittedIPRanges) == 0 {
+			caTemplate.ExcludedIPRanges = []*net.IPNet{
+				{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+				{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+			}
+		}
+
+		der, err := CreateCertificate(random, caTemplate, caTemplate, caKey.Public(), caKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("x509: creating CA certificate: %v", err)
+		}
+		if ca, err = ParseCertificate(der); err != nil {
+			return nil, nil, err
+		}
+		parent, parentKey = ca, caKey
+	}
+
+	var err error
+	if template.SerialNumber, err = randomSerialNumber(random); err != nil {
+		return nil, nil, err
+	}
+	der, err := CreateCertificate(random, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chain := Chain{leaf}
+	if ca != nil {
+		chain = append(chain, ca)
+	}
+	return chain, key, nil
+}
+
+// randomSerialNumber returns a random positive 128-bit serial number.
+func randomSerialNumber(random io.Reader) (*big.Int, error) {
+	serial, err := rand.Int(random, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	return serial.Add(serial, big.NewInt(1)), nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SelfSignedOptions
+		host string
+	}{
+		{"defaults", SelfSignedOptions{}, "localhost"},
+		{"with CA", SelfSignedOptions{Hosts: []string{"*.example.test", "192.0.2.1"}, WithCA: true}, "www.example.test"},
+		{"IP only with CA", SelfSignedOptions{Hosts: []string{"::1"}, WithCA: true}, "::1"},
+		{"RSA key", SelfSignedOptions{Key: testPrivateKey, ValidFor: time.Hour}, "::1"},
+	}
+	for _, test := range tests {
+		chain, key, err := GenerateSelfSigned(test.opts)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if test.opts.Key != nil && key != test.opts.Key {
+			t.Errorf("%s: the given key was not used", test.name)
+		}
+		if !PublicKeysEqual(chain.Leaf().PublicKey, key.Public()) {
+			t.Errorf("%s: key does not match the certificate", test.name)
+		}
+		if want := test.opts.WithCA; (len(chain) == 2) != want {
+			t.Errorf("%s: got a chain of length %d", test.name, len(chain))
+		}
+
+		roots := NewCertPool()
+		roots.AddCert(chain[len(chain)-1])
+		if _, err := chain.Leaf().Verify(VerifyOptions{Roots: roots, DNSName: test.host}); err != nil {
+			t.Errorf("%s: Verify failed: %v", test.name, err)
+		}
+		if _, err := chain.Leaf().Verify(VerifyOptions{Roots: roots, DNSName: "other.invalid"}); err == nil {
+			t.Errorf("%s: Verify succeeded for another name", test.name)
+		}
+
+		_, isRSA := key.Public().(*rsa.PublicKey)
+		if got := chain.Leaf().KeyUsage&KeyUsageKeyEncipherment != 0; got != isRSA {
+			t.Errorf("%s: key encipherment usage is %v, want %v", test.name, got, isRSA)
+		}
+	}
+}
+
+func TestGenerateSelfSignedCAConstraints(t *testing.T) {
+	chain, _, err := GenerateSelfSigned(SelfSignedOptions{Hosts: []string{"dev.example.test"}, WithCA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := chain[1]
+	if len(ca.PermittedDNSDomains) != 1 || ca.PermittedDNSDomains[0] != "dev.example.test" {
+		t.Errorf("CA permits %v", ca.PermittedDNSDomains)
+	}
+	if len(ca.ExcludedIPRanges) != 2 {
+		t.Errorf("CA does not exclude IP addresses: %v", ca.ExcludedIPRanges)
+	}
+
+	// A certificate for another name, if the CA key were recovered, would
+	// not verify. Simulate it by re-signing with a known key.
+	roots := NewCertPool()
+	other, _, err := GenerateSelfSigned(SelfSignedOptions{Hosts: []string{"bank.example", "192.0.2.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := TemplateFromCertificate(ca)
+	template.PublicKey = testPrivateKey.Public()
+	template.SignatureAlgorithm = UnknownSignatureAlgorithm
+	caDER, err := CreateCertificate(rand.Reader, template, template, testPrivateKey.Public(), testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeCA, err := ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AddCert(fakeCA)
+	leafTemplate := TemplateFromCertificate(other.Leaf())
+	leafTemplate.SignatureAlgorithm = UnknownSignatureAlgorithm
+	leafDER, err := CreateCertificate(rand.Reader, leafTemplate, fakeCA, other.Leaf().PublicKey, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bank.example", net.IPv4(192, 0, 2, 1).String()} {
+		if _, err := leaf.Verify(VerifyOptions{Roots: roots, DNSName: name}); err == nil {
+			t.Errorf("certificate for %s outside the name constraints verified", name)
+		}
+	}
+}
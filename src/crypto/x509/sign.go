@@ -0,0 +1,130 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"strings"
+)
+
+// SignatureAlgorithmsError is returned by CreateCertificate and
+// CreateCertificateRequest if the template has SignatureAlgorithms and none
+// of them could be used to sign.
+type SignatureAlgorithmsError struct {
+	// Algorithms lists the signature algorithms of the template, in order,
+	// and Errs holds why each of them failed: either the key type of the
+	// signer doesn't support it, or the signer returned an error.
+	Algorithms []SignatureAlgorithm
+	Errs       []error
+}
+
+func (e *SignatureAlgorithmsError) Error() string {
+	var parts []string
+	for i, algo := range e.Algorithms {
+		parts = append(parts, algo.String()+": "+strings.TrimPrefix(e.Errs[i].Error(), "x509: "))
+	}
+	return "x509: failed to sign with any signature algorithm (" + strings.Join(parts, "; ") + ")"
+}
+
+// signingParams are the parameters for signing with one signature
+// algorithm.
+type signingParams struct {
+	algo     SignatureAlgorithm // zero for the default of the key
+	hashFunc crypto.Hash
+	sigAlgo  pkix.AlgorithmIdentifier
+	listed   bool  // whether algo is from SignatureAlgorithms
+	err      error // if the key can't sign with algo
+}
+
+// signingParamsFor returns the parameters for signing with key using each of
+// algos, or using requested if algos is empty. It returns an error if none
+// of the algorithms is supported by the key type.
+func signingParamsFor(key crypto.Signer, requested SignatureAlgorithm, algos []SignatureAlgorithm) ([]signingParams, error) {
+	if len(algos) == 0 {
+		hashFunc, sigAlgo, err := signingParamsForPublicKey(key.Public(), requested)
+		if err != nil {
+			return nil, err
+		}
+		return []signingParams{{algo: requested, hashFunc: hashFunc, sigAlgo: sigAlgo}}, nil
+	}
+	if requested != 0 {
+		return nil, errors.New("x509: only one of SignatureAlgorithm and SignatureAlgorithms may be set")
+	}
+
+	params := make([]signingParams, len(algos))
+	supported := false
+	for i, algo := range algos {
+		p := &params[i]
+		p.algo, p.listed = algo, true
+		if algo == 0 {
+			p.err = errors.New("x509: unknown SignatureAlgorithm")
+			continue
+		}
+		p.hashFunc, p.sigAlgo, p.err = signingParamsForPublicKey(key.Public(), algo)
+		if p.err == nil {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, signingFailure(params)
+	}
+	return params, nil
+}
+
+// sign signs with key the TBS structure that tbs returns for each signature
+// algorithm of params, until one succeeds. It returns the signature
+// algorithm identifier that was used and the signature.
+//
+// If params came from SignatureAlgorithms, the failures are reported with a
+// SignatureAlgorithmsError, otherwise the error of the signer is returned.
+func sign(rand io.Reader, key crypto.Signer, params []signingParams, tbs func(pkix.AlgorithmIdentifier) ([]byte, error)) (pkix.AlgorithmIdentifier, []byte, error) {
+	for i := range params {
+		p := &params[i]
+		if p.err != nil {
+			continue
+		}
+
+		signed, err := tbs(p.sigAlgo)
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		if p.hashFunc != 0 {
+			h := p.hashFunc.New()
+			h.Write(signed)
+			signed = h.Sum(nil)
+		}
+
+		var signerOpts crypto.SignerOpts = p.hashFunc
+		if p.algo.isRSAPSS() {
+			signerOpts = &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+				Hash:       p.hashFunc,
+			}
+		}
+
+		signature, err := key.Sign(rand, signed, signerOpts)
+		if err == nil {
+			return p.sigAlgo, signature, nil
+		}
+		if !p.listed {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		p.err = err
+	}
+	return pkix.AlgorithmIdentifier{}, nil, signingFailure(params)
+}
+
+func signingFailure(params []signingParams) error {
+	e := new(SignatureAlgorithmsError)
+	for _, p := range params {
+		e.Algorithms = append(e.Algorithms, p.algo)
+		e.Errs = append(e.Errs, p.err)
+	}
+	return e
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// pssOnlySigner is an RSA signer that, like some HSMs, only allows RSA-PSS.
+type pssOnlySigner struct {
+	*rsa.PrivateKey
+}
+
+func (s pssOnlySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); !ok {
+		return nil, errors.New("mechanism not allowed")
+	}
+	return s.PrivateKey.Sign(rand, digest, opts)
+}
+
+func TestSignatureAlgorithms(t *testing.T) {
+	signer := pssOnlySigner{testPrivateKey}
+	algos := []SignatureAlgorithm{ECDSAWithSHA256, SHA256WithRSA, SHA384WithRSAPSS}
+	template := &Certificate{
+		SerialNumber:        big.NewInt(1),
+		Subject:             pkix.Name{CommonName: "HSM Root"},
+		NotBefore:           time.Now().Add(-time.Hour),
+		NotAfter:            time.Now().Add(time.Hour),
+		SignatureAlgorithms: algos,
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if algo, err := PeekSignatureAlgorithm(der); err != nil || algo != SHA384WithRSAPSS {
+		t.Errorf("certificate signed with %v, %v; want %v", algo, err, SHA384WithRSAPSS)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		t.Error(err)
+	}
+
+	csrDER, err := CreateCertificateRequest(rand.Reader, &CertificateRequest{
+		Subject:             pkix.Name{CommonName: "HSM Key"},
+		SignatureAlgorithms: algos,
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csr.SignatureAlgorithm != SHA384WithRSAPSS {
+		t.Errorf("CSR signed with %v, want %v", csr.SignatureAlgorithm, SHA384WithRSAPSS)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Error(err)
+	}
+
+	template.SignatureAlgorithms = algos[:2]
+	_, err = CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if e, ok := err.(*SignatureAlgorithmsError); !ok || len(e.Algorithms) != 2 || len(e.Errs) != 2 ||
+		e.Errs[1].Error() != "mechanism not allowed" {
+		t.Errorf("no usable algorithm: got %v", err)
+	}
+	template.SignatureAlgorithms = algos[:1]
+	if _, err := CreateCertificate(rand.Reader, template, template, signer.Public(), signer); err == nil {
+		t.Error("no algorithm for the key type: got no error")
+	}
+
+	template.SignatureAlgorithm = SHA256WithRSAPSS
+	if _, err := CreateCertificate(rand.Reader, template, template, signer.Public(), signer); err == nil {
+		t.Error("SignatureAlgorithm and SignatureAlgorithms both set: got no error")
+	}
+	template.SignatureAlgorithms = nil
+	template.SignatureAlgorithm = SHA256WithRSA
+	if _, err := CreateCertificate(rand.Reader, template, template, signer.Public(), signer); err == nil || err.Error() != "mechanism not allowed" {
+		t.Errorf("SignatureAlgorithm: got %v, want the signer error", err)
+	}
+}
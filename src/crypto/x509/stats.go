@@ -0,0 +1,71 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+)
+
+// VerifyStats accumulates the cryptographic work done by verifications, for
+// capacity planning or to detect populations of certificates that are
+// unexpectedly expensive to verify. See VerifyOptions.Stats.
+//
+// A VerifyStats is safe for concurrent use, so a single one can be shared by
+// all the verifications of a server. The zero value is ready to use.
+type VerifyStats struct {
+	mu              sync.Mutex
+	signatureChecks map[string]int
+}
+
+// SignatureChecks returns the number of certificate signatures checked while
+// building chains, including those that did not verify, keyed by the kind of
+// the issuer's key: "RSA-" or "DSA-" followed by the modulus size in bits,
+// "ECDSA-" followed by the curve name, such as "ECDSA-P-256", or "Ed25519".
+//
+// Signatures checked by the platform verifier are not included.
+func (s *VerifyStats) SignatureChecks() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checks := make(map[string]int, len(s.signatureChecks))
+	for kind, n := range s.signatureChecks {
+		checks[kind] = n
+	}
+	return checks
+}
+
+// addSignatureCheck records a signature check with the public key pub. It
+// does nothing if s is nil.
+func (s *VerifyStats) addSignatureCheck(pub interface{}) {
+	if s == nil {
+		return
+	}
+	kind := publicKeyKind(pub)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.signatureChecks == nil {
+		s.signatureChecks = make(map[string]int)
+	}
+	s.signatureChecks[kind]++
+}
+
+// publicKeyKind returns a short description of the algorithm and size of pub.
+func publicKeyKind(pub interface{}) string {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", pub.N.BitLen())
+	case *dsa.PublicKey:
+		return fmt.Sprintf("DSA-%d", pub.P.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + pub.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return "unknown"
+}
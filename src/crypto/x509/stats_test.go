@@ -0,0 +1,61 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyStats(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+	stats := new(VerifyStats)
+	for i := 0; i < 2; i++ {
+		if _, err := leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates, Stats: stats}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]int{"ECDSA-P-256": 4}
+	if got := stats.SignatureChecks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got signature checks %v, want %v", got, want)
+	}
+}
+
+func TestPublicKeyKind(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pub  interface{}
+		kind string
+	}{
+		{&testPrivateKey.PublicKey, "RSA-1024"},
+		{pub, "Ed25519"},
+		{nil, "unknown"},
+	} {
+		if kind := publicKeyKind(test.pub); kind != test.kind {
+			t.Errorf("got %q, want %q", kind, test.kind)
+		}
+	}
+}
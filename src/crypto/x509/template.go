@@ -0,0 +1,393 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+)
+
+// TemplateFromCertificate returns a template that, when passed to
+// CreateCertificate with the same parent and public key, produces a
+// certificate with the same contents as c, apart from the signature and, if
+// the parent differs, the issuer and authority key identifier.
+//
+// Fields of c that CreateCertificate understands are copied to the template.
+// Every extension of c that CreateCertificate would not regenerate byte for
+// byte from those fields, such as unknown extensions or extensions with an
+// unusual encoding or criticality, is copied verbatim into ExtraExtensions;
+// OIDExtensions are copied as they are.
+// Note that such extensions then take precedence over the corresponding
+// template fields. Similarly, RawSubject is copied so that the subject is
+// reproduced exactly; clear it for changes to Subject to take effect.
+//
+// The returned template shares no slices or pointers with c other than the
+// PublicKey, so it can be modified freely, for example to renew the
+// certificate with a new validity period.
+func TemplateFromCertificate(c *Certificate) *Certificate {
+	t := &Certificate{
+		RawSubject: cloneBytes(c.RawSubject),
+
+		SignatureAlgorithm: c.SignatureAlgorithm,
+		PublicKeyAlgorithm: c.PublicKeyAlgorithm,
+		PublicKey:          c.PublicKey,
+
+		Version:   c.Version,
+		Issuer:    cloneName(c.Issuer),
+		Subject:   cloneName(c.Subject),
+		NotBefore: c.NotBefore,
+		NotAfter:  c.NotAfter,
+		KeyUsage:  c.KeyUsage,
+
+		RawKeyUsage: cloneBytes(c.RawKeyUsage),
+
+		ExtKeyUsage:            append([]ExtKeyUsage(nil), c.ExtKeyUsage...),
+		UnknownExtKeyUsage:     cloneOIDs(c.UnknownExtKeyUsage),
+		UnknownExtKeyUsageOIDs: append([]OID(nil), c.UnknownExtKeyUsageOIDs...),
+
+		BasicConstraintsValid: c.BasicConstraintsValid,
+		IsCA:                  c.IsCA,
+		MaxPathLen:            c.MaxPathLen,
+		MaxPathLenZero:        c.MaxPathLenZero,
+
+		SubjectKeyId:   cloneBytes(c.SubjectKeyId),
+		AuthorityKeyId: cloneBytes(c.AuthorityKeyId),
+
+		OCSPServer:            append([]string(nil), c.OCSPServer...),
+		IssuingCertificateURL: append([]string(nil), c.IssuingCertificateURL...),
+
+		DNSNames:       append([]string(nil), c.DNSNames...),
+		EmailAddresses: append([]string(nil), c.EmailAddresses...),
+		IPAddresses:    cloneIPs(c.IPAddresses),
+		URIs:           cloneURIs(c.URIs),
+		DirectoryNames: cloneNames(c.DirectoryNames),
+
+		PermittedDNSDomainsCritical: c.PermittedDNSDomainsCritical,
+		PermittedDNSDomains:         append([]string(nil), c.PermittedDNSDomains...),
+		ExcludedDNSDomains:          append([]string(nil), c.ExcludedDNSDomains...),
+		PermittedIPRanges:           cloneIPNets(c.PermittedIPRanges),
+		ExcludedIPRanges:            cloneIPNets(c.ExcludedIPRanges),
+		PermittedEmailAddresses:     append([]string(nil), c.PermittedEmailAddresses...),
+		ExcludedEmailAddresses:      append([]string(nil), c.ExcludedEmailAddresses...),
+		PermittedURIDomains:         append([]string(nil), c.PermittedURIDomains...),
+		ExcludedURIDomains:          append([]string(nil), c.ExcludedURIDomains...),
+		PermittedDirectoryNames:     cloneNames(c.PermittedDirectoryNames),
+		ExcludedDirectoryNames:      cloneNames(c.ExcludedDirectoryNames),
+
+		CRLDistributionPoints: append([]string(nil), c.CRLDistributionPoints...),
+
+		PolicyIdentifiers: cloneOIDs(c.PolicyIdentifiers),
+		// OIDs are immutable, so copying them is enough.
+		Policies:       append([]OID(nil), c.Policies...),
+		PolicyMappings: append([]PolicyMapping(nil), c.PolicyMappings...),
+
+		BiometricInfo: cloneBiometricInfo(c.BiometricInfo),
+		Logotypes:     cloneLogotypes(c.Logotypes),
+	}
+	if c.SerialNumber != nil {
+		t.SerialNumber = new(big.Int).Set(c.SerialNumber)
+	}
+
+	if t.BasicConstraintsValid && !t.IsCA {
+		// CreateCertificate rejects path length constraints on leaf
+		// certificates. If c has one anyway, the original extension is
+		// preserved below.
+		t.MaxPathLen = -1
+		t.MaxPathLenZero = false
+	}
+
+	// Work out which extensions CreateCertificate would regenerate from the
+	// fields above, and preserve the rest verbatim.
+	regenerated, err := buildExtensions(t, bytes.Equal(c.RawSubject, emptyASN1Subject), c.AuthorityKeyId, c.SubjectKeyId)
+	if err != nil {
+		regenerated = nil
+	}
+	for _, e := range c.Extensions {
+		if !containsExtension(regenerated, e) {
+			t.ExtraExtensions = append(t.ExtraExtensions, pkix.Extension{
+				Id:       cloneOID(e.Id),
+				Critical: e.Critical,
+				Value:    cloneBytes(e.Value),
+			})
+		}
+	}
+	for _, e := range c.OIDExtensions {
+		t.OIDExtensions = append(t.OIDExtensions, OIDExtension{
+			Id:       e.Id,
+			Critical: e.Critical,
+			Value:    cloneBytes(e.Value),
+		})
+	}
+
+	return t
+}
+
+// CrossSign returns a DER encoded certificate with the same serial number,
+// subject, public key and extensions as orig, issued by newParent and signed
+// by signer, which must hold the private key of newParent. It is intended for
+// root rotation, where an existing CA is cross-signed by a new (or old) root.
+//
+// The issuer and authority key identifier are taken from newParent, any
+// authority key identifier of orig is dropped, and the signature algorithm is
+// chosen based on signer. The validity period is that of orig, shortened if
+// needed so that it does not extend beyond the validity period of newParent.
+//
+// Callers that need to change other fields, such as the serial number, should
+// use TemplateFromCertificate and CreateCertificate directly.
+func CrossSign(orig *Certificate, newParent *Certificate, signer crypto.Signer) ([]byte, error) {
+	t := TemplateFromCertificate(orig)
+	t.SignatureAlgorithm = UnknownSignatureAlgorithm
+	t.AuthorityKeyId = nil
+
+	extensions := t.ExtraExtensions[:0]
+	for _, e := range t.ExtraExtensions {
+		if !e.Id.Equal(oidExtensionAuthorityKeyId) {
+			extensions = append(extensions, e)
+		}
+	}
+	t.ExtraExtensions = extensions
+
+	if t.NotBefore.Before(newParent.NotBefore) {
+		t.NotBefore = newParent.NotBefore
+	}
+	if !newParent.NotAfter.IsZero() && t.NotAfter.After(newParent.NotAfter) {
+		t.NotAfter = newParent.NotAfter
+	}
+	if t.NotAfter.Before(t.NotBefore) {
+		return nil, errors.New("x509: validity period of certificate does not overlap with that of new parent")
+	}
+
+	return CreateCertificate(rand.Reader, t, newParent, orig.PublicKey, signer)
+}
+
+// SetExtension sets an extension in the ExtraExtensions of the template c,
+// replacing the first entry with the same OID, or appending one if there is
+// none. CreateCertificate then emits value instead of any extension it would
+// generate with that OID from the other fields of c.
+func (c *Certificate) SetExtension(id asn1.ObjectIdentifier, critical bool, value []byte) {
+	e := pkix.Extension{Id: cloneOID(id), Critical: critical, Value: cloneBytes(value)}
+	for i := range c.ExtraExtensions {
+		if c.ExtraExtensions[i].Id.Equal(id) {
+			c.ExtraExtensions[i] = e
+			return
+		}
+	}
+	c.ExtraExtensions = append(c.ExtraExtensions, e)
+}
+
+// RemoveDefaultExtension adds id to the SuppressedExtensions of the template
+// c, so that CreateCertificate does not generate the extension with that OID
+// from the other fields of c, such as the subject key identifier of a CA
+// certificate or the key usage extension. Entries of ExtraExtensions are not
+// affected.
+func (c *Certificate) RemoveDefaultExtension(id asn1.ObjectIdentifier) {
+	for _, suppressed := range c.SuppressedExtensions {
+		if suppressed.Equal(id) {
+			return
+		}
+	}
+	c.SuppressedExtensions = append(c.SuppressedExtensions, cloneOID(id))
+}
+
+// containsExtension reports whether extensions contains an extension that is
+// identical to e.
+func containsExtension(extensions []pkix.Extension, e pkix.Extension) bool {
+	for _, other := range extensions {
+		if other.Id.Equal(e.Id) && other.Critical == e.Critical && bytes.Equal(other.Value, e.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func cloneOID(oid asn1.ObjectIdentifier) asn1.ObjectIdentifier {
+	return append(asn1.ObjectIdentifier(nil), oid...)
+}
+
+func cloneOIDs(oids []asn1.ObjectIdentifier) []asn1.ObjectIdentifier {
+	var out []asn1.ObjectIdentifier
+	for _, oid := range oids {
+		out = append(out, cloneOID(oid))
+	}
+	return out
+}
+
+func cloneIPs(ips []net.IP) []net.IP {
+	var out []net.IP
+	for _, ip := range ips {
+		out = append(out, net.IP(cloneBytes(ip)))
+	}
+	return out
+}
+
+func cloneIPNets(nets []*net.IPNet) []*net.IPNet {
+	var out []*net.IPNet
+	for _, n := range nets {
+		out = append(out, &net.IPNet{IP: net.IP(cloneBytes(n.IP)), Mask: net.IPMask(cloneBytes(n.Mask))})
+	}
+	return out
+}
+
+func cloneURIs(uris []*url.URL) []*url.URL {
+	var out []*url.URL
+	for _, u := range uris {
+		u2 := *u
+		if u.User != nil {
+			u2.User = new(url.Userinfo)
+			*u2.User = *u.User
+		}
+		out = append(out, &u2)
+	}
+	return out
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func cloneAttributes(atvs []pkix.AttributeTypeAndValue) []pkix.AttributeTypeAndValue {
+	if atvs == nil {
+		return nil
+	}
+	out := make([]pkix.AttributeTypeAndValue, len(atvs))
+	for i, atv := range atvs {
+		value := atv.Value
+		switch v := value.(type) {
+		case []byte:
+			value = cloneBytes(v)
+		case asn1.ObjectIdentifier:
+			value = cloneOID(v)
+		case asn1.BitString:
+			value = asn1.BitString{Bytes: cloneBytes(v.Bytes), BitLength: v.BitLength}
+		case *big.Int:
+			value = new(big.Int).Set(v)
+		}
+		out[i] = pkix.AttributeTypeAndValue{Type: cloneOID(atv.Type), Value: value}
+	}
+	return out
+}
+
+func cloneName(n pkix.Name) pkix.Name {
+	out := pkix.Name{
+		Country:            cloneStrings(n.Country),
+		Organization:       cloneStrings(n.Organization),
+		OrganizationalUnit: cloneStrings(n.OrganizationalUnit),
+		Locality:           cloneStrings(n.Locality),
+		Province:           cloneStrings(n.Province),
+		StreetAddress:      cloneStrings(n.StreetAddress),
+		PostalCode:         cloneStrings(n.PostalCode),
+		SerialNumber:       n.SerialNumber,
+		CommonName:         n.CommonName,
+		Names:              cloneAttributes(n.Names),
+		ExtraNames:         cloneAttributes(n.ExtraNames),
+	}
+	for _, rdn := range n.RDNs {
+		out.RDNs = append(out.RDNs, cloneAttributes(rdn))
+	}
+	return out
+}
+
+func cloneNames(names []pkix.Name) []pkix.Name {
+	var out []pkix.Name
+	for _, n := range names {
+		out = append(out, cloneName(n))
+	}
+	return out
+}
+
+func cloneAlgorithmIdentifier(a pkix.AlgorithmIdentifier) pkix.AlgorithmIdentifier {
+	a.Algorithm = cloneOID(a.Algorithm)
+	a.Parameters.Bytes = cloneBytes(a.Parameters.Bytes)
+	a.Parameters.FullBytes = cloneBytes(a.Parameters.FullBytes)
+	return a
+}
+
+func cloneBiometricInfo(info []BiometricData) []BiometricData {
+	var out []BiometricData
+	for _, b := range info {
+		b.TypeOID = cloneOID(b.TypeOID)
+		b.HashAlgorithm = cloneAlgorithmIdentifier(b.HashAlgorithm)
+		b.Hash = cloneBytes(b.Hash)
+		out = append(out, b)
+	}
+	return out
+}
+
+func cloneLogotypes(l *Logotypes) *Logotypes {
+	if l == nil {
+		return nil
+	}
+	out := &Logotypes{
+		IssuerLogo:  cloneLogotypeInfoPtr(l.IssuerLogo),
+		SubjectLogo: cloneLogotypeInfoPtr(l.SubjectLogo),
+	}
+	for _, info := range l.CommunityLogos {
+		out.CommunityLogos = append(out.CommunityLogos, cloneLogotypeInfo(info))
+	}
+	for _, other := range l.OtherLogos {
+		out.OtherLogos = append(out.OtherLogos, OtherLogotypeInfo{
+			Type: cloneOID(other.Type),
+			Info: cloneLogotypeInfo(other.Info),
+		})
+	}
+	return out
+}
+
+func cloneLogotypeInfoPtr(info *LogotypeInfo) *LogotypeInfo {
+	if info == nil {
+		return nil
+	}
+	out := cloneLogotypeInfo(*info)
+	return &out
+}
+
+func cloneLogotypeInfo(info LogotypeInfo) LogotypeInfo {
+	return LogotypeInfo{
+		Images:          cloneLogotypeDetails(info.Images),
+		Audio:           cloneLogotypeDetails(info.Audio),
+		ReferenceHashes: cloneHashes(info.ReferenceHashes),
+		ReferenceURIs:   cloneStrings(info.ReferenceURIs),
+	}
+}
+
+func cloneLogotypeDetails(details []LogotypeDetails) []LogotypeDetails {
+	var out []LogotypeDetails
+	for _, d := range details {
+		out = append(out, LogotypeDetails{
+			MediaType: d.MediaType,
+			Hashes:    cloneHashes(d.Hashes),
+			URIs:      cloneStrings(d.URIs),
+		})
+	}
+	return out
+}
+
+func cloneHashes(hashes []HashAlgAndValue) []HashAlgAndValue {
+	var out []HashAlgAndValue
+	for _, h := range hashes {
+		out = append(out, HashAlgAndValue{
+			Algorithm: cloneAlgorithmIdentifier(h.Algorithm),
+			Value:     cloneBytes(h.Value),
+		})
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTemplateFromCertificateRoundTrip(t *testing.T) {
+	// A leaf with a path length constraint cannot be expressed with the
+	// template fields, so it must survive via ExtraExtensions.
+	bc, err := asn1.Marshal(basicConstraints{false, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber: big.NewInt(1234),
+		Subject: pkix.Name{
+			CommonName:   "round trip",
+			Organization: []string{"Σ Acme Co"},
+		},
+		NotBefore: time.Unix(1000, 0),
+		NotAfter:  time.Unix(100000, 0),
+
+		KeyUsage:           KeyUsageDigitalSignature,
+		ExtKeyUsage:        []ExtKeyUsage{ExtKeyUsageServerAuth},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 2, 3}},
+		SubjectKeyId:       []byte{1, 2, 3, 4},
+
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURL: []string{"http://crt.example.com/ca1.crt"},
+		DNSNames:              []string{"example.com"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1).To4()},
+		URIs:                  []*url.URL{parseURI("https://example.com/path")},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca1.crl"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+		DirectoryNames:        []pkix.Name{{CommonName: "directory", Organization: []string{"Dir Co"}}},
+		BiometricInfo: []BiometricData{{
+			Type:          BiometricPicture,
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+			Hash:          bytes.Repeat([]byte{1}, 32),
+		}},
+		Logotypes: &Logotypes{SubjectLogo: &LogotypeInfo{
+			Images: []LogotypeDetails{{
+				MediaType: "image/png",
+				Hashes: []HashAlgAndValue{{
+					Algorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+					Value:     bytes.Repeat([]byte{2}, 32),
+				}},
+				URIs: []string{"https://example.com/logo.png"},
+			}},
+		}},
+
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionBasicConstraints, Critical: true, Value: bc},
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5}, Critical: false, Value: []byte{0x05, 0x00}},
+		},
+	}
+	orig := serialiseAndParse(t, template)
+
+	tmpl := TemplateFromCertificate(orig)
+	if len(tmpl.ExtraExtensions) != 2 {
+		t.Errorf("expected 2 verbatim extensions, got %d: %v", len(tmpl.ExtraExtensions), tmpl.ExtraExtensions)
+	}
+	derBytes, err := CreateCertificate(rand.Reader, tmpl, tmpl, &testPrivateKey.PublicKey, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reissued, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(orig.RawTBSCertificate, reissued.RawTBSCertificate) {
+		if len(orig.Extensions) != len(reissued.Extensions) {
+			t.Fatalf("got %d extensions, want %d", len(reissued.Extensions), len(orig.Extensions))
+		}
+		for _, e := range orig.Extensions {
+			if !containsExtension(reissued.Extensions, e) {
+				t.Errorf("extension %v was not reproduced", e.Id)
+			}
+		}
+		if !bytes.Equal(orig.RawSubject, reissued.RawSubject) {
+			t.Error("subject was not reproduced")
+		}
+	}
+	if diffs := DiffTemplate(reissued, orig); diffs != nil {
+		t.Errorf("unexpected differences: %v", diffs)
+	}
+
+	// Modifying the template must not affect the original certificate.
+	tmpl.DNSNames[0] = "modified.example"
+	tmpl.IPAddresses[0][0] = 10
+	if orig.DNSNames[0] != "example.com" || !orig.IPAddresses[0].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Error("TemplateFromCertificate shares slices with the certificate")
+	}
+	tmpl.Subject.Organization[0] = "modified"
+	tmpl.Subject.Names[0].Type[0] = 0
+	tmpl.Subject.RDNs[0][0].Type[0] = 0
+	tmpl.DirectoryNames[0].Organization[0] = "modified"
+	tmpl.BiometricInfo[0].Hash[0] = 0
+	tmpl.Logotypes.SubjectLogo.Images[0].Hashes[0].Value[0] = 0
+	tmpl.Logotypes.SubjectLogo.Images[0].URIs[0] = "https://example.com/modified.png"
+	if orig.Subject.Organization[0] != "Σ Acme Co" || orig.Subject.Names[0].Type[0] != 2 || orig.Subject.RDNs[0][0].Type[0] != 2 ||
+		orig.DirectoryNames[0].Organization[0] != "Dir Co" || orig.BiometricInfo[0].Hash[0] != 1 ||
+		orig.Logotypes.SubjectLogo.Images[0].Hashes[0].Value[0] != 2 ||
+		orig.Logotypes.SubjectLogo.Images[0].URIs[0] != "https://example.com/logo.png" {
+		t.Error("TemplateFromCertificate shares names, biometric data or logotypes with the certificate")
+	}
+}
+
+func TestCrossSign(t *testing.T) {
+	oldRoot, oldRootKey, err := generateCert("Old Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRoot, newRootKey, err := generateCert("New Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, oldRoot, oldRootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derBytes, err := CrossSign(oldRoot, newRoot, newRootKey.(crypto.Signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cross, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(cross.RawSubject, oldRoot.RawSubject) ||
+		!bytes.Equal(cross.RawSubjectPublicKeyInfo, oldRoot.RawSubjectPublicKeyInfo) ||
+		!bytes.Equal(cross.SubjectKeyId, oldRoot.SubjectKeyId) {
+		t.Error("cross-signed certificate does not preserve the subject, key or key identifier")
+	}
+	if !bytes.Equal(cross.RawIssuer, newRoot.RawSubject) {
+		t.Error("cross-signed certificate was not issued by the new root")
+	}
+	if !bytes.Equal(cross.AuthorityKeyId, newRoot.SubjectKeyId) {
+		t.Errorf("got AuthorityKeyId %x, want %x", cross.AuthorityKeyId, newRoot.SubjectKeyId)
+	}
+	if err := cross.CheckSignatureFrom(newRoot); err != nil {
+		t.Errorf("cross-signed certificate does not verify with the new root: %v", err)
+	}
+	for _, d := range DiffTemplate(cross, oldRoot) {
+		if d.Field != "NotBefore" && d.Field != "NotAfter" {
+			t.Errorf("unexpected difference: %v", d)
+		}
+	}
+	if cross.NotBefore.Before(newRoot.NotBefore) || cross.NotAfter.After(newRoot.NotAfter) {
+		t.Error("cross-signed certificate outlives the new root")
+	}
+
+	// Certificates issued by the old root must chain to the new one.
+	opts := VerifyOptions{
+		Roots:         NewCertPool(),
+		Intermediates: NewCertPool(),
+	}
+	opts.Roots.AddCert(newRoot)
+	opts.Intermediates.AddCert(cross)
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("leaf does not chain to the new root: %v", err)
+	}
+}
+
+func TestSetAndRemoveDefaultExtension(t *testing.T) {
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CA"},
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Unix(100000, 0),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              KeyUsageCertSign,
+		ExtKeyUsage:           []ExtKeyUsage{ExtKeyUsageServerAuth},
+	}
+	template.RemoveDefaultExtension(oidExtensionSubjectKeyId)
+	template.RemoveDefaultExtension(oidExtensionKeyUsage)
+	template.RemoveDefaultExtension(oidExtensionKeyUsage)
+	if len(template.SuppressedExtensions) != 2 {
+		t.Errorf("got %d suppressed extensions, want 2", len(template.SuppressedExtensions))
+	}
+
+	custom := asn1.ObjectIdentifier{1, 2, 3, 4}
+	template.SetExtension(custom, false, []byte{5, 0})
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageClientAuth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SetExtension(oidExtensionExtendedKeyUsage, false, []byte{5, 0})
+	template.SetExtension(oidExtensionExtendedKeyUsage, true, eku)
+	if len(template.ExtraExtensions) != 2 {
+		t.Fatalf("got %d extra extensions, want 2", len(template.ExtraExtensions))
+	}
+
+	cert := serialiseAndParse(t, template)
+	if cert.SubjectKeyId != nil || cert.KeyUsage != 0 {
+		t.Errorf("suppressed extensions were generated: SubjectKeyId %x, KeyUsage %v", cert.SubjectKeyId, cert.KeyUsage)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != ExtKeyUsageClientAuth {
+		t.Errorf("got extended key usages %v, want the one set explicitly", cert.ExtKeyUsage)
+	}
+
+	// Generated extensions come first, then ExtraExtensions in order.
+	var ids []string
+	for _, e := range cert.Extensions {
+		ids = append(ids, e.Id.String())
+	}
+	want := []string{asn1.ObjectIdentifier(oidExtensionBasicConstraints).String(), custom.String(), asn1.ObjectIdentifier(oidExtensionExtendedKeyUsage).String()}
+	if len(ids) != len(want) {
+		t.Fatalf("got extensions %v, want %v", ids, want)
+	}
+	for i := range ids {
+		if ids[i] != want[i] {
+			t.Errorf("got extensions %v, want %v", ids, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// TrustAttributes are the trust settings that OpenSSL attaches to a
+// certificate in a "TRUSTED CERTIFICATE" PEM block, as found in the root
+// bundles of several Linux distributions.
+type TrustAttributes struct {
+	// TrustedUsages and UnknownTrustedUsages are the extended key usages
+	// the certificate is trusted for. If both are empty, it is trusted for
+	// any usage not rejected. ExtKeyUsageAny trusts it for any usage.
+	TrustedUsages        []ExtKeyUsage
+	UnknownTrustedUsages []OID
+
+	// RejectedUsages and UnknownRejectedUsages are the extended key usages
+	// the certificate must not be trusted for, even if they are trusted.
+	// ExtKeyUsageAny rejects it for every usage.
+	RejectedUsages        []ExtKeyUsage
+	UnknownRejectedUsages []OID
+
+	// Alias is the friendly name of the certificate, if any.
+	Alias string
+
+	// KeyID is the key identifier of the certificate, if any. It is not
+	// related to the subject key identifier extension.
+	KeyID []byte
+}
+
+// ParseTrustedCertificate parses the contents of an OpenSSL "TRUSTED
+// CERTIFICATE" PEM block: a DER certificate, optionally followed by its trust
+// attributes. The returned attributes are nil if there are none.
+func ParseTrustedCertificate(der []byte) (*Certificate, *TrustAttributes, error) {
+	input := cryptobyte.String(der)
+	var certDER, aux cryptobyte.String
+	if !input.ReadASN1Element(&certDER, cryptobyte_asn1.SEQUENCE) {
+		return nil, nil, errors.New("x509: malformed trusted certificate")
+	}
+	cert, err := ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	if input.Empty() {
+		return cert, nil, nil
+	}
+
+	// X509_CERT_AUX ::= SEQUENCE {
+	//     trust  SEQUENCE OF OBJECT IDENTIFIER OPTIONAL,
+	//     reject [0] IMPLICIT SEQUENCE OF OBJECT IDENTIFIER OPTIONAL,
+	//     alias  UTF8String OPTIONAL,
+	//     keyid  OCTET STRING OPTIONAL,
+	//     other  [1] IMPLICIT SEQUENCE OF AlgorithmIdentifier OPTIONAL }
+	errAux := errors.New("x509: malformed trust attributes")
+	if !input.ReadASN1(&aux, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, nil, errAux
+	}
+	trust := new(TrustAttributes)
+	var usages cryptobyte.String
+	var present bool
+	if !aux.ReadOptionalASN1(&usages, &present, cryptobyte_asn1.SEQUENCE) ||
+		present && !readTrustUsages(usages, &trust.TrustedUsages, &trust.UnknownTrustedUsages) {
+		return nil, nil, errAux
+	}
+	if !aux.ReadOptionalASN1(&usages, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		present && !readTrustUsages(usages, &trust.RejectedUsages, &trust.UnknownRejectedUsages) {
+		return nil, nil, errAux
+	}
+	var alias cryptobyte.String
+	if !aux.ReadOptionalASN1(&alias, &present, cryptobyte_asn1.UTF8String) {
+		return nil, nil, errAux
+	}
+	trust.Alias = string(alias)
+	var keyID cryptobyte.String
+	if !aux.ReadOptionalASN1(&keyID, &present, cryptobyte_asn1.OCTET_STRING) ||
+		!aux.SkipOptionalASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) ||
+		!aux.Empty() {
+		return nil, nil, errAux
+	}
+	if present {
+		trust.KeyID = keyID
+	}
+	return cert, trust, nil
+}
+
+// marshalTrustedCertificate returns the contents of an OpenSSL "TRUSTED
+// CERTIFICATE" PEM block for cert and trust, as read by
+// ParseTrustedCertificate.
+func marshalTrustedCertificate(cert *Certificate, trust *TrustAttributes) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddBytes(cert.Raw)
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		if len(trust.TrustedUsages) > 0 || len(trust.UnknownTrustedUsages) > 0 {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				addTrustUsages(b, trust.TrustedUsages, trust.UnknownTrustedUsages)
+			})
+		}
+		if len(trust.RejectedUsages) > 0 || len(trust.UnknownRejectedUsages) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				addTrustUsages(b, trust.RejectedUsages, trust.UnknownRejectedUsages)
+			})
+		}
+		if trust.Alias != "" {
+			b.AddASN1(cryptobyte_asn1.UTF8String, func(b *cryptobyte.Builder) {
+				b.AddBytes([]byte(trust.Alias))
+			})
+		}
+		if trust.KeyID != nil {
+			b.AddASN1OctetString(trust.KeyID)
+		}
+	})
+	return b.Bytes()
+}
+
+func addTrustUsages(b *cryptobyte.Builder, known []ExtKeyUsage, unknown []OID) {
+	for _, usage := range known {
+		oid, ok := oidFromExtKeyUsage(usage)
+		if !ok {
+			b.SetError(fmt.Errorf("x509: unknown extended key usage %d in trust attributes", usage))
+			return
+		}
+		b.AddASN1ObjectIdentifier(oid)
+	}
+	for _, u := range unknown {
+		addOID(b, u)
+	}
+}
+
+func readTrustUsages(usages cryptobyte.String, known *[]ExtKeyUsage, unknown *[]OID) bool {
+	for !usages.Empty() {
+		var u OID
+		if !readOID(&usages, &u) {
+			return false
+		}
+		if oid, ok := u.toASN1OID(); ok {
+			if extKeyUsage, ok := extKeyUsageFromOID(oid); ok {
+				*known = append(*known, extKeyUsage)
+				continue
+			}
+		}
+		*unknown = append(*unknown, u)
+	}
+	return true
+}
+
+// AddCertWithTrust adds a certificate to a pool, along with trust attributes
+// restricting the usages that Verify accepts chains through it for, when the
+// pool is VerifyOptions.Roots or VerifyOptions.TrustedIntermediates. If the
+// certificate is already in the pool, s is not modified.
+func (s *CertPool) AddCertWithTrust(cert *Certificate, trust *TrustAttributes) {
+	if cert == nil {
+		panic("adding nil Certificate to CertPool")
+	}
+	s.addCert(cert, certOrigin{trust: trust, callSite: callSite()})
+}
+
+// allows reports whether t allows a chain through its certificate to be used
+// for at least one of usages.
+func (t *TrustAttributes) allows(usages []ExtKeyUsage) bool {
+	if t == nil {
+		return true
+	}
+	if hasExtKeyUsage(t.RejectedUsages, ExtKeyUsageAny) {
+		return false
+	}
+	anyTrusted := len(t.TrustedUsages) == 0 && len(t.UnknownTrustedUsages) == 0 ||
+		hasExtKeyUsage(t.TrustedUsages, ExtKeyUsageAny)
+	for _, usage := range usages {
+		if usage == ExtKeyUsageAny {
+			return true
+		}
+		if !hasExtKeyUsage(t.RejectedUsages, usage) &&
+			(anyTrusted || hasExtKeyUsage(t.TrustedUsages, usage)) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustAllows is like t.allows, but requires every usage if
+// RequireAllKeyUsages is set.
+func (opts *VerifyOptions) trustAllows(t *TrustAttributes, usages []ExtKeyUsage) bool {
+	if !t.allows(usages) {
+		return false
+	}
+	if opts.RequireAllKeyUsages {
+		for _, usage := range usages {
+			if !t.allows([]ExtKeyUsage{usage}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasExtKeyUsage(usages []ExtKeyUsage, usage ExtKeyUsage) bool {
+	for _, u := range usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChainTrust checks the trust attributes, in VerifyOptions.Roots and
+// VerifyOptions.TrustedIntermediates, of the certificates of chain against
+// usages.
+func (opts *VerifyOptions) checkChainTrust(chain []*Certificate, usages []ExtKeyUsage) error {
+	for _, pool := range []*CertPool{opts.Roots, opts.TrustedIntermediates} {
+		if pool == nil || !pool.hasTrust {
+			continue
+		}
+		for _, cert := range chain {
+			if origin, ok := pool.origin(cert); ok && !opts.trustAllows(origin.trust, usages) {
+				return CertificateInvalidError{cert, IncompatibleUsage, "the trust attributes of the certificate do not allow the requested usage"}
+			}
+		}
+	}
+	return nil
+}
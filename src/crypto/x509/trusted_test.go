@@ -0,0 +1,183 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// trustedCertPEM was generated with
+//
+//	openssl x509 -addtrust serverAuth -addreject clientAuth \
+//		-setalias "Example Root" -trustout
+const trustedCertPEM = `
+-----BEGIN TRUSTED CERTIFICATE-----
+MIIBhjCCASugAwIBAgIUTBbbtipEGqOlp1UXWgyIzHdg3r0wCgYIKoZIzj0EAwIw
+FzEVMBMGA1UEAwwMVHJ1c3RlZCBSb290MCAXDTI2MTAxNjE0MzI1NVoYDzIxMjYw
+OTIyMTQzMjU1WjAXMRUwEwYDVQQDDAxUcnVzdGVkIFJvb3QwWTATBgcqhkjOPQIB
+BggqhkjOPQMBBwNCAAQfTLG4bmUgPmvietBMwlKSP6S/nRyf6iPBTYszEIzHbIZO
+5ihjESj5Rm7oA4GRtXqU1liDDuq1OqXjO67YXEtno1MwUTAdBgNVHQ4EFgQUXHQD
+dyxdeVC/p+8MSOPH6fv7kOkwHwYDVR0jBBgwFoAUXHQDdyxdeVC/p+8MSOPH6fv7
+kOkwDwYDVR0TAQH/BAUwAwEB/zAKBggqhkjOPQQDAgNJADBGAiEA/LOSr3j7DwUC
+eCQIJg0coSkhcawv823Y1oY+BIqWoHgCIQDmPG+zeWfwwffVLKdKVEiZb5WTOt49
+bE97wst3YyaXZDAmMAoGCCsGAQUFBwMBoAoGCCsGAQUFBwMCDAxFeGFtcGxlIFJv
+b3Q=
+-----END TRUSTED CERTIFICATE-----
+`
+
+func TestParseTrustedCertificate(t *testing.T) {
+	block, _ := pem.Decode([]byte(trustedCertPEM))
+	cert, trust, err := ParseTrustedCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "Trusted Root" {
+		t.Errorf("got subject %v", cert.Subject)
+	}
+	want := &TrustAttributes{
+		TrustedUsages:  []ExtKeyUsage{ExtKeyUsageServerAuth},
+		RejectedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth},
+		Alias:          "Example Root",
+	}
+	if !reflect.DeepEqual(trust, want) {
+		t.Errorf("got trust attributes %+v, want %+v", trust, want)
+	}
+
+	if _, trust, err := ParseTrustedCertificate(cert.Raw); err != nil || trust != nil {
+		t.Errorf("certificate without attributes: got %+v, %v", trust, err)
+	}
+	if _, _, err := ParseTrustedCertificate(append(block.Bytes, 0)); err == nil {
+		t.Error("trailing data was accepted")
+	}
+}
+
+// trustedCertificateBlock returns a "TRUSTED CERTIFICATE" PEM block for cert
+// with the given trusted usage.
+func trustedCertificateBlock(cert *Certificate, usage ExtKeyUsage) []byte {
+	oid, _ := oidFromExtKeyUsage(usage)
+	var b cryptobyte.Builder
+	b.AddBytes(cert.Raw)
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(oid)
+		})
+	})
+	return pem.EncodeToMemory(&pem.Block{Type: "TRUSTED CERTIFICATE", Bytes: b.BytesOrPanic()})
+}
+
+func TestVerifyTrustAttributes(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownUsage, err := OIDFromInts([]uint64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		trust  *TrustAttributes
+		usages []ExtKeyUsage
+		ok     bool
+	}{
+		{"no attributes", nil, nil, true},
+		{"empty attributes", &TrustAttributes{Alias: "root"}, nil, true},
+		{"trusted", &TrustAttributes{TrustedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth, ExtKeyUsageServerAuth}}, nil, true},
+		{"trusted for any", &TrustAttributes{TrustedUsages: []ExtKeyUsage{ExtKeyUsageAny}}, nil, true},
+		{"not trusted", &TrustAttributes{TrustedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}}, nil, false},
+		{"unknown trusted", &TrustAttributes{UnknownTrustedUsages: []OID{unknownUsage}}, nil, false},
+		{"rejected", &TrustAttributes{RejectedUsages: []ExtKeyUsage{ExtKeyUsageServerAuth}}, nil, false},
+		{"rejected for any", &TrustAttributes{RejectedUsages: []ExtKeyUsage{ExtKeyUsageAny}}, []ExtKeyUsage{ExtKeyUsageAny}, false},
+		{"any usage requested", &TrustAttributes{TrustedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}}, []ExtKeyUsage{ExtKeyUsageAny}, true},
+		{"one usage allowed", &TrustAttributes{RejectedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}}, []ExtKeyUsage{ExtKeyUsageClientAuth, ExtKeyUsageServerAuth}, true},
+	}
+	for _, tt := range tests {
+		roots := NewCertPool()
+		roots.AddCertWithTrust(root, tt.trust)
+		_, err := leaf.Verify(VerifyOptions{Roots: roots, KeyUsages: tt.usages})
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok {
+			if e, ok := err.(CertificateInvalidError); !ok || e.Reason != IncompatibleUsage {
+				t.Errorf("%s: got error %v, want IncompatibleUsage", tt.name, err)
+			}
+		}
+	}
+
+	roots := NewCertPool()
+	if !roots.AppendCertsFromPEM(trustedCertificateBlock(root, ExtKeyUsageClientAuth)) {
+		t.Fatal("AppendCertsFromPEM rejected a TRUSTED CERTIFICATE block")
+	}
+	if info := roots.copy().Audit(); len(info) != 1 || info[0].Trust == nil {
+		t.Errorf("Audit returned %+v, want one entry with trust attributes", info)
+	}
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots}); err == nil {
+		t.Error("root trusted only for client authentication anchored a server chain")
+	}
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, KeyUsages: []ExtKeyUsage{ExtKeyUsageAny}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWritePEMTrustAttributes(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownUsage, err := OIDFromInts([]uint64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, trust := range []*TrustAttributes{
+		{RejectedUsages: []ExtKeyUsage{ExtKeyUsageAny}},
+		{TrustedUsages: []ExtKeyUsage{ExtKeyUsageClientAuth}, UnknownTrustedUsages: []OID{unknownUsage}},
+		{RejectedUsages: []ExtKeyUsage{ExtKeyUsageServerAuth}, UnknownRejectedUsages: []OID{unknownUsage}, Alias: "root", KeyID: []byte{1, 2}},
+	} {
+		roots := NewCertPool()
+		roots.AddCertWithTrust(root, trust)
+		var buf bytes.Buffer
+		if err := roots.WritePEM(&buf); err != nil {
+			t.Fatal(err)
+		}
+		reread := NewCertPool()
+		if !reread.AppendCertsFromPEM(buf.Bytes()) {
+			t.Fatalf("%+v: AppendCertsFromPEM rejected the output of WritePEM", trust)
+		}
+		if info := reread.Audit(); len(info) != 1 || !reflect.DeepEqual(info[0].Trust, trust) {
+			t.Errorf("%+v: got trust attributes %+v after a round trip", trust, info[0].Trust)
+		}
+		if _, err := leaf.Verify(VerifyOptions{Roots: reread}); err == nil {
+			t.Errorf("%+v: the re-read root anchored a server chain", trust)
+		}
+	}
+
+	// Roots without attributes are still written as plain certificates.
+	roots := NewCertPool()
+	roots.AddCert(root)
+	var buf bytes.Buffer
+	if err := roots.WritePEM(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if block, _ := pem.Decode(buf.Bytes()); block == nil || block.Type != "CERTIFICATE" {
+		t.Errorf("got block %v, want a CERTIFICATE block", block)
+	}
+}
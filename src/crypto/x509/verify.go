@@ -6,21 +6,84 @@ package x509
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
-// ignoreCN disables interpreting Common Name as a hostname. See issue 24151.
+// ignoreCN disables interpreting Common Name as a hostname, unless
+// VerifyOptions.LegacyCommonName says otherwise. See issue 24151.
 var ignoreCN = !strings.Contains(os.Getenv("GODEBUG"), "x509ignoreCN=0")
 
+// CommonNameMode selects whether the Common Name of a leaf certificate
+// without Subject Alternative Names is treated as a hostname.
+type CommonNameMode int
+
+const (
+	// CommonNameDefault follows the GODEBUG environment variable: the
+	// Common Name is treated as a hostname only if it contains
+	// "x509ignoreCN=0".
+	CommonNameDefault CommonNameMode = iota
+	// CommonNameIgnored never treats the Common Name as a hostname.
+	CommonNameIgnored
+	// CommonNameAsHostname treats the Common Name as a hostname, as with
+	// "x509ignoreCN=0". Support for it might be removed in the future.
+	CommonNameAsHostname
+)
+
+// IPv4MappedMode selects how an IPv4-mapped IPv6 address, ::ffff:a.b.c.d,
+// relates to the IPv4 address a.b.c.d, which dual-stack sockets report for
+// the same peer.
+type IPv4MappedMode int
+
+const (
+	// IPv4MappedDefault treats the two forms as the same address when
+	// matching a hostname against the IP SANs, but checks name constraints
+	// on IP SANs as they are encoded in the certificate: an IPv4-mapped SAN
+	// is only matched by IPv6 ranges, and an IPv4 SAN only by IPv4 ranges.
+	IPv4MappedDefault IPv4MappedMode = iota
+	// IPv4MappedEquivalent treats the two forms as the same address
+	// everywhere. IPv4-mapped SANs are checked against IPv4 ranges, and
+	// IPv4 SANs against IPv4-mapped ranges, so that a SAN can't escape an
+	// excluded range by being encoded in the other form.
+	IPv4MappedEquivalent
+	// IPv4MappedDistinct treats the two forms as different addresses
+	// everywhere. A hostname matches an IP SAN only if both are IPv4, or
+	// both are IPv4-mapped.
+	IPv4MappedDistinct
+)
+
+// ValidityNestingMode selects whether Verify checks that the validity period
+// of each certificate of a chain is within that of its issuer. RFC 5280 does
+// not require it, but some policies do.
+type ValidityNestingMode int
+
+const (
+	// ValidityNestingIgnored doesn't check validity nesting.
+	ValidityNestingIgnored ValidityNestingMode = iota
+	// ValidityNestingWarn reports certificates whose validity period is
+	// not nested within their issuer's with a WarningValidityNotNested
+	// through VerifyOptions.Warnings.
+	ValidityNestingWarn
+	// ValidityNestingEnforced rejects chains with such certificates, with
+	// a ValidityNotNested error.
+	ValidityNestingEnforced
+)
+
 type InvalidReason int
 
 const (
@@ -49,8 +112,9 @@ const (
 	// a hostname.
 	//
 	// This error is only returned when legacy Common Name matching is enabled
-	// by setting the GODEBUG environment variable to "x509ignoreCN=1". This
-	// setting might be removed in the future.
+	// by VerifyOptions.LegacyCommonName or by setting the GODEBUG environment
+	// variable to "x509ignoreCN=0". This setting might be removed in the
+	// future.
 	NameConstraintsWithoutSANs
 	// UnconstrainedName results when a CA certificate contains permitted
 	// name constraints, but leaf certificate contains a name of an
@@ -65,6 +129,43 @@ const (
 	// CANotAuthorizedForExtKeyUsage results when an intermediate or root
 	// certificate does not permit a requested extended key usage.
 	CANotAuthorizedForExtKeyUsage
+	// ChainTooLong results when a chain is longer than
+	// VerifyOptions.MaxChainLength.
+	ChainTooLong
+	// InsecureAlgorithm results when a certificate uses a key that is
+	// smaller than VerifyOptions.MinRSAKeySize, or a signature algorithm
+	// listed in VerifyOptions.DisallowedSignatureAlgorithms.
+	InsecureAlgorithm
+	// NoPinnedKey results when no certificate in a chain has one of the
+	// public keys in VerifyOptions.PinnedKeys.
+	NoPinnedKey
+	// BlockedKey results when a certificate in a chain has one of the
+	// public keys in VerifyOptions.BlockedKeys.
+	BlockedKey
+	// NonCanonicalSignature results when the ECDSA signature of a
+	// certificate is rejected by VerifyOptions.StrictECDSASignatures or
+	// VerifyOptions.RequireLowS.
+	NonCanonicalSignature
+	// NonCanonicalKeyUsage results when the key usage extension of a
+	// certificate is not DER encoded and VerifyOptions.StrictKeyUsageEncoding
+	// is set.
+	NonCanonicalKeyUsage
+	// NoValidPolicy results when VerifyOptions.CertificatePolicies is set
+	// and no policy acceptable to it is valid for a chain.
+	NoValidPolicy
+	// MissingSCTs results when a chain does not meet one of
+	// VerifyOptions.CTRequirements.
+	MissingSCTs
+	// MissingSubjectAltName results when a certificate has no subject
+	// alternative name of a type required by ClientAuthOptions.RequiredSANs.
+	MissingSubjectAltName
+	// ValidityNotNested results when the validity period of a certificate
+	// is not within that of its issuer, as checked by CheckIssuance and
+	// by Verify with ValidityNestingEnforced.
+	ValidityNotNested
+	// Revoked results when a certificate is revoked or on hold, as
+	// reported by CRLChecker.
+	Revoked
 )
 
 // CertificateInvalidError results when an odd error occurs. Users of this
@@ -78,6 +179,9 @@ type CertificateInvalidError struct {
 func (e CertificateInvalidError) Error() string {
 	switch e.Reason {
 	case NotAuthorizedToSign:
+		if e.Detail != "" {
+			return "x509: certificate is not authorized to sign other certificates: " + e.Detail
+		}
 		return "x509: certificate is not authorized to sign other certificates"
 	case Expired:
 		return "x509: certificate has expired or is not yet valid: " + e.Detail
@@ -88,6 +192,9 @@ func (e CertificateInvalidError) Error() string {
 	case TooManyIntermediates:
 		return "x509: too many intermediates for path length constraint"
 	case IncompatibleUsage:
+		if e.Detail != "" {
+			return "x509: certificate specifies an incompatible key usage: " + e.Detail
+		}
 		return "x509: certificate specifies an incompatible key usage"
 	case NameMismatch:
 		return "x509: issuer name does not match subject from issuing certificate"
@@ -95,6 +202,28 @@ func (e CertificateInvalidError) Error() string {
 		return "x509: issuer has name constraints but leaf doesn't have a SAN extension"
 	case UnconstrainedName:
 		return "x509: issuer has name constraints but leaf contains unknown or unconstrained name: " + e.Detail
+	case ChainTooLong:
+		return "x509: certificate chain is too long: " + e.Detail
+	case InsecureAlgorithm:
+		return "x509: certificate uses an insecure algorithm: " + e.Detail
+	case NoPinnedKey:
+		return "x509: certificate chain does not contain a pinned public key"
+	case BlockedKey:
+		return "x509: certificate has a blocked public key"
+	case NonCanonicalSignature:
+		return "x509: certificate has a non-canonical signature: " + e.Detail
+	case NonCanonicalKeyUsage:
+		return "x509: certificate has a non-canonical key usage encoding: " + e.Detail
+	case NoValidPolicy:
+		return "x509: no acceptable certificate policy is valid for the chain: " + e.Detail
+	case MissingSCTs:
+		return "x509: certificate does not have enough signed certificate timestamps: " + e.Detail
+	case MissingSubjectAltName:
+		return "x509: certificate does not have a required subject alternative name: " + e.Detail
+	case ValidityNotNested:
+		return "x509: certificate validity period is not within that of its issuer: " + e.Detail
+	case Revoked:
+		return "x509: certificate is revoked: " + e.Detail
 	}
 	return "x509: unknown error"
 }
@@ -134,7 +263,7 @@ func (h HostnameError) Error() string {
 			valid += san.String()
 		}
 	} else {
-		if c.commonNameAsHostname() {
+		if c.commonNameAsHostname(nil) {
 			valid = c.Subject.CommonName
 		} else {
 			valid = strings.Join(c.DNSNames, ", ")
@@ -147,34 +276,81 @@ func (h HostnameError) Error() string {
 	return "x509: certificate is valid for " + valid + ", not " + h.Host
 }
 
+// HostnamesError results when a certificate is not valid for some of the
+// names checked by VerifyHostnames, or given in VerifyOptions.DNSNames.
+type HostnamesError struct {
+	// Errors holds a HostnameError for each name the certificate is not
+	// valid for, in the order the names were given. The certificate is
+	// valid for the other names.
+	Errors []HostnameError
+}
+
+func (h HostnamesError) Error() string {
+	if len(h.Errors) == 1 {
+		return h.Errors[0].Error()
+	}
+	hosts := make([]string, len(h.Errors))
+	for i, e := range h.Errors {
+		hosts[i] = e.Host
+	}
+	return "x509: certificate is not valid for " + strings.Join(hosts, ", ")
+}
+
 // UnknownAuthorityError results when the certificate issuer is unknown
 type UnknownAuthorityError struct {
 	Cert *Certificate
-	// hintErr contains an error that may be helpful in determining why an
-	// authority wasn't found.
-	hintErr error
-	// hintCert contains a possible authority certificate that was rejected
-	// because of the error in hintErr.
-	hintCert *Certificate
+	// Issuer is the subject that an issuer of Cert must have, in the form
+	// of pkix.Name.String, and AuthorityKeyId, if not empty, the hex encoded
+	// subject key identifier it must have. They can be used to tell users
+	// which CA certificate to install. They are strings so that the error
+	// stays comparable.
+	Issuer         string
+	AuthorityKeyId string
+	// HintErr contains an error that may be helpful in determining why an
+	// authority wasn't found. It is returned by Unwrap.
+	HintErr error
+	// HintCert contains a possible authority certificate that was rejected
+	// because of the error in HintErr.
+	HintCert *Certificate
+}
+
+// unknownAuthorityError returns the UnknownAuthorityError for c, with the
+// given hints, which may be nil.
+func unknownAuthorityError(c *Certificate, hintErr error, hintCert *Certificate) UnknownAuthorityError {
+	return UnknownAuthorityError{
+		Cert:           c,
+		Issuer:         c.Issuer.String(),
+		AuthorityKeyId: hex.EncodeToString(c.AuthorityKeyId),
+		HintErr:        hintErr,
+		HintCert:       hintCert,
+	}
 }
 
 func (e UnknownAuthorityError) Error() string {
 	s := "x509: certificate signed by unknown authority"
-	if e.hintErr != nil {
-		certName := e.hintCert.Subject.CommonName
+	if e.HintErr != nil {
+		certName := e.HintCert.Subject.CommonName
 		if len(certName) == 0 {
-			if len(e.hintCert.Subject.Organization) > 0 {
-				certName = e.hintCert.Subject.Organization[0]
+			if len(e.HintCert.Subject.Organization) > 0 {
+				certName = e.HintCert.Subject.Organization[0]
 			} else {
-				certName = "serial:" + e.hintCert.SerialNumber.String()
+				certName = "serial:" + e.HintCert.SerialNumber.String()
 			}
 		}
-		s += fmt.Sprintf(" (possibly because of %q while trying to verify candidate authority certificate %q)", e.hintErr, certName)
+		s += fmt.Sprintf(" (possibly because of %q while trying to verify candidate authority certificate %q)", e.HintErr, certName)
 	}
 	return s
 }
 
-// SystemRootsError results when we fail to load the system root certificates.
+// Unwrap returns HintErr, which is nil if there is no hint.
+func (e UnknownAuthorityError) Unwrap() error {
+	return e.HintErr
+}
+
+// SystemRootsError results when we fail to load the system root certificates,
+// or when the system has none. Err describes the underlying problem, such as
+// an *os.PathError for a root file or directory that could not be read, or
+// an error of the platform root store API.
 type SystemRootsError struct {
 	Err error
 }
@@ -187,23 +363,59 @@ func (se SystemRootsError) Error() string {
 	return msg
 }
 
+// Unwrap returns se.Err, so that errors.Is and errors.As can inspect it, for
+// example with os.ErrPermission.
+func (se SystemRootsError) Unwrap() error {
+	return se.Err
+}
+
 // errNotParsed is returned when a certificate without ASN.1 contents is
 // verified. Platform-specific verification needs the ASN.1 contents.
 var errNotParsed = errors.New("x509: missing ASN.1 contents; use ParseCertificate")
 
+var errTargetIsCAWithDNSName = errors.New("x509: VerifyOptions.TargetIsCA is set with DNSName or DNSNames; CA certificates are not checked against hostnames")
+
 // VerifyOptions contains parameters for Certificate.Verify.
 type VerifyOptions struct {
 	// DNSName, if set, is checked against the leaf certificate with
 	// Certificate.VerifyHostname.
 	DNSName string
 
+	// DNSNames, if not empty, are checked against the leaf certificate
+	// with Certificate.VerifyHostnames, in addition to DNSName.
+	DNSNames []string
+
+	// TargetIsCA makes the certificate being verified a CA certificate, such
+	// as an intermediate about to be installed, rather than an end-entity
+	// certificate. It must then have a basic constraints extension
+	// asserting cA and, if it has a key usage extension, KeyUsageCertSign.
+	// Its Common Name is never considered a hostname, so name constraints
+	// of its issuers are checked against its names as for any leaf, without
+	// NameConstraintsWithoutSANs errors. It counts as an intermediate for
+	// the MaxPathLen of its issuers. DNSName and DNSNames must be empty.
+	// On Windows, where the system roots are only available to the
+	// platform verifier, which can't verify CA certificates, Roots must be
+	// set.
+	TargetIsCA bool
+
 	// Intermediates is an optional pool of certificates that are not trust
 	// anchors, but can be used to form a chain from the leaf certificate to a
 	// root certificate.
 	Intermediates *CertPool
 	// Roots is the set of trusted root certificates the leaf certificate needs
 	// to chain up to. If nil, the system roots or the platform verifier are used.
+	// Chains built by the platform verifier are still checked against the
+	// chain policy options, such as PinnedKeys, MaxChainLength and
+	// CertificatePolicies.
+	// Certificates added with trust attributes, by AddCertWithTrust or from
+	// "TRUSTED CERTIFICATE" PEM blocks, only anchor chains for the usages
+	// those attributes allow.
 	Roots *CertPool
+	// TrustedIntermediates is an optional pool of certificates that are used
+	// like Intermediates, but that are also accepted as the last certificate
+	// of a chain, without a root, if no chain to Roots can be built. This is
+	// similar to OpenSSL's X509_V_FLAG_PARTIAL_CHAIN.
+	TrustedIntermediates *CertPool
 
 	// CurrentTime is used to check the validity of all certificates in the
 	// chain. If zero, the current time is used.
@@ -218,12 +430,344 @@ type VerifyOptions struct {
 	// (This matches the Windows CryptoAPI behavior, but not the spec.)
 	KeyUsages []ExtKeyUsage
 
+	// RequireAllKeyUsages makes the chain required to allow every usage of
+	// KeyUsages, instead of any of them, for example both
+	// ExtKeyUsageServerAuth and ExtKeyUsageClientAuth for the certificates
+	// of a peer-to-peer mesh. Each usage must be nested by the chain on its
+	// own. ExtKeyUsageAny then requires nothing, rather than accepting any
+	// chain, so KeyUsages holding only ExtKeyUsageAny still accepts any
+	// usage. Trust attributes, as set by AddCertWithTrust, must also allow
+	// every usage. It is ignored by the platform verifier on Windows.
+	RequireAllKeyUsages bool
+
+	// CertificatePolicies, if not empty, is the set of certificate policies
+	// that are acceptable, in the policy domain of the root, like the
+	// user-initial-policy-set of RFC 5280, Section 6.1.1. The leaf
+	// certificate is required to assert a policy that maps, through the
+	// policy mappings of the intermediates, to one of them, and every
+	// intermediate to assert it too. The anyPolicy identifier, 2.5.29.32.0,
+	// matches any policy. Certificates with critical policy mappings are
+	// only accepted when it is set.
+	//
+	// Candidate intermediates that don't assert such a policy are pruned
+	// while building chains, which keeps chain building tractable in meshes
+	// of cross-certified CAs, such as bridge PKIs.
+	CertificatePolicies []OID
+
 	// MaxConstraintComparisions is the maximum number of comparisons to
 	// perform when checking a given certificate's name constraints. If
 	// zero, a sensible default is used. This limit prevents pathological
 	// certificates from consuming excessive amounts of CPU time when
 	// validating.
 	MaxConstraintComparisions int
+
+	// NormalizeDirectoryNames makes directoryName constraints compare
+	// attribute values with the caseIgnoreMatch rules of RFC 4518, ignoring
+	// differences in case and in leading, trailing and repeated spaces,
+	// rather than requiring them to be equal. CAs often vary the case of
+	// attributes such as organization names between certificates, which
+	// otherwise breaks chains with such constraints.
+	NormalizeDirectoryNames bool
+
+	// LegacyCommonName selects whether the Common Name of a leaf without
+	// Subject Alternative Names is matched against DNSName, in which case a
+	// CA with name constraints rejects it with NameConstraintsWithoutSANs.
+	// Setting it keeps a verification independent of the GODEBUG setting
+	// that VerifyHostname follows, so libraries don't need to change global
+	// state. Like other legacy algorithms, SHA-1 signatures can be rejected
+	// per verification with DisallowedSignatureAlgorithms.
+	LegacyCommonName CommonNameMode
+
+	// IPv4Mapped selects how IPv4-mapped IPv6 addresses in DNSName, IP
+	// SANs and IP name constraints relate to IPv4 addresses.
+	IPv4Mapped IPv4MappedMode
+
+	// NormalizeDNSName, if not nil, is applied to DNSName, to the entries of
+	// DNSNames and to the DNS names of the leaf, including its Common Name
+	// if it is taken as a hostname, before they are matched. It lets callers
+	// implement a naming policy in one place, for example ignoring trailing
+	// dots or completing the single-label names of a search domain. It is
+	// not applied to IP addresses or to the names checked against name
+	// constraints.
+	NormalizeDNSName func(string) string
+
+	// ValidityNesting selects whether the validity period of each
+	// certificate must be within that of its issuer.
+	ValidityNesting ValidityNestingMode
+
+	// MaxChainLength, if positive, is the maximum number of certificates in
+	// a chain, including the leaf and the root.
+	MaxChainLength int
+
+	// ClockSkew is the tolerance applied when checking validity periods:
+	// every certificate is treated as valid from ClockSkew before its
+	// NotBefore until ClockSkew after its NotAfter.
+	ClockSkew time.Duration
+
+	// MinRSAKeySize, if positive, is the minimum size in bits of the RSA
+	// keys of all certificates in a chain.
+	MinRSAKeySize int
+
+	// DisallowedSignatureAlgorithms lists signature algorithms that are not
+	// accepted for any certificate in a chain, other than the root.
+	DisallowedSignatureAlgorithms []SignatureAlgorithm
+
+	// PinnedKeys, if not empty, lists SHA-256 hashes of DER encoded
+	// SubjectPublicKeyInfo structures. Every chain must contain a
+	// certificate with one of these keys.
+	PinnedKeys [][]byte
+
+	// BlockedKeys lists SHA-256 hashes of DER encoded SubjectPublicKeyInfo
+	// structures. Chains containing a certificate with one of these keys
+	// are rejected.
+	BlockedKeys [][]byte
+
+	// CTRequirements lists Certificate Transparency requirements, each of
+	// which every chain it applies to must meet. The SCTs of the leaf are
+	// taken from its embedded SCT list extension and from SCTs. Neither
+	// their signatures nor their log IDs are checked, so the requirements
+	// catch certificates that were not logged, but not forged SCTs.
+	CTRequirements []CTRequirement
+
+	// SCTs are additional SCTs for the leaf, in the serialization of
+	// RFC 6962, Section 3.2, as delivered in a TLS handshake or an OCSP
+	// response.
+	SCTs [][]byte
+
+	// StrictECDSASignatures rejects chains in which an ECDSA signature is
+	// not strictly DER encoded, as checked by ValidateECDSASignature.
+	StrictECDSASignatures bool
+
+	// RequireLowS is like StrictECDSASignatures, but additionally requires
+	// the S value of every ECDSA signature to be at most half the curve
+	// order.
+	RequireLowS bool
+
+	// StrictKeyUsageEncoding rejects chains in which the key usage extension
+	// of a certificate, including the root, is not DER encoded, for example
+	// because it has trailing zero bits. Such certificates are accepted by
+	// default, since some CAs and HSMs produce them.
+	StrictKeyUsageEncoding bool
+
+	// StrictBasicConstraints requires roots, like intermediates, to have a
+	// basic constraints extension asserting cA. By default, roots are only
+	// required not to deny it, so that version 1 roots, which can't have
+	// extensions, are accepted.
+	StrictBasicConstraints bool
+
+	// OCSPStaples, if not nil, holds the OCSP responses stapled by the
+	// peer, as parsed by ParseOCSPStaples. Each certificate of a chain but
+	// the root that a response covers is checked against it: the response
+	// must be signed by the issuer of the certificate in that chain, or by
+	// a delegated responder accepted by VerifyOCSPResponder, and current,
+	// and the certificate must be neither revoked nor unknown to the
+	// responder. The chains that fail are dropped, as with CheckRevocation,
+	// and revoked certificates are reported with a CertificateInvalidError
+	// with Reason Revoked.
+	OCSPStaples *OCSPStaples
+
+	// RequireOCSPStaple additionally drops the chains whose leaf is not
+	// covered by a response in OCSPStaples, as required for certificates
+	// with the OCSP Must-Staple TLS feature, RFC 7633.
+	RequireOCSPStaple bool
+
+	// CheckRevocation, if not nil, is called with each chain that Verify
+	// would otherwise return, including those built by the platform
+	// verifier, and the chains for which it returns an error are dropped.
+	// If no chain is left, Verify returns the error for the first one. It
+	// is called last, after the OCSPStaples checks, so that revocation
+	// information, which may have to be fetched, is only looked up for
+	// chains that are valid otherwise. See
+	// VerifyCRL, CRLRevocationStatus, OCSPStaples and RevocationCache for
+	// building blocks.
+	CheckRevocation func(chain []*Certificate) error
+
+	// Trace, if not nil, receives a line of text for every step of chain
+	// building: each candidate issuer considered, signature checked, name
+	// constraint evaluated and chain accepted or rejected. It is meant for
+	// diagnosing why Verify reaches a different verdict than another
+	// implementation, and the format may change. Certificates are
+	// identified by their subject and the start of the SHA-256 hash of
+	// their DER encoding.
+	Trace io.Writer
+
+	// Stats, if not nil, accumulates the signature checks performed while
+	// building chains.
+	Stats *VerifyStats
+
+	// Warnings, if not nil, is called when Verify succeeds with each
+	// problem of the returned chains that doesn't make them invalid, but is
+	// worth monitoring, such as a certificate about to expire. A
+	// certificate in several chains is reported once per kind of warning.
+	Warnings func(VerifyWarning)
+
+	// ExpiryWarning is how long before its expiry a certificate of a
+	// chain is reported with WarningExpiresSoon. If zero, it is 30 days.
+	ExpiryWarning time.Duration
+
+	// ConstantTimeMatching makes the operations of Verify that compare the
+	// certificates being verified with the contents of Roots,
+	// Intermediates and TrustedIntermediates, and the key hashes of a chain
+	// with PinnedKeys and BlockedKeys, run in time that does not depend on
+	// how much of the compared values match. It is meant for private PKIs
+	// in which the set of accepted identities is itself sensitive.
+	//
+	// Pool lookups then scan every certificate of the pool instead of
+	// using an index, which is slower for large pools. The lengths of the
+	// compared values, the sizes of the pools and whether a match was found
+	// are not hidden, and neither is the work done after a match, such as
+	// signature checks. Certificate parsing, hostname matching and name
+	// constraints are never constant-time.
+	ConstantTimeMatching bool
+}
+
+// defaultMaxConstraintComparisons is used when
+// VerifyOptions.MaxConstraintComparisions is zero.
+const defaultMaxConstraintComparisons = 250000
+
+// NewVerifyOptions returns VerifyOptions that behave like the zero value,
+// but with the defaults spelled out: an empty Intermediates pool, to which
+// the intermediates sent by a peer can be added, KeyUsages set to
+// ExtKeyUsageServerAuth, and the default MaxConstraintComparisions. Roots is
+// nil, so the system roots are used, and CurrentTime is zero, so the current
+// time is used.
+func NewVerifyOptions() VerifyOptions {
+	return VerifyOptions{
+		Intermediates:             NewCertPool(),
+		KeyUsages:                 []ExtKeyUsage{ExtKeyUsageServerAuth},
+		MaxConstraintComparisions: defaultMaxConstraintComparisons,
+	}
+}
+
+// Validate reports common mistakes in opts, which Verify would otherwise
+// silently accept, with unexpected results. It returns an error describing
+// the first one found:
+//
+//  - CurrentTime set to the Unix epoch, usually meant as the current time,
+//    which is the zero time.Time instead;
+//  - a DNSName or DNSNames entry that is a URL or has a port, which never
+//    matches, or any entry if TargetIsCA is set;
+//  - negative limits, unknown extended key usages, CommonNameMode,
+//    IPv4MappedMode and ValidityNestingMode values, and PinnedKeys or
+//    BlockedKeys entries that are not SHA-256 hashes.
+//
+// Validate doesn't check whether Verify can succeed.
+func (opts VerifyOptions) Validate() error {
+	if !opts.CurrentTime.IsZero() && opts.CurrentTime.Unix() == 0 {
+		return errors.New("x509: VerifyOptions.CurrentTime is the Unix epoch; leave it zero to use the current time")
+	}
+
+	isHost := func(host string) bool {
+		return !strings.Contains(host, "/") &&
+			(!strings.Contains(host, ":") || net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")) != nil)
+	}
+	if opts.TargetIsCA && (len(opts.DNSName) > 0 || len(opts.DNSNames) > 0) {
+		return errTargetIsCAWithDNSName
+	}
+	if !isHost(opts.DNSName) {
+		return fmt.Errorf("x509: VerifyOptions.DNSName %q is not a host name or IP address", opts.DNSName)
+	}
+	for _, host := range opts.DNSNames {
+		if !isHost(host) {
+			return fmt.Errorf("x509: VerifyOptions.DNSNames entry %q is not a host name or IP address", host)
+		}
+	}
+
+	switch {
+	case opts.MaxConstraintComparisions < 0:
+		return errors.New("x509: negative VerifyOptions.MaxConstraintComparisions")
+	case opts.MaxChainLength < 0:
+		return errors.New("x509: negative VerifyOptions.MaxChainLength")
+	case opts.MinRSAKeySize < 0:
+		return errors.New("x509: negative VerifyOptions.MinRSAKeySize")
+	case opts.ClockSkew < 0:
+		return errors.New("x509: negative VerifyOptions.ClockSkew")
+	case opts.ExpiryWarning < 0:
+		return errors.New("x509: negative VerifyOptions.ExpiryWarning")
+	case opts.LegacyCommonName < CommonNameDefault || opts.LegacyCommonName > CommonNameAsHostname:
+		return fmt.Errorf("x509: unknown VerifyOptions.LegacyCommonName %d", opts.LegacyCommonName)
+	case opts.IPv4Mapped < IPv4MappedDefault || opts.IPv4Mapped > IPv4MappedDistinct:
+		return fmt.Errorf("x509: unknown VerifyOptions.IPv4Mapped %d", opts.IPv4Mapped)
+	case opts.ValidityNesting < ValidityNestingIgnored || opts.ValidityNesting > ValidityNestingEnforced:
+		return fmt.Errorf("x509: unknown VerifyOptions.ValidityNesting %d", opts.ValidityNesting)
+	}
+	for _, usage := range opts.KeyUsages {
+		if _, ok := oidFromExtKeyUsage(usage); !ok {
+			return fmt.Errorf("x509: unknown extended key usage %d in VerifyOptions.KeyUsages", usage)
+		}
+	}
+	for _, keys := range [][][]byte{opts.PinnedKeys, opts.BlockedKeys} {
+		for _, h := range keys {
+			if len(h) != sha256.Size {
+				return fmt.Errorf("x509: %d-byte key hash in VerifyOptions.PinnedKeys or BlockedKeys, want SHA-256", len(h))
+			}
+		}
+	}
+	for _, r := range opts.CTRequirements {
+		if r.MinSCTs < 0 {
+			return errors.New("x509: negative MinSCTs in VerifyOptions.CTRequirements")
+		}
+	}
+	return nil
+}
+
+// potentialParents returns the indexes of the certificates in pool which
+// might have signed c, honoring ConstantTimeMatching.
+func (opts *VerifyOptions) potentialParents(pool *CertPool, c *Certificate) []int {
+	if opts.ConstantTimeMatching {
+		return pool.findPotentialParentsConstantTime(c)
+	}
+	return pool.findPotentialParents(c)
+}
+
+// poolContains reports whether pool contains c, honoring ConstantTimeMatching.
+func (opts *VerifyOptions) poolContains(pool *CertPool, c *Certificate) bool {
+	if opts.ConstantTimeMatching {
+		return pool.containsConstantTime(c)
+	}
+	return pool.contains(c)
+}
+
+// equalBytes compares a and b, in constant time if ConstantTimeMatching is set.
+func (opts *VerifyOptions) equalBytes(a, b []byte) bool {
+	if opts.ConstantTimeMatching {
+		return subtle.ConstantTimeCompare(a, b) == 1
+	}
+	return bytes.Equal(a, b)
+}
+
+// tracef writes a line to opts.Trace, if set. Certificate arguments are
+// replaced by a short description.
+func (opts *VerifyOptions) tracef(format string, args ...interface{}) {
+	if opts.Trace == nil {
+		return
+	}
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *Certificate:
+			args[i] = traceCertName(arg)
+		case []*Certificate:
+			names := make([]string, len(arg))
+			for j, c := range arg {
+				names[j] = traceCertName(c)
+			}
+			args[i] = strings.Join(names, " -> ")
+		}
+	}
+	fmt.Fprintf(opts.Trace, "x509: "+format+"\n", args...)
+}
+
+func traceCertName(c *Certificate) string {
+	h := sha256.Sum256(c.Raw)
+	return fmt.Sprintf("%q [%x]", c.Subject.String(), h[:4])
+}
+
+// traceErr formats the result of a step for tracef.
+func traceErr(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
 }
 
 const (
@@ -474,6 +1018,26 @@ func matchIPConstraint(ip net.IP, constraint *net.IPNet) (bool, error) {
 	return true, nil
 }
 
+// matchIPConstraintUnmapped is like matchIPConstraint, but compares
+// IPv4-mapped addresses and ranges as IPv4 ones.
+func matchIPConstraintUnmapped(ip net.IP, constraint *net.IPNet) (bool, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if len(constraint.IP) == net.IPv6len && len(constraint.Mask) == net.IPv6len && constraint.IP.To4() != nil {
+		if ones, _ := constraint.Mask[:12].Size(); ones == 96 {
+			constraint = &net.IPNet{IP: constraint.IP[12:], Mask: constraint.Mask[12:]}
+		}
+	}
+	return matchIPConstraint(ip, constraint)
+}
+
+// isIPv4Mapped reports whether ip, as encoded in a certificate, is an
+// IPv4-mapped IPv6 address.
+func isIPv4Mapped(ip net.IP) bool {
+	return len(ip) == net.IPv6len && ip.To4() != nil
+}
+
 func matchDomainConstraint(domain, constraint string) (bool, error) {
 	// The meaning of zero length constraints is not specified, but this
 	// code follows NSS and accepts them as matching everything.
@@ -576,11 +1140,37 @@ func (c *Certificate) checkNameConstraints(count *int,
 	return nil
 }
 
+// notAuthorizedToSign returns the error for c being used as an issuer, with
+// the reason it can't be one.
+func notAuthorizedToSign(c *Certificate) CertificateInvalidError {
+	var detail string
+	switch {
+	case c.BasicConstraintsState() == BasicConstraintsAbsent && c.Version < 3:
+		detail = fmt.Sprintf("version %d certificate has no basic constraints extension", c.Version)
+	case c.BasicConstraintsState() == BasicConstraintsAbsent:
+		detail = "basic constraints extension is missing"
+	case c.BasicConstraintsState() == BasicConstraintsNotCA:
+		detail = "basic constraints extension does not assert cA"
+	case c.KeyUsage != 0 && c.KeyUsage&KeyUsageCertSign == 0:
+		detail = "key usage does not include certificate signing"
+	}
+	return CertificateInvalidError{c, NotAuthorizedToSign, detail}
+}
+
 // isValid performs validity checks on c given that it is a candidate to append
 // to the chain in currentChain.
 func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *VerifyOptions) error {
-	if len(c.UnhandledCriticalExtensions) > 0 {
-		return UnhandledCriticalExtension{}
+	for _, id := range c.UnhandledCriticalExtensions {
+		// Policy mappings are handled by the policy processing of
+		// CertificatePolicies.
+		if !id.Equal(oidExtensionPolicyMappings) || len(opts.CertificatePolicies) == 0 {
+			return UnhandledCriticalExtension{}
+		}
+	}
+	for _, e := range c.OIDExtensions {
+		if e.Critical {
+			return UnhandledCriticalExtension{}
+		}
 	}
 
 	if len(currentChain) > 0 {
@@ -594,13 +1184,13 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 	if now.IsZero() {
 		now = time.Now()
 	}
-	if now.Before(c.NotBefore) {
+	if now.Before(c.NotBefore.Add(-opts.ClockSkew)) {
 		return CertificateInvalidError{
 			Cert:   c,
 			Reason: Expired,
 			Detail: fmt.Sprintf("current time %s is before %s", now.Format(time.RFC3339), c.NotBefore.Format(time.RFC3339)),
 		}
-	} else if now.After(c.NotAfter) {
+	} else if now.After(c.NotAfter.Add(opts.ClockSkew)) {
 		return CertificateInvalidError{
 			Cert:   c,
 			Reason: Expired,
@@ -610,7 +1200,7 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 
 	maxConstraintComparisons := opts.MaxConstraintComparisions
 	if maxConstraintComparisons == 0 {
-		maxConstraintComparisons = 250000
+		maxConstraintComparisons = defaultMaxConstraintComparisons
 	}
 	comparisonCount := 0
 
@@ -623,78 +1213,15 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 	}
 
 	checkNameConstraints := (certType == intermediateCertificate || certType == rootCertificate) && c.hasNameConstraints()
-	if checkNameConstraints && leaf.commonNameAsHostname() {
+	if checkNameConstraints && !opts.TargetIsCA && leaf.commonNameAsHostname(opts) {
 		// This is the deprecated, legacy case of depending on the commonName as
 		// a hostname. We don't enforce name constraints against the CN, but
 		// VerifyHostname will look for hostnames in there if there are no SANs.
 		// In order to ensure VerifyHostname will not accept an unchecked name,
 		// return an error here.
 		return CertificateInvalidError{c, NameConstraintsWithoutSANs, ""}
-	} else if checkNameConstraints && leaf.hasSANExtension() {
-		err := forEachSAN(leaf.getSANExtension(), func(tag int, data []byte) error {
-			switch tag {
-			case nameTypeEmail:
-				name := string(data)
-				mailbox, ok := parseRFC2821Mailbox(name)
-				if !ok {
-					return fmt.Errorf("x509: cannot parse rfc822Name %q", mailbox)
-				}
-
-				if err := c.checkNameConstraints(&comparisonCount, maxConstraintComparisons, "email address", name, mailbox,
-					func(parsedName, constraint interface{}) (bool, error) {
-						return matchEmailConstraint(parsedName.(rfc2821Mailbox), constraint.(string))
-					}, c.PermittedEmailAddresses, c.ExcludedEmailAddresses); err != nil {
-					return err
-				}
-
-			case nameTypeDNS:
-				name := string(data)
-				if _, ok := domainToReverseLabels(name); !ok {
-					return fmt.Errorf("x509: cannot parse dnsName %q", name)
-				}
-
-				if err := c.checkNameConstraints(&comparisonCount, maxConstraintComparisons, "DNS name", name, name,
-					func(parsedName, constraint interface{}) (bool, error) {
-						return matchDomainConstraint(parsedName.(string), constraint.(string))
-					}, c.PermittedDNSDomains, c.ExcludedDNSDomains); err != nil {
-					return err
-				}
-
-			case nameTypeURI:
-				name := string(data)
-				uri, err := url.Parse(name)
-				if err != nil {
-					return fmt.Errorf("x509: internal error: URI SAN %q failed to parse", name)
-				}
-
-				if err := c.checkNameConstraints(&comparisonCount, maxConstraintComparisons, "URI", name, uri,
-					func(parsedName, constraint interface{}) (bool, error) {
-						return matchURIConstraint(parsedName.(*url.URL), constraint.(string))
-					}, c.PermittedURIDomains, c.ExcludedURIDomains); err != nil {
-					return err
-				}
-
-			case nameTypeIP:
-				ip := net.IP(data)
-				if l := len(ip); l != net.IPv4len && l != net.IPv6len {
-					return fmt.Errorf("x509: internal error: IP SAN %x failed to parse", data)
-				}
-
-				if err := c.checkNameConstraints(&comparisonCount, maxConstraintComparisons, "IP address", ip.String(), ip,
-					func(parsedName, constraint interface{}) (bool, error) {
-						return matchIPConstraint(parsedName.(net.IP), constraint.(*net.IPNet))
-					}, c.PermittedIPRanges, c.ExcludedIPRanges); err != nil {
-					return err
-				}
-
-			default:
-				// Unknown SAN types are ignored.
-			}
-
-			return nil
-		})
-
-		if err != nil {
+	} else if checkNameConstraints {
+		if err := c.NameConstraints().check(c, leaf, &comparisonCount, maxConstraintComparisons, opts, nil); err != nil {
 			return err
 		}
 	}
@@ -716,12 +1243,16 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 	// keyUsage, and a keyUsage containing a flag indicating that the RSA
 	// encryption key could only be used for Diffie-Hellman key agreement.
 
-	if certType == intermediateCertificate && (!c.BasicConstraintsValid || !c.IsCA) {
-		return CertificateInvalidError{c, NotAuthorizedToSign, ""}
+	if (certType == intermediateCertificate || certType == rootCertificate && opts.StrictBasicConstraints) &&
+		c.BasicConstraintsState() != BasicConstraintsCA {
+		return notAuthorizedToSign(c)
 	}
 
 	if c.BasicConstraintsValid && c.MaxPathLen >= 0 {
 		numIntermediates := len(currentChain) - 1
+		if opts.TargetIsCA {
+			numIntermediates++
+		}
 		if numIntermediates > c.MaxPathLen {
 			return CertificateInvalidError{c, TooManyIntermediates, ""}
 		}
@@ -736,13 +1267,17 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 // element of the chain is c and the last element is from opts.Roots.
 //
 // If opts.Roots is nil and system roots are unavailable the returned error
-// will be of type SystemRootsError.
+// will be of type SystemRootsError, on every platform. The system roots are
+// unavailable if they could not be loaded or if none were found, or on
+// Windows if the system root store could not be opened. Other failures,
+// including an untrusted chain, are reported with other error types. See
+// SystemRootsAvailable.
 //
 // Name constraints in the intermediates will be applied to all names claimed
 // in the chain, not just opts.DNSName. Thus it is invalid for a leaf to claim
 // example.com if an intermediate doesn't permit it, even if example.com is not
-// the name being validated. Note that DirectoryName constraints are not
-// supported.
+// the name being validated. DirectoryName constraints apply to the subject
+// of the leaf and to its directoryName subject alternative names.
 //
 // Name constraint validation follows the rules from RFC 5280, with the
 // addition that DNS name constraints may use the leading period format
@@ -756,22 +1291,109 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 //
 // WARNING: this function doesn't do any revocation checking.
 func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
+	return c.verify(opts, nil)
+}
+
+// VerifyChain verifies chain exactly as it is given, without searching for
+// other paths, for protocols where the peer presents the whole chain, such as
+// TLS or the x5c header of JWS. chain starts with the leaf, and each
+// certificate must be issued and signed by the next one. The chain must end
+// in a certificate of opts.Roots or opts.TrustedIntermediates, or in one
+// issued by a certificate of opts.Roots, which is then appended to the
+// returned chain. opts.Intermediates is ignored.
+//
+// The chain is otherwise checked as by Verify, including validity periods,
+// basic constraints, name constraints, key usages, policies and host names.
+// If a certificate of chain other than the last is in opts.Roots, the
+// returned chain ends there.
+//
+// On Windows, where the system roots are only available to the platform
+// verifier, which builds its own chains, opts.Roots must be set.
+func VerifyChain(chain []*Certificate, opts VerifyOptions) ([]*Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("x509: empty certificate chain")
+	}
+	for _, c := range chain {
+		if len(c.Raw) == 0 {
+			return nil, errNotParsed
+		}
+	}
+	opts.Intermediates = nil
+	chains, err := chain[0].verify(opts, chain)
+	if err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// usePlatformVerifier reports whether Verify hands chain building to the
+// platform when Roots is nil. It is a variable for testing.
+var usePlatformVerifier = runtime.GOOS == "windows"
+
+// platformVerify is Certificate.systemVerify. It is a variable for testing.
+var platformVerify = (*Certificate).systemVerify
+
+var (
+	errVerifyChainNeedsRoots = errors.New("x509: VerifyChain requires VerifyOptions.Roots on this platform")
+	errTargetIsCANeedsRoots  = errors.New("x509: VerifyOptions.TargetIsCA requires VerifyOptions.Roots on this platform")
+)
+
+// verify implements Verify. If path is not nil, it is the chain of
+// VerifyChain, starting with c, and chains are only built along it.
+func (c *Certificate) verify(opts VerifyOptions, path []*Certificate) (chains [][]*Certificate, err error) {
 	// Platform-specific verification needs the ASN.1 contents so
 	// this makes the behavior consistent across platforms.
 	if len(c.Raw) == 0 {
 		return nil, errNotParsed
 	}
-	if opts.Intermediates != nil {
-		for _, intermediate := range opts.Intermediates.certs {
-			if len(intermediate.Raw) == 0 {
-				return nil, errNotParsed
+
+	// Use a single snapshot of concurrent pools for the whole verification.
+	opts.Roots = opts.Roots.view()
+	opts.Intermediates = opts.Intermediates.view()
+	opts.TrustedIntermediates = opts.TrustedIntermediates.view()
+
+	for _, pool := range []*CertPool{opts.Intermediates, opts.TrustedIntermediates} {
+		if pool != nil {
+			for _, intermediate := range pool.certs {
+				if len(intermediate.Raw) == 0 {
+					return nil, errNotParsed
+				}
 			}
 		}
 	}
 
 	// Use Windows's own verification and chain building.
-	if opts.Roots == nil && runtime.GOOS == "windows" {
-		return c.systemVerify(&opts)
+	opts.tracef("verifying %v", c)
+
+	if opts.Roots == nil && usePlatformVerifier {
+		if path != nil {
+			return nil, errVerifyChainNeedsRoots
+		}
+		if opts.TargetIsCA {
+			return nil, errTargetIsCANeedsRoots
+		}
+		if systemRootsPool(); systemRootsErr != nil {
+			return nil, SystemRootsError{systemRootsErr}
+		}
+		opts.tracef("using the platform verifier")
+		chains, err = platformVerify(c, &opts)
+		if err == nil && len(opts.DNSNames) > 0 {
+			if err = c.verifyHostnames(opts.DNSNames, &opts); err != nil {
+				chains = nil
+			}
+		}
+		if err == nil {
+			// The platform verifier doesn't know about the policy options,
+			// so apply them to the chains it built.
+			chains, err = opts.filterPlatformChains(chains)
+		}
+		if err == nil {
+			chains, err = opts.checkRevocation(chains)
+		}
+		if err == nil {
+			opts.warnChains(chains)
+		}
+		return chains, err
 	}
 
 	if opts.Roots == nil {
@@ -783,40 +1405,110 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 
 	err = c.isValid(leafCertificate, nil, &opts)
 	if err != nil {
+		opts.tracef("%v rejected: %s", c, err)
 		return
 	}
 
+	if opts.TargetIsCA {
+		if len(opts.DNSName) > 0 || len(opts.DNSNames) > 0 {
+			return nil, errTargetIsCAWithDNSName
+		}
+		if c.BasicConstraintsState() != BasicConstraintsCA || !c.IsCACert() {
+			return nil, notAuthorizedToSign(c)
+		}
+	}
+
 	if len(opts.DNSName) > 0 {
-		err = c.VerifyHostname(opts.DNSName)
+		err = c.verifyHostname(opts.DNSName, &opts)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(opts.DNSNames) > 0 {
+		err = c.verifyHostnames(opts.DNSNames, &opts)
 		if err != nil {
 			return
 		}
 	}
 
 	var candidateChains [][]*Certificate
-	if opts.Roots.contains(c) {
+	if len(path) <= 1 && opts.poolContains(opts.Roots, c) {
 		candidateChains = append(candidateChains, []*Certificate{c})
 	} else {
-		if candidateChains, err = c.buildChains(nil, []*Certificate{c}, nil, &opts); err != nil {
+		var policies *policySet
+		if len(opts.CertificatePolicies) > 0 {
+			if policies = newPolicySet(c.Policies); policies.empty() {
+				return nil, CertificateInvalidError{c, NoValidPolicy, "the certificate asserts no policy"}
+			}
+		}
+		if candidateChains, _, err = c.buildChains(&chainBuilder{opts: &opts, path: path}, []*Certificate{c}, policies); err != nil {
 			return nil, err
 		}
+		candidateChains = preferRootedChains(candidateChains, opts.Roots)
+	}
+
+	var policyErr error
+	n := 0
+	for _, candidate := range candidateChains {
+		if err := opts.checkChainPolicy(candidate); err != nil {
+			opts.tracef("chain %v rejected by policy: %s", candidate, err)
+			if policyErr == nil {
+				policyErr = err
+			}
+			continue
+		}
+		candidateChains[n] = candidate
+		n++
+	}
+	if n == 0 {
+		return nil, policyErr
 	}
+	candidateChains = candidateChains[:n]
 
 	keyUsages := opts.KeyUsages
 	if len(keyUsages) == 0 {
 		keyUsages = []ExtKeyUsage{ExtKeyUsageServerAuth}
 	}
 
+	var trustErr error
+	n = 0
+	for _, candidate := range candidateChains {
+		if err := opts.checkChainTrust(candidate, keyUsages); err != nil {
+			opts.tracef("chain %v rejected by trust attributes: %s", candidate, err)
+			if trustErr == nil {
+				trustErr = err
+			}
+			continue
+		}
+		candidateChains[n] = candidate
+		n++
+	}
+	if n == 0 {
+		return nil, trustErr
+	}
+	candidateChains = candidateChains[:n]
+
 	// If any key usage is acceptable then we're done.
 	for _, usage := range keyUsages {
-		if usage == ExtKeyUsageAny {
+		if usage == ExtKeyUsageAny && !opts.RequireAllKeyUsages {
+			if candidateChains, err = opts.checkRevocation(candidateChains); err != nil {
+				return nil, err
+			}
+			for _, candidate := range candidateChains {
+				opts.tracef("chain %v accepted", candidate)
+			}
+			opts.warnChains(candidateChains)
 			return candidateChains, nil
 		}
 	}
 
 	for _, candidate := range candidateChains {
-		if checkChainForKeyUsage(candidate, keyUsages) {
+		if opts.chainAllowsKeyUsages(candidate, keyUsages) {
+			opts.tracef("chain %v accepted", candidate)
 			chains = append(chains, candidate)
+		} else {
+			opts.tracef("chain %v rejected: incompatible key usage", candidate)
 		}
 	}
 
@@ -824,9 +1516,482 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		return nil, CertificateInvalidError{c, IncompatibleUsage, ""}
 	}
 
+	if chains, err = opts.checkRevocation(chains); err != nil {
+		return nil, err
+	}
+	opts.warnChains(chains)
 	return chains, nil
 }
 
+// checkRevocation returns the chains that pass checkChainRevocation, or the
+// error for the first chain if none does.
+func (opts *VerifyOptions) checkRevocation(chains [][]*Certificate) ([][]*Certificate, error) {
+	if opts.CheckRevocation == nil && opts.OCSPStaples == nil && !opts.RequireOCSPStaple {
+		return chains, nil
+	}
+	var firstErr error
+	var accepted [][]*Certificate
+	for _, chain := range chains {
+		if err := opts.checkChainRevocation(chain); err != nil {
+			opts.tracef("chain %v rejected by revocation check: %s", chain, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		accepted = append(accepted, chain)
+	}
+	if len(accepted) == 0 {
+		return nil, firstErr
+	}
+	return accepted, nil
+}
+
+// checkChainRevocation checks chain against OCSPStaples, then with
+// CheckRevocation.
+func (opts *VerifyOptions) checkChainRevocation(chain []*Certificate) error {
+	if opts.OCSPStaples != nil || opts.RequireOCSPStaple {
+		staples := opts.OCSPStaples
+		if staples == nil {
+			staples = new(OCSPStaples)
+		}
+		if err := staples.checkChain(chain, opts.CurrentTime, opts.RequireOCSPStaple); err != nil {
+			return err
+		}
+	}
+	if opts.CheckRevocation != nil {
+		return opts.CheckRevocation(chain)
+	}
+	return nil
+}
+
+// AnchorSource identifies where the trust anchor of a verified chain, its
+// last certificate, came from.
+type AnchorSource int
+
+const (
+	// AnchorRoots means the anchor is from VerifyOptions.Roots.
+	AnchorRoots AnchorSource = iota
+	// AnchorSystemRoots means the anchor is from the system root pool,
+	// which was used because VerifyOptions.Roots was nil.
+	AnchorSystemRoots
+	// AnchorSystemVerifier means the chain was built by the platform
+	// verifier, which is used on Windows if VerifyOptions.Roots is nil.
+	AnchorSystemVerifier
+	// AnchorLeaf means the leaf itself is trusted, and the chain consists
+	// only of it.
+	AnchorLeaf
+	// AnchorTrustedIntermediate means the chain is a partial chain ending
+	// in a certificate from VerifyOptions.TrustedIntermediates.
+	AnchorTrustedIntermediate
+)
+
+func (s AnchorSource) String() string {
+	switch s {
+	case AnchorRoots:
+		return "roots"
+	case AnchorSystemRoots:
+		return "system roots"
+	case AnchorSystemVerifier:
+		return "system verifier"
+	case AnchorLeaf:
+		return "trusted leaf"
+	case AnchorTrustedIntermediate:
+		return "trusted intermediate"
+	}
+	return "unknown anchor source"
+}
+
+// CertificateSource identifies where a certificate of a verified chain came
+// from.
+type CertificateSource int
+
+const (
+	// SourceLeaf means the certificate is the one being verified.
+	SourceLeaf CertificateSource = iota
+	// SourceRoots means the certificate is from VerifyOptions.Roots.
+	SourceRoots
+	// SourceIntermediates means the certificate is from
+	// VerifyOptions.Intermediates.
+	SourceIntermediates
+	// SourceTrustedIntermediates means the certificate is from
+	// VerifyOptions.TrustedIntermediates.
+	SourceTrustedIntermediates
+	// SourceSystemRoots means the certificate is from the system root pool,
+	// which was used because VerifyOptions.Roots was nil.
+	SourceSystemRoots
+	// SourceSystemVerifier means the certificate was supplied by the
+	// platform verifier, from its own stores or fetched by it.
+	SourceSystemVerifier
+)
+
+func (s CertificateSource) String() string {
+	switch s {
+	case SourceLeaf:
+		return "leaf"
+	case SourceRoots:
+		return "roots"
+	case SourceIntermediates:
+		return "intermediates"
+	case SourceTrustedIntermediates:
+		return "trusted intermediates"
+	case SourceSystemRoots:
+		return "system roots"
+	case SourceSystemVerifier:
+		return "system verifier"
+	}
+	return "unknown certificate source"
+}
+
+// VerifiedChain is a certificate chain returned by VerifyChains.
+type VerifiedChain struct {
+	// Certificates is the chain, starting with the leaf.
+	Certificates []*Certificate
+	// Anchor is where the last certificate of the chain came from.
+	Anchor AnchorSource
+	// Sources is where each certificate of the chain came from, in chain
+	// order.
+	Sources []CertificateSource
+	// Stores names, in chain order, the platform store that each
+	// certificate from SourceSystemRoots or SourceSystemVerifier was found
+	// in, if known, and is "" for the other certificates. On Windows, it
+	// is the system store, "ROOT" or "CA", holding a certificate supplied by
+	// the platform verifier. On macOS, it is the trust settings domain of a
+	// system root, as for AnchorInfo.Store, and on other Unix systems the
+	// file it was read from, as for AnchorInfo.Path. Roots compiled in with
+	// the x509embedroots build tag have the store "embedded".
+	Stores []string
+	// NameConstraints lists, in chain order, the certificates of the chain
+	// whose name constraints were evaluated, and the names of the leaf they
+	// were applied to. It is nil for chains built by the platform verifier.
+	NameConstraints []NameConstraintsEvaluation
+}
+
+// NameConstraintsEvaluation records how the name constraints of one CA
+// certificate were applied to the leaf of a verified chain, for example to
+// produce audit evidence. All the names were permitted, since the chain
+// verified.
+type NameConstraintsEvaluation struct {
+	// Certificate is the CA certificate holding the name constraints.
+	Certificate *Certificate
+	// PermittedSubtrees and ExcludedSubtrees are the number of permitted
+	// and excluded subtrees in the constraints, of all name types.
+	PermittedSubtrees, ExcludedSubtrees int
+	// Names are the names of the leaf that were checked against the
+	// constraints, in the order they were checked.
+	Names []ConstrainedName
+}
+
+// ConstrainedName is a name of a leaf certificate that was checked against
+// name constraints.
+type ConstrainedName struct {
+	// Type is one of "DNS name", "IP address", "email address", "URI" or
+	// "directory name".
+	Type string
+	// Name is the name in its string form.
+	Name string
+}
+
+// VerifyChains is like Verify, but reports for each chain where its trust
+// anchor and its other certificates came from. This lets cross-platform code
+// tell, for example, whether a chain was produced by the platform verifier or
+// by this package, and log the chain in the same form either way. It also
+// reports which name constraints were applied to which names of c.
+func (c *Certificate) VerifyChains(opts VerifyOptions) ([]VerifiedChain, error) {
+	var roots *CertPool
+	source := AnchorRoots
+	switch {
+	case opts.Roots != nil:
+		roots = opts.Roots
+	case runtime.GOOS == "windows":
+		source = AnchorSystemVerifier
+	default:
+		roots = systemRootsPool()
+		source = AnchorSystemRoots
+	}
+
+	chains, err := c.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := make([]VerifiedChain, len(chains))
+	for i, chain := range chains {
+		verified[i] = VerifiedChain{Certificates: chain, Anchor: source}
+		switch last := chain[len(chain)-1]; {
+		case source == AnchorSystemVerifier:
+		case len(chain) == 1:
+			verified[i].Anchor = AnchorLeaf
+		case !roots.contains(last) && opts.TrustedIntermediates.contains(last):
+			verified[i].Anchor = AnchorTrustedIntermediate
+		}
+		if source != AnchorSystemVerifier {
+			verified[i].NameConstraints = evaluateNameConstraints(chain, &opts)
+		}
+		verified[i].Sources, verified[i].Stores = chainSources(chain, roots, source, &opts)
+	}
+	return verified, nil
+}
+
+// chainSources returns the sources and stores of the certificates of chain,
+// for VerifiedChain. The last certificate of a chain built by this package is
+// looked up in the roots first, and the others in the intermediates first.
+func chainSources(chain []*Certificate, roots *CertPool, anchor AnchorSource, opts *VerifyOptions) ([]CertificateSource, []string) {
+	sources := make([]CertificateSource, len(chain))
+	stores := make([]string, len(chain))
+	rootSource := SourceRoots
+	if anchor == AnchorSystemRoots {
+		rootSource = SourceSystemRoots
+	}
+	for i, cert := range chain {
+		if i == 0 {
+			sources[i] = SourceLeaf
+			continue
+		}
+		if anchor == AnchorSystemVerifier {
+			if opts.Intermediates.contains(cert) {
+				sources[i] = SourceIntermediates
+				continue
+			}
+			sources[i] = SourceSystemVerifier
+			if systemStoreOf != nil {
+				stores[i] = systemStoreOf(cert)
+			}
+			continue
+		}
+
+		pools := []struct {
+			pool   *CertPool
+			source CertificateSource
+		}{
+			{opts.Intermediates, SourceIntermediates},
+			{opts.TrustedIntermediates, SourceTrustedIntermediates},
+			{roots, rootSource},
+		}
+		if i == len(chain)-1 {
+			pools[0], pools[2] = pools[2], pools[0]
+		}
+		for _, p := range pools {
+			origin, ok := p.pool.origin(cert)
+			if !ok {
+				continue
+			}
+			sources[i] = p.source
+			if p.source == SourceSystemRoots {
+				stores[i] = origin.store
+				if stores[i] == "" {
+					stores[i] = origin.path
+				}
+			}
+			break
+		}
+	}
+	return sources, stores
+}
+
+// evaluateNameConstraints repeats the name constraint checks that isValid
+// performed on chain, recording the names that each CA was checked against.
+func evaluateNameConstraints(chain []*Certificate, opts *VerifyOptions) []NameConstraintsEvaluation {
+	maxConstraintComparisons := opts.MaxConstraintComparisions
+	if maxConstraintComparisons == 0 {
+		maxConstraintComparisons = defaultMaxConstraintComparisons
+	}
+	// The checks were already traced during verification.
+	quiet := *opts
+	quiet.Trace = nil
+
+	var evaluations []NameConstraintsEvaluation
+	leaf := chain[0]
+	for _, ca := range chain[1:] {
+		if !ca.hasNameConstraints() {
+			continue
+		}
+		nc := ca.NameConstraints()
+		e := NameConstraintsEvaluation{Certificate: ca}
+		e.PermittedSubtrees, e.ExcludedSubtrees = nc.subtrees()
+		count := 0
+		// The chain verified, so the check itself cannot fail.
+		nc.check(ca, leaf, &count, maxConstraintComparisons, &quiet, func(nameType, name string) {
+			e.Names = append(e.Names, ConstrainedName{Type: nameType, Name: name})
+		})
+		evaluations = append(evaluations, e)
+	}
+	return evaluations
+}
+
+// ProgramResult is the outcome of verifying a certificate against the roots
+// of one root program. See VerifyAgainstPrograms.
+type ProgramResult struct {
+	// Chains are the verified chains ending in a root of the program, or in
+	// a certificate from VerifyOptions.TrustedIntermediates.
+	Chains [][]*Certificate
+	// Err is the error that Verify returned for the program, if any.
+	Err error
+}
+
+// VerifyAgainstPrograms verifies c against each of the root pools in
+// programs, keyed by the name of the root program, and returns the result for
+// each of them. It is equivalent to calling Verify once per program with
+// opts.Roots set to its pool, but builds chains only once, against all the
+// roots together, and falls back to a separate Verify call only for programs
+// that none of those chains reach. opts.Roots is ignored.
+//
+// This lets CAs and CDNs check that a certificate is accepted by several
+// root programs, such as those of Mozilla, Apple and Microsoft, at once.
+func (c *Certificate) VerifyAgainstPrograms(opts VerifyOptions, programs map[string]*CertPool) map[string]ProgramResult {
+	all := NewCertPool()
+	for _, pool := range programs {
+		if pool == nil {
+			continue
+		}
+		for _, root := range pool.certificates() {
+			all.AddCert(root)
+		}
+	}
+	opts.Roots = all
+	chains, err := c.Verify(opts)
+
+	results := make(map[string]ProgramResult, len(programs))
+	for name, pool := range programs {
+		if err != nil {
+			results[name] = ProgramResult{Err: err}
+			continue
+		}
+		var programChains [][]*Certificate
+		for _, chain := range chains {
+			last := chain[len(chain)-1]
+			if pool.contains(last) || opts.TrustedIntermediates.contains(last) {
+				programChains = append(programChains, chain)
+			}
+		}
+		if len(programChains) == 0 {
+			// The chains to the roots of other programs may have hidden
+			// longer ones to the roots of this one.
+			programOpts := opts
+			programOpts.Roots = pool
+			if programOpts.Roots == nil {
+				programOpts.Roots = NewCertPool()
+			}
+			programChains, programErr := c.Verify(programOpts)
+			results[name] = ProgramResult{Chains: programChains, Err: programErr}
+			continue
+		}
+		results[name] = ProgramResult{Chains: programChains}
+	}
+	return results
+}
+
+// checkChainPolicy checks chain against the restrictions of opts that apply to
+// complete chains.
+func (opts *VerifyOptions) checkChainPolicy(chain []*Certificate) error {
+	if opts.MaxChainLength > 0 && len(chain) > opts.MaxChainLength {
+		return CertificateInvalidError{chain[0], ChainTooLong, fmt.Sprintf("%d certificates, at most %d allowed", len(chain), opts.MaxChainLength)}
+	}
+
+	pinned := len(opts.PinnedKeys) == 0
+	for i, c := range chain {
+		if pub, ok := c.PublicKey.(*rsa.PublicKey); ok && pub.N.BitLen() < opts.MinRSAKeySize {
+			return CertificateInvalidError{c, InsecureAlgorithm, fmt.Sprintf("%d-bit RSA key", pub.N.BitLen())}
+		}
+		if i < len(chain)-1 {
+			for _, algo := range opts.DisallowedSignatureAlgorithms {
+				if c.SignatureAlgorithm == algo {
+					return CertificateInvalidError{c, InsecureAlgorithm, algo.String() + " signature"}
+				}
+			}
+			if pub, ok := chain[i+1].PublicKey.(*ecdsa.PublicKey); ok && (opts.StrictECDSASignatures || opts.RequireLowS) {
+				if err := ValidateECDSASignature(pub.Curve, c.Signature, opts.RequireLowS); err != nil {
+					return CertificateInvalidError{c, NonCanonicalSignature, strings.TrimPrefix(err.Error(), "x509: ")}
+				}
+			}
+			if opts.ValidityNesting == ValidityNestingEnforced {
+				if detail := validityNestingViolation(c, chain[i+1]); detail != "" {
+					return CertificateInvalidError{c, ValidityNotNested, detail}
+				}
+			}
+		}
+
+		if opts.StrictKeyUsageEncoding && len(c.RawKeyUsage) > 0 {
+			if err := checkKeyUsageEncoding(c.RawKeyUsage); err != nil {
+				return CertificateInvalidError{c, NonCanonicalKeyUsage, strings.TrimPrefix(err.Error(), "x509: ")}
+			}
+		}
+
+		if len(opts.PinnedKeys) == 0 && len(opts.BlockedKeys) == 0 {
+			continue
+		}
+		h := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+		blocked := false
+		for _, b := range opts.BlockedKeys {
+			if opts.equalBytes(h[:], b) {
+				blocked = true
+			}
+		}
+		if blocked {
+			return CertificateInvalidError{c, BlockedKey, ""}
+		}
+		for _, pin := range opts.PinnedKeys {
+			if opts.equalBytes(h[:], pin) {
+				pinned = true
+			}
+		}
+	}
+	if !pinned {
+		return CertificateInvalidError{chain[0], NoPinnedKey, ""}
+	}
+
+	return opts.checkChainSCTs(chain)
+}
+
+// filterPlatformChains returns the chains built by the platform verifier
+// that satisfy checkChainPolicy and opts.CertificatePolicies, or the error
+// of the first chain if none does.
+func (opts *VerifyOptions) filterPlatformChains(chains [][]*Certificate) ([][]*Certificate, error) {
+	var firstErr error
+	var allowed [][]*Certificate
+	for _, chain := range chains {
+		err := opts.checkChainPolicy(chain)
+		if err == nil && len(opts.CertificatePolicies) > 0 {
+			err = checkChainCertificatePolicies(chain, opts.CertificatePolicies)
+		}
+		if err != nil {
+			opts.tracef("chain %v rejected by policy: %s", chain, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		allowed = append(allowed, chain)
+	}
+	if len(allowed) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return allowed, nil
+}
+
+// checkChainCertificatePolicies checks that chain, anchored by its last
+// certificate, is valid for one of the acceptable policies, as buildChains
+// does while building chains.
+func checkChainCertificatePolicies(chain []*Certificate, acceptable []OID) error {
+	if len(chain) == 1 {
+		// A trusted leaf needs no policy.
+		return nil
+	}
+	policies := newPolicySet(chain[0].Policies)
+	if policies.empty() {
+		return CertificateInvalidError{chain[0], NoValidPolicy, "the certificate asserts no policy"}
+	}
+	for _, ca := range chain[1 : len(chain)-1] {
+		if policies = policies.throughCA(ca); policies.empty() {
+			return CertificateInvalidError{ca, NoValidPolicy, "the certificate asserts none of the policies valid for the chain below it"}
+		}
+	}
+	if anchor := chain[len(chain)-1]; !policies.acceptable(acceptable) {
+		return CertificateInvalidError{anchor, NoValidPolicy, "none of the policies valid for the chain is acceptable"}
+	}
+	return nil
+}
+
 func appendToFreshChain(chain []*Certificate, cert *Certificate) []*Certificate {
 	n := make([]*Certificate, len(chain)+1)
 	copy(n, chain)
@@ -838,76 +2003,378 @@ func appendToFreshChain(chain []*Certificate, cert *Certificate) []*Certificate
 // that an invocation of buildChains will (tranistively) make. Most chains are
 // less than 15 certificates long, so this leaves space for multiple chains and
 // for failed checks due to different intermediates having the same Subject.
+// The same certificate and issuer are checked only once, however many paths
+// lead to them.
 const maxChainSignatureChecks = 100
 
-func (c *Certificate) buildChains(cache map[*Certificate][][]*Certificate, currentChain []*Certificate, sigChecks *int, opts *VerifyOptions) (chains [][]*Certificate, err error) {
+// chainBuilder holds the state shared by the buildChains calls of a single
+// Verify call.
+type chainBuilder struct {
+	opts      *VerifyOptions
+	sigChecks int
+
+	// path is the chain of VerifyChain, if any. The issuer of the i-th
+	// certificate of a chain is then path[i+1], or a root for the last one.
+	path []*Certificate
+
+	// signatures records the result of every signature check, since in a
+	// mesh of cross-certified CAs the same certificate and issuer are
+	// reached through many paths.
+	signatures map[[2]*Certificate]error
+
+	// cache records the chains found above a certificate, so that they are
+	// not searched again when the certificate is reached through another
+	// path.
+	cache map[chainCacheKey]cachedChains
+}
+
+// chainCacheKey identifies the search for chains above a certificate. Its
+// result depends on the number of certificates below it, through path length
+// constraints, and on the policies for which they are valid, but on which
+// certificates are below it only when they cause loops.
+type chainCacheKey struct {
+	cert     *Certificate
+	depth    int
+	policies string
+}
+
+type cachedChains struct {
+	suffixes [][]*Certificate // the chains from cert upwards
+	err      error
+}
+
+// sameEntity reports whether a and b certify the same key for the same
+// subject, as the different cross-certificates of a CA do.
+func (opts *VerifyOptions) sameEntity(a, b *Certificate) bool {
+	return opts.equalBytes(a.RawSubject, b.RawSubject) &&
+		opts.equalBytes(a.RawSubjectPublicKeyInfo, b.RawSubjectPublicKeyInfo)
+}
+
+// buildChains returns the chains that extend currentChain, which ends in c,
+// to a root or trusted intermediate. If policies is not nil, it is the set of
+// policies for which currentChain is valid, in the policy domain of the
+// issuer of c, and candidates that don't keep one of them valid are pruned.
+//
+// Candidates for the same subject and key as a certificate already in
+// currentChain are skipped, which breaks the loops formed by
+// cross-certificates. loopAt is the lowest index in currentChain of a
+// certificate that caused a candidate to be skipped, or len(currentChain) if
+// there was none.
+func (c *Certificate) buildChains(b *chainBuilder, currentChain []*Certificate, policies *policySet) (chains [][]*Certificate, loopAt int, err error) {
+	opts := b.opts
+	loopAt = len(currentChain)
 	var (
 		hintErr  error
 		hintCert *Certificate
 	)
 
-	considerCandidate := func(certType int, candidate *Certificate) {
-		for _, cert := range currentChain {
-			if cert.Equal(candidate) {
+	considerCandidate := func(certType int, candidate *Certificate, trusted bool) {
+		for i, cert := range currentChain {
+			if opts.sameEntity(cert, candidate) {
+				if i < loopAt {
+					loopAt = i
+				}
 				return
 			}
 		}
 
-		if sigChecks == nil {
-			sigChecks = new(int)
+		sigKey := [2]*Certificate{c, candidate}
+		sigErr, checked := b.signatures[sigKey]
+		if !checked {
+			b.sigChecks++
+			if b.sigChecks > maxChainSignatureChecks {
+				err = errors.New("x509: signature check attempts limit reached while verifying certificate chain")
+				return
+			}
 		}
-		*sigChecks++
-		if *sigChecks > maxChainSignatureChecks {
-			err = errors.New("x509: signature check attempts limit reached while verifying certificate chain")
-			return
+
+		switch {
+		case certType == rootCertificate:
+			opts.tracef("considering root %v as issuer of %v", candidate, c)
+		case trusted:
+			opts.tracef("considering trusted intermediate %v as issuer of %v", candidate, c)
+		default:
+			opts.tracef("considering intermediate %v as issuer of %v", candidate, c)
 		}
 
-		if err := c.CheckSignatureFrom(candidate); err != nil {
+		if !checked {
+			opts.Stats.addSignatureCheck(candidate.PublicKey)
+			sigErr = c.CheckSignatureFrom(candidate)
+			if b.signatures == nil {
+				b.signatures = make(map[[2]*Certificate]error)
+			}
+			b.signatures[sigKey] = sigErr
+		}
+		if sigErr != nil {
+			if _, ok := sigErr.(ConstraintViolationError); ok {
+				// Report why the candidate can't be an issuer.
+				sigErr = notAuthorizedToSign(candidate)
+			}
+			opts.tracef("signature of %v by %v: %s", c, candidate, traceErr(sigErr))
 			if hintErr == nil {
-				hintErr = err
+				hintErr = sigErr
 				hintCert = candidate
 			}
 			return
 		}
 
+		opts.tracef("signature of %v by %v: ok", c, candidate)
+
 		err = candidate.isValid(certType, currentChain, opts)
 		if err != nil {
+			opts.tracef("%v rejected: %s", candidate, err)
 			return
 		}
 
-		switch certType {
-		case rootCertificate:
-			chains = append(chains, appendToFreshChain(currentChain, candidate))
-		case intermediateCertificate:
-			if cache == nil {
-				cache = make(map[*Certificate][][]*Certificate)
+		if certType == rootCertificate || trusted {
+			if policies != nil && !policies.acceptable(opts.CertificatePolicies) {
+				err = CertificateInvalidError{candidate, NoValidPolicy, "none of the policies valid for the chain is acceptable"}
+				opts.tracef("%v rejected as anchor: %s", candidate, err)
+			} else {
+				chains = append(chains, appendToFreshChain(currentChain, candidate))
 			}
-			childChains, ok := cache[candidate]
-			if !ok {
-				childChains, err = candidate.buildChains(cache, appendToFreshChain(currentChain, candidate), sigChecks, opts)
-				cache[candidate] = childChains
+			if certType == rootCertificate || opts.Intermediates.contains(candidate) {
+				// Chains through a trusted intermediate that is also
+				// in Intermediates are built when it is considered
+				// from there.
+				return
 			}
-			chains = append(chains, childChains...)
 		}
-	}
 
-	for _, rootNum := range opts.Roots.findPotentialParents(c) {
-		considerCandidate(rootCertificate, opts.Roots.certs[rootNum])
+		candidatePolicies := policies
+		if policies != nil {
+			candidatePolicies = policies.throughCA(candidate)
+			if candidatePolicies.empty() {
+				err = CertificateInvalidError{candidate, NoValidPolicy, "the certificate asserts none of the policies valid for the chain below it"}
+				opts.tracef("%v rejected: %s", candidate, err)
+				return
+			}
+		}
+
+		depth := len(currentChain)
+		cacheKey := chainCacheKey{candidate, depth, candidatePolicies.key()}
+		cached, ok := b.cache[cacheKey]
+		if !ok {
+			var childChains [][]*Certificate
+			var childLoopAt int
+			childChains, childLoopAt, cached.err = candidate.buildChains(b, appendToFreshChain(currentChain, candidate), candidatePolicies)
+			for _, chain := range childChains {
+				cached.suffixes = append(cached.suffixes, chain[depth:])
+			}
+			if childLoopAt < loopAt {
+				loopAt = childLoopAt
+			}
+			// If no certificate below candidate caused a loop, the
+			// chains above it are the same whatever the path to it.
+			if childLoopAt >= depth {
+				if b.cache == nil {
+					b.cache = make(map[chainCacheKey]cachedChains)
+				}
+				b.cache[cacheKey] = cached
+			}
+		}
+
+		err = cached.err
+	suffixes:
+		for _, suffix := range cached.suffixes {
+			// A cached chain might go through a certificate below
+			// candidate on this path, if it was found through another.
+			for i, cert := range currentChain {
+				for _, above := range suffix {
+					if opts.sameEntity(cert, above) {
+						if i < loopAt {
+							loopAt = i
+						}
+						continue suffixes
+					}
+				}
+			}
+			chain := make([]*Certificate, 0, depth+len(suffix))
+			chain = append(chain, currentChain...)
+			chains = append(chains, append(chain, suffix...))
+		}
 	}
-	for _, intermediateNum := range opts.Intermediates.findPotentialParents(c) {
-		considerCandidate(intermediateCertificate, opts.Intermediates.certs[intermediateNum])
+
+	considerPoolCandidate := func(certType int, pool *CertPool, i int, trusted bool) {
+		candidate, parseErr := pool.cert(i)
+		if parseErr != nil {
+			err = parseErr
+			return
+		}
+		considerCandidate(certType, candidate, trusted)
+	}
+
+	switch depth := len(currentChain); {
+	case b.path != nil && depth < len(b.path):
+		next := b.path[depth]
+		switch {
+		case opts.poolContains(opts.Roots, next):
+			considerCandidate(rootCertificate, next, false)
+		case opts.poolContains(opts.TrustedIntermediates, next):
+			considerCandidate(intermediateCertificate, next, true)
+		default:
+			considerCandidate(intermediateCertificate, next, false)
+		}
+	case b.path != nil:
+		for _, rootNum := range opts.potentialParents(opts.Roots, c) {
+			considerPoolCandidate(rootCertificate, opts.Roots, rootNum, false)
+		}
+	default:
+		for _, rootNum := range opts.potentialParents(opts.Roots, c) {
+			considerPoolCandidate(rootCertificate, opts.Roots, rootNum, false)
+		}
+		for _, intermediateNum := range opts.potentialParents(opts.Intermediates, c) {
+			considerPoolCandidate(intermediateCertificate, opts.Intermediates, intermediateNum, false)
+		}
+		for _, intermediateNum := range opts.potentialParents(opts.TrustedIntermediates, c) {
+			considerPoolCandidate(intermediateCertificate, opts.TrustedIntermediates, intermediateNum, true)
+		}
 	}
 
 	if len(chains) > 0 {
 		err = nil
 	}
 	if len(chains) == 0 && err == nil {
-		err = UnknownAuthorityError{c, hintErr, hintCert}
+		err = unknownAuthorityError(c, hintErr, hintCert)
 	}
 
 	return
 }
 
+// anyPolicy is the special policy identifier 2.5.29.32.0, which stands for
+// all policies.
+var anyPolicy = OID{[]byte{0x55, 0x1d, 0x20, 0x00}}
+
+// A policySet is the set of policies for which a partial chain is valid, in
+// the policy domain of the issuer of its last certificate. It is a
+// simplification of the valid_policy_tree of RFC 5280, Section 6.1, which
+// ignores policy qualifiers and the policy constraints extension.
+type policySet struct {
+	any      bool
+	policies []OID
+}
+
+func newPolicySet(policies []OID) *policySet {
+	for _, p := range policies {
+		if p.Equal(anyPolicy) {
+			return &policySet{any: true}
+		}
+	}
+	return &policySet{policies: policies}
+}
+
+func (s *policySet) empty() bool {
+	return !s.any && len(s.policies) == 0
+}
+
+func (s *policySet) contains(policy OID) bool {
+	if s.any {
+		return true
+	}
+	for _, p := range s.policies {
+		if p.Equal(policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptable reports whether s contains one of the acceptable policies.
+func (s *policySet) acceptable(acceptable []OID) bool {
+	for _, p := range acceptable {
+		if p.Equal(anyPolicy) || s.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// throughCA returns the set of policies for which the chain is valid after
+// appending the CA certificate ca: those asserted by ca that are in s, after
+// translating them through the policy mappings of ca.
+func (s *policySet) throughCA(ca *Certificate) *policySet {
+	for _, m := range ca.PolicyMappings {
+		// RFC 5280, Section 6.1.4, (a).
+		if m.IssuerDomainPolicy.Equal(anyPolicy) || m.SubjectDomainPolicy.Equal(anyPolicy) {
+			return &policySet{}
+		}
+	}
+	asserted := newPolicySet(ca.Policies)
+	if asserted.any && s.any {
+		return asserted
+	}
+
+	// valid reports whether the issuer domain policy p corresponds to a
+	// policy in s. A mapped policy corresponds to the subject domain
+	// policies it is mapped to, and an unmapped one to itself.
+	valid := func(p OID) bool {
+		mapped := false
+		for _, m := range ca.PolicyMappings {
+			if m.IssuerDomainPolicy.Equal(p) {
+				if s.contains(m.SubjectDomainPolicy) {
+					return true
+				}
+				mapped = true
+			}
+		}
+		return !mapped && s.contains(p)
+	}
+	out := new(policySet)
+	add := func(p OID) {
+		if !out.contains(p) && valid(p) {
+			out.policies = append(out.policies, p)
+		}
+	}
+	if !asserted.any {
+		for _, p := range asserted.policies {
+			add(p)
+		}
+		return out
+	}
+	// If ca asserts anyPolicy, every policy corresponding to one in s is
+	// valid.
+	for _, m := range ca.PolicyMappings {
+		add(m.IssuerDomainPolicy)
+	}
+	for _, p := range s.policies {
+		add(p)
+	}
+	return out
+}
+
+// key returns a string that identifies s, and the empty string for a nil
+// set.
+func (s *policySet) key() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.any:
+		return "any"
+	}
+	ids := make([]string, len(s.policies))
+	for i, p := range s.policies {
+		ids[i] = p.String()
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// preferRootedChains returns the chains that end in a certificate from roots,
+// or all chains if there are none. Chains that end in a member of
+// VerifyOptions.TrustedIntermediates are only used as a last resort.
+func preferRootedChains(chains [][]*Certificate, roots *CertPool) [][]*Certificate {
+	var rooted [][]*Certificate
+	for _, chain := range chains {
+		if roots.contains(chain[len(chain)-1]) {
+			rooted = append(rooted, chain)
+		}
+	}
+	if len(rooted) == 0 {
+		return chains
+	}
+	return rooted
+}
+
 func validHostnamePattern(host string) bool { return validHostname(host, true) }
 func validHostnameInput(host string) bool   { return validHostname(host, false) }
 
@@ -961,13 +2428,23 @@ func validHostname(host string, isPattern bool) bool {
 // commonNameAsHostname reports whether the Common Name field should be
 // considered the hostname that the certificate is valid for. This is a legacy
 // behavior, disabled by default or if the Subject Alt Name extension is present.
+// If opts is nil, the GODEBUG setting applies.
 //
 // It applies the strict validHostname check to the Common Name field, so that
 // certificates without SANs can still be validated against CAs with name
 // constraints if there is no risk the CN would be matched as a hostname.
 // See NameConstraintsWithoutSANs and issue 24151.
-func (c *Certificate) commonNameAsHostname() bool {
-	return !ignoreCN && !c.hasSANExtension() && validHostnamePattern(c.Subject.CommonName)
+func (c *Certificate) commonNameAsHostname(opts *VerifyOptions) bool {
+	ignore := ignoreCN
+	if opts != nil {
+		switch opts.LegacyCommonName {
+		case CommonNameIgnored:
+			ignore = true
+		case CommonNameAsHostname:
+			ignore = false
+		}
+	}
+	return !ignore && !c.hasSANExtension() && validHostnamePattern(c.Subject.CommonName)
 }
 
 func matchExactly(hostA, hostB string) bool {
@@ -1040,15 +2517,23 @@ func toLowerCaseASCII(in string) string {
 // Otherwise it returns an error describing the mismatch.
 //
 // IP addresses can be optionally enclosed in square brackets and are checked
-// against the IPAddresses field. Other names are checked case insensitively
+// against the IPAddresses field. An IPv4 address and the IPv4-mapped IPv6
+// address of the same value match each other; Verify can be told otherwise
+// with VerifyOptions.IPv4Mapped. IP SANs are never wildcards: 0.0.0.0 and ::
+// only match themselves. Other names are checked case insensitively
 // against the DNSNames field. If the names are valid hostnames, the certificate
 // fields can have a wildcard as the left-most label.
 //
 // The legacy Common Name field is ignored unless it's a valid hostname, the
 // certificate doesn't have any Subject Alternative Names, and the GODEBUG
-// environment variable is set to "x509ignoreCN=0". Support for Common Name is
-// deprecated will be entirely removed in the future.
+// environment variable is set to "x509ignoreCN=0". Verify can instead be told
+// per verification with VerifyOptions.LegacyCommonName. Support for Common
+// Name is deprecated will be entirely removed in the future.
 func (c *Certificate) VerifyHostname(h string) error {
+	return c.verifyHostname(h, nil)
+}
+
+func (c *Certificate) verifyHostname(h string, opts *VerifyOptions) error {
 	// IP addresses may be written in [ ].
 	candidateIP := h
 	if len(h) >= 3 && h[0] == '[' && h[len(h)-1] == ']' {
@@ -1057,8 +2542,10 @@ func (c *Certificate) VerifyHostname(h string) error {
 	if ip := net.ParseIP(candidateIP); ip != nil {
 		// We only match IP addresses against IP SANs.
 		// See RFC 6125, Appendix B.2.
+		distinct := opts != nil && opts.IPv4Mapped == IPv4MappedDistinct
+		mapped := strings.Contains(candidateIP, ":") && ip.To4() != nil
 		for _, candidate := range c.IPAddresses {
-			if ip.Equal(candidate) {
+			if ip.Equal(candidate) && (!distinct || mapped == isIPv4Mapped(candidate)) {
 				return nil
 			}
 		}
@@ -1066,11 +2553,20 @@ func (c *Certificate) VerifyHostname(h string) error {
 	}
 
 	names := c.DNSNames
-	if c.commonNameAsHostname() {
+	if c.commonNameAsHostname(opts) {
 		names = []string{c.Subject.CommonName}
 	}
 
-	candidateName := toLowerCaseASCII(h) // Save allocations inside the loop.
+	candidateName := h
+	if opts != nil && opts.NormalizeDNSName != nil {
+		candidateName = opts.NormalizeDNSName(h)
+		normalized := make([]string, len(names))
+		for i, name := range names {
+			normalized[i] = opts.NormalizeDNSName(name)
+		}
+		names = normalized
+	}
+	candidateName = toLowerCaseASCII(candidateName) // Save allocations inside the loop.
 	validCandidateName := validHostnameInput(candidateName)
 
 	for _, match := range names {
@@ -1093,6 +2589,41 @@ func (c *Certificate) VerifyHostname(h string) error {
 	return HostnameError{c, h}
 }
 
+// VerifyHostnames is like VerifyHostname, but checks every name of names, for
+// example all the server names a virtual host is configured with. It
+// returns nil if c is valid for all of them, and otherwise a HostnamesError
+// listing those it is not valid for.
+func (c *Certificate) VerifyHostnames(names []string) error {
+	return c.verifyHostnames(names, nil)
+}
+
+func (c *Certificate) verifyHostnames(names []string, opts *VerifyOptions) error {
+	var errs []HostnameError
+	for _, name := range names {
+		if err := c.verifyHostname(name, opts); err != nil {
+			errs = append(errs, err.(HostnameError))
+		}
+	}
+	if len(errs) > 0 {
+		return HostnamesError{errs}
+	}
+	return nil
+}
+
+// chainAllowsKeyUsages reports whether chain allows keyUsages, any of them
+// or, if RequireAllKeyUsages is set, all of them.
+func (opts *VerifyOptions) chainAllowsKeyUsages(chain []*Certificate, keyUsages []ExtKeyUsage) bool {
+	if !opts.RequireAllKeyUsages {
+		return checkChainForKeyUsage(chain, keyUsages)
+	}
+	for _, usage := range keyUsages {
+		if usage != ExtKeyUsageAny && !checkChainForKeyUsage(chain, []ExtKeyUsage{usage}) {
+			return false
+		}
+	}
+	return true
+}
+
 func checkChainForKeyUsage(chain []*Certificate, keyUsages []ExtKeyUsage) bool {
 	usages := make([]ExtKeyUsage, len(keyUsages))
 	copy(usages, keyUsages)
@@ -1110,7 +2641,7 @@ func checkChainForKeyUsage(chain []*Certificate, keyUsages []ExtKeyUsage) bool {
 NextCert:
 	for i := len(chain) - 1; i >= 0; i-- {
 		cert := chain[i]
-		if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsageOIDs) == 0 {
 			// The certificate doesn't have any extended key usage specified.
 			continue
 		}
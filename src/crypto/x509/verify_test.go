@@ -5,16 +5,23 @@
 package x509
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -1816,8 +1823,8 @@ func TestUnknownAuthorityError(t *testing.T) {
 		}
 		uae := &UnknownAuthorityError{
 			Cert:     c,
-			hintErr:  fmt.Errorf("empty"),
-			hintCert: c,
+			HintErr:  fmt.Errorf("empty"),
+			HintCert: c,
 		}
 		actual := uae.Error()
 		if actual != tt.expected {
@@ -1826,6 +1833,46 @@ func TestUnknownAuthorityError(t *testing.T) {
 	}
 }
 
+func TestUnknownAuthorityErrorFields(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another root with the same name, but a different key.
+	impostor, _, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(roots *CertPool) UnknownAuthorityError {
+		_, err := leaf.Verify(VerifyOptions{Roots: roots})
+		var uae UnknownAuthorityError
+		if !errors.As(err, &uae) {
+			t.Fatalf("got error %v, want UnknownAuthorityError", err)
+		}
+		if uae.Cert != leaf || uae.Issuer != "CN=Root" || uae.AuthorityKeyId != hex.EncodeToString(root.SubjectKeyId) {
+			t.Errorf("unexpected fields %+v", uae)
+		}
+		if errors.Unwrap(err) != uae.HintErr {
+			t.Errorf("Unwrap returned %v, want %v", errors.Unwrap(err), uae.HintErr)
+		}
+		return uae
+	}
+
+	if uae := verify(NewCertPool()); uae.HintErr != nil || uae.HintCert != nil {
+		t.Errorf("unexpected hint %v from %v", uae.HintErr, uae.HintCert)
+	}
+	roots := NewCertPool()
+	roots.AddCert(impostor)
+	if uae := verify(roots); uae.HintErr == nil || uae.HintCert != impostor {
+		t.Errorf("got hint %v from %v, want a signature error from the impostor", uae.HintErr, uae.HintCert)
+	}
+}
+
 var nameConstraintTests = []struct {
 	constraint, domain string
 	expectError        bool
@@ -2129,3 +2176,1125 @@ func TestLongChain(t *testing.T) {
 	}
 	t.Logf("verification took %v", time.Since(start))
 }
+
+// meshCA is a CA of a mesh of cross-certified CAs, which certify each other's
+// keys.
+type meshCA struct {
+	name string
+	key  *ecdsa.PrivateKey
+}
+
+func newMeshCA(t *testing.T, name string) *meshCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &meshCA{name, key}
+}
+
+func (ca *meshCA) keyID() []byte {
+	h := sha256.Sum256(elliptic.Marshal(ca.key.Curve, ca.key.X, ca.key.Y))
+	return h[:20]
+}
+
+// certify issues a certificate for subject, which is a CA unless it is a
+// leaf, with the given policies and policy mappings.
+func (ca *meshCA) certify(t *testing.T, subject *meshCA, leaf bool, policies []OID, mappings ...PolicyMapping) *Certificate {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: subject.name},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  !leaf,
+		SubjectKeyId:          subject.keyID(),
+		Policies:              policies,
+		PolicyMappings:        mappings,
+	}
+	if leaf {
+		template.KeyUsage = KeyUsageDigitalSignature
+	}
+	parent := &Certificate{
+		Subject:      pkix.Name{CommonName: ca.name},
+		SubjectKeyId: ca.keyID(),
+	}
+	der, err := CreateCertificate(rand.Reader, template, parent, subject.key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestBridgeMesh builds chains in a PKI modeled on the Federal PKI, in which
+// agency CAs are cross-certified with a bridge CA, the bridge with the
+// Common Policy root and some agencies with each other, with policy mappings
+// between their policy domains.
+func TestBridgeMesh(t *testing.T) {
+	mustParseOID := func(s string) OID {
+		oid, err := ParseOID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return oid
+	}
+	var (
+		commonHardware = mustParseOID("2.16.840.1.101.3.2.1.3.7")
+		bridgeBasic    = mustParseOID("2.16.840.1.101.3.2.1.3.2")
+		bridgeMedium   = mustParseOID("2.16.840.1.101.3.2.1.3.12")
+		agencyAMedium  = mustParseOID("2.999.1.1")
+		agencyABasic   = mustParseOID("2.999.1.2")
+		agencyBMedium  = mustParseOID("2.999.2.1")
+		agencyCMedium  = mustParseOID("2.999.3.1")
+	)
+	mapping := func(issuerDomain, subjectDomain OID) PolicyMapping {
+		return PolicyMapping{issuerDomain, subjectDomain}
+	}
+
+	common := newMeshCA(t, "Common")
+	bridge := newMeshCA(t, "Bridge")
+	a := newMeshCA(t, "Agency A")
+	b := newMeshCA(t, "Agency B")
+	c := newMeshCA(t, "Agency C")
+
+	roots := NewCertPool()
+	roots.AddCert(common.certify(t, common, false, nil))
+
+	intermediates := NewCertPool()
+	for _, cert := range []*Certificate{
+		common.certify(t, bridge, false, []OID{commonHardware}, mapping(commonHardware, bridgeMedium)),
+		bridge.certify(t, common, false, []OID{bridgeMedium}, mapping(bridgeMedium, commonHardware)),
+
+		bridge.certify(t, a, false, []OID{bridgeMedium, bridgeBasic}, mapping(bridgeMedium, agencyAMedium), mapping(bridgeBasic, agencyABasic)),
+		a.certify(t, bridge, false, []OID{agencyAMedium, agencyABasic}, mapping(agencyAMedium, bridgeMedium), mapping(agencyABasic, bridgeBasic)),
+		bridge.certify(t, b, false, []OID{bridgeMedium}, mapping(bridgeMedium, agencyBMedium)),
+		b.certify(t, bridge, false, []OID{agencyBMedium}, mapping(agencyBMedium, bridgeMedium)),
+		// Agency C is only cross-certified at basic assurance.
+		bridge.certify(t, c, false, []OID{bridgeBasic}, mapping(bridgeBasic, agencyCMedium)),
+		c.certify(t, bridge, false, []OID{agencyCMedium}, mapping(agencyCMedium, bridgeBasic)),
+
+		a.certify(t, b, false, []OID{agencyAMedium}, mapping(agencyAMedium, agencyBMedium)),
+		b.certify(t, a, false, []OID{agencyBMedium}, mapping(agencyBMedium, agencyAMedium)),
+		a.certify(t, c, false, []OID{agencyAMedium}, mapping(agencyAMedium, agencyCMedium)),
+		c.certify(t, a, false, []OID{agencyCMedium}, mapping(agencyCMedium, agencyAMedium)),
+
+		a.certify(t, a, false, nil),
+		b.certify(t, b, false, nil),
+		c.certify(t, c, false, nil),
+	} {
+		intermediates.AddCert(cert)
+	}
+
+	// More agencies, only cross-certified with the bridge, multiply the
+	// loops through it.
+	for i := 0; i < 8; i++ {
+		agency := newMeshCA(t, fmt.Sprintf("Agency %d", i))
+		intermediates.AddCert(bridge.certify(t, agency, false, []OID{bridgeMedium}))
+		intermediates.AddCert(agency.certify(t, bridge, false, []OID{bridgeMedium}))
+	}
+
+	leafB := b.certify(t, newMeshCA(t, "Leaf B"), true, []OID{agencyBMedium})
+	leafC := c.certify(t, newMeshCA(t, "Leaf C"), true, []OID{agencyCMedium})
+
+	tests := []struct {
+		name     string
+		leaf     *Certificate
+		policies []OID
+		chains   []string
+		reason   InvalidReason
+
+		unknownAuthority bool
+	}{
+		{
+			// Without policy processing, the critical policy mappings
+			// of the cross-certificates are unhandled.
+			name:             "no policy",
+			leaf:             leafB,
+			unknownAuthority: true,
+		},
+		{
+			name:     "medium",
+			leaf:     leafB,
+			policies: []OID{commonHardware},
+			chains: []string{
+				"Leaf B -> Agency B -> Bridge -> Common",
+				"Leaf B -> Agency B -> Agency A -> Bridge -> Common",
+			},
+		},
+		{
+			// The chain through Agency C is only valid for basic
+			// assurance, which the root doesn't map to.
+			name:     "any policy",
+			leaf:     leafB,
+			policies: []OID{anyPolicy},
+			chains: []string{
+				"Leaf B -> Agency B -> Bridge -> Common",
+				"Leaf B -> Agency B -> Agency A -> Bridge -> Common",
+			},
+		},
+		{
+			name:     "mapped through agencies",
+			leaf:     leafC,
+			policies: []OID{commonHardware},
+			chains: []string{
+				"Leaf C -> Agency C -> Agency A -> Bridge -> Common",
+				"Leaf C -> Agency C -> Agency A -> Agency B -> Bridge -> Common",
+			},
+		},
+		{
+			name:     "unknown policy",
+			leaf:     leafC,
+			policies: []OID{mustParseOID("2.999.9")},
+			reason:   NoValidPolicy,
+		},
+	}
+	for _, tt := range tests {
+		stats := new(VerifyStats)
+		chains, err := tt.leaf.Verify(VerifyOptions{
+			Roots:               roots,
+			Intermediates:       intermediates,
+			KeyUsages:           []ExtKeyUsage{ExtKeyUsageAny},
+			CertificatePolicies: tt.policies,
+			Stats:               stats,
+		})
+		if tt.unknownAuthority {
+			if _, ok := err.(UnknownAuthorityError); !ok {
+				t.Errorf("%s: got error %v, want UnknownAuthorityError", tt.name, err)
+			}
+			continue
+		}
+		if tt.chains == nil {
+			if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != tt.reason {
+				t.Errorf("%s: got error %v, want reason %d", tt.name, err, tt.reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, chain := range chains {
+			var names []string
+			for _, cert := range chain {
+				names = append(names, cert.Subject.CommonName)
+			}
+			got = append(got, strings.Join(names, " -> "))
+		}
+		sort.Strings(got)
+		want := append([]string(nil), tt.chains...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got chains\n\t%s\nwant\n\t%s", tt.name, strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+		}
+		if checks := stats.SignatureChecks()["ECDSA-P-256"]; checks > maxChainSignatureChecks {
+			t.Errorf("%s: %d signature checks", tt.name, checks)
+		}
+	}
+}
+
+func TestPartialChain(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := NewCertPool()
+	trusted.AddCert(intermediate)
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	if _, err := leaf.Verify(VerifyOptions{Roots: NewCertPool(), Intermediates: trusted}); err == nil {
+		t.Error("chain to an untrusted intermediate was accepted")
+	}
+
+	chains, err := leaf.Verify(VerifyOptions{Roots: NewCertPool(), TrustedIntermediates: trusted})
+	if err != nil {
+		t.Fatalf("partial chain was rejected: %v", err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 2 || !chains[0][1].Equal(intermediate) {
+		t.Errorf("got %d chains, want a single chain ending at the intermediate", len(chains))
+	}
+
+	// A full chain to a root is preferred.
+	for _, opts := range []VerifyOptions{
+		{Roots: roots, TrustedIntermediates: trusted},
+		{Roots: roots, Intermediates: trusted, TrustedIntermediates: trusted},
+	} {
+		chains, err = leaf.Verify(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chains) != 1 || len(chains[0]) != 3 || !chains[0][2].Equal(root) {
+			for _, chain := range chains {
+				t.Logf("chain: %s", chainToDebugString(chain))
+			}
+			t.Error("want a single chain ending at the root")
+		}
+	}
+}
+
+func TestVerifyChainsAnchor(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := func(certs ...*Certificate) *CertPool {
+		p := NewCertPool()
+		for _, c := range certs {
+			p.AddCert(c)
+		}
+		return p
+	}
+	tests := []struct {
+		opts    VerifyOptions
+		want    AnchorSource
+		sources []CertificateSource
+	}{
+		{
+			VerifyOptions{Roots: pool(root), Intermediates: pool(intermediate)},
+			AnchorRoots,
+			[]CertificateSource{SourceLeaf, SourceIntermediates, SourceRoots},
+		},
+		{
+			VerifyOptions{Roots: pool(leaf)},
+			AnchorLeaf,
+			[]CertificateSource{SourceLeaf},
+		},
+		{
+			VerifyOptions{Roots: pool(), TrustedIntermediates: pool(intermediate)},
+			AnchorTrustedIntermediate,
+			[]CertificateSource{SourceLeaf, SourceTrustedIntermediates},
+		},
+		{
+			VerifyOptions{Roots: pool(root, intermediate), Intermediates: pool(intermediate)},
+			AnchorRoots,
+			[]CertificateSource{SourceLeaf, SourceRoots},
+		},
+	}
+	for i, tt := range tests {
+		chains, err := leaf.VerifyChains(tt.opts)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		found := false
+		for _, chain := range chains {
+			if chain.Anchor != tt.want {
+				t.Errorf("#%d: got anchor %v, want %v", i, chain.Anchor, tt.want)
+			}
+			if len(chain.Stores) != len(chain.Certificates) {
+				t.Errorf("#%d: got %d stores for %d certificates", i, len(chain.Stores), len(chain.Certificates))
+			}
+			found = found || reflect.DeepEqual(chain.Sources, tt.sources)
+		}
+		if !found {
+			t.Errorf("#%d: no chain with sources %v", i, tt.sources)
+		}
+	}
+}
+
+func TestVerifyAgainstPrograms(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoot, otherRootKey, err := generateCert("Other Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelatedRoot, _, err := generateCert("Unrelated Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CrossSign(intermediate, otherRoot, otherRootKey.(crypto.Signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crossSigned, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := func(certs ...*Certificate) *CertPool {
+		p := NewCertPool()
+		for _, c := range certs {
+			p.AddCert(c)
+		}
+		return p
+	}
+	opts := VerifyOptions{Intermediates: pool(intermediate, crossSigned)}
+	results := leaf.VerifyAgainstPrograms(opts, map[string]*CertPool{
+		"a": pool(root),
+		"b": pool(otherRoot),
+		"c": pool(unrelatedRoot),
+	})
+
+	for name, want := range map[string]*Certificate{"a": root, "b": otherRoot} {
+		r := results[name]
+		if r.Err != nil {
+			t.Errorf("%s: %v", name, r.Err)
+			continue
+		}
+		if len(r.Chains) != 1 || !r.Chains[0][len(r.Chains[0])-1].Equal(want) {
+			t.Errorf("%s: got %d chains, want one chain to %v", name, len(r.Chains), want.Subject)
+			for _, chain := range r.Chains {
+				t.Logf("%s: %s", name, chainToDebugString(chain))
+			}
+		}
+	}
+	if _, ok := results["c"].Err.(UnknownAuthorityError); !ok {
+		t.Errorf("c: got error %v, want UnknownAuthorityError", results["c"].Err)
+	}
+}
+
+func TestVerifyTrace(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Constrained Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		PermittedDNSDomains:   []string{"example.com"},
+	}
+	rootDER, err := CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"www.example.com"},
+		ExtKeyUsage:  []ExtKeyUsage{ExtKeyUsageServerAuth},
+	}
+	leafDER, err := CreateCertificate(rand.Reader, leafTemplate, root, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace bytes.Buffer
+	opts := VerifyOptions{Roots: NewCertPool(), Trace: &trace}
+	opts.Roots.AddCert(root)
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`x509: verifying "CN=Leaf"`,
+		`x509: considering root "CN=Constrained Root"`,
+		`x509: signature of "CN=Leaf"`,
+		`x509: DNS name "www.example.com" against the name constraints of "CN=Constrained Root"`,
+		`x509: chain "CN=Leaf" [`,
+		`] accepted`,
+	} {
+		if !strings.Contains(trace.String(), want) {
+			t.Errorf("trace does not contain %q:\n%s", want, trace.String())
+		}
+	}
+}
+
+func TestLegacyCommonNameOption(t *testing.T) {
+	defer func(savedIgnoreCN bool) {
+		ignoreCN = savedIgnoreCN
+	}(ignoreCN)
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf.example", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	tests := []struct {
+		ignoreCN bool
+		mode     CommonNameMode
+		ok       bool
+	}{
+		{true, CommonNameDefault, false},
+		{true, CommonNameAsHostname, true},
+		{true, CommonNameIgnored, false},
+		{false, CommonNameDefault, true},
+		{false, CommonNameAsHostname, true},
+		{false, CommonNameIgnored, false},
+	}
+	for _, tt := range tests {
+		ignoreCN = tt.ignoreCN
+		_, err := leaf.Verify(VerifyOptions{Roots: roots, DNSName: "leaf.example", LegacyCommonName: tt.mode})
+		if (err == nil) != tt.ok {
+			t.Errorf("ignoreCN=%v, mode %d: got error %v, want success %v", tt.ignoreCN, tt.mode, err, tt.ok)
+		}
+	}
+}
+
+func TestVerifyOptionsValidate(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, _, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := func(certs ...*Certificate) *CertPool {
+		p := NewCertPool()
+		for _, c := range certs {
+			p.AddCert(c)
+		}
+		return p
+	}
+
+	if err := NewVerifyOptions().Validate(); err != nil {
+		t.Errorf("NewVerifyOptions: %v", err)
+	}
+	if err := (VerifyOptions{}).Validate(); err != nil {
+		t.Errorf("zero VerifyOptions: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts VerifyOptions
+		ok   bool
+	}{
+		{"epoch", VerifyOptions{CurrentTime: time.Unix(0, 0)}, false},
+		{"past time", VerifyOptions{CurrentTime: time.Unix(1, 0)}, true},
+		{"URL", VerifyOptions{DNSName: "https://example.com"}, false},
+		{"host and port", VerifyOptions{DNSName: "example.com:443"}, false},
+		{"URL in DNSNames", VerifyOptions{DNSNames: []string{"example.com", "https://example.com"}}, false},
+		{"DNSNames", VerifyOptions{DNSNames: []string{"example.com", "[2001:db8::1]"}}, true},
+		{"IPv6", VerifyOptions{DNSName: "2001:db8::1"}, true},
+		{"bracketed IPv6", VerifyOptions{DNSName: "[2001:db8::1]"}, true},
+		// Intermediate trust anchors and cross-signed roots sent as
+		// intermediates are legitimate.
+		{"intermediate in roots", VerifyOptions{Roots: pool(root, intermediate)}, true},
+		{"root in intermediates", VerifyOptions{Roots: pool(root), Intermediates: pool(root)}, true},
+		{"intermediates", VerifyOptions{Roots: pool(root), Intermediates: pool(intermediate)}, true},
+		{"negative skew", VerifyOptions{ClockSkew: -time.Minute}, false},
+		{"negative expiry warning", VerifyOptions{ExpiryWarning: -time.Minute}, false},
+		{"negative chain length", VerifyOptions{MaxChainLength: -1}, false},
+		{"unknown usage", VerifyOptions{KeyUsages: []ExtKeyUsage{ExtKeyUsageAny, 1000}}, false},
+		{"unknown CommonNameMode", VerifyOptions{LegacyCommonName: 3}, false},
+		{"unknown IPv4MappedMode", VerifyOptions{IPv4Mapped: 3}, false},
+		{"unknown ValidityNestingMode", VerifyOptions{ValidityNesting: 3}, false},
+		{"short pin", VerifyOptions{PinnedKeys: [][]byte{make([]byte, 20)}}, false},
+		{"pin", VerifyOptions{PinnedKeys: [][]byte{make([]byte, 32)}}, true},
+		{"negative MinSCTs", VerifyOptions{CTRequirements: []CTRequirement{{MinSCTs: -1}}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate returned %v", tt.name, err)
+		}
+	}
+}
+
+func TestIPv4MappedMode(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(template, parent *Certificate) *Certificate {
+		template.SerialNumber = big.NewInt(1)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		if parent == nil {
+			parent = template
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	_, excluded, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := create(&Certificate{
+		Subject:               pkix.Name{CommonName: "Root"},
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		ExcludedIPRanges:      []*net.IPNet{excluded},
+	}, nil)
+	// CreateCertificate encodes IPv4-mapped addresses in 4 bytes, so the
+	// IPv4-mapped SANs are encoded by ipv6SANExtension.
+	v4 := create(&Certificate{Subject: pkix.Name{CommonName: "v4"}, IPAddresses: []net.IP{net.ParseIP("192.0.2.1").To4()}}, root)
+	mapped := create(&Certificate{Subject: pkix.Name{CommonName: "mapped"}, ExtraExtensions: []pkix.Extension{ipv6SANExtension(t, net.ParseIP("::ffff:198.51.100.1"))}}, root)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	tests := []struct {
+		cert *Certificate
+		host string
+		mode IPv4MappedMode
+		ok   bool
+	}{
+		{mapped, "198.51.100.1", IPv4MappedDefault, true},
+		{mapped, "::ffff:198.51.100.1", IPv4MappedDefault, true},
+		{mapped, "198.51.100.1", IPv4MappedEquivalent, true},
+		{mapped, "198.51.100.1", IPv4MappedDistinct, false},
+		{mapped, "[::ffff:198.51.100.1]", IPv4MappedDistinct, true},
+		{v4, "::ffff:192.0.2.1", IPv4MappedDistinct, false},
+	}
+	for _, tt := range tests {
+		err := tt.cert.verifyHostname(tt.host, &VerifyOptions{IPv4Mapped: tt.mode})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s with mode %d: got %v", tt.host, tt.mode, err)
+		}
+	}
+
+	// The SAN ::ffff:192.0.2.1 escapes the excluded IPv4 range, unless
+	// IPv4-mapped addresses are equivalent.
+	escaping := create(&Certificate{Subject: pkix.Name{CommonName: "escaping"}, ExtraExtensions: []pkix.Extension{ipv6SANExtension(t, net.ParseIP("::ffff:192.0.2.1"))}}, root)
+	for _, mode := range []IPv4MappedMode{IPv4MappedDefault, IPv4MappedDistinct} {
+		if _, err := escaping.Verify(VerifyOptions{Roots: roots, IPv4Mapped: mode}); err != nil {
+			t.Errorf("mode %d: %v", mode, err)
+		}
+	}
+	_, err = escaping.Verify(VerifyOptions{Roots: roots, IPv4Mapped: IPv4MappedEquivalent})
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != CANotAuthorizedForThisName {
+		t.Errorf("IPv4MappedEquivalent: got %v, want CANotAuthorizedForThisName", err)
+	}
+	if _, err := v4.Verify(VerifyOptions{Roots: roots}); err == nil {
+		t.Error("IPv4 SAN in the excluded range was accepted")
+	}
+}
+
+// ipv6SANExtension returns a subject alternative name extension with the
+// single IP address ip, encoded in 16 bytes even if it is an IPv4-mapped
+// address.
+func ipv6SANExtension(t *testing.T, ip net.IP) pkix.Extension {
+	value, err := asn1.Marshal([]asn1.RawValue{{Tag: nameTypeIP, Class: asn1.ClassContextSpecific, Bytes: ip.To16()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidExtensionSubjectAltName, Value: value}
+}
+
+func TestNormalizeDNSName(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"db.corp.example", "legacy.corp.example."},
+
+		InsecureSkipSANValidation: true,
+	}, root, rootKey.(crypto.Signer).Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	// Ignore trailing dots and complete single-label names.
+	var calls int
+	normalize := func(name string) string {
+		calls++
+		name = strings.TrimSuffix(name, ".")
+		if !strings.Contains(name, ".") {
+			name += ".corp.example"
+		}
+		return name
+	}
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, DNSName: "db"}); err == nil {
+		t.Error("single-label name matched without NormalizeDNSName")
+	}
+	opts := VerifyOptions{Roots: roots, DNSName: "db", DNSNames: []string{"legacy.corp.example"}, NormalizeDNSName: normalize}
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("with NormalizeDNSName: %v", err)
+	}
+	if calls == 0 {
+		t.Error("NormalizeDNSName was not called")
+	}
+
+	opts.DNSName = "web"
+	_, err = leaf.Verify(opts)
+	if e, ok := err.(HostnameError); !ok || e.Host != "web" {
+		t.Errorf("got %v, want a HostnameError for the name as given", err)
+	}
+
+	// IP addresses are not normalized.
+	calls = 0
+	opts.DNSName, opts.DNSNames = "192.0.2.1", nil
+	if _, err := leaf.Verify(opts); err == nil || calls != 0 {
+		t.Errorf("IP address: got %v after %d calls", err, calls)
+	}
+}
+
+func TestStrictBasicConstraints(t *testing.T) {
+	leaf, err := certificateFromPEM(x509v1TestLeaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := certificateFromPEM(x509v1TestIntermediate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(v1)
+	opts := VerifyOptions{Roots: roots, CurrentTime: time.Unix(1481753183, 0)}
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Fatalf("version 1 root was rejected: %v", err)
+	}
+
+	opts.StrictBasicConstraints = true
+	_, err = leaf.Verify(opts)
+	want := "x509: certificate is not authorized to sign other certificates: version 1 certificate has no basic constraints extension"
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != NotAuthorizedToSign || e.Cert != v1 || e.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	// Without a basic constraints extension, a version 3 certificate can't
+	// issue certificates, and the hint says why.
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noBC := &Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "No basic constraints"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := CreateCertificate(rand.Reader, noBC, root, rootKey.(crypto.Signer).Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, _, err := generateCert("Leaf", false, inter, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+	_, err = child.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates})
+	if e, ok := err.(UnknownAuthorityError); !ok || !strings.Contains(e.Error(), "basic constraints extension is missing") {
+		t.Errorf("got %v, want a hint about the missing basic constraints", err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, inter, interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := generateCert("Other", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+	opts := VerifyOptions{Roots: roots}
+
+	for _, chain := range [][]*Certificate{{leaf, inter}, {leaf, inter, root}} {
+		got, err := VerifyChain(chain, opts)
+		if err != nil {
+			t.Fatalf("%d certificates: %v", len(chain), err)
+		}
+		if len(got) != 3 || got[0] != leaf || got[1] != inter || !got[2].Equal(root) {
+			t.Errorf("%d certificates: got %v", len(chain), got)
+		}
+	}
+
+	// The intermediates are not searched.
+	opts.Intermediates = intermediates
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChain([]*Certificate{leaf}, opts); err == nil {
+		t.Error("incomplete chain was completed from Intermediates")
+	}
+	for _, chain := range [][]*Certificate{{leaf, root}, {leaf, inter, other}, {leaf, leaf, inter}} {
+		if _, err := VerifyChain(chain, opts); err == nil {
+			t.Errorf("chain %v was accepted", chain)
+		}
+	}
+
+	expired := opts
+	expired.CurrentTime = time.Now().Add(48 * time.Hour)
+	if _, err := VerifyChain([]*Certificate{leaf, inter}, expired); err == nil {
+		t.Error("expired chain was accepted")
+	}
+
+	trustedLeaf := NewCertPool()
+	trustedLeaf.AddCert(leaf)
+	if got, err := VerifyChain([]*Certificate{leaf}, VerifyOptions{Roots: trustedLeaf}); err != nil || len(got) != 1 {
+		t.Errorf("trusted leaf: got %v, %v", got, err)
+	}
+	if _, err := VerifyChain(nil, opts); err == nil {
+		t.Error("empty chain was accepted")
+	}
+}
+
+// TestVerifyChainPlatformVerifier checks that VerifyChain and TargetIsCA
+// don't fall back to the system roots where they are only available to the
+// platform verifier.
+func TestVerifyChainPlatformVerifier(t *testing.T) {
+	defer func(old bool) { usePlatformVerifier = old }(usePlatformVerifier)
+	usePlatformVerifier = true
+
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChain([]*Certificate{leaf, root}, VerifyOptions{}); err != errVerifyChainNeedsRoots {
+		t.Errorf("got %v, want %v", err, errVerifyChainNeedsRoots)
+	}
+	if _, err := root.Verify(VerifyOptions{TargetIsCA: true}); err != errTargetIsCANeedsRoots {
+		t.Errorf("TargetIsCA: got %v, want %v", err, errTargetIsCANeedsRoots)
+	}
+}
+
+func TestPlatformVerifierChainPolicy(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old bool) { usePlatformVerifier = old }(usePlatformVerifier)
+	defer func(old func(*Certificate, *VerifyOptions) ([][]*Certificate, error)) { platformVerify = old }(platformVerify)
+	systemRootsPool()
+	defer func(err error) { systemRootsErr = err }(systemRootsErr)
+	usePlatformVerifier, systemRootsErr = true, nil
+	platformVerify = func(c *Certificate, opts *VerifyOptions) ([][]*Certificate, error) {
+		return [][]*Certificate{{c, root}}, nil
+	}
+
+	if _, err := leaf.Verify(VerifyOptions{}); err != nil {
+		t.Fatalf("platform verifier: %v", err)
+	}
+	rootHash := sha256.Sum256(root.RawSubjectPublicKeyInfo)
+	policy, err := OIDFromInts([]uint64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		opts   VerifyOptions
+		reason InvalidReason
+	}{
+		{"blocked key", VerifyOptions{BlockedKeys: [][]byte{rootHash[:]}}, BlockedKey},
+		{"pinned key", VerifyOptions{PinnedKeys: [][]byte{make([]byte, 32)}}, NoPinnedKey},
+		{"chain length", VerifyOptions{MaxChainLength: 1}, ChainTooLong},
+		{"certificate policies", VerifyOptions{CertificatePolicies: []OID{policy}}, NoValidPolicy},
+	} {
+		_, err := leaf.Verify(tt.opts)
+		if e, ok := err.(CertificateInvalidError); !ok || e.Reason != tt.reason {
+			t.Errorf("%s: got %v, want reason %d", tt.name, err, tt.reason)
+		}
+	}
+}
+
+func TestRequireAllKeyUsages(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(cn string, usages []ExtKeyUsage, isCA bool, parent *Certificate, pub, priv interface{}) *Certificate {
+		template := &Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              KeyUsageDigitalSignature | KeyUsageCertSign,
+			ExtKeyUsage:           usages,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if parent == nil {
+			parent = template
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	root := create("Root", nil, true, nil, rootKey.Public(), rootKey)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh := create("mesh", []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth}, false, root, leafKey.Public(), rootKey)
+	server := create("server", []ExtKeyUsage{ExtKeyUsageServerAuth}, false, root, leafKey.Public(), rootKey)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	serverOnlyRoots := NewCertPool()
+	serverOnlyRoots.AddCertWithTrust(root, &TrustAttributes{TrustedUsages: []ExtKeyUsage{ExtKeyUsageServerAuth}})
+
+	both := []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth}
+	tests := []struct {
+		leaf   *Certificate
+		roots  *CertPool
+		usages []ExtKeyUsage
+		all    bool
+		ok     bool
+	}{
+		{mesh, roots, both, true, true},
+		{server, roots, both, false, true},
+		{server, roots, both, true, false},
+		{server, roots, []ExtKeyUsage{ExtKeyUsageAny}, true, true},
+		{server, roots, []ExtKeyUsage{ExtKeyUsageAny, ExtKeyUsageClientAuth}, false, true},
+		{server, roots, []ExtKeyUsage{ExtKeyUsageAny, ExtKeyUsageClientAuth}, true, false},
+		{mesh, serverOnlyRoots, both, false, true},
+		{mesh, serverOnlyRoots, both, true, false},
+	}
+	for i, tt := range tests {
+		_, err := tt.leaf.Verify(VerifyOptions{Roots: tt.roots, KeyUsages: tt.usages, RequireAllKeyUsages: tt.all})
+		if (err == nil) != tt.ok {
+			t.Errorf("#%d: %s with %v, RequireAllKeyUsages %v: got error %v", i, tt.leaf.Subject.CommonName, tt.usages, tt.all, err)
+		}
+	}
+}
+
+func TestCheckRevocation(t *testing.T) {
+	root1, root1Key, err := generateCert("Root 1", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root2, root2Key, err := generateCert("Root 2", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(cn string, isCA bool, parent *Certificate, pub, priv interface{}) *Certificate {
+		der, err := CreateCertificate(rand.Reader, &Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              KeyUsageDigitalSignature | KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	// The intermediate is cross-signed, so the leaf has a chain to each root.
+	inter1 := create("Intermediate", true, root1, interKey.Public(), root1Key)
+	inter2 := create("Intermediate", true, root2, interKey.Public(), root2Key)
+	leaf := create("leaf", false, inter1, interKey.Public(), interKey)
+
+	roots := NewCertPool()
+	roots.AddCert(root1)
+	roots.AddCert(root2)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter1)
+	intermediates.AddCert(inter2)
+
+	errRevoked := errors.New("revoked")
+	calls := 0
+	opts := VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CheckRevocation: func(chain []*Certificate) error {
+			calls++
+			if chain[len(chain)-1] == root1 {
+				return errRevoked
+			}
+			return nil
+		},
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || len(chains) != 1 || chains[0][2] != root2 {
+		t.Errorf("got %d calls and chains %v, want the chain to Root 2", calls, chains)
+	}
+
+	opts.CheckRevocation = func([]*Certificate) error { return errRevoked }
+	if _, err := leaf.Verify(opts); err != errRevoked {
+		t.Errorf("all chains revoked: got %v", err)
+	}
+	opts.KeyUsages = []ExtKeyUsage{ExtKeyUsageAny}
+	if _, err := leaf.Verify(opts); err != errRevoked {
+		t.Errorf("all chains revoked, any usage: got %v", err)
+	}
+}
+
+func TestTargetIsCA(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(template, parent *Certificate, pub, priv interface{}) *Certificate {
+		template.SerialNumber = big.NewInt(1)
+		template.NotBefore, template.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		template.BasicConstraintsValid = true
+		der, err := CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	rootTemplate := &Certificate{
+		Subject:             pkix.Name{CommonName: "Root"},
+		KeyUsage:            KeyUsageCertSign,
+		IsCA:                true,
+		MaxPathLenZero:      true,
+		PermittedDNSDomains: []string{"example.com"},
+	}
+	root := create(rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	// The Common Name looks like a hostname, and the intermediate has no SANs.
+	inter := create(&Certificate{
+		Subject:  pkix.Name{CommonName: "ca.example.net"},
+		KeyUsage: KeyUsageCertSign,
+		IsCA:     true,
+	}, root, interKey.Public(), rootKey)
+	leaf := create(&Certificate{
+		Subject:  pkix.Name{CommonName: "Leaf"},
+		DNSNames: []string{"leaf.example.com"},
+	}, inter, interKey.Public(), interKey)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	opts := VerifyOptions{Roots: roots, LegacyCommonName: CommonNameAsHostname}
+	_, err = inter.Verify(opts)
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != NameConstraintsWithoutSANs {
+		t.Errorf("intermediate as a leaf: got %v, want NameConstraintsWithoutSANs", err)
+	}
+
+	opts.TargetIsCA = true
+	_, err = inter.Verify(opts)
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != TooManyIntermediates || e.Cert != root {
+		t.Errorf("intermediate under a MaxPathLen 0 root: got %v, want TooManyIntermediates", err)
+	}
+
+	rootTemplate.MaxPathLenZero = false
+	root = create(rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	roots = NewCertPool()
+	roots.AddCert(root)
+	opts.Roots = roots
+	chains, err := inter.Verify(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 2 || chains[0][1] != root {
+		t.Errorf("got chains %v", chains)
+	}
+
+	if _, err := leaf.Verify(opts); err == nil {
+		t.Error("end-entity certificate was accepted with TargetIsCA")
+	}
+	opts.DNSName = "ca.example.net"
+	if _, err := inter.Verify(opts); err == nil {
+		t.Error("DNSName was accepted with TargetIsCA")
+	}
+	if err := opts.Validate(); err == nil {
+		t.Error("Validate accepted DNSName with TargetIsCA")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"fmt"
+	"time"
+)
+
+// VerifyWarningKind identifies the problem reported by a VerifyWarning.
+type VerifyWarningKind int
+
+const (
+	// WarningExpiresSoon means that a certificate of a chain expires within
+	// VerifyOptions.ExpiryWarning.
+	WarningExpiresSoon VerifyWarningKind = iota
+	// WarningSHA1Signature means that a certificate of a chain, other than
+	// its root, has a SHA-1 signature, which is accepted unless
+	// VerifyOptions.DisallowedSignatureAlgorithms says otherwise.
+	WarningSHA1Signature
+	// WarningNoSCTs means that the leaf comes with no Signed Certificate
+	// Timestamps, and that none of VerifyOptions.CTRequirements applies to
+	// the chain to require them.
+	WarningNoSCTs
+	// WarningValidityNotNested means that the validity period of a
+	// certificate is not within that of its issuer, and that
+	// VerifyOptions.ValidityNesting is ValidityNestingWarn.
+	WarningValidityNotNested
+)
+
+func (k VerifyWarningKind) String() string {
+	switch k {
+	case WarningExpiresSoon:
+		return "expires soon"
+	case WarningSHA1Signature:
+		return "SHA-1 signature"
+	case WarningNoSCTs:
+		return "no SCTs"
+	case WarningValidityNotNested:
+		return "validity not nested"
+	}
+	return fmt.Sprintf("VerifyWarningKind(%d)", int(k))
+}
+
+// A VerifyWarning is a non-fatal problem found by Verify, reported through
+// VerifyOptions.Warnings.
+type VerifyWarning struct {
+	Kind VerifyWarningKind
+	// Cert is the certificate the warning is about.
+	Cert *Certificate
+	// Detail is a human-readable description of the problem.
+	Detail string
+}
+
+func (w VerifyWarning) String() string {
+	return fmt.Sprintf("x509: warning for %q: %s", w.Cert.Subject, w.Detail)
+}
+
+// warnChains reports the warnings of the verified chains to opts.Warnings.
+func (opts *VerifyOptions) warnChains(chains [][]*Certificate) {
+	if opts.Warnings == nil {
+		return
+	}
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	within := opts.ExpiryWarning
+	if within == 0 {
+		within = 30 * 24 * time.Hour
+	}
+
+	type key struct {
+		kind VerifyWarningKind
+		cert *Certificate
+	}
+	reported := make(map[key]bool)
+	warn := func(kind VerifyWarningKind, c *Certificate, detail string) {
+		if reported[key{kind, c}] {
+			return
+		}
+		reported[key{kind, c}] = true
+		opts.Warnings(VerifyWarning{Kind: kind, Cert: c, Detail: detail})
+	}
+
+	for _, chain := range chains {
+		for i, c := range chain {
+			if left := c.NotAfter.Sub(now); left < within {
+				warn(WarningExpiresSoon, c, fmt.Sprintf("expires at %s, in %s", c.NotAfter.Format(time.RFC3339), left.Round(time.Second)))
+			}
+			if i < len(chain)-1 {
+				switch c.SignatureAlgorithm {
+				case SHA1WithRSA, DSAWithSHA1, ECDSAWithSHA1:
+					warn(WarningSHA1Signature, c, "signed with "+c.SignatureAlgorithm.String())
+				}
+				if opts.ValidityNesting == ValidityNestingWarn {
+					if detail := validityNestingViolation(c, chain[i+1]); detail != "" {
+						warn(WarningValidityNotNested, c, detail)
+					}
+				}
+			}
+		}
+
+		required := false
+		for i := range opts.CTRequirements {
+			if opts.CTRequirements[i].applies(chain) {
+				required = true
+			}
+		}
+		if !required && countSCTLogs(chain[0], opts.SCTs) == 0 {
+			warn(WarningNoSCTs, chain[0], "no SCTs, and Certificate Transparency is not required")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestVerifyWarnings(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	create := func(template, parent *Certificate) *Certificate {
+		template.SerialNumber = big.NewInt(1)
+		template.NotBefore = now.Add(-time.Hour)
+		if parent == nil {
+			parent = template
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	root := create(&Certificate{
+		Subject:               pkix.Name{CommonName: "Root"},
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+	intermediate := create(&Certificate{
+		Subject:               pkix.Name{CommonName: "Intermediate"},
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SignatureAlgorithm:    ECDSAWithSHA1,
+	}, root)
+	leaf := create(&Certificate{
+		Subject:  pkix.Name{CommonName: "Leaf"},
+		NotAfter: now.Add(7 * 24 * time.Hour),
+		DNSNames: []string{"example.com"},
+	}, intermediate)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(intermediate)
+
+	verify := func(opts VerifyOptions) ([]VerifyWarning, error) {
+		var warnings []VerifyWarning
+		opts.Roots, opts.Intermediates = roots, intermediates
+		opts.Warnings = func(w VerifyWarning) { warnings = append(warnings, w) }
+		_, err := leaf.Verify(opts)
+		sort.Slice(warnings, func(i, j int) bool { return warnings[i].Kind < warnings[j].Kind })
+		return warnings, err
+	}
+
+	warnings, err := verify(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VerifyWarning{
+		{Kind: WarningExpiresSoon, Cert: leaf},
+		{Kind: WarningSHA1Signature, Cert: intermediate},
+		{Kind: WarningNoSCTs, Cert: leaf},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("got %d warnings, want %d: %v", len(warnings), len(want), warnings)
+	}
+	for i := range want {
+		if warnings[i].Kind != want[i].Kind || warnings[i].Cert != want[i].Cert || warnings[i].Detail == "" {
+			t.Errorf("warning %d is %v (%v), want %v", i, warnings[i], warnings[i].Kind, want[i].Kind)
+		}
+	}
+
+	// A shorter threshold and a CT requirement that only applies to later
+	// leaves leave the SHA-1 and SCT warnings.
+	warnings, err = verify(VerifyOptions{
+		ExpiryWarning:  24 * time.Hour,
+		CTRequirements: []CTRequirement{{After: now.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || warnings[0].Kind != WarningSHA1Signature || warnings[1].Kind != WarningNoSCTs {
+		t.Errorf("with ExpiryWarning: got %v", warnings)
+	}
+
+	// Failed verifications report no warnings.
+	warnings, err = verify(VerifyOptions{DNSName: "example.org"})
+	if err == nil || len(warnings) != 0 {
+		t.Errorf("failed verification: got %v, %v", warnings, err)
+	}
+}
+
+func TestValidityNesting(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	create := func(template, parent *Certificate) *Certificate {
+		template.SerialNumber = big.NewInt(1)
+		template.NotBefore = now.Add(-time.Hour)
+		if parent == nil {
+			parent = template
+		}
+		der, err := CreateCertificate(rand.Reader, template, parent, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	root := create(&Certificate{
+		Subject:               pkix.Name{CommonName: "Root"},
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+	// The leaf outlives its issuer.
+	leaf := create(&Certificate{
+		Subject:  pkix.Name{CommonName: "Leaf"},
+		NotAfter: now.Add(2 * 365 * 24 * time.Hour),
+	}, root)
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	for _, mode := range []ValidityNestingMode{ValidityNestingIgnored, ValidityNestingWarn} {
+		var warnings []VerifyWarning
+		_, err := leaf.Verify(VerifyOptions{
+			Roots:           roots,
+			ValidityNesting: mode,
+			Warnings: func(w VerifyWarning) {
+				if w.Kind == WarningValidityNotNested {
+					warnings = append(warnings, w)
+				}
+			},
+		})
+		if err != nil {
+			t.Errorf("mode %d: %v", mode, err)
+		}
+		if mode == ValidityNestingWarn && (len(warnings) != 1 || warnings[0].Cert != leaf) {
+			t.Errorf("ValidityNestingWarn: got warnings %v", warnings)
+		}
+		if mode == ValidityNestingIgnored && len(warnings) != 0 {
+			t.Errorf("ValidityNestingIgnored: got warnings %v", warnings)
+		}
+	}
+
+	_, err = leaf.Verify(VerifyOptions{Roots: roots, ValidityNesting: ValidityNestingEnforced})
+	if e, ok := err.(CertificateInvalidError); !ok || e.Reason != ValidityNotNested || e.Cert != leaf {
+		t.Errorf("ValidityNestingEnforced: got %v, want ValidityNotNested", err)
+	}
+}
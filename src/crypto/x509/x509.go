@@ -155,9 +155,9 @@ type tbsCertificate struct {
 	Validity           validity
 	Subject            asn1.RawValue
 	PublicKey          publicKeyInfo
-	UniqueId           asn1.BitString   `asn1:"optional,tag:1"`
-	SubjectUniqueId    asn1.BitString   `asn1:"optional,tag:2"`
-	Extensions         []pkix.Extension `asn1:"optional,explicit,tag:3"`
+	UniqueId           asn1.BitString `asn1:"optional,tag:1"`
+	SubjectUniqueId    asn1.BitString `asn1:"optional,tag:2"`
+	Extensions         asn1.RawValue  `asn1:"optional,explicit,tag:3"`
 }
 
 type dsaAlgorithmParameters struct {
@@ -672,6 +672,14 @@ type Certificate struct {
 	Signature          []byte
 	SignatureAlgorithm SignatureAlgorithm
 
+	// SignatureAlgorithmIdentifier is the signature algorithm of a parsed
+	// certificate, as it was encoded. It identifies the algorithm when
+	// SignatureAlgorithm is UnknownSignatureAlgorithm, because its OID or
+	// parameters are not supported. Such certificates are still parsed,
+	// and only fail when their signature is checked, with an
+	// UnknownSignatureAlgorithmError. It is ignored by CreateCertificate.
+	SignatureAlgorithmIdentifier pkix.AlgorithmIdentifier
+
 	PublicKeyAlgorithm PublicKeyAlgorithm
 	PublicKey          interface{}
 
@@ -682,18 +690,78 @@ type Certificate struct {
 	NotBefore, NotAfter time.Time // Validity bounds.
 	KeyUsage            KeyUsage
 
+	// RawKeyUsage is the value of the key usage extension, exactly as it
+	// was encoded in a parsed certificate. CreateCertificate uses it instead
+	// of encoding KeyUsage if it holds the same usages, so that a parsed
+	// certificate that does not use the minimal DER encoding is reproduced
+	// exactly.
+	RawKeyUsage []byte
+
 	// Extensions contains raw X.509 extensions. When parsing certificates,
 	// this can be used to extract non-critical extensions that are not
 	// parsed by this package. When marshaling certificates, the Extensions
 	// field is ignored, see ExtraExtensions.
 	Extensions []pkix.Extension
 
+	// OIDExtensions contains the extensions of a parsed certificate whose
+	// identifiers have an arc too large for asn1.ObjectIdentifier, and
+	// which are therefore missing from Extensions. They are not interpreted
+	// by this package, and Verify fails if one of them is critical. When
+	// marshaling certificates, they are copied, raw, after ExtraExtensions.
+	OIDExtensions []OIDExtension
+
 	// ExtraExtensions contains extensions to be copied, raw, into any
 	// marshaled certificates. Values override any extensions that would
 	// otherwise be produced based on the other fields. The ExtraExtensions
 	// field is not populated when parsing certificates, see Extensions.
+	//
+	// CreateCertificate emits the extensions it generates from the other
+	// fields first, in a fixed order, followed by ExtraExtensions in order.
+	// An extension generated from the other fields is omitted if its OID
+	// appears in ExtraExtensions or in SuppressedExtensions. See also
+	// SetExtension and RemoveDefaultExtension.
 	ExtraExtensions []pkix.Extension
 
+	// SuppressedExtensions lists extensions that CreateCertificate must not
+	// generate from the other fields, such as the subject key identifier it
+	// otherwise adds to CA certificates. It does not affect
+	// ExtraExtensions. It is not populated when parsing certificates.
+	SuppressedExtensions []asn1.ObjectIdentifier
+
+	// DuplicateHandling controls how CreateCertificate treats extensions
+	// that appear more than once in ExtraExtensions and repeated subject
+	// alternative names. By default, they are an error. It is not
+	// populated when parsing certificates.
+	DuplicateHandling DuplicateHandling
+
+	// InsecureSkipSANValidation makes CreateCertificate accept subject
+	// alternative names that are not well formed, such as DNS names with
+	// spaces or empty labels, email addresses that are not valid mailboxes,
+	// and relative URIs. It also disables the conversion of
+	// internationalized DNS names to ASCII. It is meant only for generating
+	// test fixtures. It is not populated when parsing certificates.
+	InsecureSkipSANValidation bool
+
+	// RejectUnicodeDNSNames makes CreateCertificate return an error for
+	// DNSNames entries with non-ASCII characters, instead of converting
+	// them to their ASCII form with Punycode. It is not populated when
+	// parsing certificates.
+	RejectUnicodeDNSNames bool
+
+	// SignatureAlgorithms lists the signature algorithms CreateCertificate
+	// may sign with, in order of preference, for signers such as HSMs that
+	// only allow some mechanisms. See SignatureAlgorithmsError. It is not
+	// populated when parsing certificates.
+	SignatureAlgorithms []SignatureAlgorithm
+
+	// EnforceNameConstraints makes CreateCertificate check the subject and
+	// subject alternative names of the new certificate against the name
+	// constraints of parent, as Verify would, and return an error instead
+	// of signing a certificate that violates them. Self-issued
+	// certificates are not checked, as in Verify. It is not populated when
+	// parsing certificates.
+	EnforceNameConstraints bool
+
 	// UnhandledCriticalExtensions contains a list of extension IDs that
 	// were not (fully) processed when parsing. Verify will fail if this
 	// slice is non-empty, unless verification is delegated to an OS
@@ -704,9 +772,39 @@ type Certificate struct {
 	// handled.
 	UnhandledCriticalExtensions []asn1.ObjectIdentifier
 
+	// UnhandledExtensions lists, in order, the IDs of all the extensions,
+	// critical or not, that were not (fully) processed when parsing, by the
+	// same criteria as UnhandledCriticalExtensions. Such extensions are
+	// carried in Extensions and were either not decoded, or, like policy
+	// mappings, decoded but not enforced by Verify in all cases; the others
+	// were decoded into the fields of Certificate. Together with
+	// OIDExtensions, which are never processed, it lets users measure how
+	// much of the certificates they see this package understands, for
+	// example before rejecting the certificates with unknown extensions.
+	UnhandledExtensions []asn1.ObjectIdentifier
+
 	ExtKeyUsage        []ExtKeyUsage           // Sequence of extended key usages.
 	UnknownExtKeyUsage []asn1.ObjectIdentifier // Encountered extended key usages unknown to this package.
 
+	// UnknownExtKeyUsageOIDs contains all the extended key usages of a
+	// parsed certificate that are unknown to this package, including those
+	// with an arc too large for UnknownExtKeyUsage. When marshaling
+	// certificates, it is used instead of UnknownExtKeyUsage if not empty.
+	UnknownExtKeyUsageOIDs []OID
+
+	// The following fields report whether the corresponding extension was
+	// marked critical in a parsed certificate. They are populated by
+	// ParseCertificate and ignored by CreateCertificate, which always marks
+	// the KeyUsage and BasicConstraints extensions critical and marks the
+	// Subject Alternative Name extension critical only if the subject is
+	// empty. The criticality of the name constraints extension is reported
+	// by PermittedDNSDomainsCritical.
+	KeyUsageCritical          bool
+	ExtKeyUsageCritical       bool
+	BasicConstraintsCritical  bool
+	SubjectAltNameCritical    bool
+	PolicyIdentifiersCritical bool
+
 	// BasicConstraintsValid indicates whether IsCA, MaxPathLen,
 	// and MaxPathLenZero are valid.
 	BasicConstraintsValid bool
@@ -746,6 +844,16 @@ type Certificate struct {
 	IPAddresses    []net.IP
 	URIs           []*url.URL
 
+	// DirectoryNames contains the directoryName entries of the subject
+	// alternative name extension. Some PKIs carry the authoritative
+	// identity of the subject there rather than in Subject.
+	DirectoryNames []pkix.Name
+
+	// IssuerAltNames contains the entries of the issuer alternative name
+	// extension (RFC 5280, 4.2.1.7), or nil if the extension is absent or
+	// malformed. It is not used by CreateCertificate, see ExtraExtensions.
+	IssuerAltNames *AlternativeNames
+
 	// Name constraints
 	PermittedDNSDomainsCritical bool // if true then the name constraints are marked critical.
 	PermittedDNSDomains         []string
@@ -757,16 +865,76 @@ type Certificate struct {
 	PermittedURIDomains         []string
 	ExcludedURIDomains          []string
 
+	// PermittedDirectoryNames and ExcludedDirectoryNames are the
+	// directoryName constraints. A name is within a constraint if the
+	// attributes of the constraint are, in order, a prefix of its own
+	// attributes. When marshaling, the attributes are taken in the order
+	// of pkix.Name.ToRDNSequence.
+	PermittedDirectoryNames []pkix.Name
+	ExcludedDirectoryNames  []pkix.Name
+
 	// CRL Distribution Points
 	CRLDistributionPoints []string
 
+	// PolicyIdentifiers contains the certificate policies that can be
+	// represented by asn1.ObjectIdentifier. See Policies.
 	PolicyIdentifiers []asn1.ObjectIdentifier
+
+	// Policies contains all the certificate policies of a parsed
+	// certificate, including those with an arc too large for
+	// PolicyIdentifiers. When marshaling certificates, it is used instead
+	// of PolicyIdentifiers if not empty.
+	Policies []OID
+
+	// PolicyMappings contains the policy mappings of a CA certificate, see
+	// RFC 5280, Section 4.2.1.5. When marshaling certificates, the extension
+	// is marked critical, as the RFC recommends. As the mappings are only
+	// enforced by Verify when VerifyOptions.CertificatePolicies is set, a
+	// critical policy mappings extension is listed in
+	// UnhandledCriticalExtensions, and accepted by Verify only then.
+	PolicyMappings []PolicyMapping
+
+	// BiometricInfo contains the entries of the qualified certificate
+	// biometricInfo extension, see RFC 3739, Section 3.2.2.
+	BiometricInfo []BiometricData
+
+	// Logotypes contains the logotype extension, see RFC 3709.
+	Logotypes *Logotypes
+}
+
+// A PolicyMapping declares that the IssuerDomainPolicy of the issuing CA is
+// considered equivalent to the SubjectDomainPolicy of the subject CA.
+type PolicyMapping struct {
+	IssuerDomainPolicy  OID
+	SubjectDomainPolicy OID
 }
 
+// ErrSerialInUse is returned by CreateCertificateWithOptions and
+// CreateCertificateVerified if IssuanceOptions.SerialInUse reports that the
+// serial number is already in use.
+var ErrSerialInUse = errors.New("x509: serial number is already in use")
+
 // ErrUnsupportedAlgorithm results from attempting to perform an operation that
 // involves algorithms that are not currently implemented.
 var ErrUnsupportedAlgorithm = errors.New("x509: cannot verify signature: algorithm unimplemented")
 
+// UnknownSignatureAlgorithmError results from checking the signature of a
+// parsed certificate or certificate request whose signature algorithm is not
+// supported. errors.Is reports it as ErrUnsupportedAlgorithm.
+type UnknownSignatureAlgorithmError struct {
+	// Algorithm is the signature algorithm, as it was encoded.
+	Algorithm pkix.AlgorithmIdentifier
+}
+
+func (e UnknownSignatureAlgorithmError) Error() string {
+	return fmt.Sprintf("x509: cannot verify signature: algorithm unimplemented (%v)", e.Algorithm.Algorithm)
+}
+
+// Is reports whether target is ErrUnsupportedAlgorithm.
+func (e UnknownSignatureAlgorithmError) Is(target error) bool {
+	return target == ErrUnsupportedAlgorithm
+}
+
 // An InsecureAlgorithmError
 type InsecureAlgorithmError SignatureAlgorithm
 
@@ -790,24 +958,96 @@ func (c *Certificate) Equal(other *Certificate) bool {
 	return bytes.Equal(c.Raw, other.Raw)
 }
 
-func (c *Certificate) hasSANExtension() bool {
-	return oidInExtensions(oidExtensionSubjectAltName, c.Extensions)
-}
-
-// CheckSignatureFrom verifies that the signature on c is a valid signature
-// from parent.
-func (c *Certificate) CheckSignatureFrom(parent *Certificate) error {
+// IsCACert reports whether the key of c may be used to verify certificate
+// signatures, as CheckSignatureFrom requires of the parent. That is the case
+// for version 3 certificates whose basic constraints extension asserts cA,
+// and for version 1 and 2 certificates, such as old roots, which can't have
+// extensions, unless a key usage extension that is present lacks
+// KeyUsageCertSign. Verify additionally requires the basic constraints
+// extension for intermediates.
+func (c *Certificate) IsCACert() bool {
 	// RFC 5280, 4.2.1.9:
 	// "If the basic constraints extension is not present in a version 3
 	// certificate, or the extension is present but the cA boolean is not
 	// asserted, then the certified public key MUST NOT be used to verify
 	// certificate signatures."
-	if parent.Version == 3 && !parent.BasicConstraintsValid ||
-		parent.BasicConstraintsValid && !parent.IsCA {
-		return ConstraintViolationError{}
+	if c.Version == 3 && !c.BasicConstraintsValid ||
+		c.BasicConstraintsValid && !c.IsCA {
+		return false
+	}
+	return c.KeyUsage == 0 || c.KeyUsage&KeyUsageCertSign != 0
+}
+
+// BasicConstraintsState distinguishes a missing basic constraints extension
+// from one that does not assert cA, which Certificate otherwise both
+// represents with IsCA false.
+type BasicConstraintsState int
+
+const (
+	// BasicConstraintsAbsent means the certificate has no basic constraints
+	// extension, as is always the case for version 1 and 2 certificates.
+	BasicConstraintsAbsent BasicConstraintsState = iota
+	// BasicConstraintsNotCA means the extension is present, but does not
+	// assert cA.
+	BasicConstraintsNotCA
+	// BasicConstraintsCA means the extension is present and asserts cA.
+	BasicConstraintsCA
+)
+
+func (s BasicConstraintsState) String() string {
+	switch s {
+	case BasicConstraintsAbsent:
+		return "absent"
+	case BasicConstraintsNotCA:
+		return "cA=false"
+	case BasicConstraintsCA:
+		return "cA=true"
 	}
+	return fmt.Sprintf("BasicConstraintsState(%d)", int(s))
+}
+
+// BasicConstraintsState returns the state of the basic constraints extension
+// of c, as reflected by BasicConstraintsValid and IsCA.
+func (c *Certificate) BasicConstraintsState() BasicConstraintsState {
+	switch {
+	case !c.BasicConstraintsValid:
+		return BasicConstraintsAbsent
+	case !c.IsCA:
+		return BasicConstraintsNotCA
+	}
+	return BasicConstraintsCA
+}
+
+// IsSelfIssued reports whether the subject and issuer of c are the same, as
+// for roots and for the certificates with which a CA rolls over its key, see
+// RFC 5280, Section 3.2. The names are compared in their DER encodings, so c
+// must have been parsed. The signature is not checked.
+func (c *Certificate) IsSelfIssued() bool {
+	return len(c.RawSubject) > 0 && bytes.Equal(c.RawSubject, c.RawIssuer)
+}
+
+// isSelfSigned reports whether c is self-issued and signed by its own key.
+func (c *Certificate) isSelfSigned() bool {
+	return c.IsSelfIssued() && c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
+
+// IsServerAuthCert reports whether the extended key usages of c allow TLS
+// server authentication, as Verify checks them: c has no extended key usage
+// extension, or it includes ExtKeyUsageServerAuth, ExtKeyUsageAny, or one of
+// the Server Gated Crypto usages. Verify additionally requires the usage to
+// be allowed by every certificate of the chain.
+func (c *Certificate) IsServerAuthCert() bool {
+	return checkChainForKeyUsage([]*Certificate{c}, []ExtKeyUsage{ExtKeyUsageServerAuth})
+}
+
+func (c *Certificate) hasSANExtension() bool {
+	return oidInExtensions(oidExtensionSubjectAltName, c.Extensions)
+}
 
-	if parent.KeyUsage != 0 && parent.KeyUsage&KeyUsageCertSign == 0 {
+// CheckSignatureFrom verifies that the signature on c is a valid signature
+// from parent.
+func (c *Certificate) CheckSignatureFrom(parent *Certificate) error {
+	if !parent.IsCACert() {
 		return ConstraintViolationError{}
 	}
 
@@ -817,6 +1057,9 @@ func (c *Certificate) CheckSignatureFrom(parent *Certificate) error {
 
 	// TODO(agl): don't ignore the path length constraint.
 
+	if c.SignatureAlgorithm == UnknownSignatureAlgorithm && len(c.SignatureAlgorithmIdentifier.Algorithm) > 0 {
+		return UnknownSignatureAlgorithmError{c.SignatureAlgorithmIdentifier}
+	}
 	return parent.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
 }
 
@@ -830,6 +1073,16 @@ func (c *Certificate) hasNameConstraints() bool {
 	return oidInExtensions(oidExtensionNameConstraints, c.Extensions)
 }
 
+// hasTemplateNameConstraints reports whether any of the name constraint fields
+// used by CreateCertificate are populated in c.
+func (c *Certificate) hasTemplateNameConstraints() bool {
+	return len(c.PermittedDNSDomains) > 0 || len(c.ExcludedDNSDomains) > 0 ||
+		len(c.PermittedIPRanges) > 0 || len(c.ExcludedIPRanges) > 0 ||
+		len(c.PermittedEmailAddresses) > 0 || len(c.ExcludedEmailAddresses) > 0 ||
+		len(c.PermittedURIDomains) > 0 || len(c.ExcludedURIDomains) > 0 ||
+		len(c.PermittedDirectoryNames) > 0 || len(c.ExcludedDirectoryNames) > 0
+}
+
 func (c *Certificate) getSANExtension() []byte {
 	for _, e := range c.Extensions {
 		if e.Id.Equal(oidExtensionSubjectAltName) {
@@ -941,17 +1194,12 @@ type basicConstraints struct {
 	MaxPathLen int  `asn1:"optional,default:-1"`
 }
 
-// RFC 5280 4.2.1.4
-type policyInformation struct {
-	Policy asn1.ObjectIdentifier
-	// policyQualifiers omitted
-}
-
 const (
-	nameTypeEmail = 1
-	nameTypeDNS   = 2
-	nameTypeURI   = 6
-	nameTypeIP    = 7
+	nameTypeEmail     = 1
+	nameTypeDNS       = 2
+	nameTypeDirectory = 4
+	nameTypeURI       = 6
+	nameTypeIP        = 7
 )
 
 // RFC 5280, 4.2.2.1
@@ -1074,6 +1322,16 @@ func parsePublicKey(algo PublicKeyAlgorithm, keyData *publicKeyInfo) (interface{
 	}
 }
 
+// AlternativeNames holds the entries of a GeneralNames structure, such as the
+// issuer alternative name extension, that are understood by this package.
+type AlternativeNames struct {
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	DirectoryNames []pkix.Name
+}
+
 func forEachSAN(extension []byte, callback func(tag int, data []byte) error) error {
 	// RFC 5280, 4.2.1.6
 
@@ -1118,9 +1376,19 @@ func forEachSAN(extension []byte, callback func(tag int, data []byte) error) err
 	return nil
 }
 
-func parseSANExtension(value []byte) (dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL, err error) {
+func parseSANExtension(value []byte) (dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL, directoryNames []pkix.Name, err error) {
 	err = forEachSAN(value, func(tag int, data []byte) error {
 		switch tag {
+		case nameTypeDirectory:
+			// Directory names used to be ignored, so a malformed one is
+			// skipped rather than making the certificate unparsable.
+			var rdns pkix.RDNSequence
+			if rest, err := asn1.Unmarshal(data, &rdns); err != nil || len(rest) != 0 {
+				return nil
+			}
+			var name pkix.Name
+			name.FillFromRDNSequence(&rdns)
+			directoryNames = append(directoryNames, name)
 		case nameTypeEmail:
 			emailAddresses = append(emailAddresses, string(data))
 		case nameTypeDNS:
@@ -1211,13 +1479,13 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 		return false, errors.New("x509: empty name constraints extension")
 	}
 
-	getValues := func(subtrees cryptobyte.String) (dnsNames []string, ips []*net.IPNet, emails, uriDomains []string, err error) {
+	getValues := func(subtrees cryptobyte.String) (dnsNames []string, ips []*net.IPNet, emails, uriDomains []string, dirNames []pkix.Name, err error) {
 		for !subtrees.Empty() {
 			var seq, value cryptobyte.String
 			var tag cryptobyte_asn1.Tag
 			if !subtrees.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) ||
 				!seq.ReadAnyASN1(&value, &tag) {
-				return nil, nil, nil, nil, fmt.Errorf("x509: invalid NameConstraints extension")
+				return nil, nil, nil, nil, nil, fmt.Errorf("x509: invalid NameConstraints extension")
 			}
 
 			var (
@@ -1225,13 +1493,14 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 				emailTag = cryptobyte_asn1.Tag(1).ContextSpecific()
 				ipTag    = cryptobyte_asn1.Tag(7).ContextSpecific()
 				uriTag   = cryptobyte_asn1.Tag(6).ContextSpecific()
+				dirTag   = cryptobyte_asn1.Tag(4).ContextSpecific().Constructed()
 			)
 
 			switch tag {
 			case dnsTag:
 				domain := string(value)
 				if err := isIA5String(domain); err != nil {
-					return nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
+					return nil, nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
 				}
 
 				trimmedDomain := domain
@@ -1243,7 +1512,7 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 					trimmedDomain = trimmedDomain[1:]
 				}
 				if _, ok := domainToReverseLabels(trimmedDomain); !ok {
-					return nil, nil, nil, nil, fmt.Errorf("x509: failed to parse dnsName constraint %q", domain)
+					return nil, nil, nil, nil, nil, fmt.Errorf("x509: failed to parse dnsName constraint %q", domain)
 				}
 				dnsNames = append(dnsNames, domain)
 
@@ -1261,11 +1530,11 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 					mask = value[16:]
 
 				default:
-					return nil, nil, nil, nil, fmt.Errorf("x509: IP constraint contained value of length %d", l)
+					return nil, nil, nil, nil, nil, fmt.Errorf("x509: IP constraint contained value of length %d", l)
 				}
 
 				if !isValidIPMask(mask) {
-					return nil, nil, nil, nil, fmt.Errorf("x509: IP constraint contained invalid mask %x", mask)
+					return nil, nil, nil, nil, nil, fmt.Errorf("x509: IP constraint contained invalid mask %x", mask)
 				}
 
 				ips = append(ips, &net.IPNet{IP: net.IP(ip), Mask: net.IPMask(mask)})
@@ -1273,14 +1542,14 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 			case emailTag:
 				constraint := string(value)
 				if err := isIA5String(constraint); err != nil {
-					return nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
+					return nil, nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
 				}
 
 				// If the constraint contains an @ then
 				// it specifies an exact mailbox name.
 				if strings.Contains(constraint, "@") {
 					if _, ok := parseRFC2821Mailbox(constraint); !ok {
-						return nil, nil, nil, nil, fmt.Errorf("x509: failed to parse rfc822Name constraint %q", constraint)
+						return nil, nil, nil, nil, nil, fmt.Errorf("x509: failed to parse rfc822Name constraint %q", constraint)
 					}
 				} else {
 					// Otherwise it's a domain name.
@@ -1289,7 +1558,7 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 						domain = domain[1:]
 					}
 					if _, ok := domainToReverseLabels(domain); !ok {
-						return nil, nil, nil, nil, fmt.Errorf("x509: failed to parse rfc822Name constraint %q", constraint)
+						return nil, nil, nil, nil, nil, fmt.Errorf("x509: failed to parse rfc822Name constraint %q", constraint)
 					}
 				}
 				emails = append(emails, constraint)
@@ -1297,11 +1566,11 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 			case uriTag:
 				domain := string(value)
 				if err := isIA5String(domain); err != nil {
-					return nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
+					return nil, nil, nil, nil, nil, errors.New("x509: invalid constraint value: " + err.Error())
 				}
 
 				if net.ParseIP(domain) != nil {
-					return nil, nil, nil, nil, fmt.Errorf("x509: failed to parse URI constraint %q: cannot be IP address", domain)
+					return nil, nil, nil, nil, nil, fmt.Errorf("x509: failed to parse URI constraint %q: cannot be IP address", domain)
 				}
 
 				trimmedDomain := domain
@@ -1313,22 +1582,31 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 					trimmedDomain = trimmedDomain[1:]
 				}
 				if _, ok := domainToReverseLabels(trimmedDomain); !ok {
-					return nil, nil, nil, nil, fmt.Errorf("x509: failed to parse URI constraint %q", domain)
+					return nil, nil, nil, nil, nil, fmt.Errorf("x509: failed to parse URI constraint %q", domain)
 				}
 				uriDomains = append(uriDomains, domain)
 
+			case dirTag:
+				var rdns pkix.RDNSequence
+				if rest, err := asn1.Unmarshal(value, &rdns); err != nil || len(rest) != 0 {
+					return nil, nil, nil, nil, nil, errors.New("x509: failed to parse directoryName constraint")
+				}
+				var name pkix.Name
+				name.FillFromRDNSequence(&rdns)
+				dirNames = append(dirNames, name)
+
 			default:
 				unhandled = true
 			}
 		}
 
-		return dnsNames, ips, emails, uriDomains, nil
+		return dnsNames, ips, emails, uriDomains, dirNames, nil
 	}
 
-	if out.PermittedDNSDomains, out.PermittedIPRanges, out.PermittedEmailAddresses, out.PermittedURIDomains, err = getValues(permitted); err != nil {
+	if out.PermittedDNSDomains, out.PermittedIPRanges, out.PermittedEmailAddresses, out.PermittedURIDomains, out.PermittedDirectoryNames, err = getValues(permitted); err != nil {
 		return false, err
 	}
-	if out.ExcludedDNSDomains, out.ExcludedIPRanges, out.ExcludedEmailAddresses, out.ExcludedURIDomains, err = getValues(excluded); err != nil {
+	if out.ExcludedDNSDomains, out.ExcludedIPRanges, out.ExcludedEmailAddresses, out.ExcludedURIDomains, out.ExcludedDirectoryNames, err = getValues(excluded); err != nil {
 		return false, err
 	}
 	out.PermittedDNSDomainsCritical = e.Critical
@@ -1336,6 +1614,138 @@ func parseNameConstraintsExtension(out *Certificate, e pkix.Extension) (unhandle
 	return unhandled, nil
 }
 
+// parseExtensions parses a SEQUENCE OF Extension. Extensions whose identifiers
+// can't be represented by asn1.ObjectIdentifier are returned separately.
+func parseExtensions(der []byte) ([]pkix.Extension, []OIDExtension, error) {
+	// RFC 5280, 4.1
+	//
+	// Extension  ::=  SEQUENCE  {
+	//      extnID      OBJECT IDENTIFIER,
+	//      critical    BOOLEAN DEFAULT FALSE,
+	//      extnValue   OCTET STRING  }
+	input := cryptobyte.String(der)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, nil, errors.New("x509: malformed extensions")
+	}
+	var extensions []pkix.Extension
+	var oidExtensions []OIDExtension
+	for !seq.Empty() {
+		var ext, critical cryptobyte.String
+		var id OID
+		var hasCritical bool
+		var value []byte
+		if !seq.ReadASN1(&ext, cryptobyte_asn1.SEQUENCE) ||
+			!readOID(&ext, &id) ||
+			!ext.ReadOptionalASN1(&critical, &hasCritical, cryptobyte_asn1.BOOLEAN) ||
+			!ext.ReadASN1Bytes(&value, cryptobyte_asn1.OCTET_STRING) ||
+			!ext.Empty() ||
+			hasCritical && (len(critical) != 1 || critical[0] != 0 && critical[0] != 0xff) {
+			return nil, nil, errors.New("x509: malformed extension")
+		}
+		isCritical := hasCritical && critical[0] == 0xff
+		if oid, ok := id.toASN1OID(); ok {
+			extensions = append(extensions, pkix.Extension{Id: oid, Critical: isCritical, Value: value})
+		} else {
+			oidExtensions = append(oidExtensions, OIDExtension{Id: id, Critical: isCritical, Value: value})
+		}
+	}
+	return extensions, oidExtensions, nil
+}
+
+// parseExtKeyUsageExtension sets the extended key usages of out from the value
+// of an extended key usage extension.
+func parseExtKeyUsageExtension(out *Certificate, value []byte) error {
+	input := cryptobyte.String(value)
+	var usages cryptobyte.String
+	if !input.ReadASN1(&usages, cryptobyte_asn1.SEQUENCE) {
+		return errors.New("x509: malformed X.509 ExtendedKeyUsage")
+	}
+	if !input.Empty() {
+		return errors.New("x509: trailing data after X.509 ExtendedKeyUsage")
+	}
+	for !usages.Empty() {
+		var u OID
+		if !readOID(&usages, &u) {
+			return errors.New("x509: malformed X.509 ExtendedKeyUsage")
+		}
+		oid, ok := u.toASN1OID()
+		if ok {
+			if extKeyUsage, ok := extKeyUsageFromOID(oid); ok {
+				out.ExtKeyUsage = append(out.ExtKeyUsage, extKeyUsage)
+				continue
+			}
+			out.UnknownExtKeyUsage = append(out.UnknownExtKeyUsage, oid)
+		}
+		out.UnknownExtKeyUsageOIDs = append(out.UnknownExtKeyUsageOIDs, u)
+	}
+	return nil
+}
+
+// parseCertificatePoliciesExtension sets the policies of out from the value of
+// a certificate policies extension. Policy qualifiers are ignored.
+func parseCertificatePoliciesExtension(out *Certificate, value []byte) error {
+	// RFC 5280, 4.2.1.4
+	//
+	// certificatePolicies ::= SEQUENCE SIZE (1..MAX) OF PolicyInformation
+	//
+	// PolicyInformation ::= SEQUENCE {
+	//      policyIdentifier   CertPolicyId,
+	//      policyQualifiers   SEQUENCE SIZE (1..MAX) OF
+	//                              PolicyQualifierInfo OPTIONAL }
+	input := cryptobyte.String(value)
+	var policies cryptobyte.String
+	if !input.ReadASN1(&policies, cryptobyte_asn1.SEQUENCE) {
+		return errors.New("x509: malformed X.509 certificate policies")
+	}
+	if !input.Empty() {
+		return errors.New("x509: trailing data after X.509 certificate policies")
+	}
+	for !policies.Empty() {
+		var policy cryptobyte.String
+		var id OID
+		if !policies.ReadASN1(&policy, cryptobyte_asn1.SEQUENCE) || !readOID(&policy, &id) {
+			return errors.New("x509: malformed X.509 certificate policies")
+		}
+		out.Policies = append(out.Policies, id)
+		if oid, ok := id.toASN1OID(); ok {
+			out.PolicyIdentifiers = append(out.PolicyIdentifiers, oid)
+		}
+	}
+	return nil
+}
+
+// parsePolicyMappingsExtension parses the value of a policy mappings
+// extension.
+func parsePolicyMappingsExtension(value []byte) ([]PolicyMapping, error) {
+	// RFC 5280, 4.2.1.5
+	//
+	// PolicyMappings ::= SEQUENCE SIZE (1..MAX) OF SEQUENCE {
+	//      issuerDomainPolicy      CertPolicyId,
+	//      subjectDomainPolicy     CertPolicyId }
+	input := cryptobyte.String(value)
+	var mappings cryptobyte.String
+	if !input.ReadASN1(&mappings, cryptobyte_asn1.SEQUENCE) || mappings.Empty() {
+		return nil, errors.New("x509: malformed X.509 policy mappings")
+	}
+	if !input.Empty() {
+		return nil, errors.New("x509: trailing data after X.509 policy mappings")
+	}
+	var out []PolicyMapping
+	for !mappings.Empty() {
+		var mapping cryptobyte.String
+		var m PolicyMapping
+		if !mappings.ReadASN1(&mapping, cryptobyte_asn1.SEQUENCE) ||
+			!readOID(&mapping, &m.IssuerDomainPolicy) ||
+			!readOID(&mapping, &m.SubjectDomainPolicy) ||
+			!mapping.Empty() {
+			return nil, errors.New("x509: malformed X.509 policy mappings")
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
 func parseCertificate(in *certificate) (*Certificate, error) {
 	out := new(Certificate)
 	out.Raw = in.Raw
@@ -1347,6 +1757,7 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 	out.Signature = in.SignatureValue.RightAlign()
 	out.SignatureAlgorithm =
 		getSignatureAlgorithmFromAI(in.TBSCertificate.SignatureAlgorithm)
+	out.SignatureAlgorithmIdentifier = in.TBSCertificate.SignatureAlgorithm
 
 	out.PublicKeyAlgorithm =
 		getPublicKeyAlgorithmFromOID(in.TBSCertificate.PublicKey.Algorithm.Algorithm)
@@ -1377,28 +1788,24 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 	out.NotBefore = in.TBSCertificate.Validity.NotBefore
 	out.NotAfter = in.TBSCertificate.Validity.NotAfter
 
-	for _, e := range in.TBSCertificate.Extensions {
-		out.Extensions = append(out.Extensions, e)
+	if len(in.TBSCertificate.Extensions.Bytes) > 0 {
+		if out.Extensions, out.OIDExtensions, err = parseExtensions(in.TBSCertificate.Extensions.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range out.Extensions {
 		unhandled := false
 
 		if len(e.Id) == 4 && e.Id[0] == 2 && e.Id[1] == 5 && e.Id[2] == 29 {
 			switch e.Id[3] {
 			case 15:
 				// RFC 5280, 4.2.1.3
-				var usageBits asn1.BitString
-				if rest, err := asn1.Unmarshal(e.Value, &usageBits); err != nil {
+				if out.KeyUsage, err = parseKeyUsage(e.Value); err != nil {
 					return nil, err
-				} else if len(rest) != 0 {
-					return nil, errors.New("x509: trailing data after X.509 KeyUsage")
 				}
-
-				var usage int
-				for i := 0; i < 9; i++ {
-					if usageBits.At(i) != 0 {
-						usage |= 1 << uint(i)
-					}
-				}
-				out.KeyUsage = KeyUsage(usage)
+				out.RawKeyUsage = e.Value
+				out.KeyUsageCritical = e.Critical
 
 			case 19:
 				// RFC 5280, 4.2.1.9
@@ -1410,21 +1817,33 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 				}
 
 				out.BasicConstraintsValid = true
+				out.BasicConstraintsCritical = e.Critical
 				out.IsCA = constraints.IsCA
 				out.MaxPathLen = constraints.MaxPathLen
 				out.MaxPathLenZero = out.MaxPathLen == 0
 				// TODO: map out.MaxPathLen to 0 if it has the -1 default value? (Issue 19285)
 			case 17:
-				out.DNSNames, out.EmailAddresses, out.IPAddresses, out.URIs, err = parseSANExtension(e.Value)
+				out.DNSNames, out.EmailAddresses, out.IPAddresses, out.URIs, out.DirectoryNames, err = parseSANExtension(e.Value)
 				if err != nil {
 					return nil, err
 				}
+				out.SubjectAltNameCritical = e.Critical
 
-				if len(out.DNSNames) == 0 && len(out.EmailAddresses) == 0 && len(out.IPAddresses) == 0 && len(out.URIs) == 0 {
+				if len(out.DNSNames) == 0 && len(out.EmailAddresses) == 0 && len(out.IPAddresses) == 0 && len(out.URIs) == 0 && len(out.DirectoryNames) == 0 {
 					// If we didn't parse anything then we do the critical check, below.
 					unhandled = true
 				}
 
+			case 18:
+				// RFC 5280, 4.2.1.7. The issuer alternative name is
+				// informational only, so a malformed one is ignored and
+				// remains unhandled.
+				if dnsNames, emailAddresses, ipAddresses, uris, directoryNames, err := parseSANExtension(e.Value); err == nil {
+					out.IssuerAltNames = &AlternativeNames{dnsNames, emailAddresses, ipAddresses, uris, directoryNames}
+				} else {
+					unhandled = true
+				}
+
 			case 30:
 				unhandled, err = parseNameConstraintsExtension(out, e)
 				if err != nil {
@@ -1484,20 +1903,10 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 				//
 				// KeyPurposeId ::= OBJECT IDENTIFIER
 
-				var keyUsage []asn1.ObjectIdentifier
-				if rest, err := asn1.Unmarshal(e.Value, &keyUsage); err != nil {
+				if err := parseExtKeyUsageExtension(out, e.Value); err != nil {
 					return nil, err
-				} else if len(rest) != 0 {
-					return nil, errors.New("x509: trailing data after X.509 ExtendedKeyUsage")
-				}
-
-				for _, u := range keyUsage {
-					if extKeyUsage, ok := extKeyUsageFromOID(u); ok {
-						out.ExtKeyUsage = append(out.ExtKeyUsage, extKeyUsage)
-					} else {
-						out.UnknownExtKeyUsage = append(out.UnknownExtKeyUsage, u)
-					}
 				}
+				out.ExtKeyUsageCritical = e.Critical
 
 			case 14:
 				// RFC 5280, 4.2.1.2
@@ -1511,16 +1920,19 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 
 			case 32:
 				// RFC 5280 4.2.1.4: Certificate Policies
-				var policies []policyInformation
-				if rest, err := asn1.Unmarshal(e.Value, &policies); err != nil {
+				if err := parseCertificatePoliciesExtension(out, e.Value); err != nil {
 					return nil, err
-				} else if len(rest) != 0 {
-					return nil, errors.New("x509: trailing data after X.509 certificate policies")
 				}
-				out.PolicyIdentifiers = make([]asn1.ObjectIdentifier, len(policies))
-				for i, policy := range policies {
-					out.PolicyIdentifiers[i] = policy.Policy
+				out.PolicyIdentifiersCritical = e.Critical
+
+			case 33:
+				// RFC 5280 4.2.1.5: Policy Mappings
+				if out.PolicyMappings, err = parsePolicyMappingsExtension(e.Value); err != nil {
+					return nil, err
 				}
+				// The mappings are only enforced when Verify processes
+				// policies, so they remain unhandled otherwise.
+				unhandled = true
 
 			default:
 				// Unknown extensions are recorded if critical.
@@ -1546,11 +1958,30 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 					out.IssuingCertificateURL = append(out.IssuingCertificateURL, string(v.Location.Bytes))
 				}
 			}
+		} else if e.Id.Equal(oidExtensionBiometricInfo) {
+			// RFC 3739, 3.2.2. Like the issuer alternative name, the
+			// biometric information is informational only, so a malformed
+			// one is ignored and a critical one remains unhandled.
+			if biometricInfo, err := parseBiometricInfoExtension(e.Value); err == nil {
+				out.BiometricInfo = biometricInfo
+			} else {
+				unhandled = true
+			}
+		} else if e.Id.Equal(oidExtensionLogotype) {
+			// RFC 3709. The same applies to logotypes.
+			if logotypes, err := parseLogotypeExtension(e.Value); err == nil {
+				out.Logotypes = logotypes
+			} else {
+				unhandled = true
+			}
 		} else {
 			// Unknown extensions are recorded if critical.
 			unhandled = true
 		}
 
+		if unhandled {
+			out.UnhandledExtensions = append(out.UnhandledExtensions, e.Id)
+		}
 		if e.Critical && unhandled {
 			out.UnhandledCriticalExtensions = append(out.UnhandledCriticalExtensions, e.Id)
 		}
@@ -1600,6 +2031,50 @@ func ParseCertificates(asn1Data []byte) ([]*Certificate, error) {
 	return ret, nil
 }
 
+// parseKeyUsage parses the value of a key usage extension.
+func parseKeyUsage(value []byte) (KeyUsage, error) {
+	var usageBits asn1.BitString
+	if rest, err := asn1.Unmarshal(value, &usageBits); err != nil {
+		return 0, err
+	} else if len(rest) != 0 {
+		return 0, errors.New("x509: trailing data after X.509 KeyUsage")
+	}
+
+	var usage int
+	for i := 0; i < 9; i++ {
+		if usageBits.At(i) != 0 {
+			usage |= 1 << uint(i)
+		}
+	}
+	return KeyUsage(usage), nil
+}
+
+// rawKeyUsageMatches reports whether c.RawKeyUsage is a valid encoding of
+// c.KeyUsage.
+func (c *Certificate) rawKeyUsageMatches() bool {
+	usage, err := parseKeyUsage(c.RawKeyUsage)
+	return err == nil && usage == c.KeyUsage
+}
+
+// checkKeyUsageEncoding checks that value is the DER encoding of a key usage
+// extension. DER requires trailing zero bits of a named bit list to be
+// removed, which also means that at least one bit must be set.
+func checkKeyUsageEncoding(value []byte) error {
+	var usageBits asn1.BitString
+	if rest, err := asn1.Unmarshal(value, &usageBits); err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return errors.New("x509: trailing data after X.509 KeyUsage")
+	}
+	if usageBits.BitLength == 0 {
+		return errors.New("x509: KeyUsage has no bits set")
+	}
+	if usageBits.At(usageBits.BitLength-1) == 0 {
+		return fmt.Errorf("x509: KeyUsage has %d bits, with trailing zero bits", usageBits.BitLength)
+	}
+	return nil
+}
+
 func reverseBitsInAByte(in byte) byte {
 	b1 := in>>4 | in<<4
 	b2 := b1>>2&0x33 | b1<<2&0xcc
@@ -1635,6 +2110,7 @@ var (
 	oidExtensionBasicConstraints      = []int{2, 5, 29, 19}
 	oidExtensionSubjectAltName        = []int{2, 5, 29, 17}
 	oidExtensionCertificatePolicies   = []int{2, 5, 29, 32}
+	oidExtensionPolicyMappings        = []int{2, 5, 29, 33}
 	oidExtensionNameConstraints       = []int{2, 5, 29, 30}
 	oidExtensionCRLDistributionPoints = []int{2, 5, 29, 31}
 	oidExtensionAuthorityInfoAccess   = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
@@ -1659,7 +2135,7 @@ func oidInExtensions(oid asn1.ObjectIdentifier, extensions []pkix.Extension) boo
 
 // marshalSANs marshals a list of addresses into a the contents of an X.509
 // SubjectAlternativeName extension.
-func marshalSANs(dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL) (derBytes []byte, err error) {
+func marshalSANs(dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL, directoryNames []pkix.Name) (derBytes []byte, err error) {
 	var rawValues []asn1.RawValue
 	for _, name := range dnsNames {
 		rawValues = append(rawValues, asn1.RawValue{Tag: nameTypeDNS, Class: 2, Bytes: []byte(name)})
@@ -1678,6 +2154,14 @@ func marshalSANs(dnsNames, emailAddresses []string, ipAddresses []net.IP, uris [
 	for _, uri := range uris {
 		rawValues = append(rawValues, asn1.RawValue{Tag: nameTypeURI, Class: 2, Bytes: []byte(uri.String())})
 	}
+	for _, name := range directoryNames {
+		// Name is a CHOICE, so directoryName is explicitly tagged.
+		rdns, err := asn1.Marshal(name.ToRDNSequence())
+		if err != nil {
+			return nil, err
+		}
+		rawValues = append(rawValues, asn1.RawValue{Tag: nameTypeDirectory, Class: 2, IsCompound: true, Bytes: rdns})
+	}
 	return asn1.Marshal(rawValues)
 }
 
@@ -1691,55 +2175,155 @@ func isIA5String(s string) error {
 	return nil
 }
 
+// validateSANs checks that the subject alternative names of a template are
+// well formed, so that CreateCertificate does not issue certificates that
+// other implementations reject.
+func validateSANs(sans subjectAltNames) error {
+	for _, name := range sans.dnsNames {
+		if !validHostnamePattern(name) {
+			return fmt.Errorf("x509: invalid DNS name %q in template", name)
+		}
+	}
+	for _, email := range sans.emailAddresses {
+		if err := isIA5String(email); err != nil {
+			return err
+		}
+		if _, ok := parseRFC2821Mailbox(email); !ok {
+			return fmt.Errorf("x509: invalid email address %q in template", email)
+		}
+	}
+	for _, uri := range sans.uris {
+		if !uri.IsAbs() {
+			return fmt.Errorf("x509: URI %q in template is not absolute", uri)
+		}
+		if err := isIA5String(uri.String()); err != nil {
+			return err
+		}
+	}
+	for _, ip := range sans.ipAddresses {
+		if l := len(ip); l != net.IPv4len && l != net.IPv6len {
+			return fmt.Errorf("x509: invalid IP address %x in template", []byte(ip))
+		}
+	}
+	return nil
+}
+
+// marshalExtensions returns the explicitly tagged extensions field of a
+// TBSCertificate, holding extensions followed by oidExtensions, or nil if
+// there are none, since RFC 5280 doesn't allow an empty Extensions field.
+func marshalExtensions(extensions []pkix.Extension, oidExtensions []OIDExtension) ([]byte, error) {
+	if len(extensions) == 0 && len(oidExtensions) == 0 {
+		return nil, nil
+	}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.Tag(3).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, e := range extensions {
+				b.MarshalASN1(e)
+			}
+			for _, e := range oidExtensions {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					addOID(b, e.Id)
+					if e.Critical {
+						b.AddASN1Boolean(true)
+					}
+					b.AddASN1OctetString(e.Value)
+				})
+			}
+		})
+	})
+	return b.Bytes()
+}
+
 func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId []byte, subjectKeyId []byte) (ret []pkix.Extension, err error) {
-	ret = make([]pkix.Extension, 10 /* maximum number of elements. */)
+	ret = make([]pkix.Extension, 13 /* maximum number of elements. */)
 	n := 0
 
+	extraExtensions, err := dedupExtensions(template.ExtraExtensions, template.DuplicateHandling)
+	if err != nil {
+		return nil, err
+	}
+	sans, err := dedupSANs(template)
+	if err != nil {
+		return nil, err
+	}
+	if !template.InsecureSkipSANValidation {
+		if err := validateSANs(sans); err != nil {
+			return nil, err
+		}
+	}
+
+	// generate reports whether an extension should be generated from the
+	// template fields.
+	generate := func(id asn1.ObjectIdentifier) bool {
+		if oidInExtensions(id, extraExtensions) {
+			return false
+		}
+		for _, suppressed := range template.SuppressedExtensions {
+			if suppressed.Equal(id) {
+				return false
+			}
+		}
+		return true
+	}
+
 	if template.KeyUsage != 0 &&
-		!oidInExtensions(oidExtensionKeyUsage, template.ExtraExtensions) {
+		generate(oidExtensionKeyUsage) {
 		ret[n].Id = oidExtensionKeyUsage
 		ret[n].Critical = true
 
-		var a [2]byte
-		a[0] = reverseBitsInAByte(byte(template.KeyUsage))
-		a[1] = reverseBitsInAByte(byte(template.KeyUsage >> 8))
+		if template.rawKeyUsageMatches() {
+			ret[n].Value = template.RawKeyUsage
+		} else {
+			var a [2]byte
+			a[0] = reverseBitsInAByte(byte(template.KeyUsage))
+			a[1] = reverseBitsInAByte(byte(template.KeyUsage >> 8))
 
-		l := 1
-		if a[1] != 0 {
-			l = 2
-		}
+			l := 1
+			if a[1] != 0 {
+				l = 2
+			}
 
-		bitString := a[:l]
-		ret[n].Value, err = asn1.Marshal(asn1.BitString{Bytes: bitString, BitLength: asn1BitLength(bitString)})
-		if err != nil {
-			return
+			bitString := a[:l]
+			ret[n].Value, err = asn1.Marshal(asn1.BitString{Bytes: bitString, BitLength: asn1BitLength(bitString)})
+			if err != nil {
+				return
+			}
 		}
 		n++
 	}
 
-	if (len(template.ExtKeyUsage) > 0 || len(template.UnknownExtKeyUsage) > 0) &&
-		!oidInExtensions(oidExtensionExtendedKeyUsage, template.ExtraExtensions) {
+	if (len(template.ExtKeyUsage) > 0 || len(template.UnknownExtKeyUsage) > 0 || len(template.UnknownExtKeyUsageOIDs) > 0) &&
+		generate(oidExtensionExtendedKeyUsage) {
 		ret[n].Id = oidExtensionExtendedKeyUsage
 
-		var oids []asn1.ObjectIdentifier
-		for _, u := range template.ExtKeyUsage {
-			if oid, ok := oidFromExtKeyUsage(u); ok {
-				oids = append(oids, oid)
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, u := range template.ExtKeyUsage {
+				if oid, ok := oidFromExtKeyUsage(u); ok {
+					b.AddASN1ObjectIdentifier(oid)
+				} else {
+					panic("internal error")
+				}
+			}
+			if len(template.UnknownExtKeyUsageOIDs) > 0 {
+				for _, oid := range template.UnknownExtKeyUsageOIDs {
+					addOID(b, oid)
+				}
 			} else {
-				panic("internal error")
+				for _, oid := range template.UnknownExtKeyUsage {
+					b.AddASN1ObjectIdentifier(oid)
+				}
 			}
-		}
-
-		oids = append(oids, template.UnknownExtKeyUsage...)
-
-		ret[n].Value, err = asn1.Marshal(oids)
+		})
+		ret[n].Value, err = b.Bytes()
 		if err != nil {
 			return
 		}
 		n++
 	}
 
-	if template.BasicConstraintsValid && !oidInExtensions(oidExtensionBasicConstraints, template.ExtraExtensions) {
+	if template.BasicConstraintsValid && generate(oidExtensionBasicConstraints) {
 		// Leaving MaxPathLen as zero indicates that no maximum path
 		// length is desired, unless MaxPathLenZero is set. A value of
 		// -1 causes encoding/asn1 to omit the value as desired.
@@ -1756,7 +2340,7 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 		n++
 	}
 
-	if len(subjectKeyId) > 0 && !oidInExtensions(oidExtensionSubjectKeyId, template.ExtraExtensions) {
+	if len(subjectKeyId) > 0 && generate(oidExtensionSubjectKeyId) {
 		ret[n].Id = oidExtensionSubjectKeyId
 		ret[n].Value, err = asn1.Marshal(subjectKeyId)
 		if err != nil {
@@ -1765,7 +2349,7 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 		n++
 	}
 
-	if len(authorityKeyId) > 0 && !oidInExtensions(oidExtensionAuthorityKeyId, template.ExtraExtensions) {
+	if len(authorityKeyId) > 0 && generate(oidExtensionAuthorityKeyId) {
 		ret[n].Id = oidExtensionAuthorityKeyId
 		ret[n].Value, err = asn1.Marshal(authKeyId{authorityKeyId})
 		if err != nil {
@@ -1775,7 +2359,7 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 	}
 
 	if (len(template.OCSPServer) > 0 || len(template.IssuingCertificateURL) > 0) &&
-		!oidInExtensions(oidExtensionAuthorityInfoAccess, template.ExtraExtensions) {
+		generate(oidExtensionAuthorityInfoAccess) {
 		ret[n].Id = oidExtensionAuthorityInfoAccess
 		var aiaValues []authorityInfoAccess
 		for _, name := range template.OCSPServer {
@@ -1797,39 +2381,70 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 		n++
 	}
 
-	if (len(template.DNSNames) > 0 || len(template.EmailAddresses) > 0 || len(template.IPAddresses) > 0 || len(template.URIs) > 0) &&
-		!oidInExtensions(oidExtensionSubjectAltName, template.ExtraExtensions) {
+	if (len(sans.dnsNames) > 0 || len(sans.emailAddresses) > 0 || len(sans.ipAddresses) > 0 || len(sans.uris) > 0 || len(sans.directoryNames) > 0) &&
+		generate(oidExtensionSubjectAltName) {
 		ret[n].Id = oidExtensionSubjectAltName
 		// From RFC 5280, Section 4.2.1.6:
 		// “If the subject field contains an empty sequence ... then
 		// subjectAltName extension ... is marked as critical”
 		ret[n].Critical = subjectIsEmpty
-		ret[n].Value, err = marshalSANs(template.DNSNames, template.EmailAddresses, template.IPAddresses, template.URIs)
+		ret[n].Value, err = marshalSANs(sans.dnsNames, sans.emailAddresses, sans.ipAddresses, sans.uris, sans.directoryNames)
 		if err != nil {
 			return
 		}
 		n++
 	}
 
-	if len(template.PolicyIdentifiers) > 0 &&
-		!oidInExtensions(oidExtensionCertificatePolicies, template.ExtraExtensions) {
+	if (len(template.PolicyIdentifiers) > 0 || len(template.Policies) > 0) &&
+		generate(oidExtensionCertificatePolicies) {
 		ret[n].Id = oidExtensionCertificatePolicies
-		policies := make([]policyInformation, len(template.PolicyIdentifiers))
-		for i, policy := range template.PolicyIdentifiers {
-			policies[i].Policy = policy
+
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			if len(template.Policies) > 0 {
+				for _, policy := range template.Policies {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						addOID(b, policy)
+					})
+				}
+			} else {
+				for _, policy := range template.PolicyIdentifiers {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1ObjectIdentifier(policy)
+					})
+				}
+			}
+		})
+		ret[n].Value, err = b.Bytes()
+		if err != nil {
+			return
 		}
-		ret[n].Value, err = asn1.Marshal(policies)
+		n++
+	}
+
+	if len(template.PolicyMappings) > 0 &&
+		generate(oidExtensionPolicyMappings) {
+		ret[n].Id = oidExtensionPolicyMappings
+		ret[n].Critical = true
+
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, mapping := range template.PolicyMappings {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					addOID(b, mapping.IssuerDomainPolicy)
+					addOID(b, mapping.SubjectDomainPolicy)
+				})
+			}
+		})
+		ret[n].Value, err = b.Bytes()
 		if err != nil {
 			return
 		}
 		n++
 	}
 
-	if (len(template.PermittedDNSDomains) > 0 || len(template.ExcludedDNSDomains) > 0 ||
-		len(template.PermittedIPRanges) > 0 || len(template.ExcludedIPRanges) > 0 ||
-		len(template.PermittedEmailAddresses) > 0 || len(template.ExcludedEmailAddresses) > 0 ||
-		len(template.PermittedURIDomains) > 0 || len(template.ExcludedURIDomains) > 0) &&
-		!oidInExtensions(oidExtensionNameConstraints, template.ExtraExtensions) {
+	if template.hasTemplateNameConstraints() &&
+		generate(oidExtensionNameConstraints) {
 		ret[n].Id = oidExtensionNameConstraints
 		ret[n].Critical = template.PermittedDNSDomainsCritical
 
@@ -1841,7 +2456,7 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 			return ipAndMask
 		}
 
-		serialiseConstraints := func(dns []string, ips []*net.IPNet, emails []string, uriDomains []string) (der []byte, err error) {
+		serialiseConstraints := func(dns []string, ips []*net.IPNet, emails []string, uriDomains []string, dirNames []pkix.Name) (der []byte, err error) {
 			var b cryptobyte.Builder
 
 			for _, name := range dns {
@@ -1888,15 +2503,29 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 				})
 			}
 
+			for _, dirName := range dirNames {
+				rdns, err := asn1.Marshal(dirName.ToRDNSequence())
+				if err != nil {
+					return nil, err
+				}
+
+				// Name is a CHOICE, so directoryName is explicitly tagged.
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.Tag(4).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+						b.AddBytes(rdns)
+					})
+				})
+			}
+
 			return b.Bytes()
 		}
 
-		permitted, err := serialiseConstraints(template.PermittedDNSDomains, template.PermittedIPRanges, template.PermittedEmailAddresses, template.PermittedURIDomains)
+		permitted, err := serialiseConstraints(template.PermittedDNSDomains, template.PermittedIPRanges, template.PermittedEmailAddresses, template.PermittedURIDomains, template.PermittedDirectoryNames)
 		if err != nil {
 			return nil, err
 		}
 
-		excluded, err := serialiseConstraints(template.ExcludedDNSDomains, template.ExcludedIPRanges, template.ExcludedEmailAddresses, template.ExcludedURIDomains)
+		excluded, err := serialiseConstraints(template.ExcludedDNSDomains, template.ExcludedIPRanges, template.ExcludedEmailAddresses, template.ExcludedURIDomains, template.ExcludedDirectoryNames)
 		if err != nil {
 			return nil, err
 		}
@@ -1924,7 +2553,7 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 	}
 
 	if len(template.CRLDistributionPoints) > 0 &&
-		!oidInExtensions(oidExtensionCRLDistributionPoints, template.ExtraExtensions) {
+		generate(oidExtensionCRLDistributionPoints) {
 		ret[n].Id = oidExtensionCRLDistributionPoints
 
 		var crlDp []distributionPoint
@@ -1946,11 +2575,43 @@ func buildExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId
 		n++
 	}
 
+	if len(template.BiometricInfo) > 0 &&
+		generate(oidExtensionBiometricInfo) {
+		ret[n].Id = oidExtensionBiometricInfo
+		ret[n].Value, err = marshalBiometricInfo(template.BiometricInfo)
+		if err != nil {
+			return
+		}
+		n++
+	}
+
+	if template.Logotypes != nil &&
+		generate(oidExtensionLogotype) {
+		ret[n].Id = oidExtensionLogotype
+		ret[n].Value, err = marshalLogotypes(template.Logotypes)
+		if err != nil {
+			return
+		}
+		n++
+	}
+
 	// Adding another extension here? Remember to update the maximum number
 	// of elements in the make() at the top of the function and the list of
 	// template fields used in CreateCertificate documentation.
 
-	return append(ret[:n], template.ExtraExtensions...), nil
+	ret = append(ret[:n], extraExtensions...)
+
+	if subjectIsEmpty {
+		// RFC 5280, Section 4.2.1.6: the identity of a certificate with an
+		// empty subject is in its subjectAltName extension, which must be
+		// critical.
+		for i := range ret {
+			if ret[i].Id.Equal(oidExtensionSubjectAltName) {
+				ret[i].Critical = true
+			}
+		}
+	}
+	return ret, nil
 }
 
 func subjectBytes(cert *Certificate) ([]byte, error) {
@@ -2043,36 +2704,52 @@ var emptyASN1Subject = []byte{0x30, 0}
 //
 //  - AuthorityKeyId
 //  - BasicConstraintsValid
+//  - BiometricInfo
 //  - CRLDistributionPoints
+//  - DirectoryNames
 //  - DNSNames
+//  - DuplicateHandling
 //  - EmailAddresses
+//  - EnforceNameConstraints
+//  - ExcludedDirectoryNames
 //  - ExcludedDNSDomains
 //  - ExcludedEmailAddresses
 //  - ExcludedIPRanges
 //  - ExcludedURIDomains
 //  - ExtKeyUsage
 //  - ExtraExtensions
+//  - InsecureSkipSANValidation
 //  - IPAddresses
 //  - IsCA
 //  - IssuingCertificateURL
 //  - KeyUsage
+//  - Logotypes
 //  - MaxPathLen
 //  - MaxPathLenZero
 //  - NotAfter
 //  - NotBefore
 //  - OCSPServer
+//  - OIDExtensions
+//  - PermittedDirectoryNames
 //  - PermittedDNSDomains
 //  - PermittedDNSDomainsCritical
 //  - PermittedEmailAddresses
 //  - PermittedIPRanges
 //  - PermittedURIDomains
 //  - PolicyIdentifiers
+//  - Policies
+//  - PolicyMappings
+//  - RawKeyUsage
+//  - RejectUnicodeDNSNames
 //  - SerialNumber
 //  - SignatureAlgorithm
+//  - SignatureAlgorithms
 //  - Subject
 //  - SubjectKeyId
+//  - SuppressedExtensions
 //  - URIs
 //  - UnknownExtKeyUsage
+//  - UnknownExtKeyUsageOIDs
 //
 // The certificate is signed by parent. If parent is equal to template then the
 // certificate is self-signed. The parameter pub is the public key of the
@@ -2090,7 +2767,37 @@ var emptyASN1Subject = []byte{0x30, 0}
 //
 // If SubjectKeyId from template is empty and the template is a CA, SubjectKeyId
 // will be generated from the hash of the public key.
+//
+// An extension that appears more than once in ExtraExtensions, or a repeated
+// subject alternative name, is an error unless template.DuplicateHandling is
+// DuplicatesOverride. Malformed subject alternative names are an error unless
+// template.InsecureSkipSANValidation is set. Internationalized DNS names are
+// converted to their ASCII form, as described in RFC 5280, Section 7.2,
+// unless template.RejectUnicodeDNSNames is set.
+//
+// If the Subject is empty, the subject alternative name extension, from the
+// template fields or from ExtraExtensions, is marked critical, as required
+// by RFC 5280, Section 4.2.1.6.
+//
+// If template.SignatureAlgorithms is not empty, template.SignatureAlgorithm
+// must be zero. The algorithms that priv cannot use with its key type are
+// skipped, and the others are tried in order until priv signs successfully.
+// If none works, the error is a *SignatureAlgorithmsError. The algorithm
+// that was used is the SignatureAlgorithm of the parsed certificate, see
+// also PeekSignatureAlgorithm.
+//
+// SerialNumber must be positive, see CreateCertificateWithOptions to issue
+// test fixtures with other serial numbers or to detect serial number
+// collisions. If template.EnforceNameConstraints is set, names that the name
+// constraints of parent don't allow are an error.
 func CreateCertificate(rand io.Reader, template, parent *Certificate, pub, priv interface{}) (cert []byte, err error) {
+	return CreateCertificateWithOptions(rand, template, parent, pub, priv, IssuanceOptions{})
+}
+
+// createCertificate implements CreateCertificate. The names of the new
+// certificate, unless it is self-issued, are checked against the name
+// constraints of constrainingCAs.
+func createCertificate(rand io.Reader, template, parent *Certificate, pub, priv interface{}, constrainingCAs []*Certificate, opts IssuanceOptions) (cert []byte, err error) {
 	key, ok := priv.(crypto.Signer)
 	if !ok {
 		return nil, errors.New("x509: certificate private key does not implement crypto.Signer")
@@ -2099,12 +2806,18 @@ func CreateCertificate(rand io.Reader, template, parent *Certificate, pub, priv
 	if template.SerialNumber == nil {
 		return nil, errors.New("x509: no SerialNumber given")
 	}
+	if template.SerialNumber.Sign() <= 0 && !opts.InsecureAllowNonPositiveSerial {
+		return nil, errors.New("x509: SerialNumber must be positive")
+	}
+	if opts.SerialInUse != nil && opts.SerialInUse(template.SerialNumber) {
+		return nil, ErrSerialInUse
+	}
 
 	if template.BasicConstraintsValid && !template.IsCA && template.MaxPathLen != -1 && (template.MaxPathLen != 0 || template.MaxPathLenZero) {
 		return nil, errors.New("x509: only CAs are allowed to specify MaxPathLen")
 	}
 
-	hashFunc, signatureAlgorithm, err := signingParamsForPublicKey(key.Public(), template.SignatureAlgorithm)
+	params, err := signingParamsFor(key, template.SignatureAlgorithm, template.SignatureAlgorithms)
 	if err != nil {
 		return nil, err
 	}
@@ -2143,42 +2856,37 @@ func CreateCertificate(rand io.Reader, template, parent *Certificate, pub, priv
 	if err != nil {
 		return
 	}
-
-	encodedPublicKey := asn1.BitString{BitLength: len(publicKeyBytes) * 8, Bytes: publicKeyBytes}
-	c := tbsCertificate{
-		Version:            2,
-		SerialNumber:       template.SerialNumber,
-		SignatureAlgorithm: signatureAlgorithm,
-		Issuer:             asn1.RawValue{FullBytes: asn1Issuer},
-		Validity:           validity{template.NotBefore.UTC(), template.NotAfter.UTC()},
-		Subject:            asn1.RawValue{FullBytes: asn1Subject},
-		PublicKey:          publicKeyInfo{nil, publicKeyAlgorithm, encodedPublicKey},
-		Extensions:         extensions,
-	}
-
-	tbsCertContents, err := asn1.Marshal(c)
+	rawExtensions, err := marshalExtensions(extensions, template.OIDExtensions)
 	if err != nil {
 		return
 	}
-	c.Raw = tbsCertContents
-
-	signed := tbsCertContents
-	if hashFunc != 0 {
-		h := hashFunc.New()
-		h.Write(signed)
-		signed = h.Sum(nil)
-	}
 
-	var signerOpts crypto.SignerOpts = hashFunc
-	if template.SignatureAlgorithm != 0 && template.SignatureAlgorithm.isRSAPSS() {
-		signerOpts = &rsa.PSSOptions{
-			SaltLength: rsa.PSSSaltLengthEqualsHash,
-			Hash:       hashFunc,
+	if len(constrainingCAs) > 0 && !bytes.Equal(asn1Issuer, asn1Subject) {
+		if err = checkIssuedNames(constrainingCAs, asn1Subject, extensions); err != nil {
+			return nil, err
 		}
 	}
 
-	var signature []byte
-	signature, err = key.Sign(rand, signed, signerOpts)
+	encodedPublicKey := asn1.BitString{BitLength: len(publicKeyBytes) * 8, Bytes: publicKeyBytes}
+	c := tbsCertificate{
+		Version:      2,
+		SerialNumber: template.SerialNumber,
+		Issuer:       asn1.RawValue{FullBytes: asn1Issuer},
+		Validity:     validity{template.NotBefore.UTC(), template.NotAfter.UTC()},
+		Subject:      asn1.RawValue{FullBytes: asn1Subject},
+		PublicKey:    publicKeyInfo{nil, publicKeyAlgorithm, encodedPublicKey},
+		Extensions:   asn1.RawValue{FullBytes: rawExtensions},
+	}
+
+	// The TBSCertificate includes the signature algorithm, so it is
+	// marshaled again for each algorithm.
+	signatureAlgorithm, signature, err := sign(rand, key, params, func(sigAlgo pkix.AlgorithmIdentifier) ([]byte, error) {
+		c.SignatureAlgorithm = sigAlgo
+		c.Raw = nil
+		tbsCertContents, err := asn1.Marshal(c)
+		c.Raw = tbsCertContents
+		return tbsCertContents, err
+	})
 	if err != nil {
 		return
 	}
@@ -2302,6 +3010,11 @@ type CertificateRequest struct {
 	Signature          []byte
 	SignatureAlgorithm SignatureAlgorithm
 
+	// SignatureAlgorithmIdentifier is the signature algorithm of a parsed
+	// request, as it was encoded. See the Certificate field of the same
+	// name. It is ignored by CreateCertificateRequest.
+	SignatureAlgorithmIdentifier pkix.AlgorithmIdentifier
+
 	PublicKeyAlgorithm PublicKeyAlgorithm
 	PublicKey          interface{}
 
@@ -2319,6 +3032,11 @@ type CertificateRequest struct {
 	// package.
 	Extensions []pkix.Extension
 
+	// OIDExtensions contains the requested extensions whose identifiers have
+	// an arc too large for asn1.ObjectIdentifier, and which are therefore
+	// missing from Extensions. It is ignored by CreateCertificateRequest.
+	OIDExtensions []OIDExtension
+
 	// ExtraExtensions contains extensions to be copied, raw, into any CSR
 	// marshaled by CreateCertificateRequest. Values override any extensions
 	// that would otherwise be produced based on the other fields but are
@@ -2333,6 +3051,12 @@ type CertificateRequest struct {
 	EmailAddresses []string
 	IPAddresses    []net.IP
 	URIs           []*url.URL
+
+	// SignatureAlgorithms lists the signature algorithms
+	// CreateCertificateRequest may sign with, in order of preference, as
+	// Certificate.SignatureAlgorithms does for CreateCertificate. It is not
+	// populated by ParseCertificateRequest.
+	SignatureAlgorithms []SignatureAlgorithm
 }
 
 // These structures reflect the ASN.1 structure of X.509 certificate
@@ -2392,7 +3116,7 @@ func parseRawAttributes(rawAttributes []asn1.RawValue) []pkix.AttributeTypeAndVa
 
 // parseCSRExtensions parses the attributes from a CSR and extracts any
 // requested extensions.
-func parseCSRExtensions(rawAttributes []asn1.RawValue) ([]pkix.Extension, error) {
+func parseCSRExtensions(rawAttributes []asn1.RawValue) ([]pkix.Extension, []OIDExtension, error) {
 	// pkcs10Attribute reflects the Attribute structure from RFC 2986, Section 4.1.
 	type pkcs10Attribute struct {
 		Id     asn1.ObjectIdentifier
@@ -2400,6 +3124,7 @@ func parseCSRExtensions(rawAttributes []asn1.RawValue) ([]pkix.Extension, error)
 	}
 
 	var ret []pkix.Extension
+	var oidRet []OIDExtension
 	for _, rawAttr := range rawAttributes {
 		var attr pkcs10Attribute
 		if rest, err := asn1.Unmarshal(rawAttr.FullBytes, &attr); err != nil || len(rest) != 0 || len(attr.Values) == 0 {
@@ -2411,20 +3136,22 @@ func parseCSRExtensions(rawAttributes []asn1.RawValue) ([]pkix.Extension, error)
 			continue
 		}
 
-		var extensions []pkix.Extension
-		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &extensions); err != nil {
-			return nil, err
+		extensions, oidExtensions, err := parseExtensions(attr.Values[0].FullBytes)
+		if err != nil {
+			return nil, nil, err
 		}
 		ret = append(ret, extensions...)
+		oidRet = append(oidRet, oidExtensions...)
 	}
 
-	return ret, nil
+	return ret, oidRet, nil
 }
 
 // CreateCertificateRequest creates a new certificate request based on a
 // template. The following members of template are used:
 //
 //  - SignatureAlgorithm
+//  - SignatureAlgorithms
 //  - Subject
 //  - DNSNames
 //  - EmailAddresses
@@ -2440,15 +3167,15 @@ func parseCSRExtensions(rawAttributes []asn1.RawValue) ([]pkix.Extension, error)
 // ed25519.PrivateKey satisfies this.)
 //
 // The returned slice is the certificate request in DER encoding.
+//
+// template.SignatureAlgorithms is handled as by CreateCertificate.
 func CreateCertificateRequest(rand io.Reader, template *CertificateRequest, priv interface{}) (csr []byte, err error) {
 	key, ok := priv.(crypto.Signer)
 	if !ok {
 		return nil, errors.New("x509: certificate private key does not implement crypto.Signer")
 	}
 
-	var hashFunc crypto.Hash
-	var sigAlgo pkix.AlgorithmIdentifier
-	hashFunc, sigAlgo, err = signingParamsForPublicKey(key.Public(), template.SignatureAlgorithm)
+	params, err := signingParamsFor(key, template.SignatureAlgorithm, template.SignatureAlgorithms)
 	if err != nil {
 		return nil, err
 	}
@@ -2464,7 +3191,7 @@ func CreateCertificateRequest(rand io.Reader, template *CertificateRequest, priv
 
 	if (len(template.DNSNames) > 0 || len(template.EmailAddresses) > 0 || len(template.IPAddresses) > 0 || len(template.URIs) > 0) &&
 		!oidInExtensions(oidExtensionSubjectAltName, template.ExtraExtensions) {
-		sanBytes, err := marshalSANs(template.DNSNames, template.EmailAddresses, template.IPAddresses, template.URIs)
+		sanBytes, err := marshalSANs(template.DNSNames, template.EmailAddresses, template.IPAddresses, template.URIs, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -2586,15 +3313,9 @@ func CreateCertificateRequest(rand io.Reader, template *CertificateRequest, priv
 	}
 	tbsCSR.Raw = tbsCSRContents
 
-	signed := tbsCSRContents
-	if hashFunc != 0 {
-		h := hashFunc.New()
-		h.Write(signed)
-		signed = h.Sum(nil)
-	}
-
-	var signature []byte
-	signature, err = key.Sign(rand, signed, hashFunc)
+	sigAlgo, signature, err := sign(rand, key, params, func(pkix.AlgorithmIdentifier) ([]byte, error) {
+		return tbsCSRContents, nil
+	})
 	if err != nil {
 		return
 	}
@@ -2631,8 +3352,9 @@ func parseCertificateRequest(in *certificateRequest) (*CertificateRequest, error
 		RawSubjectPublicKeyInfo:  in.TBSCSR.PublicKey.Raw,
 		RawSubject:               in.TBSCSR.Subject.FullBytes,
 
-		Signature:          in.SignatureValue.RightAlign(),
-		SignatureAlgorithm: getSignatureAlgorithmFromAI(in.SignatureAlgorithm),
+		Signature:                    in.SignatureValue.RightAlign(),
+		SignatureAlgorithm:           getSignatureAlgorithmFromAI(in.SignatureAlgorithm),
+		SignatureAlgorithmIdentifier: in.SignatureAlgorithm,
 
 		PublicKeyAlgorithm: getPublicKeyAlgorithmFromOID(in.TBSCSR.PublicKey.Algorithm.Algorithm),
 
@@ -2655,13 +3377,13 @@ func parseCertificateRequest(in *certificateRequest) (*CertificateRequest, error
 
 	out.Subject.FillFromRDNSequence(&subject)
 
-	if out.Extensions, err = parseCSRExtensions(in.TBSCSR.RawAttributes); err != nil {
+	if out.Extensions, out.OIDExtensions, err = parseCSRExtensions(in.TBSCSR.RawAttributes); err != nil {
 		return nil, err
 	}
 
 	for _, extension := range out.Extensions {
 		if extension.Id.Equal(oidExtensionSubjectAltName) {
-			out.DNSNames, out.EmailAddresses, out.IPAddresses, out.URIs, err = parseSANExtension(extension.Value)
+			out.DNSNames, out.EmailAddresses, out.IPAddresses, out.URIs, _, err = parseSANExtension(extension.Value)
 			if err != nil {
 				return nil, err
 			}
@@ -2673,6 +3395,9 @@ func parseCertificateRequest(in *certificateRequest) (*CertificateRequest, error
 
 // CheckSignature reports whether the signature on c is valid.
 func (c *CertificateRequest) CheckSignature() error {
+	if c.SignatureAlgorithm == UnknownSignatureAlgorithm && len(c.SignatureAlgorithmIdentifier.Algorithm) > 0 {
+		return UnknownSignatureAlgorithmError{c.SignatureAlgorithmIdentifier}
+	}
 	return checkSignature(c.SignatureAlgorithm, c.RawTBSCertificateRequest, c.Signature, c.PublicKey)
 }
 
@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"internal/testenv"
 	"math/big"
@@ -29,8 +30,12 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
 )
 
 func TestParsePKCS1PrivateKey(t *testing.T) {
@@ -440,6 +445,58 @@ func TestMatchIP(t *testing.T) {
 	}
 }
 
+func TestVerifyHostnames(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Leaf"},
+		NotBefore:    root.NotBefore,
+		NotAfter:     root.NotAfter,
+		DNSNames:     []string{"example.com", "*.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("192.0.2.1")},
+	}
+	der, err := CreateCertificate(rand.Reader, template, root, rootKey.(crypto.Signer).Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leaf.VerifyHostnames([]string{"example.com", "www.example.com", "[192.0.2.1]"}); err != nil {
+		t.Errorf("VerifyHostnames: %v", err)
+	}
+	err = leaf.VerifyHostnames([]string{"example.org", "www.example.com", "a.b.example.com", "192.0.2.2"})
+	herr, ok := err.(HostnamesError)
+	if !ok {
+		t.Fatalf("VerifyHostnames returned %v, want a HostnamesError", err)
+	}
+	var hosts []string
+	for _, e := range herr.Errors {
+		if e.Certificate != leaf {
+			t.Errorf("error for %s is about the wrong certificate", e.Host)
+		}
+		hosts = append(hosts, e.Host)
+	}
+	if want := []string{"example.org", "a.b.example.com", "192.0.2.2"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("got errors for %q, want %q", hosts, want)
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, DNSNames: []string{"example.com", "www.example.com"}}); err != nil {
+		t.Errorf("Verify with DNSNames: %v", err)
+	}
+	_, err = leaf.Verify(VerifyOptions{Roots: roots, DNSName: "example.com", DNSNames: []string{"example.net"}})
+	if herr, ok := err.(HostnamesError); !ok || len(herr.Errors) != 1 || herr.Errors[0].Host != "example.net" {
+		t.Errorf("Verify with DNSNames returned %v", err)
+	}
+}
+
 func TestCertificateParse(t *testing.T) {
 	s, _ := base64.StdEncoding.DecodeString(certBytes)
 	certs, err := ParseCertificates(s)
@@ -653,6 +710,8 @@ func TestCreateSelfSignedCertificate(t *testing.T) {
 			ExcludedEmailAddresses:  []string{".example.com", "example.com"},
 			PermittedURIDomains:     []string{".bar.com", "bar.com"},
 			ExcludedURIDomains:      []string{".bar2.com", "bar2.com"},
+			PermittedDirectoryNames: []pkix.Name{{Country: []string{"US"}, Organization: []string{"Acme Co"}}},
+			ExcludedDirectoryNames:  []pkix.Name{{Country: []string{"US"}, Organization: []string{"Acme Co"}, OrganizationalUnit: []string{"Ops"}}},
 
 			CRLDistributionPoints: []string{"http://crl1.example.com/ca1.crl", "http://crl2.example.com/ca1.crl"},
 
@@ -670,7 +729,7 @@ func TestCreateSelfSignedCertificate(t *testing.T) {
 			},
 		}
 
-		derBytes, err := CreateCertificate(random, &template, &template, test.pub, test.priv)
+		derBytes, err := CreateCertificateWithOptions(random, &template, &template, test.pub, test.priv, IssuanceOptions{InsecureAllowNonPositiveSerial: true})
 		if err != nil {
 			t.Errorf("%s: failed to create certificate: %s", test.name, err)
 			continue
@@ -718,6 +777,14 @@ func TestCreateSelfSignedCertificate(t *testing.T) {
 			t.Errorf("%s: failed to parse excluded URIs: %#v", test.name, cert.ExcludedURIDomains)
 		}
 
+		if len(cert.PermittedDirectoryNames) != 1 || cert.PermittedDirectoryNames[0].String() != "O=Acme Co,C=US" {
+			t.Errorf("%s: failed to parse permitted directory names: %v", test.name, cert.PermittedDirectoryNames)
+		}
+
+		if len(cert.ExcludedDirectoryNames) != 1 || cert.ExcludedDirectoryNames[0].String() != "OU=Ops,O=Acme Co,C=US" {
+			t.Errorf("%s: failed to parse excluded directory names: %v", test.name, cert.ExcludedDirectoryNames)
+		}
+
 		if cert.Subject.CommonName != commonName {
 			t.Errorf("%s: subject wasn't correctly copied from the template. Got %s, want %s", test.name, cert.Subject.CommonName, commonName)
 		}
@@ -1497,7 +1564,7 @@ func marshalAndParseCSR(t *testing.T, template *CertificateRequest) *Certificate
 }
 
 func TestCertificateRequestOverrides(t *testing.T) {
-	sanContents, err := marshalSANs([]string{"foo.example.com"}, nil, nil, nil)
+	sanContents, err := marshalSANs([]string{"foo.example.com"}, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1559,7 +1626,7 @@ func TestCertificateRequestOverrides(t *testing.T) {
 		t.Errorf("bad attributes: %#v\n", csr.Attributes)
 	}
 
-	sanContents2, err := marshalSANs([]string{"foo2.example.com"}, nil, nil, nil)
+	sanContents2, err := marshalSANs([]string{"foo2.example.com"}, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1662,6 +1729,124 @@ func serialiseAndParse(t *testing.T, template *Certificate) *Certificate {
 	return cert
 }
 
+func TestCreateCertificateValidatesSANs(t *testing.T) {
+	relative, _ := url.Parse("/relative/path")
+	tests := []struct {
+		name string
+		mod  func(*Certificate)
+	}{
+		{"NUL in DNS name", func(c *Certificate) { c.DNSNames = []string{"example.com\x00.evil.com"} }},
+		{"space in DNS name", func(c *Certificate) { c.DNSNames = []string{"example .com"} }},
+		{"leading dot in DNS name", func(c *Certificate) { c.DNSNames = []string{".example.com"} }},
+		{"bad email address", func(c *Certificate) { c.EmailAddresses = []string{"not an address"} }},
+		{"relative URI", func(c *Certificate) { c.URIs = []*url.URL{relative} }},
+		{"short IP address", func(c *Certificate) { c.IPAddresses = []net.IP{{1, 2}} }},
+	}
+	for _, test := range tests {
+		template := &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Leaf"},
+			NotBefore:    time.Unix(1000, 0),
+			NotAfter:     time.Unix(100000, 0),
+		}
+		test.mod(template)
+		if _, err := CreateCertificate(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey); err == nil {
+			t.Errorf("%s: CreateCertificate succeeded", test.name)
+		}
+		template.InsecureSkipSANValidation = true
+		if _, err := CreateCertificate(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey); err != nil {
+			t.Errorf("%s: CreateCertificate with InsecureSkipSANValidation failed: %v", test.name, err)
+		}
+	}
+
+	template := &Certificate{
+		SerialNumber:   big.NewInt(1),
+		NotBefore:      time.Unix(1000, 0),
+		NotAfter:       time.Unix(100000, 0),
+		DNSNames:       []string{"*.example.com", "_service.example.com"},
+		EmailAddresses: []string{"\"quoted local\"@example.com"},
+	}
+	serialiseAndParse(t, template)
+}
+
+func TestCreateCertificateSerialNumber(t *testing.T) {
+	template := &Certificate{
+		Subject:   pkix.Name{CommonName: "Leaf"},
+		NotBefore: time.Unix(1000, 0),
+		NotAfter:  time.Unix(100000, 0),
+	}
+	allow := IssuanceOptions{InsecureAllowNonPositiveSerial: true}
+	for _, serial := range []int64{0, -1} {
+		template.SerialNumber = big.NewInt(serial)
+		if _, err := CreateCertificate(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey); err == nil {
+			t.Errorf("serial %d: CreateCertificate succeeded", serial)
+		}
+		der, err := CreateCertificateWithOptions(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey, allow)
+		if err != nil {
+			t.Fatalf("serial %d: CreateCertificateWithOptions failed: %v", serial, err)
+		}
+		if c, err := ParseCertificate(der); err != nil || c.SerialNumber.Int64() != serial {
+			t.Errorf("serial %d: got %v, %v", serial, c, err)
+		}
+	}
+
+	issued := make(map[string]bool)
+	opts := IssuanceOptions{SerialInUse: func(serial *big.Int) bool {
+		return issued[serial.String()]
+	}}
+	parent := &Certificate{
+		Subject:   pkix.Name{CommonName: "CA"},
+		NotBefore: time.Unix(1000, 0),
+		NotAfter:  time.Unix(100000, 0),
+	}
+	template = &Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Leaf"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+	}
+	if _, err := CreateCertificateWithOptions(rand.Reader, template, parent, &testPrivateKey.PublicKey, testPrivateKey, opts); err != nil {
+		t.Fatalf("CreateCertificateWithOptions failed: %v", err)
+	}
+	issued["42"] = true
+	if _, err := CreateCertificateWithOptions(rand.Reader, template, parent, &testPrivateKey.PublicKey, testPrivateKey, opts); err != ErrSerialInUse {
+		t.Errorf("CreateCertificateWithOptions with a used serial returned %v, want ErrSerialInUse", err)
+	}
+}
+
+func TestCreateCertificateWithoutExtensions(t *testing.T) {
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "no extensions"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+	}
+	cert := serialiseAndParse(t, template)
+	if len(cert.Extensions) != 0 {
+		t.Fatalf("got extensions %v", cert.Extensions)
+	}
+
+	// RFC 5280 doesn't allow an empty extensions field, so it must be
+	// omitted.
+	tbs := cryptobyte.String(cert.RawTBSCertificate)
+	if !tbs.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!tbs.SkipASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) || // signature
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) || // issuer
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) || // validity
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) || // subject
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) { // subjectPublicKeyInfo
+		t.Fatal("malformed TBSCertificate")
+	}
+	if tbs.PeekASN1Tag(cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()) {
+		t.Error("TBSCertificate has an extensions field")
+	}
+	if !tbs.Empty() {
+		t.Errorf("unexpected trailing TBSCertificate fields %x", []byte(tbs))
+	}
+}
+
 func TestMaxPathLenNotCA(t *testing.T) {
 	template := &Certificate{
 		SerialNumber: big.NewInt(1),
@@ -1938,6 +2123,67 @@ func TestISOOIDInCertificate(t *testing.T) {
 	}
 }
 
+func TestUnknownSignatureAlgorithm(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replace ecdsa-with-SHA256 with the unassigned 1.2.840.10045.4.3.127,
+	// in the certificate and in its TBSCertificate.
+	ecdsaWithSHA256 := []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x04, 0x03, 0x02}
+	unknown := []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x04, 0x03, 0x7f}
+	unknownOID := asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 127}
+	der := bytes.Replace(leaf.Raw, ecdsaWithSHA256, unknown, -1)
+
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("certificate with an unknown signature algorithm failed to parse: %v", err)
+	}
+	if cert.SignatureAlgorithm != UnknownSignatureAlgorithm || !cert.SignatureAlgorithmIdentifier.Algorithm.Equal(unknownOID) {
+		t.Errorf("got signature algorithm %v, %v", cert.SignatureAlgorithm, cert.SignatureAlgorithmIdentifier.Algorithm)
+	}
+	if cert.Subject.CommonName != "leaf" || !cert.IsServerAuthCert() {
+		t.Errorf("certificate metadata was not parsed: %v", cert.Subject)
+	}
+	err = cert.CheckSignatureFrom(root)
+	if e, ok := err.(UnknownSignatureAlgorithmError); !ok || !e.Algorithm.Algorithm.Equal(unknownOID) {
+		t.Errorf("CheckSignatureFrom: got %v, want an UnknownSignatureAlgorithmError", err)
+	}
+	if !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Error("UnknownSignatureAlgorithmError is not ErrUnsupportedAlgorithm")
+	}
+	if leaf.SignatureAlgorithmIdentifier.Algorithm.Equal(unknownOID) || leaf.CheckSignatureFrom(root) != nil {
+		t.Error("the original certificate was modified")
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	if _, err := cert.Verify(VerifyOptions{Roots: roots}); err == nil || !strings.Contains(err.Error(), "algorithm unimplemented") {
+		t.Errorf("Verify: got %v, want an unsupported algorithm error", err)
+	}
+
+	csrDER, err := CreateCertificateRequest(rand.Reader, &CertificateRequest{
+		Subject: pkix.Name{CommonName: "request"},
+	}, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := ParseCertificateRequest(bytes.Replace(csrDER, ecdsaWithSHA256, unknown, -1))
+	if err != nil {
+		t.Fatalf("request with an unknown signature algorithm failed to parse: %v", err)
+	}
+	if csr.Subject.CommonName != "request" || !csr.SignatureAlgorithmIdentifier.Algorithm.Equal(unknownOID) {
+		t.Errorf("got request for %v, signed with %v", csr.Subject, csr.SignatureAlgorithmIdentifier.Algorithm)
+	}
+	if err := csr.CheckSignature(); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("CheckSignature: got %v, want an unsupported algorithm error", err)
+	}
+}
+
 // certMultipleRDN contains a RelativeDistinguishedName with two elements (the
 // common name and serial number). This particular certificate was the first
 // such certificate in the “Pilot” Certificate Transparency log.
@@ -2033,6 +2279,78 @@ pa/O1nF0AfWOpw==
 	}
 }
 
+func TestCertPoolAudit(t *testing.T) {
+	defer func(old bool) { debugPoolCallers = old }(debugPoolCallers)
+	debugPoolCallers = true
+
+	root, err := certificateFromPEM(geoTrustRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewCertPool()
+	pool.AddCert(root)
+	pool.AppendCertsFromPEM([]byte(startComRoot))
+
+	info := pool.copy().Audit()
+	if len(info) != 2 {
+		t.Fatalf("Audit returned %d entries; want 2", len(info))
+	}
+	if !info[0].Certificate.Equal(root) {
+		t.Errorf("Audit()[0] is %v; want %v", info[0].Certificate.Subject, root.Subject)
+	}
+	for i, in := range info {
+		if in.System || in.Path != "" {
+			t.Errorf("Audit()[%d] = %+v; want a programmatic entry", i, in)
+		}
+		if !strings.Contains(in.CallSite, "TestCertPoolAudit") || !strings.Contains(in.CallSite, "x509_test.go:") {
+			t.Errorf("Audit()[%d].CallSite = %q; want this test", i, in.CallSite)
+		}
+	}
+}
+
+func TestCertPoolWritePEM(t *testing.T) {
+	var certs []*Certificate
+	for _, data := range []string{geoTrustRoot, startComRoot, startComIntermediate, googleLeaf, googleLeafWithInvalidHash} {
+		cert, err := certificateFromPEM(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+
+	var outputs []string
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 4, 0, 3, 1}} {
+		pool := NewCertPool()
+		for _, i := range order {
+			pool.AddCert(certs[i])
+		}
+		var buf bytes.Buffer
+		if err := pool.WritePEM(&buf); err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, buf.String())
+	}
+	for i := range outputs {
+		if outputs[i] != outputs[0] {
+			t.Fatalf("WritePEM output depends on the insertion order:\n%s\n%s", outputs[0], outputs[i])
+		}
+	}
+
+	pool := NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(outputs[0])) {
+		t.Fatal("failed to parse WritePEM output")
+	}
+	subjects := pool.Subjects()
+	if len(subjects) != len(certs) {
+		t.Fatalf("WritePEM wrote %d certificates; want %d", len(subjects), len(certs))
+	}
+	for i := 1; i < len(subjects); i++ {
+		if bytes.Compare(subjects[i-1], subjects[i]) > 0 {
+			t.Errorf("WritePEM output is not sorted by subject")
+		}
+	}
+}
+
 const emptyNameConstraintsPEM = `
 -----BEGIN CERTIFICATE-----
 MIIC1jCCAb6gAwIBAgICEjQwDQYJKoZIhvcNAQELBQAwKDEmMCQGA1UEAxMdRW1w
@@ -2156,6 +2474,103 @@ func TestRDNSequenceString(t *testing.T) {
 	}
 }
 
+func TestNameRDNsPreserved(t *testing.T) {
+	var (
+		oidDomainComponent = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+		oidUserID          = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+		oidEmailAddress    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+		oidCommonName      = asn1.ObjectIdentifier{2, 5, 4, 3}
+		oidSerialNumber    = asn1.ObjectIdentifier{2, 5, 4, 5}
+	)
+
+	// An order that ToRDNSequence would not produce from the named fields,
+	// with a multi-valued RDN.
+	rdns := pkix.RDNSequence{
+		{{Type: oidDomainComponent, Value: "org"}},
+		{{Type: oidDomainComponent, Value: "example"}},
+		{{Type: oidCommonName, Value: "Jane Doe"}, {Type: oidUserID, Value: "jdoe"}},
+		{{Type: oidSerialNumber, Value: "1"}},
+		{{Type: oidSerialNumber, Value: "2"}},
+		{{Type: oidEmailAddress, Value: "jdoe@example.org"}},
+	}
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{RDNs: rdns},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+	}
+	cert := serialiseAndParse(t, template)
+
+	if !reflect.DeepEqual(cert.Subject.RDNs, rdns) {
+		t.Errorf("RDNs = %v, want %v", cert.Subject.RDNs, rdns)
+	}
+	if !reflect.DeepEqual(cert.Subject.ToRDNSequence(), rdns) {
+		t.Errorf("ToRDNSequence() = %v, want %v", cert.Subject.ToRDNSequence(), rdns)
+	}
+	if got, want := cert.Subject.RDNString(), rdns.String(); got != want {
+		t.Errorf("RDNString() = %q, want %q", got, want)
+	}
+	// String keeps the order of the named fields.
+	withoutRDNs := cert.Subject
+	withoutRDNs.RDNs = nil
+	if got, want := cert.Subject.String(), withoutRDNs.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// Re-encoding the parsed name reproduces it exactly.
+	reissued := serialiseAndParse(t, &Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      cert.Subject,
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+	})
+	if !bytes.Equal(reissued.RawSubject, cert.RawSubject) {
+		t.Error("re-encoding the parsed subject changed it")
+	}
+
+	// Filling a name again replaces its RDNs, which don't alias rdns.
+	var filled pkix.Name
+	filled.FillFromRDNSequence(&rdns)
+	filled.FillFromRDNSequence(&rdns)
+	if !reflect.DeepEqual(filled.RDNs, rdns) {
+		t.Errorf("after filling twice, RDNs = %v, want %v", filled.RDNs, rdns)
+	}
+	filled.RDNs[0] = nil
+	if rdns[0] == nil {
+		t.Error("RDNs aliases the filled sequence")
+	}
+
+	name := cert.Subject
+	for _, tc := range []struct {
+		got, want []string
+	}{
+		{name.DomainComponents(), []string{"org", "example"}},
+		{name.UserIDs(), []string{"jdoe"}},
+		{name.EmailAddresses(), []string{"jdoe@example.org"}},
+		{name.SerialNumbers(), []string{"1", "2"}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+
+	// Modifying a named field falls back to the named fields.
+	name.CommonName = "John Doe"
+	if got := name.ToRDNSequence(); reflect.DeepEqual(got, rdns) {
+		t.Error("modified name was marshaled from RDNs")
+	}
+	if s := name.RDNString(); !strings.Contains(s, "CN=John Doe") {
+		t.Errorf("RDNString() = %q does not reflect the modified CommonName", s)
+	}
+
+	// So does clearing all of them.
+	name = cert.Subject
+	name.CommonName, name.SerialNumber = "", ""
+	if got := name.ToRDNSequence(); len(got) != 0 {
+		t.Errorf("cleared name was marshaled as %v", got)
+	}
+}
+
 const criticalNameConstraintWithUnknownTypePEM = `
 -----BEGIN CERTIFICATE-----
 MIIC/TCCAeWgAwIBAgICEjQwDQYJKoZIhvcNAQELBQAwKDEmMCQGA1UEAxMdRW1w
@@ -2189,6 +2604,71 @@ func TestCriticalNameConstraintWithUnknownType(t *testing.T) {
 	}
 }
 
+func TestUnhandledExtensions(t *testing.T) {
+	private := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 99, 1}
+	critical := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 99, 2}
+	issuerAltName := asn1.ObjectIdentifier{2, 5, 29, 18}
+	ian, err := marshalSANs([]string{"ca.example.com"}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerPolicy, err := ParseOID("2.999.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjectPolicy, err := ParseOID("2.999.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "Extensions"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		DNSNames:       []string{"example.com"},
+		KeyUsage:       KeyUsageDigitalSignature,
+		ExtKeyUsage:    []ExtKeyUsage{ExtKeyUsageServerAuth},
+		PolicyMappings: []PolicyMapping{{issuerPolicy, subjectPolicy}},
+		ExtraExtensions: []pkix.Extension{
+			{Id: private, Value: []byte{0x05, 0x00}},
+			{Id: critical, Critical: true, Value: []byte{0x05, 0x00}},
+			// A decoded issuer alternative name is handled, even if critical.
+			{Id: issuerAltName, Critical: true, Value: ian},
+		},
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, testPrivateKey.Public(), testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Extensions) <= 3 {
+		t.Fatalf("got %d extensions, want the standard ones too", len(cert.Extensions))
+	}
+	if cert.IssuerAltNames == nil {
+		t.Error("issuer alternative name was not decoded")
+	}
+	// Policy mappings are decoded but only enforced when Verify processes
+	// policies.
+	mappings := asn1.ObjectIdentifier(oidExtensionPolicyMappings)
+	if !reflect.DeepEqual(cert.UnhandledExtensions, []asn1.ObjectIdentifier{mappings, private, critical}) {
+		t.Errorf("UnhandledExtensions = %v", cert.UnhandledExtensions)
+	}
+	if !reflect.DeepEqual(cert.UnhandledCriticalExtensions, []asn1.ObjectIdentifier{mappings, critical}) {
+		t.Errorf("UnhandledCriticalExtensions = %v", cert.UnhandledCriticalExtensions)
+	}
+
+	// A malformed issuer alternative name is unhandled.
+	template.PolicyMappings = nil
+	template.ExtraExtensions = []pkix.Extension{{Id: issuerAltName, Value: []byte{0x30, 0x03, 0x82, 0x05, 0x00}}}
+	cert = serialiseAndParse(t, template)
+	if cert.IssuerAltNames != nil || !reflect.DeepEqual(cert.UnhandledExtensions, []asn1.ObjectIdentifier{issuerAltName}) {
+		t.Errorf("malformed issuer alternative name: decoded %+v, unhandled %v", cert.IssuerAltNames, cert.UnhandledExtensions)
+	}
+}
+
 const badIPMaskPEM = `
 -----BEGIN CERTIFICATE-----
 MIICzzCCAbegAwIBAgICEjQwDQYJKoZIhvcNAQELBQAwHTEbMBkGA1UEAxMSQmFk
@@ -2301,6 +2781,267 @@ func TestEmptySubject(t *testing.T) {
 	t.Fatal("SAN extension is missing")
 }
 
+func TestEmptySubjectExtraExtensions(t *testing.T) {
+	// SANs from ExtraExtensions are marked critical too, without changing
+	// the template.
+	san, err := marshalSANs(nil, nil, nil, []*url.URL{{Scheme: "urn", Opaque: "dev:ops:1234-device-1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Certificate{
+		SerialNumber:    big.NewInt(1),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionSubjectAltName, Value: san}},
+	}
+	derBytes, err := CreateCertificate(rand.Reader, &template, &template, &testPrivateKey.PublicKey, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.SubjectAltNameCritical || len(cert.URIs) != 1 {
+		t.Errorf("got critical %v, URIs %v", cert.SubjectAltNameCritical, cert.URIs)
+	}
+	if template.ExtraExtensions[0].Critical {
+		t.Error("CreateCertificate modified the template")
+	}
+}
+
+func TestVerifyEmptySubject(t *testing.T) {
+	// Empty-subject leaves are verified by their SANs, even under directory
+	// name constraints and with the Common Name taken as a hostname.
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &Certificate{
+		SerialNumber:            big.NewInt(2),
+		Subject:                 pkix.Name{CommonName: "Constrained CA"},
+		NotBefore:               time.Now().Add(-time.Hour),
+		NotAfter:                time.Now().Add(time.Hour),
+		KeyUsage:                KeyUsageCertSign,
+		BasicConstraintsValid:   true,
+		IsCA:                    true,
+		PermittedDNSDomains:     []string{"example.com"},
+		PermittedDirectoryNames: []pkix.Name{{Organization: []string{"Example"}}},
+	}
+	derBytes, err := CreateCertificate(rand.Reader, ca, root, &testPrivateKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = ParseCertificate(derBytes); err != nil {
+		t.Fatal(err)
+	}
+	leaf := &Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"device.example.com"},
+	}
+	if derBytes, err = CreateCertificate(rand.Reader, leaf, ca, &testPrivateKey.PublicKey, testPrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = ParseCertificate(derBytes); err != nil {
+		t.Fatal(err)
+	}
+	roots, intermediates := NewCertPool(), NewCertPool()
+	roots.AddCert(root)
+	intermediates.AddCert(ca)
+	opts := VerifyOptions{
+		Roots:            roots,
+		Intermediates:    intermediates,
+		DNSName:          "device.example.com",
+		LegacyCommonName: CommonNameAsHostname,
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Errorf("empty-subject leaf: %v", err)
+	}
+	opts.DNSName = "example.org"
+	if _, err := leaf.Verify(opts); err == nil || err.Error() != "x509: certificate is valid for device.example.com, not example.org" {
+		t.Errorf("empty-subject leaf for another name: got %v", err)
+	}
+}
+
+func TestExtensionCriticality(t *testing.T) {
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageServerAuth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "criticality"},
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Unix(100000, 0),
+		KeyUsage:              KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"example.com"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{1, 2, 3}},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionExtendedKeyUsage, Critical: true, Value: eku},
+		},
+	}
+
+	cert := serialiseAndParse(t, template)
+	if !cert.KeyUsageCritical {
+		t.Error("KeyUsageCritical is false")
+	}
+	if !cert.BasicConstraintsCritical {
+		t.Error("BasicConstraintsCritical is false")
+	}
+	if !cert.ExtKeyUsageCritical {
+		t.Error("ExtKeyUsageCritical is false")
+	}
+	if cert.SubjectAltNameCritical {
+		t.Error("SubjectAltNameCritical is true for a certificate with a subject")
+	}
+	if cert.PolicyIdentifiersCritical {
+		t.Error("PolicyIdentifiersCritical is true")
+	}
+
+	template.Subject = pkix.Name{}
+	if cert := serialiseAndParse(t, template); !cert.SubjectAltNameCritical {
+		t.Error("SubjectAltNameCritical is false for a certificate with an empty subject")
+	}
+}
+
+func TestKeyUsageEncoding(t *testing.T) {
+	// digitalSignature, encoded with 16 bits instead of 1, as some HSMs do.
+	nonMinimal := []byte{0x03, 0x03, 0x00, 0x80, 0x00}
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "key usage"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Unix(100000, 0),
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionKeyUsage, Critical: true, Value: nonMinimal},
+		},
+	}
+	cert := serialiseAndParse(t, template)
+	if cert.KeyUsage != KeyUsageDigitalSignature {
+		t.Errorf("got KeyUsage %v, want %v", cert.KeyUsage, KeyUsageDigitalSignature)
+	}
+	if !bytes.Equal(cert.RawKeyUsage, nonMinimal) {
+		t.Errorf("got RawKeyUsage %x, want %x", cert.RawKeyUsage, nonMinimal)
+	}
+	if err := checkKeyUsageEncoding(cert.RawKeyUsage); err == nil {
+		t.Error("non-minimal KeyUsage accepted as DER")
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(cert)
+	if _, err := cert.Verify(VerifyOptions{Roots: roots, CurrentTime: time.Unix(2000, 0)}); err != nil {
+		t.Errorf("non-minimal KeyUsage rejected by default: %v", err)
+	}
+	_, err := cert.Verify(VerifyOptions{Roots: roots, CurrentTime: time.Unix(2000, 0), StrictKeyUsageEncoding: true})
+	if invalid, ok := err.(CertificateInvalidError); !ok || invalid.Reason != NonCanonicalKeyUsage {
+		t.Errorf("got error %v, want reason NonCanonicalKeyUsage", err)
+	}
+
+	reissue := TemplateFromCertificate(cert)
+	for _, e := range reissue.ExtraExtensions {
+		if e.Id.Equal(oidExtensionKeyUsage) {
+			t.Error("KeyUsage extension copied into ExtraExtensions")
+		}
+	}
+	if got := serialiseAndParse(t, reissue).RawKeyUsage; !bytes.Equal(got, nonMinimal) {
+		t.Errorf("reissued certificate has KeyUsage %x, want %x", got, nonMinimal)
+	}
+
+	reissue.KeyUsage |= KeyUsageCertSign
+	minimal := []byte{0x03, 0x02, 0x02, 0x84}
+	got := serialiseAndParse(t, reissue).RawKeyUsage
+	if !bytes.Equal(got, minimal) {
+		t.Errorf("modified certificate has KeyUsage %x, want %x", got, minimal)
+	}
+	if err := checkKeyUsageEncoding(got); err != nil {
+		t.Errorf("minimal KeyUsage rejected: %v", err)
+	}
+	if err := checkKeyUsageEncoding([]byte{0x03, 0x01, 0x00}); err == nil {
+		t.Error("empty KeyUsage accepted as DER")
+	}
+}
+
+func TestDirectoryNameAndIssuerAltName(t *testing.T) {
+	dirName := pkix.Name{
+		Country:      []string{"ES"},
+		Organization: []string{"Ministerio"},
+		SerialNumber: "IDCES-12345678Z",
+		CommonName:   "Juan Español",
+	}
+	ian, err := marshalSANs([]string{"ca.example.com"}, []string{"ca@example.com"}, nil, nil, []pkix.Name{{CommonName: "Issuing Authority"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "directory name"},
+		NotBefore:      time.Unix(1000, 0),
+		NotAfter:       time.Unix(100000, 0),
+		DNSNames:       []string{"example.com"},
+		DirectoryNames: []pkix.Name{dirName},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 18}, Value: ian},
+		},
+	}
+	cert := serialiseAndParse(t, template)
+
+	if len(cert.DirectoryNames) != 1 {
+		t.Fatalf("got %d directory names, want 1", len(cert.DirectoryNames))
+	}
+	if got, want := cert.DirectoryNames[0].String(), dirName.String(); got != want {
+		t.Errorf("got directoryName %q, want %q", got, want)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "example.com" {
+		t.Errorf("unexpected DNS names %v", cert.DNSNames)
+	}
+
+	names := cert.IssuerAltNames
+	if names == nil {
+		t.Fatal("issuer alternative name was not parsed")
+	}
+	if !reflect.DeepEqual(names.DNSNames, []string{"ca.example.com"}) ||
+		!reflect.DeepEqual(names.EmailAddresses, []string{"ca@example.com"}) ||
+		len(names.DirectoryNames) != 1 || names.DirectoryNames[0].CommonName != "Issuing Authority" {
+		t.Errorf("unexpected issuer alternative names %+v", names)
+	}
+
+	// A directoryName-only SAN satisfies the requirement for a SAN when the
+	// subject is empty.
+	template.Subject = pkix.Name{}
+	template.DNSNames = nil
+	template.ExtraExtensions = nil
+	cert = serialiseAndParse(t, template)
+	if !cert.SubjectAltNameCritical || len(cert.UnhandledCriticalExtensions) != 0 {
+		t.Errorf("directoryName-only SAN: critical %v, unhandled %v", cert.SubjectAltNameCritical, cert.UnhandledCriticalExtensions)
+	}
+}
+
+func TestMalformedDirectoryNameSAN(t *testing.T) {
+	// A dNSName and a directoryName holding a NULL instead of a Name.
+	san := []byte{0x30, 0x11, 0x82, 0x0b}
+	san = append(san, "example.com"...)
+	san = append(san, 0xa4, 0x02, 0x05, 0x00)
+	template := &Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "Malformed directoryName"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionSubjectAltName, Value: san}},
+	}
+	der, err := CreateCertificate(rand.Reader, template, template, testPrivateKey.Public(), testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("certificate with a malformed directoryName SAN failed to parse: %v", err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "example.com" || len(cert.DirectoryNames) != 0 {
+		t.Errorf("got DNSNames %v and DirectoryNames %v", cert.DNSNames, cert.DirectoryNames)
+	}
+}
+
 // multipleURLsInCRLDPPEM contains two URLs in a single CRL DistributionPoint
 // structure. It is taken from https://crt.sh/?id=12721534.
 const multipleURLsInCRLDPPEM = `
@@ -2663,3 +3404,131 @@ func TestCreateRevocationList(t *testing.T) {
 		})
 	}
 }
+
+func TestCertificateUsagePredicates(t *testing.T) {
+	name := []byte("name")
+	other := []byte("other")
+	tests := []struct {
+		name       string
+		cert       *Certificate
+		ca         bool
+		selfIssued bool
+		serverAuth bool
+		bc         BasicConstraintsState
+	}{
+		{
+			name:       "v1 root",
+			cert:       &Certificate{Version: 1, RawSubject: name, RawIssuer: name},
+			ca:         true,
+			selfIssued: true,
+			serverAuth: true,
+		},
+		{
+			name:       "v3 root",
+			cert:       &Certificate{Version: 3, BasicConstraintsValid: true, IsCA: true, KeyUsage: KeyUsageCertSign, RawSubject: name, RawIssuer: name},
+			ca:         true,
+			selfIssued: true,
+			serverAuth: true,
+			bc:         BasicConstraintsCA,
+		},
+		{
+			name:       "v3 without basic constraints",
+			cert:       &Certificate{Version: 3, RawSubject: name, RawIssuer: other},
+			serverAuth: true,
+		},
+		{
+			name: "v3 CA without certSign",
+			cert: &Certificate{Version: 3, BasicConstraintsValid: true, IsCA: true, KeyUsage: KeyUsageDigitalSignature, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageClientAuth}},
+			bc:   BasicConstraintsCA,
+		},
+		{
+			name:       "v1 with cA false",
+			cert:       &Certificate{Version: 1, BasicConstraintsValid: true, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageAny}},
+			serverAuth: true,
+			bc:         BasicConstraintsNotCA,
+		},
+		{
+			name:       "server",
+			cert:       &Certificate{Version: 3, BasicConstraintsValid: true, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageClientAuth, ExtKeyUsageServerAuth}, RawSubject: name, RawIssuer: other},
+			serverAuth: true,
+			bc:         BasicConstraintsNotCA,
+		},
+		{
+			name:       "server gated crypto",
+			cert:       &Certificate{Version: 3, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageNetscapeServerGatedCrypto}},
+			serverAuth: true,
+		},
+		{
+			name: "unknown usage only",
+			cert: &Certificate{Version: 3, UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 2, 3}}},
+		},
+		{
+			name: "unparsed",
+			cert: &Certificate{Version: 3, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageCodeSigning}},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.cert.IsCACert(); got != tt.ca {
+			t.Errorf("%s: IsCACert() = %v, want %v", tt.name, got, tt.ca)
+		}
+		if got := tt.cert.IsSelfIssued(); got != tt.selfIssued {
+			t.Errorf("%s: IsSelfIssued() = %v, want %v", tt.name, got, tt.selfIssued)
+		}
+		if got := tt.cert.IsServerAuthCert(); got != tt.serverAuth {
+			t.Errorf("%s: IsServerAuthCert() = %v, want %v", tt.name, got, tt.serverAuth)
+		}
+		if got := tt.cert.BasicConstraintsState(); got != tt.bc {
+			t.Errorf("%s: BasicConstraintsState() = %v, want %v", tt.name, got, tt.bc)
+		}
+	}
+}
+
+func TestConcurrentCertPool(t *testing.T) {
+	root, rootKey, err := generateCert("Root", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+
+	intermediates := NewConcurrentCertPool()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates})
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		other, _, err := generateCert("Other", true, root, rootKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intermediates.AddCert(other)
+	}
+	if !intermediates.AppendCertsFromPEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Raw})) {
+		t.Fatal("AppendCertsFromPEM failed")
+	}
+	wg.Wait()
+
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("Verify with the concurrent pool: %v", err)
+	}
+	if n := len(intermediates.Subjects()); n != 11 {
+		t.Errorf("got %d subjects, want 11", n)
+	}
+	if info := intermediates.copy().Audit(); len(info) != 11 || !info[10].Certificate.Equal(intermediate) {
+		t.Errorf("copy of the concurrent pool has %d certificates", len(info))
+	}
+}
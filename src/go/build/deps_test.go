@@ -412,12 +412,13 @@ var pkgDeps = map[string][]string{
 		"container/list", "context", "crypto/x509", "encoding/pem", "net", "syscall", "crypto/ed25519",
 	},
 	"crypto/x509": {
-		"L4", "CRYPTO-MATH", "OS", "CGO", "crypto/ed25519", "crypto/x509/internal/macOS",
+		"L4", "CRYPTO-MATH", "OS", "CGO", "context", "crypto/ed25519", "crypto/x509/internal/macOS",
 		"crypto/x509/pkix", "encoding/pem", "encoding/hex", "net", "os/user", "syscall", "net/url",
 		"golang.org/x/crypto/cryptobyte", "golang.org/x/crypto/cryptobyte/asn1",
 	},
-	"crypto/x509/pkix":           {"L4", "CRYPTO-MATH", "encoding/hex"},
-	"crypto/x509/internal/macOS": {"L4"},
+	"crypto/x509/pkix":              {"L4", "CRYPTO-MATH", "encoding/hex"},
+	"crypto/x509/internal/macOS":    {"L4"},
+	"crypto/x509/internal/x509test": {"L4", "CRYPTO-MATH", "crypto/x509"},
 
 	// Simple net+crypto-aware packages.
 	"mime/multipart": {"L4", "OS", "mime", "crypto/rand", "net/textproto", "mime/quotedprintable"},